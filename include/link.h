@@ -143,6 +143,8 @@ struct	LSym
 	uchar	fnptr;	// arm only
 	uchar	seenglobl;
 	uchar	onlist;	// on the textp or datap lists
+	uchar	local;	// never exported from a Go shared library or a program linked against one
+	uchar	elftype;	// ELF symbol type of a symbol from a Go shared library
 	int16	symid;	// for writing .5/.6/.8 files
 	int32	dynid;
 	int32	sig;
@@ -254,6 +256,7 @@ enum
 	R_PLT1,
 	R_PLT2,
 	R_USEFIELD,
+	R_GOTPCREL, // PC-relative offset of the symbol's GOT entry
 };
 
 // Auto.type
@@ -373,6 +376,7 @@ struct	Link
 	int32	debugfloat;	// -F flag in 5l
 	int32	debugpcln;	// -O flag in linker
 	int32	flag_shared;	// -shared flag in linker
+	int32	flag_dynlink;	// -dynlink flag in compiler and assembler
 	int32	iself;
 	Biobuf*	bso;	// for -v flag
 	char*	pathname;
@@ -518,6 +522,16 @@ enum
 	LinkExternal,
 };
 
+// Build modes, chosen by the linker's -buildmode flag.
+enum
+{
+	BuildmodeExe = 0,
+	BuildmodeCArchive,
+	BuildmodeCShared,
+	BuildmodePIE,
+	BuildmodeShared,
+};
+
 extern	uchar	fnuxi8[8];
 extern	uchar	fnuxi4[4];
 extern	uchar	inuxi1[1];
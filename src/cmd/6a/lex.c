@@ -115,12 +115,16 @@ main(int argc, char *argv[])
 	flagfn1("D", "name[=value]: add #define", dodef);
 	flagfn1("I", "dir: add dir to include path", setinclude);
 	flagcount("S", "print assembly and machine code", &debug['S']);
+	flagcount("dynlink", "support references to Go symbols defined in other shared libraries", &ctxt->flag_dynlink);
 	flagcount("m", "debug preprocessor macros", &debug['m']);
 	flagstr("o", "file: set output file", &outfile);
-	flagstr("trimpath", "prefix: remove prefix from recorded source file paths", &ctxt->trimpath);
+	flagcount("shared", "generate code that can be linked into a shared library", &ctxt->flag_shared);
+	flagstr("trimpath", "prefixes: remove semicolon-separated prefixes from recorded source file paths", &ctxt->trimpath);
 
 	flagparse(&argc, &argv, usage);
 	ctxt->debugasm = debug['S'];
+	if(ctxt->flag_dynlink)
+		ctxt->flag_shared = 1;
 
 	if(argc < 1)
 		usage();
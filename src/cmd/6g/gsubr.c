@@ -287,6 +287,9 @@ ginit(void)
 	if(nacl) {
 		reg[D_BP]++;
 		reg[D_R15]++;
+	} else if(flag_dynlink) {
+		// R15 is used by liblink to load addresses from the GOT.
+		reg[D_R15]++;
 	}
 }
 
@@ -300,6 +303,8 @@ gclean(void)
 	if(nacl) {
 		reg[D_BP]--;
 		reg[D_R15]--;
+	} else if(flag_dynlink) {
+		reg[D_R15]--;
 	}
 
 
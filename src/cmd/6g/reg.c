@@ -1181,6 +1181,8 @@ BtoR(uint32 b)
 	b &= 0xffffL;
 	if(nacl)
 		b &= ~((1<<(D_BP-D_AX)) | (1<<(D_R15-D_AX)));
+	else if(flag_dynlink)
+		b &= ~(1<<(D_R15-D_AX));	// used for GOT loads
 	if(b == 0)
 		return 0;
 	return bitno(b) + D_AX;
@@ -862,6 +862,7 @@ enum
 	D_FCONST	= 119,
 	D_SCONST	= 120,
 	D_ADDR		= 121,
+	D_GOTREF,	/* sym@GOT(SB): the GOT entry of sym, for -dynlink */
 
 	D_INDIR,	/* additive */
 
@@ -288,12 +288,23 @@ elfreloc1(Reloc *r, vlong sectoff)
 		else
 			return -1;
 		break;
+
+	case R_TLS_IE:
+		if(r->siz == 4)
+			VPUT(R_X86_64_GOTTPOFF | (uint64)elfsym<<32);
+		else
+			return -1;
+		break;
 		
 	case R_CALL:
 		if(r->siz == 4) {
-			if(r->xsym->type == SDYNIMPORT)
-				VPUT(R_X86_64_GOTPCREL | (uint64)elfsym<<32);
-			else
+			if(r->xsym->type == SDYNIMPORT) {
+				// Calls between Go modules go through the PLT.
+				if(dynlinking())
+					VPUT(R_X86_64_PLT32 | (uint64)elfsym<<32);
+				else
+					VPUT(R_X86_64_GOTPCREL | (uint64)elfsym<<32);
+			} else
 				VPUT(R_X86_64_PC32 | (uint64)elfsym<<32);
 		} else
 			return -1;
@@ -301,11 +312,21 @@ elfreloc1(Reloc *r, vlong sectoff)
 
 	case R_PCREL:
 		if(r->siz == 4) {
-			VPUT(R_X86_64_PC32 | (uint64)elfsym<<32);
+			if(r->xsym->type == SDYNIMPORT && r->xsym->elftype == STT_FUNC)
+				VPUT(R_X86_64_PLT32 | (uint64)elfsym<<32);
+			else
+				VPUT(R_X86_64_PC32 | (uint64)elfsym<<32);
 		} else
 			return -1;
 		break;
 
+	case R_GOTPCREL:
+		if(r->siz == 4)
+			VPUT(R_X86_64_GOTPCREL | (uint64)elfsym<<32);
+		else
+			return -1;
+		break;
+
 	case R_TLS:
 		if(r->siz == 4) {
 			if(flag_shared)
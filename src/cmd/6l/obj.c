@@ -59,8 +59,11 @@ archinit(void)
 	if(linkmode == LinkAuto && strcmp(getgoextlinkenabled(), "0") == 0)
 		linkmode = LinkInternal;
 
-	if(flag_shared)
+	if(flag_shared) {
+		if(HEADTYPE != Hlinux)
+			sysfatal("cannot use -shared, -buildmode or -linkshared with -H %s", headstr(HEADTYPE));
 		linkmode = LinkExternal;
+	}
 
 	switch(HEADTYPE) {
 	default:
@@ -112,7 +112,7 @@ main(int argc, char *argv[])
 	flagcount("S", "print assembly and machine code", &debug['S']);
 	flagcount("m", "debug preprocessor macros", &debug['m']);
 	flagstr("o", "file: set output file", &outfile);
-	flagstr("trimpath", "prefix: remove prefix from recorded source file paths", &ctxt->trimpath);
+	flagstr("trimpath", "prefixes: remove semicolon-separated prefixes from recorded source file paths", &ctxt->trimpath);
 
 	flagparse(&argc, &argv, usage);
 	ctxt->debugasm = debug['S'];
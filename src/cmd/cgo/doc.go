@@ -198,6 +198,13 @@ since it is copied into two different C output files, it must not
 contain any definitions, only declarations. Definitions must be
 placed in preambles in other files, or in C source files.
 
+The exported functions of a main package are the C interface of
+the library written by 'go build -buildmode=c-archive' or
+'go build -buildmode=c-shared'. The go command writes the
+_cgo_export.h header next to the library, named after it:
+building libfoo.so also writes libfoo.h. The header declares
+the exported functions with C linkage also when compiled as C++.
+
 Using cgo directly
 
 Usage:
@@ -638,6 +638,8 @@ func (p *Package) writeExports(fgo2, fc, fm io.Writer) {
 	fmt.Fprintf(fgcch, "/* Created by cgo - DO NOT EDIT. */\n")
 	fmt.Fprintf(fgcch, "%s\n", p.Preamble)
 	fmt.Fprintf(fgcch, "%s\n", p.gccExportHeaderProlog())
+	fmt.Fprintf(fgcch, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n")
+	defer fmt.Fprintf(fgcch, "\n#ifdef __cplusplus\n}\n#endif\n")
 
 	fmt.Fprintf(fgcc, "/* Created by cgo - DO NOT EDIT. */\n")
 	fmt.Fprintf(fgcc, "#include \"_cgo_export.h\"\n")
@@ -0,0 +1,77 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Run this shell script, but do it in Go so it can be run by "go test".
+//
+//	replace the word LINE with the line number < testdata/test.go > $tmp/test_line.go
+//	go build -o $tmp/testcover cmd/cover
+//	$tmp/testcover -mode=count -var=coverTest -o $tmp/test_cover.go $tmp/test_line.go
+//	cp testdata/main.go $tmp/main.go
+//	go run $tmp/main.go $tmp/test_cover.go
+//
+func TestCover(t *testing.T) {
+	switch runtime.GOOS {
+	case "android", "nacl":
+		t.Skipf("skipping on %s", runtime.GOOS)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "TestCover")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Read in the test file (testdata/test.go) and write it, with LINEs specified, to coverInput.
+	file, err := ioutil.ReadFile(filepath.Join("testdata", "test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(file, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.Replace(line, []byte("LINE"), []byte(fmt.Sprint(i+1)), -1)
+	}
+	coverInput := filepath.Join(tmpDir, "test_line.go")
+	if err := ioutil.WriteFile(coverInput, bytes.Join(lines, []byte("\n")), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	testcover := filepath.Join(tmpDir, "testcover.exe")
+	out, err := exec.Command("go", "build", "-o", testcover, "cmd/cover").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build -o %v cmd/cover: %v\n%s", testcover, err, out)
+	}
+
+	coverOutput := filepath.Join(tmpDir, "test_cover.go")
+	out, err = exec.Command(testcover, "-mode=count", "-var=coverTest", "-o", coverOutput, coverInput).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v -mode=count: %v\n%s", testcover, err, out)
+	}
+
+	// go run wants all its files in one directory.
+	main, err := ioutil.ReadFile(filepath.Join("testdata", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMain := filepath.Join(tmpDir, "main.go")
+	if err := ioutil.WriteFile(testMain, main, 0666); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command("go", "run", testMain, coverOutput).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+}
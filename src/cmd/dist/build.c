@@ -687,7 +687,7 @@ install(char *dir)
 	}
 
 	islib = hasprefix(dir, "lib") || streq(dir, "cmd/gc");
-	ispkg = !islib && !hasprefix(dir, "cmd/");
+	ispkg = !islib && (!hasprefix(dir, "cmd/") || hasprefix(dir, "cmd/internal/"));
 	isgo = ispkg || streq(dir, "cmd/go") || streq(dir, "cmd/cgo");
 
 	exe = "";
@@ -924,7 +924,7 @@ install(char *dir)
 			bwriteb(&archive, &b);
 
 		vadd(&compile, "-p");
-		if(hasprefix(dir, "cmd/"))
+		if(!ispkg)
 			vadd(&compile, "main");
 		else
 			vadd(&compile, dir);
@@ -1295,6 +1295,7 @@ static char *buildorder[] = {
 	"encoding/base64",
 	"syscall",
 	"time",
+	"internal/testlog",
 	"os",
 	"reflect",
 	"fmt",
@@ -1318,6 +1319,7 @@ static char *buildorder[] = {
 	"text/template",
 	"go/doc",
 	"go/build",
+	"cmd/internal/test2json",
 	"cmd/go",
 };
 
@@ -1347,6 +1349,7 @@ static char *cleantab[] = {
 	// Go packages.
 	"bufio",
 	"bytes",
+	"cmd/internal/test2json",
 	"container/heap",
 	"encoding",
 	"encoding/base64",
@@ -1360,6 +1363,7 @@ static char *cleantab[] = {
 	"go/parser",
 	"go/scanner",
 	"go/token",
+	"internal/testlog",
 	"io",
 	"io/ioutil",
 	"log",
@@ -50,6 +50,9 @@ Flags:
 		show entire file path when printing line numbers in errors
 	-I dir1 -I dir2
 		add dir1 and dir2 to the list of paths to check for imported packages
+	-importmap source=actual
+		compile imports of source as imports of the package actual,
+		such as a vendored copy of source
 	-N
 		disable optimizations
 	-nolocalimports
@@ -64,6 +67,12 @@ Flags:
 		print the compiler version
 	-race
 		compile with race detection enabled
+	-shared
+		generate code that can be linked into a shared library;
+		only supported on linux/amd64
+	-dynlink
+		generate code that can refer to Go symbols defined in other
+		shared libraries, as for -linkshared; implies -shared
 
 There are also a number of debugging flags; run the command with no arguments
 to get a usage message.
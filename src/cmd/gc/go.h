@@ -788,6 +788,14 @@ struct Idir
 	char*	dir;
 };
 
+typedef	struct	Importmap	Importmap;
+struct Importmap
+{
+	Importmap*	link;
+	char*	source;
+	char*	actual;
+};
+
 /*
  * argument passing to/from
  * smagic and umagic
@@ -901,6 +909,7 @@ extern	char*	runtimeimport;
 extern	char*	unsafeimport;
 EXTERN	char*	myimportpath;
 EXTERN	Idir*	idirs;
+EXTERN	Importmap*	importmap;
 EXTERN	char*	localimport;
 EXTERN	char*	asmhdr;
 
@@ -985,6 +994,8 @@ EXTERN	int	pure_go;
 EXTERN	char*	flag_installsuffix;
 EXTERN	int	flag_race;
 EXTERN	int	flag_largemodel;
+EXTERN	int	flag_shared;
+EXTERN	int	flag_dynlink;
 EXTERN	int	noescape;
 EXTERN	int	nosplit;
 EXTERN	int	debuglive;
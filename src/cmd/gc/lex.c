@@ -28,6 +28,7 @@ static void	ungetc(int);
 static int32	getr(void);
 static int	escchar(int, int*, vlong*);
 static void	addidir(char*);
+static void	addimportmap(char*);
 static int	getlinepragma(void);
 static char *goos, *goarch, *goroot;
 
@@ -295,8 +296,11 @@ main(int argc, char *argv[])
 	flagfn0("V", "print compiler version", doversion);
 	flagcount("W", "debug parse tree after type checking", &debug['W']);
 	flagstr("asmhdr", "file: write assembly header to named file", &asmhdr);
+	flagfn1("importmap", "source=actual: use package actual for imports of source", addimportmap);
 	flagcount("complete", "compiling complete package (no C or assembly)", &pure_go);
 	flagstr("d", "list: print debug information about items in list", &debugstr);
+	if(thechar == '6')
+		flagcount("dynlink", "support references to Go symbols defined in other shared libraries", &flag_dynlink);
 	flagcount("e", "no limit on number of errors reported", &debug['e']);
 	flagcount("f", "debug stack frames", &debug['f']);
 	flagcount("g", "debug code generation", &debug['g']);
@@ -314,7 +318,9 @@ main(int argc, char *argv[])
 	flagcount("r", "debug generated wrappers", &debug['r']);
 	flagcount("race", "enable race detector", &flag_race);
 	flagcount("s", "warn about composite literals that can be simplified", &debug['s']);
-	flagstr("trimpath", "prefix: remove prefix from recorded source file paths", &ctxt->trimpath);
+	if(thechar == '6')
+		flagcount("shared", "generate code that can be linked into a shared library", &flag_shared);
+	flagstr("trimpath", "prefixes: remove semicolon-separated prefixes from recorded source file paths", &ctxt->trimpath);
 	flagcount("u", "reject unsafe code", &safemode);
 	flagcount("v", "increase debug verbosity", &debug['v']);
 	flagcount("w", "debug type checking", &debug['w']);
@@ -328,6 +334,10 @@ main(int argc, char *argv[])
 	flagparse(&argc, &argv, usage);
 	ctxt->debugasm = debug['S'];
 	ctxt->debugvlog = debug['v'];
+	if(flag_dynlink)
+		flag_shared = 1;
+	ctxt->flag_shared = flag_shared;
+	ctxt->flag_dynlink = flag_dynlink;
 
 	if(argc < 1)
 		usage();
@@ -597,6 +607,28 @@ addidir(char* dir)
 	(*pp)->dir = dir;
 }
 
+// addimportmap records that imports of the path source
+// refer to the package with path actual, as when
+// the go command resolves an import to a vendored copy.
+static void
+addimportmap(char *s)
+{
+	Importmap *m;
+	char *eq;
+
+	eq = strchr(s, '=');
+	if(eq == nil || eq == s || eq[1] == '\0' || strchr(eq+1, '=') != nil) {
+		print("-importmap argument must be of the form source=actual\n");
+		errorexit();
+	}
+	m = mal(sizeof(Importmap));
+	m->source = mal(eq - s + 1);
+	memmove(m->source, s, eq - s);
+	m->actual = strdup(eq+1);
+	m->link = importmap;
+	importmap = m;
+}
+
 // is this path a local name?  begins with ./ or ../ or /
 static int
 islocalname(Strlit *name)
@@ -694,6 +726,7 @@ importfile(Val *f, int line)
 	int len;
 	Strlit *path;
 	char *cleanbuf, *prefix;
+	Importmap *m;
 
 	USED(line);
 
@@ -740,6 +773,12 @@ importfile(Val *f, int line)
 	}
 	
 	path = f->u.sval;
+	for(m = importmap; m != nil; m = m->link) {
+		if(strcmp(path->s, m->source) == 0) {
+			path = strlit(m->actual);
+			break;
+		}
+	}
 	if(islocalname(path)) {
 		if(path->s[0] == '/') {
 			yyerror("import path cannot be absolute path");
@@ -530,17 +530,30 @@ dimportpath(Pkg *p)
 static int
 dgopkgpath(Sym *s, int ot, Pkg *pkg)
 {
+	Node *n;
+
 	if(pkg == nil)
 		return dgostringptr(s, ot, nil);
 
 	// Emit reference to go.importpath.""., which 6l will
 	// rewrite using the correct import path.  Every package
 	// that imports this one directly defines the symbol.
+	// A package in a Go shared library need not be imported
+	// by any other, so with -dynlink it defines the symbol too.
 	if(pkg == localpkg) {
 		static Sym *ns;
 
-		if(ns == nil)
+		if(ns == nil) {
 			ns = pkglookup("importpath.\"\".", mkpkg(strlit("go")));
+			if(flag_dynlink && myimportpath != nil) {
+				n = nod(ONAME, N, N);
+				n->sym = ns;
+				n->class = PEXTERN;
+				n->xoffset = 0;
+				gdatastring(n, strlit(myimportpath));
+				ggloblsym(ns, types[TSTRING]->width, DUPOK|RODATA);
+			}
+		}
 		return dsymptr(s, ot, ns, 0);
 	}
 
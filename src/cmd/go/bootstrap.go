@@ -16,6 +16,7 @@ import (
 )
 
 var errHTTP = errors.New("no http in bootstrap go command")
+var errModules = errors.New("no modules in bootstrap go command")
 
 func httpGET(url string) ([]byte, error) {
 	return nil, errHTTP
@@ -25,6 +26,38 @@ func httpsOrHTTP(importPath string) (string, io.ReadCloser, error) {
 	return "", nil, errHTTP
 }
 
+func modVersions(path string) ([]string, error) {
+	return nil, errModules
+}
+
+func modFetchGoMod(path, version string) ([]byte, error) {
+	return nil, errModules
+}
+
+func modFetchDir(path, version string) (string, error) {
+	return "", errModules
+}
+
+func (b *builder) buildFromCache(a *action) bool {
+	return false
+}
+
+func (b *builder) saveBuildCache(a *action) {}
+
+func (b *builder) testActionID(a *action) string {
+	return ""
+}
+
+func getTestCache(id, dir string) ([]byte, bool) {
+	return nil, false
+}
+
+func putTestCache(id, dir string, log, out []byte) {}
+
+func cacheTrim() {}
+
+func cleanCache(all, tests bool) {}
+
 func parseMetaGoImports(r io.Reader) ([]metaImport, error) {
 	panic("unreachable")
 }
@@ -58,6 +58,11 @@ and test commands:
 	-a
 		force rebuilding of packages that are already up-to-date.
 		In Go releases, does not apply to the standard library.
+	-buildmode mode
+		build mode to use. See 'go help buildmode' for more.
+	-linkshared
+		link against the Go shared library installed with
+		-buildmode=shared. See 'go help buildmode' for more.
 	-n
 		print the commands but do not run them.
 	-p n
@@ -74,29 +79,43 @@ and test commands:
 	-x
 		print the commands.
 
+	-asmflags '[pattern=]arg list'
+		arguments to pass on each 5a, 6a, or 8a assembler invocation.
 	-ccflags 'arg list'
 		arguments to pass on each 5c, 6c, or 8c compiler invocation.
 	-compiler name
 		name of compiler to use, as in runtime.Compiler (gccgo or gc).
 	-gccgoflags 'arg list'
 		arguments to pass on each gccgo compiler/linker invocation.
-	-gcflags 'arg list'
+	-gcflags '[pattern=]arg list'
 		arguments to pass on each 5g, 6g, or 8g compiler invocation.
 	-installsuffix suffix
 		a suffix to use in the name of the package installation directory,
 		in order to keep output separate from default builds.
 		If using the -race flag, the install suffix is automatically set to race
 		or, if set explicitly, has _race appended to it.
-	-ldflags 'flag list'
+	-ldflags '[pattern=]flag list'
 		arguments to pass on each 5l, 6l, or 8l linker invocation.
 	-tags 'tag list'
 		a list of build tags to consider satisfied during the build.
 		For more information about build tags, see the description of
 		build constraints in the documentation for the go/build package.
+	-toolexec 'cmd args'
+		a program to use to invoke toolchain programs like 6g, 6l and cgo.
+		For example, instead of running 6g, the go command will run
+		'cmd args /path/to/6g <arguments for 6g>'.
 
 The list flags accept a space-separated list of strings. To embed spaces
 in an element in the list, surround it with either single or double quotes.
 
+The -asmflags, -gcflags and -ldflags lists apply to every package unless
+they begin with a package pattern and an equals sign, as in
+-gcflags=mypkg/...=-m, in which case they apply only to the packages
+matching the pattern. The patterns are those described in 'go help
+packages'. These flags may be repeated with different patterns; when
+several match a package, the last one wins. For -ldflags, the pattern
+is matched against the main package being linked.
+
 For more about specifying packages, see 'go help packages'.
 For more about where packages and binaries are installed,
 run 'go help gopath'.  For more about calling between Go and C/C++,
@@ -125,12 +144,16 @@ var buildV bool               // -v flag
 var buildX bool               // -x flag
 var buildI bool               // -i flag
 var buildO = cmdBuild.Flag.String("o", "", "output file")
-var buildWork bool           // -work flag
-var buildGcflags []string    // -gcflags flag
-var buildCcflags []string    // -ccflags flag
-var buildLdflags []string    // -ldflags flag
-var buildGccgoflags []string // -gccgoflags flag
-var buildRace bool           // -race flag
+var buildWork bool               // -work flag
+var buildGcflags perPackageFlag  // -gcflags flag
+var buildCcflags []string        // -ccflags flag
+var buildLdflags perPackageFlag  // -ldflags flag
+var buildAsmflags perPackageFlag // -asmflags flag
+var buildGccgoflags []string     // -gccgoflags flag
+var buildRace bool               // -race flag
+var buildBuildmode string        // -buildmode flag
+var buildLinkshared bool         // -linkshared flag
+var buildToolExec []string       // -toolexec flag
 
 var buildContext = build.Default
 var buildToolchain toolchain = noToolchain{}
@@ -171,17 +194,21 @@ func init() {
 func addBuildFlags(cmd *Command) {
 	// NOTE: If you add flags here, also add them to testflag.go.
 	cmd.Flag.BoolVar(&buildA, "a", false, "")
+	cmd.Flag.StringVar(&buildBuildmode, "buildmode", "default", "")
+	cmd.Flag.BoolVar(&buildLinkshared, "linkshared", false, "")
 	cmd.Flag.BoolVar(&buildN, "n", false, "")
 	cmd.Flag.IntVar(&buildP, "p", buildP, "")
 	cmd.Flag.StringVar(&buildContext.InstallSuffix, "installsuffix", "", "")
 	cmd.Flag.BoolVar(&buildV, "v", false, "")
 	cmd.Flag.BoolVar(&buildX, "x", false, "")
 	cmd.Flag.BoolVar(&buildWork, "work", false, "")
-	cmd.Flag.Var((*stringsFlag)(&buildGcflags), "gcflags", "")
+	cmd.Flag.Var(&buildAsmflags, "asmflags", "")
+	cmd.Flag.Var(&buildGcflags, "gcflags", "")
 	cmd.Flag.Var((*stringsFlag)(&buildCcflags), "ccflags", "")
-	cmd.Flag.Var((*stringsFlag)(&buildLdflags), "ldflags", "")
+	cmd.Flag.Var(&buildLdflags, "ldflags", "")
 	cmd.Flag.Var((*stringsFlag)(&buildGccgoflags), "gccgoflags", "")
 	cmd.Flag.Var((*stringsFlag)(&buildContext.BuildTags), "tags", "")
+	cmd.Flag.Var((*stringsFlag)(&buildToolExec), "toolexec", "")
 	cmd.Flag.Var(buildCompiler{}, "compiler", "")
 	cmd.Flag.BoolVar(&buildRace, "race", false, "")
 }
@@ -258,12 +285,110 @@ func (v *stringsFlag) String() string {
 	return "<stringsFlag>"
 }
 
+// A perPackageFlag is a list flag, like -gcflags, whose settings may
+// be limited to the packages matching a pattern: -gcflags=mypkg/...=-m
+// passes -m only when compiling mypkg and the packages below it.
+// A setting without a pattern applies to every package.
+type perPackageFlag struct {
+	values []perPackageValue
+	extra  []string // added for every package by -race and -buildmode
+}
+
+type perPackageValue struct {
+	match func(p *Package) bool
+	flags []string
+}
+
+func (f *perPackageFlag) Set(s string) error {
+	match := func(p *Package) bool { return true }
+	if s != "" && s[0] != '-' && s[0] != '\'' && s[0] != '"' {
+		i := strings.Index(s, "=")
+		if i < 0 {
+			return fmt.Errorf("missing =<flags> in <pattern>=<flags>")
+		}
+		pattern := strings.TrimSpace(s[:i])
+		if pattern == "" {
+			return fmt.Errorf("missing <pattern> in <pattern>=<flags>")
+		}
+		match = matchPackage(pattern)
+		s = s[i+1:]
+	}
+	flags, err := splitQuotedFields(s)
+	if err != nil {
+		return err
+	}
+	f.values = append(f.values, perPackageValue{match, flags})
+	return nil
+}
+
+func (f *perPackageFlag) String() string {
+	return "<perPackageFlag>"
+}
+
+// present reports whether the flag was given on the command line.
+func (f *perPackageFlag) present() bool {
+	return len(f.values) > 0
+}
+
+// add appends args to the flags for every package.
+func (f *perPackageFlag) add(args ...string) {
+	f.extra = append(f.extra, args...)
+}
+
+// forPackage returns the flags for the package p: those of the last
+// setting whose pattern matches p, followed by the added ones.
+func (f *perPackageFlag) forPackage(p *Package) []string {
+	var flags []string
+	for i := len(f.values) - 1; i >= 0; i-- {
+		if f.values[i].match(p) {
+			flags = f.values[i].flags
+			break
+		}
+	}
+	return stringList(flags, f.extra)
+}
+
+// matchPackage returns a function reporting whether a package matches
+// the pattern, which is either an import path pattern or, like ./...,
+// a pattern for the directories below the current one.
+func matchPackage(pattern string) func(p *Package) bool {
+	switch {
+	case pattern == "all":
+		return func(p *Package) bool { return true }
+	case pattern == "std":
+		return func(p *Package) bool { return p.Standard }
+	case build.IsLocalImport(pattern) || filepath.IsAbs(pattern):
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(cwd, pattern)
+		}
+		match := matchPattern(filepath.ToSlash(pattern))
+		return func(p *Package) bool { return match(filepath.ToSlash(p.Dir)) }
+	default:
+		match := matchPattern(pattern)
+		return func(p *Package) bool { return match(p.ImportPath) }
+	}
+}
+
 func runBuild(cmd *Command, args []string) {
 	raceInit()
+	buildModeInit()
 	var b builder
 	b.init()
 
 	pkgs := packagesForBuild(args)
+	checkBuildModePackages(pkgs)
+
+	if buildBuildmode == "shared" {
+		if *buildO == "" {
+			*buildO = sharedLibName(args, pkgs)
+		}
+		depMode := modeBuild
+		if buildI {
+			depMode = modeInstall
+		}
+		b.do(b.sharedLibAction(modeBuild, depMode, pkgs, *buildO))
+		return
+	}
 
 	if len(pkgs) == 1 && pkgs[0].Name == "main" && *buildO == "" {
 		_, *buildO = path.Split(pkgs[0].ImportPath)
@@ -273,10 +398,10 @@ func runBuild(cmd *Command, args []string) {
 	// sanity check some often mis-used options
 	switch buildContext.Compiler {
 	case "gccgo":
-		if len(buildGcflags) != 0 {
+		if buildGcflags.present() {
 			fmt.Println("go build: when using gccgo toolchain, please pass compiler flags using -gccgoflags, not -gcflags")
 		}
-		if len(buildLdflags) != 0 {
+		if buildLdflags.present() {
 			fmt.Println("go build: when using gccgo toolchain, please pass linker flags using -gccgoflags, not -ldflags")
 		}
 	case "gc":
@@ -327,10 +452,12 @@ See also: go build, go get, go clean.
 
 func runInstall(cmd *Command, args []string) {
 	raceInit()
+	buildModeInit()
 	pkgs := packagesForBuild(args)
+	checkBuildModePackages(pkgs)
 
 	for _, p := range pkgs {
-		if p.Target == "" && (!p.Standard || p.ImportPath != "unsafe") {
+		if p.Target == "" && (!p.Standard || p.ImportPath != "unsafe") && (p.Module == nil || p.Name == "main") {
 			if p.cmdline {
 				errorf("go install: no install location for .go files listed on command line (GOBIN not set)")
 			} else if p.ConflictDir != "" {
@@ -344,6 +471,10 @@ func runInstall(cmd *Command, args []string) {
 
 	var b builder
 	b.init()
+	if buildBuildmode == "shared" {
+		b.do(b.sharedLibAction(modeInstall, modeInstall, pkgs, sharedLibName(args, pkgs)))
+		return
+	}
 	a := &action{}
 	for _, p := range pkgs {
 		a.deps = append(a.deps, b.action(modeInstall, modeInstall, p))
@@ -413,6 +544,8 @@ type action struct {
 	objpkg string // the intermediate package .a file created during the action
 	target string // goal of the action: the created package or executable
 
+	buildID string // build cache key for the output, or "" if not cached
+
 	// Execution state.
 	pending  int  // number of deps yet to complete
 	priority int  // relative execution priority
@@ -569,8 +702,9 @@ func (b *builder) action(mode buildMode, depMode buildMode, p *Package) *action
 	// generate for cgo as a dependency of the build of any package
 	// using cgo, to make sure we do not overwrite the binary while
 	// a package is using it.  If this is a cross-build, then the cgo we
-	// are writing is not the cgo we need to use.
-	if goos == runtime.GOOS && goarch == runtime.GOARCH && !buildRace {
+	// are writing is not the cgo we need to use, and the same goes for
+	// builds with -race or a non-default -buildmode.
+	if goos == runtime.GOOS && goarch == runtime.GOARCH && !buildRace && !buildModeExternal() {
 		if len(p.CgoFiles) > 0 || p.Standard && p.ImportPath == "runtime/cgo" {
 			var stk importStack
 			p1 := loadPackage("cmd/cgo", &stk)
@@ -603,8 +737,8 @@ func (b *builder) action(mode buildMode, depMode buildMode, p *Package) *action
 		return a
 	}
 
-	if p.local && p.target == "" {
-		// Imported via local path.  No permanent target.
+	if (p.local || p.Module != nil) && p.target == "" {
+		// Imported via local path or from a module.  No permanent target.
 		mode = modeBuild
 	}
 	work := p.pkgdir
@@ -642,6 +776,78 @@ func (b *builder) action(mode buildMode, depMode buildMode, p *Package) *action
 	return a
 }
 
+// sharedLibAction returns the action for linking the Go shared
+// library target from the non-main packages in pkgs and all the
+// packages they import, applying mode to them and depMode to their
+// dependencies. When installing, the library goes in the package
+// directory of pkgs and is recorded next to each of its packages
+// for the builds with -linkshared.
+func (b *builder) sharedLibAction(mode, depMode buildMode, pkgs []*Package, target string) *action {
+	var libpkgs []*Package
+	seen := map[*Package]bool{}
+	for _, p := range pkgs {
+		if p.Name != "main" && !seen[p] {
+			seen[p] = true
+			libpkgs = append(libpkgs, p)
+		}
+	}
+	// Like any externally linked binary, the library needs
+	// runtime/cgo to start the Go runtime.
+	var stk importStack
+	p1 := loadPackage("runtime/cgo", &stk)
+	if p1.Error != nil {
+		fatalf("load runtime/cgo: %v", p1.Error)
+	}
+	if !seen[p1] {
+		computeStale(p1)
+		libpkgs = append(libpkgs, p1)
+	}
+
+	if mode == modeInstall {
+		p := libpkgs[0]
+		dir := strings.TrimSuffix(p.target, filepath.FromSlash(p.ImportPath)+".a")
+		target = filepath.Join(dir, target)
+	}
+	a := &action{target: target}
+	a.f = func(b *builder, a *action) error {
+		return b.linkShared(a, libpkgs, mode == modeInstall)
+	}
+	for _, p := range libpkgs {
+		a.deps = append(a.deps, b.action(mode, depMode, p))
+	}
+	return a
+}
+
+// sharedLibName returns the file name of the Go shared library
+// built from pkgs, named on the command line by args: lib followed
+// by the import path patterns, joined by commas, with slashes
+// replaced by dashes and the ... wildcards dropped, as in libstd.so
+// or libnet-http,encoding-json.so. Relative patterns are replaced
+// by the import paths of the packages.
+func sharedLibName(args []string, pkgs []*Package) string {
+	names := args
+	for _, arg := range args {
+		if build.IsLocalImport(arg) || filepath.IsAbs(arg) {
+			names = nil
+			break
+		}
+	}
+	if len(names) == 0 {
+		for _, p := range pkgs {
+			if p.Name != "main" {
+				names = append(names, p.ImportPath)
+			}
+		}
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		name = strings.Replace(path.Clean(name), "/...", "", -1)
+		name = strings.Replace(name, "...", "", -1)
+		parts[i] = strings.Replace(name, "/", "-", -1)
+	}
+	return "lib" + strings.Join(parts, ",") + ".so"
+}
+
 // actionList returns the list of actions in the dag rooted at root
 // as visited in a depth-first post-order traversal.
 func actionList(root *action) []*action {
@@ -766,6 +972,10 @@ func (b *builder) do(root *action) {
 	}
 
 	wg.Wait()
+
+	if !buildN {
+		cacheTrim()
+	}
 }
 
 // hasString reports whether s appears in the list of strings.
@@ -799,6 +1009,14 @@ func (b *builder) build(a *action) (err error) {
 			err = fmt.Errorf("go build %s: %v", a.p.ImportPath, err)
 		}
 	}()
+	if b.buildFromCache(a) {
+		return nil
+	}
+	defer func() {
+		if err == nil {
+			b.saveBuildCache(a)
+		}
+	}()
 	if buildN {
 		// In -n mode, print a banner between packages.
 		// The banner is five lines so that when changes to
@@ -967,17 +1185,17 @@ func (b *builder) build(a *action) (err error) {
 		switch {
 		case strings.HasSuffix(name, _goos_goarch):
 			targ := file[:len(name)-len(_goos_goarch)] + "_GOOS_GOARCH." + ext
-			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644); err != nil {
+			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644, false); err != nil {
 				return err
 			}
 		case strings.HasSuffix(name, _goarch):
 			targ := file[:len(name)-len(_goarch)] + "_GOARCH." + ext
-			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644); err != nil {
+			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644, false); err != nil {
 				return err
 			}
 		case strings.HasSuffix(name, _goos):
 			targ := file[:len(name)-len(_goos)] + "_GOOS." + ext
-			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644); err != nil {
+			if err := b.copyFile(a, obj+targ, filepath.Join(a.p.Dir, file), 0644, false); err != nil {
 				return err
 			}
 		}
@@ -1099,7 +1317,101 @@ func (b *builder) install(a *action) (err error) {
 		defer os.Remove(a1.target)
 	}
 
-	return b.moveOrCopyFile(a, a.target, a1.target, perm)
+	if err := b.moveOrCopyFile(a, a.target, a1.target, perm); err != nil {
+		return err
+	}
+
+	// A package from a Go shared library that is installed again
+	// is no longer the one in the library.
+	if !a1.link && !buildN {
+		os.Remove(shlibnameFile(a.target))
+	}
+
+	// A C archive or shared library comes with the header
+	// declaring the functions it exports. The header is not an
+	// object file, so replace the one left by an earlier build
+	// explicitly.
+	if a1.link && (buildBuildmode == "c-archive" || buildBuildmode == "c-shared") && len(a1.p.CgoFiles) > 0 {
+		hdr := strings.TrimSuffix(a.target, filepath.Ext(a.target)) + ".h"
+		return b.copyFile(a, hdr, a1.objdir+"_cgo_export.h", 0644, true)
+	}
+	return nil
+}
+
+// linkShared is the action for linking the Go shared library
+// holding pkgs and, if install is set, recording its path in a
+// shlibname file next to each of its installed packages.
+func (b *builder) linkShared(a *action, pkgs []*Package, install bool) (err error) {
+	name := filepath.Base(a.target)
+	defer func() {
+		if err != nil && err != errPrintedOutput {
+			err = fmt.Errorf("go build %s: %v", name, err)
+		}
+	}()
+	if buildN {
+		fmt.Printf("\n#\n# %s\n#\n\n", name)
+	}
+	if buildV {
+		fmt.Fprintf(os.Stderr, "%s\n", name)
+	}
+
+	// The library holds every package the action depends on.
+	// For a package that is both built and installed, the
+	// installed file comes last in the list.
+	all := actionList(a)
+	all = all[:len(all)-1] // drop a
+	files := map[*Package]string{}
+	var libpkgs []*Package
+	for _, a1 := range all {
+		if a1.p == nil || a1.target == "" || a1.p.Name == "main" {
+			continue
+		}
+		if _, ok := files[a1.p]; !ok {
+			libpkgs = append(libpkgs, a1.p)
+		}
+		files[a1.p] = a1.target
+	}
+	var libargs []string
+	for _, p := range libpkgs {
+		libargs = append(libargs, p.ImportPath+"="+files[p])
+	}
+
+	dir, _ := filepath.Split(a.target)
+	if dir != "" {
+		if err := b.mkdir(dir); err != nil {
+			return err
+		}
+	}
+	if err := b.run(".", name, nil, buildToolExec, tool(archChar+"l"), "-o", a.target, b.includeArgs("-L", all), gcLdflags(pkgs[0], all), libargs); err != nil {
+		return err
+	}
+	if !install {
+		return nil
+	}
+
+	lib, err := filepath.Abs(a.target)
+	if err != nil {
+		return err
+	}
+	for _, p := range libpkgs {
+		file := shlibnameFile(files[p])
+		if buildN || buildX {
+			b.showcmd("", "echo %s >%s", lib, file)
+			if buildN {
+				continue
+			}
+		}
+		if err := ioutil.WriteFile(file, []byte(lib+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shlibnameFile returns the name of the file recording the Go
+// shared library that holds the package installed as target.
+func shlibnameFile(target string) string {
+	return strings.TrimSuffix(target, ".a") + ".shlibname"
 }
 
 // includeArgs returns the -I or -L directory list for access
@@ -1163,11 +1475,12 @@ func (b *builder) moveOrCopyFile(a *action, dst, src string, perm os.FileMode) e
 		}
 	}
 
-	return b.copyFile(a, dst, src, perm)
+	return b.copyFile(a, dst, src, perm, false)
 }
 
-// copyFile is like 'cp src dst'.
-func (b *builder) copyFile(a *action, dst, src string, perm os.FileMode) error {
+// copyFile is like 'cp src dst'. Unless force is set, it refuses
+// to overwrite an existing file that is not an object file.
+func (b *builder) copyFile(a *action, dst, src string, perm os.FileMode, force bool) error {
 	if buildN || buildX {
 		b.showcmd("", "cp %s %s", src, dst)
 		if buildN {
@@ -1188,7 +1501,7 @@ func (b *builder) copyFile(a *action, dst, src string, perm os.FileMode) error {
 		if fi.IsDir() {
 			return fmt.Errorf("build output %q already exists and is a directory", dst)
 		}
-		if !isObject(dst) {
+		if !force && !isObject(dst) {
 			return fmt.Errorf("build output %q already exists and is not an object file", dst)
 		}
 	}
@@ -1229,7 +1542,7 @@ func (b *builder) copyFile(a *action, dst, src string, perm os.FileMode) error {
 //	go tool cover -mode=b.coverMode -var="varName" -o dst.go src.go
 func (b *builder) cover(a *action, dst, src string, perm os.FileMode, varName string) error {
 	return b.run(a.objdir, "cover "+a.p.ImportPath, nil,
-		tool("cover"),
+		buildToolExec, tool("cover"),
 		"-mode", a.p.coverMode,
 		"-var", varName,
 		"-o", dst,
@@ -1656,8 +1969,15 @@ func (gcToolchain) gc(b *builder, p *Package, archive, obj string, asmhdr bool,
 	if buildContext.InstallSuffix != "" {
 		gcargs = append(gcargs, "-installsuffix", buildContext.InstallSuffix)
 	}
+	// The source imports a vendored package by its path in the
+	// vendor directory; map that to the path of the vendored copy.
+	for _, path := range p.Imports {
+		if i, ok := findVendor(path); ok {
+			gcargs = append(gcargs, "-importmap", path[i+len("vendor/"):]+"="+path)
+		}
+	}
 
-	args := stringList(tool(archChar+"g"), "-o", ofile, "-trimpath", b.work, buildGcflags, gcargs, "-D", p.localPrefix, importArgs)
+	args := stringList(buildToolExec, tool(archChar+"g"), "-o", ofile, "-trimpath", b.trimpath(p), buildGcflags.forPackage(p), gcargs, "-D", p.localPrefix, importArgs)
 	if ofile == archive {
 		args = append(args, "-pack")
 	}
@@ -1676,7 +1996,32 @@ func (gcToolchain) asm(b *builder, p *Package, obj, ofile, sfile string) error {
 	// Add -I pkg/GOOS_GOARCH so #include "textflag.h" works in .s files.
 	inc := filepath.Join(goroot, "pkg", fmt.Sprintf("%s_%s", goos, goarch))
 	sfile = mkAbs(p.Dir, sfile)
-	return b.run(p.Dir, p.ImportPath, nil, tool(archChar+"a"), "-trimpath", b.work, "-I", obj, "-I", inc, "-o", ofile, "-D", "GOOS_"+goos, "-D", "GOARCH_"+goarch, sfile)
+	return b.run(p.Dir, p.ImportPath, nil, buildToolExec, tool(archChar+"a"), "-trimpath", b.trimpath(p), "-I", obj, "-I", inc, "-o", ofile, "-D", "GOOS_"+goos, "-D", "GOARCH_"+goarch, buildAsmflags.forPackage(p), sfile)
+}
+
+// trimpath returns the -trimpath argument for compiling and assembling p:
+// the work directory and, for a GOPATH package, its workspace's src directory.
+func (b *builder) trimpath(p *Package) string {
+	if src := gopathSrc(p); src != "" {
+		return b.work + ";" + src
+	}
+	return b.work
+}
+
+// gopathSrc returns the src directory of the GOPATH workspace holding p,
+// or "" if p is not a GOPATH package in the directory named by its import
+// path. Trimming that directory records the source files of p by import
+// path and file name, so that its archive does not depend on where the
+// workspace is, and the build cache can share it between GOPATH settings.
+func gopathSrc(p *Package) string {
+	if p.Goroot || p.local || p.Root == "" {
+		return ""
+	}
+	src := filepath.Join(p.Root, "src")
+	if p.Dir != filepath.Join(src, filepath.FromSlash(p.ImportPath)) {
+		return ""
+	}
+	return src
 }
 
 func (gcToolchain) pkgpath(basedir string, p *Package) string {
@@ -1715,7 +2060,7 @@ func (gcToolchain) pack(b *builder, p *Package, objDir, afile string, ofiles []s
 
 	// Need actual pack.
 	cmdline[0] = tool("pack")
-	return b.run(p.Dir, p.ImportPath, nil, cmdline)
+	return b.run(p.Dir, p.ImportPath, nil, buildToolExec, cmdline)
 }
 
 func packInternal(b *builder, afile string, ofiles []string) error {
@@ -1770,15 +2115,19 @@ func packInternal(b *builder, afile string, ofiles []string) error {
 
 func (gcToolchain) ld(b *builder, p *Package, out string, allactions []*action, mainpkg string, ofiles []string) error {
 	importArgs := b.includeArgs("-L", allactions)
+	return b.run(".", p.ImportPath, nil, buildToolExec, tool(archChar+"l"), "-o", out, importArgs, gcLdflags(p, allactions), mainpkg)
+}
+
+// gcLdflags returns the flags for the gc linker when linking p and
+// the packages of allactions.
+func gcLdflags(p *Package, allactions []*action) []string {
 	cxx := len(p.CXXFiles) > 0
 	for _, a := range allactions {
 		if a.p != nil && len(a.p.CXXFiles) > 0 {
 			cxx = true
 		}
 	}
-	ldflags := buildLdflags
-	// Limit slice capacity so that concurrent appends do not race on the shared array.
-	ldflags = ldflags[:len(ldflags):len(ldflags)]
+	ldflags := buildLdflags.forPackage(p)
 	if buildContext.InstallSuffix != "" {
 		ldflags = append(ldflags, "-installsuffix", buildContext.InstallSuffix)
 	}
@@ -1824,7 +2173,7 @@ func (gcToolchain) ld(b *builder, p *Package, out string, allactions []*action,
 			}
 		}
 	}
-	return b.run(".", p.ImportPath, nil, tool(archChar+"l"), "-o", out, importArgs, ldflags, mainpkg)
+	return ldflags
 }
 
 func (gcToolchain) cc(b *builder, p *Package, objdir, ofile, cfile string) error {
@@ -2212,7 +2561,7 @@ func (b *builder) cgo(p *Package, cgoExe, obj string, pcCFLAGS, pcLDFLAGS, gccfi
 		}
 		objExt = "o"
 	}
-	if err := b.run(p.Dir, p.ImportPath, cgoenv, cgoExe, "-objdir", obj, cgoflags, "--", cgoCPPFLAGS, cgoexeCFLAGS, p.CgoFiles); err != nil {
+	if err := b.run(p.Dir, p.ImportPath, cgoenv, buildToolExec, cgoExe, "-objdir", obj, cgoflags, "--", cgoCPPFLAGS, cgoexeCFLAGS, p.CgoFiles); err != nil {
 		return nil, nil, err
 	}
 	outGo = append(outGo, gofiles...)
@@ -2344,7 +2693,7 @@ func (b *builder) cgo(p *Package, cgoExe, obj string, pcCFLAGS, pcLDFLAGS, gccfi
 	if p.Standard && p.ImportPath == "runtime/cgo" {
 		cgoflags = append(cgoflags, "-dynlinker") // record path to dynamic linker
 	}
-	if err := b.run(p.Dir, p.ImportPath, nil, cgoExe, "-objdir", obj, "-dynpackage", p.Name, "-dynimport", dynobj, "-dynout", importGo, cgoflags); err != nil {
+	if err := b.run(p.Dir, p.ImportPath, nil, buildToolExec, cgoExe, "-objdir", obj, "-dynpackage", p.Name, "-dynimport", dynobj, "-dynout", importGo, cgoflags); err != nil {
 		return nil, nil, err
 	}
 	outGo = append(outGo, importGo)
@@ -2639,8 +2988,8 @@ func raceInit() {
 		fmt.Fprintf(os.Stderr, "go %s: -race is only supported on linux/amd64, freebsd/amd64, darwin/amd64 and windows/amd64\n", flag.Args()[0])
 		os.Exit(2)
 	}
-	buildGcflags = append(buildGcflags, "-race")
-	buildLdflags = append(buildLdflags, "-race")
+	buildGcflags.add("-race")
+	buildLdflags.add("-race")
 	buildCcflags = append(buildCcflags, "-D", "RACE")
 	if buildContext.InstallSuffix != "" {
 		buildContext.InstallSuffix += "_"
@@ -2649,6 +2998,102 @@ func raceInit() {
 	buildContext.BuildTags = append(buildContext.BuildTags, "race")
 }
 
+// buildModeInit checks the -buildmode and -linkshared flags and sets
+// the flags that make the compilers, the assemblers and the linker
+// build for them. Packages compiled for a build mode other than the
+// default are installed with the install suffix shared; those of a
+// Go shared library and of the programs linked against it, with the
+// install suffix dynlink.
+func buildModeInit() {
+	mode := "-buildmode=" + buildBuildmode
+	switch buildBuildmode {
+	case "default", "exe":
+		if !buildLinkshared {
+			return
+		}
+		mode = "-linkshared"
+	case "c-archive", "c-shared", "shared":
+		if cmd := flag.Args()[0]; cmd == "run" || cmd == "test" {
+			fmt.Fprintf(os.Stderr, "go %s: cannot run a program built with -buildmode=%s\n", cmd, buildBuildmode)
+			os.Exit(2)
+		}
+	case "pie":
+		// ok
+	default:
+		fmt.Fprintf(os.Stderr, "go %s: unknown build mode -buildmode=%s\n", flag.Args()[0], buildBuildmode)
+		os.Exit(2)
+	}
+	if buildLinkshared && mode != "-linkshared" {
+		fmt.Fprintf(os.Stderr, "go %s: -linkshared cannot be used with -buildmode=%s\n", flag.Args()[0], buildBuildmode)
+		os.Exit(2)
+	}
+	if goarch != "amd64" || goos != "linux" {
+		fmt.Fprintf(os.Stderr, "go %s: %s is only supported on linux/amd64\n", flag.Args()[0], mode)
+		os.Exit(2)
+	}
+	if _, ok := buildToolchain.(gcToolchain); !ok || !buildContext.CgoEnabled {
+		fmt.Fprintf(os.Stderr, "go %s: %s requires the gc toolchain and cgo\n", flag.Args()[0], mode)
+		os.Exit(2)
+	}
+	switch buildBuildmode {
+	case "c-archive":
+		exeSuffix = ".a"
+	case "c-shared":
+		exeSuffix = ".so"
+	}
+	suffix := "shared"
+	if buildBuildmode == "shared" || buildLinkshared {
+		// The packages refer to Go symbols defined in another
+		// module: the program or the shared library.
+		buildGcflags.add("-dynlink")
+		buildAsmflags.add("-dynlink")
+		suffix = "dynlink"
+	} else {
+		buildGcflags.add("-shared")
+		buildAsmflags.add("-shared")
+	}
+	if buildLinkshared {
+		buildLdflags.add("-linkshared")
+	} else {
+		buildLdflags.add("-buildmode=" + buildBuildmode)
+	}
+	if buildContext.InstallSuffix != "" {
+		buildContext.InstallSuffix += "_"
+	}
+	buildContext.InstallSuffix += suffix
+}
+
+// buildModeExternal reports whether the -buildmode and -linkshared
+// flags select a mode that the linker implements by linking
+// externally. Main packages then depend on runtime/cgo, which the
+// linker needs.
+func buildModeExternal() bool {
+	switch buildBuildmode {
+	case "c-archive", "c-shared", "pie", "shared":
+		return true
+	}
+	return buildLinkshared
+}
+
+// checkBuildModePackages reports an error if the -buildmode flag
+// asks for a library but pkgs is not a single main package or,
+// for a Go shared library, has no package other than main ones.
+func checkBuildModePackages(pkgs []*Package) {
+	switch buildBuildmode {
+	case "c-archive", "c-shared":
+		if len(pkgs) != 1 || pkgs[0].Name != "main" {
+			fatalf("-buildmode=%s requires exactly one main package", buildBuildmode)
+		}
+	case "shared":
+		for _, p := range pkgs {
+			if p.Name != "main" {
+				return
+			}
+		}
+		fatalf("-buildmode=shared requires at least one non-main package")
+	}
+}
+
 // defaultSuffix returns file extension used for command files in
 // current os environment.
 func defaultSuffix() string {
@@ -0,0 +1,49 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPerPackageFlag(t *testing.T) {
+	var f perPackageFlag
+	for _, s := range []string{"-N -l", "net/...=-m", "net/http=", "./x/...='-d checknil'"} {
+		if err := f.Set(s); err != nil {
+			t.Fatalf("Set(%q): %v", s, err)
+		}
+	}
+	f.add("-race")
+
+	tests := []struct {
+		path, dir string
+		flags     []string
+	}{
+		{"fmt", "", []string{"-N", "-l", "-race"}},
+		{"net", "", []string{"-m", "-race"}},
+		{"net/url", "", []string{"-m", "-race"}},
+		{"net/http", "", []string{"-race"}},
+		{"example.com/x", filepath.Join(cwd, "x"), []string{"-d checknil", "-race"}},
+		{"example.com/x/y", filepath.Join(cwd, "x", "y"), []string{"-d checknil", "-race"}},
+		{"example.com/xy", filepath.Join(cwd, "xy"), []string{"-N", "-l", "-race"}},
+	}
+	for _, tt := range tests {
+		p := &Package{ImportPath: tt.path, Dir: tt.dir}
+		if flags := f.forPackage(p); !reflect.DeepEqual(flags, tt.flags) {
+			t.Errorf("forPackage(%s) = %q, want %q", tt.path, flags, tt.flags)
+		}
+	}
+}
+
+func TestPerPackageFlagErrors(t *testing.T) {
+	for _, s := range []string{"net", "=-m", "net='-m"} {
+		var f perPackageFlag
+		if err := f.Set(s); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", s)
+		}
+	}
+}
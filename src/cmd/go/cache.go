@@ -0,0 +1,574 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !cmd_go_bootstrap
+
+// This code is compiled into the real 'go' binary, but it is not
+// compiled into the binary that is built during all.bash, so as
+// to avoid needing to build crypto/sha256 during the bootstrap
+// process.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The build cache holds the package archives compiled by earlier
+// builds and the output of earlier successful test runs. Each entry
+// is a file named by the hex SHA-256 hash of a description of
+// everything that went into it (the action ID), stored in a
+// subdirectory named for the first byte of the hash.
+
+var cacheDirOnce sync.Once
+var cacheDirPath string
+
+// cacheDir returns the build cache directory,
+// or "" if the build cache is disabled.
+func cacheDir() string {
+	cacheDirOnce.Do(func() {
+		dir := defaultCacheDir()
+		if dir == "" {
+			return
+		}
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return
+		}
+		cacheDirPath = dir
+	})
+	return cacheDirPath
+}
+
+// defaultCacheDir returns the directory named by $GOCACHE,
+// or else the go-build directory in the user's cache directory.
+func defaultCacheDir() string {
+	dir := os.Getenv("GOCACHE")
+	if dir == "off" {
+		return ""
+	}
+	if dir != "" {
+		return dir
+	}
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+	case "darwin":
+		if home := os.Getenv("HOME"); home != "" {
+			dir = filepath.Join(home, "Library", "Caches")
+		}
+	case "plan9":
+		if home := os.Getenv("home"); home != "" {
+			dir = filepath.Join(home, "lib", "cache")
+		}
+	default:
+		dir = os.Getenv("XDG_CACHE_HOME")
+		if dir == "" && os.Getenv("HOME") != "" {
+			dir = filepath.Join(os.Getenv("HOME"), ".cache")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "go-build")
+}
+
+// cacheFile returns the name of the file holding the cache entry id.
+func cacheFile(id string) string {
+	return filepath.Join(cacheDir(), id[:2], id+"-d")
+}
+
+// cachePut stores the data read from r as the cache entry id.
+// It writes a temporary file and renames it into place,
+// so that readers never see a partial entry.
+func cachePut(id string, r io.Reader) error {
+	file := cacheFile(id)
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), id+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// An entry's modification time records when it was last used, to within
+// cacheUseInterval. Every cacheTrimInterval, cacheTrim removes the entries
+// not used for cacheTrimLimit.
+const (
+	cacheUseInterval  = 1 * time.Hour
+	cacheTrimInterval = 24 * time.Hour
+	cacheTrimLimit    = 5 * 24 * time.Hour
+)
+
+// cacheTrimFile is the name of the file in the build cache recording,
+// as a Unix time in nanoseconds, when cacheTrim last ran.
+const cacheTrimFile = "trim.txt"
+
+// cacheUsed records that the cache entry file has just been used.
+func cacheUsed(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(info.ModTime()) >= cacheUseInterval {
+		os.Chtimes(file, now, now)
+	}
+}
+
+// cacheTrim removes the entries of the build cache that have not
+// been used recently, unless it has done so already in the last
+// cacheTrimInterval. Failing to remove an entry only leaves it for
+// the next trim, so errors are ignored.
+func cacheTrim() {
+	if dir := cacheDir(); dir != "" {
+		trimCacheDir(dir, time.Now())
+	}
+}
+
+// trimCacheDir implements cacheTrim for the cache in dir,
+// as of the time now.
+func trimCacheDir(dir string, now time.Time) {
+	trimFile := filepath.Join(dir, cacheTrimFile)
+	if data, err := ioutil.ReadFile(trimFile); err == nil {
+		if t, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && now.Sub(time.Unix(0, t)) < cacheTrimInterval {
+			return
+		}
+	}
+	cutoff := now.Add(-cacheTrimLimit)
+	for i := 0; i < 256; i++ {
+		subdir := filepath.Join(dir, fmt.Sprintf("%02x", i))
+		infos, err := ioutil.ReadDir(subdir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			// This also removes temporary files left
+			// by go commands that were interrupted.
+			if info.ModTime().Before(cutoff) {
+				os.Remove(filepath.Join(subdir, info.Name()))
+			}
+		}
+	}
+	ioutil.WriteFile(trimFile, []byte(strconv.FormatInt(now.UnixNano(), 10)+"\n"), 0666)
+}
+
+var fileHashCache struct {
+	sync.Mutex
+	m map[string]string
+}
+
+// hashFile returns the hex SHA-256 hash of the contents of file.
+// The hash is computed once per file per run of the go command:
+// the files it is used for, sources, tools and the outputs of
+// finished actions, do not change during a build.
+func hashFile(file string) (string, error) {
+	fileHashCache.Lock()
+	defer fileHashCache.Unlock()
+	if h, ok := fileHashCache.m[file]; ok {
+		return h, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if fileHashCache.m == nil {
+		fileHashCache.m = make(map[string]string)
+	}
+	sum := fmt.Sprintf("%x", h.Sum(nil))
+	fileHashCache.m[file] = sum
+	return sum, nil
+}
+
+// buildActionID returns the action ID for the build action a,
+// or "" if its output cannot be cached. Only package archives
+// built by the gc toolchain are cached, and only when all their
+// inputs are known: packages using SWIG or pkg-config depend on
+// files the go command does not track.
+func (b *builder) buildActionID(a *action) string {
+	p := a.p
+	if a.link || buildN || cacheDir() == "" || p.usesSwig() || len(p.CgoPkgConfig) > 0 {
+		return ""
+	}
+	if _, ok := buildToolchain.(gcToolchain); !ok {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "build %s %s/%s\n", runtime.Version(), goos, goarch)
+	tools := []string{archChar + "g", archChar + "a", "pack"}
+	if p.usesCgo() {
+		tools = append(tools, "cgo")
+	}
+	if p.coverMode != "" {
+		tools = append(tools, "cover")
+	}
+	for _, name := range tools {
+		exe := tool(name)
+		if name == "cgo" && a.cgo != nil && a.cgo.target != "" {
+			exe = a.cgo.target
+		}
+		id, err := hashFile(exe)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(h, "tool %s %s\n", name, id)
+	}
+
+	// The compiler records the names of the source files. Those of
+	// a GOPATH package are recorded by import path (see gopathSrc),
+	// but otherwise, and in the C objects built for cgo, the directory
+	// is part of the output. So is the prefix for local imports,
+	// which GOPATH packages may not use.
+	if gopathSrc(p) == "" || p.usesCgo() {
+		fmt.Fprintf(h, "dir %s %q\n", p.Dir, p.localPrefix)
+	}
+	fmt.Fprintf(h, "package %s %s\n", p.ImportPath, p.Name)
+	fmt.Fprintf(h, "imports %q\n", p.Imports)
+	fmt.Fprintf(h, "gcflags %q\n", buildGcflags.forPackage(p))
+	fmt.Fprintf(h, "asmflags %q\n", buildAsmflags.forPackage(p))
+	fmt.Fprintf(h, "toolexec %q\n", buildToolExec)
+	fmt.Fprintf(h, "installsuffix %q\n", buildContext.InstallSuffix)
+	if p.usesCgo() {
+		for _, key := range []string{"CC", "CXX", "CGO_CPPFLAGS", "CGO_CFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS"} {
+			fmt.Fprintf(h, "env %s=%q\n", key, os.Getenv(key))
+		}
+		fmt.Fprintf(h, "defaultcc %q\n", defaultCC)
+	}
+	if p.coverMode != "" {
+		fmt.Fprintf(h, "cover %s\n", p.coverMode)
+		var keys []string
+		for key := range p.coverVars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(h, "covervar %s %s\n", key, p.coverVars[key].Var)
+		}
+	}
+
+	for _, files := range [][]string{p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles, p.MFiles, p.HFiles, p.SFiles, p.SysoFiles} {
+		for _, file := range files {
+			id, err := hashFile(filepath.Join(p.Dir, file))
+			if err != nil {
+				return ""
+			}
+			fmt.Fprintf(h, "file %s %s\n", file, id)
+		}
+	}
+
+	// The dependencies are identified by the contents of their
+	// archives, so that rebuilding a dependency with the same result
+	// does not invalidate the packages importing it.
+	for _, a1 := range a.deps {
+		if a1 == a.cgo || a1.p == nil || a1.target == "" {
+			continue
+		}
+		id, err := hashFile(a1.target)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(h, "dep %s %s\n", a1.p.ImportPath, id)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// buildFromCache computes the action ID of the build action a and,
+// unless the -a flag is set, looks for its output in the build cache.
+// If it is there, buildFromCache copies it to a.objpkg and reports true.
+func (b *builder) buildFromCache(a *action) bool {
+	a.buildID = b.buildActionID(a)
+	if a.buildID == "" || buildA {
+		return false
+	}
+	file := cacheFile(a.buildID)
+	if _, err := os.Stat(file); err != nil {
+		return false
+	}
+	if err := b.mkdir(filepath.Dir(a.objpkg)); err != nil {
+		return false
+	}
+	if err := b.copyFile(a, a.objpkg, file, 0666, false); err != nil {
+		return false
+	}
+	cacheUsed(file)
+	return true
+}
+
+// saveBuildCache stores the archive built by the action a in the
+// build cache. Failing to do so only costs a later rebuild,
+// so errors are ignored.
+func (b *builder) saveBuildCache(a *action) {
+	if a.buildID == "" {
+		return
+	}
+	f, err := os.Open(a.objpkg)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	cachePut(a.buildID, f)
+}
+
+// testActionID returns the action ID for the run of the test binary
+// built by a.deps[0], or "" if its result cannot be cached.
+// The ID covers the test binary, its command line and the environment
+// variables whose names begin with GO, which the runtime reads without
+// going through package os. The files and other environment variables
+// the test consults are checked separately; see getTestCache.
+func (b *builder) testActionID(a *action) string {
+	if !testCacheable || buildN || cacheDir() == "" {
+		return ""
+	}
+	p := a.p
+	h := sha256.New()
+	id, err := hashFile(a.deps[0].target)
+	if err != nil {
+		return ""
+	}
+	fmt.Fprintf(h, "test %s\n", id)
+	fmt.Fprintf(h, "dir %s\n", p.Dir)
+	fmt.Fprintf(h, "exec %q\n", findExecCmd())
+	fmt.Fprintf(h, "args %q\n", testArgs)
+	env := os.Environ()
+	sort.Strings(env)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GO") {
+			fmt.Fprintf(h, "env %s\n", kv)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// testModTimeCutoff is how recently a file a test consults may have
+// changed for its result to be cached. A file changed more recently
+// might change again without its modification time changing.
+const testModTimeCutoff = 2 * time.Second
+
+// testInputsID returns a hash of the current values of the
+// environment variables and the current state of the files named
+// in the test log, read with relative names resolved in dir.
+// It returns "" if the log is not a test log or names a file
+// changed too recently to be sure of its state.
+func testInputsID(dir string, log []byte) string {
+	lines := strings.Split(string(log), "\n")
+	if lines[0] != "# test log" {
+		return ""
+	}
+	h := sha256.New()
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return ""
+		}
+		op, name := line[:i], line[i+1:]
+		if op == "getenv" {
+			v, ok := syscall.Getenv(name)
+			fmt.Fprintf(h, "getenv %q %v %q\n", name, ok, v)
+			continue
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		var id string
+		switch op {
+		case "chdir":
+			dir = name
+			id = "-"
+		case "stat":
+			id = testStatID(name)
+		case "open":
+			id = testOpenID(name)
+		default:
+			return ""
+		}
+		if id == "" {
+			return ""
+		}
+		fmt.Fprintf(h, "%s %q %s\n", op, name, id)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// testStatID describes the state of file as seen by os.Stat,
+// or returns "" if it changed too recently. Only the mode of
+// a directory counts, since its other attributes change with
+// its entries.
+func testStatID(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "missing"
+	}
+	if info.IsDir() {
+		return info.Mode().String()
+	}
+	if time.Since(info.ModTime()) < testModTimeCutoff {
+		return ""
+	}
+	return fmt.Sprintf("%v %d %d", info.Mode(), info.Size(), info.ModTime().UnixNano())
+}
+
+// testOpenID describes the contents of file, or returns ""
+// if it changed too recently. Directories are described
+// by the names and modes of their entries.
+func testOpenID(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "missing"
+	}
+	h := sha256.New()
+	if info.IsDir() {
+		infos, err := ioutil.ReadDir(file)
+		if err != nil {
+			return ""
+		}
+		for _, info := range infos {
+			fmt.Fprintf(h, "%q %v\n", info.Name(), info.Mode())
+		}
+	} else {
+		if time.Since(info.ModTime()) < testModTimeCutoff {
+			return ""
+		}
+		// Not hashFile: the test may have changed the file
+		// since the go command last read it.
+		f, err := os.Open(file)
+		if err != nil {
+			return ""
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// testAndInputKey returns the cache key for the output of the test
+// run testID when the files and environment it consults have the
+// state described by inputsID.
+func testAndInputKey(testID, inputsID string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte("inputs "+testID+" "+inputsID)))
+}
+
+// testExpireFile is the name of the file in the build cache recording,
+// as a Unix time in nanoseconds, when 'go clean -testcache' last ran.
+// Test results cached before then are ignored.
+const testExpireFile = "testexpire.txt"
+
+// getTestCache returns the output of the cached test run id,
+// provided that the environment variables and files the run
+// consulted, with relative names resolved in dir, are unchanged.
+// The entry id holds the log of what the run consulted, and the
+// output is stored under a key combining id and their state.
+func getTestCache(id, dir string) ([]byte, bool) {
+	log, ok := readTestCache(id)
+	if !ok {
+		return nil, false
+	}
+	inputsID := testInputsID(dir, log)
+	if inputsID == "" {
+		return nil, false
+	}
+	return readTestCache(testAndInputKey(id, inputsID))
+}
+
+// readTestCache returns the contents of the cache entry id,
+// unless 'go clean -testcache' expired it.
+func readTestCache(id string) ([]byte, bool) {
+	file := cacheFile(id)
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, false
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(cacheDir(), testExpireFile)); err == nil {
+		if t, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && info.ModTime().UnixNano() < t {
+			return nil, false
+		}
+	}
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	cacheUsed(file)
+	return out, true
+}
+
+// putTestCache stores the output of the successful test run id,
+// along with the log of the files and environment variables it
+// consulted. See getTestCache.
+func putTestCache(id, dir string, log, out []byte) {
+	inputsID := testInputsID(dir, log)
+	if inputsID == "" {
+		return
+	}
+	if cachePut(id, bytes.NewReader(log)) == nil {
+		cachePut(testAndInputKey(id, inputsID), bytes.NewReader(out))
+	}
+}
+
+// cleanCache implements 'go clean -cache' and 'go clean -testcache'.
+// Removing the whole cache is simple; test results are expired
+// instead, since they cannot be told apart from package archives.
+func cleanCache(all, tests bool) {
+	dir := defaultCacheDir()
+	if dir == "" {
+		return
+	}
+	if all {
+		if buildN || buildX {
+			var b builder
+			b.print = fmt.Print
+			b.showcmd("", "rm -rf %s", dir)
+			if buildN {
+				return
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			errorf("go clean -cache: %v", err)
+		}
+		return
+	}
+	if tests {
+		if _, err := os.Stat(dir); err != nil {
+			return
+		}
+		now := strconv.FormatInt(time.Now().UnixNano(), 10)
+		if err := ioutil.WriteFile(filepath.Join(dir, testExpireFile), []byte(now+"\n"), 0666); err != nil {
+			errorf("go clean -testcache: %v", err)
+		}
+	}
+}
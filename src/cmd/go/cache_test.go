@@ -0,0 +1,68 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrimCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	entries := map[string]time.Duration{
+		"00/00old-d":          6 * 24 * time.Hour,
+		"00/00new-d":          1 * time.Hour,
+		"ff/ffold-d":          10 * 24 * time.Hour,
+		"ff/ffold.tmp-123":    10 * 24 * time.Hour,
+		"ff/ffrecent-d":       4 * 24 * time.Hour,
+		"ab/abjustexpired-d":  5*24*time.Hour + time.Minute,
+		"ab/abnotexpiring-d":  5*24*time.Hour - time.Minute,
+		"ab/abusedinfuture-d": -time.Hour,
+	}
+	create := func(name string, age time.Duration) {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(name), 0666); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-age)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, age := range entries {
+		create(name, age)
+	}
+
+	trimCacheDir(dir, now)
+	for name, age := range entries {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if kept := err == nil; kept != (age < cacheTrimLimit) {
+			t.Errorf("after trim, %s (age %v) kept = %v, want %v", name, age, kept, !kept)
+		}
+	}
+
+	// A second trim within cacheTrimInterval does nothing.
+	create("00/00old-d", 6*24*time.Hour)
+	trimCacheDir(dir, now.Add(cacheTrimInterval-time.Minute))
+	if _, err := os.Stat(filepath.Join(dir, "00", "00old-d")); err != nil {
+		t.Errorf("second trim within %v removed an entry: %v", cacheTrimInterval, err)
+	}
+	trimCacheDir(dir, now.Add(cacheTrimInterval+time.Minute))
+	if _, err := os.Stat(filepath.Join(dir, "00", "00old-d")); err == nil {
+		t.Errorf("trim after %v kept an old entry", cacheTrimInterval)
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 var cmdClean = &Command{
-	UsageLine: "clean [-i] [-r] [-n] [-x] [build flags] [packages]",
+	UsageLine: "clean [-i] [-r] [-n] [-x] [-cache] [-testcache] [build flags] [packages]",
 	Short:     "remove object files",
 	Long: `
 Clean removes object files from package source directories.
@@ -52,14 +52,23 @@ dependencies of the packages named by the import paths.
 
 The -x flag causes clean to print remove commands as it executes them.
 
+The -cache flag causes clean to remove the entire build cache.
+
+The -testcache flag causes clean to expire all test results in the
+build cache, so that the next go test runs every test again.
+
 For more about build flags, see 'go help build'.
 
+For more about the build cache, see 'go help cache'.
+
 For more about specifying packages, see 'go help packages'.
 	`,
 }
 
-var cleanI bool // clean -i flag
-var cleanR bool // clean -r flag
+var cleanI bool             // clean -i flag
+var cleanR bool             // clean -r flag
+var cleanCacheFlag bool     // clean -cache flag
+var cleanTestcacheFlag bool // clean -testcache flag
 
 func init() {
 	// break init cycle
@@ -67,6 +76,8 @@ func init() {
 
 	cmdClean.Flag.BoolVar(&cleanI, "i", false, "")
 	cmdClean.Flag.BoolVar(&cleanR, "r", false, "")
+	cmdClean.Flag.BoolVar(&cleanCacheFlag, "cache", false, "")
+	cmdClean.Flag.BoolVar(&cleanTestcacheFlag, "testcache", false, "")
 	// -n and -x are important enough to be
 	// mentioned explicitly in the docs but they
 	// are part of the build flags.
@@ -75,6 +86,14 @@ func init() {
 }
 
 func runClean(cmd *Command, args []string) {
+	if cleanCacheFlag || cleanTestcacheFlag {
+		cleanCache(cleanCacheFlag, cleanTestcacheFlag)
+		if len(args) == 0 {
+			// Cleaning the cache is all that was asked for,
+			// not cleaning the package in the current directory.
+			return
+		}
+	}
 	for _, pkg := range packagesAndErrors(args) {
 		clean(pkg)
 	}
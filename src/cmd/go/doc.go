@@ -33,10 +33,13 @@ Use "go help [command]" for more information about a command.
 
 Additional help topics:
 
+    buildmode   description of build modes
     c           calling between Go and C
+    cache       build and test caching
     filetype    file types
     gopath      GOPATH environment variable
     importpath  import path syntax
+    modules     versioned modules and go.mod files
     packages    description of package lists
     testflag    description of testing flags
     testfunc    description of testing functions
@@ -77,6 +80,11 @@ and test commands:
 	-a
 		force rebuilding of packages that are already up-to-date.
 		In Go releases, does not apply to the standard library.
+	-buildmode mode
+		build mode to use. See 'go help buildmode' for more.
+	-linkshared
+		link against the Go shared library installed with
+		-buildmode=shared. See 'go help buildmode' for more.
 	-n
 		print the commands but do not run them.
 	-p n
@@ -93,29 +101,43 @@ and test commands:
 	-x
 		print the commands.
 
+	-asmflags '[pattern=]arg list'
+		arguments to pass on each 5a, 6a, or 8a assembler invocation.
 	-ccflags 'arg list'
 		arguments to pass on each 5c, 6c, or 8c compiler invocation.
 	-compiler name
 		name of compiler to use, as in runtime.Compiler (gccgo or gc).
 	-gccgoflags 'arg list'
 		arguments to pass on each gccgo compiler/linker invocation.
-	-gcflags 'arg list'
+	-gcflags '[pattern=]arg list'
 		arguments to pass on each 5g, 6g, or 8g compiler invocation.
 	-installsuffix suffix
 		a suffix to use in the name of the package installation directory,
 		in order to keep output separate from default builds.
 		If using the -race flag, the install suffix is automatically set to race
 		or, if set explicitly, has _race appended to it.
-	-ldflags 'flag list'
+	-ldflags '[pattern=]flag list'
 		arguments to pass on each 5l, 6l, or 8l linker invocation.
 	-tags 'tag list'
 		a list of build tags to consider satisfied during the build.
 		For more information about build tags, see the description of
 		build constraints in the documentation for the go/build package.
+	-toolexec 'cmd args'
+		a program to use to invoke toolchain programs like 6g, 6l and cgo.
+		For example, instead of running 6g, the go command will run
+		'cmd args /path/to/6g <arguments for 6g>'.
 
 The list flags accept a space-separated list of strings. To embed spaces
 in an element in the list, surround it with either single or double quotes.
 
+The -asmflags, -gcflags and -ldflags lists apply to every package unless
+they begin with a package pattern and an equals sign, as in
+-gcflags=mypkg/...=-m, in which case they apply only to the packages
+matching the pattern. The patterns are those described in 'go help
+packages'. These flags may be repeated with different patterns; when
+several match a package, the last one wins. For -ldflags, the pattern
+is matched against the main package being linked.
+
 For more about specifying packages, see 'go help packages'.
 For more about where packages and binaries are installed,
 run 'go help gopath'.  For more about calling between Go and C/C++,
@@ -128,7 +150,7 @@ Remove object files
 
 Usage:
 
-	go clean [-i] [-r] [-n] [-x] [build flags] [packages]
+	go clean [-i] [-r] [-n] [-x] [-cache] [-testcache] [build flags] [packages]
 
 Clean removes object files from package source directories.
 The go command builds most objects in a temporary directory,
@@ -166,8 +188,15 @@ dependencies of the packages named by the import paths.
 
 The -x flag causes clean to print remove commands as it executes them.
 
+The -cache flag causes clean to remove the entire build cache.
+
+The -testcache flag causes clean to expire all test results in the
+build cache, so that the next go test runs every test again.
+
 For more about build flags, see 'go help build'.
 
+For more about the build cache, see 'go help cache'.
+
 For more about specifying packages, see 'go help packages'.
 
 
@@ -355,6 +384,16 @@ rule is that if the local installation is running version "go1", get
 searches for a branch or tag named "go1". If no such version exists it
 retrieves the most recent version of the package.
 
+Get never downloads or updates packages in vendor directories:
+the dependencies of a package that are found in a vendor directory
+are left alone, and naming a vendored package is an error. A vendor
+directory is updated along with the tree containing it, or by hand.
+See 'go help gopath' for more about vendor directories.
+
+In module mode, get instead updates the requirements in go.mod for
+the modules providing the named packages, which may be given as
+path@version. See 'go help modules'.
+
 For more about specifying packages, see 'go help packages'.
 
 For more about how 'go get' finds source code to
@@ -382,7 +421,7 @@ List packages
 
 Usage:
 
-	go list [-e] [-f format] [-json] [build flags] [packages]
+	go list [-e] [-f format] [-json] [-m] [build flags] [packages]
 
 List lists the packages named by the import paths, one per line.
 
@@ -441,6 +480,9 @@ syntax of package template.  The default output is equivalent to -f
         TestImports  []string // imports from TestGoFiles
         XTestGoFiles []string // _test.go files outside package
         XTestImports []string // imports from XTestGoFiles
+
+        // Module information
+        Module *Module // module containing the package, in module mode
     }
 
 The template function "join" calls strings.Join.
@@ -476,6 +518,21 @@ printing.  Erroneous packages will have a non-empty ImportPath and
 a non-nil Error field; other information may or may not be missing
 (zeroed).
 
+The -m flag causes list to list modules instead of packages, in
+module mode. The arguments are module paths; with no arguments, list
+prints the main module, and the argument "all" stands for the whole
+build list. The default output shows the module path and version.
+The struct being passed to the template is:
+
+    type Module struct {
+        Path    string // module path
+        Version string // module version; empty for the main module
+        Dir     string // directory holding the module's files, if available
+        Main    bool   // is this the main module?
+    }
+
+For more about modules, see 'go help modules'.
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
@@ -536,6 +593,18 @@ with source in the current directory, including tests, and runs the tests.
 The package is built in a temporary directory so it does not interfere with the
 non-test installation.
 
+When go test is given packages on the command line, it caches the
+results of the tests that pass. Running the same test binary again,
+with the same flags, in the same environment, prints the earlier
+output with "(cached)" in place of the elapsed time instead of running
+the tests. Only the test binary flags -cpu, -parallel, -run, -short,
+-timeout and -v allow a result to be cached. The test binary records
+the files and environment variables the tests consult through package
+os, and the result is reused only while they are unchanged. Tests
+whose inputs are read some other way, for instance by a subprocess,
+may need 'go clean -testcache' or GOCACHE=off to run again.
+See 'go help cache' for details.
+
 In addition to the build flags, the flags handled by 'go test' itself are:
 
 	-c
@@ -551,6 +620,10 @@ In addition to the build flags, the flags handled by 'go test' itself are:
 	    Install packages that are dependencies of the test.
 	    Do not run the test.
 
+	-json
+	    Convert test output to JSON suitable for automated processing.
+	    See 'godoc cmd/test2json' for the encoding details.
+
 	-o file
 		Compile the test binary to the named file.
 		The test still runs (unless -c or -i is specified).
@@ -613,6 +686,65 @@ The -x flag prints commands as they are executed.
 See also: go fmt, go fix.
 
 
+Description of build modes
+
+The 'go build' and 'go install' commands take a -buildmode argument which
+indicates which kind of object file is to be built. Currently supported values
+are:
+
+	-buildmode=default
+		Listed main packages are built into executables and listed
+		non-main packages are built into .a files (the default
+		behavior).
+
+	-buildmode=exe
+		Same as -buildmode=default.
+
+	-buildmode=c-archive
+		Build the listed main package, plus all packages it imports,
+		into a C archive file name.a. The only callable symbols will
+		be those functions exported using a cgo //export comment,
+		declared in the header name.h written next to the archive.
+		Requires exactly one main package to be listed; its main
+		function is not run.
+
+	-buildmode=c-shared
+		Build the listed main package, plus all packages it imports,
+		into a C shared library name.so, with the header name.h.
+		The only callable symbols will be those functions exported
+		using a cgo //export comment. Requires exactly one main
+		package to be listed; its main function is not run.
+
+	-buildmode=pie
+		Build the listed main packages and everything they import
+		into position independent executables (PIE).
+
+	-buildmode=shared
+		Combine the listed non-main packages, plus all packages they
+		import, into a single Go shared library, libname.so, named
+		after the import path patterns as in libstd.so for std.
+		Listed main packages are ignored. 'go install' puts the
+		library in the package directory and records it next to
+		each of its packages, for the programs built with
+		-linkshared, which load the packages from the library
+		instead of linking them in. A program can use only one
+		Go shared library, which holds the runtime.
+
+The library modes start the Go runtime and run the package
+initializers on a thread of their own when the library is loaded;
+calls into the exported functions wait for the initializers to finish.
+A library installs signal handlers only for the signals that the
+C program has left at their default action.
+
+The modes other than default and exe compile every package with
+-shared and install the results with the install suffix shared.
+The shared mode and the -linkshared flag compile every package
+with -dynlink instead and use the install suffix dynlink, so that
+a program linked with -linkshared finds the installed library.
+They are supported only on linux/amd64, with the gc toolchain
+and cgo.
+
+
 Calling between Go and C
 
 There are two different ways to call between Go and C/C++ code.
@@ -632,6 +764,49 @@ compiler.  The CC or CXX environment variables may be set to determine
 the C or C++ compiler, respectively, to use.
 
 
+Build and test caching
+
+The go command caches the package archives it compiles and the
+results of the tests that pass, in a single cache shared by all
+GOPATH settings, so that building or testing code again is fast,
+even after building it with other flags in between.
+
+A cache entry is keyed by a hash of all the inputs of the build:
+the Go version and target system, the compiler, assembler and other
+tools used, the package's import path and source files, the compiler
+flags, build tags and -installsuffix, and the archives of the packages
+it imports. A package changed in any of these ways is compiled again;
+one that is not is copied from the cache, even when its installed
+archive is out of date. The compiler records the source files of a
+package in a GOPATH workspace by import path and file name, as in
+example.com/x/x.go, so that the same package in two workspaces is
+compiled once. For other packages, and packages using cgo, the
+directory is part of the key.
+
+Packages built with gccgo, and packages using SWIG or pkg-config,
+whose inputs the go command cannot see, are never cached, nor are
+linked binaries.
+
+Test results are keyed by the test binary, its flags and the values
+of environment variables whose names begin with GO. A result is
+reused only if the files and other environment variables the test
+consulted through package os, which the test binary logs, are
+unchanged; it is not cached at all if one of those files changed
+less than two seconds before the test finished. See 'go help test'.
+
+The cache is stored in the directory named by the GOCACHE environment
+variable, by default the go-build subdirectory of the user's cache
+directory ($XDG_CACHE_HOME or $HOME/.cache on Unix systems,
+$HOME/Library/Caches on OS X and %LocalAppData% on Windows).
+Setting GOCACHE=off disables the cache. The -a build flag ignores
+the cached archives but stores the new ones.
+
+The go command removes the cache entries that it has not used in the
+last five days, checking at most once a day. 'go clean -cache' removes
+the whole cache, and 'go clean -testcache' expires the cached test
+results.
+
+
 File types
 
 The go command examines the contents of a restricted set of files
@@ -728,6 +903,28 @@ Go searches each directory listed in GOPATH to find source code,
 but new packages are always downloaded into the first directory
 in the list.
 
+Vendor Directories
+
+Code below a directory that has a subdirectory named "vendor" can
+import the packages in that vendor directory by their paths relative
+to it, without the prefix leading to the vendor directory. For
+example, code in DIR/src/foo/bar/x.go that imports "crash/bang" is
+satisfied by DIR/src/foo/vendor/crash/bang if that directory holds
+Go files. Vendor directories are searched before GOROOT and GOPATH,
+starting with the one in the directory of the importing package and
+moving up to its parents, so the innermost vendor directory
+providing the package wins.
+
+The vendored package keeps its full path, foo/vendor/crash/bang,
+which is the path 'go list' reports among the imports of foo/bar
+and the path under which it is installed. It must not be imported
+by that path: code importing "foo/vendor/crash/bang" is an error.
+A vendor directory with no Go files in a subdirectory does not hide
+the package of the same path elsewhere.
+
+'go get' never downloads or updates vendored packages; see 'go help get'.
+Vendor directories are not used in module mode.
+
 
 Import path syntax
 
@@ -878,6 +1075,91 @@ package appropriate for the Go release being used.
 Run 'go help install' for more.
 
 
+Versioned modules and go.mod files
+
+A module is a tree of Go packages that is versioned as a unit.
+A module is defined by a go.mod file in the root of the tree,
+which declares the module path, the import path prefix shared by
+all the packages in the module, and the versions of the other
+modules it requires.
+
+When the current directory or one of its parents contains a go.mod
+file, and the directory is not in the Go tree, the go command runs
+in module mode: that directory holds the main module, and imports
+are resolved using the modules it requires instead of GOPATH.
+Setting GOMODULES=off disables module mode.
+
+A go.mod file looks like:
+
+	module example.com/service
+
+	require (
+		example.com/lib v1.2.0
+		golang.org/x/text v0.1.1
+	)
+
+Versions are semantic versions of the form vMAJOR.MINOR.PATCH,
+optionally followed by a pre-release suffix, as in v1.3.0-rc.1.
+Lines beginning with // are comments.
+
+Each required module may in turn require other modules. The build
+uses the minimal version selection rule: for each module path that
+the main module requires, directly or indirectly, it selects the
+highest of the versions required, which is the oldest version that
+satisfies every requirement. The selected versions form the build
+list, which 'go list -m all' prints. The build list changes only
+when a go.mod file changes, so builds are reproducible, and two
+modules on the same machine can require different versions of the
+same dependency.
+
+An import path is provided by the module in the build list with
+the longest path that is a prefix of it. Import paths without a
+dot in their first element, like "fmt" or "net/http", are standard
+packages. Packages in modules are built in a temporary directory
+rather than installed; commands are installed to GOBIN, or to the
+bin directory of the first GOPATH entry.
+
+Modules are downloaded from the module proxy named by the GOPROXY
+environment variable and kept in the module cache in pkg/mod of the
+first GOPATH entry; once a version is in the cache, the go command
+never downloads it again. If GOPROXY is unset or "off", only the
+cache is used, so that no command accesses the network. A proxy
+may be a file:// URL naming a directory, which allows working
+entirely offline. For each module path, the proxy serves:
+
+	MODULE/@v/list          the known versions, one per line
+	MODULE/@v/VERSION.mod   the go.mod file of the version
+	MODULE/@v/VERSION.zip   the files of the version
+
+where each upper-case letter in MODULE is replaced by an exclamation
+mark followed by the lower-case letter, as in example.com/!azure.
+The files in the zip archive must be named MODULE@VERSION/FILE,
+using the module path as written.
+
+The go.sum file next to go.mod records a cryptographic checksum of
+each module version and each go.mod file used in the build, one per
+line:
+
+	example.com/lib v1.2.0 h1:2yhh3l4sBd8SvA6DPKjjEYrRb5HILsKWzHgaHQZnlc8=
+	example.com/lib v1.2.0/go.mod h1:e+F3CRqb6RE0yB3H1mAC2tr2sfsUkC/1cW4EgM4dZcA=
+
+The go command adds missing checksums to go.sum, and refuses to use
+a download whose checksum differs from the one recorded there.
+Both go.mod and go.sum should be checked in to version control.
+
+In module mode, 'go get' changes the requirements in go.mod instead
+of downloading into GOPATH. An argument path@version requires the
+given version of the module providing path; a path without a version
+requires the latest release of that module. The -u flag also updates
+the modules required by the named modules to their latest releases.
+'go get' rewrites go.mod in the format shown above, dropping any
+comments.
+
+Patterns like mod/... are not expanded in module mode; use a pattern
+relative to the current directory, like ./..., to name the packages
+in the main module.
+
+
 Description of package lists
 
 Many commands apply to a set of packages:
@@ -952,6 +1234,11 @@ control the execution of any test:
 	    Run benchmarks matching the regular expression.
 	    By default, no benchmarks run. To run all benchmarks,
 	    use '-bench .' or '-bench=.'.
+	    The regular expression is split by unbracketed slash (/)
+	    characters into a sequence of regular expressions, and each
+	    part of a benchmark's identifier must match the corresponding
+	    element in the sequence, if any. Possible parents of matches
+	    are run with b.N=1 to identify sub-benchmarks.
 
 	-benchmem
 	    Print memory allocation statistics for benchmarks.
@@ -1008,6 +1295,23 @@ control the execution of any test:
 	    Write a CPU profile to the specified file before exiting.
 	    Writes test binary as -c would.
 
+	-fuzz regexp
+	    Run the fuzz target matching the regular expression, generating
+	    new inputs until it fails or -fuzztime passes. The regular
+	    expression must match exactly one fuzz target. The package
+	    under test is always built with coverage analysis, in count
+	    mode unless -covermode says otherwise, so that fuzzing can
+	    tell which inputs reach new code. A failing input is minimized
+	    and written to testdata/fuzz/FuzzXXX.
+
+	-fuzzminimizetime t
+	    Spend at most t minimizing a failing fuzz input.
+	    The default is 60s.
+
+	-fuzztime t
+	    Stop fuzzing after t. By default fuzzing runs until it finds
+	    a failure.
+
 	-memprofile mem.out
 	    Write a memory profile to the file after all tests have passed.
 	    Writes test binary as -c would.
@@ -1029,7 +1333,14 @@ control the execution of any test:
 
 	-run regexp
 	    Run only those tests and examples matching the regular
-	    expression.
+	    expression. For tests, the regular expression is split by
+	    unbracketed slash (/) characters into a sequence of regular
+	    expressions, and each part of a test's identifier must match
+	    the corresponding element in the sequence, if any. Note that
+	    possible parents of matches are run too, so that -run=X/Y
+	    matches and runs and reports the result of all tests matching
+	    X, even those without sub-tests matching Y, because it must run
+	    them to look for those sub-tests.
 
 	-short
 	    Tell long-running tests to shorten their run time.
@@ -1040,6 +1351,11 @@ control the execution of any test:
 	-timeout t
 	    If a test runs longer than t, panic.
 
+	-trace trace.out
+	    Write an execution trace to the specified file before exiting.
+	    Writes test binary as -c would. View the trace with
+	    'go tool trace pkg.test trace.out'.
+
 	-v
 	    Verbose output: log all tests as they are run. Also print all
 	    text from Log and Logf calls even if the test succeeds.
@@ -1079,6 +1395,13 @@ A benchmark function is one named BenchmarkXXX and should have the signature,
 
 	func BenchmarkXXX(b *testing.B) { ... }
 
+A fuzz target is one named FuzzXXX and should have the signature,
+
+	func FuzzXXX(f *testing.F) { ... }
+
+Without the -fuzz flag, a fuzz target runs its fuzz function on the seed
+inputs it adds and on those stored in testdata/fuzz/FuzzXXX, like a test.
+
 An example function is similar to a test function but, instead of using
 *testing.T to report success or failure, prints output to os.Stdout.
 That output is compared against the function's "Output:" comment, which
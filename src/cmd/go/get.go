@@ -48,6 +48,16 @@ rule is that if the local installation is running version "go1", get
 searches for a branch or tag named "go1". If no such version exists it
 retrieves the most recent version of the package.
 
+Get never downloads or updates packages in vendor directories:
+the dependencies of a package that are found in a vendor directory
+are left alone, and naming a vendored package is an error. A vendor
+directory is updated along with the tree containing it, or by hand.
+See 'go help gopath' for more about vendor directories.
+
+In module mode, get instead updates the requirements in go.mod for
+the modules providing the named packages, which may be given as
+path@version. See 'go help modules'.
+
 For more about specifying packages, see 'go help packages'.
 
 For more about how 'go get' finds source code to
@@ -73,6 +83,16 @@ func runGet(cmd *Command, args []string) {
 		fatalf("go get: cannot use -f flag without -u")
 	}
 
+	if modEnabled {
+		// Module mode: update go.mod, then install.
+		args = modGet(args)
+		if *getD {
+			return
+		}
+		runInstall(cmd, args)
+		return
+	}
+
 	// Phase 1.  Download/update.
 	var stk importStack
 	for _, arg := range downloadPaths(args) {
@@ -124,7 +144,7 @@ func downloadPaths(args []string) []string {
 				expand = matchPackages(a)
 			}
 			if len(expand) > 0 {
-				out = append(out, expand...)
+				out = append(out, dropVendored(expand)...)
 				continue
 			}
 		}
@@ -133,6 +153,18 @@ func downloadPaths(args []string) []string {
 	return out
 }
 
+// dropVendored returns the import paths in list
+// that are not in vendor directories.
+func dropVendored(list []string) []string {
+	var out []string
+	for _, path := range list {
+		if _, ok := findVendor(path); !ok {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
 // downloadCache records the import paths we have already
 // considered during the download, to avoid duplicate work when
 // there is more than one dependency sequence leading to
@@ -160,6 +192,13 @@ func download(arg string, stk *importStack, getTestDeps bool) {
 		return
 	}
 
+	// A vendored package belongs to the tree that vendors it,
+	// and is updated only by updating that tree's vendor directory.
+	if _, ok := findVendor(p.ImportPath); ok {
+		errorf("go get %s: cannot download or update vendored package %s", arg, p.ImportPath)
+		return
+	}
+
 	// Only process each package once.
 	// (Unless we're fetching test dependencies for this package,
 	// in which case we want to process it again.)
@@ -193,6 +232,7 @@ func download(arg string, stk *importStack, getTestDeps bool) {
 			} else {
 				args = matchPackages(arg)
 			}
+			args = dropVendored(args)
 			isWildcard = true
 		}
 
@@ -240,17 +280,22 @@ func download(arg string, stk *importStack, getTestDeps bool) {
 		}
 
 		// Process dependencies, now that we know what they are.
+		// Vendored dependencies come with the tree that vendors them.
 		for _, dep := range p.deps {
+			if _, ok := findVendor(dep.ImportPath); ok {
+				continue
+			}
 			// Don't get test dependencies recursively.
 			download(dep.ImportPath, stk, false)
 		}
 		if getTestDeps {
 			// Process test dependencies when -t is specified.
 			// (Don't get test dependencies for test dependencies.)
-			for _, path := range p.TestImports {
-				download(path, stk, false)
-			}
-			for _, path := range p.XTestImports {
+			for _, path := range stringList(p.TestImports, p.XTestImports) {
+				path = vendoredImportPath(p, path)
+				if _, ok := findVendor(path); ok {
+					continue
+				}
 				download(path, stk, false)
 			}
 		}
@@ -299,6 +299,28 @@ Here's an example directory layout:
 Go searches each directory listed in GOPATH to find source code,
 but new packages are always downloaded into the first directory
 in the list.
+
+Vendor Directories
+
+Code below a directory that has a subdirectory named "vendor" can
+import the packages in that vendor directory by their paths relative
+to it, without the prefix leading to the vendor directory. For
+example, code in DIR/src/foo/bar/x.go that imports "crash/bang" is
+satisfied by DIR/src/foo/vendor/crash/bang if that directory holds
+Go files. Vendor directories are searched before GOROOT and GOPATH,
+starting with the one in the directory of the importing package and
+moving up to its parents, so the innermost vendor directory
+providing the package wins.
+
+The vendored package keeps its full path, foo/vendor/crash/bang,
+which is the path 'go list' reports among the imports of foo/bar
+and the path under which it is installed. It must not be imported
+by that path: code importing "foo/vendor/crash/bang" is an error.
+A vendor directory with no Go files in a subdirectory does not hide
+the package of the same path elsewhere.
+
+'go get' never downloads or updates vendored packages; see 'go help get'.
+Vendor directories are not used in module mode.
 	`,
 }
 
@@ -341,3 +363,111 @@ at the first item in the file that is not a blank line or //-style
 line comment.
 	`,
 }
+
+var helpCache = &Command{
+	UsageLine: "cache",
+	Short:     "build and test caching",
+	Long: `
+The go command caches the package archives it compiles and the
+results of the tests that pass, in a single cache shared by all
+GOPATH settings, so that building or testing code again is fast,
+even after building it with other flags in between.
+
+A cache entry is keyed by a hash of all the inputs of the build:
+the Go version and target system, the compiler, assembler and other
+tools used, the package's import path and source files, the compiler
+flags, build tags and -installsuffix, and the archives of the packages
+it imports. A package changed in any of these ways is compiled again;
+one that is not is copied from the cache, even when its installed
+archive is out of date. The compiler records the source files of a
+package in a GOPATH workspace by import path and file name, as in
+example.com/x/x.go, so that the same package in two workspaces is
+compiled once. For other packages, and packages using cgo, the
+directory is part of the key.
+
+Packages built with gccgo, and packages using SWIG or pkg-config,
+whose inputs the go command cannot see, are never cached, nor are
+linked binaries.
+
+Test results are keyed by the test binary, its flags and the values
+of environment variables whose names begin with GO. A result is
+reused only if the files and other environment variables the test
+consulted through package os, which the test binary logs, are
+unchanged; it is not cached at all if one of those files changed
+less than two seconds before the test finished. See 'go help test'.
+
+The cache is stored in the directory named by the GOCACHE environment
+variable, by default the go-build subdirectory of the user's cache
+directory ($XDG_CACHE_HOME or $HOME/.cache on Unix systems,
+$HOME/Library/Caches on OS X and %LocalAppData% on Windows).
+Setting GOCACHE=off disables the cache. The -a build flag ignores
+the cached archives but stores the new ones.
+
+The go command removes the cache entries that it has not used in the
+last five days, checking at most once a day. 'go clean -cache' removes
+the whole cache, and 'go clean -testcache' expires the cached test
+results.
+	`,
+}
+
+var helpBuildmode = &Command{
+	UsageLine: "buildmode",
+	Short:     "description of build modes",
+	Long: `
+The 'go build' and 'go install' commands take a -buildmode argument which
+indicates which kind of object file is to be built. Currently supported values
+are:
+
+	-buildmode=default
+		Listed main packages are built into executables and listed
+		non-main packages are built into .a files (the default
+		behavior).
+
+	-buildmode=exe
+		Same as -buildmode=default.
+
+	-buildmode=c-archive
+		Build the listed main package, plus all packages it imports,
+		into a C archive file name.a. The only callable symbols will
+		be those functions exported using a cgo //export comment,
+		declared in the header name.h written next to the archive.
+		Requires exactly one main package to be listed; its main
+		function is not run.
+
+	-buildmode=c-shared
+		Build the listed main package, plus all packages it imports,
+		into a C shared library name.so, with the header name.h.
+		The only callable symbols will be those functions exported
+		using a cgo //export comment. Requires exactly one main
+		package to be listed; its main function is not run.
+
+	-buildmode=pie
+		Build the listed main packages and everything they import
+		into position independent executables (PIE).
+
+	-buildmode=shared
+		Combine the listed non-main packages, plus all packages they
+		import, into a single Go shared library, libname.so, named
+		after the import path patterns as in libstd.so for std.
+		Listed main packages are ignored. 'go install' puts the
+		library in the package directory and records it next to
+		each of its packages, for the programs built with
+		-linkshared, which load the packages from the library
+		instead of linking them in. A program can use only one
+		Go shared library, which holds the runtime.
+
+The library modes start the Go runtime and run the package
+initializers on a thread of their own when the library is loaded;
+calls into the exported functions wait for the initializers to finish.
+A library installs signal handlers only for the signals that the
+C program has left at their default action.
+
+The modes other than default and exe compile every package with
+-shared and install the results with the install suffix shared.
+The shared mode and the -linkshared flag compile every package
+with -dynlink instead and use the install suffix dynlink, so that
+a program linked with -linkshared finds the installed library.
+They are supported only on linux/amd64, with the gc toolchain
+and cgo.
+	`,
+}
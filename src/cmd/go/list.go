@@ -14,7 +14,7 @@ import (
 )
 
 var cmdList = &Command{
-	UsageLine: "list [-e] [-f format] [-json] [build flags] [packages]",
+	UsageLine: "list [-e] [-f format] [-json] [-m] [build flags] [packages]",
 	Short:     "list packages",
 	Long: `
 List lists the packages named by the import paths, one per line.
@@ -74,6 +74,9 @@ syntax of package template.  The default output is equivalent to -f
         TestImports  []string // imports from TestGoFiles
         XTestGoFiles []string // _test.go files outside package
         XTestImports []string // imports from XTestGoFiles
+
+        // Module information
+        Module *Module // module containing the package, in module mode
     }
 
 The template function "join" calls strings.Join.
@@ -109,6 +112,21 @@ printing.  Erroneous packages will have a non-empty ImportPath and
 a non-nil Error field; other information may or may not be missing
 (zeroed).
 
+The -m flag causes list to list modules instead of packages, in
+module mode. The arguments are module paths; with no arguments, list
+prints the main module, and the argument "all" stands for the whole
+build list. The default output shows the module path and version.
+The struct being passed to the template is:
+
+    type Module struct {
+        Path    string // module path
+        Version string // module version; empty for the main module
+        Dir     string // directory holding the module's files, if available
+        Main    bool   // is this the main module?
+    }
+
+For more about modules, see 'go help modules'.
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
@@ -121,18 +139,28 @@ func init() {
 }
 
 var listE = cmdList.Flag.Bool("e", false, "")
-var listFmt = cmdList.Flag.String("f", "{{.ImportPath}}", "")
+var listFmt = cmdList.Flag.String("f", listDefaultFmt, "")
 var listJson = cmdList.Flag.Bool("json", false, "")
+var listM = cmdList.Flag.Bool("m", false, "")
 var nl = []byte{'\n'}
 
+const listDefaultFmt = "{{.ImportPath}}"
+
 func runList(cmd *Command, args []string) {
 	out := newTrackingWriter(os.Stdout)
 	defer out.w.Flush()
 
-	var do func(*Package)
+	if *listM && !modEnabled {
+		fatalf("go list -m: not using modules; see 'go help modules'")
+	}
+	if *listM && *listFmt == listDefaultFmt {
+		*listFmt = "{{.Path}}{{if .Version}} {{.Version}}{{end}}"
+	}
+
+	var do func(interface{})
 	if *listJson {
-		do = func(p *Package) {
-			b, err := json.MarshalIndent(p, "", "\t")
+		do = func(x interface{}) {
+			b, err := json.MarshalIndent(x, "", "\t")
 			if err != nil {
 				out.Flush()
 				fatalf("%s", err)
@@ -156,8 +184,8 @@ func runList(cmd *Command, args []string) {
 		if err != nil {
 			fatalf("%s", err)
 		}
-		do = func(p *Package) {
-			if err := tmpl.Execute(out, p); err != nil {
+		do = func(x interface{}) {
+			if err := tmpl.Execute(out, x); err != nil {
 				out.Flush()
 				fatalf("%s", err)
 			}
@@ -167,6 +195,15 @@ func runList(cmd *Command, args []string) {
 		}
 	}
 
+	if *listM {
+		mods := modListModules(args)
+		exitIfErrors()
+		for _, m := range mods {
+			do(m)
+		}
+		return
+	}
+
 	load := packages
 	if *listE {
 		load = packagesAndErrors
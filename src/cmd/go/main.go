@@ -89,10 +89,13 @@ var commands = []*Command{
 	cmdVersion,
 	cmdVet,
 
+	helpBuildmode,
 	helpC,
+	helpCache,
 	helpFileType,
 	helpGopath,
 	helpImportPath,
+	helpModules,
 	helpPackages,
 	helpTestflag,
 	helpTestfunc,
@@ -151,6 +154,8 @@ func main() {
 		os.Exit(2)
 	}
 
+	modInit()
+
 	for _, cmd := range commands {
 		if cmd.Name() == args[0] && cmd.Run != nil {
 			cmd.Flag.Usage = func() { cmd.Usage() }
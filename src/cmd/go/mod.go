@@ -0,0 +1,844 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var helpModules = &Command{
+	UsageLine: "modules",
+	Short:     "versioned modules and go.mod files",
+	Long: `
+A module is a tree of Go packages that is versioned as a unit.
+A module is defined by a go.mod file in the root of the tree,
+which declares the module path, the import path prefix shared by
+all the packages in the module, and the versions of the other
+modules it requires.
+
+When the current directory or one of its parents contains a go.mod
+file, and the directory is not in the Go tree, the go command runs
+in module mode: that directory holds the main module, and imports
+are resolved using the modules it requires instead of GOPATH.
+Setting GOMODULES=off disables module mode.
+
+A go.mod file looks like:
+
+	module example.com/service
+
+	require (
+		example.com/lib v1.2.0
+		golang.org/x/text v0.1.1
+	)
+
+Versions are semantic versions of the form vMAJOR.MINOR.PATCH,
+optionally followed by a pre-release suffix, as in v1.3.0-rc.1.
+Lines beginning with // are comments.
+
+Each required module may in turn require other modules. The build
+uses the minimal version selection rule: for each module path that
+the main module requires, directly or indirectly, it selects the
+highest of the versions required, which is the oldest version that
+satisfies every requirement. The selected versions form the build
+list, which 'go list -m all' prints. The build list changes only
+when a go.mod file changes, so builds are reproducible, and two
+modules on the same machine can require different versions of the
+same dependency.
+
+An import path is provided by the module in the build list with
+the longest path that is a prefix of it. Import paths without a
+dot in their first element, like "fmt" or "net/http", are standard
+packages. Packages in modules are built in a temporary directory
+rather than installed; commands are installed to GOBIN, or to the
+bin directory of the first GOPATH entry.
+
+Modules are downloaded from the module proxy named by the GOPROXY
+environment variable and kept in the module cache in pkg/mod of the
+first GOPATH entry; once a version is in the cache, the go command
+never downloads it again. If GOPROXY is unset or "off", only the
+cache is used, so that no command accesses the network. A proxy
+may be a file:// URL naming a directory, which allows working
+entirely offline. For each module path, the proxy serves:
+
+	MODULE/@v/list          the known versions, one per line
+	MODULE/@v/VERSION.mod   the go.mod file of the version
+	MODULE/@v/VERSION.zip   the files of the version
+
+where each upper-case letter in MODULE is replaced by an exclamation
+mark followed by the lower-case letter, as in example.com/!azure.
+The files in the zip archive must be named MODULE@VERSION/FILE,
+using the module path as written.
+
+The go.sum file next to go.mod records a cryptographic checksum of
+each module version and each go.mod file used in the build, one per
+line:
+
+	example.com/lib v1.2.0 h1:2yhh3l4sBd8SvA6DPKjjEYrRb5HILsKWzHgaHQZnlc8=
+	example.com/lib v1.2.0/go.mod h1:e+F3CRqb6RE0yB3H1mAC2tr2sfsUkC/1cW4EgM4dZcA=
+
+The go command adds missing checksums to go.sum, and refuses to use
+a download whose checksum differs from the one recorded there.
+Both go.mod and go.sum should be checked in to version control.
+
+In module mode, 'go get' changes the requirements in go.mod instead
+of downloading into GOPATH. An argument path@version requires the
+given version of the module providing path; a path without a version
+requires the latest release of that module. The -u flag also updates
+the modules required by the named modules to their latest releases.
+'go get' rewrites go.mod in the format shown above, dropping any
+comments.
+
+Patterns like mod/... are not expanded in module mode; use a pattern
+relative to the current directory, like ./..., to name the packages
+in the main module.
+	`,
+}
+
+// A Module describes a module in the build list.
+type Module struct {
+	// Note: These fields are part of the go command's public API.
+	// See list.go.
+	Path    string `json:",omitempty"` // module path
+	Version string `json:",omitempty"` // module version; empty for the main module
+	Dir     string `json:",omitempty"` // directory holding the module's files, if available
+	Main    bool   `json:",omitempty"` // is this the main module?
+}
+
+var (
+	modEnabled bool      // resolving imports with modules?
+	modRoot    string    // directory containing the main module's go.mod
+	modMain    *modFile  // the main module's go.mod
+	modList    []*Module // build list, computed by modBuildList
+)
+
+// modInit enables module mode if the current directory
+// is in a module outside the Go tree.
+func modInit() {
+	if os.Getenv("GOMODULES") == "off" {
+		return
+	}
+	if _, ok := hasSubdir(goroot, cwd); ok || cwd == goroot {
+		return
+	}
+	for dir := cwd; ; {
+		file := filepath.Join(dir, "go.mod")
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			f, err := readModFile(file)
+			if err != nil {
+				fatalf("go: %v", err)
+			}
+			modEnabled, modRoot, modMain = true, dir, f
+			return
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+// modCacheRoot returns the root of the module cache.
+func modCacheRoot() string {
+	list := filepath.SplitList(buildContext.GOPATH)
+	if len(list) == 0 || list[0] == "" {
+		fatalf("go: GOPATH must be set to hold the module cache; see 'go help modules'")
+	}
+	return filepath.Join(list[0], "pkg", "mod")
+}
+
+// modBinDir returns the directory where commands
+// from modules are installed.
+func modBinDir() string {
+	if gobin != "" {
+		return gobin
+	}
+	list := filepath.SplitList(buildContext.GOPATH)
+	if len(list) == 0 || list[0] == "" {
+		return ""
+	}
+	return filepath.Join(list[0], "bin")
+}
+
+// modEscape returns s with each upper-case letter replaced by
+// an exclamation mark followed by the lower-case letter, so that
+// module paths and versions differing only in case name different
+// files on case-insensitive file systems.
+func modEscape(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// modUnescape reverses modEscape. It reports false if s is not
+// the result of escaping some string: if it has an upper-case letter,
+// or an exclamation mark not followed by a lower-case letter.
+func modUnescape(s string) (string, bool) {
+	var buf bytes.Buffer
+	bang := false
+	for _, r := range s {
+		switch {
+		case 'A' <= r && r <= 'Z':
+			return "", false
+		case bang:
+			if r < 'a' || 'z' < r {
+				return "", false
+			}
+			r = unicode.ToUpper(r)
+			bang = false
+		case r == '!':
+			bang = true
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	if bang {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// modVersion is a module path and version, as in a requirement.
+type modVersion struct {
+	path    string
+	version string
+}
+
+func (m modVersion) String() string {
+	return m.path + "@" + m.version
+}
+
+// A modFile is a parsed go.mod file.
+type modFile struct {
+	module  string
+	require []modVersion
+}
+
+func readModFile(file string) (*modFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseModFile(file, data)
+}
+
+// parseModFile parses the contents of a go.mod file.
+// The file name is used only in error messages.
+func parseModFile(file string, data []byte) (*modFile, error) {
+	f := new(modFile)
+	seen := make(map[string]bool)
+	inRequire := false
+	for i, line := range strings.Split(string(data), "\n") {
+		lineErr := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", file, i+1, fmt.Sprintf(format, args...))
+		}
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if inRequire {
+			if len(fields) == 1 && fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+		} else {
+			switch fields[0] {
+			case "module":
+				if len(fields) != 2 {
+					return nil, lineErr("usage: module path")
+				}
+				if f.module != "" {
+					return nil, lineErr("repeated module statement")
+				}
+				path, err := unquoteModPath(fields[1])
+				if err != nil {
+					return nil, lineErr("%v", err)
+				}
+				f.module = path
+				continue
+			case "require":
+				fields = fields[1:]
+				if len(fields) == 1 && fields[0] == "(" {
+					inRequire = true
+					continue
+				}
+			default:
+				return nil, lineErr("unknown directive: %s", fields[0])
+			}
+		}
+		if len(fields) != 2 {
+			return nil, lineErr("usage: require path version")
+		}
+		path, err := unquoteModPath(fields[0])
+		if err != nil {
+			return nil, lineErr("%v", err)
+		}
+		if !semverIsValid(fields[1]) {
+			return nil, lineErr("invalid version %q for %s: must be of the form vMAJOR.MINOR.PATCH", fields[1], path)
+		}
+		if seen[path] {
+			return nil, lineErr("repeated requirement for %s", path)
+		}
+		seen[path] = true
+		f.require = append(f.require, modVersion{path, fields[1]})
+	}
+	if inRequire {
+		return nil, fmt.Errorf("%s: unterminated require block", file)
+	}
+	if f.module == "" {
+		return nil, fmt.Errorf("%s: no module statement", file)
+	}
+	return f, nil
+}
+
+// unquoteModPath returns the module path s, which may be quoted.
+func unquoteModPath(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		s = u
+	}
+	if err := checkModPath(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// checkModPath reports an error if path is not a valid module path.
+// Module paths name directories in the module cache and URLs on the
+// proxy, so they must be clean slash-separated paths: no empty, "."
+// or ".." elements, and none of the characters that have a meaning
+// in module queries or on the command line.
+func checkModPath(path string) error {
+	if path == "" || strings.HasPrefix(path, "/") || strings.ContainsAny(path, "@ \t\\") {
+		return fmt.Errorf("invalid module path %q", path)
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return fmt.Errorf("invalid module path %q", path)
+		}
+	}
+	return nil
+}
+
+// format returns the canonical text of the go.mod file.
+func (f *modFile) format() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "module %s\n", f.module)
+	if len(f.require) > 0 {
+		fmt.Fprintf(&buf, "\nrequire (\n")
+		for _, r := range f.require {
+			fmt.Fprintf(&buf, "\t%s %s\n", r.path, r.version)
+		}
+		fmt.Fprintf(&buf, ")\n")
+	}
+	return buf.Bytes()
+}
+
+// setRequire requires version m.version of module m.path,
+// replacing any existing requirement for the module.
+func (f *modFile) setRequire(m modVersion) {
+	for i, r := range f.require {
+		if r.path == m.path {
+			f.require[i] = m
+			return
+		}
+	}
+	f.require = append(f.require, m)
+	sort.Sort(byModPath(f.require))
+}
+
+type byModPath []modVersion
+
+func (x byModPath) Len() int           { return len(x) }
+func (x byModPath) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x byModPath) Less(i, j int) bool { return x[i].path < x[j].path }
+
+// semverParse splits a semantic version vMAJOR.MINOR.PATCH[-PRERELEASE]
+// into its numbers and its pre-release suffix, without the dash.
+// Only the canonical form is accepted: the numbers have no leading
+// zeros and there is no build metadata.
+func semverParse(v string) (nums [3]string, pre string, ok bool) {
+	if !strings.HasPrefix(v, "v") {
+		return
+	}
+	v = v[1:]
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+		if pre == "" {
+			return
+		}
+		for _, id := range strings.Split(pre, ".") {
+			if id == "" || strings.TrimFunc(id, isSemverIdentChar) != "" || isNum(id) && len(id) > 1 && id[0] == '0' {
+				return
+			}
+		}
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return
+	}
+	for i, p := range parts {
+		if !isNum(p) || len(p) > 1 && p[0] == '0' {
+			return
+		}
+		nums[i] = p
+	}
+	return nums, pre, true
+}
+
+func isSemverIdentChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-'
+}
+
+func isNum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// semverIsValid reports whether v is a valid semantic version.
+func semverIsValid(v string) bool {
+	_, _, ok := semverParse(v)
+	return ok
+}
+
+// semverIsRelease reports whether v is a valid semantic version
+// without a pre-release suffix.
+func semverIsRelease(v string) bool {
+	_, pre, ok := semverParse(v)
+	return ok && pre == ""
+}
+
+// semverCompare returns -1, 0, or +1 depending on whether
+// v < w, v == w, or v > w in semantic version precedence.
+// An invalid version is considered less than any valid one.
+func semverCompare(v, w string) int {
+	vn, vpre, vok := semverParse(v)
+	wn, wpre, wok := semverParse(w)
+	if !vok || !wok {
+		return compareBool(vok, wok)
+	}
+	for i := range vn {
+		if c := compareNum(vn[i], wn[i]); c != 0 {
+			return c
+		}
+	}
+	if vpre == "" || wpre == "" {
+		// A release is greater than its pre-releases.
+		return compareBool(vpre == "", wpre == "")
+	}
+	vids, wids := strings.Split(vpre, "."), strings.Split(wpre, ".")
+	for i := 0; i < len(vids) && i < len(wids); i++ {
+		x, y := vids[i], wids[i]
+		if x == y {
+			continue
+		}
+		switch xn, yn := isNum(x), isNum(y); {
+		case xn && yn:
+			return compareNum(x, y)
+		case xn != yn:
+			// Numeric identifiers are less than alphanumeric ones.
+			return compareBool(yn, xn)
+		case x < y:
+			return -1
+		default:
+			return +1
+		}
+	}
+	return compareInt(len(vids), len(wids))
+}
+
+// compareNum compares two decimal numbers without leading zeros.
+func compareNum(x, y string) int {
+	if c := compareInt(len(x), len(y)); c != 0 {
+		return c
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+func compareInt(x, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+func compareBool(x, y bool) int {
+	switch {
+	case x == y:
+		return 0
+	case x:
+		return +1
+	}
+	return -1
+}
+
+// mvsBuildList returns the build list for the target module using
+// minimal version selection: the target followed by, for each other
+// module path reachable through the requirements reported by reqs,
+// the highest version required of it, in path order.
+func mvsBuildList(target modVersion, reqs func(modVersion) ([]modVersion, error)) ([]modVersion, error) {
+	max := map[string]string{target.path: target.version}
+	seen := map[modVersion]bool{target: true}
+	queue := []modVersion{target}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		list, err := reqs(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range list {
+			if r.path == target.path {
+				// The target's own version always wins.
+				continue
+			}
+			if v, ok := max[r.path]; !ok || semverCompare(r.version, v) > 0 {
+				max[r.path] = r.version
+			}
+			if !seen[r] {
+				seen[r] = true
+				queue = append(queue, r)
+			}
+		}
+	}
+
+	var list []modVersion
+	for path, version := range max {
+		if path != target.path {
+			list = append(list, modVersion{path, version})
+		}
+	}
+	sort.Sort(byModPath(list))
+	return append([]modVersion{target}, list...), nil
+}
+
+// modReqs returns the requirements of module version m,
+// read from its go.mod file.
+func modReqs(m modVersion) ([]modVersion, error) {
+	if m.path == modMain.module && m.version == "" {
+		return modMain.require, nil
+	}
+	data, err := modFetchGoMod(m.path, m.version)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseModFile(m.String()+"/go.mod", data)
+	if err != nil {
+		return nil, err
+	}
+	if f.module != m.path {
+		return nil, fmt.Errorf("%s: go.mod declares module path %s", m, f.module)
+	}
+	return f.require, nil
+}
+
+// modBuildList returns the build list of the main module,
+// computing it on first use.
+func modBuildList() []*Module {
+	if modList != nil {
+		return modList
+	}
+	list, err := mvsBuildList(modVersion{modMain.module, ""}, modReqs)
+	if err != nil {
+		fatalf("go: %v", err)
+	}
+	modList = []*Module{{Path: modMain.module, Dir: modRoot, Main: true}}
+	for _, m := range list[1:] {
+		modList = append(modList, &Module{Path: m.path, Version: m.version})
+	}
+	return modList
+}
+
+// modInMain reports whether the import path is in the main module.
+func modInMain(path string) bool {
+	return path == modMain.module || strings.HasPrefix(path, modMain.module+"/")
+}
+
+// modDirImportPath returns the import path of the package in dir,
+// or "" if dir is not in the main module.
+func modDirImportPath(dir string) string {
+	if dir == modRoot {
+		return modMain.module
+	}
+	if rel, ok := hasSubdir(modRoot, dir); ok {
+		return modMain.module + "/" + rel
+	}
+	return ""
+}
+
+// modImport finds the package with the given import path in
+// the module that provides it, downloading the module if needed.
+// It returns the module, or nil for a standard package, and the
+// package. Like build.Import, it always returns a non-nil package.
+func modImport(path string) (*Module, *build.Package, error) {
+	var m *Module
+	if modInMain(path) {
+		m = modBuildList()[0]
+	} else {
+		if isStandardImportPath(path) {
+			// Standard package. GOPATH plays no part in module mode.
+			ctxt := buildContext
+			ctxt.GOPATH = ""
+			bp, err := ctxt.Import(path, "", build.ImportComment)
+			return nil, bp, err
+		}
+		if checkModPath(path) != nil {
+			return nil, &build.Package{ImportPath: path}, fmt.Errorf("invalid import path %q", path)
+		}
+		for _, m1 := range modBuildList() {
+			if (path == m1.Path || strings.HasPrefix(path, m1.Path+"/")) && (m == nil || len(m1.Path) > len(m.Path)) {
+				m = m1
+			}
+		}
+		if m == nil {
+			return nil, &build.Package{ImportPath: path}, fmt.Errorf("cannot find module providing package %s", path)
+		}
+	}
+
+	if m.Dir == "" {
+		dir, err := modFetchDir(m.Path, m.Version)
+		if err != nil {
+			return m, &build.Package{ImportPath: path}, err
+		}
+		m.Dir = dir
+	}
+	dir := m.Dir
+	if path != m.Path {
+		dir = filepath.Join(dir, filepath.FromSlash(path[len(m.Path)+1:]))
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		id := m.Path
+		if m.Version != "" {
+			id += "@" + m.Version
+		}
+		return m, &build.Package{ImportPath: path}, fmt.Errorf("module %s does not contain package %s", id, path)
+	}
+	bp, err := buildContext.ImportDir(dir, build.ImportComment)
+	bp.ImportPath = path
+	bp.Root = ""
+	bp.SrcRoot = ""
+	bp.PkgRoot = ""
+	bp.PkgObj = ""
+	bp.BinDir = modBinDir()
+	return m, bp, err
+}
+
+// isStandardImportPath reports whether path names a standard package:
+// its first element, or the whole path if there is only one, has no dot.
+func isStandardImportPath(path string) bool {
+	elem := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		elem = path[:i]
+	}
+	return !strings.Contains(elem, ".")
+}
+
+// modQuery returns the module version providing the package with the
+// given import path: the requested version, or the latest release if
+// version is empty or "latest", of the module with the longest path
+// that is a prefix of the import path and has such a version.
+func modQuery(path, version string) (modVersion, error) {
+	if version == "latest" {
+		version = ""
+	}
+	if version != "" && !semverIsValid(version) {
+		return modVersion{}, fmt.Errorf("invalid version %q: must be of the form vMAJOR.MINOR.PATCH", version)
+	}
+	var lastErr error
+	for prefix := path; ; {
+		if version != "" {
+			_, err := modFetchGoMod(prefix, version)
+			if err == nil {
+				return modVersion{prefix, version}, nil
+			}
+			lastErr = err
+		} else {
+			list, err := modVersions(prefix)
+			if err != nil {
+				lastErr = err
+			} else if latest := modLatest(list); latest != "" {
+				return modVersion{prefix, latest}, nil
+			}
+		}
+		i := strings.LastIndex(prefix, "/")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	if lastErr != nil {
+		return modVersion{}, fmt.Errorf("cannot find module providing package %s: %v", path, lastErr)
+	}
+	return modVersion{}, fmt.Errorf("cannot find module providing package %s", path)
+}
+
+// modLatest returns the latest version in list: the highest
+// release, or if there are none, the highest pre-release.
+func modLatest(list []string) string {
+	latest := ""
+	for _, v := range list {
+		if !semverIsValid(v) {
+			continue
+		}
+		if latest == "" || semverIsRelease(v) && !semverIsRelease(latest) ||
+			semverIsRelease(v) == semverIsRelease(latest) && semverCompare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// modGet implements 'go get' in module mode. It updates the main
+// module's requirements for the modules providing the arguments,
+// writes the new go.mod, and returns the arguments without their
+// versions, for installation.
+func modGet(args []string) []string {
+	if *getF || *getFix {
+		fatalf("go get: -f and -fix are not supported in module mode")
+	}
+
+	var paths []string
+	var named []modVersion
+	for _, arg := range args {
+		path, version := arg, ""
+		if i := strings.Index(arg, "@"); i >= 0 {
+			path, version = arg[:i], arg[i+1:]
+		}
+		paths = append(paths, path)
+		if build.IsLocalImport(path) || modInMain(path) {
+			if version != "" {
+				errorf("go get %s: cannot choose the version of the main module", arg)
+			}
+			continue
+		}
+		if strings.Contains(path, "...") {
+			errorf("go get %s: patterns are not supported in module mode", arg)
+			continue
+		}
+		if err := checkModPath(path); err != nil {
+			errorf("go get %s: %v", arg, err)
+			continue
+		}
+		m, err := modQuery(path, version)
+		if err != nil {
+			errorf("go get %s: %v", arg, err)
+			continue
+		}
+		modMain.setRequire(m)
+		named = append(named, m)
+	}
+	exitIfErrors()
+
+	if *getU {
+		// Update the dependencies of the named modules.
+		for _, m := range named {
+			list, err := mvsBuildList(m, modReqs)
+			if err != nil {
+				fatalf("go get: %v", err)
+			}
+			for _, dep := range list[1:] {
+				latest, err := modQuery(dep.path, "")
+				if err != nil {
+					errorf("go get: %v", err)
+					continue
+				}
+				if latest.path == dep.path && semverCompare(latest.version, dep.version) > 0 {
+					modMain.setRequire(latest)
+				}
+			}
+		}
+		exitIfErrors()
+	}
+
+	modList = nil
+	modBuildList()
+	if err := ioutil.WriteFile(filepath.Join(modRoot, "go.mod"), modMain.format(), 0666); err != nil {
+		fatalf("go get: %v", err)
+	}
+	return paths
+}
+
+// modListModules returns the modules named by the arguments
+// to 'go list -m': the main module when there are none, and
+// the whole build list for "all".
+func modListModules(args []string) []*Module {
+	list := modBuildList()
+	if len(args) == 0 {
+		return list[:1]
+	}
+	var mods []*Module
+	for _, arg := range args {
+		if arg == "all" {
+			mods = append(mods, list...)
+			continue
+		}
+		found := false
+		for _, m := range list {
+			if m.Path == arg {
+				mods = append(mods, m)
+				found = true
+			}
+		}
+		if !found {
+			errorf("go list -m: module %s is not in the build list", arg)
+		}
+	}
+	for _, m := range mods {
+		if m.Dir == "" {
+			if dir := modCacheDir(m.Path, m.Version); isDir(dir) {
+				m.Dir = dir
+			}
+		}
+	}
+	return mods
+}
+
+// modCacheDir returns the directory in the module cache
+// holding the files of the given module version.
+func modCacheDir(path, version string) string {
+	return filepath.Join(modCacheRoot(), modEscape(path)+"@"+modEscape(version))
+}
+
+func isDir(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,388 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var semverCompareTests = []string{
+	"v1.0.0-alpha",
+	"v1.0.0-alpha.1",
+	"v1.0.0-alpha.beta",
+	"v1.0.0-beta",
+	"v1.0.0-beta.2",
+	"v1.0.0-beta.11",
+	"v1.0.0-rc.1",
+	"v1.0.0",
+	"v1.0.1",
+	"v1.2.0",
+	"v1.10.0",
+	"v2.0.0",
+}
+
+func TestSemverCompare(t *testing.T) {
+	for i, v := range semverCompareTests {
+		for j, w := range semverCompareTests {
+			if c, want := semverCompare(v, w), compareInt(i, j); c != want {
+				t.Errorf("semverCompare(%q, %q) = %d, want %d", v, w, c, want)
+			}
+		}
+	}
+}
+
+func TestSemverIsValid(t *testing.T) {
+	for _, v := range semverCompareTests {
+		if !semverIsValid(v) {
+			t.Errorf("semverIsValid(%q) = false, want true", v)
+		}
+	}
+	for _, v := range []string{"", "1.0.0", "v1", "v1.0", "v1.0.0.0", "v01.0.0", "v1.0.0-", "v1.0.0-a..b", "v1.0.0-01", "v1.0.0+build", "v1.x.0"} {
+		if semverIsValid(v) {
+			t.Errorf("semverIsValid(%q) = true, want false", v)
+		}
+	}
+}
+
+func TestModLatest(t *testing.T) {
+	tests := []struct {
+		list   []string
+		latest string
+	}{
+		{nil, ""},
+		{[]string{"v1.0.0", "v1.10.0", "v1.2.0"}, "v1.10.0"},
+		{[]string{"v1.0.0", "v2.0.0-rc.1"}, "v1.0.0"},
+		{[]string{"v2.0.0-alpha", "v2.0.0-rc.1"}, "v2.0.0-rc.1"},
+		{[]string{"bad", "v0.1.0"}, "v0.1.0"},
+	}
+	for _, tt := range tests {
+		if latest := modLatest(tt.list); latest != tt.latest {
+			t.Errorf("modLatest(%q) = %q, want %q", tt.list, latest, tt.latest)
+		}
+	}
+}
+
+func TestIsStandardImportPath(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		std  bool
+	}{
+		{"fmt", true},
+		{"net/http", true},
+		{"example.com", false},
+		{"example.com/a", false},
+		{"a/b.c", true},
+	} {
+		if std := isStandardImportPath(tt.path); std != tt.std {
+			t.Errorf("isStandardImportPath(%q) = %v, want %v", tt.path, std, tt.std)
+		}
+	}
+}
+
+func TestModEscape(t *testing.T) {
+	if s, want := modEscape("github.com/Azure/go-SDK"), "github.com/!azure/go-!s!d!k"; s != want {
+		t.Errorf("modEscape = %q, want %q", s, want)
+	}
+	if s, ok := modUnescape("github.com/!azure/go-!s!d!k"); s != "github.com/Azure/go-SDK" || !ok {
+		t.Errorf("modUnescape = %q, %v, want %q, true", s, ok, "github.com/Azure/go-SDK")
+	}
+	for _, bad := range []string{"github.com/Azure", "x!", "x!!a", "x!1"} {
+		if s, ok := modUnescape(bad); ok {
+			t.Errorf("modUnescape(%q) = %q, true, want false", bad, s)
+		}
+	}
+}
+
+const testModFile = `// The service.
+module "example.com/svc"
+
+require example.com/c v0.1.0 // single
+require (
+	example.com/b v1.2.0-rc.1
+	example.com/a v1.0.0
+)
+`
+
+func TestParseModFile(t *testing.T) {
+	f, err := parseModFile("go.mod", []byte(testModFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &modFile{
+		module: "example.com/svc",
+		require: []modVersion{
+			{"example.com/c", "v0.1.0"},
+			{"example.com/b", "v1.2.0-rc.1"},
+			{"example.com/a", "v1.0.0"},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("parseModFile = %+v, want %+v", f, want)
+	}
+
+	f.setRequire(modVersion{"example.com/b", "v1.2.0"})
+	f.setRequire(modVersion{"example.com/d", "v0.0.1"})
+	const formatted = `module example.com/svc
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.2.0
+	example.com/c v0.1.0
+	example.com/d v0.0.1
+)
+`
+	if s := string(f.format()); s != formatted {
+		t.Errorf("format:\n%s\nwant:\n%s", s, formatted)
+	}
+	f1, err := parseModFile("go.mod", f.format())
+	if err != nil || !reflect.DeepEqual(f1, f) {
+		t.Errorf("parseModFile(format()) = %+v, %v, want %+v", f1, err, f)
+	}
+}
+
+var parseModFileErrorTests = []struct {
+	in  string
+	err string
+}{
+	{"require example.com/a v1.0.0\n", "go.mod: no module statement"},
+	{"module a\nmodule b\n", "go.mod:2: repeated module statement"},
+	{"module a\nrequire example.com/a 1.0\n", `go.mod:2: invalid version "1.0"`},
+	{"module a\nrequire example.com/a\n", "go.mod:2: usage: require path version"},
+	{"module a\nrequire (\nexample.com/a v1.0.0\nexample.com/a v1.1.0\n)\n", "go.mod:4: repeated requirement"},
+	{"module a\nrequire (\n", "go.mod: unterminated require block"},
+	{"module ./a\n", `go.mod:1: invalid module path "./a"`},
+	{"module a\nrequire example.com/../../x v1.0.0\n", `go.mod:2: invalid module path "example.com/../../x"`},
+	{"module a\nrequire example.com/./x v1.0.0\n", `go.mod:2: invalid module path "example.com/./x"`},
+	{"module a\nreplace b\n", "go.mod:2: unknown directive: replace"},
+}
+
+func TestParseModFileErrors(t *testing.T) {
+	for _, tt := range parseModFileErrorTests {
+		_, err := parseModFile("go.mod", []byte(tt.in))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("parseModFile(%q) = %v, want error beginning %q", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestMVSBuildList(t *testing.T) {
+	// A requires B 1.2 and C 1.2; B 1.2 requires D 1.3;
+	// C 1.2 requires D 1.4; C 1.3, which nothing requires,
+	// requires F 1.1, and D 1.4 requires A 1.0.
+	graph := map[string][]string{
+		"A":        {"B@v1.2.0", "C@v1.2.0"},
+		"B@v1.2.0": {"D@v1.3.0"},
+		"C@v1.2.0": {"D@v1.4.0"},
+		"C@v1.3.0": {"F@v1.1.0"},
+		"D@v1.3.0": {"E@v1.2.0"},
+		"D@v1.4.0": {"A@v1.0.0"},
+	}
+	reqs := func(m modVersion) ([]modVersion, error) {
+		key := m.path
+		if m.version != "" {
+			key += "@" + m.version
+		}
+		var list []modVersion
+		for _, r := range graph[key] {
+			i := strings.Index(r, "@")
+			list = append(list, modVersion{r[:i], r[i+1:]})
+		}
+		return list, nil
+	}
+	list, err := mvsBuildList(modVersion{"A", ""}, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range list {
+		got = append(got, fmt.Sprint(m))
+	}
+	want := []string{"A@", "B@v1.2.0", "C@v1.2.0", "D@v1.4.0", "E@v1.2.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mvsBuildList = %v, want %v", got, want)
+	}
+}
+
+func TestCheckModPath(t *testing.T) {
+	for _, path := range []string{"example.com", "example.com/a/b", "gopkg.in/yaml.v2", "example.com/a-b_c~d"} {
+		if err := checkModPath(path); err != nil {
+			t.Errorf("checkModPath(%q) = %v, want nil", path, err)
+		}
+	}
+	for _, path := range []string{"", ".", "..", "./a", "../a", "/a", "a/", "a//b", "a/./b", "a/../b", "a/..", "a@v1", "a b", `a\b`} {
+		if err := checkModPath(path); err == nil {
+			t.Errorf("checkModPath(%q) = nil, want error", path)
+		}
+	}
+}
+
+func TestModFetchEscape(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modfetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	proxy := filepath.Join(dir, "proxy")
+	if err := os.MkdirAll(proxy, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("secret\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxy))
+
+	// proxy/x/../../secret is dir/secret.
+	if data, err := modProxyGet("x/../..", "secret"); err == nil {
+		t.Errorf("modProxyGet read %q from outside the proxy", data)
+	}
+	if _, err := modFetchGoMod("x/../..", "v1.0.0"); err == nil {
+		t.Errorf("modFetchGoMod accepted a module path leaving the module cache")
+	}
+	if _, err := modFetchDir("x/../..", "v1.0.0"); err == nil {
+		t.Errorf("modFetchDir accepted a module path leaving the module cache")
+	}
+}
+
+func TestModFetchDirEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modfetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A zip with directory entries, as most zip tools write them.
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, name := range []string{"example.com/m@v1.0.0/", "example.com/m@v1.0.0/sub/", "example.com/m@v1.0.0/sub/x.go"} {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(name, "/") {
+			w.Write([]byte("package sub\n"))
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	proxy := filepath.Join(dir, "proxy", "example.com", "m", "@v")
+	if err := os.MkdirAll(proxy, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(proxy, "v1.0.0.zip"), buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(filepath.Join(dir, "proxy")))
+	defer func(gopath, root string, sums map[string]string) {
+		buildContext.GOPATH, modRoot, modSums = gopath, root, sums
+	}(buildContext.GOPATH, modRoot, modSums)
+	buildContext.GOPATH = filepath.Join(dir, "gopath")
+	modRoot = dir
+	modSums = nil
+
+	mdir, err := modFetchDir("example.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(mdir, "sub", "x.go"))
+	if err != nil || string(data) != "package sub\n" {
+		t.Errorf("reading extracted sub/x.go = %q, %v", data, err)
+	}
+}
+
+func TestModVersionsCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modfetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	os.Setenv("GOPROXY", "off")
+	defer func(gopath string) { buildContext.GOPATH = gopath }(buildContext.GOPATH)
+	buildContext.GOPATH = dir
+
+	for _, v := range []string{"v1.0.0-RC.1", "v1.0.0"} {
+		if err := modWriteFile(modDownloadFile("example.com/m", v, ".mod"), []byte("module example.com/m\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := modVersions("example.com/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v1.0.0-RC.1", "v1.0.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("modVersions = %q, want %q", versions, want)
+	}
+}
+
+func TestModFetchDirHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modfetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two modules holding the same file.
+	for _, path := range []string{"example.com/a", "example.com/b"} {
+		var buf bytes.Buffer
+		z := zip.NewWriter(&buf)
+		w, err := z.Create(path + "@v1.0.0/x.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("package x\n"))
+		if err := z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		proxy := filepath.Join(dir, "proxy", filepath.FromSlash(path), "@v")
+		if err := os.MkdirAll(proxy, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(proxy, "v1.0.0.zip"), buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer os.Setenv("GOPROXY", os.Getenv("GOPROXY"))
+	os.Setenv("GOPROXY", "file://"+filepath.ToSlash(filepath.Join(dir, "proxy")))
+	defer func(gopath, root string, sums map[string]string) {
+		buildContext.GOPATH, modRoot, modSums = gopath, root, sums
+	}(buildContext.GOPATH, modRoot, modSums)
+	buildContext.GOPATH = filepath.Join(dir, "gopath")
+	modRoot = dir
+	modSums = nil
+
+	for _, path := range []string{"example.com/a", "example.com/b"} {
+		if _, err := modFetchDir(path, "v1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ha, hb := modSums["example.com/a v1.0.0"], modSums["example.com/b v1.0.0"]
+	if ha == "" || ha == hb {
+		t.Errorf("modules with the same files have checksums %q and %q, want different ones", ha, hb)
+	}
+	want, err := modHash([]string{"example.com/a@v1.0.0/x.go"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("package x\n")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ha != want {
+		t.Errorf("checksum of example.com/a = %q, want %q", ha, want)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !cmd_go_bootstrap
+
+// This code is compiled into the real 'go' binary, but it is not
+// compiled into the binary that is built during all.bash, so as
+// to avoid needing to build archive/zip and crypto/sha256 during
+// the bootstrap process.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// modProxyGet returns the file with the given name for the module
+// path from the proxy named by $GOPROXY.
+func modProxyGet(path, name string) ([]byte, error) {
+	if err := checkModPath(path); err != nil {
+		return nil, err
+	}
+	proxy := os.Getenv("GOPROXY")
+	if proxy == "" || proxy == "off" {
+		return nil, fmt.Errorf("module %s: not in the module cache, and downloads are disabled (GOPROXY=%s)", path, proxy)
+	}
+	url := strings.TrimSuffix(proxy, "/") + "/" + modEscape(path) + "/" + name
+	switch {
+	case strings.HasPrefix(url, "file://"):
+		data, err := ioutil.ReadFile(filepath.FromSlash(strings.TrimPrefix(url, "file://")))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: not found", url)
+		}
+		return data, err
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		return httpGET(url)
+	}
+	return nil, fmt.Errorf("GOPROXY=%s: unsupported URL scheme", proxy)
+}
+
+// modDownloadFile returns the name of the file in the module cache
+// holding the download with the given suffix for a module version.
+func modDownloadFile(path, version, suffix string) string {
+	return filepath.Join(modCacheRoot(), "cache", "download", modEscape(path), "@v", modEscape(version)+suffix)
+}
+
+// modVersions returns the known versions of the module path,
+// as listed by the proxy, or if downloads are disabled, as found
+// in the module cache.
+func modVersions(path string) ([]string, error) {
+	if err := checkModPath(path); err != nil {
+		return nil, err
+	}
+	var list []string
+	if proxy := os.Getenv("GOPROXY"); proxy == "" || proxy == "off" {
+		files, err := filepath.Glob(modDownloadFile(path, "*", ".mod"))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			// The file names are escaped; see modDownloadFile.
+			if v, ok := modUnescape(strings.TrimSuffix(filepath.Base(f), ".mod")); ok {
+				list = append(list, v)
+			}
+		}
+	} else {
+		data, err := modProxyGet(path, "@v/list")
+		if err != nil {
+			return nil, err
+		}
+		list = strings.Fields(string(data))
+	}
+	var versions []string
+	for _, v := range list {
+		if semverIsValid(v) {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// modFetchGoMod returns the go.mod file of the module version,
+// downloading it into the module cache if needed, after checking
+// it against go.sum.
+func modFetchGoMod(path, version string) ([]byte, error) {
+	if err := checkModPath(path); err != nil {
+		return nil, err
+	}
+	file := modDownloadFile(path, version, ".mod")
+	data, err := ioutil.ReadFile(file)
+	cached := err == nil
+	if !cached {
+		data, err = modProxyGet(path, "@v/"+version+".mod")
+		if err != nil {
+			return nil, err
+		}
+	}
+	h, err := modHash([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := modCheckSum(path, version+"/go.mod", h); err != nil {
+		return nil, err
+	}
+	if !cached {
+		if err := modWriteFile(file, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// modFetchDir returns the directory holding the files of the module
+// version, downloading and extracting them into the module cache if
+// needed, after checking them against go.sum.
+func modFetchDir(path, version string) (string, error) {
+	if err := checkModPath(path); err != nil {
+		return "", err
+	}
+	dir := modCacheDir(path, version)
+	zipfile := modDownloadFile(path, version, ".zip")
+	if isDir(dir) {
+		// The hash of the zip file, computed when it was extracted.
+		h, err := ioutil.ReadFile(zipfile + "hash")
+		if err == nil {
+			if err := modCheckSum(path, version, strings.TrimSpace(string(h))); err != nil {
+				return "", err
+			}
+			return dir, nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(zipfile)
+	cached := err == nil
+	if !cached {
+		fmt.Fprintf(os.Stderr, "go: downloading %s %s\n", path, version)
+		data, err = modProxyGet(path, "@v/"+version+".zip")
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(data) > modMaxZipFile {
+		return "", fmt.Errorf("%s@%s: zip file too large", path, version)
+	}
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("%s@%s: %v", path, version, err)
+	}
+	prefix := path + "@" + version + "/"
+	files := make(map[string]*zip.File)
+	var names []string
+	var size uint64
+	for _, f := range z.File {
+		name := f.Name
+		if !strings.HasPrefix(name, prefix) {
+			return "", fmt.Errorf("%s@%s: unexpected file %s in zip", path, version, name)
+		}
+		if strings.HasSuffix(name, "/") {
+			// Directory entry; the directories are created
+			// as needed when the files are extracted.
+			continue
+		}
+		name = name[len(prefix):]
+		if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") || hasPathElem(name, "..") {
+			return "", fmt.Errorf("%s@%s: invalid file name %s in zip", path, version, f.Name)
+		}
+		if files[name] != nil {
+			return "", fmt.Errorf("%s@%s: duplicate file %s in zip", path, version, f.Name)
+		}
+		size += f.UncompressedSize64
+		if size > modMaxZipFile {
+			return "", fmt.Errorf("%s@%s: unzipped files too large", path, version)
+		}
+		files[name] = f
+		names = append(names, name)
+	}
+	// The hash covers the full names in the zip, so that it depends
+	// on the module path and version as well as on the files.
+	var hnames []string
+	for _, name := range names {
+		hnames = append(hnames, prefix+name)
+	}
+	h, err := modHash(hnames, func(name string) (io.ReadCloser, error) {
+		return openZipFile(files[name[len(prefix):]])
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s@%s: %v", path, version, err)
+	}
+	if err := modCheckSum(path, version, h); err != nil {
+		return "", err
+	}
+	if !cached {
+		if err := modWriteFile(zipfile, data); err != nil {
+			return "", err
+		}
+	}
+
+	// Extract into a temporary directory and rename it into place,
+	// so that an interrupted extraction leaves no partial tree.
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	for _, name := range names {
+		if err := extractZipFile(filepath.Join(tmp, filepath.FromSlash(name)), files[name]); err != nil {
+			return "", fmt.Errorf("%s@%s: %v", path, version, err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil && !isDir(dir) {
+		return "", err
+	}
+	if err := modWriteFile(zipfile+"hash", []byte(h+"\n")); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func extractZipFile(file string, f *zip.File) error {
+	r, err := openZipFile(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	w, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// modMaxZipFile is the maximum size of a module zip file,
+// and of the files extracted from it.
+const modMaxZipFile = 500 << 20
+
+// openZipFile opens the file f in a zip. Reading it fails if it holds
+// more data than its header declares, so that the sizes checked by
+// modFetchDir bound what is read.
+func openZipFile(f *zip.File) (io.ReadCloser, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &zipFileReader{r, f.Name, int64(f.UncompressedSize64)}, nil
+}
+
+type zipFileReader struct {
+	io.ReadCloser
+	name string
+	left int64 // bytes left of the declared size
+}
+
+func (r *zipFileReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n, fmt.Errorf("%s: file larger than its declared size", r.name)
+	}
+	return n, err
+}
+
+// hasPathElem reports whether the slash-separated path
+// has an element equal to elem.
+func hasPathElem(path, elem string) bool {
+	for _, e := range strings.Split(path, "/") {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
+
+// modWriteFile writes data to the named file in the module cache,
+// creating its directory if needed.
+func modWriteFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0666)
+}
+
+// modHash returns the "h1:" checksum of the named files: the base64
+// encoded SHA-256 hash of a summary listing the SHA-256 hash and the
+// name of each file, in name order. The open function returns the
+// contents of a file. The files of a module zip are named path@version/name,
+// as in the zip; a go.mod file is named go.mod alone.
+func modHash(names []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	summary := sha256.New()
+	for _, name := range names {
+		r, err := open(name)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// modSums holds the checksums in the main module's go.sum file,
+// keyed by module path and version, separated by a space.
+var modSums map[string]string
+
+// modCheckSum checks the checksum h of the module version against
+// go.sum, adding it to go.sum if it is not there yet.
+// For go.mod files, the version has a "/go.mod" suffix.
+func modCheckSum(path, version, h string) error {
+	if modSums == nil {
+		if err := modReadSum(); err != nil {
+			return err
+		}
+	}
+	key := path + " " + version
+	if want, ok := modSums[key]; ok {
+		if h != want {
+			return fmt.Errorf("verifying %s@%s: checksum mismatch\n\tdownloaded: %s\n\tgo.sum:     %s", path, version, h, want)
+		}
+		return nil
+	}
+	modSums[key] = h
+	return modWriteSum()
+}
+
+func modReadSum() error {
+	modSums = make(map[string]string)
+	file := filepath.Join(modRoot, "go.sum")
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 || !strings.HasPrefix(f[2], "h1:") {
+			return fmt.Errorf("%s:%d: malformed line", file, i+1)
+		}
+		modSums[f[0]+" "+f[1]] = f[2]
+	}
+	return nil
+}
+
+func modWriteSum() error {
+	var keys []string
+	for key := range modSums {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s %s\n", key, modSums[key])
+	}
+	return ioutil.WriteFile(filepath.Join(modRoot, "go.sum"), buf.Bytes(), 0666)
+}
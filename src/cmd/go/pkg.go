@@ -11,6 +11,7 @@ import (
 	"go/build"
 	"go/scanner"
 	"go/token"
+	"io/ioutil"
 	"os"
 	pathpkg "path"
 	"path/filepath"
@@ -73,6 +74,9 @@ type Package struct {
 	XTestGoFiles []string `json:",omitempty"` // _test.go files outside package
 	XTestImports []string `json:",omitempty"` // imports from XTestGoFiles
 
+	// Module information
+	Module *Module `json:",omitempty"` // module containing the package, in module mode
+
 	// Unexported fields are not part of the public API.
 	build        *build.Package
 	pkgdir       string // overrides build.PkgDir
@@ -232,24 +236,34 @@ func makeImportValid(r rune) rune {
 // loadImport scans the directory named by path, which must be an import path,
 // but possibly a local import path (an absolute file system path or one beginning
 // with ./ or ../).  A local relative path is interpreted relative to srcDir.
+// If parent is not nil, the import appears in the package parent, and
+// it is resolved using the vendor directories that apply to parent.
 // It returns a *Package describing the package found in that directory.
-func loadImport(path string, srcDir string, stk *importStack, importPos []token.Position) *Package {
+func loadImport(path string, srcDir string, parent *Package, stk *importStack, importPos []token.Position) *Package {
 	stk.push(path)
 	defer stk.pop()
 
 	// Determine canonical identifier for this package.
 	// For a local import the identifier is the pseudo-import path
 	// we create from the full directory to the package.
+	// For a vendored import it is the path of the vendored copy.
 	// Otherwise it is the usual import path.
 	importPath := path
+	origPath := path
 	isLocal := build.IsLocalImport(path)
 	if isLocal {
 		importPath = dirToImportPath(filepath.Join(srcDir, path))
+	} else if !modEnabled {
+		path = vendoredImportPath(parent, path)
+		importPath = path
 	}
 	if p := packageCache[importPath]; p != nil {
 		if perr := disallowInternal(srcDir, p, stk); perr != p {
 			return perr
 		}
+		if perr := disallowVendor(origPath, p, stk); perr != p {
+			return perr
+		}
 		return reusePackage(p, stk)
 	}
 
@@ -264,12 +278,18 @@ func loadImport(path string, srcDir string, stk *importStack, importPos []token.
 	//
 	// TODO: After Go 1, decide when to pass build.AllowBinary here.
 	// See issue 3268 for mistakes to avoid.
-	bp, err := buildContext.Import(path, srcDir, build.ImportComment)
+	var bp *build.Package
+	var err error
+	if modEnabled && !isLocal {
+		p.Module, bp, err = modImport(path)
+	} else {
+		bp, err = buildContext.Import(path, srcDir, build.ImportComment)
+	}
 	bp.ImportPath = importPath
 	if gobin != "" {
 		bp.BinDir = gobin
 	}
-	if err == nil && !isLocal && bp.ImportComment != "" && bp.ImportComment != path {
+	if err == nil && !isLocal && bp.ImportComment != "" && bp.ImportComment != path && bp.ImportComment != origPath {
 		err = fmt.Errorf("code in directory %s expects import %q", bp.Dir, bp.ImportComment)
 	}
 	p.load(stk, bp, err)
@@ -282,10 +302,107 @@ func loadImport(path string, srcDir string, stk *importStack, importPos []token.
 	if perr := disallowInternal(srcDir, p, stk); perr != p {
 		return perr
 	}
+	if perr := disallowVendor(origPath, p, stk); perr != p {
+		return perr
+	}
 
 	return p
 }
 
+// vendoredImportPath returns the import path of the package that
+// satisfies an import of path in the package parent: the path of the
+// package in the innermost vendor directory providing it, or else
+// path itself. The vendor directories that apply to parent are those
+// named vendor in parent's directory and in each of its parents, up
+// to the src directory of parent's GOROOT or GOPATH tree.
+// If parent is x/y/z, path might expand to x/y/z/vendor/path,
+// x/y/vendor/path, x/vendor/path, or vendor/path.
+func vendoredImportPath(parent *Package, path string) string {
+	if parent == nil || parent.Root == "" {
+		return path
+	}
+	root := filepath.Join(parent.Root, "src")
+	if filepath.Join(root, filepath.FromSlash(parent.ImportPath)) != filepath.Clean(parent.Dir) {
+		// Not in the tree at the expected place, as for
+		// a package hidden by one earlier in GOPATH.
+		return path
+	}
+	for prefix := parent.ImportPath; ; {
+		vendor := filepath.Join(root, filepath.FromSlash(prefix), "vendor")
+		if isDir(vendor) {
+			dir := filepath.Join(vendor, filepath.FromSlash(path))
+			if isDir(dir) && hasGoFiles(dir) {
+				return pathpkg.Join(prefix, "vendor", path)
+			}
+		}
+		if prefix == "" {
+			return path
+		}
+		i := strings.LastIndex(prefix, "/")
+		if i < 0 {
+			i = 0
+		}
+		prefix = prefix[:i]
+	}
+}
+
+// hasGoFiles reports whether dir contains any .go files.
+// A vendor directory provides a package only if it has Go files,
+// so that vendoring just a/b/c does not hide the non-vendored a/b.
+func hasGoFiles(dir string) bool {
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// disallowVendor checks that p may be imported as path.
+// Vendored packages are imported by the path they have in the
+// vendor directory: x/vendor/y must be imported as y, never as
+// x/vendor/y.
+// If the import is allowed, disallowVendor returns the original package p.
+// If not, it returns a new package containing just an appropriate error.
+func disallowVendor(path string, p *Package, stk *importStack) *Package {
+	// The stack includes p.ImportPath.
+	// If that's the only thing on the stack, we started
+	// with a name given on the command line, not an
+	// import. Anything listed on the command line is fine.
+	if len(*stk) == 1 {
+		return p
+	}
+
+	i, ok := findVendor(path)
+	if !ok {
+		return p
+	}
+	perr := *p
+	perr.Error = &PackageError{
+		ImportStack: stk.copy(),
+		Err:         "must be imported as " + path[i+len("vendor/"):],
+	}
+	perr.Incomplete = true
+	return &perr
+}
+
+// findVendor looks for the final "vendor" path element in the given import path.
+// If there isn't one, findVendor returns ok=false.
+// Otherwise, findVendor returns ok=true and the index of the "vendor".
+func findVendor(path string) (index int, ok bool) {
+	// Two cases, depending on vendor at start of string or not.
+	// The order matters: we must return the index of the final element,
+	// because the final one is where the vendored path begins.
+	switch {
+	case strings.Contains(path, "/vendor/"):
+		return strings.LastIndex(path, "/vendor/") + 1, true
+	case strings.HasPrefix(path, "vendor/"):
+		return 0, true
+	}
+	return 0, false
+}
+
 // reusePackage reuses package p to satisfy the import at the top
 // of the import stack stk.  If this use causes an import loop,
 // reusePackage updates p's error information to record the loop.
@@ -335,6 +452,13 @@ func disallowInternal(srcDir string, p *Package, stk *importStack) *Package {
 		return p
 	}
 
+	// The generated 'testmain' package is allowed to access testing/internal/...,
+	// as if it were generated into the testing directory tree
+	// (it's actually in a temporary directory outside any Go tree).
+	if strings.HasPrefix(p.ImportPath, "testing/internal") && len(*stk) >= 2 && (*stk)[len(*stk)-2] == "testmain" {
+		return p
+	}
+
 	// Check for "internal" element: four cases depending on begin of string and/or end of string.
 	i, ok := findInternal(p.ImportPath)
 	if !ok {
@@ -394,14 +518,16 @@ var goTools = map[string]targetDir{
 	"cmd/addr2line":                        toTool,
 	"cmd/api":                              toTool,
 	"cmd/cgo":                              toTool,
+	"cmd/cover":                            toTool,
 	"cmd/fix":                              toTool,
 	"cmd/link":                             toTool,
 	"cmd/nm":                               toTool,
 	"cmd/objdump":                          toTool,
 	"cmd/pack":                             toTool,
 	"cmd/pprof":                            toTool,
+	"cmd/test2json":                        toTool,
+	"cmd/trace":                            toTool,
 	"cmd/yacc":                             toTool,
-	"golang.org/x/tools/cmd/cover":         toTool,
 	"golang.org/x/tools/cmd/godoc":         toBin,
 	"golang.org/x/tools/cmd/vet":           toTool,
 	"code.google.com/p/go.tools/cmd/cover": stalePath,
@@ -497,8 +623,9 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 		if p.target != "" && buildContext.GOOS == "windows" {
 			p.target += ".exe"
 		}
-	} else if p.local {
-		// Local import turned into absolute path.
+	} else if p.local || p.Module != nil {
+		// Local import turned into absolute path,
+		// or package in a module.
 		// No permanent install target.
 		p.target = ""
 	} else {
@@ -516,6 +643,11 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 	if len(p.CgoFiles) > 0 && (!p.Standard || !cgoSyscallExclude[p.ImportPath]) {
 		importPaths = append(importPaths, "syscall")
 	}
+	// The linker needs runtime/cgo for the build modes
+	// that link externally.
+	if p.Name == "main" && len(p.CgoFiles) == 0 && buildModeExternal() {
+		importPaths = append(importPaths, "runtime/cgo")
+	}
 	// Everything depends on runtime, except runtime and unsafe.
 	if !p.Standard || (p.ImportPath != "runtime" && p.ImportPath != "unsafe") {
 		importPaths = append(importPaths, "runtime")
@@ -581,7 +713,7 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 		if path == "C" {
 			continue
 		}
-		p1 := loadImport(path, p.Dir, stk, p.build.ImportPos[path])
+		p1 := loadImport(path, p.Dir, p, stk, p.build.ImportPos[path])
 		if p1.local {
 			if !p.local && p.Error == nil {
 				p.Error = &PackageError{
@@ -595,6 +727,13 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 			}
 			path = p1.ImportPath
 			importPaths[i] = path
+		} else if p1.ImportPath != path {
+			// Vendored import: record the path of the vendored copy.
+			path = p1.ImportPath
+			importPaths[i] = path
+			if i < len(p.Imports) {
+				p.Imports[i] = path
+			}
 		}
 		deps[path] = p1
 		imports = append(imports, p1)
@@ -857,6 +996,13 @@ func loadPackage(arg string, stk *importStack) *Package {
 	// This lets you run go test ./ioutil in package io and be
 	// referring to io/ioutil rather than a hypothetical import of
 	// "./ioutil".
+	if build.IsLocalImport(arg) && modEnabled {
+		// In module mode, a directory in the main module
+		// is named by its import path in the module.
+		if path := modDirImportPath(filepath.Join(cwd, arg)); path != "" {
+			arg = path
+		}
+	}
 	if build.IsLocalImport(arg) {
 		bp, _ := buildContext.ImportDir(filepath.Join(cwd, arg), build.FindOnly)
 		if bp.ImportPath != "" && bp.ImportPath != "." {
@@ -864,7 +1010,7 @@ func loadPackage(arg string, stk *importStack) *Package {
 		}
 	}
 
-	return loadImport(arg, cwd, stk, nil)
+	return loadImport(arg, cwd, nil, stk, nil)
 }
 
 // packages returns the packages named by the
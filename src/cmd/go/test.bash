@@ -273,6 +273,45 @@ fi
 rm -f ./testdata/err
 unset GOPATH
 
+export GOPATH=$(pwd)/testdata/vendor
+TEST 'vendor directories - innermost wins'
+d=$(mktemp -d -t testgoXXX)
+if ! ./testgo build -o $d/hello vend/hello; then
+	echo 'go build vend/hello failed'
+	ok=false
+elif [ "$($d/hello)" != "vend/hello/vendor/p vend/vendor/p" ]; then
+	echo 'vend/hello used the wrong vendored packages:'
+	$d/hello
+	ok=false
+fi
+rm -rf $d
+TEST 'vendor directories - go list reports vendored path'
+if [ "$(./testgo list -f '{{.Imports}}' vend/hello)" != "[fmt vend/hello/vendor/p vend/x]" ]; then
+	echo 'go list vend/hello reported wrong imports:'
+	./testgo list -f '{{.Imports}}' vend/hello
+	ok=false
+fi
+TEST 'vendor directories - import by vendored path'
+if ./testgo build vend/bad 2>testdata/err; then
+	echo 'go build vend/bad succeeded'
+	ok=false
+elif ! grep 'must be imported as q' testdata/err >/dev/null; then
+	echo 'go build did not mention vendored import:'
+	cat testdata/err
+	ok=false
+fi
+TEST 'vendor directories - go get refuses vendored package'
+if ./testgo get -d vend/vendor/p 2>testdata/err; then
+	echo 'go get vend/vendor/p succeeded'
+	ok=false
+elif ! grep 'cannot download or update vendored package' testdata/err >/dev/null; then
+	echo 'go get did not refuse vendored package:'
+	cat testdata/err
+	ok=false
+fi
+rm -f ./testdata/err
+unset GOPATH
+
 export GOPATH=$(pwd)/testdata/src
 TEST disallowed C source files
 export GOPATH=$(pwd)/testdata
@@ -842,6 +881,59 @@ d=$(TMPDIR=/var/tmp mktemp -d -t testgoXXX)
 ./testgo test -short -cover ./testdata/cgocover >testdata/cover.txt 2>&1 || ok=false
 checkcoverage
 
+TEST go test -fuzz finds a failing input
+d=$(TMPDIR=/var/tmp mktemp -d -t testgoXXX)
+export GOPATH=$d
+mkdir -p $d/src/fuzzme
+# Random inputs almost never start with "FUZ"; fuzzing gets there
+# by following the coverage of each branch in turn.
+cat >$d/src/fuzzme/fuzzme.go <<EOF
+package fuzzme
+
+func Check(b []byte) bool {
+	if len(b) > 0 && b[0] == 'F' {
+		if len(b) > 1 && b[1] == 'U' {
+			if len(b) > 2 && b[2] == 'Z' {
+				return true
+			}
+		}
+	}
+	return false
+}
+EOF
+cat >$d/src/fuzzme/fuzzme_test.go <<EOF
+package fuzzme
+
+import "testing"
+
+func FuzzCheck(f *testing.F) {
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if Check(b) {
+			t.Fatalf("Check(%q) = true", b)
+		}
+	})
+}
+EOF
+if ./testgo test -fuzz=FuzzCheck -fuzztime=60s fuzzme >$d/out 2>&1; then
+	echo go test -fuzz=FuzzCheck did not find the failing input
+	cat $d/out
+	ok=false
+elif ! grep -q 'Failing input written to testdata/fuzz/FuzzCheck/' $d/out; then
+	echo go test -fuzz=FuzzCheck did not record the failing input
+	cat $d/out
+	ok=false
+elif ! grep -q '^\[\]byte("FUZ")$' $d/src/fuzzme/testdata/fuzz/FuzzCheck/*; then
+	echo go test -fuzz=FuzzCheck recorded the wrong input
+	cat $d/src/fuzzme/testdata/fuzz/FuzzCheck/*
+	ok=false
+elif ./testgo test fuzzme >$d/out 2>&1; then
+	echo go test did not replay the recorded failing input
+	cat $d/out
+	ok=false
+fi
+rm -rf $d
+unset GOPATH
+
 TEST cgo depends on syscall
 rm -rf $GOROOT/pkg/*_race
 d=$(TMPDIR=/var/tmp mktemp -d -t testgoXXX)
@@ -966,25 +1058,32 @@ echo '
 package foo
 func F() {}
 ' >$d/src/x/y/foo/foo.go
+# checkbar checks that build -i installs x/y/foo once it is stale, and
+# leaves it alone once it is not. It looks at foo.a rather than at the
+# -v output, since a touched but unchanged foo.go is compiled from the
+# build cache and not listed.
 checkbar() {
 	desc="$1"
+	fooa=$d/pkg/${GOOS}_${GOARCH}/x/y/foo.a
 	sleep 1
 	touch $d/src/x/y/foo/foo.go
 	if ! ./testgo build -v -i x/y/bar &> $d/err; then
 		echo build -i "$1" failed
 		cat $d/err
 		ok=false
-	elif ! grep x/y/foo $d/err >/dev/null; then
-		echo first build -i "$1" did not build x/y/foo
+	elif [ ! $fooa -nt $d/src/x/y/foo/foo.go ]; then
+		echo first build -i "$1" did not install x/y/foo
 		cat $d/err
 		ok=false
 	fi
+	touch -r $fooa $d/foo.stamp
+	sleep 1
 	if ! ./testgo build -v -i x/y/bar &> $d/err; then
 		echo second build -i "$1" failed
 		cat $d/err
 		ok=false
-	elif grep x/y/foo $d/err >/dev/null; then
-		echo second build -i "$1" built x/y/foo
+	elif [ $fooa -nt $d/foo.stamp ]; then
+		echo second build -i "$1" installed x/y/foo
 		cat $d/err
 		ok=false
 	fi
@@ -1109,6 +1208,217 @@ fi
 unset GOPATH
 rm -rf $d
 
+TEST go build and go test use the build cache
+d=$(mktemp -d -t testgoXXX)
+export GOPATH=$(pwd)/testdata
+export GOCACHE=$d/cache
+if ! ./testgo test xtestonly >$d/out 2>&1 || grep -q cached $d/out; then
+	echo "first go test xtestonly did not run the test"
+	cat $d/out
+	ok=false
+elif ! ./testgo test xtestonly >$d/out 2>&1 || ! grep -q 'ok.*xtestonly.*(cached)' $d/out; then
+	echo "second go test xtestonly did not use the cached result"
+	cat $d/out
+	ok=false
+elif ! ./testgo test -v xtestonly >$d/out 2>&1 || grep -q cached $d/out; then
+	echo "go test -v xtestonly used the result cached without -v"
+	cat $d/out
+	ok=false
+elif ! ./testgo build -x xtestonly >$d/out 2>&1 || grep -q '[568]g ' $d/out; then
+	echo "go build xtestonly compiled a package in the build cache"
+	cat $d/out
+	ok=false
+fi
+./testgo clean -testcache
+if ! ./testgo test xtestonly >$d/out 2>&1 || grep -q cached $d/out; then
+	echo "go test xtestonly used a result expired by go clean -testcache"
+	cat $d/out
+	ok=false
+fi
+./testgo clean -cache
+if [ -d $d/cache ]; then
+	echo "go clean -cache did not remove the cache"
+	ok=false
+fi
+unset GOPATH
+unset GOCACHE
+rm -rf $d
+
+TEST go test rechecks the files and environment a cached test consulted
+d=$(mktemp -d -t testgoXXX)
+export GOPATH=$d
+export GOCACHE=$d/cache
+mkdir -p $d/src/readfile
+cat >$d/src/readfile/readfile_test.go <<'EOF'
+package readfile
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	data, err := ioutil.ReadFile(os.Getenv("READFILE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", data)
+}
+EOF
+echo one >$d/input
+touch -t 201501010000 $d/input
+export READFILE=$d/input
+./testgo test readfile >/dev/null 2>&1
+if ! ./testgo test readfile >$d/out 2>&1 || ! grep -q 'ok.*readfile.*(cached)' $d/out; then
+	echo "second go test readfile did not use the cached result"
+	cat $d/out
+	ok=false
+fi
+echo two >$d/input
+touch -t 201501010000 $d/input
+if ! ./testgo test readfile >$d/out 2>&1 || grep -q cached $d/out; then
+	echo "go test readfile used the cached result after its input changed"
+	cat $d/out
+	ok=false
+fi
+echo two >$d/input2
+touch -t 201501010000 $d/input2
+export READFILE=$d/input2
+if ! ./testgo test readfile >$d/out 2>&1 || grep -q cached $d/out; then
+	echo "go test readfile used the cached result after READFILE changed"
+	cat $d/out
+	ok=false
+fi
+unset READFILE
+unset GOPATH
+unset GOCACHE
+rm -rf $d
+
+TEST go build shares cached archives between GOPATH workspaces
+d=$(mktemp -d -t testgoXXX)
+export GOCACHE=$d/cache
+for w in w1 w2; do
+	mkdir -p $d/$w/src/x
+	echo 'package x; func F() int { return 1 }' >$d/$w/src/x/x.go
+done
+if ! GOPATH=$d/w1 ./testgo build -x x >$d/out 2>&1 || ! grep -q '[568]g ' $d/out; then
+	echo "go build x in the first workspace did not compile x"
+	cat $d/out
+	ok=false
+elif ! GOPATH=$d/w2 ./testgo build -x x >$d/out 2>&1 || grep -q '[568]g ' $d/out; then
+	echo "go build x in the second workspace did not use the archive built in the first"
+	cat $d/out
+	ok=false
+fi
+if ! GOPATH=$d/w2 ./testgo install x; then
+	echo "go install x failed"
+	ok=false
+elif grep -a -q "$d/w2" $d/w2/pkg/*/x.a || ! grep -a -q x/x.go $d/w2/pkg/*/x.a; then
+	echo "x.a records x.go by its directory, not as x/x.go"
+	ok=false
+fi
+unset GOCACHE
+rm -rf $d
+
+TEST go build -gcflags with a package pattern and -toolexec
+d=$(mktemp -d -t testgoXXX)
+export GOPATH=$d
+mkdir -p $d/src/x/foo $d/src/x/bar
+echo '
+package foo
+func F() {}
+' >$d/src/x/foo/foo.go
+echo '
+package main
+import "x/foo"
+func main() { foo.F() }
+' >$d/src/x/bar/bar.go
+if ! ./testgo build -n -gcflags=-N -gcflags=x/foo=-m -toolexec='echo wrapped' -o $d/bar x/bar >$d/out 2>&1; then
+	echo go build -n failed
+	cat $d/out
+	ok=false
+elif ! grep -q 'wrapped .*[568]g .* -m .*foo.go' $d/out || grep -q '[568]g .* -N .*foo.go' $d/out; then
+	echo "go build did not compile x/foo with only -m through the -toolexec program"
+	cat $d/out
+	ok=false
+elif ! grep -q 'wrapped .*[568]g .* -N .*bar.go' $d/out || grep -q '[568]g .* -m .*bar.go' $d/out; then
+	echo "go build did not compile x/bar with only -N through the -toolexec program"
+	cat $d/out
+	ok=false
+elif ! grep -q 'wrapped .*[568]l ' $d/out; then
+	echo "go build did not link through the -toolexec program"
+	cat $d/out
+	ok=false
+fi
+unset GOPATH
+rm -rf $d
+
+TEST go build -buildmode=c-archive twice into the same directory
+if [ "$(./testgo env GOOS)/$(./testgo env GOARCH)" = linux/amd64 -a "$(./testgo env CGO_ENABLED)" = 1 ]; then
+	d=$(mktemp -d -t testgoXXX)
+	export GOPATH=$d
+	mkdir -p $d/src/clib
+	echo '
+package main
+import "C"
+//export Add
+func Add(a, b C.int) C.int { return a + b }
+func main() {}
+' >$d/src/clib/clib.go
+	for i in 1 2; do
+		if ! ./testgo build -buildmode=c-archive -o $d/libclib.a clib >$d/out 2>&1; then
+			echo "go build -buildmode=c-archive failed on build $i"
+			cat $d/out
+			ok=false
+		elif ! grep -q 'Add' $d/libclib.h; then
+			echo "go build -buildmode=c-archive did not write libclib.h on build $i"
+			ok=false
+		fi
+	done
+	unset GOPATH
+	rm -rf $d
+fi
+
+TEST go install -buildmode=shared and go build -linkshared
+if [ "$(./testgo env GOOS)/$(./testgo env GOARCH)" = linux/amd64 -a "$(./testgo env CGO_ENABLED)" = 1 ]; then
+	d=$(mktemp -d -t testgoXXX)
+	export GOPATH=$d
+	mkdir -p $d/src/dep $d/src/main
+	echo '
+package dep
+import "strings"
+type Upper string
+func (u Upper) String() string { return strings.ToUpper(string(u)) }
+' >$d/src/dep/dep.go
+	echo '
+package main
+import ("dep"; "fmt")
+func main() { fmt.Println(dep.Upper("shared")) }
+' >$d/src/main/main.go
+	pkgdir=$(./testgo env GOROOT)/pkg/linux_amd64_testshared_dynlink
+	if ! ./testgo install -installsuffix testshared -buildmode=shared dep >$d/out 2>&1; then
+		echo "go install -buildmode=shared dep failed"
+		cat $d/out
+		ok=false
+	elif [ ! -f $d/pkg/linux_amd64_testshared_dynlink/libdep.so ]; then
+		echo "go install -buildmode=shared dep did not write libdep.so"
+		ok=false
+	elif ! grep -q "^$d/pkg/linux_amd64_testshared_dynlink/libdep.so\$" $pkgdir/runtime.shlibname; then
+		echo "go install -buildmode=shared dep did not record libdep.so for runtime"
+		ok=false
+	elif ! ./testgo build -installsuffix testshared -linkshared -o $d/main.exe main >$d/out 2>&1; then
+		echo "go build -linkshared main failed"
+		cat $d/out
+		ok=false
+	elif [ "$($d/main.exe)" != SHARED ]; then
+		echo "program built with -linkshared printed $($d/main.exe), want SHARED"
+		ok=false
+	fi
+	unset GOPATH
+	rm -rf $d $pkgdir
+fi
+
 # clean up
 if $started; then stop; fi
 rm -rf testdata/bin testdata/bin1
@@ -13,6 +13,8 @@ import (
 	"go/doc"
 	"go/parser"
 	"go/token"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
@@ -26,6 +28,8 @@ import (
 	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"cmd/internal/test2json"
 )
 
 // Break init loop.
@@ -64,6 +68,18 @@ with source in the current directory, including tests, and runs the tests.
 The package is built in a temporary directory so it does not interfere with the
 non-test installation.
 
+When go test is given packages on the command line, it caches the
+results of the tests that pass. Running the same test binary again,
+with the same flags, in the same environment, prints the earlier
+output with "(cached)" in place of the elapsed time instead of running
+the tests. Only the test binary flags -cpu, -parallel, -run, -short,
+-timeout and -v allow a result to be cached. The test binary records
+the files and environment variables the tests consult through package
+os, and the result is reused only while they are unchanged. Tests
+whose inputs are read some other way, for instance by a subprocess,
+may need 'go clean -testcache' or GOCACHE=off to run again.
+See 'go help cache' for details.
+
 In addition to the build flags, the flags handled by 'go test' itself are:
 
 	-c
@@ -79,6 +95,10 @@ In addition to the build flags, the flags handled by 'go test' itself are:
 	    Install packages that are dependencies of the test.
 	    Do not run the test.
 
+	-json
+	    Convert test output to JSON suitable for automated processing.
+	    See 'godoc cmd/test2json' for the encoding details.
+
 	-o file
 		Compile the test binary to the named file.
 		The test still runs (unless -c or -i is specified).
@@ -118,6 +138,11 @@ control the execution of any test:
 	    Run benchmarks matching the regular expression.
 	    By default, no benchmarks run. To run all benchmarks,
 	    use '-bench .' or '-bench=.'.
+	    The regular expression is split by unbracketed slash (/)
+	    characters into a sequence of regular expressions, and each
+	    part of a benchmark's identifier must match the corresponding
+	    element in the sequence, if any. Possible parents of matches
+	    are run with b.N=1 to identify sub-benchmarks.
 
 	-benchmem
 	    Print memory allocation statistics for benchmarks.
@@ -174,6 +199,23 @@ control the execution of any test:
 	    Write a CPU profile to the specified file before exiting.
 	    Writes test binary as -c would.
 
+	-fuzz regexp
+	    Run the fuzz target matching the regular expression, generating
+	    new inputs until it fails or -fuzztime passes. The regular
+	    expression must match exactly one fuzz target. The package
+	    under test is always built with coverage analysis, in count
+	    mode unless -covermode says otherwise, so that fuzzing can
+	    tell which inputs reach new code. A failing input is minimized
+	    and written to testdata/fuzz/FuzzXXX.
+
+	-fuzzminimizetime t
+	    Spend at most t minimizing a failing fuzz input.
+	    The default is 60s.
+
+	-fuzztime t
+	    Stop fuzzing after t. By default fuzzing runs until it finds
+	    a failure.
+
 	-memprofile mem.out
 	    Write a memory profile to the file after all tests have passed.
 	    Writes test binary as -c would.
@@ -195,7 +237,14 @@ control the execution of any test:
 
 	-run regexp
 	    Run only those tests and examples matching the regular
-	    expression.
+	    expression. For tests, the regular expression is split by
+	    unbracketed slash (/) characters into a sequence of regular
+	    expressions, and each part of a test's identifier must match
+	    the corresponding element in the sequence, if any. Note that
+	    possible parents of matches are run too, so that -run=X/Y
+	    matches and runs and reports the result of all tests matching
+	    X, even those without sub-tests matching Y, because it must run
+	    them to look for those sub-tests.
 
 	-short
 	    Tell long-running tests to shorten their run time.
@@ -206,6 +255,11 @@ control the execution of any test:
 	-timeout t
 	    If a test runs longer than t, panic.
 
+	-trace trace.out
+	    Write an execution trace to the specified file before exiting.
+	    Writes test binary as -c would. View the trace with
+	    'go tool trace pkg.test trace.out'.
+
 	-v
 	    Verbose output: log all tests as they are run. Also print all
 	    text from Log and Logf calls even if the test succeeds.
@@ -248,6 +302,13 @@ A benchmark function is one named BenchmarkXXX and should have the signature,
 
 	func BenchmarkXXX(b *testing.B) { ... }
 
+A fuzz target is one named FuzzXXX and should have the signature,
+
+	func FuzzXXX(f *testing.F) { ... }
+
+Without the -fuzz flag, a fuzz target runs its fuzz function on the seed
+inputs it adds and on those stored in testdata/fuzz/FuzzXXX, like a test.
+
 An example function is similar to a test function but, instead of using
 *testing.T to report success or failure, prints output to os.Stdout.
 That output is compared against the function's "Output:" comment, which
@@ -283,6 +344,8 @@ var (
 	testCoverMode    string     // -covermode flag
 	testCoverPaths   []string   // -coverpkg flag
 	testCoverPkgs    []*Package // -coverpkg flag
+	testFuzz         bool       // -fuzz flag
+	testJSON         bool       // -json flag
 	testO            string     // -o flag
 	testProfile      bool       // some profiling flag
 	testNeedBinary   bool       // profile needs to keep binary around
@@ -292,15 +355,16 @@ var (
 	testBench        bool
 	testStreamOutput bool // show output as it is generated
 	testShowPass     bool // show passing output
+	testCacheable    bool // successful test results can be cached
 
 	testKillTimeout = 10 * time.Minute
 )
 
 var testMainDeps = map[string]bool{
 	// Dependencies for testmain.
-	"testing": true,
-	"regexp":  true,
-	"os":      true,
+	"testing":                   true,
+	"testing/internal/testdeps": true,
+	"os": true,
 }
 
 func runTest(cmd *Command, args []string) {
@@ -310,6 +374,10 @@ func runTest(cmd *Command, args []string) {
 	findExecCmd() // initialize cached result
 
 	raceInit()
+	buildModeInit()
+	if buildModeExternal() {
+		testMainDeps["runtime/cgo"] = true
+	}
 	pkgs := packagesForBuild(pkgArgs)
 	if len(pkgs) == 0 {
 		fatalf("no packages to test")
@@ -324,6 +392,9 @@ func runTest(cmd *Command, args []string) {
 	if testProfile && len(pkgs) != 1 {
 		fatalf("cannot use test profile flag with multiple packages")
 	}
+	if testFuzz && len(pkgs) != 1 {
+		fatalf("cannot use -fuzz flag with multiple packages")
+	}
 
 	// If a test timeout was given and is parseable, set our kill timeout
 	// to that timeout plus one minute.  This is a backup alarm in case
@@ -336,18 +407,23 @@ func runTest(cmd *Command, args []string) {
 	// show passing test output (after buffering) with -v flag.
 	// must buffer because tests are running in parallel, and
 	// otherwise the output will get mixed.
-	testShowPass = testV
+	testShowPass = testV || testJSON
 
 	// stream test output (no buffering) when no package has
 	// been given on the command line (implicit current directory)
-	// or when benchmarking.
+	// or when benchmarking or fuzzing.
 	// Also stream if we're showing output anyway with a
 	// single package under test.  In that case, streaming the
 	// output produces the same result as not streaming,
 	// just more immediately.
-	testStreamOutput = len(pkgArgs) == 0 || testBench ||
+	testStreamOutput = len(pkgArgs) == 0 || testBench || testFuzz ||
 		(len(pkgs) <= 1 && testShowPass)
 
+	// Cache the results of tests run for the packages named on the
+	// command line, unless they are given flags that make them do
+	// more than pass or fail.
+	testCacheable = len(pkgArgs) > 0 && cacheableTestArgs(testArgs)
+
 	var b builder
 	b.init()
 
@@ -559,11 +635,15 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 	var imports, ximports []*Package
 	var stk importStack
 	stk.push(p.ImportPath + " (test)")
-	for _, path := range p.TestImports {
-		p1 := loadImport(path, p.Dir, &stk, p.build.TestImportPos[path])
+	for i, path := range p.TestImports {
+		p1 := loadImport(path, p.Dir, p, &stk, p.build.TestImportPos[path])
 		if p1.Error != nil {
 			return nil, nil, nil, p1.Error
 		}
+		if !p1.local {
+			// Record the path of a vendored copy.
+			p.TestImports[i] = p1.ImportPath
+		}
 		if contains(p1.Deps, p.ImportPath) {
 			// Same error that loadPackage returns (via reusePackage) in pkg.go.
 			// Can't change that code, because that code is only for loading the
@@ -580,15 +660,18 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 	stk.pop()
 	stk.push(p.ImportPath + "_test")
 	pxtestNeedsPtest := false
-	for _, path := range p.XTestImports {
+	for i, path := range p.XTestImports {
 		if path == p.ImportPath {
 			pxtestNeedsPtest = true
 			continue
 		}
-		p1 := loadImport(path, p.Dir, &stk, p.build.XTestImportPos[path])
+		p1 := loadImport(path, p.Dir, p, &stk, p.build.XTestImportPos[path])
 		if p1.Error != nil {
 			return nil, nil, nil, p1.Error
 		}
+		if !p1.local {
+			p.XTestImports[i] = p1.ImportPath
+		}
 		ximports = append(ximports, p1)
 	}
 	stk.pop()
@@ -707,13 +790,13 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 		omitDWARF:  !testC && !testNeedBinary,
 	}
 
-	// The generated main also imports testing, regexp, and os.
+	// The generated main also imports testing, testing/internal/testdeps, and os.
 	stk.push("testmain")
 	for dep := range testMainDeps {
 		if dep == ptest.ImportPath {
 			pmain.imports = append(pmain.imports, ptest)
 		} else {
-			p1 := loadImport(dep, "", &stk, nil)
+			p1 := loadImport(dep, "", nil, &stk, nil)
 			if p1.Error != nil {
 				return nil, nil, nil, p1.Error
 			}
@@ -974,21 +1057,69 @@ func (b *builder) runTest(a *action) error {
 		}
 	}
 
+	// w receives the test output and the final summary line.
+	// With -json, it converts them to JSON events on their way
+	// to the standard output or a.testOutput.
+	var w io.Writer = a.testOutput
+	var json *test2json.Converter
+	if testJSON {
+		if testStreamOutput {
+			w = os.Stdout
+		}
+		json = test2json.NewConverter(w, a.p.ImportPath, test2json.Timestamp)
+		w = json
+	}
+
 	if a.failed {
 		// We were unable to build the binary.
 		a.failed = false
-		fmt.Fprintf(a.testOutput, "FAIL\t%s [build failed]\n", a.p.ImportPath)
+		fmt.Fprintf(w, "FAIL\t%s [build failed]\n", a.p.ImportPath)
+		if json != nil {
+			json.Exited(errors.New("build failed"))
+			json.Close()
+		}
 		setExitStatus(1)
 		return nil
 	}
 
+	cacheID := b.testActionID(a)
+	var testlogFile string
+	if cacheID != "" {
+		if out, ok := getTestCache(cacheID, a.p.Dir); ok {
+			if testShowPass {
+				w.Write(out)
+			}
+			fmt.Fprintf(w, "ok  \t%s\t(cached)%s\n", a.p.ImportPath, coveragePercentage(out))
+			if json != nil {
+				json.Exited(nil)
+				json.Close()
+			}
+			return nil
+		}
+		// Have the test binary log the files and environment
+		// variables it consults, to check before reusing the result.
+		testlogFile = filepath.Join(filepath.Dir(a.deps[0].target), "testlog.txt")
+		args = append(args, "-test.testlogfile="+testlogFile)
+	}
+
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Dir = a.p.Dir
 	cmd.Env = envForDir(cmd.Dir)
-	var buf bytes.Buffer
+	var buf, record bytes.Buffer
 	if testStreamOutput {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
+		if json != nil {
+			cmd.Stdout = json
+			cmd.Stderr = json
+		}
+		if cacheID != "" {
+			// Keep a copy of the output for the cache.
+			// As with -json, the standard error is merged
+			// into the standard output.
+			cmd.Stdout = io.MultiWriter(cmd.Stdout, &record)
+			cmd.Stderr = cmd.Stdout
+		}
 	} else {
 		cmd.Stdout = &buf
 		cmd.Stderr = &buf
@@ -1051,26 +1182,68 @@ func (b *builder) runTest(a *action) error {
 	}
 	out := buf.Bytes()
 	t := fmt.Sprintf("%.3fs", time.Since(t0).Seconds())
+	if json != nil {
+		defer json.Close()
+		defer json.Exited(err)
+	}
 	if err == nil {
+		if cacheID != "" {
+			if inputs, err := ioutil.ReadFile(testlogFile); err == nil {
+				if testStreamOutput {
+					putTestCache(cacheID, a.p.Dir, inputs, record.Bytes())
+				} else {
+					putTestCache(cacheID, a.p.Dir, inputs, out)
+				}
+			}
+		}
 		if testShowPass {
-			a.testOutput.Write(out)
+			w.Write(out)
 		}
-		fmt.Fprintf(a.testOutput, "ok  \t%s\t%s%s\n", a.p.ImportPath, t, coveragePercentage(out))
+		fmt.Fprintf(w, "ok  \t%s\t%s%s\n", a.p.ImportPath, t, coveragePercentage(out))
 		return nil
 	}
 
 	setExitStatus(1)
 	if len(out) > 0 {
-		a.testOutput.Write(out)
+		w.Write(out)
 		// assume printing the test binary's exit status is superfluous
 	} else {
-		fmt.Fprintf(a.testOutput, "%s\n", err)
+		fmt.Fprintf(w, "%s\n", err)
 	}
-	fmt.Fprintf(a.testOutput, "FAIL\t%s\t%s\n", a.p.ImportPath, t)
+	fmt.Fprintf(w, "FAIL\t%s\t%s\n", a.p.ImportPath, t)
 
 	return nil
 }
 
+// cacheableTestFlags are the test binary flags that do not stop
+// the result of a test run from being cached.
+var cacheableTestFlags = map[string]bool{
+	"cpu":      true,
+	"parallel": true,
+	"run":      true,
+	"short":    true,
+	"timeout":  true,
+	"v":        true,
+}
+
+// cacheableTestArgs reports whether the test binary arguments
+// consist only of cacheable flags.
+func cacheableTestArgs(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-test.") {
+			return false
+		}
+		name := arg[len("-test."):]
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		if !cacheableTestFlags[name] {
+			return false
+		}
+	}
+	return true
+}
+
 // coveragePercentage returns the coverage results (if enabled) for the
 // test. It uncovers the data by scanning the output from the test run.
 func coveragePercentage(out []byte) string {
@@ -1112,6 +1285,13 @@ func (b *builder) printTest(a *action) error {
 
 // notest is the action for testing a package with no test files.
 func (b *builder) notest(a *action) error {
+	if testJSON {
+		json := test2json.NewConverter(os.Stdout, a.p.ImportPath, test2json.Timestamp)
+		fmt.Fprintf(json, "?   \t%s\t[no test files]\n", a.p.ImportPath)
+		json.Exited(nil)
+		json.Close()
+		return nil
+	}
 	fmt.Printf("?   \t%s\t[no test files]\n", a.p.ImportPath)
 	return nil
 }
@@ -1196,6 +1376,7 @@ func writeTestmain(out string, t *testFuncs) error {
 type testFuncs struct {
 	Tests       []testFunc
 	Benchmarks  []testFunc
+	FuzzTargets []testFunc
 	Examples    []testFunc
 	TestMain    *testFunc
 	Package     *Package
@@ -1265,6 +1446,9 @@ func (t *testFuncs) load(filename, pkg string, doImport, seen *bool) error {
 		case isTest(name, "Benchmark"):
 			t.Benchmarks = append(t.Benchmarks, testFunc{pkg, name, ""})
 			*doImport, *seen = true, true
+		case isTest(name, "Fuzz"):
+			t.FuzzTargets = append(t.FuzzTargets, testFunc{pkg, name, ""})
+			*doImport, *seen = true, true
 		}
 	}
 	ex := doc.Examples(f)
@@ -1294,8 +1478,8 @@ import (
 {{if not .TestMain}}
 	"os"
 {{end}}
-	"regexp"
 	"testing"
+	"testing/internal/testdeps"
 
 {{if .ImportTest}}
 	{{if .NeedTest}}_test{{else}}_{{end}} {{.Package.ImportPath | printf "%q"}}
@@ -1320,26 +1504,18 @@ var benchmarks = []testing.InternalBenchmark{
 {{end}}
 }
 
+var fuzzTargets = []testing.InternalFuzzTarget{
+{{range .FuzzTargets}}
+	{"{{.Name}}", {{.Package}}.{{.Name}}},
+{{end}}
+}
+
 var examples = []testing.InternalExample{
 {{range .Examples}}
 	{"{{.Name}}", {{.Package}}.{{.Name}}, {{.Output | printf "%q"}}},
 {{end}}
 }
 
-var matchPat string
-var matchRe *regexp.Regexp
-
-func matchString(pat, str string) (result bool, err error) {
-	if matchRe == nil || matchPat != pat {
-		matchPat = pat
-		matchRe, err = regexp.Compile(matchPat)
-		if err != nil {
-			return
-		}
-	}
-	return matchRe.MatchString(str), nil
-}
-
 {{if .CoverEnabled}}
 
 // Only updated by init functions, so no need for atomicity.
@@ -1388,7 +1564,7 @@ func main() {
 		CoveredPackages: {{printf "%q" .Covered}},
 	})
 {{end}}
-	m := testing.MainStart(matchString, tests, benchmarks, examples)
+	m := testing.MainStart(testdeps.TestDeps{}, tests, benchmarks, fuzzTargets, examples)
 {{with .TestMain}}
 	{{.Package}}.{{.Name}}(m)
 {{else}}
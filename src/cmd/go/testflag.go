@@ -20,6 +20,7 @@ var usageMessage = `Usage of go test:
   -c=false: compile but do not run the test binary
   -file=file_test.go: specify file to use for tests;
       use multiple times for multiple files
+  -json=false: convert test output to JSON
   -p=n: build and test up to n packages in parallel
   -x=false: print command lines as they are executed
 
@@ -33,6 +34,9 @@ var usageMessage = `Usage of go test:
   -coverprofile="": passes -test.coverprofile to test if -cover
   -cpu="": passes -test.cpu to test
   -cpuprofile="": passes -test.cpuprofile to test
+  -fuzz="": passes -test.fuzz to test and enables coverage analysis
+  -fuzzminimizetime=60s: passes -test.fuzzminimizetime to test
+  -fuzztime=0: passes -test.fuzztime to test
   -memprofile="": passes -test.memprofile to test
   -memprofilerate=0: passes -test.memprofilerate to test
   -blockprofile="": pases -test.blockprofile to test
@@ -42,6 +46,7 @@ var usageMessage = `Usage of go test:
   -run="": passes -test.run to test
   -short=false: passes -test.short to test
   -timeout=0: passes -test.timeout to test
+  -trace="": passes -test.trace to test
   -v=false: passes -test.v to test
 `
 
@@ -67,6 +72,7 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "c", boolVar: &testC},
 	{name: "cover", boolVar: &testCover},
 	{name: "coverpkg"},
+	{name: "json", boolVar: &testJSON},
 	{name: "o"},
 
 	// build flags.
@@ -76,15 +82,19 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "x", boolVar: &buildX},
 	{name: "i", boolVar: &buildI},
 	{name: "work", boolVar: &buildWork},
+	{name: "asmflags", multiOK: true},
 	{name: "ccflags"},
-	{name: "gcflags"},
+	{name: "gcflags", multiOK: true},
 	{name: "exec"},
-	{name: "ldflags"},
+	{name: "ldflags", multiOK: true},
 	{name: "gccgoflags"},
 	{name: "tags"},
 	{name: "compiler"},
 	{name: "race", boolVar: &buildRace},
 	{name: "installsuffix"},
+	{name: "buildmode"},
+	{name: "linkshared", boolVar: &buildLinkshared},
+	{name: "toolexec"},
 
 	// passed to 6.out, adding a "test." prefix to the name if necessary: -v becomes -test.v.
 	{name: "bench", passToTest: true},
@@ -94,6 +104,9 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "coverprofile", passToTest: true},
 	{name: "cpu", passToTest: true},
 	{name: "cpuprofile", passToTest: true},
+	{name: "fuzz", passToTest: true},
+	{name: "fuzzminimizetime", passToTest: true},
+	{name: "fuzztime", passToTest: true},
 	{name: "memprofile", passToTest: true},
 	{name: "memprofilerate", passToTest: true},
 	{name: "blockprofile", passToTest: true},
@@ -103,6 +116,7 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "run", passToTest: true},
 	{name: "short", boolVar: new(bool), passToTest: true},
 	{name: "timeout", passToTest: true},
+	{name: "trace", passToTest: true},
 	{name: "v", boolVar: &testV, passToTest: true},
 }
 
@@ -151,7 +165,7 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		var err error
 		switch f.name {
 		// bool flags.
-		case "a", "c", "i", "n", "x", "v", "race", "cover", "work":
+		case "a", "c", "i", "n", "x", "v", "race", "linkshared", "cover", "work", "json":
 			setBoolFlag(f.boolVar, value)
 		case "o":
 			testO = value
@@ -168,14 +182,16 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 			if err != nil {
 				fatalf("invalid flag argument for -%s: %v", f.name, err)
 			}
+		case "asmflags":
+			if err := buildAsmflags.Set(value); err != nil {
+				fatalf("invalid flag argument for -%s: %v", f.name, err)
+			}
 		case "gcflags":
-			buildGcflags, err = splitQuotedFields(value)
-			if err != nil {
+			if err := buildGcflags.Set(value); err != nil {
 				fatalf("invalid flag argument for -%s: %v", f.name, err)
 			}
 		case "ldflags":
-			buildLdflags, err = splitQuotedFields(value)
-			if err != nil {
+			if err := buildLdflags.Set(value); err != nil {
 				fatalf("invalid flag argument for -%s: %v", f.name, err)
 			}
 		case "gccgoflags":
@@ -183,16 +199,23 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 			if err != nil {
 				fatalf("invalid flag argument for -%s: %v", f.name, err)
 			}
+		case "toolexec":
+			buildToolExec, err = splitQuotedFields(value)
+			if err != nil {
+				fatalf("invalid flag argument for -%s: %v", f.name, err)
+			}
 		case "tags":
 			buildContext.BuildTags = strings.Fields(value)
 		case "compiler":
 			buildCompiler{}.Set(value)
+		case "buildmode":
+			buildBuildmode = value
 		case "bench":
 			// record that we saw the flag; don't care about the value
 			testBench = true
 		case "timeout":
 			testTimeout = value
-		case "blockprofile", "cpuprofile", "memprofile":
+		case "blockprofile", "cpuprofile", "memprofile", "trace":
 			testProfile = true
 			testNeedBinary = true
 		case "coverpkg":
@@ -213,6 +236,8 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 				fatalf("invalid flag argument for -cover: %q", value)
 			}
 			testCover = true
+		case "fuzz":
+			testFuzz = value != ""
 		case "outputdir":
 			outputDir = value
 		}
@@ -224,8 +249,19 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		}
 	}
 
+	// Fuzzing is guided by the coverage counters of the package under test,
+	// so it is always built with coverage analysis, which needs the cover
+	// tool just as -cover does.
+	if testFuzz {
+		testCover = true
+	}
+
 	if testCoverMode == "" {
 		testCoverMode = "set"
+		if testFuzz {
+			// Fuzzing tells inputs apart by how often they run each block.
+			testCoverMode = "count"
+		}
 		if buildRace {
 			// Default coverage mode is atomic when -race is set.
 			testCoverMode = "atomic"
@@ -240,6 +276,12 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		}
 		passToTest = append(passToTest, "-test.outputdir", dir)
 	}
+
+	// The JSON converter needs the verbose output to see
+	// every test start and result.
+	if testJSON && !testV {
+		passToTest = append(passToTest, "-test.v=true")
+	}
 	return
 }
 
@@ -0,0 +1,309 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package test2json implements conversion of test binary output to JSON.
+// It is used by cmd/test2json and cmd/go.
+//
+// See the cmd/test2json documentation for details of the JSON encoding.
+package test2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode controls details of the conversion.
+type Mode int
+
+const (
+	Timestamp Mode = 1 << iota // include Time in events
+)
+
+// event is the JSON struct we emit.
+type event struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  string   `json:",omitempty"`
+}
+
+// A Converter holds the state of a test-to-JSON conversion.
+// It implements io.WriteCloser; the caller writes test output in,
+// and the converter writes JSON output to w.
+type Converter struct {
+	w        io.Writer // JSON output stream
+	pkg      string    // package to name in events
+	mode     Mode      // mode bits
+	start    time.Time // time converter started
+	testName string    // name of current test, for output attribution
+	report   []*event  // pending test result reports (nested for subtests)
+	result   string    // overall test result if seen
+	partial  []byte    // unterminated input line
+	err      error     // first error writing to w
+}
+
+// maxLine is the longest input line held back waiting for a newline.
+// Longer lines are delivered in pieces as output events.
+const maxLine = 4096
+
+var (
+	// printed by test on successful run.
+	bigPass = []byte("PASS\n")
+
+	// printed by test after a normal test failure.
+	bigFail = []byte("FAIL\n")
+
+	// printed by 'go test' along with an error if the test binary
+	// terminates with an error.
+	bigFailErrorPrefix = []byte("FAIL\t")
+
+	// printed by 'go test' for a package without test files.
+	skipLinePrefix = []byte("?   \t")
+	skipLineSuffix = []byte("\t[no test files]\n")
+
+	updates = [][]byte{
+		[]byte("=== RUN "),
+	}
+
+	reports = [][]byte{
+		[]byte("--- PASS: "),
+		[]byte("--- FAIL: "),
+		[]byte("--- SKIP: "),
+		[]byte("--- BENCH: "),
+	}
+
+	fourSpace = []byte("    ")
+)
+
+// NewConverter returns a "test to json" converter.
+// Writes on the returned writer are written as JSON to w,
+// with minimal delay.
+//
+// The writes to w are whole JSON events ending in \n,
+// so that it is safe to run multiple tests writing to multiple converters
+// writing to a single underlying output stream w.
+// As long as the underlying output w can handle concurrent writes
+// from multiple goroutines, the result will be a JSON stream
+// describing the relative ordering of execution in all the concurrent tests.
+//
+// The mode flag adjusts the behavior of the converter.
+// Passing Timestamp includes event timestamps and the elapsed time
+// of the whole run.
+//
+// The pkg string, if present, specifies the import path to
+// report in the JSON stream.
+func NewConverter(w io.Writer, pkg string, mode Mode) *Converter {
+	return &Converter{
+		w:     w,
+		pkg:   pkg,
+		mode:  mode,
+		start: time.Now(),
+	}
+}
+
+// Write writes the test input to the converter.
+func (c *Converter) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			c.partial = append(c.partial, b...)
+			if len(c.partial) >= maxLine {
+				c.flushPartial()
+			}
+			break
+		}
+		line := b[:i+1]
+		b = b[i+1:]
+		if len(c.partial) > 0 {
+			line = append(c.partial, line...)
+			c.partial = nil
+		}
+		c.handleInputLine(line)
+	}
+	return n, c.err
+}
+
+// Exited marks the test process as having exited with the given error.
+func (c *Converter) Exited(err error) {
+	if err == nil {
+		if c.result != "skip" {
+			c.result = "pass"
+		}
+	} else {
+		c.result = "fail"
+	}
+}
+
+// Close marks the end of the go test output.
+// It flushes any pending input and then output (only partial lines at this point)
+// and then emits the final overall package-level pass/fail event.
+func (c *Converter) Close() error {
+	c.flushPartial()
+	c.flushReport(0)
+	if c.result != "" {
+		e := &event{Action: c.result}
+		if c.mode&Timestamp != 0 {
+			dt := time.Since(c.start).Seconds()
+			e.Elapsed = &dt
+		}
+		c.writeEvent(e)
+	}
+	return c.err
+}
+
+// handleInputLine handles a single whole test output line.
+// It must write the line to c.w as output events,
+// but it can choose to emit other events first.
+func (c *Converter) handleInputLine(line []byte) {
+	// Final PASS or FAIL.
+	if bytes.Equal(line, bigPass) || bytes.Equal(line, bigFail) || bytes.HasPrefix(line, bigFailErrorPrefix) {
+		c.flushReport(0)
+		c.writeOutput(line)
+		if bytes.Equal(line, bigPass) {
+			c.result = "pass"
+		} else {
+			c.result = "fail"
+		}
+		return
+	}
+
+	// Special case for a package without tests, reported by go test
+	// as its only line. Report it as plain output but remember to
+	// say skip in the final summary.
+	if bytes.HasPrefix(line, skipLinePrefix) && bytes.HasSuffix(line, skipLineSuffix) && len(c.report) == 0 {
+		c.result = "skip"
+	}
+
+	// "=== RUN "
+	actionColon := false
+	origLine := line
+	ok := false
+	indent := 0
+	for _, magic := range updates {
+		if bytes.HasPrefix(line, magic) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		// "--- PASS: "
+		// "--- FAIL: "
+		// "--- SKIP: "
+		// "--- BENCH: "
+		// but possibly indented.
+		for bytes.HasPrefix(line, fourSpace) {
+			line = line[4:]
+			indent++
+		}
+		for _, magic := range reports {
+			if bytes.HasPrefix(line, magic) {
+				actionColon = true
+				ok = true
+				break
+			}
+		}
+	}
+
+	if !ok {
+		// Not a special test output line.
+		c.writeOutput(origLine)
+		return
+	}
+
+	// Parse out action and test name.
+	i := len(updates[0])
+	if actionColon {
+		i = bytes.IndexByte(line, ':') + 1
+	}
+	action := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(string(line[4:i])), ":"))
+	name := strings.TrimSpace(string(line[i:]))
+
+	e := &event{Action: action}
+	if line[0] == '-' { // PASS, FAIL, SKIP or BENCH report
+		// Parse out elapsed time.
+		if i := strings.Index(name, " ("); i >= 0 {
+			if strings.HasSuffix(name, "s)") {
+				t, err := strconv.ParseFloat(name[i+2:len(name)-2], 64)
+				if err == nil {
+					e.Elapsed = &t
+				}
+			}
+			name = name[:i]
+		}
+		if len(c.report) < indent {
+			// Nested deeper than expected.
+			// Treat this line as plain output.
+			c.writeOutput(origLine)
+			return
+		}
+		// Flush reports at this indentation level or deeper.
+		// The new report is held back until the output that
+		// follows it, which belongs to the same test, has been
+		// delivered.
+		c.flushReport(indent)
+		e.Test = name
+		c.testName = name
+		c.report = append(c.report, e)
+		c.writeOutput(origLine)
+		return
+	}
+
+	// === update.
+	// Finish any pending PASS/FAIL reports.
+	c.flushReport(0)
+	c.testName = name
+	e.Test = name
+	c.writeEvent(e)
+	c.writeOutput(origLine)
+}
+
+// flushReport flushes all pending PASS/FAIL reports at levels >= depth.
+func (c *Converter) flushReport(depth int) {
+	c.testName = ""
+	for len(c.report) > depth {
+		e := c.report[len(c.report)-1]
+		c.report = c.report[:len(c.report)-1]
+		c.writeEvent(e)
+	}
+}
+
+// flushPartial delivers any unterminated input line as output.
+func (c *Converter) flushPartial() {
+	if len(c.partial) > 0 {
+		c.writeOutput(c.partial)
+		c.partial = nil
+	}
+}
+
+// writeOutput writes out as an output event for the current test.
+func (c *Converter) writeOutput(out []byte) {
+	c.writeEvent(&event{Action: "output", Test: c.testName, Output: string(out)})
+}
+
+// writeEvent writes an event to c.w, filling in the package and time.
+func (c *Converter) writeEvent(e *event) {
+	e.Package = c.pkg
+	if c.mode&Timestamp != 0 {
+		t := time.Now()
+		e.Time = &t
+	}
+	js, err := json.Marshal(e)
+	if err != nil {
+		// Should not happen - event is valid for json.Marshal.
+		c.w.Write([]byte(fmt.Sprintf("testjson internal error: %v\n", err)))
+		return
+	}
+	js = append(js, '\n')
+	if _, err := c.w.Write(js); err != nil && c.err == nil {
+		c.err = err
+	}
+}
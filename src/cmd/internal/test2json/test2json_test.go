@@ -0,0 +1,172 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.json files")
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata/*.test files")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".test")
+		orig, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		// Test one line written to c at a time.
+		// Assume that's the most likely to be handled correctly.
+		var buf bytes.Buffer
+		c := NewConverter(&buf, "", 0)
+		in := append([]byte{}, orig...)
+		for _, line := range bytes.SplitAfter(in, []byte("\n")) {
+			writeAndKill(c, line)
+		}
+		c.Close()
+
+		if *update {
+			js := strings.TrimSuffix(file, ".test") + ".json"
+			t.Logf("rewriting %s", js)
+			if err := ioutil.WriteFile(js, buf.Bytes(), 0666); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(strings.TrimSuffix(file, ".test") + ".json")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		diffJSON(t, name+" by line", buf.Bytes(), want)
+		if t.Failed() {
+			// If the line-at-a-time conversion fails, no point testing boundary conditions.
+			continue
+		}
+
+		// Write entire input in bulk.
+		buf.Reset()
+		c = NewConverter(&buf, "", 0)
+		in = append([]byte{}, orig...)
+		writeAndKill(c, in)
+		c.Close()
+		diffJSON(t, name+" in bulk", buf.Bytes(), want)
+
+		// Write entire input byte-by-byte.
+		buf.Reset()
+		c = NewConverter(&buf, "", 0)
+		in = append([]byte{}, orig...)
+		for i := range in {
+			writeAndKill(c, in[i:i+1])
+		}
+		c.Close()
+		diffJSON(t, name+" by byte", buf.Bytes(), want)
+	}
+}
+
+// writeAndKill writes b to w and then fills b with Zs.
+// The filling makes sure that if w is holding onto b for
+// future use, that future use will have obviously wrong data.
+func writeAndKill(w *Converter, b []byte) {
+	w.Write(b)
+	for i := range b {
+		b[i] = 'Z'
+	}
+}
+
+// diffJSON diffs the stream we have against the stream we want
+// and fails the test with a useful message if they don't match.
+func diffJSON(t *testing.T, name string, have, want []byte) {
+	h := bytes.SplitAfter(have, []byte("\n"))
+	w := bytes.SplitAfter(want, []byte("\n"))
+	for i := 0; i < len(h) || i < len(w); i++ {
+		var hl, wl []byte
+		if i < len(h) {
+			hl = h[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if !bytes.Equal(hl, wl) {
+			t.Errorf("%s: line %d:\nhave %s\nwant %s", name, i+1, hl, wl)
+			return
+		}
+	}
+}
+
+func TestExited(t *testing.T) {
+	tests := []struct {
+		in   string
+		err  error
+		want string
+	}{
+		{"PASS\n", nil, "pass"},
+		{"FAIL\n", errors.New("exit status 1"), "fail"},
+		{"ok  \tx\t0.001s\n", nil, "pass"},
+		{"signal: killed\n", errors.New("signal: killed"), "fail"},
+		{"?   \tx\t[no test files]\n", nil, "skip"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		c := NewConverter(&buf, "x", 0)
+		c.Write([]byte(tt.in))
+		c.Exited(tt.err)
+		c.Close()
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		var e event
+		if err := json.Unmarshal(lines[len(lines)-1], &e); err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		want := event{Action: tt.want, Package: "x"}
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("%q: final event = %+v, want %+v", tt.in, e, want)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewConverter(&buf, "x", Timestamp)
+	c.Write([]byte("=== RUN TestA\n--- PASS: TestA (1.50s)\nPASS\n"))
+	c.Exited(nil)
+	c.Close()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	for _, line := range lines {
+		var e event
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if e.Time == nil {
+			t.Errorf("%s: missing Time", line)
+		}
+		switch e.Action {
+		case "pass":
+			if e.Elapsed == nil {
+				t.Errorf("%s: missing Elapsed", line)
+			} else if e.Test == "TestA" && *e.Elapsed != 1.5 {
+				t.Errorf("%s: Elapsed = %v, want 1.5", line, *e.Elapsed)
+			}
+		}
+	}
+}
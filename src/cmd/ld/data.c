@@ -154,8 +154,9 @@ relocsym(LSym *s)
 		if(r->siz == 0) // informational relocation - no work to do
 			continue;
 
-		// Solaris needs the ability to reference dynimport symbols.
-		if(HEADTYPE != Hsolaris && r->sym != S && r->sym->type == SDYNIMPORT)
+		// Solaris needs the ability to reference dynimport symbols,
+		// and so does Go code using Go shared libraries.
+		if(HEADTYPE != Hsolaris && !dynlinking() && r->sym != S && r->sym->type == SDYNIMPORT)
 			diag("unhandled relocation for %s (type %d rtype %d)", r->sym->name, r->sym->type, r->type);
 		if(r->sym != S && r->sym->type != STLSBSS && !r->sym->reachable)
 			diag("unreachable sym in relocation: %s %s", s->name, r->sym->name);
@@ -164,6 +165,15 @@ relocsym(LSym *s)
 		if (r->type == R_TLS && strcmp(goos, "android") == 0)
 			r->type = R_ADDR;
 
+		// A GOT load of a symbol defined in this module,
+		//	MOVQ sym@GOT(SB), reg
+		// becomes a direct reference:
+		//	LEAQ sym(SB), reg
+		if(r->type == R_GOTPCREL && thechar == '6' && r->sym->type != SDYNIMPORT && off >= 2 && s->p[off-2] == 0x8b) {
+			s->p[off-2] = 0x8d;
+			r->type = R_PCREL;
+		}
+
 		switch(r->type) {
 		default:
 			o = 0;
@@ -307,6 +317,13 @@ relocsym(LSym *s)
 		case R_SIZE:
 			o = r->sym->size + r->add;
 			break;
+		case R_GOTPCREL:
+			// Left to the external linker, which builds the GOT.
+			r->done = 0;
+			r->xsym = r->sym;
+			r->xadd = r->add - r->siz;
+			o = 0;
+			break;
 		}
 //print("relocate %s %#llux (%#llux+%#llux, size %d) => %s %#llux +%#llx [%llx]\n", s->name, (uvlong)(s->value+off), (uvlong)s->value, (uvlong)r->off, r->siz, r->sym ? r->sym->name : "<nil>", (uvlong)symaddr(r->sym), (vlong)r->add, (vlong)o);
 		switch(siz) {
@@ -416,6 +433,10 @@ dynreloc(void)
 	// compute these sections or mark their symbols as reachable.
 	if(debug['d'] && HEADTYPE != Hwindows)
 		return;
+	// References to the symbols of Go shared libraries
+	// are left to the external linker.
+	if(dynlinking())
+		return;
 	if(debug['v'])
 		Bprint(&bso, "%5.2f reloc\n", cputime());
 	Bflush(&bso);
@@ -1012,7 +1033,7 @@ dodata(void)
 	sect->len = datsize - sect->vaddr;
 
 	/* shared library initializer */
-	if(flag_shared) {
+	if(buildmode == BuildmodeCArchive || buildmode == BuildmodeCShared || flag_linkshared) {
 		sect = addsection(&segdata, ".init_array", 06);
 		sect->align = maxalign(s, SINITARR);
 		datsize = rnd(datsize, sect->align);
@@ -22,6 +22,7 @@ Original options are listed on the manual page linked above.
 
 Usage:
 	go tool 6l [flags] mainObj
+	go tool 6l -buildmode shared [flags] importpath=file...
 Substitute 6l with 5l, 8l or 9l as appropriate.
 
 Options new in this version:
@@ -84,6 +85,28 @@ Options new in this version:
 		internal, external, or auto.  The default is auto.
 		This sets the linking mode as described in
 		../cgo/doc.go.
+	-buildmode mode
+		Set the build mode.  The mode must be one of exe,
+		c-archive, c-shared, pie or shared.  The default is exe.
+		The c-archive and c-shared modes write a C archive
+		or a shared library whose initializer starts the
+		Go runtime; pie writes a position independent
+		executable.  The shared mode writes a Go shared
+		library for programs linked with -linkshared; it
+		takes the packages to put in the library as
+		importpath=file arguments instead of mainObj.
+		All but exe imply -linkmode external and need
+		packages compiled with -shared, and are only
+		supported on linux/amd64.
+	-linkshared
+		Link against the Go shared libraries that hold the
+		imported packages.  An installed package pkg.a that
+		is part of a shared library has a pkg.shlibname file
+		next to it holding the library's path.  Packages
+		must be compiled with -dynlink.  Implies -linkmode
+		external and -w; only supported on linux/amd64.
+	-shared
+		Same as -buildmode c-shared.
 	-tmpdir dir
 		Set the location to use for any temporary files.  The
 		default is a newly created directory that is removed
@@ -781,6 +781,8 @@ elfshbits(Section *sect)
 			sh->flags |= SHF_TLS; // no TLS on android
 		sh->type = SHT_NOBITS;
 	}
+	if(strcmp(sect->name, ".init_array") == 0)
+		sh->type = SHT_INIT_ARRAY;
 	if(linkmode != LinkExternal)
 		sh->addr = sect->vaddr;
 	sh->addralign = sect->align;
@@ -953,7 +955,7 @@ doelf(void)
 		addstring(shstrtab, ".note.GNU-stack");
 	}
 
-	if(flag_shared) {
+	if(buildmode == BuildmodeCArchive || buildmode == BuildmodeCShared || flag_linkshared) {
 		addstring(shstrtab, ".init_array");
 		if(thechar == '6' || thechar == '9')
 			addstring(shstrtab, ".rela.init_array");
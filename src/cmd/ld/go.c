@@ -448,7 +448,7 @@ loadcgo(char *file, char *pkg, char *p, int n)
 			local = expandpkg(local, pkg);
 			s = linklookup(ctxt, local, 0);
 
-			if(flag_shared && s == linklookup(ctxt, "main", 0))
+			if((buildmode == BuildmodeCArchive || buildmode == BuildmodeCShared) && s == linklookup(ctxt, "main", 0))
 				continue;
 
 			// export overrides import, for openbsd/cgo.
@@ -545,6 +545,10 @@ markflood(void)
 	int i;
 	
 	for(s=markq; s!=S; s=s->queue) {
+		// A symbol from a Go shared library brings
+		// nothing else into the program.
+		if(s->type == SDYNIMPORT && flag_linkshared)
+			continue;
 		if(s->type == STEXT) {
 			if(debug['v'] > 1)
 				Bprint(&bso, "marktext %s\n", s->name);
@@ -598,7 +602,25 @@ deadcode(void)
 	if(debug['v'])
 		Bprint(&bso, "%5.2f deadcode\n", cputime());
 
-	mark(linklookup(ctxt, INITENTRY, 0));
+	if(buildmode == BuildmodeShared) {
+		// A shared library keeps everything it defines:
+		// any of it may be used by the programs linked against it.
+		for(s = ctxt->allsym; s != S; s = s->allsym)
+			if(s->type != 0 && s->type != SXREF && s->type != SDYNIMPORT && strcmp(s->name, ".dup") != 0)
+				mark(s);
+	} else if(flag_linkshared) {
+		// The runtime is in a shared library, which calls
+		// main.init and main.main; the program's own module
+		// data is added to the list by its initializer.
+		mark(linklookup(ctxt, "main.init", 0));
+		mark(linklookup(ctxt, "main.main", 0));
+		mark(linklookup(ctxt, "go.link.addmoduledata", 0));
+		mark(linklookup(ctxt, "go.link.addmoduledatainit", 0));
+	} else {
+		mark(linklookup(ctxt, INITENTRY, 0));
+		if(buildmode == BuildmodeCArchive || buildmode == BuildmodeCShared)
+			mark(linklookup(ctxt, smprint("%s.ptr", INITENTRY), 0));
+	}
 	for(i=0; i<nelem(markextra); i++)
 		mark(linklookup(ctxt, markextra[i], 0));
 
@@ -859,3 +881,30 @@ setlinkmode(char *arg)
 		errorexit();
 	}
 }
+
+void
+setbuildmode(char *arg)
+{
+	if(strcmp(arg, "exe") == 0)
+		buildmode = BuildmodeExe;
+	else if(strcmp(arg, "c-archive") == 0)
+		buildmode = BuildmodeCArchive;
+	else if(strcmp(arg, "c-shared") == 0)
+		buildmode = BuildmodeCShared;
+	else if(strcmp(arg, "pie") == 0)
+		buildmode = BuildmodePIE;
+	else if(strcmp(arg, "shared") == 0)
+		buildmode = BuildmodeShared;
+	else {
+		fprint(2, "unknown build mode -buildmode %s\n", arg);
+		errorexit();
+	}
+}
+
+// dynlinking reports whether the Go code being linked can refer to
+// Go symbols in another module, or be referred to from one.
+int
+dynlinking(void)
+{
+	return buildmode == BuildmodeShared || flag_linkshared;
+}
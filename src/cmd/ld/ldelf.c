@@ -906,3 +906,260 @@ reltype(char *pn, int elftype, uchar *siz)
 
 	return 256+elftype;
 }
+
+typedef struct ShlibSym ShlibSym;
+struct ShlibSym
+{
+	uint64	addr;
+	int	i;
+};
+
+static int
+shlibsymcmp(const void *va, const void *vb)
+{
+	ShlibSym *a, *b;
+
+	a = (ShlibSym*)va;
+	b = (ShlibSym*)vb;
+	if(a->addr < b->addr)
+		return -1;
+	if(a->addr > b->addr)
+		return +1;
+	return 0;
+}
+
+// shlibsymat returns the index in byaddr of the symbol
+// with the highest address not above addr, or -1.
+static int
+shlibsymat(ShlibSym *byaddr, int n, uint64 addr)
+{
+	int lo, hi, m;
+
+	lo = 0;
+	hi = n;
+	while(lo < hi) {
+		m = (lo+hi)/2;
+		if(byaddr[m].addr <= addr)
+			lo = m+1;
+		else
+			hi = m;
+	}
+	return lo-1;
+}
+
+// ldshlibsyms reads the dynamic symbols of the Go shared library
+// path, which the program is being linked against (-linkshared).
+// Every symbol the library defines becomes a dynamic import,
+// replacing any copy of it in the object files, which have all
+// been loaded by now. The linker still decodes type symbols to
+// lay out the garbage collection data of the program's own
+// variables, so a type keeps its contents: the copy from the
+// object files if there is one, or else the bytes and
+// relocations of the type in the library.
+void
+ldshlibsyms(char *path)
+{
+	Biobuf *f;
+	ElfObj *obj;
+	ElfSect *sect, *dynsym, *dynstr, *rela;
+	ElfHdrBytes64 *hdr;
+	ElfSymBytes64 *b;
+	ShlibSym *byaddr;
+	LSym *s, *t, *last, **syms;
+	Reloc *r;
+	Endian *e;
+	uchar hdrbuf[64], *p, *needrel;
+	uint64 *addr, value, off, info, add;
+	char *name;
+	int i, j, k, n, nbyaddr, shndx;
+
+	if(debug['v'])
+		Bprint(&bso, "%5.2f ldshlibsyms %s\n", cputime(), path);
+
+	f = Bopen(path, OREAD);
+	if(f == nil) {
+		diag("cannot open shared library %s: %r", path);
+		return;
+	}
+	e = &le;
+	if(Bread(f, hdrbuf, sizeof hdrbuf) != sizeof hdrbuf)
+		goto bad;
+	hdr = (ElfHdrBytes64*)hdrbuf;
+	if(memcmp(hdr->ident, ElfMagic, 4) != 0 || hdr->ident[4] != ElfClass64 || hdr->ident[5] != ElfDataLsb ||
+	   e->e16(hdr->type) != ElfTypeSharedObject || e->e16(hdr->machine) != ElfMachAmd64) {
+		diag("%s: not an amd64 ELF shared library", path);
+		Bterm(f);
+		return;
+	}
+
+	obj = mal(sizeof *obj);
+	obj->e = e;
+	obj->f = f;
+	obj->base = 0;
+	obj->len = Bseek(f, 0, 2);
+	obj->name = path;
+	obj->is64 = 1;
+	obj->shoff = e->e64(hdr->shoff);
+	obj->shentsize = e->e16(hdr->shentsize);
+	obj->shnum = e->e16(hdr->shnum);
+	obj->shstrndx = e->e16(hdr->shstrndx);
+
+	// load section list into memory.
+	obj->sect = mal(obj->shnum*sizeof obj->sect[0]);
+	obj->nsect = obj->shnum;
+	for(i=0; i<obj->nsect; i++) {
+		ElfSectBytes64 sb;
+
+		if(Bseek(f, obj->shoff+i*obj->shentsize, 0) < 0)
+			goto bad;
+		werrstr("short read");
+		if(Bread(f, &sb, sizeof sb) != sizeof sb)
+			goto bad;
+		sect = &obj->sect[i];
+		sect->name = (char*)(uintptr)e->e32(sb.name);
+		sect->type = e->e32(sb.type);
+		sect->flags = e->e64(sb.flags);
+		sect->addr = e->e64(sb.addr);
+		sect->off = e->e64(sb.off);
+		sect->size = e->e64(sb.size);
+		sect->link = e->e32(sb.link);
+		sect->info = e->e32(sb.info);
+		sect->align = e->e64(sb.align);
+		sect->entsize = e->e64(sb.entsize);
+	}
+	if(obj->shstrndx >= obj->nsect) {
+		werrstr("shstrndx out of range %d >= %d", obj->shstrndx, obj->nsect);
+		goto bad;
+	}
+	sect = &obj->sect[obj->shstrndx];
+	if(map(obj, sect) < 0)
+		goto bad;
+	for(i=0; i<obj->nsect; i++)
+		obj->sect[i].name = (char*)sect->base + (uintptr)obj->sect[i].name;
+
+	dynsym = section(obj, ".dynsym");
+	if(dynsym == nil || dynsym->link <= 0 || dynsym->link >= obj->nsect) {
+		diag("%s: shared library has no dynamic symbol table", path);
+		Bterm(f);
+		return;
+	}
+	dynstr = &obj->sect[dynsym->link];
+	if(map(obj, dynsym) < 0 || map(obj, dynstr) < 0)
+		goto bad;
+
+	n = dynsym->size / sizeof(ElfSymBytes64);
+	syms = emallocz(n*sizeof syms[0]);
+	addr = emallocz(n*sizeof addr[0]);
+	needrel = emallocz(n);
+	byaddr = emallocz(n*sizeof byaddr[0]);
+	nbyaddr = 0;
+	for(i=1; i<n; i++) {
+		b = (ElfSymBytes64*)(dynsym->base + i*sizeof *b);
+		shndx = e->e16(b->shndx);
+		if((b->info>>4) != ElfSymBindGlobal || shndx == ElfSymShnNone || shndx >= obj->nsect)
+			continue;
+		name = (char*)dynstr->base + e->e32(b->name);
+		value = e->e64(b->value);
+		s = linklookup(ctxt, name, 0);
+		if(s->type != 0 && s->type != SXREF && s->type != SDYNIMPORT &&
+		   s->type != SBSS && s->type != SNOPTRBSS && !s->dupok) {
+			diag("%s: duplicate symbol %s: defined both in the library and in an object file", path, name);
+			continue;
+		}
+		s->type = SDYNIMPORT;
+		s->extname = s->name;
+		s->size = e->e64(b->size);
+		s->elftype = b->info&0xf;
+		syms[i] = s;
+		addr[i] = value;
+		byaddr[nbyaddr].addr = value;
+		byaddr[nbyaddr].i = i;
+		nbyaddr++;
+
+		if(strncmp(name, "type.", 5) != 0 && strncmp(name, "runtime.gcbits.", 15) != 0)
+			continue;
+		if(s->np > 0)
+			continue;
+		sect = &obj->sect[shndx];
+		if(sect->type == ElfSectNobits || value < sect->addr || value+s->size > sect->addr+sect->size)
+			continue;
+		symgrow(ctxt, s, s->size);
+		werrstr("short read");
+		if(Bseek(f, sect->off + value - sect->addr, 0) < 0 || Bread(f, s->p, s->np) != s->np)
+			goto bad;
+		needrel[i] = 1;
+	}
+	qsort(byaddr, nbyaddr, sizeof byaddr[0], shlibsymcmp);
+
+	// Rebuild the relocations of the types read from the
+	// library, from its dynamic relocations.
+	rela = section(obj, ".rela.dyn");
+	if(rela != nil) {
+		if(map(obj, rela) < 0)
+			goto bad;
+		for(p = rela->base; p+24 <= rela->base+rela->size; p += 24) {
+			off = e->e64(p);
+			info = e->e64(p+8);
+			add = e->e64(p+16);
+			k = shlibsymat(byaddr, nbyaddr, off);
+			if(k < 0)
+				continue;
+			i = byaddr[k].i;
+			if(!needrel[i] || off+8 > addr[i]+syms[i]->size)
+				continue;
+			switch(ELF64_R_TYPE(info)) {
+			default:
+				continue;
+			case R_X86_64_RELATIVE:
+				k = shlibsymat(byaddr, nbyaddr, add);
+				if(k < 0)
+					continue;
+				j = byaddr[k].i;
+				if(add >= addr[j]+syms[j]->size)
+					continue;
+				add -= addr[j];
+				break;
+			case R_X86_64_64:
+				j = ELF64_R_SYM(info);
+				if(j >= n || syms[j] == nil)
+					continue;
+				break;
+			}
+			t = syms[j];
+			r = addrel(syms[i]);
+			r->off = off - addr[i];
+			r->siz = 8;
+			r->type = R_ADDR;
+			r->sym = t;
+			r->add = add;
+		}
+	}
+
+	// The library's code replaces the objects' copies.
+	last = nil;
+	for(s = ctxt->textp; s != nil; s = s->next) {
+		if(s->type == SDYNIMPORT)
+			continue;
+		if(last == nil)
+			ctxt->textp = s;
+		else
+			last->next = s;
+		last = s;
+	}
+	if(last == nil)
+		ctxt->textp = nil;
+	else
+		last->next = nil;
+	ctxt->etextp = last;
+
+	free(byaddr);
+	free(needrel);
+	free(addr);
+	free(syms);
+	Bterm(f);
+	return;
+
+bad:
+	diag("%s: malformed shared library: %r", path);
+	Bterm(f);
+}
@@ -104,6 +104,7 @@ void
 libinit(void)
 {
 	char *suffix, *suffixsep;
+	LSym *s;
 
 	funcalign = FuncAlign;
 	fmtinstall('i', iconv);
@@ -132,13 +133,23 @@ libinit(void)
 
 	if(INITENTRY == nil) {
 		INITENTRY = mal(strlen(goarch)+strlen(goos)+20);
-		if(!flag_shared) {
+		if(buildmode != BuildmodeCArchive && buildmode != BuildmodeCShared) {
 			sprint(INITENTRY, "_rt0_%s_%s", goarch, goos);
 		} else {
 			sprint(INITENTRY, "_rt0_%s_%s_lib", goarch, goos);
 		}
 	}
 	linklookup(ctxt, INITENTRY, 0)->type = SXREF;
+
+	// A library has no entry point of its own: it is started
+	// by the dynamic loader or the C runtime, which run the
+	// functions listed in the .init_array section.
+	if(buildmode == BuildmodeCArchive || buildmode == BuildmodeCShared) {
+		s = linklookup(ctxt, smprint("%s.ptr", INITENTRY), 0);
+		s->type = SINITARR;
+		s->dupok = 1;
+		addaddr(ctxt, s, linklookup(ctxt, INITENTRY, 0));
+	}
 }
 
 void
@@ -177,6 +188,99 @@ loadinternal(char *name)
 		Bprint(&bso, "warning: unable to find %s.a\n", name);
 }
 
+static char*	shlib;
+
+// loadlibrary loads the package in library i. When linking with
+// -linkshared, a package installed in a Go shared library has a
+// pkg.shlibname file next to its pkg.a naming the library; its
+// object file is skipped and the library's symbols are read once
+// all the object files are loaded, see ldelf.c:/^ldshlibsyms.
+// A Go shared library holds its own copy of the runtime, so all
+// the packages must come from the same one.
+static void
+loadlibrary(int i)
+{
+	Biobuf *f;
+	char *file, *name, *lib;
+	int n;
+
+	if(flag_linkshared) {
+		file = ctxt->library[i].file;
+		n = strlen(file);
+		if(n > 2 && strcmp(file+n-2, ".a") == 0)
+			n -= 2;
+		name = smprint("%.*s.shlibname", n, file);
+		f = Bopen(name, OREAD);
+		free(name);
+		if(f != nil) {
+			lib = Brdstr(f, '\n', 1);
+			Bterm(f);
+			if(lib == nil) {
+				diag("cannot read shared library name for %s", ctxt->library[i].file);
+				return;
+			}
+			if(shlib == nil)
+				shlib = lib;
+			else {
+				if(strcmp(shlib, lib) != 0)
+					diag("%s is in %s, not in %s: cannot link against more than one Go shared library",
+						ctxt->library[i].pkg, lib, shlib);
+				free(lib);
+			}
+			return;
+		}
+	}
+	objfile(ctxt->library[i].file, ctxt->library[i].pkg);
+}
+
+// addmoduledatainit adds the initializer that a program linked
+// with -linkshared runs from its .init_array to add its module
+// data, go.link.moduledata, to the runtime's list of modules.
+// The runtime's own firstmoduledata is in the shared library,
+// so the program's module data is a local copy of the same size.
+// The linker fills in both the same way, see symtab.c:/^moduledata.
+static void
+addmoduledatainit(void)
+{
+	LSym *s, *md, *init;
+
+	md = linklookup(ctxt, "go.link.moduledata", 0);
+	md->type = SNOPTRDATA;
+	md->size = linklookup(ctxt, "runtime.firstmoduledata", 0)->size;
+	md->local = 1;
+
+	// The initializer is called with the C calling convention:
+	//	LEAQ	go.link.moduledata(SB), DI
+	//	CALL	runtime.addmoduledata(SB)
+	//	RET
+	s = linklookup(ctxt, "go.link.addmoduledata", 0);
+	s->type = STEXT;
+	s->local = 1;
+	adduint8(ctxt, s, 0x48);
+	adduint8(ctxt, s, 0x8d);
+	adduint8(ctxt, s, 0x3d);
+	addpcrelplus(ctxt, s, md, 0);
+	adduint8(ctxt, s, 0xe8);
+	addpcrelplus(ctxt, s, linklookup(ctxt, "runtime.addmoduledata", 0), 0);
+	s->r[s->nr-1].type = R_CALL;
+	adduint8(ctxt, s, 0xc3);
+	if(ctxt->textp == nil)
+		ctxt->textp = s;
+	else
+		ctxt->etextp->next = s;
+	ctxt->etextp = s;
+
+	init = linklookup(ctxt, "go.link.addmoduledatainit", 0);
+	init->type = SINITARR;
+	init->local = 1;
+	addaddr(ctxt, init, s);
+
+	// Growing a symbol marks it reachable; let deadcode mark
+	// these from its roots, so that it follows their relocations.
+	s->reachable = 0;
+	init->reachable = 0;
+}
+
 void
 loadlib(void)
 {
@@ -184,11 +288,16 @@ loadlib(void)
 	LSym *s, *tlsg;
 	char* cgostrsym;
 
-	if(flag_shared) {
+	if(buildmode == BuildmodeCShared) {
 		s = linklookup(ctxt, "runtime.islibrary", 0);
 		s->dupok = 1;
 		adduint8(ctxt, s, 1);
 	}
+	if(buildmode == BuildmodeCArchive) {
+		s = linklookup(ctxt, "runtime.isarchive", 0);
+		s->dupok = 1;
+		adduint8(ctxt, s, 1);
+	}
 
 	loadinternal("runtime");
 	if(thechar == '5')
@@ -200,7 +309,7 @@ loadlib(void)
 		if(debug['v'] > 1)
 			Bprint(&bso, "%5.2f autolib: %s (from %s)\n", cputime(), ctxt->library[i].file, ctxt->library[i].objref);
 		iscgo |= strcmp(ctxt->library[i].pkg, "runtime/cgo") == 0;
-		objfile(ctxt->library[i].file, ctxt->library[i].pkg);
+		loadlibrary(i);
 	}
 
 	if(linkmode == LinkAuto) {
@@ -221,7 +330,7 @@ loadlib(void)
 		// be handled differently but it's an unusual case.
 		loadinternal("runtime/cgo");
 		if(i < ctxt->libraryp)
-			objfile(ctxt->library[i].file, ctxt->library[i].pkg);
+			loadlibrary(i);
 
 		// Pretend that we really imported the package.
 		s = linklookup(ctxt, "go.importpath.runtime/cgo.", 0);
@@ -256,10 +365,34 @@ loadlib(void)
 			}
 	}
 	
+	// The Go shared library's symbols override the copies
+	// in the object files, so read them last.
+	if(shlib != nil)
+		ldshlibsyms(shlib);
+	if(flag_linkshared)
+		addmoduledatainit();
+
+	// A Go shared library leaves main.init and main.main
+	// to the program linked against it.
+	if(buildmode == BuildmodeShared) {
+		for(i=0; i<2; i++) {
+			s = linklookup(ctxt, i == 0 ? "main.init" : "main.main", 0);
+			if(s->type == 0 || s->type == SXREF) {
+				s->type = SDYNIMPORT;
+				s->extname = s->name;
+				s->elftype = STT_FUNC;
+			}
+		}
+	}
+
+	// When linking against a Go shared library, the thread-local
+	// g is the one in the library.
 	tlsg = linklookup(ctxt, "runtime.tlsg", 0);
-	tlsg->type = STLSBSS;
-	tlsg->size = PtrSize;
-	tlsg->hide = 1;
+	if(tlsg->type != SDYNIMPORT) {
+		tlsg->type = STLSBSS;
+		tlsg->size = PtrSize;
+		tlsg->hide = 1;
+	}
 	tlsg->reachable = 1;
 	ctxt->tlsg = tlsg;
 
@@ -561,17 +694,107 @@ hostlinksetup(void)
 	free(p);
 }
 
-void
-hostlink(void)
+// hostobjcopy copies the host objects to the temporary directory,
+// adding their names to argv, and returns the new argument count.
+static int
+hostobjcopy(char **argv, int argc)
 {
-	char *p, **argv;
-	int c, i, w, n, argc, len;
+	char *p;
+	int i, w, n, len;
 	Hostobj *h;
 	Biobuf *f;
 	static char buf[64<<10];
 
+	for(i=0; i<nhostobj; i++) {
+		h = &hostobj[i];
+		f = Bopen(h->file, OREAD);
+		if(f == nil) {
+			ctxt->cursym = S;
+			diag("cannot reopen %s: %r", h->pn);
+			errorexit();
+		}
+		Bseek(f, h->off, 0);
+		p = smprint("%s/%06d.o", tmpdir, i);
+		argv[argc++] = p;
+		w = create(p, 1, 0775);
+		if(w < 0) {
+			ctxt->cursym = S;
+			diag("cannot create %s: %r", p);
+			errorexit();
+		}
+		len = h->len;
+		while(len > 0 && (n = Bread(f, buf, sizeof buf)) > 0){
+			if(n > len)
+				n = len;
+			dowrite(w, buf, n);
+			len -= n;
+		}
+		if(close(w) < 0) {
+			ctxt->cursym = S;
+			diag("cannot write %s: %r", p);
+			errorexit();
+		}
+		Bterm(f);
+	}
+	return argc;
+}
+
+static void
+hostrun(char **argv)
+{
+	int i;
+
+	quotefmtinstall();
+	if(debug['v']) {
+		Bprint(&bso, "host link:");
+		for(i=0; argv[i] != nil; i++)
+			Bprint(&bso, " %q", argv[i]);
+		Bprint(&bso, "\n");
+		Bflush(&bso);
+	}
+
+	if(runcmd(argv) < 0) {
+		ctxt->cursym = S;
+		diag("%s: running %s failed: %r", argv0, argv[0]);
+		errorexit();
+	}
+}
+
+// hostarchive writes the C archive for -buildmode=c-archive:
+// the Go object and the host objects, ready to be linked
+// into a C program.
+static void
+hostarchive(void)
+{
+	char **argv;
+	int argc;
+
+	argv = malloc((5+nhostobj)*sizeof argv[0]);
+	argc = 0;
+	argv[argc++] = "ar";
+	argv[argc++] = "rcs";
+	argv[argc++] = outfile;
+	argc = hostobjcopy(argv, argc);
+	argv[argc++] = smprint("%s/go.o", tmpdir);
+	argv[argc] = nil;
+
+	// ar adds to an existing archive.
+	mayberemoveoutfile();
+	hostrun(argv);
+}
+
+void
+hostlink(void)
+{
+	char *p, **argv;
+	int c, i, argc;
+
 	if(linkmode != LinkExternal || nerrors > 0)
 		return;
+	if(buildmode == BuildmodeCArchive) {
+		hostarchive();
+		return;
+	}
 
 	c = 0;
 	p = extldflags;
@@ -584,7 +807,7 @@ hostlink(void)
 		p = strchr(p + 1, ' ');
 	}
 
-	argv = malloc((14+nhostobj+nldflag+c)*sizeof argv[0]);
+	argv = malloc((21+nhostobj+nldflag+c)*sizeof argv[0]);
 	argc = 0;
 	if(extld == nil)
 		extld = "gcc";
@@ -614,9 +837,28 @@ hostlink(void)
 	if(iself && AssumeGoldLinker)
 		argv[argc++] = "-Wl,--rosegment";
 
-	if(flag_shared) {
+	switch(buildmode) {
+	case BuildmodeCShared:
+		argv[argc++] = "-Wl,-Bsymbolic";
+		argv[argc++] = "-shared";
+		break;
+	case BuildmodePIE:
+		argv[argc++] = "-pie";
+		break;
+	case BuildmodeShared:
+		// Resolve the library's symbols at load time: lazy
+		// binding would run the dynamic loader on goroutine stacks.
 		argv[argc++] = "-Wl,-Bsymbolic";
+		argv[argc++] = "-Wl,-z,now";
 		argv[argc++] = "-shared";
+		break;
+	}
+	if(flag_linkshared) {
+		// The program's own code is position independent,
+		// and it must not copy the libraries' data.
+		argv[argc++] = "-pie";
+		argv[argc++] = "-Wl,-z,now";
+		argv[argc++] = "-Wl,-z,nocopyreloc";
 	}
 	argv[argc++] = "-o";
 	argv[argc++] = outfile;
@@ -632,40 +874,10 @@ hostlink(void)
 		argv[argc++] = "-Qunused-arguments";
 
 	// already wrote main object file
-	// copy host objects to temporary directory
-	for(i=0; i<nhostobj; i++) {
-		h = &hostobj[i];
-		f = Bopen(h->file, OREAD);
-		if(f == nil) {
-			ctxt->cursym = S;
-			diag("cannot reopen %s: %r", h->pn);
-			errorexit();
-		}
-		Bseek(f, h->off, 0);
-		p = smprint("%s/%06d.o", tmpdir, i);
-		argv[argc++] = p;
-		w = create(p, 1, 0775);
-		if(w < 0) {
-			ctxt->cursym = S;
-			diag("cannot create %s: %r", p);
-			errorexit();
-		}
-		len = h->len;
-		while(len > 0 && (n = Bread(f, buf, sizeof buf)) > 0){
-			if(n > len)
-				n = len;
-			dowrite(w, buf, n);
-			len -= n;
-		}
-		if(close(w) < 0) {
-			ctxt->cursym = S;
-			diag("cannot write %s: %r", p);
-			errorexit();
-		}
-		Bterm(f);
-	}
-	
+	argc = hostobjcopy(argv, argc);
 	argv[argc++] = smprint("%s/go.o", tmpdir);
+	if(shlib != nil)
+		argv[argc++] = shlib;
 	for(i=0; i<nldflag; i++)
 		argv[argc++] = ldflag[i];
 
@@ -694,21 +906,7 @@ hostlink(void)
 	}
 
 	argv[argc] = nil;
-
-	quotefmtinstall();
-	if(debug['v']) {
-		Bprint(&bso, "host link:");
-		for(i=0; i<argc; i++)
-			Bprint(&bso, " %q", argv[i]);
-		Bprint(&bso, "\n");
-		Bflush(&bso);
-	}
-
-	if(runcmd(argv) < 0) {
-		ctxt->cursym = S;
-		diag("%s: running %s failed: %r", argv0, argv[0]);
-		errorexit();
-	}
+	hostrun(argv);
 }
 
 void
@@ -1121,7 +1319,9 @@ stkcheck(Chain *up, int depth)
 		// external function.
 		// should never be called directly.
 		// only diagnose the direct caller.
-		if(depth == 1 && s->type != SXREF)
+		// A Go shared library's functions are checked
+		// when the library is linked.
+		if(depth == 1 && s->type != SXREF && !dynlinking())
 			diag("call to external function %s", s->name);
 		return -1;
 	}
@@ -1318,6 +1518,8 @@ void
 usage(void)
 {
 	fprint(2, "usage: %cl [options] main.%c\n", thechar, thechar);
+	if(thechar == '6')
+		fprint(2, "       %cl -buildmode shared [options] importpath=file...\n", thechar);
 	flagprint(2);
 	exits("usage");
 }
@@ -1379,6 +1581,7 @@ genasmsym(void (*put)(LSym*, char*, int, vlong, vlong, int, LSym*))
 		case SELFROSECT:
 		case SMACHOGOT:
 		case STYPE:
+		case STYPELINK:
 		case SSTRING:
 		case SGOSTRING:
 		case SWINDOWS:
@@ -1459,6 +1662,7 @@ xdefine(char *p, int t, vlong v)
 	s->value = v;
 	s->reachable = 1;
 	s->special = 1;
+	s->local = 1;
 }
 
 vlong
@@ -84,6 +84,7 @@ EXTERN	int	elfglobalsymndx;
 EXTERN	char*	flag_installsuffix;
 EXTERN	int	flag_race;
 EXTERN	int flag_shared;
+EXTERN	int	flag_linkshared;
 EXTERN	char*	tracksym;
 EXTERN	char*	interpreter;
 EXTERN	char*	tmpdir;
@@ -102,6 +103,7 @@ EXTERN	char*	paramspace;
 EXTERN	int	nerrors;
 
 EXTERN	int	linkmode;
+EXTERN	int	buildmode;
 EXTERN	int64	liveness;
 
 // for dynexport field of LSym
@@ -221,6 +223,7 @@ void	dostkoff(void);
 void	dosymtype(void);
 void	doversion(void);
 void	doweak(void);
+int	dynlinking(void);
 void	dynreloc(void);
 void	dynrelocsym(LSym *s);
 vlong	entryvalue(void);
@@ -241,6 +244,7 @@ void	ldmacho(Biobuf *f, char *pkg, int64 len, char *pn);
 void	ldobj(Biobuf *f, char *pkg, int64 len, char *pn, char *file, int whence);
 void	ldpe(Biobuf *f, char *pkg, int64 len, char *pn);
 void	ldpkg(Biobuf *f, char *pkg, int64 len, char *filename, int whence);
+void	ldshlibsyms(char *path);
 uint16	le16(uchar *b);
 uint32	le32(uchar *b);
 uint64	le64(uchar *b);
@@ -265,6 +269,7 @@ void	putsymb(LSym *s, char *name, int t, vlong v, vlong size, int ver, LSym *typ
 int	rbyoff(const void *va, const void *vb);
 void	reloc(void);
 void	relocsym(LSym *s);
+void	setbuildmode(char *arg);
 void	setheadtype(char *s);
 void	setinterp(char *s);
 void	setlinkmode(char *arg);
@@ -232,8 +232,10 @@ pclntab(void)
 	start += -ftab->np & (PtrSize-1);
 	setuint32(ctxt, ftab, 8+PtrSize+nfunc*2*PtrSize+PtrSize, start);
 
+	// The first entry is the length of the table, counting itself:
+	// file numbers start at 1.
 	symgrow(ctxt, ftab, start+(ctxt->nhistfile+1)*4);
-	setuint32(ctxt, ftab, start, ctxt->nhistfile);
+	setuint32(ctxt, ftab, start, ctxt->nhistfile+1);
 	for(s = ctxt->filesyms; s != S; s = s->next)
 		setuint32(ctxt, ftab, start + s->value*4, ftabaddstring(ftab, s->name));
 
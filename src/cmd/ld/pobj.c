@@ -46,6 +46,7 @@ void
 main(int argc, char *argv[])
 {
 	int i;
+	char *p;
 
 	linkarchinit();
 	ctxt = linknew(thelinkarch);
@@ -65,6 +66,7 @@ main(int argc, char *argv[])
 	INITRND = -1;
 	INITENTRY = 0;
 	linkmode = LinkAuto;
+	buildmode = BuildmodeExe;
 	
 	// For testing behavior of go command when tools crash.
 	// Undocumented, not in standard flag parser to avoid
@@ -103,6 +105,7 @@ main(int argc, char *argv[])
 	flagfn2("X", "name value: define string data", addstrdata);
 	flagcount("Z", "clear stack frame on entry", &debug['Z']);
 	flagcount("a", "disassemble output", &debug['a']);
+	flagfn1("buildmode", "mode: set build mode (exe, c-archive, c-shared, pie, shared)", setbuildmode);
 	flagcount("c", "dump call graph", &debug['c']);
 	flagcount("d", "disable dynamic executable", &debug['d']);
 	flagstr("extld", "ld: linker to run in external mode", &extld);
@@ -112,6 +115,8 @@ main(int argc, char *argv[])
 	flagstr("installsuffix", "suffix: pkg directory suffix", &flag_installsuffix);
 	flagstr("k", "sym: set field tracking symbol", &tracksym);
 	flagfn1("linkmode", "mode: set link mode (internal, external, auto)", setlinkmode);
+	if(thechar == '6')
+		flagcount("linkshared", "link against installed Go shared libraries", &flag_linkshared);
 	flagcount("n", "dump symbol table", &debug['n']);
 	flagstr("o", "outfile: set output file", &outfile);
 	flagstr("r", "dir1:dir2:...: set ELF dynamic linker search path", &rpath);
@@ -125,6 +130,21 @@ main(int argc, char *argv[])
 	flagcount("w", "disable DWARF generation", &debug['w']);
 	
 	flagparse(&argc, &argv, usage);
+
+	// -shared is the old spelling of -buildmode=c-shared.
+	// All the other build modes need code compiled with -shared,
+	// and so does a program linked against Go shared libraries.
+	if(flag_shared && buildmode == BuildmodeExe)
+		buildmode = BuildmodeCShared;
+	if(flag_linkshared && buildmode != BuildmodeExe)
+		sysfatal("-linkshared can only be used with -buildmode=exe");
+	if(buildmode != BuildmodeExe || flag_linkshared)
+		flag_shared = 1;
+	// The types of a program linked against Go shared libraries
+	// are mostly in the libraries, which the DWARF writer cannot decode.
+	if(flag_linkshared)
+		debug['w'] = 1;
+
 	ctxt->bso = &bso;
 	ctxt->debugdivmod = debug['M'];
 	ctxt->debugfloat = debug['F'];
@@ -134,7 +154,7 @@ main(int argc, char *argv[])
 	ctxt->debugstack = debug['K'];
 	ctxt->debugvlog = debug['v'];
 
-	if(argc != 1)
+	if(argc < 1 || (argc > 1 && buildmode != BuildmodeShared))
 		usage();
 
 	if(outfile == nil) {
@@ -162,7 +182,18 @@ main(int argc, char *argv[])
 	cbp = buf.cbuf;
 	cbc = sizeof(buf.cbuf);
 
-	addlibpath(ctxt, "command line", "command line", argv[0], "main");
+	if(buildmode == BuildmodeShared) {
+		// A shared library is linked from the packages
+		// listed on the command line as importpath=file.
+		for(i=0; i<argc; i++) {
+			p = strchr(argv[i], '=');
+			if(p == nil)
+				usage();
+			*p++ = '\0';
+			addlibpath(ctxt, "command line", "command line", p, argv[i]);
+		}
+	} else
+		addlibpath(ctxt, "command line", "command line", argv[0], "main");
 	loadlib();
 	
 	if(thechar == '5') {
@@ -56,8 +56,10 @@ putelfstr(char *s)
 	elfstrsize += n;
 	memmove(elfstrdat+off, s, n);
 	// replace "·" as ".", because DTrace cannot handle it.
+	// Go shared libraries keep it: the symbols of a library
+	// are looked up by their Go names, see ldelf.c:/^ldshlibsyms.
 	p = strstr(s, "·");
-	if(p != nil) {
+	if(p != nil && !dynlinking()) {
 		p = q = elfstrdat+off;
 		while (*q != '\0') {
 			if((uchar)*q == 0xc2 && (uchar)*(q+1) == 0xb7) {
@@ -149,6 +151,11 @@ putelfsym(LSym *x, char *s, int t, vlong addr, vlong size, int ver, LSym *go)
 	if(linkmode == LinkExternal && !(x->cgoexport&CgoExportStatic))
 		bind = STB_LOCAL;
 
+	// A Go shared library and the programs linked against it
+	// refer to each other's Go symbols, so those stay global.
+	if(dynlinking() && !ver && !(x->type & SHIDDEN) && !x->local)
+		bind = STB_GLOBAL;
+
 	if(bind != elfbind)
 		return;
 
@@ -184,11 +191,46 @@ putelfsymshndx(vlong sympos, int shndx)
 	cseek(here);
 }
 
+// putelftlsg writes the ELF symbol for runtime.tlsg in external
+// linking mode, in the pass for its binding: a Go shared library
+// exports it to the programs linked against it, for which it is
+// a dynamic import like the library's other symbols.
+static void
+putelftlsg(void)
+{
+	LSym *s;
+	int bind;
+
+	if(linkmode != LinkExternal || HEADTYPE == Hopenbsd)
+		return;
+	s = linklookup(ctxt, "runtime.tlsg", 0);
+	if(s->type == SDYNIMPORT)
+		return;
+	bind = STB_LOCAL;
+	if(buildmode == BuildmodeShared)
+		bind = STB_GLOBAL;
+	if(bind != elfbind)
+		return;
+	if(s->sect == nil) {
+		ctxt->cursym = nil;
+		diag("missing section for %s", s->name);
+		errorexit();
+	}
+	if (strcmp(goos, "android") == 0) {
+		// Android emulates runtime.tlsg as a regular variable.
+		putelfsyment(putelfstr(s->name), 0, s->size, (bind<<4)|STT_OBJECT, s->sect->elfsect->shnum, 0);
+	} else {
+		putelfsyment(putelfstr(s->name), 0, s->size, (bind<<4)|STT_TLS, s->sect->elfsect->shnum, 0);
+	}
+	s->elfsym = numelfsym++;
+}
+
 void
 asmelfsym(void)
 {
 	LSym *s;
 	char *name;
+	int type;
 
 	// the first symbol entry is reserved
 	putelfsyment(0, 0, 0, (STB_LOCAL<<4)|STT_NOTYPE, 0, 0);
@@ -197,35 +239,23 @@ asmelfsym(void)
 
 	elfbind = STB_LOCAL;
 	genasmsym(putelfsym);
-	
-	if(linkmode == LinkExternal && HEADTYPE != Hopenbsd) {
-		s = linklookup(ctxt, "runtime.tlsg", 0);
-		if(s->sect == nil) {
-			ctxt->cursym = nil;
-			diag("missing section for %s", s->name);
-			errorexit();
-		}
-		if (strcmp(goos, "android") == 0) {
-			// Android emulates runtime.tlsg as a regular variable.
-			putelfsyment(putelfstr(s->name), 0, s->size, (STB_LOCAL<<4)|STT_OBJECT, s->sect->elfsect->shnum, 0);
-		} else {
-			putelfsyment(putelfstr(s->name), 0, s->size, (STB_LOCAL<<4)|STT_TLS, s->sect->elfsect->shnum, 0);
-		}
-		s->elfsym = numelfsym++;
-	}
+	putelftlsg();
 
 	elfbind = STB_GLOBAL;
 	elfglobalsymndx = numelfsym;
 	genasmsym(putelfsym);
+	putelftlsg();
 	
 	for(s=ctxt->allsym; s!=S; s=s->allsym) {
 		if(s->type != SHOSTOBJ && !(s->type == SDYNIMPORT && s->reachable))
 			continue;
-		if(s->type == SDYNIMPORT)
+		type = STT_NOTYPE;
+		if(s->type == SDYNIMPORT) {
 			name = s->extname;
-		else
+			type = s->elftype;
+		} else
 			name = s->name;
-		putelfsyment(putelfstr(name), 0, 0, (STB_GLOBAL<<4)|STT_NOTYPE, 0, 0);
+		putelfsyment(putelfstr(name), 0, 0, (STB_GLOBAL<<4)|type, 0, 0);
 		s->elfsym = numelfsym++;
 	}
 }
@@ -338,6 +368,48 @@ vputl(uint64 v)
 	lputl(v >> 32);
 }
 
+// moduledata fills in the fields of the runtime's moduledata
+// for this module that only the linker knows, see
+// ../../runtime/symtab.go:/^type.moduledata.
+static void
+moduledata(LSym *s)
+{
+	static char *names[] = {
+		"runtime.pclntab",
+		"runtime.epclntab",
+		"runtime.text",
+		"runtime.etext",
+		"runtime.noptrdata",
+		"runtime.enoptrdata",
+		"runtime.data",
+		"runtime.edata",
+		"runtime.bss",
+		"runtime.ebss",
+		"runtime.noptrbss",
+		"runtime.enoptrbss",
+		"runtime.end",
+		"runtime.gcdata",
+		"runtime.gcbss",
+		"runtime.typelink",
+		"runtime.etypelink",
+	};
+	vlong size;
+	int i;
+
+	// The Go variable has the size of the whole struct;
+	// the rest of it is filled in at run time.
+	size = s->size;
+	s->type = SNOPTRDATA;
+	s->size = 0;
+	s->reachable = 1;
+	for(i=0; i<nelem(names); i++)
+		addaddr(ctxt, s, linklookup(ctxt, names[i], 0));
+	if(s->size < size) {
+		s->size = size;
+		symgrow(ctxt, s, size);
+	}
+}
+
 void
 symtab(void)
 {
@@ -370,12 +442,14 @@ symtab(void)
 	s->type = SRODATA;
 	s->size = 0;
 	s->reachable = 1;
+	s->local = 1;
 	xdefine("runtime.egcdata", SRODATA, 0);
 
 	s = linklookup(ctxt, "runtime.gcbss", 0);
 	s->type = SRODATA;
 	s->size = 0;
 	s->reachable = 1;
+	s->local = 1;
 	xdefine("runtime.egcbss", SRODATA, 0);
 
 	// pseudo-symbols to mark locations of type, string, and go string data.
@@ -383,18 +457,21 @@ symtab(void)
 	s->type = STYPE;
 	s->size = 0;
 	s->reachable = 1;
+	s->local = 1;
 	symtype = s;
 
 	s = linklookup(ctxt, "go.string.*", 0);
 	s->type = SGOSTRING;
 	s->size = 0;
 	s->reachable = 1;
+	s->local = 1;
 	symgostring = s;
 	
 	s = linklookup(ctxt, "go.func.*", 0);
 	s->type = SGOFUNC;
 	s->size = 0;
 	s->reachable = 1;
+	s->local = 1;
 	symgofunc = s;
 	
 	symtypelink = linklookup(ctxt, "runtime.typelink", 0);
@@ -403,23 +480,30 @@ symtab(void)
 	symt->type = SSYMTAB;
 	symt->size = 0;
 	symt->reachable = 1;
+	symt->local = 1;
 
 	// assign specific types so that they sort together.
 	// within a type they sort by size, so the .* symbols
 	// just defined above will be first.
 	// hide the specific symbols.
+	// Types and type links are shared between Go modules,
+	// so when dynamically linking they are ordinary symbols.
 	for(s = ctxt->allsym; s != S; s = s->allsym) {
 		if(!s->reachable || s->special || s->type != SRODATA)
 			continue;
 		if(strncmp(s->name, "type.", 5) == 0) {
 			s->type = STYPE;
-			s->hide = 1;
-			s->outer = symtype;
+			if(!dynlinking()) {
+				s->hide = 1;
+				s->outer = symtype;
+			}
 		}
 		if(strncmp(s->name, "go.typelink.", 12) == 0) {
 			s->type = STYPELINK;
-			s->hide = 1;
-			s->outer = symtypelink;
+			if(!dynlinking()) {
+				s->hide = 1;
+				s->outer = symtypelink;
+			}
 		}
 		if(strncmp(s->name, "go.string.", 10) == 0) {
 			s->type = SGOSTRING;
@@ -439,4 +523,11 @@ symtab(void)
 			liveness += (s->size+s->align-1)&~(s->align-1);
 		}
 	}
+
+	// The module data of a program linked against a Go shared
+	// library is its own; see lib.c:/^addmoduledatainit.
+	s = linklookup(ctxt, "runtime.firstmoduledata", 0);
+	if(s->type == SDYNIMPORT)
+		s = linklookup(ctxt, "go.link.moduledata", 0);
+	moduledata(s);
 }
@@ -8,5 +8,10 @@
 //
 //	go tool pprof binary profile
 //
+// With -http=host:port, pprof serves a web interface instead of
+// reading commands from the terminal. Its pages show the call graph,
+// the top functions, a flame graph, and annotated source and
+// disassembly, with the focus and ignore filters set in the page.
+//
 // For more information, see http://blog.golang.org/profiling-go-programs.
 package main
@@ -65,6 +65,9 @@ func PProf(c Completer, interactive **bool, svgpan **string) Commands {
 		"png": {c, report.Dot, invokeDot("png"), false, "Outputs a graph image in PNG format"},
 		"ps":  {c, report.Dot, invokeDot("ps"), false, "Outputs a graph in PS format"},
 
+		// Generate a flame graph, which needs no postprocessing by dot.
+		"flamegraph": {c, report.FlameGraph, awayFromTTY("svg"), false, "Outputs a flame graph in SVG format"},
+
 		// Save SVG output into a file after including svgpan library
 		"svg": {c, report.Dot, saveSVGToFile(svgpan), false, "Outputs a graph in SVG format"},
 
@@ -10,6 +10,7 @@ package driver
 import (
 	"bytes"
 	"fmt"
+	"internal/pprof/profile"
 	"io"
 	"net/url"
 	"os"
@@ -23,7 +24,6 @@ import (
 
 	"cmd/pprof/internal/commands"
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 	"cmd/pprof/internal/report"
 	"cmd/pprof/internal/tempfile"
 )
@@ -108,6 +108,10 @@ func PProf(flagset plugin.FlagSet, fetch plugin.Fetcher, sym plugin.Symbolizer,
 
 	prof.RemoveUninteresting()
 
+	if *f.flagHTTP != "" {
+		return serveWebInterface(*f.flagHTTP, prof, obj, ui, f)
+	}
+
 	if *f.flagInteractive {
 		return interactive(prof, obj, ui, f)
 	}
@@ -424,6 +428,7 @@ func grabProfile(source, exec, buildid string, fetch plugin.Fetcher, sym plugin.
 
 type flags struct {
 	flagInteractive   *bool              // Accept commands interactively
+	flagHTTP          *string            // Serve a web interface on this address
 	flagCommands      map[string]*bool   // pprof commands without parameters
 	flagParamCommands map[string]*string // pprof commands with parameters
 
@@ -613,6 +618,7 @@ func (f *flags) usage(ui plugin.UI) {
 func getFlags(flag plugin.FlagSet, overrides commands.Commands, ui plugin.UI) (*flags, error) {
 	f := &flags{
 		flagInteractive:   flag.Bool("interactive", false, "Accepts commands interactively"),
+		flagHTTP:          flag.String("http", "", "Serve a web interface on host:port"),
 		flagCommands:      make(map[string]*bool),
 		flagParamCommands: make(map[string]*string),
 
@@ -775,14 +781,14 @@ func processFlags(p *profile.Profile, ui plugin.UI, f *flags) error {
 
 	var err error
 	si, sm := *f.flagSampleIndex, *f.flagMean || *f.flagMeanDelay
-	si, err = sampleIndex(p, &f.flagTotalDelay, si, 1, "delay", "-total_delay", err)
-	si, err = sampleIndex(p, &f.flagMeanDelay, si, 1, "delay", "-mean_delay", err)
-	si, err = sampleIndex(p, &f.flagContentions, si, 0, "contentions", "-contentions", err)
+	si, err = sampleIndex(p, &f.flagTotalDelay, si, "delay", "-total_delay", err)
+	si, err = sampleIndex(p, &f.flagMeanDelay, si, "delay", "-mean_delay", err)
+	si, err = sampleIndex(p, &f.flagContentions, si, "contentions", "-contentions", err)
 
-	si, err = sampleIndex(p, &f.flagInUseSpace, si, 1, "inuse_space", "-inuse_space", err)
-	si, err = sampleIndex(p, &f.flagInUseObjects, si, 0, "inuse_objects", "-inuse_objects", err)
-	si, err = sampleIndex(p, &f.flagAllocSpace, si, 1, "alloc_space", "-alloc_space", err)
-	si, err = sampleIndex(p, &f.flagAllocObjects, si, 0, "alloc_objects", "-alloc_objects", err)
+	si, err = sampleIndex(p, &f.flagInUseSpace, si, "inuse_space", "-inuse_space", err)
+	si, err = sampleIndex(p, &f.flagInUseObjects, si, "inuse_objects", "-inuse_objects", err)
+	si, err = sampleIndex(p, &f.flagAllocSpace, si, "alloc_space", "-alloc_space", err)
+	si, err = sampleIndex(p, &f.flagAllocObjects, si, "alloc_objects", "-alloc_objects", err)
 
 	if si == -1 {
 		// Use last value if none is requested.
@@ -799,9 +805,12 @@ func processFlags(p *profile.Profile, ui plugin.UI, f *flags) error {
 	return nil
 }
 
+// sampleIndex returns the index of the sample value named sampleType
+// if the option selecting it is set. Legacy profiles carry only the
+// values for one selection, while protocol buffer profiles may carry
+// several, so the index is found by name.
 func sampleIndex(p *profile.Profile, flag **bool,
 	sampleIndex int,
-	newSampleIndex int,
 	sampleType, option string,
 	err error) (int, error) {
 	if err != nil || !**flag {
@@ -811,11 +820,12 @@ func sampleIndex(p *profile.Profile, flag **bool,
 	if sampleIndex != -1 {
 		return 0, fmt.Errorf("set at most one sample value selection option")
 	}
-	if newSampleIndex >= len(p.SampleType) ||
-		p.SampleType[newSampleIndex].Type != sampleType {
-		return 0, fmt.Errorf("option %s not valid for this profile", option)
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			return i, nil
+		}
 	}
-	return newSampleIndex, nil
+	return 0, fmt.Errorf("option %s not valid for this profile", option)
 }
 
 func countFlags(bs []*bool) int {
@@ -872,7 +882,7 @@ var usageMsg = "Output file parameters (for file-based output formats):\n" +
 	"  -inuse_objects    Display in-use object counts\n" +
 	"  -alloc_space      Display allocated memory size\n" +
 	"  -alloc_objects    Display allocated object counts\n" +
-	"Sample value selection option (for contention profiles):\n" +
+	"Sample value selection option (for block and mutex profiles):\n" +
 	"  -total_delay      Display total delay at each region\n" +
 	"  -contentions      Display number of delays at each region\n" +
 	"  -mean_delay       Display mean delay at each region\n" +
@@ -883,6 +893,8 @@ var usageMsg = "Output file parameters (for file-based output formats):\n" +
 	"                    Restrict to samples with numeric tags in range (eg \"32kb:1mb\")\n" +
 	"  -tagignore=r      Discard samples tagged with key:value matching regexp\n" +
 	"                    Avoid samples with numeric tags in range (eg \"1mb:\")\n" +
+	"Web interface:\n" +
+	"  -http=host:port   Serve a web interface on host:port\n" +
 	"Miscellaneous:\n" +
 	"  -call_tree        Generate a context-sensitive call tree\n" +
 	"  -unit=u           Convert all samples to unit u for display\n" +
@@ -1008,19 +1020,15 @@ func generate(interactive bool, prof *profile.Profile, obj plugin.ObjTool, ui pl
 		defer outputFile.Close()
 		w = outputFile
 	}
+	return writeReport(w, prof, obj, ui, f, o, postProcess)
+}
 
-	value, stype, unit := sampleFormat(prof, f)
-	o.SampleType = stype
-	rpt := report.New(prof, *o, value, unit)
-
-	// Do not apply filters if we're just generating a proto, so we
-	// still have all the data.
-	if o.OutputFormat != report.Proto {
-		// Delay applying focus/ignore until after creating the report so
-		// the report reflects the total number of samples.
-		if err := preprocess(prof, ui, f); err != nil {
-			return err
-		}
+// writeReport generates the report described by o for prof and
+// writes it to w, after running it through postProcess if not nil.
+func writeReport(w io.Writer, prof *profile.Profile, obj plugin.ObjTool, ui plugin.UI, f *flags, o *report.Options, postProcess commands.PostProcessor) error {
+	rpt, err := newReport(prof, ui, f, o)
+	if err != nil {
+		return err
 	}
 
 	if postProcess == nil {
@@ -1034,3 +1042,22 @@ func generate(interactive bool, prof *profile.Profile, obj plugin.ObjTool, ui pl
 
 	return postProcess(&dot, w, ui)
 }
+
+// newReport creates the report described by o for prof and applies
+// the filters selected by f to prof.
+func newReport(prof *profile.Profile, ui plugin.UI, f *flags, o *report.Options) (*report.Report, error) {
+	value, stype, unit := sampleFormat(prof, f)
+	o.SampleType = stype
+	rpt := report.New(prof, *o, value, unit)
+
+	// Do not apply filters if we're just generating a proto, so we
+	// still have all the data.
+	if o.OutputFormat != report.Proto {
+		// Delay applying focus/ignore until after creating the report so
+		// the report reflects the total number of samples.
+		if err := preprocess(prof, ui, f); err != nil {
+			return nil, err
+		}
+	}
+	return rpt, nil
+}
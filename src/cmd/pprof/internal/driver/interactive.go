@@ -6,6 +6,7 @@ package driver
 
 import (
 	"fmt"
+	"internal/pprof/profile"
 	"io"
 	"regexp"
 	"sort"
@@ -14,7 +15,6 @@ import (
 
 	"cmd/pprof/internal/commands"
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 )
 
 var profileFunctionNames = []string{}
@@ -219,7 +219,7 @@ func commandHelp(_ []string, ui plugin.UI, f *flags) error {
             inuse_space
             inuse_objects
 
-            total_delay        for contention profiles
+            total_delay        for block and mutex profiles
             mean_delay
             contentions
 
@@ -0,0 +1,336 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"internal/pprof/profile"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cmd/pprof/internal/plugin"
+	"cmd/pprof/internal/report"
+)
+
+// A webView is a page of the web interface, showing the report
+// generated by a pprof command.
+type webView struct {
+	Path    string
+	Name    string
+	Command string
+}
+
+var webViews = []webView{
+	{"/", "Graph", "svg"},
+	{"/top", "Top", "top"},
+	{"/flamegraph", "Flame graph", "flamegraph"},
+	{"/source", "Source", "list"},
+	{"/disasm", "Disassembly", "disasm"},
+}
+
+// webFilters are the filtering options that can be changed from the
+// web interface, with the flags holding their initial values.
+var webFilters = []struct {
+	name string
+	flag func(f *flags) **string
+}{
+	{"focus", func(f *flags) **string { return &f.flagFocus }},
+	{"ignore", func(f *flags) **string { return &f.flagIgnore }},
+	{"hide", func(f *flags) **string { return &f.flagHide }},
+	{"tagfocus", func(f *flags) **string { return &f.flagTagFocus }},
+	{"tagignore", func(f *flags) **string { return &f.flagTagIgnore }},
+}
+
+// A webInterface serves the reports for a profile over HTTP.
+type webInterface struct {
+	mu    sync.Mutex // serializes report generation and symbol lookups
+	prof  *profile.Profile
+	obj   plugin.ObjTool
+	ui    plugin.UI
+	flags *flags
+}
+
+// serveWebInterface serves a web interface for the profile on addr.
+// Each page shows one report, filtered with the options in the
+// request; the options not set by the request keep the values given
+// on the command line.
+func serveWebInterface(addr string, p *profile.Profile, obj plugin.ObjTool, ui plugin.UI, f *flags) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// The graph is embedded in the page, where it needs no pan library.
+	f.flagSVGPan = newString("")
+
+	wi := &webInterface{prof: p, obj: obj, ui: ui, flags: f}
+	mux := http.NewServeMux()
+	for _, v := range webViews {
+		mux.HandleFunc(v.Path, wi.handler(v))
+	}
+	ui.Print("Serving web interface on http://", ln.Addr())
+	return http.Serve(ln, mux)
+}
+
+// A webUI is a plugin.UI that collects the error messages printed
+// while generating a report, to show them on the page.
+type webUI struct {
+	plugin.UI
+	msgs []string
+}
+
+func (u *webUI) PrintErr(args ...interface{}) {
+	u.msgs = append(u.msgs, strings.TrimSuffix(fmt.Sprint(args...), "\n"))
+}
+
+func (u *webUI) IsTerminal() bool {
+	return false
+}
+
+// webPage is the data for the page template.
+type webPage struct {
+	Title       string
+	View        webView
+	Views       []webView
+	Query       template.URL // query string for the current options, with ?
+	Filters     []webFilter
+	SampleTypes []string
+	SampleIndex int
+	Errors      []string
+
+	// The report, in one of the following forms.
+	SVG    template.HTML
+	Text   string
+	Legend []string
+	Items  []webItem
+}
+
+type webFilter struct {
+	Name, Value string
+}
+
+// A webItem is an entry of the top report, with links that focus
+// the top report and the source listing on it.
+type webItem struct {
+	report.TextItem
+	Focus, Source template.URL
+}
+
+func (wi *webInterface) handler(v webView) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != v.Path {
+			http.NotFound(w, r)
+			return
+		}
+		wi.mu.Lock()
+		page := wi.render(v, r.URL.Query())
+		wi.mu.Unlock()
+
+		var buf bytes.Buffer
+		if err := webTemplate.Execute(&buf, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		buf.WriteTo(w)
+	}
+}
+
+// render generates the report for v with the options in q.
+func (wi *webInterface) render(v webView, q url.Values) *webPage {
+	ui := &webUI{UI: wi.ui}
+	page := &webPage{
+		Title: "pprof",
+		View:  v,
+		Views: webViews,
+	}
+	if len(wi.prof.Mapping) > 0 && wi.prof.Mapping[0].File != "" {
+		page.Title += " " + wi.prof.Mapping[0].File
+	}
+	for _, st := range wi.prof.SampleType {
+		page.SampleTypes = append(page.SampleTypes, st.Type)
+	}
+
+	cf := *wi.flags
+	cf.flagInteractive = newBool(false)
+	cf.flagOutput = newString("")
+	cf.flagCommands = make(map[string]*bool)
+	cf.flagParamCommands = make(map[string]*string)
+
+	// Apply the options in the request, and record them all
+	// so that the links to the other views preserve them.
+	opts := url.Values{}
+	for _, filter := range webFilters {
+		fl := filter.flag(&cf)
+		if _, ok := q[filter.name]; ok {
+			*fl = newString(q.Get(filter.name))
+		}
+		page.Filters = append(page.Filters, webFilter{filter.name, **fl})
+		opts.Set(filter.name, **fl)
+	}
+	if s := q.Get("si"); s != "" {
+		si, err := strconv.Atoi(s)
+		if err != nil || si < 0 || si >= len(wi.prof.SampleType) {
+			page.Errors = append(page.Errors, fmt.Sprintf("sample index %q out of range [0..%d]", s, len(wi.prof.SampleType)-1))
+		} else {
+			cf.flagSampleIndex = newInt(si)
+		}
+	}
+	page.SampleIndex = *cf.flagSampleIndex
+	opts.Set("si", strconv.Itoa(page.SampleIndex))
+	page.Query = template.URL("?" + opts.Encode())
+	if page.Errors != nil {
+		return page
+	}
+
+	if pcmd := cf.commands[v.Command]; pcmd != nil && pcmd.HasParam {
+		// Listings show the functions matching the focus.
+		symbol := *cf.flagFocus
+		if symbol == "" {
+			symbol = "."
+		}
+		cf.flagParamCommands[v.Command] = newString(symbol)
+	} else {
+		cf.flagCommands[v.Command] = newBool(true)
+	}
+
+	defer func() {
+		page.Errors = append(ui.msgs, page.Errors...)
+	}()
+	prof := wi.prof.Copy()
+	if err := processFlags(prof, ui, &cf); err != nil {
+		page.Errors = append(page.Errors, err.Error())
+		return page
+	}
+	o, postProcess, err := parseOptions(&cf)
+	if err != nil {
+		page.Errors = append(page.Errors, err.Error())
+		return page
+	}
+
+	if v.Command == "top" {
+		rpt, err := newReport(prof, ui, &cf, o)
+		if err != nil {
+			page.Errors = append(page.Errors, err.Error())
+			return page
+		}
+		items, legend, err := report.TextItems(rpt)
+		if err != nil {
+			page.Errors = append(page.Errors, err.Error())
+			return page
+		}
+		page.Legend = legend
+		for _, item := range items {
+			focus := "^" + regexp.QuoteMeta(item.Name) + "$"
+			page.Items = append(page.Items, webItem{
+				TextItem: item,
+				Focus:    webLink("/top", opts, "focus", focus),
+				Source:   webLink("/source", opts, "focus", focus),
+			})
+		}
+		return page
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, prof, wi.obj, ui, &cf, o, postProcess); err != nil {
+		page.Errors = append(page.Errors, err.Error())
+		return page
+	}
+	switch v.Command {
+	case "svg", "flamegraph":
+		// Drop the XML prologue, which has no place inside HTML.
+		s := buf.String()
+		if i := strings.Index(s, "<svg"); i >= 0 {
+			s = s[i:]
+		}
+		page.SVG = template.HTML(s)
+	default:
+		page.Text = buf.String()
+	}
+	return page
+}
+
+// webLink returns a link to path with the options opts,
+// after setting the option key to value.
+func webLink(path string, opts url.Values, key, value string) template.URL {
+	q := url.Values{}
+	for k, v := range opts {
+		q[k] = v
+	}
+	q.Set(key, value)
+	return template.URL(path + "?" + q.Encode())
+}
+
+var webTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.Title}}</title>
+<style type="text/css">
+body { font-family: sans-serif; margin: 8px; }
+.nav a { margin-right: 12px; }
+.nav .current { font-weight: bold; }
+form { margin: 8px 0; }
+form input { width: 12em; }
+.error { color: #c00000; }
+table { border-collapse: collapse; }
+th, td { padding: 1px 8px; text-align: right; font-family: monospace; }
+td.name { text-align: left; }
+tr:hover { background-color: #eeeeee; }
+g.frame { cursor: pointer; }
+g.frame:hover rect { stroke: black; }
+</style>
+</head>
+<body>
+<div class="nav">
+{{range .Views}}<a href="{{.Path}}{{$.Query}}"{{if eq .Path $.View.Path}} class="current"{{end}}>{{.Name}}</a>
+{{end}}
+</div>
+<form id="options" action="{{.View.Path}}">
+{{range .Filters}}{{.Name}} <input type="text" id="{{.Name}}" name="{{.Name}}" value="{{.Value}}">
+{{end}}
+{{if .SampleTypes}}<select name="si">
+{{range $i, $t := .SampleTypes}}<option value="{{$i}}"{{if eq $i $.SampleIndex}} selected{{end}}>{{$t}}</option>
+{{end}}</select>{{end}}
+<button type="submit">Apply</button>
+<a href="{{.View.Path}}">Reset</a>
+</form>
+{{range .Errors}}<div class="error">{{.}}</div>
+{{end}}
+{{if .SVG}}<div id="report">{{.SVG}}</div>{{end}}
+{{if .Text}}<pre>{{.Text}}</pre>{{end}}
+{{if .Legend}}<pre>{{range .Legend}}{{.}}
+{{end}}</pre>{{end}}
+{{if .Items}}<table>
+<tr><th>flat</th><th>flat%</th><th>sum%</th><th>cum</th><th>cum%</th><th></th><th></th></tr>
+{{range .Items}}<tr><td>{{.FlatFormat}}</td><td>{{.FlatPercent}}</td><td>{{.SumPercent}}</td><td>{{.CumFormat}}</td><td>{{.CumPercent}}</td>
+<td class="name"><a href="{{.Focus}}">{{.Name}}</a></td><td><a href="{{.Source}}">source</a></td></tr>
+{{end}}</table>{{end}}
+<script type="text/javascript">
+// Clicking on a flame graph frame focuses on its function.
+(function() {
+	var frames = document.querySelectorAll("g.frame");
+	for (var i = 0; i < frames.length; i++) {
+		frames[i].addEventListener("click", function() {
+			var title = this.getElementsByTagName("title")[0].textContent;
+			var name = title.substring(0, title.lastIndexOf(" ("));
+			if (name == "root") {
+				return;
+			}
+			document.getElementById("focus").value = "^" + name.replace(/[.*+?^${}()|[\]\\]/g, "\\$&") + "$";
+			document.getElementById("options").submit();
+		});
+	}
+})();
+</script>
+</body>
+</html>
+`))
@@ -8,6 +8,7 @@ package fetch
 
 import (
 	"fmt"
+	"internal/pprof/profile"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -17,7 +18,6 @@ import (
 	"time"
 
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 )
 
 // FetchProfile reads from a data source (network, file) and generates a
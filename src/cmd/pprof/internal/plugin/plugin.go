@@ -8,12 +8,11 @@ package plugin
 import (
 	"bufio"
 	"fmt"
+	"internal/pprof/profile"
 	"os"
 	"regexp"
 	"strings"
 	"time"
-
-	"cmd/pprof/internal/profile"
 )
 
 // A FlagSet creates and parses command-line flags.
@@ -0,0 +1,141 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+// This file contains routines related to the generation of flame
+// graphs.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// Flame graph layout, in pixels.
+const (
+	flameWidth       = 1200
+	flameFrameHeight = 16
+	flameTitleHeight = 32
+	flameMinWidth    = 0.5 // narrower frames are not drawn
+	flameCharWidth   = 7   // approximate width of a character
+)
+
+// A flameNode is a frame in a flame graph. Its value is the total
+// value of the samples whose stacks begin with the frames leading
+// to it from the root.
+type flameNode struct {
+	name     string
+	value    int64
+	children map[string]*flameNode
+}
+
+// child returns the child of n with the given name, creating it if needed.
+func (n *flameNode) child(name string) *flameNode {
+	c := n.children[name]
+	if c == nil {
+		if n.children == nil {
+			n.children = make(map[string]*flameNode)
+		}
+		c = &flameNode{name: name}
+		n.children[name] = c
+	}
+	return c
+}
+
+// sortedChildren returns the children of n ordered by name.
+func (n *flameNode) sortedChildren() []*flameNode {
+	var names []string
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cs := make([]*flameNode, len(names))
+	for i, name := range names {
+		cs[i] = n.children[name]
+	}
+	return cs
+}
+
+// depth returns the number of frames on the deepest stack below n.
+func (n *flameNode) depth() int {
+	d := 0
+	for _, c := range n.children {
+		if cd := c.depth(); cd > d {
+			d = cd
+		}
+	}
+	return d + 1
+}
+
+// printFlameGraph prints a flame graph for a profile in SVG format.
+// Each frame is drawn above its caller, with a width proportional
+// to the value of the samples whose stacks pass through it.
+// Frames are named as in the other reports, so the granularity
+// options apply. Samples with negative values are left out.
+func printFlameGraph(w io.Writer, rpt *Report) error {
+	root := &flameNode{name: "root"}
+	for _, s := range rpt.prof.Sample {
+		v := rpt.sampleValue(s)
+		if v <= 0 {
+			continue
+		}
+		var stack []nodeInfo
+		for _, l := range s.Location {
+			stack = append(stack, newLocInfo(l)...)
+		}
+		n := root
+		n.value += v
+		for i := len(stack) - 1; i >= 0; i-- {
+			n = n.child(stack[i].prettyName())
+			n.value += v
+		}
+	}
+
+	height := flameTitleHeight + root.depth()*flameFrameHeight
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="Verdana, sans-serif" font-size="11">`+"\n", flameWidth, height)
+	fmt.Fprintf(w, `<text x="%d" y="20" text-anchor="middle" font-size="15">%s</text>`+"\n",
+		flameWidth/2, template.HTMLEscapeString(fmt.Sprintf("%s: %s total", rpt.options.SampleType, rpt.formatValue(root.value))))
+	if root.value > 0 {
+		printFlameNode(w, rpt, root, 0, 0, float64(flameWidth)/float64(root.value), height, root.value)
+	}
+	fmt.Fprintln(w, "</svg>")
+	return nil
+}
+
+// printFlameNode prints the frame for n at the given depth and
+// horizontal offset, followed by the frames of its callees.
+func printFlameNode(w io.Writer, rpt *Report, n *flameNode, depth int, x, scale float64, height int, total int64) {
+	width := float64(n.value) * scale
+	if width < flameMinWidth {
+		return
+	}
+	y := height - (depth+1)*flameFrameHeight
+	name := template.HTMLEscapeString(n.name)
+	fmt.Fprintf(w, `<g class="frame"><title>%s (%s, %s)</title>`, name, rpt.formatValue(n.value), percentage(n.value, total))
+	fmt.Fprintf(w, `<rect x="%.1f" y="%d" width="%.1f" height="%d" rx="2" fill="%s"/>`, x, y, width, flameFrameHeight-1, flameColor(n.name))
+	if label := n.name; width > 3*flameCharWidth {
+		if max := int(width/flameCharWidth) - 1; len(label) > max {
+			label = label[:max-2] + ".."
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%d">%s</text>`, x+3, y+flameFrameHeight-4, template.HTMLEscapeString(label))
+	}
+	fmt.Fprintln(w, "</g>")
+
+	for _, c := range n.sortedChildren() {
+		printFlameNode(w, rpt, c, depth+1, x, scale, height, total)
+		x += float64(c.value) * scale
+	}
+}
+
+// flameColor returns a warm color for the frame named name, so that
+// a function has the same color wherever it appears.
+func flameColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	v := h.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+v%50, 80+(v>>8)%150, 40+(v>>16)%50)
+}
@@ -8,6 +8,7 @@ package report
 
 import (
 	"fmt"
+	"internal/pprof/profile"
 	"io"
 	"math"
 	"os"
@@ -19,7 +20,6 @@ import (
 	"time"
 
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 )
 
 // Generate generates a report as directed by the Report.
@@ -48,6 +48,8 @@ func Generate(w io.Writer, rpt *Report, obj plugin.ObjTool) error {
 		return printWebSource(w, rpt, obj)
 	case Callgrind:
 		return printCallgrind(w, rpt)
+	case FlameGraph:
+		return printFlameGraph(w, rpt)
 	}
 	return fmt.Errorf("unexpected output format")
 }
@@ -321,33 +323,69 @@ func printTags(w io.Writer, rpt *Report) error {
 
 // printText prints a flat text report for a profile.
 func printText(w io.Writer, rpt *Report) error {
-	g, err := newGraph(rpt)
+	items, legend, err := TextItems(rpt)
 	if err != nil {
 		return err
 	}
 
-	origCount, droppedNodes, _ := g.preprocess(rpt)
-	fmt.Fprintln(w, strings.Join(legendDetailLabels(rpt, g, origCount, droppedNodes, 0), "\n"))
+	fmt.Fprintln(w, strings.Join(legend, "\n"))
 
 	fmt.Fprintf(w, "%10s %5s%% %5s%% %10s %5s%%\n",
 		"flat", "flat", "sum", "cum", "cum")
 
-	var flatSum int64
-	for _, n := range g.ns {
-		name, flat, cum := n.info.prettyName(), n.flat, n.cum
-
-		flatSum += flat
+	for _, item := range items {
 		fmt.Fprintf(w, "%10s %s %s %10s %s  %s\n",
-			rpt.formatValue(flat),
-			percentage(flat, rpt.total),
-			percentage(flatSum, rpt.total),
-			rpt.formatValue(cum),
-			percentage(cum, rpt.total),
-			name)
+			item.FlatFormat,
+			item.FlatPercent,
+			item.SumPercent,
+			item.CumFormat,
+			item.CumPercent,
+			item.Name)
 	}
 	return nil
 }
 
+// A TextItem is an entry of a flat text report.
+type TextItem struct {
+	Name       string
+	Flat, Cum  int64
+	FlatFormat string // Flat, formatted for display
+	CumFormat  string // Cum, formatted for display
+
+	// Percentages of the total value, formatted for display.
+	// SumPercent covers this entry and the ones before it.
+	FlatPercent, SumPercent, CumPercent string
+}
+
+// TextItems returns the entries of the flat text report for rpt, in
+// report order, along with the legend describing the report.
+func TextItems(rpt *Report) ([]TextItem, []string, error) {
+	g, err := newGraph(rpt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	origCount, droppedNodes, _ := g.preprocess(rpt)
+	legend := legendDetailLabels(rpt, g, origCount, droppedNodes, 0)
+
+	var items []TextItem
+	var flatSum int64
+	for _, n := range g.ns {
+		flatSum += n.flat
+		items = append(items, TextItem{
+			Name:        n.info.prettyName(),
+			Flat:        n.flat,
+			Cum:         n.cum,
+			FlatFormat:  rpt.formatValue(n.flat),
+			CumFormat:   rpt.formatValue(n.cum),
+			FlatPercent: percentage(n.flat, rpt.total),
+			SumPercent:  percentage(flatSum, rpt.total),
+			CumPercent:  percentage(n.cum, rpt.total),
+		})
+	}
+	return items, legend, nil
+}
+
 // printCallgrind prints a graph for a profile on callgrind format.
 func printCallgrind(w io.Writer, rpt *Report) error {
 	g, err := newGraph(rpt)
@@ -936,6 +974,7 @@ const (
 	List
 	WebList
 	Callgrind
+	FlameGraph
 )
 
 // Options are the formatting and filtering options used to generate a
@@ -9,12 +9,12 @@ package symbolizer
 
 import (
 	"fmt"
+	"internal/pprof/profile"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 )
 
 // Symbolize adds symbol and line number information to all locations
@@ -9,13 +9,12 @@ package symbolz
 import (
 	"bytes"
 	"fmt"
+	"internal/pprof/profile"
 	"io"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
-
-	"cmd/pprof/internal/profile"
 )
 
 var (
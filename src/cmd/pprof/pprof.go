@@ -8,6 +8,7 @@ import (
 	"debug/gosym"
 	"flag"
 	"fmt"
+	"internal/pprof/profile"
 	"os"
 	"regexp"
 	"strings"
@@ -18,7 +19,6 @@ import (
 	"cmd/pprof/internal/driver"
 	"cmd/pprof/internal/fetch"
 	"cmd/pprof/internal/plugin"
-	"cmd/pprof/internal/profile"
 	"cmd/pprof/internal/symbolizer"
 	"cmd/pprof/internal/symbolz"
 )
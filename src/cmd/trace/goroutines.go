@@ -0,0 +1,206 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Goroutine-related profiles.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"internal/trace"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/goroutines", httpGoroutines)
+	http.HandleFunc("/goroutine", httpGoroutine)
+}
+
+// gtype describes a group of goroutines grouped by start PC.
+type gtype struct {
+	ID       uint64 // Unique identifier (PC).
+	Name     string // Start function.
+	N        int    // Total number of goroutines in this group.
+	ExecTime int64  // Total execution time of all goroutines in this group.
+}
+
+type gtypeList []gtype
+
+func (l gtypeList) Len() int {
+	return len(l)
+}
+
+func (l gtypeList) Less(i, j int) bool {
+	return l[i].ExecTime > l[j].ExecTime
+}
+
+func (l gtypeList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// gdescList sorts goroutines by the field named by sortBy.
+type gdescList struct {
+	gs     []*trace.GDesc
+	sortBy string
+}
+
+func (l gdescList) Len() int {
+	return len(l.gs)
+}
+
+func (l gdescList) Less(i, j int) bool {
+	return gdescField(l.gs[i], l.sortBy) > gdescField(l.gs[j], l.sortBy)
+}
+
+func (l gdescList) Swap(i, j int) {
+	l.gs[i], l.gs[j] = l.gs[j], l.gs[i]
+}
+
+// gdescField returns the time in g named by field.
+func gdescField(g *trace.GDesc, field string) int64 {
+	switch field {
+	case "exec":
+		return g.ExecTime
+	case "sched":
+		return g.SchedWaitTime
+	case "io":
+		return g.IOTime
+	case "block":
+		return g.BlockTime
+	case "syscall":
+		return g.SyscallTime
+	case "gc":
+		return g.GCTime
+	}
+	return g.TotalTime
+}
+
+// analyzeGoroutines computes statistics for the goroutines in the trace.
+func analyzeGoroutines() (map[uint64]*trace.GDesc, error) {
+	events, err := parseEvents()
+	if err != nil {
+		return nil, err
+	}
+	return trace.GoroutineStats(events), nil
+}
+
+// httpGoroutines serves the list of goroutine groups.
+func httpGoroutines(w http.ResponseWriter, r *http.Request) {
+	gs, err := analyzeGoroutines()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	gss := make(map[uint64]gtype)
+	for _, g := range gs {
+		gs1 := gss[g.PC]
+		gs1.ID = g.PC
+		gs1.Name = g.Name
+		gs1.N++
+		gs1.ExecTime += g.ExecTime
+		gss[g.PC] = gs1
+	}
+	var glist gtypeList
+	for k, v := range gss {
+		v.ID = k
+		if v.Name == "" {
+			v.Name = "(unknown)"
+		}
+		glist = append(glist, v)
+	}
+	sort.Sort(glist)
+	if err := templGoroutines.Execute(w, glist); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var templGoroutines = template.Must(template.New("").Funcs(funcs).Parse(`
+<html>
+<head><title>Goroutines</title></head>
+<body>
+<h2>Goroutines</h2>
+<table border="1" cellpadding="3">
+<tr><th>Start function</th><th>Count</th><th>Total execution time</th></tr>
+{{range $}}
+  <tr>
+    <td><a href="/goroutine?id={{.ID}}">{{.Name}}</a></td>
+    <td>{{.N}}</td>
+    <td>{{dur .ExecTime}}</td>
+  </tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// httpGoroutine serves the statistics for the goroutines with one start PC.
+func httpGoroutine(w http.ResponseWriter, r *http.Request) {
+	gs, err := analyzeGoroutines()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pc, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse id parameter '%v': %v", r.FormValue("id"), err), http.StatusBadRequest)
+		return
+	}
+	var glist []*trace.GDesc
+	for _, g := range gs {
+		if g.PC == pc {
+			glist = append(glist, g)
+		}
+	}
+	sortBy := r.FormValue("sort")
+	sort.Sort(gdescList{glist, sortBy})
+	data := struct {
+		PC uint64
+		GS []*trace.GDesc
+	}{pc, glist}
+	if err := templGoroutine.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var templGoroutine = template.Must(template.New("").Funcs(funcs).Parse(`
+<html>
+<head><title>Goroutines</title></head>
+<body>
+<table border="1" cellpadding="3">
+<tr>
+<th>Goroutine</th>
+<th><a href="/goroutine?id={{.PC}}&sort=total">Total time</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=exec">Execution</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=io">Network wait</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=block">Sync block</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=syscall">Blocking syscall</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=sched">Scheduler wait</a></th>
+<th><a href="/goroutine?id={{.PC}}&sort=gc">GC</a></th>
+</tr>
+{{range .GS}}
+  <tr>
+    <td><a href="/timeline?goid={{.ID}}">{{.ID}}</a></td>
+    <td>{{dur .TotalTime}}</td>
+    <td>{{dur .ExecTime}}</td>
+    <td>{{dur .IOTime}}</td>
+    <td>{{dur .BlockTime}}</td>
+    <td>{{dur .SyscallTime}}</td>
+    <td>{{dur .SchedWaitTime}}</td>
+    <td>{{dur .GCTime}}</td>
+  </tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+var funcs = template.FuncMap{
+	"dur": func(ns int64) string {
+		return time.Duration(ns).String()
+	},
+}
@@ -0,0 +1,283 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Timeline view of the trace.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"internal/trace"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func init() {
+	http.HandleFunc("/timeline", httpTimeline)
+}
+
+const (
+	timelineWidth = 1200 // width of the time axis in pixels
+	labelWidth    = 100  // width of the row labels in pixels
+	rowHeight     = 20
+	axisHeight    = 20
+)
+
+// A slice is a span of time drawn as a rectangle on a row.
+type slice struct {
+	X, Y, W int
+	Color   string
+	Title   string
+}
+
+// A mark is an instant event drawn as a tick on a row.
+type mark struct {
+	X, Y  int
+	Title string
+}
+
+// A label names a row or a time on the axis.
+type label struct {
+	X, Y int
+	Text string
+}
+
+type timelineData struct {
+	Width, Height int
+	Goid          string
+	Start, End    int64
+	Prev, Next    [2]int64
+	ZoomIn        [2]int64
+	ZoomOut       [2]int64
+	Rows          []label
+	Axis          []label
+	Slices        []slice
+	Marks         []mark
+}
+
+// httpTimeline serves an SVG rendering of the trace between the
+// start and end times, in nanoseconds, given in the request. If the
+// request names a goroutine, only that goroutine and the goroutines
+// related to it are shown.
+func httpTimeline(w http.ResponseWriter, r *http.Request) {
+	events, err := parseEvents()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	traceEnd := events[len(events)-1].Ts
+	if traceEnd == 0 {
+		traceEnd = 1
+	}
+	start, end := int64(0), traceEnd
+	if s := r.FormValue("start"); s != "" {
+		if start, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse start parameter '%v': %v", s, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.FormValue("end"); s != "" {
+		if end, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse end parameter '%v': %v", s, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if end <= start {
+		http.Error(w, fmt.Sprintf("end %v is not after start %v", end, start), http.StatusBadRequest)
+		return
+	}
+	var related map[uint64]bool
+	goid := r.FormValue("goid")
+	if goid != "" {
+		id, err := strconv.ParseUint(goid, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse goid parameter '%v': %v", goid, err), http.StatusBadRequest)
+			return
+		}
+		related = trace.RelatedGoroutines(events, id)
+	}
+
+	data := generateTimeline(events, start, end, traceEnd, related)
+	data.Goid = goid
+	if err := templTimeline.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// generateTimeline lays out the events that overlap [start, end].
+// If related is not nil, only goroutines in it are drawn.
+func generateTimeline(events []*trace.Event, start, end, traceEnd int64, related map[uint64]bool) *timelineData {
+	d := &timelineData{Start: start, End: end}
+	span := end - start
+	d.Prev = [2]int64{start - span/2, end - span/2}
+	if d.Prev[0] < 0 {
+		d.Prev = [2]int64{0, span}
+	}
+	d.Next = [2]int64{start + span/2, end + span/2}
+	d.ZoomIn = [2]int64{start + span/4, end - span/4}
+	if d.ZoomIn[1] <= d.ZoomIn[0] {
+		d.ZoomIn = [2]int64{start, end}
+	}
+	d.ZoomOut = [2]int64{start - span/2, end + span/2}
+	if d.ZoomOut[0] < 0 {
+		d.ZoomOut[0] = 0
+	}
+
+	// Assign rows: GC first, then the real Ps in order, then the fake Ps.
+	var procs []int
+	seen := make(map[int]bool)
+	for _, ev := range events {
+		if ev.P >= 0 && ev.P < trace.FakeP && !seen[ev.P] {
+			seen[ev.P] = true
+			procs = append(procs, ev.P)
+		}
+	}
+	sort.Ints(procs)
+	row := make(map[int]int)
+	const gcRow = 0
+	d.Rows = append(d.Rows, label{Text: "GC"})
+	for _, p := range procs {
+		row[p] = len(d.Rows)
+		d.Rows = append(d.Rows, label{Text: fmt.Sprintf("Proc %v", p)})
+	}
+	for _, p := range []int{trace.TimerP, trace.NetpollP, trace.SyscallP} {
+		row[p] = len(d.Rows)
+		d.Rows = append(d.Rows, label{Text: fakePName(p)})
+	}
+	for i := range d.Rows {
+		d.Rows[i].Y = axisHeight + i*rowHeight + rowHeight*3/4
+	}
+	d.Width = labelWidth + timelineWidth + labelWidth/2 // room for the last axis label
+	d.Height = axisHeight + len(d.Rows)*rowHeight
+
+	x := func(ts int64) int {
+		if ts < start {
+			ts = start
+		}
+		if ts > end {
+			ts = end
+		}
+		return labelWidth + int(float64(ts-start)*timelineWidth/float64(span))
+	}
+	y := func(r int) int {
+		return axisHeight + r*rowHeight
+	}
+	addSlice := func(r int, from, to int64, color, title string) {
+		if to < start || from > end {
+			return
+		}
+		w := x(to) - x(from)
+		if w < 1 {
+			w = 1
+		}
+		d.Slices = append(d.Slices, slice{X: x(from), Y: y(r) + 2, W: w, Color: color, Title: title})
+	}
+
+	// Only the first GoStart of a goroutine carries its start function.
+	gs := trace.GoroutineStats(events)
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvGCStart:
+			to := traceEnd
+			if ev.Link != nil {
+				to = ev.Link.Ts
+			}
+			addSlice(gcRow, ev.Ts, to, "#d0a040", fmt.Sprintf("GC %v", time.Duration(to-ev.Ts)))
+		case trace.EvGoStart:
+			r, ok := row[ev.P]
+			if !ok || related != nil && !related[ev.G] {
+				break
+			}
+			to := traceEnd
+			if ev.Link != nil {
+				to = ev.Link.Ts
+			}
+			var name string
+			var pc uint64
+			if g := gs[ev.G]; g != nil {
+				name = g.Name
+				pc = g.PC
+			}
+			title := fmt.Sprintf("G%v %v %v", ev.G, name, time.Duration(to-ev.Ts))
+			if ev.Link != nil {
+				title += "\nend: " + trace.EventDescriptions[ev.Link.Type].Name
+			}
+			addSlice(r, ev.Ts, to, goroutineColor(pc), title)
+		case trace.EvGoUnblock, trace.EvGoSysExit:
+			if ev.P < trace.FakeP || ev.Ts < start || ev.Ts > end {
+				break
+			}
+			g := ev.G
+			if ev.Type == trace.EvGoUnblock {
+				g = ev.Args[0]
+			}
+			if related != nil && !related[g] {
+				break
+			}
+			title := fmt.Sprintf("%v G%v", trace.EventDescriptions[ev.Type].Name, g)
+			d.Marks = append(d.Marks, mark{X: x(ev.Ts), Y: y(row[ev.P]) + 2, Title: title})
+		}
+	}
+
+	// Time axis.
+	const ticks = 8
+	for i := 0; i <= ticks; i++ {
+		ts := start + span*int64(i)/ticks
+		d.Axis = append(d.Axis, label{X: x(ts), Y: axisHeight - 6, Text: time.Duration(ts).String()})
+	}
+	return d
+}
+
+func fakePName(p int) string {
+	switch p {
+	case trace.TimerP:
+		return "Timers"
+	case trace.NetpollP:
+		return "Network"
+	case trace.SyscallP:
+		return "Syscalls"
+	}
+	return fmt.Sprintf("Proc %v", p)
+}
+
+// goroutineColor returns a color for goroutines that start at pc,
+// so that goroutines running the same function look alike.
+func goroutineColor(pc uint64) string {
+	h := pc * 0x9E3779B97F4A7C15
+	return fmt.Sprintf("hsl(%d, 60%%, 65%%)", h>>32%360)
+}
+
+var templTimeline = template.Must(template.New("").Parse(`
+<html>
+<head>
+<title>Timeline</title>
+<style>
+rect.slice:hover { stroke: black; }
+</style>
+</head>
+<body>
+<a href="/">Main</a> |
+<a href="/timeline?start={{index .Prev 0}}&end={{index .Prev 1}}&goid={{.Goid}}">&larr; Earlier</a> |
+<a href="/timeline?start={{index .ZoomIn 0}}&end={{index .ZoomIn 1}}&goid={{.Goid}}">Zoom in</a> |
+<a href="/timeline?start={{index .ZoomOut 0}}&end={{index .ZoomOut 1}}&goid={{.Goid}}">Zoom out</a> |
+<a href="/timeline?start={{index .Next 0}}&end={{index .Next 1}}&goid={{.Goid}}">Later &rarr;</a>
+{{if .Goid}}| showing goroutine {{.Goid}} and related goroutines{{end}}
+<br>
+<svg width="{{.Width}}" height="{{.Height}}" font-family="sans-serif" font-size="11">
+{{range .Rows}}<text x="2" y="{{.Y}}">{{.Text}}</text>
+{{end}}
+{{range .Axis}}<text x="{{.X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+<line x1="{{.X}}" y1="{{.Y}}" x2="{{.X}}" y2="{{$.Height}}" stroke="#eeeeee"/>
+{{end}}
+{{range .Slices}}<rect class="slice" x="{{.X}}" y="{{.Y}}" width="{{.W}}" height="16" fill="{{.Color}}"><title>{{.Title}}</title></rect>
+{{end}}
+{{range .Marks}}<rect x="{{.X}}" y="{{.Y}}" width="1" height="16" fill="black"><title>{{.Title}}</title></rect>
+{{end}}
+</svg>
+</body>
+</html>
+`))
@@ -0,0 +1,253 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package context
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The tests are written against testingT rather than *testing.T
+// and run from x_test.go, in package context_test: package testing
+// depends on context through runtime/pprof, so an internal test of
+// context cannot import it.
+type testingT interface {
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// otherContext is a Context that's not one of the types defined in
+// context.go. This lets us test code paths that differ based on the
+// underlying type of the Context.
+type otherContext struct {
+	Context
+}
+
+func XTestBackground(t testingT) {
+	c := Background()
+	if c == nil {
+		t.Fatalf("Background returned nil")
+	}
+	select {
+	case x := <-c.Done():
+		t.Errorf("<-c.Done() == %v want nothing (it should block)", x)
+	default:
+	}
+	if got, want := fmt.Sprint(c), "context.Background"; got != want {
+		t.Errorf("Background().String() = %q want %q", got, want)
+	}
+	if got, want := fmt.Sprint(TODO()), "context.TODO"; got != want {
+		t.Errorf("TODO().String() = %q want %q", got, want)
+	}
+}
+
+func XTestWithCancel(t testingT) {
+	c1, cancel := WithCancel(Background())
+
+	if got, want := fmt.Sprint(c1), "context.Background.WithCancel"; got != want {
+		t.Errorf("c1.String() = %q want %q", got, want)
+	}
+
+	o := otherContext{c1}
+	c2, _ := WithCancel(o)
+	contexts := []Context{c1, o, c2}
+
+	for i, c := range contexts {
+		if d := c.Done(); d == nil {
+			t.Errorf("c[%d].Done() == %v want non-nil", i, d)
+		}
+		if e := c.Err(); e != nil {
+			t.Errorf("c[%d].Err() == %v want nil", i, e)
+		}
+
+		select {
+		case x := <-c.Done():
+			t.Errorf("<-c.Done() == %v want nothing (it should block)", x)
+		default:
+		}
+	}
+
+	cancel()
+	time.Sleep(100 * time.Millisecond) // let cancelation propagate
+
+	for i, c := range contexts {
+		select {
+		case <-c.Done():
+		default:
+			t.Errorf("<-c[%d].Done() blocked, but shouldn't have", i)
+		}
+		if e := c.Err(); e != Canceled {
+			t.Errorf("c[%d].Err() == %v want %v", i, e, Canceled)
+		}
+	}
+}
+
+func XTestParentFinishesChild(t testingT) {
+	parent, cancel := WithCancel(Background())
+	cancelChild, stop := WithCancel(parent)
+	defer stop()
+	timerChild, stop := WithTimeout(parent, time.Hour)
+	defer stop()
+	valueChild := WithValue(parent, "key", "value")
+
+	pc := parent.(*cancelCtx)
+	pc.mu.Lock()
+	if len(pc.children) != 2 {
+		t.Errorf("parent has %d children, want 2", len(pc.children))
+	}
+	pc.mu.Unlock()
+
+	cancel()
+
+	for _, c := range []Context{cancelChild, timerChild, valueChild} {
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("%v was not canceled with its parent", c)
+		}
+		if e := c.Err(); e != Canceled {
+			t.Errorf("%v.Err() == %v want %v", c, e, Canceled)
+		}
+	}
+
+	pc.mu.Lock()
+	if pc.children != nil {
+		t.Errorf("canceled parent still has children: %v", pc.children)
+	}
+	pc.mu.Unlock()
+
+	// A child derived from a canceled parent is canceled immediately.
+	late, stop := WithCancel(parent)
+	defer stop()
+	select {
+	case <-late.Done():
+	default:
+		t.Errorf("child of canceled parent was not canceled")
+	}
+}
+
+func XTestChildFinishesFirst(t testingT) {
+	parent, cancel := WithCancel(Background())
+	defer cancel()
+	child, stop := WithCancel(parent)
+	stop()
+
+	pc := parent.(*cancelCtx)
+	pc.mu.Lock()
+	if len(pc.children) != 0 {
+		t.Errorf("parent still has %d children after child canceled", len(pc.children))
+	}
+	pc.mu.Unlock()
+
+	select {
+	case <-parent.Done():
+		t.Errorf("parent was canceled by its child")
+	default:
+	}
+	if e := child.Err(); e != Canceled {
+		t.Errorf("child.Err() == %v want %v", e, Canceled)
+	}
+}
+
+func testDeadline(c Context, wait time.Duration, t testingT) {
+	select {
+	case <-time.After(wait):
+		t.Fatalf("context should have timed out")
+	case <-c.Done():
+	}
+	if e := c.Err(); e != DeadlineExceeded {
+		t.Errorf("c.Err() == %v want %v", e, DeadlineExceeded)
+	}
+}
+
+func XTestDeadline(t testingT) {
+	c, _ := WithDeadline(Background(), time.Now().Add(100*time.Millisecond))
+	if got, prefix := fmt.Sprint(c), "context.Background.WithDeadline("; !strings.HasPrefix(got, prefix) {
+		t.Errorf("c.String() = %q want prefix %q", got, prefix)
+	}
+	testDeadline(c, time.Second, t)
+
+	c, _ = WithDeadline(Background(), time.Now().Add(100*time.Millisecond))
+	o := otherContext{c}
+	testDeadline(o, time.Second, t)
+
+	c, _ = WithDeadline(Background(), time.Now().Add(100*time.Millisecond))
+	o = otherContext{c}
+	c, _ = WithDeadline(o, time.Now().Add(time.Hour))
+	testDeadline(c, time.Second, t)
+
+	c, _ = WithDeadline(Background(), time.Now().Add(-time.Hour))
+	testDeadline(c, time.Second, t)
+}
+
+func XTestTimeout(t testingT) {
+	c, _ := WithTimeout(Background(), 100*time.Millisecond)
+	if d, ok := c.Deadline(); !ok || d.After(time.Now().Add(100*time.Millisecond)) {
+		t.Errorf("c.Deadline() = %v, %v, want a deadline within 100ms", d, ok)
+	}
+	testDeadline(c, time.Second, t)
+}
+
+func XTestCanceledTimeout(t testingT) {
+	c, _ := WithTimeout(Background(), time.Second)
+	o := otherContext{c}
+	c, cancel := WithTimeout(o, 2*time.Second)
+	cancel()
+	time.Sleep(100 * time.Millisecond) // let cancelation propagate
+	select {
+	case <-c.Done():
+	default:
+		t.Errorf("<-c.Done() blocked, but shouldn't have")
+	}
+	if e := c.Err(); e != Canceled {
+		t.Errorf("c.Err() == %v want %v", e, Canceled)
+	}
+}
+
+type key1 int
+type key2 int
+
+var k1 = key1(1)
+var k2 = key2(1) // same int as k1, different type
+var k3 = key2(3) // same type as k2, different int
+
+func XTestValues(t testingT) {
+	check := func(c Context, nm, v1, v2, v3 string) {
+		if v, ok := c.Value(k1).(string); ok == (len(v1) == 0) || v != v1 {
+			t.Errorf(`%s.Value(k1).(string) = %q, %t want %q, %t`, nm, v, ok, v1, len(v1) != 0)
+		}
+		if v, ok := c.Value(k2).(string); ok == (len(v2) == 0) || v != v2 {
+			t.Errorf(`%s.Value(k2).(string) = %q, %t want %q, %t`, nm, v, ok, v2, len(v2) != 0)
+		}
+		if v, ok := c.Value(k3).(string); ok == (len(v3) == 0) || v != v3 {
+			t.Errorf(`%s.Value(k3).(string) = %q, %t want %q, %t`, nm, v, ok, v3, len(v3) != 0)
+		}
+	}
+
+	c0 := Background()
+	check(c0, "c0", "", "", "")
+
+	c1 := WithValue(Background(), k1, "c1k1")
+	check(c1, "c1", "c1k1", "", "")
+
+	c2 := WithValue(c1, k2, "c2k2")
+	check(c2, "c2", "c1k1", "c2k2", "")
+
+	c3 := WithValue(c2, k3, "c3k3")
+	check(c3, "c2", "c1k1", "c2k2", "c3k3")
+
+	c4 := WithValue(c3, k1, nil)
+	check(c4, "c4", "", "c2k2", "c3k3")
+
+	o0 := otherContext{Background()}
+	check(o0, "o0", "", "", "")
+
+	o1 := otherContext{WithValue(Background(), k1, "c1k1")}
+	check(o1, "o1", "c1k1", "", "")
+
+	o2 := WithValue(o1, k2, "o2k2")
+	check(o2, "o2", "c1k1", "o2k2", "")
+}
@@ -0,0 +1,19 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package context_test
+
+import (
+	. "context"
+	"testing"
+)
+
+func TestBackground(t *testing.T)          { XTestBackground(t) }
+func TestWithCancel(t *testing.T)          { XTestWithCancel(t) }
+func TestParentFinishesChild(t *testing.T) { XTestParentFinishesChild(t) }
+func TestChildFinishesFirst(t *testing.T)  { XTestChildFinishesFirst(t) }
+func TestDeadline(t *testing.T)            { XTestDeadline(t) }
+func TestTimeout(t *testing.T)             { XTestTimeout(t) }
+func TestCanceledTimeout(t *testing.T)     { XTestCanceledTimeout(t) }
+func TestValues(t *testing.T)              { XTestValues(t) }
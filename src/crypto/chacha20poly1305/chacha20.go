@@ -0,0 +1,88 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chacha20poly1305
+
+// chachaBlockSize is the size, in bytes, of a ChaCha20 keystream block.
+const chachaBlockSize = 64
+
+func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	a += b
+	d ^= a
+	d = d<<16 | d>>16
+	c += d
+	b ^= c
+	b = b<<12 | b>>20
+	a += b
+	d ^= a
+	d = d<<8 | d>>24
+	c += d
+	b ^= c
+	b = b<<7 | b>>25
+	return a, b, c, d
+}
+
+// chachaBlock writes the ChaCha20 keystream block for the given state to
+// out. See RFC 7539, section 2.3.
+func chachaBlock(out *[chachaBlockSize]byte, state *[16]uint32) {
+	x := *state
+	for i := 0; i < 10; i++ {
+		// Column rounds.
+		x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarterRound(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarterRound(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarterRound(x[3], x[7], x[11], x[15])
+		// Diagonal rounds.
+		x[0], x[5], x[10], x[15] = quarterRound(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarterRound(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarterRound(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarterRound(x[3], x[4], x[9], x[14])
+	}
+	for i, v := range x {
+		putUint32(out[4*i:], v+state[i])
+	}
+}
+
+// chachaXORKeyStream XORs in with the ChaCha20 keystream for the given key
+// and nonce, starting at block counter, and writes the result to out. out
+// must be at least as long as in. See RFC 7539, section 2.4.
+func chachaXORKeyStream(out, in []byte, key *[KeySize]byte, nonce []byte, counter uint32) {
+	var state [16]uint32
+	state[0] = 0x61707865
+	state[1] = 0x3320646e
+	state[2] = 0x79622d32
+	state[3] = 0x6b206574
+	for i := 0; i < 8; i++ {
+		state[4+i] = getUint32(key[4*i:])
+	}
+	state[12] = counter
+	state[13] = getUint32(nonce[0:])
+	state[14] = getUint32(nonce[4:])
+	state[15] = getUint32(nonce[8:])
+
+	var block [chachaBlockSize]byte
+	for len(in) > 0 {
+		chachaBlock(&block, &state)
+		n := len(in)
+		if n > chachaBlockSize {
+			n = chachaBlockSize
+		}
+		for i := 0; i < n; i++ {
+			out[i] = in[i] ^ block[i]
+		}
+		in, out = in[n:], out[n:]
+		state[12]++
+	}
+}
+
+func getUint32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putUint32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
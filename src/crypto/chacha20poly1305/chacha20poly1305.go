@@ -0,0 +1,136 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package chacha20poly1305 implements the ChaCha20-Poly1305 AEAD, as defined
+// in RFC 7539.
+//
+// ChaCha20-Poly1305 is a fast alternative to AES-GCM on platforms without
+// hardware support for AES.
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+const (
+	// KeySize is the size, in bytes, of a ChaCha20-Poly1305 key.
+	KeySize = 32
+	// NonceSize is the size, in bytes, of a ChaCha20-Poly1305 nonce.
+	NonceSize = 12
+)
+
+type chacha20poly1305 struct {
+	key [KeySize]byte
+}
+
+// New returns a ChaCha20-Poly1305 AEAD that uses the given 256-bit key.
+func New(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("chacha20poly1305: bad key length")
+	}
+	c := new(chacha20poly1305)
+	copy(c.key[:], key)
+	return c, nil
+}
+
+func (c *chacha20poly1305) NonceSize() int {
+	return NonceSize
+}
+
+func (c *chacha20poly1305) Overhead() int {
+	return poly1305TagSize
+}
+
+func (c *chacha20poly1305) Seal(dst, nonce, plaintext, data []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: incorrect nonce length given to ChaCha20-Poly1305")
+	}
+	if uint64(len(plaintext)) > (1<<38)-64 {
+		// The 32-bit block counter would wrap around.
+		panic("chacha20poly1305: plaintext too large")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+poly1305TagSize)
+	chachaXORKeyStream(out, plaintext, &c.key, nonce, 1)
+
+	var tag [poly1305TagSize]byte
+	c.auth(&tag, nonce, out[:len(plaintext)], data)
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+var errOpen = errors.New("chacha20poly1305: message authentication failed")
+
+func (c *chacha20poly1305) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: incorrect nonce length given to ChaCha20-Poly1305")
+	}
+
+	if len(ciphertext) < poly1305TagSize {
+		return nil, errOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-poly1305TagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-poly1305TagSize]
+
+	var expectedTag [poly1305TagSize]byte
+	c.auth(&expectedTag, nonce, ciphertext, data)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	if subtle.ConstantTimeCompare(expectedTag[:], tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+
+	chachaXORKeyStream(out, ciphertext, &c.key, nonce, 1)
+
+	return ret, nil
+}
+
+// auth computes the Poly1305 tag over the additional data and the
+// ciphertext, using a one-time key derived from the first ChaCha20 block.
+// See RFC 7539, section 2.8.
+func (c *chacha20poly1305) auth(out *[poly1305TagSize]byte, nonce, ciphertext, data []byte) {
+	var polyKey [32]byte
+	chachaXORKeyStream(polyKey[:], polyKey[:], &c.key, nonce, 0)
+
+	var zeros [15]byte
+	p := newPoly1305(&polyKey)
+	p.Write(data)
+	p.Write(zeros[:(16-len(data)%16)%16])
+	p.Write(ciphertext)
+	p.Write(zeros[:(16-len(ciphertext)%16)%16])
+
+	var lengths [16]byte
+	putUint64(lengths[0:], uint64(len(data)))
+	putUint64(lengths[8:], uint64(len(ciphertext)))
+	p.Write(lengths[:])
+	p.Sum(out)
+}
+
+func putUint64(b []byte, v uint64) {
+	putUint32(b[0:], uint32(v))
+	putUint32(b[4:], uint32(v>>32))
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes. If the
+// original slice has sufficient capacity then no allocation is performed.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
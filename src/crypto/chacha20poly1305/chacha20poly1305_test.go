@@ -0,0 +1,214 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chacha20poly1305
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The first test vector is taken from RFC 7539, section 2.8.2. The others
+// were generated with an independent implementation.
+var chacha20Poly1305Tests = []struct {
+	key, nonce, plaintext, ad, result string
+}{
+	{
+		"808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f",
+		"070000004041424344454647",
+		"4c616469657320616e642047656e746c656d656e206f662074686520636c6173" +
+			"73206f66202739393a204966204920636f756c64206f6666657220796f75206f" +
+			"6e6c79206f6e652074697020666f7220746865206675747572652c2073756e73" +
+			"637265656e20776f756c642062652069742e",
+		"50515253c0c1c2c3c4c5c6c7",
+		"d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d6" +
+			"3dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+			"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+			"3ff4def08e4b7a9de576d26586cec64b61161ae10b594f09e26a7e902ecbd060" +
+			"0691",
+	},
+	{
+		"52f22665a60c12d289185d950ee8813609166f6b113d178d6c0fd3901ff239a1",
+		"a095f20f9395650cf9380b8e",
+		"",
+		"",
+		"06aed975b89d364eb0ad2246ca360e41",
+	},
+	{
+		"db224a6b248a1e924e8fd0ae2e1a9492a3305f188cb610900f9e347fae886dc6",
+		"507795ec745c4c3fcb2eb2c7",
+		"3e",
+		"",
+		"1ed5b117a1f2e2563cff141f8742afba06",
+	},
+	{
+		"14934c867ee057ba72499bfa121e836b2ac15726ee7d6b0af6ab13c38e92cae0",
+		"d15057b159987f94cc7411d7",
+		"79b2aa100fbbb34fa593feaed27248",
+		"17f145",
+		"36161abe71d347bc12dc226586ecbfb11e86c180f5ed05c08431867a6d3639",
+	},
+	{
+		"b762e3ab5805f0765a2b9c1d7e0f37c44921bd3f6564eadf7f142a72668c47e2",
+		"23d16edd8c47b46afc5baee2",
+		"4801256b885e9c9051f320b0db83f39e",
+		"61f53b26152d263ba83b037cd4962e43",
+		"a341f94d6e64c381937aad96d6a754d09c3849ad568c9f9e9568e153ee619808",
+	},
+	{
+		"a7adbd0d74e6dec7f3dfaecc8f646566641a7ba2660f3011fc3570291c57990d",
+		"1a0091268919f25d9d0612df",
+		"9d6026a240f4589a5d791f1dd97cfefa77",
+		"35",
+		"c6d03f9a4524d3a836dc71cd51ec6f3fe684a8423b869b3ac3c8e2b0424c38ce" +
+			"88",
+	},
+	{
+		"7a7b4f15241abf57bd437ad4b129840534f3f3875c25b08bea06c2874cfaa4dd",
+		"17b2d842845de82a5bc53988",
+		"8ac78054a2399ccfc9fcc2da31ce3dd166bdcd3a33847e5bbb07fd07ca477842" +
+			"31b19af45872ceefb9fc59f4f95d14381a3a783256347b9ffce69cd7007ae8a7",
+		"",
+		"9a645210313d945e6e70b5070d02fad114a33301b67296c1d74554eb22a372f4" +
+			"77a285a9d8d467debe19ef55f88cfaa39b0d76b6732e5afeec5ad040b1575c0a" +
+			"e9dff0192a6415bd6455a18c4e8d503a",
+	},
+	{
+		"58cca415d5a91ee863c8b6c0337ae32d6fcaa25516cdf2f8b8657666bef215b9",
+		"282bfe20072697e777cea725",
+		"bfef236ffcdf31d3df360740364a803dc39653428b6bd5210fe8bd5ae575a995" +
+			"d0e7846bd3eae080218826868204df70c62e9b01c6cc262c24799eb91e8e0f53" +
+			"ae",
+		"9cd398fa79a8ef59278c8c210503ccf8b9a61a86",
+		"fa705d463891b28211842f1ba2f8d3e941cb510f06a8ff109be2e4d7cc689ecc" +
+			"861de473b0460a1e6665adaa057a359cc2f7e738f44c588f28b50452581ae8ab" +
+			"77851e5f884dc1bfee98c9eaf7c247f628",
+	},
+	{
+		"84878e7bc8c61be28f0e3f30460ac51981738f07c2e4e91071539cf9819b8333",
+		"b146738288ce7a81f13fb285",
+		"e4f133d772236a1f64715012ab3d6d1236ab4dc81fe5c627f0b7a4a95d2440e2" +
+			"23f77738bff31865e27c29fdaad53929b46efe8367566b325b5117b85d04568d" +
+			"7570b4046254849f4b83f5101cfcebc93af8e01a1543450ae7c72e45c121d16c" +
+			"d9e9add1f242672689eb83927eb35316470eccb02e6ce51244f004a216cd4215" +
+			"9b",
+		"e0e0f1ed42ec8f",
+		"a0fb5e8f90e142ba25cfddb480773cfe378f771669921e34ab2b3c274ca82695" +
+			"a22deef62cb1e153b3e03d388853f7f93ac7700e8417cf44e71cac5bbdc9426a" +
+			"ed81948b791c593e586b4af238f1ca2278f5845472970c78570ed110f881403f" +
+			"914cabedae575dded3dfb3102a76844a7029e3a92c5e932559f289c637d6e6ec" +
+			"7154941ab4be7e71cdd2f767872e8477ef",
+	},
+}
+
+func TestChaCha20Poly1305(t *testing.T) {
+	for i, test := range chacha20Poly1305Tests {
+		key, _ := hex.DecodeString(test.key)
+		nonce, _ := hex.DecodeString(test.nonce)
+		plaintext, _ := hex.DecodeString(test.plaintext)
+		ad, _ := hex.DecodeString(test.ad)
+
+		aead, err := New(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct := aead.Seal(nil, nonce, plaintext, ad)
+		if ctHex := hex.EncodeToString(ct); ctHex != test.result {
+			t.Errorf("#%d: got %s, want %s", i, ctHex, test.result)
+			continue
+		}
+
+		plaintext2, err := aead.Open(nil, nonce, ct, ad)
+		if err != nil {
+			t.Errorf("#%d: Open failed", i)
+			continue
+		}
+
+		if !bytes.Equal(plaintext, plaintext2) {
+			t.Errorf("#%d: plaintext's don't match: got %x vs %x", i, plaintext2, plaintext)
+			continue
+		}
+
+		if len(ad) > 0 {
+			ad[0] ^= 0x80
+			if _, err := aead.Open(nil, nonce, ct, ad); err == nil {
+				t.Errorf("#%d: Open was successful after altering additional data", i)
+			}
+			ad[0] ^= 0x80
+		}
+
+		nonce[0] ^= 0x80
+		if _, err := aead.Open(nil, nonce, ct, ad); err == nil {
+			t.Errorf("#%d: Open was successful after altering nonce", i)
+		}
+		nonce[0] ^= 0x80
+
+		ct[len(ct)-1] ^= 0x80
+		if _, err := aead.Open(nil, nonce, ct, ad); err == nil {
+			t.Errorf("#%d: Open was successful after altering ciphertext", i)
+		}
+		ct[len(ct)-1] ^= 0x80
+	}
+}
+
+func TestChaCha20Poly1305InPlace(t *testing.T) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+	aead, _ := New(key)
+
+	plaintext := []byte("hello, world")
+	buf := make([]byte, len(plaintext), len(plaintext)+aead.Overhead())
+	copy(buf, plaintext)
+	ct := aead.Seal(buf[:0], nonce, buf, nil)
+	if &ct[0] != &buf[0] {
+		t.Errorf("Seal did not reuse the destination buffer")
+	}
+	pt, err := aead.Open(ct[:0], nonce, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, plaintext) {
+		t.Errorf("got %q, want %q", pt, plaintext)
+	}
+}
+
+// TestPoly1305 uses the test vector from RFC 7539, section 2.5.2.
+func TestPoly1305(t *testing.T) {
+	key, _ := hex.DecodeString("85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b")
+	var k [32]byte
+	copy(k[:], key)
+
+	// Write the message in pieces to exercise buffering.
+	msg := []byte("Cryptographic Forum Research Group")
+	p := newPoly1305(&k)
+	p.Write(msg[:3])
+	p.Write(msg[3:20])
+	p.Write(msg[20:])
+
+	var tag [poly1305TagSize]byte
+	p.Sum(&tag)
+	if tagHex := hex.EncodeToString(tag[:]); tagHex != "a8061dc1305136c6c22b8baf0c0127a9" {
+		t.Errorf("got %s, want a8061dc1305136c6c22b8baf0c0127a9", tagHex)
+	}
+}
+
+func benchmarkSeal(b *testing.B, size int) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+	aead, _ := New(key)
+	plaintext := make([]byte, size)
+	out := make([]byte, 0, size+aead.Overhead())
+
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out = aead.Seal(out[:0], nonce, plaintext, nil)
+	}
+}
+
+func BenchmarkSeal64(b *testing.B) { benchmarkSeal(b, 64) }
+func BenchmarkSeal1K(b *testing.B) { benchmarkSeal(b, 1024) }
+func BenchmarkSeal8K(b *testing.B) { benchmarkSeal(b, 8192) }
@@ -0,0 +1,171 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chacha20poly1305
+
+// poly1305TagSize is the size, in bytes, of a Poly1305 authenticator.
+const poly1305TagSize = 16
+
+// poly1305 is a Poly1305 one-time authenticator, as defined in RFC 7539,
+// section 2.5. The accumulator and the key are held in 26-bit limbs so
+// that products fit in 64 bits.
+type poly1305 struct {
+	r   [5]uint32
+	h   [5]uint32
+	pad [4]uint32
+
+	buf    [16]byte
+	bufLen int
+}
+
+func newPoly1305(key *[32]byte) *poly1305 {
+	p := new(poly1305)
+	// r is clamped as required by the specification.
+	p.r[0] = getUint32(key[0:]) & 0x3ffffff
+	p.r[1] = (getUint32(key[3:]) >> 2) & 0x3ffff03
+	p.r[2] = (getUint32(key[6:]) >> 4) & 0x3ffc0ff
+	p.r[3] = (getUint32(key[9:]) >> 6) & 0x3f03fff
+	p.r[4] = (getUint32(key[12:]) >> 8) & 0x00fffff
+	for i := range p.pad {
+		p.pad[i] = getUint32(key[16+4*i:])
+	}
+	return p
+}
+
+// blocks adds the full 16-byte blocks of m to the accumulator. hibit is the
+// bit set just beyond the end of each block: 1<<24 for a full block, or
+// zero for a final block that has already been padded.
+func (p *poly1305) blocks(m []byte, hibit uint32) {
+	r0, r1, r2, r3, r4 := uint64(p.r[0]), uint64(p.r[1]), uint64(p.r[2]), uint64(p.r[3]), uint64(p.r[4])
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+	h0, h1, h2, h3, h4 := p.h[0], p.h[1], p.h[2], p.h[3], p.h[4]
+
+	for len(m) >= 16 {
+		h0 += getUint32(m[0:]) & 0x3ffffff
+		h1 += (getUint32(m[3:]) >> 2) & 0x3ffffff
+		h2 += (getUint32(m[6:]) >> 4) & 0x3ffffff
+		h3 += (getUint32(m[9:]) >> 6) & 0x3ffffff
+		h4 += (getUint32(m[12:]) >> 8) | hibit
+
+		d0 := uint64(h0)*r0 + uint64(h1)*s4 + uint64(h2)*s3 + uint64(h3)*s2 + uint64(h4)*s1
+		d1 := uint64(h0)*r1 + uint64(h1)*r0 + uint64(h2)*s4 + uint64(h3)*s3 + uint64(h4)*s2
+		d2 := uint64(h0)*r2 + uint64(h1)*r1 + uint64(h2)*r0 + uint64(h3)*s4 + uint64(h4)*s3
+		d3 := uint64(h0)*r3 + uint64(h1)*r2 + uint64(h2)*r1 + uint64(h3)*r0 + uint64(h4)*s4
+		d4 := uint64(h0)*r4 + uint64(h1)*r3 + uint64(h2)*r2 + uint64(h3)*r1 + uint64(h4)*r0
+
+		c := d0 >> 26
+		h0 = uint32(d0) & 0x3ffffff
+		d1 += c
+		c = d1 >> 26
+		h1 = uint32(d1) & 0x3ffffff
+		d2 += c
+		c = d2 >> 26
+		h2 = uint32(d2) & 0x3ffffff
+		d3 += c
+		c = d3 >> 26
+		h3 = uint32(d3) & 0x3ffffff
+		d4 += c
+		c = d4 >> 26
+		h4 = uint32(d4) & 0x3ffffff
+		h0 += uint32(c) * 5
+		h1 += h0 >> 26
+		h0 &= 0x3ffffff
+
+		m = m[16:]
+	}
+
+	p.h[0], p.h[1], p.h[2], p.h[3], p.h[4] = h0, h1, h2, h3, h4
+}
+
+// Write adds m to the authenticated message.
+func (p *poly1305) Write(m []byte) {
+	if p.bufLen > 0 {
+		n := copy(p.buf[p.bufLen:], m)
+		p.bufLen += n
+		m = m[n:]
+		if p.bufLen < len(p.buf) {
+			return
+		}
+		p.blocks(p.buf[:], 1<<24)
+		p.bufLen = 0
+	}
+	if n := len(m) &^ 15; n > 0 {
+		p.blocks(m[:n], 1<<24)
+		m = m[n:]
+	}
+	p.bufLen = copy(p.buf[:], m)
+}
+
+// Sum writes the authenticator for the message written so far to out.
+func (p *poly1305) Sum(out *[poly1305TagSize]byte) {
+	if p.bufLen > 0 {
+		p.buf[p.bufLen] = 1
+		for i := p.bufLen + 1; i < len(p.buf); i++ {
+			p.buf[i] = 0
+		}
+		p.blocks(p.buf[:], 0)
+	}
+
+	h0, h1, h2, h3, h4 := p.h[0], p.h[1], p.h[2], p.h[3], p.h[4]
+
+	// Fully carry h.
+	c := h1 >> 26
+	h1 &= 0x3ffffff
+	h2 += c
+	c = h2 >> 26
+	h2 &= 0x3ffffff
+	h3 += c
+	c = h3 >> 26
+	h3 &= 0x3ffffff
+	h4 += c
+	c = h4 >> 26
+	h4 &= 0x3ffffff
+	h0 += c * 5
+	c = h0 >> 26
+	h0 &= 0x3ffffff
+	h1 += c
+
+	// Compute h + -p and select it, in constant time, if h >= p.
+	g0 := h0 + 5
+	c = g0 >> 26
+	g0 &= 0x3ffffff
+	g1 := h1 + c
+	c = g1 >> 26
+	g1 &= 0x3ffffff
+	g2 := h2 + c
+	c = g2 >> 26
+	g2 &= 0x3ffffff
+	g3 := h3 + c
+	c = g3 >> 26
+	g3 &= 0x3ffffff
+	g4 := h4 + c - 1<<26
+
+	mask := (g4 >> 31) - 1
+	g0 &= mask
+	g1 &= mask
+	g2 &= mask
+	g3 &= mask
+	g4 &= mask
+	mask = ^mask
+	h0 = h0&mask | g0
+	h1 = h1&mask | g1
+	h2 = h2&mask | g2
+	h3 = h3&mask | g3
+	h4 = h4&mask | g4
+
+	// h = h % 2^128, then add the pad.
+	h0 = h0 | h1<<26
+	h1 = h1>>6 | h2<<20
+	h2 = h2>>12 | h3<<14
+	h3 = h3>>18 | h4<<8
+
+	f := uint64(h0) + uint64(p.pad[0])
+	putUint32(out[0:], uint32(f))
+	f = uint64(h1) + uint64(p.pad[1]) + f>>32
+	putUint32(out[4:], uint32(f))
+	f = uint64(h2) + uint64(p.pad[2]) + f>>32
+	putUint32(out[8:], uint32(f))
+	f = uint64(h3) + uint64(p.pad[3]) + f>>32
+	putUint32(out[12:], uint32(f))
+}
@@ -0,0 +1,215 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package curve25519 implements the X25519 Diffie-Hellman function over
+// Curve25519, as defined in RFC 7748.
+package curve25519
+
+// fieldElement represents an element of GF(2^255 - 19) as ten signed limbs
+// of alternately 26 and 25 bits, least significant first, so that limb i
+// has weight 2^ceil(25.5*i). Limbs may temporarily exceed their width
+// between carries. All operations run in constant time.
+type fieldElement [10]int64
+
+// limbWidth returns the number of bits in limb i.
+func limbWidth(i int) uint {
+	return 26 - uint(i&1)
+}
+
+// feCarry propagates the carries of f, leaving limbs 1 to 9 within their
+// width. The carry out of the top limb is multiplied by 19, since
+// 2^255 = 19 mod p.
+func feCarry(f *fieldElement) {
+	for i := 0; i < 10; i++ {
+		w := limbWidth(i)
+		c := f[i] >> w
+		f[i] -= c << w
+		if i < 9 {
+			f[i+1] += c
+		} else {
+			f[0] += 19 * c
+		}
+	}
+}
+
+// feCSwap swaps f and g if b is 1, and leaves them unchanged if b is 0.
+func feCSwap(f, g *fieldElement, b int64) {
+	mask := -b
+	for i := range f {
+		t := mask & (f[i] ^ g[i])
+		f[i] ^= t
+		g[i] ^= t
+	}
+}
+
+func feAdd(out, a, b *fieldElement) {
+	for i := range out {
+		out[i] = a[i] + b[i]
+	}
+}
+
+func feSub(out, a, b *fieldElement) {
+	for i := range out {
+		out[i] = a[i] - b[i]
+	}
+}
+
+// feMul sets out to a * b. The inputs must be carried, or the sum or
+// difference of carried values, so that the products fit in 64 bits.
+func feMul(out, a, b *fieldElement) {
+	// When both limbs are odd their weights sum to one more bit than
+	// that of limb i+j, so odd rows use b with its odd limbs doubled.
+	var b2 fieldElement
+	for j := range b {
+		b2[j] = b[j] << uint(j&1)
+	}
+
+	var t [19]int64
+	for i := 0; i < 10; i++ {
+		bj := b
+		if i&1 == 1 {
+			bj = &b2
+		}
+		ai := a[i]
+		for j := 0; j < 10; j++ {
+			t[i+j] += ai * bj[j]
+		}
+	}
+	for i := 0; i < 9; i++ {
+		t[i] += 19 * t[i+10]
+	}
+	copy(out[:], t[:10])
+	feCarry(out)
+	feCarry(out)
+}
+
+func feSquare(out, a *fieldElement) {
+	feMul(out, a, a)
+}
+
+// feInvert sets out to the inverse of z, computed as z^(p-2).
+func feInvert(out, z *fieldElement) {
+	c := *z
+	for i := 253; i >= 0; i-- {
+		feSquare(&c, &c)
+		if i != 2 && i != 4 {
+			feMul(&c, &c, z)
+		}
+	}
+	*out = c
+}
+
+func feFromBytes(out *fieldElement, in *[32]byte) {
+	// The most significant bit of the u-coordinate is ignored, because
+	// the top limb ends at bit 254.
+	off := uint(0)
+	for i := range out {
+		w := limbWidth(i)
+		var v int64
+		for b := uint(0); b < 5 && off/8+b < 32; b++ {
+			v |= int64(in[off/8+b]) << (8 * b)
+		}
+		out[i] = (v >> (off % 8)) & (1<<w - 1)
+		off += w
+	}
+}
+
+// feToBytes writes the canonical encoding of f, fully reduced mod p, to out.
+func feToBytes(out *[32]byte, f *fieldElement) {
+	h := *f
+	feCarry(&h)
+	feCarry(&h)
+	feCarry(&h)
+
+	// Now 0 <= h < 2^255. Compute h + 19; if that reaches 2^255 then
+	// h >= p, and the reduced value is h + 19 - 2^255.
+	t := h
+	t[0] += 19
+	for i := 0; i < 9; i++ {
+		w := limbWidth(i)
+		t[i+1] += t[i] >> w
+		t[i] &= 1<<w - 1
+	}
+	overflow := t[9] >> 25
+	t[9] &= 1<<25 - 1
+	feCSwap(&h, &t, overflow)
+
+	var acc uint64
+	var accBits uint
+	n := 0
+	for i := range h {
+		acc |= uint64(h[i]) << accBits
+		accBits += limbWidth(i)
+		for accBits >= 8 {
+			out[n] = byte(acc)
+			n++
+			acc >>= 8
+			accBits -= 8
+		}
+	}
+	out[n] = byte(acc)
+}
+
+// basePoint is the u-coordinate of the standard generator of Curve25519.
+var basePoint = [32]byte{9}
+
+// ScalarMult sets dst to the product scalar * point, where point is the
+// u-coordinate of a point on Curve25519. The scalar is clamped as described
+// in RFC 7748, section 5.
+func ScalarMult(dst, scalar, point *[32]byte) {
+	e := *scalar
+	e[0] &= 248
+	e[31] &= 127
+	e[31] |= 64
+
+	var x1, x2, z2, x3, z3, tmp0, tmp1 fieldElement
+	feFromBytes(&x1, point)
+	x2[0] = 1
+	x3 = x1
+	z3[0] = 1
+
+	// a24 is (486662 - 2) / 4, the curve constant used by the ladder.
+	a24 := fieldElement{121665}
+
+	// This is the Montgomery ladder from RFC 7748, section 5, swapping
+	// the working points in and out for each bit of the scalar.
+	for pos := 254; pos >= 0; pos-- {
+		b := int64(e[pos/8]>>uint(pos&7)) & 1
+		feCSwap(&x2, &x3, b)
+		feCSwap(&z2, &z3, b)
+
+		feAdd(&tmp0, &x2, &z2)     // A = x2 + z2
+		feSub(&tmp1, &x2, &z2)     // B = x2 - z2
+		feAdd(&x2, &x3, &z3)       // C = x3 + z3
+		feSub(&z2, &x3, &z3)       // D = x3 - z3
+		feMul(&z3, &z2, &tmp0)     // DA = D * A
+		feMul(&z2, &x2, &tmp1)     // CB = C * B
+		feSquare(&tmp0, &tmp0)     // AA = A^2
+		feSquare(&tmp1, &tmp1)     // BB = B^2
+		feAdd(&x3, &z3, &z2)       // DA + CB
+		feSub(&z2, &z3, &z2)       // DA - CB
+		feSquare(&x3, &x3)         // x3 = (DA + CB)^2
+		feSquare(&z2, &z2)         // (DA - CB)^2
+		feMul(&z3, &x1, &z2)       // z3 = x1 * (DA - CB)^2
+		feMul(&x2, &tmp0, &tmp1)   // x2 = AA * BB
+		feSub(&tmp1, &tmp0, &tmp1) // E = AA - BB
+		feMul(&z2, &a24, &tmp1)    // a24 * E
+		feAdd(&z2, &z2, &tmp0)     // AA + a24 * E
+		feMul(&z2, &z2, &tmp1)     // z2 = E * (AA + a24 * E)
+
+		feCSwap(&x2, &x3, b)
+		feCSwap(&z2, &z3, b)
+	}
+
+	feInvert(&z2, &z2)
+	feMul(&x2, &x2, &z2)
+	feToBytes(dst, &x2)
+}
+
+// ScalarBaseMult sets dst to the product scalar * base, where base is the
+// standard generator. The result is the X25519 public key for the private
+// key scalar.
+func ScalarBaseMult(dst, scalar *[32]byte) {
+	ScalarMult(dst, scalar, &basePoint)
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package curve25519
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 7748, section 5.2.
+var scalarMultTests = []struct {
+	scalar, point, result string
+}{
+	{
+		"a546e36bf0527c9d3b16154b82465edd62144c0ac1fc5a18506a2244ba449ac4",
+		"e6db6867583030db3594c1a424b15f7c726624ec26b3353b10a903a6d0ab1c4c",
+		"c3da55379de9c6908e94ea4df28d084f32eccf03491c71f754b4075577a28552",
+	},
+	{
+		"4b66e9d4d1b4673c5ad22691957d6af5c11b6421e0ea01d42ca4169e7918ba0d",
+		"e5210f12786811d3f4b7959d0538ae2c31dbe7106fc03c3efc4cd549c715a493",
+		"95cbde9476e8907d7aade45cb4b873f88b595a68799fa152e6f8f7647aac7957",
+	},
+}
+
+func fromHex(s string) *[32]byte {
+	var out [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		panic("bad test vector: " + s)
+	}
+	copy(out[:], b)
+	return &out
+}
+
+func TestScalarMult(t *testing.T) {
+	for i, test := range scalarMultTests {
+		var out [32]byte
+		ScalarMult(&out, fromHex(test.scalar), fromHex(test.point))
+		if got := hex.EncodeToString(out[:]); got != test.result {
+			t.Errorf("#%d: got %s, want %s", i, got, test.result)
+		}
+	}
+}
+
+// TestIterated runs the iterated test from RFC 7748, section 5.2, in which
+// the output of each step becomes the scalar of the next and the previous
+// scalar becomes the point.
+func TestIterated(t *testing.T) {
+	k := basePoint
+	u := basePoint
+	var out [32]byte
+	for i := 1; i <= 1000; i++ {
+		ScalarMult(&out, &k, &u)
+		u, k = k, out
+		switch i {
+		case 1:
+			if got, want := hex.EncodeToString(k[:]), "422c8e7a6227d7bca1350b3e2bb7279f7897b87bb6854b783c60e80311ae3079"; got != want {
+				t.Fatalf("after one iteration got %s, want %s", got, want)
+			}
+		case 1000:
+			if got, want := hex.EncodeToString(k[:]), "684cf59ba83309552800ef566f2f4d3c1c3887c49360e3875f2eb94d99532c51"; got != want {
+				t.Fatalf("after 1000 iterations got %s, want %s", got, want)
+			}
+		}
+	}
+}
+
+// TestDiffieHellman uses the key agreement example from RFC 7748,
+// section 6.1.
+func TestDiffieHellman(t *testing.T) {
+	alicePrivate := fromHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	bobPrivate := fromHex("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb")
+
+	var alicePublic, bobPublic, aliceShared, bobShared [32]byte
+	ScalarBaseMult(&alicePublic, alicePrivate)
+	ScalarBaseMult(&bobPublic, bobPrivate)
+	if got, want := hex.EncodeToString(alicePublic[:]), "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"; got != want {
+		t.Errorf("Alice's public key is %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(bobPublic[:]), "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"; got != want {
+		t.Errorf("Bob's public key is %s, want %s", got, want)
+	}
+
+	ScalarMult(&aliceShared, alicePrivate, &bobPublic)
+	ScalarMult(&bobShared, bobPrivate, &alicePublic)
+	const want = "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742"
+	if got := hex.EncodeToString(aliceShared[:]); got != want {
+		t.Errorf("Alice's shared secret is %s, want %s", got, want)
+	}
+	if got := hex.EncodeToString(bobShared[:]); got != want {
+		t.Errorf("Bob's shared secret is %s, want %s", got, want)
+	}
+}
+
+func BenchmarkScalarBaseMult(b *testing.B) {
+	var in, out [32]byte
+	in[0] = 1
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ScalarBaseMult(&out, &in)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ed25519 implements the Ed25519 signature algorithm, as defined in
+// RFC 8032.
+//
+// Ed25519 signs messages directly rather than their digests, so Sign must
+// be passed crypto.Hash(0) as its options.
+package ed25519
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519/internal/edwards25519"
+	cryptorand "crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	// PublicKeySize is the size, in bytes, of public keys as used in this package.
+	PublicKeySize = 32
+	// PrivateKeySize is the size, in bytes, of private keys as used in this package.
+	PrivateKeySize = 64
+	// SignatureSize is the size, in bytes, of signatures generated and verified by this package.
+	SignatureSize = 64
+	// SeedSize is the size, in bytes, of private key seeds. These are the
+	// private key representations used by RFC 8032.
+	SeedSize = 32
+)
+
+// PublicKey is the type of Ed25519 public keys.
+type PublicKey []byte
+
+// PrivateKey is the type of Ed25519 private keys. It holds the seed
+// followed by the public key.
+type PrivateKey []byte
+
+// Public returns the PublicKey corresponding to priv.
+func (priv PrivateKey) Public() crypto.PublicKey {
+	publicKey := make([]byte, PublicKeySize)
+	copy(publicKey, priv[32:])
+	return PublicKey(publicKey)
+}
+
+// Seed returns the private key seed corresponding to priv. It is provided
+// for interoperability with RFC 8032, which represents private keys as
+// seeds.
+func (priv PrivateKey) Seed() []byte {
+	seed := make([]byte, SeedSize)
+	copy(seed, priv[:32])
+	return seed
+}
+
+// Sign signs the given message with priv. Ed25519 performs two passes over
+// messages to be signed and therefore cannot handle pre-hashed messages.
+// Thus opts.HashFunc() must return zero to indicate the message hasn't been
+// hashed. This can be achieved by passing crypto.Hash(0) as the value for
+// opts. The rand argument is ignored, since Ed25519 signatures are
+// deterministic.
+func (priv PrivateKey) Sign(rand io.Reader, message []byte, opts crypto.SignerOpts) (signature []byte, err error) {
+	if opts.HashFunc() != crypto.Hash(0) {
+		return nil, errors.New("ed25519: cannot sign hashed message")
+	}
+
+	return Sign(priv, message), nil
+}
+
+// GenerateKey generates a public/private key pair using entropy from rand.
+// If rand is nil, crypto/rand.Reader will be used.
+func GenerateKey(rand io.Reader) (PublicKey, PrivateKey, error) {
+	if rand == nil {
+		rand = cryptorand.Reader
+	}
+
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey := NewKeyFromSeed(seed)
+	publicKey := make([]byte, PublicKeySize)
+	copy(publicKey, privateKey[32:])
+
+	return publicKey, privateKey, nil
+}
+
+// NewKeyFromSeed calculates a private key from a seed. It will panic if
+// len(seed) is not SeedSize.
+func NewKeyFromSeed(seed []byte) PrivateKey {
+	if l := len(seed); l != SeedSize {
+		panic("ed25519: bad seed length: " + strconv.Itoa(l))
+	}
+
+	digest := sha512.Sum512(seed)
+	var a [32]byte
+	copy(a[:], digest[:32])
+	clamp(&a)
+
+	var A edwards25519.Point
+	var publicKey [32]byte
+	A.ScalarBaseMult(&a)
+	A.Bytes(&publicKey)
+
+	privateKey := make([]byte, PrivateKeySize)
+	copy(privateKey, seed)
+	copy(privateKey[32:], publicKey[:])
+	return privateKey
+}
+
+// clamp prepares the secret scalar as described in RFC 8032, section 5.1.5.
+func clamp(a *[32]byte) {
+	a[0] &= 248
+	a[31] &= 127
+	a[31] |= 64
+}
+
+// Sign signs the message with privateKey and returns a signature. It will
+// panic if len(privateKey) is not PrivateKeySize.
+func Sign(privateKey PrivateKey, message []byte) []byte {
+	if l := len(privateKey); l != PrivateKeySize {
+		panic("ed25519: bad private key length: " + strconv.Itoa(l))
+	}
+
+	digest := sha512.Sum512(privateKey[:32])
+	var a [32]byte
+	copy(a[:], digest[:32])
+	clamp(&a)
+
+	h := sha512.New()
+	h.Write(digest[32:])
+	h.Write(message)
+	var messageDigest, r [64]byte
+	h.Sum(messageDigest[:0])
+	var rReduced [32]byte
+	edwards25519.ScReduce(&rReduced, &messageDigest)
+
+	var R edwards25519.Point
+	var encodedR [32]byte
+	R.ScalarBaseMult(&rReduced)
+	R.Bytes(&encodedR)
+
+	h.Reset()
+	h.Write(encodedR[:])
+	h.Write(privateKey[32:])
+	h.Write(message)
+	h.Sum(r[:0])
+	var k [32]byte
+	edwards25519.ScReduce(&k, &r)
+
+	var s [32]byte
+	edwards25519.ScMulAdd(&s, &k, &a, &rReduced)
+
+	signature := make([]byte, SignatureSize)
+	copy(signature, encodedR[:])
+	copy(signature[32:], s[:])
+	return signature
+}
+
+// Verify reports whether sig is a valid signature of message by publicKey.
+// It will panic if len(publicKey) is not PublicKeySize.
+func Verify(publicKey PublicKey, message, sig []byte) bool {
+	if l := len(publicKey); l != PublicKeySize {
+		panic("ed25519: bad public key length: " + strconv.Itoa(l))
+	}
+
+	if len(sig) != SignatureSize || sig[63]&224 != 0 {
+		return false
+	}
+
+	var encodedA [32]byte
+	copy(encodedA[:], publicKey)
+	var A edwards25519.Point
+	if !A.SetBytes(&encodedA) {
+		return false
+	}
+
+	var s [32]byte
+	copy(s[:], sig[32:])
+	if !edwards25519.ScMinimal(&s) {
+		return false
+	}
+
+	h := sha512.New()
+	h.Write(sig[:32])
+	h.Write(publicKey)
+	h.Write(message)
+	var digest [64]byte
+	h.Sum(digest[:0])
+	var k [32]byte
+	edwards25519.ScReduce(&k, &digest)
+
+	// Check that R = [s]B - [k]A.
+	var sB, kA, R edwards25519.Point
+	sB.ScalarBaseMult(&s)
+	kA.ScalarMult(&k, &A)
+	kA.Neg(&kA)
+	R.Add(&sB, &kA)
+
+	var checkR [32]byte
+	R.Bytes(&checkR)
+	return bytes.Equal(sig[:32], checkR[:])
+}
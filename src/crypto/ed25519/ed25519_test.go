@@ -0,0 +1,186 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ed25519
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// The first three vectors are from RFC 8032, section 7.1. The others were
+// generated with an independent implementation.
+var signTests = []struct {
+	seed, publicKey, message, signature string
+}{
+	{
+		"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		"",
+		"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+	},
+	{
+		"4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		"72",
+		"92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+	},
+	{
+		"c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+		"fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+		"af82",
+		"6291d657deec24024827e69c3abe01a30ce548a284743a445e3680d7db5ac3ac18ff9b538d16f290ae67f760984dc6594a7c15e9716ed28dc027beceea1ec40a",
+	},
+	{
+		"f41a344243b47421489b4c33aa72b531605526b8a6915edce4a06f365a34054a",
+		"6c9cb9754e3833665134dabc8cd96f397495df626c95f567e285bdc62386cc6f",
+		"",
+		"9b1ba4519868b03cad1936031c0e36f1b0d0a8448f57b5f58bf975dc95958008923ee02e1498352de9f786a28b4bbecc7ac58892a053fb4da9528a24087c3809",
+	},
+	{
+		"6c9f0397648e74567078b9ebf5a5509077153df2a34f4286f7344c9a4a1e9694",
+		"852b4a3ecf2ce30791d13c2c980aac8cba14a8e346724226b4637fd07fbc2380",
+		"b9",
+		"fa3913a2528cc118c4659f91601c6295fa21c394a7a2a1925399a8a3de856f9544381bb985b2b5a04f8e7c266af96add63cbc5b22bee3d61f8b23a415237ff06",
+	},
+	{
+		"82a9e66b89d0836034e534f2065e879ab5bd3c64570a983f8a424ea145bdb515",
+		"3fcadbcb84d12b7f4b26cc8da4a211380582dc36506a1c8eb7902dcdb744f4a9",
+		"c3541ec00bb3d200f606f897c0507a8537f20cbd4b356acc9e9d2293120a0a",
+		"20e60d90ffd42202631ce9a21a8416e613dc4be55a76c9ac2f8ac69759c0e03f4b6b3b021c2e7f87724641606d9d106953c34c126b7e91fe94dba9f10cd21d06",
+	},
+	{
+		"cbcf591fd126e217a702f5bf6bfb8b8702104292473db8a675aca1b7be7f553f",
+		"b80b34721f38971e5ef2882706d431fed310b3916242107714dd0595f4799073",
+		"fdc886d69d88af2df64e4b26d9ce96910e3dfb7bbc2eebd1ce3fc6cfaed5bb043a0e4c429b7712a09e2b7569d16168919ecf0cdbb1d0df5b138de8023b109c6c",
+		"54bfda14c6a848e3896cdca1f9c572adbac6d64b82a9dea2dd678f9b87b0720a667a09383b0cc8b79758c112f6adc05168880067c29f8bc40e840168b2aade06",
+	},
+	{
+		"2800e27c9657fa0a59ff6c619bcfccb1fb5a96a4c87a8a49ee511c398079e411",
+		"6c463e3f0ae4022d49a88e54b264cb8fd904fc45c0819c3c71407aa7ded6d24e",
+		"76d15d3c975b9c04640c5baba5e9ad208fee7068f8044b850f2d2360a857bf95a10bb6c1d1102344aab38015eafa89f2a677273ae19ef092d273a09b52b53d39d41504b257b95b3bc1be2ab27fdd8ae4066cd16393d3fa25491c273f5040ac3cd6a43649",
+		"0606d3c81be7660adc815e1c7245f11232f893815ad71519801672ab29e0ef73f2816f64143bb008242f978770ba539371ebd3a14f0e6bdd3832d60afb106c02",
+	},
+}
+
+func fromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSignVerify(t *testing.T) {
+	for i, test := range signTests {
+		priv := NewKeyFromSeed(fromHex(test.seed))
+		pub := priv.Public().(PublicKey)
+		if !bytes.Equal(pub, fromHex(test.publicKey)) {
+			t.Errorf("#%d: got public key %x, want %s", i, pub, test.publicKey)
+			continue
+		}
+		if !bytes.Equal(priv.Seed(), fromHex(test.seed)) {
+			t.Errorf("#%d: Seed returned %x, want %s", i, priv.Seed(), test.seed)
+		}
+
+		message := fromHex(test.message)
+		sig := Sign(priv, message)
+		if !bytes.Equal(sig, fromHex(test.signature)) {
+			t.Errorf("#%d: got signature %x, want %s", i, sig, test.signature)
+			continue
+		}
+		if !Verify(pub, message, sig) {
+			t.Errorf("#%d: valid signature rejected", i)
+		}
+
+		wrongMessage := append([]byte{0}, message...)
+		if Verify(pub, wrongMessage, sig) {
+			t.Errorf("#%d: signature of different message accepted", i)
+		}
+		for j := 0; j < len(sig); j += 7 {
+			sig[j] ^= 0x10
+			if Verify(pub, message, sig) {
+				t.Errorf("#%d: signature with byte %d modified accepted", i, j)
+			}
+			sig[j] ^= 0x10
+		}
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	pub, priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub) != PublicKeySize || len(priv) != PrivateKeySize {
+		t.Fatalf("got key sizes %d and %d", len(pub), len(priv))
+	}
+
+	message := []byte("test message")
+	sig := Sign(priv, message)
+	if !Verify(pub, message, sig) {
+		t.Error("valid signature rejected")
+	}
+}
+
+func TestCryptoSigner(t *testing.T) {
+	pub, priv, _ := GenerateKey(nil)
+
+	var signer crypto.Signer = priv
+	if !bytes.Equal(signer.Public().(PublicKey), pub) {
+		t.Fatalf("Public returned %x, want %x", signer.Public(), pub)
+	}
+
+	message := []byte("message")
+	sig, err := signer.Sign(nil, message, crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(pub, message, sig) {
+		t.Error("Verify failed on signature from Sign")
+	}
+
+	if _, err := signer.Sign(nil, message, crypto.SHA256); err == nil {
+		t.Error("Sign succeeded with a pre-hashed message")
+	}
+}
+
+func TestMalleability(t *testing.T) {
+	// The signature below is the first RFC 8032 test vector with l added
+	// to S. It must be rejected, see RFC 8032, section 5.1.7.
+	test := signTests[0]
+	sig := fromHex(test.signature)
+	l := fromHex("edd3f55c1a631258d69cf7a2def9de1400000000000000000000000000000010")
+	var carry int
+	for i := range l {
+		v := int(sig[32+i]) + int(l[i]) + carry
+		sig[32+i] = byte(v)
+		carry = v >> 8
+	}
+	if Verify(fromHex(test.publicKey), nil, sig) {
+		t.Error("signature with non-canonical S accepted")
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	_, priv, _ := GenerateKey(rand.Reader)
+	message := []byte("Hello, world!")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Sign(priv, message)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	pub, priv, _ := GenerateKey(rand.Reader)
+	message := []byte("Hello, world!")
+	sig := Sign(priv, message)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(pub, message, sig)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package edwards25519 implements group and scalar arithmetic on the
+// twisted Edwards curve -x^2 + y^2 = 1 + d*x^2*y^2 that is birationally
+// equivalent to Curve25519, as used by Ed25519. See RFC 8032, section 5.1.
+//
+// Group operations run in constant time, except for decoding a point,
+// which only ever handles public values.
+package edwards25519
+
+// Point is a point on the curve in extended coordinates (X:Y:Z:T), where
+// x = X/Z, y = Y/Z and x*y = T/Z. See "Twisted Edwards Curves Revisited",
+// Hisil, Wong, Carter and Dawson, 2008.
+type Point struct {
+	x, y, z, t fieldElement
+}
+
+var (
+	// d is the curve constant -121665/121666.
+	d fieldElement
+	// d2 is 2*d.
+	d2 fieldElement
+	// sqrtM1 is a square root of -1.
+	sqrtM1 fieldElement
+	// basePoint is the generator B from RFC 8032, section 5.1.
+	basePoint Point
+)
+
+func init() {
+	num := fieldElement{121665}
+	den := fieldElement{121666}
+	feInvert(&den, &den)
+	feMul(&d, &num, &den)
+	feNeg(&d, &d)
+	feAdd(&d2, &d, &d)
+
+	// sqrt(-1) = 2^((p-1)/4), where (p-1)/4 = 2^253 - 5.
+	two := fieldElement{2}
+	fePow(&sqrtM1, &two, 252, 2)
+
+	// B is the point with y = 4/5 and positive x.
+	b := [32]byte{0x58}
+	for i := 1; i < len(b); i++ {
+		b[i] = 0x66
+	}
+	if !basePoint.SetBytes(&b) {
+		panic("edwards25519: failed to decode base point")
+	}
+}
+
+// Zero sets p to the identity element (0, 1).
+func (p *Point) Zero() *Point {
+	feZero(&p.x)
+	feOne(&p.y)
+	feOne(&p.z)
+	feZero(&p.t)
+	return p
+}
+
+// Add sets p to a + b and returns p. It uses the complete addition formula
+// "add-2008-hwcd-3", which is also valid when a and b are equal.
+func (p *Point) Add(a, b *Point) *Point {
+	var A, B, C, D, E, F, G, H, t0 fieldElement
+
+	feSub(&A, &a.y, &a.x)
+	feSub(&t0, &b.y, &b.x)
+	feMul(&A, &A, &t0)
+	feAdd(&B, &a.y, &a.x)
+	feAdd(&t0, &b.y, &b.x)
+	feMul(&B, &B, &t0)
+	feMul(&C, &a.t, &b.t)
+	feMul(&C, &C, &d2)
+	feMul(&D, &a.z, &b.z)
+	feAdd(&D, &D, &D)
+	feSub(&E, &B, &A)
+	feSub(&F, &D, &C)
+	feAdd(&G, &D, &C)
+	feAdd(&H, &B, &A)
+
+	feMul(&p.x, &E, &F)
+	feMul(&p.y, &G, &H)
+	feMul(&p.t, &E, &H)
+	feMul(&p.z, &F, &G)
+	return p
+}
+
+// Neg sets p to -a and returns p.
+func (p *Point) Neg(a *Point) *Point {
+	feNeg(&p.x, &a.x)
+	p.y = a.y
+	p.z = a.z
+	feNeg(&p.t, &a.t)
+	return p
+}
+
+// cMove sets p to q if b is 1, and leaves it unchanged if b is 0.
+func (p *Point) cMove(q *Point, b int64) {
+	feCMove(&p.x, &q.x, b)
+	feCMove(&p.y, &q.y, b)
+	feCMove(&p.z, &q.z, b)
+	feCMove(&p.t, &q.t, b)
+}
+
+// ScalarMult sets p to s * q and returns p. s is a little-endian integer
+// and need not be reduced.
+func (p *Point) ScalarMult(s *[32]byte, q *Point) *Point {
+	var r, sum Point
+	r.Zero()
+	base := *q
+	for i := 255; i >= 0; i-- {
+		r.Add(&r, &r)
+		sum.Add(&r, &base)
+		r.cMove(&sum, int64(s[i/8]>>uint(i&7))&1)
+	}
+	*p = r
+	return p
+}
+
+// ScalarBaseMult sets p to s * B, where B is the standard generator, and
+// returns p.
+func (p *Point) ScalarBaseMult(s *[32]byte) *Point {
+	return p.ScalarMult(s, &basePoint)
+}
+
+// Bytes writes the 32-byte encoding of p to out: the y coordinate in
+// little-endian order, with the top bit holding the sign of x.
+func (p *Point) Bytes(out *[32]byte) {
+	var zInv, x, y fieldElement
+	feInvert(&zInv, &p.z)
+	feMul(&x, &p.x, &zInv)
+	feMul(&y, &p.y, &zInv)
+	feToBytes(out, &y)
+	out[31] |= byte(feIsNegative(&x) << 7)
+}
+
+// SetBytes sets p to the point encoded in b and reports whether b is a
+// valid encoding. It is not constant time. See RFC 8032, section 5.1.3.
+func (p *Point) SetBytes(b *[32]byte) bool {
+	var y fieldElement
+	feFromBytes(&y, b)
+
+	// Reject non-canonical encodings of y.
+	var yBytes [32]byte
+	feToBytes(&yBytes, &y)
+	for i := 0; i < 31; i++ {
+		if yBytes[i] != b[i] {
+			return false
+		}
+	}
+	if yBytes[31] != b[31]&0x7f {
+		return false
+	}
+
+	// x^2 = (y^2 - 1) / (d*y^2 + 1) = u / v.
+	var one, u, v, v3, vxx, x, t0 fieldElement
+	feOne(&one)
+	feSquare(&u, &y)
+	feMul(&v, &u, &d)
+	feSub(&u, &u, &one)
+	feAdd(&v, &v, &one)
+
+	// x = u * v^3 * (u * v^7)^((p-5)/8).
+	feSquare(&v3, &v)
+	feMul(&v3, &v3, &v)
+	feSquare(&x, &v3)
+	feMul(&x, &x, &v)
+	feMul(&x, &x, &u)
+	fePow22523(&x, &x)
+	feMul(&x, &x, &v3)
+	feMul(&x, &x, &u)
+
+	feSquare(&vxx, &x)
+	feMul(&vxx, &vxx, &v)
+	if feEqual(&vxx, &u) == 0 {
+		feNeg(&t0, &u)
+		if feEqual(&vxx, &t0) == 0 {
+			return false
+		}
+		feMul(&x, &x, &sqrtM1)
+	}
+
+	var zero fieldElement
+	sign := int64(b[31] >> 7)
+	if feEqual(&x, &zero) == 1 && sign == 1 {
+		return false
+	}
+	if feIsNegative(&x) != sign {
+		feNeg(&x, &x)
+	}
+
+	p.x = x
+	p.y = y
+	feOne(&p.z)
+	feMul(&p.t, &x, &y)
+	return true
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package edwards25519
+
+// fieldElement represents an element of GF(2^255 - 19) as ten signed limbs
+// of alternately 26 and 25 bits, least significant first, so that limb i
+// has weight 2^ceil(25.5*i). All operations run in constant time.
+//
+// This is the same representation as used by crypto/curve25519. Unlike
+// there, feAdd and feSub carry their result, so that any value produced by
+// this file is a valid input to feMul.
+type fieldElement [10]int64
+
+// limbWidth returns the number of bits in limb i.
+func limbWidth(i int) uint {
+	return 26 - uint(i&1)
+}
+
+// feCarry propagates the carries of f, leaving limbs 1 to 9 within their
+// width. The carry out of the top limb is multiplied by 19, since
+// 2^255 = 19 mod p.
+func feCarry(f *fieldElement) {
+	for i := 0; i < 10; i++ {
+		w := limbWidth(i)
+		c := f[i] >> w
+		f[i] -= c << w
+		if i < 9 {
+			f[i+1] += c
+		} else {
+			f[0] += 19 * c
+		}
+	}
+}
+
+func feZero(f *fieldElement) {
+	*f = fieldElement{}
+}
+
+func feOne(f *fieldElement) {
+	*f = fieldElement{1}
+}
+
+// feCMove sets f to g if b is 1, and leaves it unchanged if b is 0.
+func feCMove(f, g *fieldElement, b int64) {
+	mask := -b
+	for i := range f {
+		f[i] ^= mask & (f[i] ^ g[i])
+	}
+}
+
+func feAdd(out, a, b *fieldElement) {
+	for i := range out {
+		out[i] = a[i] + b[i]
+	}
+	feCarry(out)
+}
+
+func feSub(out, a, b *fieldElement) {
+	for i := range out {
+		out[i] = a[i] - b[i]
+	}
+	feCarry(out)
+}
+
+func feNeg(out, a *fieldElement) {
+	var zero fieldElement
+	feSub(out, &zero, a)
+}
+
+// feMul sets out to a * b.
+func feMul(out, a, b *fieldElement) {
+	// When both limbs are odd their weights sum to one more bit than
+	// that of limb i+j, so odd rows use b with its odd limbs doubled.
+	var b2 fieldElement
+	for j := range b {
+		b2[j] = b[j] << uint(j&1)
+	}
+
+	var t [19]int64
+	for i := 0; i < 10; i++ {
+		bj := b
+		if i&1 == 1 {
+			bj = &b2
+		}
+		ai := a[i]
+		for j := 0; j < 10; j++ {
+			t[i+j] += ai * bj[j]
+		}
+	}
+	for i := 0; i < 9; i++ {
+		t[i] += 19 * t[i+10]
+	}
+	copy(out[:], t[:10])
+	feCarry(out)
+	feCarry(out)
+}
+
+func feSquare(out, a *fieldElement) {
+	feMul(out, a, a)
+}
+
+// fePow sets out to z^e, where e is the public exponent whose binary
+// representation is topBit ones followed by the bits below, of which only
+// those listed in zeros are clear.
+func fePow(out, z *fieldElement, topBit int, zeros ...int) {
+	c := *z
+	for i := topBit - 1; i >= 0; i-- {
+		feSquare(&c, &c)
+		skip := false
+		for _, j := range zeros {
+			if i == j {
+				skip = true
+			}
+		}
+		if !skip {
+			feMul(&c, &c, z)
+		}
+	}
+	*out = c
+}
+
+// feInvert sets out to the inverse of z, computed as z^(p-2).
+func feInvert(out, z *fieldElement) {
+	// p - 2 = 2^255 - 21.
+	fePow(out, z, 254, 2, 4)
+}
+
+// fePow22523 sets out to z^((p-5)/8), which is used to compute square roots.
+func fePow22523(out, z *fieldElement) {
+	// (p - 5) / 8 = 2^252 - 3.
+	fePow(out, z, 251, 1)
+}
+
+func feFromBytes(out *fieldElement, in *[32]byte) {
+	// The most significant bit is ignored, because the top limb ends at
+	// bit 254.
+	off := uint(0)
+	for i := range out {
+		w := limbWidth(i)
+		var v int64
+		for b := uint(0); b < 5 && off/8+b < 32; b++ {
+			v |= int64(in[off/8+b]) << (8 * b)
+		}
+		out[i] = (v >> (off % 8)) & (1<<w - 1)
+		off += w
+	}
+}
+
+// feToBytes writes the canonical encoding of f, fully reduced mod p, to out.
+func feToBytes(out *[32]byte, f *fieldElement) {
+	h := *f
+	feCarry(&h)
+	feCarry(&h)
+	feCarry(&h)
+
+	// Now 0 <= h < 2^255. Compute h + 19; if that reaches 2^255 then
+	// h >= p, and the reduced value is h + 19 - 2^255.
+	t := h
+	t[0] += 19
+	for i := 0; i < 9; i++ {
+		w := limbWidth(i)
+		t[i+1] += t[i] >> w
+		t[i] &= 1<<w - 1
+	}
+	overflow := t[9] >> 25
+	t[9] &= 1<<25 - 1
+	feCMove(&h, &t, overflow)
+
+	var acc uint64
+	var accBits uint
+	n := 0
+	for i := range h {
+		acc |= uint64(h[i]) << accBits
+		accBits += limbWidth(i)
+		for accBits >= 8 {
+			out[n] = byte(acc)
+			n++
+			acc >>= 8
+			accBits -= 8
+		}
+	}
+	out[n] = byte(acc)
+}
+
+// feIsNegative returns 1 if the canonical encoding of f is odd, which RFC
+// 8032 calls negative, and 0 otherwise.
+func feIsNegative(f *fieldElement) int64 {
+	var s [32]byte
+	feToBytes(&s, f)
+	return int64(s[0] & 1)
+}
+
+// feEqual returns 1 if f and g represent the same element, and 0 otherwise.
+func feEqual(f, g *fieldElement) int64 {
+	var a, b [32]byte
+	feToBytes(&a, f)
+	feToBytes(&b, g)
+	var v byte
+	for i := range a {
+		v |= a[i] ^ b[i]
+	}
+	return int64((uint32(v) - 1) >> 31)
+}
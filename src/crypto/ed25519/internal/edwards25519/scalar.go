@@ -0,0 +1,106 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package edwards25519
+
+// Scalars are integers modulo the group order
+//
+//	l = 2^252 + 27742317777372353535851937790883648493,
+//
+// encoded as 32 little-endian bytes. Internally they are held in 32-bit
+// limbs, least significant first.
+
+// order is l in 32-bit limbs.
+var order = [8]uint64{
+	0x5cf5d3ed, 0x5812631a, 0xa2f79cd6, 0x14def9de,
+	0x00000000, 0x00000000, 0x00000000, 0x10000000,
+}
+
+// scReduceLimbs sets out to the n-limb little-endian value in mod l. It
+// processes one bit at a time, which keeps it simple and constant time.
+func scReduceLimbs(out *[32]byte, in []uint64) {
+	var r [8]uint64
+	for i := 32*len(in) - 1; i >= 0; i-- {
+		// r = 2r + bit. As r < l < 2^253, this cannot overflow.
+		bit := (in[i/32] >> uint(i%32)) & 1
+		for j := 7; j > 0; j-- {
+			r[j] = (r[j]<<1 | r[j-1]>>31) & 0xffffffff
+		}
+		r[0] = (r[0]<<1 | bit) & 0xffffffff
+
+		// If r >= l, subtract l. Since r < 2l, once is enough.
+		var t [8]uint64
+		var borrow uint64
+		for j := range r {
+			v := r[j] - order[j] - borrow
+			t[j] = v & 0xffffffff
+			borrow = (v >> 32) & 1
+		}
+		mask := borrow - 1 // all ones if there was no borrow
+		for j := range r {
+			r[j] = r[j]&^mask | t[j]&mask
+		}
+	}
+	for j, v := range r {
+		out[4*j] = byte(v)
+		out[4*j+1] = byte(v >> 8)
+		out[4*j+2] = byte(v >> 16)
+		out[4*j+3] = byte(v >> 24)
+	}
+}
+
+func loadLimbs(out []uint64, in []byte) {
+	for i := range out {
+		out[i] = uint64(in[4*i]) | uint64(in[4*i+1])<<8 | uint64(in[4*i+2])<<16 | uint64(in[4*i+3])<<24
+	}
+}
+
+// ScReduce sets out to s mod l, where s is a 64-byte little-endian integer.
+func ScReduce(out *[32]byte, s *[64]byte) {
+	var limbs [16]uint64
+	loadLimbs(limbs[:], s[:])
+	scReduceLimbs(out, limbs[:])
+}
+
+// ScMulAdd sets out to (a*b + c) mod l.
+func ScMulAdd(out, a, b, c *[32]byte) {
+	var al, bl, cl [8]uint64
+	loadLimbs(al[:], a[:])
+	loadLimbs(bl[:], b[:])
+	loadLimbs(cl[:], c[:])
+
+	var t [17]uint64
+	for i := range al {
+		var carry uint64
+		for j := range bl {
+			v := t[i+j] + al[i]*bl[j] + carry
+			t[i+j] = v & 0xffffffff
+			carry = v >> 32
+		}
+		t[i+8] += carry
+	}
+	var carry uint64
+	for i := range t {
+		v := t[i] + carry
+		if i < len(cl) {
+			v += cl[i]
+		}
+		t[i] = v & 0xffffffff
+		carry = v >> 32
+	}
+	scReduceLimbs(out, t[:16])
+}
+
+// ScMinimal reports whether s is the canonical encoding of a scalar, that
+// is, whether s < l.
+func ScMinimal(s *[32]byte) bool {
+	var limbs [8]uint64
+	loadLimbs(limbs[:], s[:])
+	for i := 7; i >= 0; i-- {
+		if limbs[i] != order[i] {
+			return limbs[i] < order[i]
+		}
+	}
+	return false
+}
@@ -22,6 +22,7 @@ const (
 	VersionTLS10 = 0x0301
 	VersionTLS11 = 0x0302
 	VersionTLS12 = 0x0303
+	VersionTLS13 = 0x0304
 )
 
 const (
@@ -30,10 +31,31 @@ const (
 	recordHeaderLen = 5            // record header length
 	maxHandshake    = 65536        // maximum handshake we support (protocol max is 16 MB)
 
+	// maxSessionTicketLifetime is the longest lifetime, in seconds, that a
+	// TLS 1.3 session ticket may have. See RFC 8446, section 4.6.1.
+	maxSessionTicketLifetime = 7 * 24 * 60 * 60
+
 	minVersion = VersionSSL30
 	maxVersion = VersionTLS12
 )
 
+// The last eight bytes of the server random of a TLS 1.3 capable server
+// that negotiates an earlier version. See RFC 8446, section 4.1.3.
+const (
+	downgradeCanaryTLS12 = "DOWNGRD\x01"
+	downgradeCanaryTLS11 = "DOWNGRD\x00"
+)
+
+// helloRetryRequestRandom is the Random value of a ServerHello that is
+// actually a HelloRetryRequest: the SHA-256 of "HelloRetryRequest". See RFC
+// 8446, section 4.1.3.
+var helloRetryRequestRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11,
+	0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e,
+	0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
 // TLS record types.
 type recordType uint8
 
@@ -46,18 +68,21 @@ const (
 
 // TLS handshake message types.
 const (
-	typeClientHello        uint8 = 1
-	typeServerHello        uint8 = 2
-	typeNewSessionTicket   uint8 = 4
-	typeCertificate        uint8 = 11
-	typeServerKeyExchange  uint8 = 12
-	typeCertificateRequest uint8 = 13
-	typeServerHelloDone    uint8 = 14
-	typeCertificateVerify  uint8 = 15
-	typeClientKeyExchange  uint8 = 16
-	typeFinished           uint8 = 20
-	typeCertificateStatus  uint8 = 22
-	typeNextProtocol       uint8 = 67 // Not IANA assigned
+	typeClientHello         uint8 = 1
+	typeServerHello         uint8 = 2
+	typeNewSessionTicket    uint8 = 4
+	typeEncryptedExtensions uint8 = 8
+	typeCertificate         uint8 = 11
+	typeServerKeyExchange   uint8 = 12
+	typeCertificateRequest  uint8 = 13
+	typeServerHelloDone     uint8 = 14
+	typeCertificateVerify   uint8 = 15
+	typeClientKeyExchange   uint8 = 16
+	typeFinished            uint8 = 20
+	typeCertificateStatus   uint8 = 22
+	typeKeyUpdate           uint8 = 24
+	typeNextProtocol        uint8 = 67  // Not IANA assigned
+	typeMessageHash         uint8 = 254 // synthetic message
 )
 
 // TLS compression types.
@@ -67,15 +92,21 @@ const (
 
 // TLS extension numbers
 const (
-	extensionServerName          uint16 = 0
-	extensionStatusRequest       uint16 = 5
-	extensionSupportedCurves     uint16 = 10
-	extensionSupportedPoints     uint16 = 11
-	extensionSignatureAlgorithms uint16 = 13
-	extensionALPN                uint16 = 16
-	extensionSessionTicket       uint16 = 35
-	extensionNextProtoNeg        uint16 = 13172 // not IANA assigned
-	extensionRenegotiationInfo   uint16 = 0xff01
+	extensionServerName             uint16 = 0
+	extensionStatusRequest          uint16 = 5
+	extensionSupportedCurves        uint16 = 10
+	extensionSupportedPoints        uint16 = 11
+	extensionSignatureAlgorithms    uint16 = 13
+	extensionALPN                   uint16 = 16
+	extensionSessionTicket          uint16 = 35
+	extensionPreSharedKey           uint16 = 41
+	extensionSupportedVersions      uint16 = 43
+	extensionCookie                 uint16 = 44
+	extensionPSKModes               uint16 = 45
+	extensionCertificateAuthorities uint16 = 47
+	extensionKeyShare               uint16 = 51
+	extensionNextProtoNeg           uint16 = 13172 // not IANA assigned
+	extensionRenegotiationInfo      uint16 = 0xff01
 )
 
 // TLS signaling cipher suite values
@@ -91,6 +122,11 @@ const (
 	CurveP256 CurveID = 23
 	CurveP384 CurveID = 24
 	CurveP521 CurveID = 25
+
+	// X25519 is the Curve25519 Diffie-Hellman function of RFC 7748. It
+	// is not used by default and must be enabled through
+	// Config.CurvePreferences.
+	X25519 CurveID = 29
 )
 
 // TLS Elliptic Curve Point Formats
@@ -99,6 +135,18 @@ const (
 	pointFormatUncompressed uint8 = 0
 )
 
+// TLS 1.3 PSK Key Exchange Modes. See RFC 8446, section 4.2.9.
+const (
+	pskModePlain uint8 = 0
+	pskModeDHE   uint8 = 1
+)
+
+// TLS 1.3 Key Update requests. See RFC 8446, section 4.6.3.
+const (
+	keyUpdateNotRequested uint8 = 0
+	keyUpdateRequested    uint8 = 1
+)
+
 // TLS CertificateStatusType (RFC 3546)
 const (
 	statusTypeOCSP uint8 = 1
@@ -123,6 +171,13 @@ const (
 const (
 	hashSHA1   uint8 = 2
 	hashSHA256 uint8 = 4
+	hashSHA384 uint8 = 5
+	hashSHA512 uint8 = 6
+
+	// hashIntrinsic marks the TLS 1.3 signature schemes, such as
+	// RSASSA-PSS, whose hash is implied by the signature value. See RFC
+	// 8446, section 4.2.3.
+	hashIntrinsic uint8 = 8
 )
 
 // Signature algorithms for TLS 1.2 (See RFC 5246, section A.4.1)
@@ -131,6 +186,14 @@ const (
 	signatureECDSA uint8 = 3
 )
 
+// RSASSA-PSS and EdDSA signature algorithms, used with hashIntrinsic.
+const (
+	signatureRSAPSSSHA256 uint8 = 4
+	signatureRSAPSSSHA384 uint8 = 5
+	signatureRSAPSSSHA512 uint8 = 6
+	signatureEd25519      uint8 = 7
+)
+
 // signatureAndHash mirrors the TLS 1.2, SignatureAndHashAlgorithm struct. See
 // RFC 5246, section A.4.1.
 type signatureAndHash struct {
@@ -154,6 +217,19 @@ var supportedClientCertSignatureAlgorithms = []signatureAndHash{
 	{hashSHA256, signatureECDSA},
 }
 
+// supportedSignatureAlgorithmsTLS13 contains the signature schemes that the
+// code accepts and advertises for TLS 1.3 CertificateVerify messages. The
+// PKCS #1 v1.5 and SHA-1 based schemes are not allowed in TLS 1.3.
+var supportedSignatureAlgorithmsTLS13 = []signatureAndHash{
+	{hashIntrinsic, signatureRSAPSSSHA256},
+	{hashSHA256, signatureECDSA},
+	{hashIntrinsic, signatureRSAPSSSHA384},
+	{hashSHA384, signatureECDSA},
+	{hashIntrinsic, signatureRSAPSSSHA512},
+	{hashSHA512, signatureECDSA},
+	{hashIntrinsic, signatureEd25519},
+}
+
 // ConnectionState records basic TLS details about the connection.
 type ConnectionState struct {
 	Version                    uint16                // TLS version used by the connection (e.g. VersionTLS12)
@@ -167,8 +243,9 @@ type ConnectionState struct {
 	VerifiedChains             [][]*x509.Certificate // verified chains built from PeerCertificates
 
 	// TLSUnique contains the "tls-unique" channel binding value (see RFC
-	// 5929, section 3). For resumed sessions this value will be nil
-	// because resumption does not include enough context (see
+	// 5929, section 3). For resumed sessions and TLS 1.3 connections this
+	// value will be nil because resumption does not include enough
+	// context (see
 	// https://secure-resumption.com/#channelbindings). This will change in
 	// future versions of Go once the TLS master-secret fix has been
 	// standardized and implemented.
@@ -193,8 +270,13 @@ type ClientSessionState struct {
 	sessionTicket      []uint8             // Encrypted ticket used for session resumption with server
 	vers               uint16              // SSL/TLS version negotiated for the session
 	cipherSuite        uint16              // Ciphersuite negotiated for the session
-	masterSecret       []byte              // MasterSecret generated by client on a full handshake
+	masterSecret       []byte              // MasterSecret generated by client on a full handshake, or the TLS 1.3 resumption PSK
 	serverCertificates []*x509.Certificate // Certificate chain presented by the server
+
+	// TLS 1.3 only.
+	receivedAt time.Time // When the ticket was received from the server
+	lifetime   uint32    // Ticket lifetime in seconds, as sent by the server
+	ageAdd     uint32    // Obfuscation value for the ticket age
 }
 
 // ClientSessionCache is a cache of ClientSessionState objects that can be used
@@ -303,6 +385,8 @@ type Config struct {
 
 	// CipherSuites is a list of supported cipher suites. If CipherSuites
 	// is nil, TLS uses a list of suites supported by the implementation.
+	// The TLS 1.3 cipher suites are not configurable and are always
+	// enabled when TLS 1.3 is.
 	CipherSuites []uint16
 
 	// PreferServerCipherSuites controls whether the server selects the
@@ -334,8 +418,8 @@ type Config struct {
 	MinVersion uint16
 
 	// MaxVersion contains the maximum SSL/TLS version that is acceptable.
-	// If zero, then the maximum version supported by this package is used,
-	// which is currently TLS 1.2.
+	// If zero, then TLS 1.2 is used. TLS 1.3 is not enabled by default
+	// and must be selected by setting MaxVersion to VersionTLS13.
 	MaxVersion uint16
 
 	// CurvePreferences contains the elliptic curves that will be used in
@@ -410,6 +494,17 @@ func (c *Config) curvePreferences() []CurveID {
 	return c.CurvePreferences
 }
 
+// supportedVersions returns the versions, highest first, that are
+// advertised in the TLS 1.3 supported_versions extension. TLS 1.0 is the
+// minimum version supported as a client.
+func (c *Config) supportedVersions() []uint16 {
+	var versions []uint16
+	for v := c.maxVersion(); v >= c.minVersion() && v >= VersionTLS10; v-- {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
 // mutualVersion returns the protocol version to use given the advertised
 // version of the peer.
 func (c *Config) mutualVersion(vers uint16) (uint16, bool) {
@@ -425,6 +520,18 @@ func (c *Config) mutualVersion(vers uint16) (uint16, bool) {
 	return vers, true
 }
 
+// mutualVersionTLS13 returns the highest version in peerVersions, a
+// supported_versions list, that is within the configured range.
+func (c *Config) mutualVersionTLS13(peerVersions []uint16) (uint16, bool) {
+	var best uint16
+	for _, v := range peerVersions {
+		if v >= c.minVersion() && v <= c.maxVersion() && v > best {
+			best = v
+		}
+	}
+	return best, best != 0
+}
+
 // getCertificate returns the best certificate for the given ClientHelloInfo,
 // defaulting to the first element of c.Certificates.
 func (c *Config) getCertificate(clientHello *ClientHelloInfo) (*Certificate, error) {
@@ -488,10 +595,11 @@ func (c *Config) BuildNameToCertificate() {
 type Certificate struct {
 	Certificate [][]byte
 	// PrivateKey contains the private key corresponding to the public key
-	// in Leaf. For a server, this must be a *rsa.PrivateKey or
-	// *ecdsa.PrivateKey. For a client doing client authentication, this
-	// can be any type that implements crypto.Signer (which includes RSA
-	// and ECDSA private keys).
+	// in Leaf. For a server, this must be a *rsa.PrivateKey,
+	// *ecdsa.PrivateKey or, for TLS 1.3 only, an ed25519.PrivateKey. For
+	// a client doing client authentication, this can be any type that
+	// implements crypto.Signer (which includes RSA, ECDSA and Ed25519
+	// private keys).
 	PrivateKey crypto.PrivateKey
 	// OCSPStaple contains an optional OCSP response which will be served
 	// to clients that request it.
@@ -610,9 +718,12 @@ func defaultCipherSuites() []uint16 {
 }
 
 func initDefaultCipherSuites() {
-	varDefaultCipherSuites = make([]uint16, len(cipherSuites))
-	for i, suite := range cipherSuites {
-		varDefaultCipherSuites[i] = suite.id
+	varDefaultCipherSuites = make([]uint16, 0, len(cipherSuites))
+	for _, suite := range cipherSuites {
+		if suite.flags&suiteDefaultOff != 0 {
+			continue
+		}
+		varDefaultCipherSuites = append(varDefaultCipherSuites, suite.id)
 	}
 }
 
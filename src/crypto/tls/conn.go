@@ -49,12 +49,22 @@ type Conn struct {
 	clientProtocol         string
 	clientProtocolFallback bool
 
+	// resumptionSecret is the TLS 1.3 resumption master secret, from which
+	// the PSKs carried by NewSessionTickets are derived.
+	resumptionSecret []byte
+
 	// input/output
 	in, out  halfConn     // in.Mutex < out.Mutex
 	rawInput *block       // raw input, right off the wire
 	input    *block       // application data waiting to be read
 	hand     bytes.Buffer // handshake data waiting to be read
 
+	// buffering indicates whether records are buffered in sendBuf, rather
+	// than written directly to the connection, so that a TLS 1.3 flight
+	// is sent in a single write.
+	buffering bool
+	sendBuf   []byte // a buffer of records waiting to be sent
+
 	tmp [16]byte
 }
 
@@ -107,6 +117,8 @@ type halfConn struct {
 	nextCipher interface{} // next encryption state
 	nextMac    macFunction // next MAC algorithm
 
+	trafficSecret []byte // current TLS 1.3 traffic secret
+
 	// used to save allocating a new buffer for each MAC.
 	inDigestBuf, outDigestBuf []byte
 }
@@ -147,6 +159,17 @@ func (hc *halfConn) changeCipherSpec() error {
 	return nil
 }
 
+// setTrafficSecret switches to the TLS 1.3 record protection derived from
+// secret, which takes effect immediately.
+func (hc *halfConn) setTrafficSecret(suite *cipherSuiteTLS13, secret []byte) {
+	hc.trafficSecret = secret
+	key, iv := suite.trafficKey(secret)
+	hc.version = VersionTLS13
+	hc.cipher = suite.aead(key, iv)
+	hc.mac = nil
+	hc.resetSeq()
+}
+
 // incSeq increments the sequence number.
 func (hc *halfConn) incSeq() {
 	for i := 7; i >= 0; i-- {
@@ -253,13 +276,38 @@ func (hc *halfConn) decrypt(b *block) (ok bool, prefixLen int, alertValue alert)
 		switch c := hc.cipher.(type) {
 		case cipher.Stream:
 			c.XORKeyStream(payload, payload)
-		case cipher.AEAD:
-			explicitIVLen = 8
+		case aead:
+			if hc.version >= VersionTLS13 {
+				// TLS 1.3 uses the sequence number as the nonce
+				// and the record header as additional data.
+				var err error
+				payload, err = c.Open(payload[:0], hc.seq[:], payload, b.data[:recordHeaderLen])
+				if err != nil {
+					return false, 0, alertBadRecordMAC
+				}
+				// Strip the padding and recover the real content
+				// type from the end of the plaintext.
+				i := len(payload) - 1
+				for i >= 0 && payload[i] == 0 {
+					i--
+				}
+				if i < 0 {
+					return false, 0, alertUnexpectedMessage
+				}
+				b.data[0] = payload[i]
+				b.resize(recordHeaderLen + i)
+				break
+			}
+
+			explicitIVLen = c.explicitNonceLen()
 			if len(payload) < explicitIVLen {
 				return false, 0, alertBadRecordMAC
 			}
-			nonce := payload[:8]
-			payload = payload[8:]
+			nonce := payload[:explicitIVLen]
+			if len(nonce) == 0 {
+				nonce = hc.seq[:]
+			}
+			payload = payload[explicitIVLen:]
 
 			var additionalData [13]byte
 			copy(additionalData[:], hc.seq[:])
@@ -370,10 +418,26 @@ func (hc *halfConn) encrypt(b *block, explicitIVLen int) (bool, alert) {
 		switch c := hc.cipher.(type) {
 		case cipher.Stream:
 			c.XORKeyStream(payload, payload)
-		case cipher.AEAD:
+		case aead:
+			if hc.version >= VersionTLS13 {
+				// The record header, which already has to carry
+				// the final length, is the additional data.
+				payloadLen := len(b.data) - recordHeaderLen
+				b.resize(len(b.data) + c.Overhead())
+				n := len(b.data) - recordHeaderLen
+				b.data[3] = byte(n >> 8)
+				b.data[4] = byte(n)
+				payload := b.data[recordHeaderLen : recordHeaderLen+payloadLen]
+				c.Seal(payload[:0], hc.seq[:], payload, b.data[:recordHeaderLen])
+				break
+			}
+
 			payloadLen := len(b.data) - recordHeaderLen - explicitIVLen
 			b.resize(len(b.data) + c.Overhead())
 			nonce := b.data[recordHeaderLen : recordHeaderLen+explicitIVLen]
+			if len(nonce) == 0 {
+				nonce = hc.seq[:]
+			}
 			payload := b.data[recordHeaderLen+explicitIVLen:]
 			payload = payload[:payloadLen]
 
@@ -518,7 +582,9 @@ func (c *Conn) readRecord(want recordType) error {
 		c.sendAlert(alertInternalError)
 		return c.in.setErrorLocked(errors.New("tls: unknown record type requested"))
 	case recordTypeHandshake, recordTypeChangeCipherSpec:
-		if c.handshakeComplete {
+		// TLS 1.3 has handshake messages after the handshake, but
+		// never a ChangeCipherSpec.
+		if c.handshakeComplete && !(want == recordTypeHandshake && c.vers == VersionTLS13) {
 			c.sendAlert(alertInternalError)
 			return c.in.setErrorLocked(errors.New("tls: handshake or ChangeCipherSpec requested after handshake complete"))
 		}
@@ -561,9 +627,14 @@ Again:
 
 	vers := uint16(b.data[1])<<8 | uint16(b.data[2])
 	n := int(b.data[3])<<8 | int(b.data[4])
-	if c.haveVers && vers != c.vers {
+	expectedVers := c.vers
+	if expectedVers == VersionTLS13 {
+		// TLS 1.3 records claim to be TLS 1.2.
+		expectedVers = VersionTLS12
+	}
+	if c.haveVers && vers != expectedVers {
 		c.sendAlert(alertProtocolVersion)
-		return c.in.setErrorLocked(fmt.Errorf("tls: received record with version %x when expecting version %x", vers, c.vers))
+		return c.in.setErrorLocked(fmt.Errorf("tls: received record with version %x when expecting version %x", vers, expectedVers))
 	}
 	if n > maxCiphertext {
 		c.sendAlert(alertRecordOverflow)
@@ -595,11 +666,29 @@ Again:
 
 	// Process message.
 	b, c.rawInput = c.in.splitBlock(b, recordHeaderLen+n)
+
+	if c.vers == VersionTLS13 && !c.handshakeComplete && typ == recordTypeChangeCipherSpec {
+		// TLS 1.3 peers may send an unencrypted ChangeCipherSpec
+		// during the handshake for middlebox compatibility. It has
+		// no meaning and is dropped.
+		if n != 1 || b.data[recordHeaderLen] != 1 {
+			c.in.freeBlock(b)
+			return c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
+		}
+		c.in.freeBlock(b)
+		goto Again
+	}
+	if c.in.version >= VersionTLS13 && c.in.cipher != nil && typ != recordTypeApplicationData {
+		c.in.freeBlock(b)
+		return c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
+	}
+
 	ok, off, err := c.in.decrypt(b)
 	if !ok {
 		c.in.setErrorLocked(c.sendAlert(err))
 	}
 	b.off = off
+	typ = recordType(b.data[0])
 	data := b.data[b.off:]
 	if len(data) > maxPlaintext {
 		err := c.sendAlert(alertRecordOverflow)
@@ -651,7 +740,7 @@ Again:
 
 	case recordTypeHandshake:
 		// TODO(rsc): Should at least pick off connection close.
-		if typ != want {
+		if typ != want && !(c.handshakeComplete && c.vers == VersionTLS13) {
 			return c.in.setErrorLocked(c.sendAlert(alertNoRenegotiation))
 		}
 		c.hand.Write(data)
@@ -674,6 +763,7 @@ func (c *Conn) sendAlertLocked(err alert) error {
 	}
 	c.tmp[1] = byte(err)
 	c.writeRecord(recordTypeAlert, c.tmp[0:2])
+	c.flush()
 	// closeNotify is a special case in that it isn't an error:
 	if err != alertCloseNotify {
 		return c.out.setErrorLocked(&net.OpError{Op: "local error", Err: err})
@@ -709,16 +799,16 @@ func (c *Conn) writeRecord(typ recordType, data []byte) (n int, err error) {
 				explicitIVLen = cbc.BlockSize()
 			}
 		}
-		if explicitIVLen == 0 {
-			if _, ok := c.out.cipher.(cipher.AEAD); ok {
-				explicitIVLen = 8
+		if explicitIVLen == 0 && c.out.version < VersionTLS13 {
+			if a, ok := c.out.cipher.(aead); ok {
+				explicitIVLen = a.explicitNonceLen()
 				// The AES-GCM construction in TLS has an
 				// explicit nonce so that the nonce can be
 				// random. However, the nonce is only 8 bytes
 				// which is too small for a secure, random
 				// nonce. Therefore we use the sequence number
 				// as the nonce.
-				explicitIVIsSeq = true
+				explicitIVIsSeq = explicitIVLen > 0
 			}
 		}
 		b.resize(recordHeaderLen + explicitIVLen + m)
@@ -728,6 +818,9 @@ func (c *Conn) writeRecord(typ recordType, data []byte) (n int, err error) {
 			// Some TLS servers fail if the record version is
 			// greater than TLS 1.0 for the initial ClientHello.
 			vers = VersionTLS10
+		} else if vers == VersionTLS13 {
+			// TLS 1.3 froze the record version at TLS 1.2.
+			vers = VersionTLS12
 		}
 		b.data[1] = byte(vers >> 8)
 		b.data[2] = byte(vers)
@@ -744,8 +837,19 @@ func (c *Conn) writeRecord(typ recordType, data []byte) (n int, err error) {
 			}
 		}
 		copy(b.data[recordHeaderLen+explicitIVLen:], data)
+		if c.out.version >= VersionTLS13 && c.out.cipher != nil {
+			// Protected TLS 1.3 records all look like application
+			// data; the real type follows the encrypted payload.
+			b.resize(len(b.data) + 1)
+			b.data[len(b.data)-1] = byte(typ)
+			b.data[0] = byte(recordTypeApplicationData)
+		}
 		c.out.encrypt(b, explicitIVLen)
-		_, err = c.conn.Write(b.data)
+		if c.buffering {
+			c.sendBuf = append(c.sendBuf, b.data...)
+		} else {
+			_, err = c.conn.Write(b.data)
+		}
 		if err != nil {
 			break
 		}
@@ -754,7 +858,7 @@ func (c *Conn) writeRecord(typ recordType, data []byte) (n int, err error) {
 	}
 	c.out.freeBlock(b)
 
-	if typ == recordTypeChangeCipherSpec {
+	if typ == recordTypeChangeCipherSpec && c.vers != VersionTLS13 {
 		err = c.out.changeCipherSpec()
 		if err != nil {
 			// Cannot call sendAlert directly,
@@ -768,6 +872,18 @@ func (c *Conn) writeRecord(typ recordType, data []byte) (n int, err error) {
 	return
 }
 
+// flush writes any records buffered in sendBuf to the connection.
+// c.out.Mutex <= L.
+func (c *Conn) flush() error {
+	if len(c.sendBuf) == 0 {
+		return nil
+	}
+
+	_, err := c.conn.Write(c.sendBuf)
+	c.sendBuf = nil
+	return err
+}
+
 // readHandshake reads the next handshake message from
 // the record layer.
 // c.in.Mutex < L; c.out.Mutex < L.
@@ -802,13 +918,27 @@ func (c *Conn) readHandshake() (interface{}, error) {
 	case typeServerHello:
 		m = new(serverHelloMsg)
 	case typeNewSessionTicket:
-		m = new(newSessionTicketMsg)
+		if c.vers == VersionTLS13 {
+			m = new(newSessionTicketMsgTLS13)
+		} else {
+			m = new(newSessionTicketMsg)
+		}
 	case typeCertificate:
-		m = new(certificateMsg)
+		if c.vers == VersionTLS13 {
+			m = new(certificateMsgTLS13)
+		} else {
+			m = new(certificateMsg)
+		}
 	case typeCertificateRequest:
-		m = &certificateRequestMsg{
-			hasSignatureAndHash: c.vers >= VersionTLS12,
+		if c.vers == VersionTLS13 {
+			m = new(certificateRequestMsgTLS13)
+		} else {
+			m = &certificateRequestMsg{
+				hasSignatureAndHash: c.vers >= VersionTLS12,
+			}
 		}
+	case typeEncryptedExtensions:
+		m = new(encryptedExtensionsMsg)
 	case typeCertificateStatus:
 		m = new(certificateStatusMsg)
 	case typeServerKeyExchange:
@@ -825,6 +955,8 @@ func (c *Conn) readHandshake() (interface{}, error) {
 		m = new(nextProtoMsg)
 	case typeFinished:
 		m = new(finishedMsg)
+	case typeKeyUpdate:
+		m = new(keyUpdateMsg)
 	default:
 		return nil, c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
 	}
@@ -840,6 +972,98 @@ func (c *Conn) readHandshake() (interface{}, error) {
 	return m, nil
 }
 
+// handlePostHandshakeMessage processes a handshake message that arrived
+// after the TLS 1.3 handshake completed.
+// c.in.Mutex <= L.
+func (c *Conn) handlePostHandshakeMessage() error {
+	msg, err := c.readHandshake()
+	if err != nil {
+		return err
+	}
+
+	switch msg := msg.(type) {
+	case *newSessionTicketMsgTLS13:
+		return c.handleNewSessionTicket(msg)
+	case *keyUpdateMsg:
+		return c.handleKeyUpdate(msg)
+	}
+
+	return c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
+}
+
+// handleNewSessionTicket stores a TLS 1.3 session ticket sent by the
+// server in the client session cache.
+func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
+	if !c.isClient {
+		return c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
+	}
+
+	if c.config.SessionTicketsDisabled || c.config.ClientSessionCache == nil {
+		return nil
+	}
+
+	// See RFC 8446, section 4.6.1.
+	if msg.lifetime == 0 {
+		return nil
+	}
+	if msg.lifetime > maxSessionTicketLifetime {
+		return c.in.setErrorLocked(c.sendAlert(alertIllegalParameter))
+	}
+
+	suite := cipherSuiteTLS13ByID(c.cipherSuite)
+	if suite == nil || c.resumptionSecret == nil {
+		return c.in.setErrorLocked(c.sendAlert(alertInternalError))
+	}
+	psk := suite.expandLabel(c.resumptionSecret, "resumption", msg.nonce, suite.hash.Size())
+
+	session := &ClientSessionState{
+		sessionTicket:      msg.label,
+		vers:               c.vers,
+		cipherSuite:        c.cipherSuite,
+		masterSecret:       psk,
+		serverCertificates: c.peerCertificates,
+		receivedAt:         c.config.time(),
+		lifetime:           msg.lifetime,
+		ageAdd:             msg.ageAdd,
+	}
+	c.config.ClientSessionCache.Put(clientSessionCacheKey(c.conn.RemoteAddr(), c.config), session)
+
+	return nil
+}
+
+// handleKeyUpdate switches to the next receiving traffic secret and, if
+// the peer asked for it, updates the sending one too.
+func (c *Conn) handleKeyUpdate(msg *keyUpdateMsg) error {
+	suite := cipherSuiteTLS13ByID(c.cipherSuite)
+	if suite == nil {
+		return c.in.setErrorLocked(c.sendAlert(alertInternalError))
+	}
+
+	c.in.setTrafficSecret(suite, suite.nextTrafficSecret(c.in.trafficSecret))
+
+	if msg.updateRequested {
+		c.out.Lock()
+		defer c.out.Unlock()
+		if err := c.sendKeyUpdateLocked(suite, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendKeyUpdateLocked sends a KeyUpdate message and switches to the next
+// sending traffic secret.
+// c.out.Mutex <= L.
+func (c *Conn) sendKeyUpdateLocked(suite *cipherSuiteTLS13, updateRequested bool) error {
+	msg := &keyUpdateMsg{updateRequested: updateRequested}
+	if _, err := c.writeRecord(recordTypeHandshake, msg.marshal()); err != nil {
+		return c.out.setErrorLocked(err)
+	}
+	c.out.setTrafficSecret(suite, suite.nextTrafficSecret(c.out.trafficSecret))
+	return nil
+}
+
 // Write writes data to the connection.
 func (c *Conn) Write(b []byte) (int, error) {
 	if err := c.Handshake(); err != nil {
@@ -905,6 +1129,11 @@ func (c *Conn) Read(b []byte) (n int, err error) {
 				// Soft error, like EAGAIN
 				return 0, err
 			}
+			for c.hand.Len() > 0 {
+				if err := c.handlePostHandshakeMessage(); err != nil {
+					return 0, err
+				}
+			}
 		}
 		if err := c.in.err; err != nil {
 			return 0, err
@@ -997,7 +1226,7 @@ func (c *Conn) ConnectionState() ConnectionState {
 		state.PeerCertificates = c.peerCertificates
 		state.VerifiedChains = c.verifiedChains
 		state.ServerName = c.serverName
-		if !c.didResume {
+		if !c.didResume && c.vers != VersionTLS13 {
 			state.TLSUnique = c.firstFinished[:]
 		}
 	}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/subtle"
 	"crypto/x509"
@@ -91,6 +92,46 @@ NextCipherSuite:
 		hello.signatureAndHashes = supportedSKXSignatureAlgorithms
 	}
 
+	var ecdheParams ecdheParameters
+	if hello.vers >= VersionTLS13 {
+		// TLS 1.3 is offered through the supported_versions extension
+		// and the legacy version field stays at TLS 1.2.
+		hello.vers = VersionTLS12
+		hello.supportedVersions = c.config.supportedVersions()
+
+		suites := make([]uint16, 0, len(cipherSuitesTLS13)+len(hello.cipherSuites))
+		for _, suite := range cipherSuitesTLS13 {
+			suites = append(suites, suite.id)
+		}
+		hello.cipherSuites = append(suites, hello.cipherSuites...)
+
+		sigAndHashes := supportedSignatureAlgorithmsTLS13
+		for _, sigAndHash := range supportedSKXSignatureAlgorithms {
+			if !isSupportedSignatureAndHash(sigAndHash, sigAndHashes) {
+				sigAndHashes = append(sigAndHashes[:len(sigAndHashes):len(sigAndHashes)], sigAndHash)
+			}
+		}
+		hello.signatureAndHashes = sigAndHashes
+
+		// A non-empty session ID makes the handshake look like a TLS 1.2
+		// resumption to middleboxes. See RFC 8446, appendix D.4.
+		hello.sessionId = make([]byte, 32)
+		if _, err := io.ReadFull(c.config.rand(), hello.sessionId); err != nil {
+			c.sendAlert(alertInternalError)
+			return errors.New("tls: short read from Rand: " + err.Error())
+		}
+
+		// Send a key share for the most preferred curve only. If the
+		// server wants another one, it will ask for it.
+		ecdheParams, err = generateECDHEParameters(c.config.rand(), hello.supportedCurves[0])
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+		hello.keyShares = []keyShare{{group: ecdheParams.CurveID(), data: ecdheParams.PublicKey()}}
+		hello.pskModes = []uint8{pskModeDHE}
+	}
+
 	var session *ClientSessionState
 	var cacheKey string
 	sessionCache := c.config.ClientSessionCache
@@ -124,15 +165,23 @@ NextCipherSuite:
 		}
 	}
 
-	if session != nil {
+	var earlySecret, binderKey []byte
+	if session != nil && session.vers == VersionTLS13 {
+		earlySecret, binderKey = c.offerSessionTLS13(hello, session)
+		if earlySecret == nil {
+			session = nil
+		}
+	} else if session != nil {
 		hello.sessionTicket = session.sessionTicket
 		// A random session ID is used to detect when the
 		// server accepted the ticket and is resuming a session
 		// (see RFC 5077).
-		hello.sessionId = make([]byte, 16)
-		if _, err := io.ReadFull(c.config.rand(), hello.sessionId); err != nil {
-			c.sendAlert(alertInternalError)
-			return errors.New("tls: short read from Rand: " + err.Error())
+		if hello.sessionId == nil {
+			hello.sessionId = make([]byte, 16)
+			if _, err := io.ReadFull(c.config.rand(), hello.sessionId); err != nil {
+				c.sendAlert(alertInternalError)
+				return errors.New("tls: short read from Rand: " + err.Error())
+			}
 		}
 	}
 
@@ -148,15 +197,53 @@ NextCipherSuite:
 		return unexpectedMessageError(serverHello, msg)
 	}
 
-	vers, ok := c.config.mutualVersion(serverHello.vers)
-	if !ok || vers < VersionTLS10 {
+	peerVersion := serverHello.vers
+	if serverHello.supportedVersion != 0 {
+		peerVersion = serverHello.supportedVersion
+	}
+	vers, ok := c.config.mutualVersion(peerVersion)
+	if !ok || vers < VersionTLS10 || (peerVersion > VersionTLS12 && vers != peerVersion) {
 		// TLS 1.0 is the minimum version supported as a client.
 		c.sendAlert(alertProtocolVersion)
-		return fmt.Errorf("tls: server selected unsupported protocol version %x", serverHello.vers)
+		return fmt.Errorf("tls: server selected unsupported protocol version %x", peerVersion)
+	}
+	if vers == VersionTLS13 && len(hello.supportedVersions) == 0 {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: server negotiated TLS 1.3 without being offered it")
 	}
 	c.vers = vers
 	c.haveVers = true
 
+	// If we offered TLS 1.3, a server that picked an earlier version must
+	// not also be TLS 1.3 capable, or this is a downgrade attack. See RFC
+	// 8446, section 4.1.3.
+	if c.config.maxVersion() >= VersionTLS13 && vers < VersionTLS13 && len(serverHello.random) == 32 {
+		canary := string(serverHello.random[24:])
+		if canary == downgradeCanaryTLS12 || canary == downgradeCanaryTLS11 {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: downgrade attempt detected, possibly due to a MitM attack or a broken middlebox")
+		}
+	}
+
+	if vers == VersionTLS13 {
+		hs := &clientHandshakeStateTLS13{
+			c:           c,
+			serverHello: serverHello,
+			hello:       hello,
+			ecdheParams: ecdheParams,
+			session:     session,
+			earlySecret: earlySecret,
+			binderKey:   binderKey,
+		}
+		return hs.handshake()
+	}
+
+	if session != nil && session.vers == VersionTLS13 {
+		// The server didn't take up the TLS 1.3 session, and it can't
+		// be resumed in TLS 1.2.
+		session = nil
+	}
+
 	suite := mutualCipherSuite(c.config.cipherSuites(), serverHello.cipherSuite)
 	if suite == nil {
 		c.sendAlert(alertHandshakeFailure)
@@ -235,46 +322,10 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 	}
 	hs.finishedHash.Write(certMsg.marshal())
 
-	certs := make([]*x509.Certificate, len(certMsg.certificates))
-	for i, asn1Data := range certMsg.certificates {
-		cert, err := x509.ParseCertificate(asn1Data)
-		if err != nil {
-			c.sendAlert(alertBadCertificate)
-			return errors.New("tls: failed to parse certificate from server: " + err.Error())
-		}
-		certs[i] = cert
-	}
-
-	if !c.config.InsecureSkipVerify {
-		opts := x509.VerifyOptions{
-			Roots:         c.config.RootCAs,
-			CurrentTime:   c.config.time(),
-			DNSName:       c.config.ServerName,
-			Intermediates: x509.NewCertPool(),
-		}
-
-		for i, cert := range certs {
-			if i == 0 {
-				continue
-			}
-			opts.Intermediates.AddCert(cert)
-		}
-		c.verifiedChains, err = certs[0].Verify(opts)
-		if err != nil {
-			c.sendAlert(alertBadCertificate)
-			return err
-		}
-	}
-
-	switch certs[0].PublicKey.(type) {
-	case *rsa.PublicKey, *ecdsa.PublicKey:
-		break
-	default:
-		c.sendAlert(alertUnsupportedCertificate)
-		return fmt.Errorf("tls: server's certificate contains an unsupported type of public key: %T", certs[0].PublicKey)
+	if err := c.verifyServerCertificate(certMsg.certificates); err != nil {
+		return err
 	}
-
-	c.peerCertificates = certs
+	certs := c.peerCertificates
 
 	if hs.serverHello.ocspStapling {
 		msg, err = c.readHandshake()
@@ -320,18 +371,6 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 	certReq, ok := msg.(*certificateRequestMsg)
 	if ok {
 		certRequested = true
-
-		// RFC 4346 on the certificateAuthorities field:
-		// A list of the distinguished names of acceptable certificate
-		// authorities. These distinguished names may specify a desired
-		// distinguished name for a root CA or for a subordinate CA;
-		// thus, this message can be used to describe both known roots
-		// and a desired authorization space. If the
-		// certificate_authorities list is empty then the client MAY
-		// send any certificate of the appropriate
-		// ClientCertificateType, unless there is some external
-		// arrangement to the contrary.
-
 		hs.finishedHash.Write(certReq.marshal())
 
 		var rsaAvail, ecdsaAvail bool
@@ -344,47 +383,11 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 			}
 		}
 
-		// We need to search our list of client certs for one
-		// where SignatureAlgorithm is acceptable to the server and the
-		// Issuer is in certReq.certificateAuthorities
-	findCert:
-		for i, chain := range c.config.Certificates {
-			if !rsaAvail && !ecdsaAvail {
-				continue
-			}
-
-			for j, cert := range chain.Certificate {
-				x509Cert := chain.Leaf
-				// parse the certificate if this isn't the leaf
-				// node, or if chain.Leaf was nil
-				if j != 0 || x509Cert == nil {
-					if x509Cert, err = x509.ParseCertificate(cert); err != nil {
-						c.sendAlert(alertInternalError)
-						return errors.New("tls: failed to parse client certificate #" + strconv.Itoa(i) + ": " + err.Error())
-					}
-				}
-
-				switch {
-				case rsaAvail && x509Cert.PublicKeyAlgorithm == x509.RSA:
-				case ecdsaAvail && x509Cert.PublicKeyAlgorithm == x509.ECDSA:
-				default:
-					continue findCert
-				}
-
-				if len(certReq.certificateAuthorities) == 0 {
-					// they gave us an empty list, so just take the
-					// first cert from c.config.Certificates
-					chainToSend = &chain
-					break findCert
-				}
-
-				for _, ca := range certReq.certificateAuthorities {
-					if bytes.Equal(x509Cert.RawIssuer, ca) {
-						chainToSend = &chain
-						break findCert
-					}
-				}
-			}
+		chainToSend, err = c.findClientCertificate(certReq.certificateAuthorities, func(alg x509.PublicKeyAlgorithm) bool {
+			return rsaAvail && alg == x509.RSA || ecdsaAvail && alg == x509.ECDSA
+		})
+		if err != nil {
+			return err
 		}
 
 		msg, err = c.readHandshake()
@@ -486,7 +489,7 @@ func (hs *clientHandshakeState) establishKeys() error {
 func (hs *clientHandshakeState) serverResumedSession() bool {
 	// If the server responded with the same sessionId then it means the
 	// sessionTicket is being used to resume a TLS session.
-	return hs.session != nil && hs.hello.sessionId != nil &&
+	return hs.session != nil && hs.hello.sessionTicket != nil && hs.hello.sessionId != nil &&
 		bytes.Equal(hs.serverHello.sessionId, hs.hello.sessionId)
 }
 
@@ -518,6 +521,11 @@ func (hs *clientHandshakeState) processServerHello() (bool, error) {
 		return false, errors.New("server advertised both NPN and ALPN extensions")
 	}
 
+	if serverHasALPN && !offeredProtocol(hs.hello.alpnProtocols, hs.serverHello.alpnProtocol) {
+		c.sendAlert(alertIllegalParameter)
+		return false, errors.New("tls: server selected unadvertised ALPN protocol")
+	}
+
 	if serverHasALPN {
 		c.clientProtocol = hs.serverHello.alpnProtocol
 		c.clientProtocolFallback = false
@@ -612,6 +620,109 @@ func (hs *clientHandshakeState) sendFinished(out []byte) error {
 	return nil
 }
 
+// verifyServerCertificate parses and, unless InsecureSkipVerify is set,
+// verifies the certificate chain sent by the server.
+func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
+	certs := make([]*x509.Certificate, len(certificates))
+	for i, asn1Data := range certificates {
+		cert, err := x509.ParseCertificate(asn1Data)
+		if err != nil {
+			c.sendAlert(alertBadCertificate)
+			return errors.New("tls: failed to parse certificate from server: " + err.Error())
+		}
+		certs[i] = cert
+	}
+
+	if !c.config.InsecureSkipVerify {
+		opts := x509.VerifyOptions{
+			Roots:         c.config.RootCAs,
+			CurrentTime:   c.config.time(),
+			DNSName:       c.config.ServerName,
+			Intermediates: x509.NewCertPool(),
+		}
+
+		for i, cert := range certs {
+			if i == 0 {
+				continue
+			}
+			opts.Intermediates.AddCert(cert)
+		}
+		var err error
+		c.verifiedChains, err = certs[0].Verify(opts)
+		if err != nil {
+			c.sendAlert(alertBadCertificate)
+			return err
+		}
+	}
+
+	switch certs[0].PublicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		break
+	default:
+		c.sendAlert(alertUnsupportedCertificate)
+		return fmt.Errorf("tls: server's certificate contains an unsupported type of public key: %T", certs[0].PublicKey)
+	}
+
+	c.peerCertificates = certs
+	return nil
+}
+
+// findClientCertificate returns the first configured certificate chain
+// whose certificates all have public key algorithms accepted by keyOk and
+// one of which was issued by one of the given certificate authorities. If
+// the list of authorities is empty, any such chain is taken. It returns
+// nil if there is no suitable chain.
+func (c *Conn) findClientCertificate(certificateAuthorities [][]byte, keyOk func(x509.PublicKeyAlgorithm) bool) (*Certificate, error) {
+	// RFC 4346 on the certificateAuthorities field:
+	// A list of the distinguished names of acceptable certificate
+	// authorities. These distinguished names may specify a desired
+	// distinguished name for a root CA or for a subordinate CA;
+	// thus, this message can be used to describe both known roots
+	// and a desired authorization space. If the
+	// certificate_authorities list is empty then the client MAY
+	// send any certificate of the appropriate
+	// ClientCertificateType, unless there is some external
+	// arrangement to the contrary.
+
+	// We need to search our list of client certs for one
+	// where SignatureAlgorithm is acceptable to the server and the
+	// Issuer is in certificateAuthorities
+findCert:
+	for i := range c.config.Certificates {
+		chain := &c.config.Certificates[i]
+		for j, cert := range chain.Certificate {
+			x509Cert := chain.Leaf
+			// parse the certificate if this isn't the leaf
+			// node, or if chain.Leaf was nil
+			if j != 0 || x509Cert == nil {
+				var err error
+				if x509Cert, err = x509.ParseCertificate(cert); err != nil {
+					c.sendAlert(alertInternalError)
+					return nil, errors.New("tls: failed to parse client certificate #" + strconv.Itoa(i) + ": " + err.Error())
+				}
+			}
+
+			if !keyOk(x509Cert.PublicKeyAlgorithm) {
+				continue findCert
+			}
+
+			if len(certificateAuthorities) == 0 {
+				// they gave us an empty list, so just take the
+				// first cert from c.config.Certificates
+				return chain, nil
+			}
+
+			for _, ca := range certificateAuthorities {
+				if bytes.Equal(x509Cert.RawIssuer, ca) {
+					return chain, nil
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
 // clientSessionCacheKey returns a key used to cache sessionTickets that could
 // be used to resume previously negotiated TLS sessions with a server.
 func clientSessionCacheKey(serverAddr net.Addr, config *Config) string {
@@ -621,6 +732,18 @@ func clientSessionCacheKey(serverAddr net.Addr, config *Config) string {
 	return serverAddr.String()
 }
 
+// offeredProtocol reports whether proto is one of the ALPN protocols that
+// were offered. A server must only select an offered protocol; see RFC 7301,
+// section 3.2.
+func offeredProtocol(protos []string, proto string) bool {
+	for _, p := range protos {
+		if p == proto {
+			return true
+		}
+	}
+	return false
+}
+
 // mutualProtocol finds the mutual Next Protocol Negotiation or ALPN protocol
 // given list of possible protocols and a list of the preference order. The
 // first list must not be empty. It returns the resulting protocol and flag
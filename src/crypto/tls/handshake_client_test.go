@@ -17,6 +17,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -269,6 +270,17 @@ func runClientTestTLS12(t *testing.T, template *clientTest) {
 	runClientTestForVersion(t, template, "TLSv12-", "-tls1_2")
 }
 
+func runClientTestTLS13(t *testing.T, template *clientTest) {
+	test := *template
+	config := *testConfig
+	if test.config != nil {
+		config = *test.config
+	}
+	config.MaxVersion = VersionTLS13
+	test.config = &config
+	runClientTestForVersion(t, &test, "TLSv13-", "-tls1_3")
+}
+
 func TestHandshakeClientRSARC4(t *testing.T) {
 	test := &clientTest{
 		name:    "RSA-RC4",
@@ -311,6 +323,19 @@ func TestHandshakeClientECDHEECDSAAESGCM(t *testing.T) {
 	runClientTestTLS12(t, test)
 }
 
+func TestHandshakeClientECDHERSAChaCha20(t *testing.T) {
+	config := *testConfig
+	config.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}
+	config.CurvePreferences = []CurveID{X25519}
+
+	test := &clientTest{
+		name:    "ECDHE-RSA-CHACHA20-POLY1305",
+		command: []string{"openssl", "s_server", "-cipher", "ECDHE-RSA-CHACHA20-POLY1305"},
+		config:  &config,
+	}
+	runClientTestTLS12(t, test)
+}
+
 func TestHandshakeClientCertRSA(t *testing.T) {
 	config := *testConfig
 	cert, _ := X509KeyPair([]byte(clientCertificatePEM), []byte(clientKeyPEM))
@@ -401,6 +426,109 @@ func TestClientResumption(t *testing.T) {
 	testResumeState("WithoutSessionCache", false)
 }
 
+func TestClientResumptionTLS13(t *testing.T) {
+	serverConfig := &Config{
+		Certificates: testConfig.Certificates,
+		MaxVersion:   VersionTLS13,
+	}
+	clientConfig := &Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         VersionTLS13,
+		ClientSessionCache: NewLRUClientSessionCache(32),
+	}
+
+	testResumeState := func(test string, didResume bool) {
+		hs, err := testHandshake(clientConfig, serverConfig)
+		if err != nil {
+			t.Fatalf("%s: handshake failed: %s", test, err)
+		}
+		if hs.Version != VersionTLS13 {
+			t.Fatalf("%s: version %x, expected %x", test, hs.Version, VersionTLS13)
+		}
+		if hs.DidResume != didResume {
+			t.Fatalf("%s resumed: %v, expected: %v", test, hs.DidResume, didResume)
+		}
+	}
+
+	testResumeState("Handshake", false)
+	testResumeState("Resume", true)
+
+	if _, err := io.ReadFull(serverConfig.rand(), serverConfig.SessionTicketKey[:]); err != nil {
+		t.Fatalf("Failed to invalidate SessionTicketKey")
+	}
+	testResumeState("InvalidSessionTicketKey", false)
+	testResumeState("ResumeAfterInvalidSessionTicketKey", true)
+
+	clientConfig.ClientSessionCache = nil
+	testResumeState("WithoutSessionCache", false)
+}
+
+func TestHandshakeClientTLS13AES128(t *testing.T) {
+	test := &clientTest{
+		name:    "AES128-SHA256",
+		command: []string{"openssl", "s_server", "-ciphersuites", "TLS_AES_128_GCM_SHA256"},
+	}
+	runClientTestTLS13(t, test)
+}
+
+func TestHandshakeClientTLS13AES256(t *testing.T) {
+	test := &clientTest{
+		name:    "AES256-SHA384",
+		command: []string{"openssl", "s_server", "-ciphersuites", "TLS_AES_256_GCM_SHA384"},
+	}
+	runClientTestTLS13(t, test)
+}
+
+func TestHandshakeClientTLS13ChaCha20(t *testing.T) {
+	test := &clientTest{
+		name:    "CHACHA20-SHA256",
+		command: []string{"openssl", "s_server", "-ciphersuites", "TLS_CHACHA20_POLY1305_SHA256"},
+	}
+	runClientTestTLS13(t, test)
+}
+
+func TestHandshakeClientTLS13X25519(t *testing.T) {
+	config := *testConfig
+	config.CurvePreferences = []CurveID{X25519}
+
+	test := &clientTest{
+		name:    "X25519",
+		command: []string{"openssl", "s_server", "-groups", "X25519"},
+		config:  &config,
+	}
+	runClientTestTLS13(t, test)
+}
+
+func TestHandshakeClientTLS13HelloRetryRequest(t *testing.T) {
+	config := *testConfig
+	config.CurvePreferences = []CurveID{CurveP256, CurveP384}
+
+	test := &clientTest{
+		name:    "HelloRetryRequest",
+		command: []string{"openssl", "s_server", "-groups", "P-384"},
+		config:  &config,
+		validate: func(state ConnectionState) error {
+			if state.Version != VersionTLS13 {
+				return fmt.Errorf("got version %x, expected %x", state.Version, VersionTLS13)
+			}
+			return nil
+		},
+	}
+	runClientTestTLS13(t, test)
+}
+
+func TestHandshakeClientTLS13ClientCert(t *testing.T) {
+	config := *testConfig
+	config.Certificates = testConfig.Certificates[:1]
+
+	test := &clientTest{
+		name:    "ClientCert-RSA",
+		command: []string{"openssl", "s_server", "-verify", "1"},
+		config:  &config,
+	}
+	runClientTestTLS13(t, test)
+}
+
 func TestLRUClientSessionCache(t *testing.T) {
 	// Initialize cache of capacity 4.
 	cache := NewLRUClientSessionCache(4)
@@ -466,6 +594,7 @@ func TestHandshakeClientALPNMatch(t *testing.T) {
 		},
 	}
 	runClientTestTLS12(t, test)
+	runClientTestTLS13(t, test)
 }
 
 func TestHandshakeClientALPNNoMatch(t *testing.T) {
@@ -488,3 +617,113 @@ func TestHandshakeClientALPNNoMatch(t *testing.T) {
 	}
 	runClientTestTLS12(t, test)
 }
+
+func TestHandshakeClientALPNUnadvertised(t *testing.T) {
+	// A server must only select one of the protocols offered by the
+	// client; anything else should abort the handshake.
+	c, s := net.Pipe()
+	go func() {
+		srv := Server(s, testConfig)
+		if _, err := srv.readHandshake(); err != nil {
+			s.Close()
+			return
+		}
+		serverHello := &serverHelloMsg{
+			vers:         VersionTLS12,
+			random:       make([]byte, 32),
+			cipherSuite:  TLS_RSA_WITH_RC4_128_SHA,
+			alpnProtocol: "proto3",
+		}
+		srv.vers = VersionTLS12
+		srv.writeRecord(recordTypeHandshake, serverHello.marshal())
+		s.Close()
+	}()
+
+	config := *testConfig
+	config.NextProtos = []string{"proto1", "proto2"}
+	err := Client(c, &config).Handshake()
+	c.Close()
+	if err == nil || !strings.Contains(err.Error(), "unadvertised ALPN protocol") {
+		t.Errorf("Got error: %v; expected an unadvertised ALPN protocol error", err)
+	}
+}
+
+func TestHandshakeClientALPNTLS13(t *testing.T) {
+	serverConfig := &Config{
+		Certificates: testConfig.Certificates,
+		MaxVersion:   VersionTLS13,
+		NextProtos:   []string{"proto3", "proto1"},
+	}
+	clientConfig := &Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         VersionTLS13,
+		NextProtos:         []string{"proto2", "proto1"},
+	}
+
+	c, s := net.Pipe()
+	go func() {
+		Server(s, serverConfig).Handshake()
+		s.Close()
+	}()
+	cli := Client(c, clientConfig)
+	if err := cli.Handshake(); err != nil {
+		t.Fatalf("handshake failed: %s", err)
+	}
+	state := cli.ConnectionState()
+	c.Close()
+	if state.Version != VersionTLS13 {
+		t.Fatalf("Incorrect version %x, should be %x", state.Version, VersionTLS13)
+	}
+	if state.NegotiatedProtocol != "proto1" || !state.NegotiatedProtocolIsMutual {
+		t.Errorf("Got protocol %q (mutual %v), wanted mutual proto1", state.NegotiatedProtocol, state.NegotiatedProtocolIsMutual)
+	}
+}
+
+func TestHandshakeClientALPNUnadvertisedTLS13(t *testing.T) {
+	// In TLS 1.3 the selected protocol travels in the encrypted
+	// EncryptedExtensions message, so run the server side step by step
+	// and make it select from a list the client did not send. The
+	// transcript uses the raw ClientHello, so the keys still agree.
+	ln := newLocalListener(t)
+	defer ln.Close()
+	go func() {
+		s, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		srv := Server(s, &Config{
+			Certificates: testConfig.Certificates,
+			MaxVersion:   VersionTLS13,
+			NextProtos:   []string{"proto3"},
+		})
+		clientHello, err := srv.readClientHello()
+		if err != nil {
+			return
+		}
+		hs := serverHandshakeStateTLS13{c: srv, clientHello: clientHello}
+		srv.buffering = true
+		if hs.processClientHello() != nil || hs.checkForResumption() != nil || hs.pickCertificate() != nil {
+			return
+		}
+		clientHello.alpnProtocols = []string{"proto3"}
+		if hs.sendServerParameters() != nil || hs.sendServerCertificate() != nil || hs.sendServerFinished() != nil {
+			return
+		}
+		srv.flush()
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	err = Client(c, &Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         VersionTLS13,
+		NextProtos:         []string{"proto1", "proto2"},
+	}).Handshake()
+	if err == nil || !strings.Contains(err.Error(), "unadvertised ALPN protocol") {
+		t.Errorf("Got error: %v; expected an unadvertised ALPN protocol error", err)
+	}
+}
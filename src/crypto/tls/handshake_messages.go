@@ -23,6 +23,24 @@ type clientHelloMsg struct {
 	signatureAndHashes  []signatureAndHash
 	secureRenegotiation bool
 	alpnProtocols       []string
+	supportedVersions   []uint16
+	cookie              []byte
+	keyShares           []keyShare
+	pskModes            []uint8
+	pskIdentities       []pskIdentity
+	pskBinders          [][]byte
+}
+
+// keyShare is a TLS 1.3 KeyShareEntry. See RFC 8446, section 4.2.8.
+type keyShare struct {
+	group CurveID
+	data  []byte
+}
+
+// pskIdentity is a TLS 1.3 PskIdentity. See RFC 8446, section 4.2.11.
+type pskIdentity struct {
+	label               []byte
+	obfuscatedTicketAge uint32
 }
 
 func (m *clientHelloMsg) equal(i interface{}) bool {
@@ -46,7 +64,13 @@ func (m *clientHelloMsg) equal(i interface{}) bool {
 		bytes.Equal(m.sessionTicket, m1.sessionTicket) &&
 		eqSignatureAndHashes(m.signatureAndHashes, m1.signatureAndHashes) &&
 		m.secureRenegotiation == m1.secureRenegotiation &&
-		eqStrings(m.alpnProtocols, m1.alpnProtocols)
+		eqStrings(m.alpnProtocols, m1.alpnProtocols) &&
+		eqUint16s(m.supportedVersions, m1.supportedVersions) &&
+		bytes.Equal(m.cookie, m1.cookie) &&
+		eqKeyShares(m.keyShares, m1.keyShares) &&
+		bytes.Equal(m.pskModes, m1.pskModes) &&
+		eqPSKIdentities(m.pskIdentities, m1.pskIdentities) &&
+		eqByteSlices(m.pskBinders, m1.pskBinders)
 }
 
 func (m *clientHelloMsg) marshal() []byte {
@@ -99,6 +123,35 @@ func (m *clientHelloMsg) marshal() []byte {
 		}
 		numExtensions++
 	}
+	if len(m.supportedVersions) > 0 {
+		extensionsLength += 1 + 2*len(m.supportedVersions)
+		numExtensions++
+	}
+	if len(m.cookie) > 0 {
+		extensionsLength += 2 + len(m.cookie)
+		numExtensions++
+	}
+	if len(m.keyShares) > 0 {
+		extensionsLength += 2
+		for _, ks := range m.keyShares {
+			extensionsLength += 4 + len(ks.data)
+		}
+		numExtensions++
+	}
+	if len(m.pskModes) > 0 {
+		extensionsLength += 1 + len(m.pskModes)
+		numExtensions++
+	}
+	if len(m.pskIdentities) > 0 {
+		extensionsLength += 2 + 2
+		for _, psk := range m.pskIdentities {
+			extensionsLength += 2 + len(psk.label) + 4
+		}
+		for _, binder := range m.pskBinders {
+			extensionsLength += 1 + len(binder)
+		}
+		numExtensions++
+	}
 	if numExtensions > 0 {
 		extensionsLength += 4 * numExtensions
 		length += 2 + extensionsLength
@@ -271,12 +324,148 @@ func (m *clientHelloMsg) marshal() []byte {
 		lengths[0] = byte(stringsLength >> 8)
 		lengths[1] = byte(stringsLength)
 	}
+	if len(m.supportedVersions) > 0 {
+		// RFC 8446, section 4.2.1
+		z[0] = byte(extensionSupportedVersions >> 8)
+		z[1] = byte(extensionSupportedVersions)
+		l := 1 + 2*len(m.supportedVersions)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(l - 1)
+		z = z[5:]
+		for _, vers := range m.supportedVersions {
+			z[0] = byte(vers >> 8)
+			z[1] = byte(vers)
+			z = z[2:]
+		}
+	}
+	if len(m.cookie) > 0 {
+		// RFC 8446, section 4.2.2
+		z[0] = byte(extensionCookie >> 8)
+		z[1] = byte(extensionCookie)
+		l := 2 + len(m.cookie)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(len(m.cookie) >> 8)
+		z[5] = byte(len(m.cookie))
+		copy(z[6:], m.cookie)
+		z = z[6+len(m.cookie):]
+	}
+	if len(m.keyShares) > 0 {
+		// RFC 8446, section 4.2.8
+		z[0] = byte(extensionKeyShare >> 8)
+		z[1] = byte(extensionKeyShare)
+		lengths := z[2:]
+		z = z[6:]
+
+		sharesLength := 0
+		for _, ks := range m.keyShares {
+			z[0] = byte(ks.group >> 8)
+			z[1] = byte(ks.group)
+			z[2] = byte(len(ks.data) >> 8)
+			z[3] = byte(len(ks.data))
+			copy(z[4:], ks.data)
+			z = z[4+len(ks.data):]
+			sharesLength += 4 + len(ks.data)
+		}
+
+		lengths[2] = byte(sharesLength >> 8)
+		lengths[3] = byte(sharesLength)
+		sharesLength += 2
+		lengths[0] = byte(sharesLength >> 8)
+		lengths[1] = byte(sharesLength)
+	}
+	if len(m.pskModes) > 0 {
+		// RFC 8446, section 4.2.9
+		z[0] = byte(extensionPSKModes >> 8)
+		z[1] = byte(extensionPSKModes)
+		l := 1 + len(m.pskModes)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(len(m.pskModes))
+		copy(z[5:], m.pskModes)
+		z = z[5+len(m.pskModes):]
+	}
+	if len(m.pskIdentities) > 0 {
+		// RFC 8446, section 4.2.11. This must be the last extension.
+		identitiesLength := 0
+		for _, psk := range m.pskIdentities {
+			identitiesLength += 2 + len(psk.label) + 4
+		}
+		bindersLength := 0
+		for _, binder := range m.pskBinders {
+			bindersLength += 1 + len(binder)
+		}
+
+		z[0] = byte(extensionPreSharedKey >> 8)
+		z[1] = byte(extensionPreSharedKey)
+		l := 2 + identitiesLength + 2 + bindersLength
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(identitiesLength >> 8)
+		z[5] = byte(identitiesLength)
+		z = z[6:]
+		for _, psk := range m.pskIdentities {
+			z[0] = byte(len(psk.label) >> 8)
+			z[1] = byte(len(psk.label))
+			copy(z[2:], psk.label)
+			z = z[2+len(psk.label):]
+			z[0] = byte(psk.obfuscatedTicketAge >> 24)
+			z[1] = byte(psk.obfuscatedTicketAge >> 16)
+			z[2] = byte(psk.obfuscatedTicketAge >> 8)
+			z[3] = byte(psk.obfuscatedTicketAge)
+			z = z[4:]
+		}
+		z[0] = byte(bindersLength >> 8)
+		z[1] = byte(bindersLength)
+		z = z[2:]
+		for _, binder := range m.pskBinders {
+			z[0] = byte(len(binder))
+			copy(z[1:], binder)
+			z = z[1+len(binder):]
+		}
+	}
 
 	m.raw = x
 
 	return x
 }
 
+// marshalWithoutBinders returns the ClientHello up to, but not including,
+// the PreSharedKeyExtension binders, which is the input to the binder
+// computation. See RFC 8446, section 4.2.11.2. m.pskBinders must already
+// hold slices of the final lengths.
+func (m *clientHelloMsg) marshalWithoutBinders() []byte {
+	bindersLength := 2
+	for _, binder := range m.pskBinders {
+		bindersLength += 1 + len(binder)
+	}
+
+	fullMessage := m.marshal()
+	return fullMessage[:len(fullMessage)-bindersLength]
+}
+
+// updateBinders replaces m.pskBinders, updating the marshaled message in
+// place. The new binders must have the same lengths as the current ones.
+func (m *clientHelloMsg) updateBinders(pskBinders [][]byte) {
+	if len(pskBinders) != len(m.pskBinders) {
+		panic("tls: internal error: pskBinders length mismatch")
+	}
+	for i := range m.pskBinders {
+		if len(pskBinders[i]) != len(m.pskBinders[i]) {
+			panic("tls: internal error: pskBinders length mismatch")
+		}
+	}
+
+	z := m.marshal()
+	z = z[len(m.marshalWithoutBinders())+2:]
+	m.pskBinders = pskBinders
+	for _, binder := range m.pskBinders {
+		copy(z[1:], binder)
+		z = z[1+len(binder):]
+	}
+}
+
 func (m *clientHelloMsg) unmarshal(data []byte) bool {
 	if len(data) < 42 {
 		return false
@@ -326,6 +515,12 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 	m.sessionTicket = nil
 	m.signatureAndHashes = nil
 	m.alpnProtocols = nil
+	m.supportedVersions = nil
+	m.cookie = nil
+	m.keyShares = nil
+	m.pskModes = nil
+	m.pskIdentities = nil
+	m.pskBinders = nil
 
 	if len(data) == 0 {
 		// ClientHello is optionally followed by extension data
@@ -453,6 +648,113 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 				m.alpnProtocols = append(m.alpnProtocols, string(d[:stringLen]))
 				d = d[stringLen:]
 			}
+		case extensionSupportedVersions:
+			// RFC 8446, section 4.2.1
+			if length < 1 {
+				return false
+			}
+			l := int(data[0])
+			if l%2 == 1 || length != l+1 {
+				return false
+			}
+			m.supportedVersions = make([]uint16, l/2)
+			d := data[1:]
+			for i := range m.supportedVersions {
+				m.supportedVersions[i] = uint16(d[0])<<8 | uint16(d[1])
+				d = d[2:]
+			}
+		case extensionCookie:
+			// RFC 8446, section 4.2.2
+			if length < 2 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if l == 0 || length != l+2 {
+				return false
+			}
+			m.cookie = data[2:length]
+		case extensionKeyShare:
+			// RFC 8446, section 4.2.8
+			if length < 2 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if length != l+2 {
+				return false
+			}
+			d := data[2:length]
+			for len(d) != 0 {
+				if len(d) < 4 {
+					return false
+				}
+				var ks keyShare
+				ks.group = CurveID(d[0])<<8 | CurveID(d[1])
+				dataLen := int(d[2])<<8 | int(d[3])
+				d = d[4:]
+				if dataLen == 0 || len(d) < dataLen {
+					return false
+				}
+				ks.data = d[:dataLen]
+				d = d[dataLen:]
+				m.keyShares = append(m.keyShares, ks)
+			}
+		case extensionPSKModes:
+			// RFC 8446, section 4.2.9
+			if length < 1 {
+				return false
+			}
+			l := int(data[0])
+			if length != l+1 {
+				return false
+			}
+			m.pskModes = data[1:length]
+		case extensionPreSharedKey:
+			// RFC 8446, section 4.2.11
+			if len(data) != length {
+				return false // pre_shared_key must be the last extension
+			}
+			if length < 2 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if l == 0 || length < l+2 {
+				return false
+			}
+			d := data[2 : 2+l]
+			for len(d) != 0 {
+				if len(d) < 2 {
+					return false
+				}
+				labelLen := int(d[0])<<8 | int(d[1])
+				d = d[2:]
+				if labelLen == 0 || len(d) < labelLen+4 {
+					return false
+				}
+				var psk pskIdentity
+				psk.label = d[:labelLen]
+				d = d[labelLen:]
+				psk.obfuscatedTicketAge = uint32(d[0])<<24 | uint32(d[1])<<16 | uint32(d[2])<<8 | uint32(d[3])
+				d = d[4:]
+				m.pskIdentities = append(m.pskIdentities, psk)
+			}
+			d = data[2+l : length]
+			if len(d) < 2 {
+				return false
+			}
+			l = int(d[0])<<8 | int(d[1])
+			d = d[2:]
+			if l == 0 || len(d) != l {
+				return false
+			}
+			for len(d) != 0 {
+				binderLen := int(d[0])
+				d = d[1:]
+				if binderLen < 32 || len(d) < binderLen {
+					return false
+				}
+				m.pskBinders = append(m.pskBinders, d[:binderLen])
+				d = d[binderLen:]
+			}
 		}
 		data = data[length:]
 	}
@@ -473,6 +775,16 @@ type serverHelloMsg struct {
 	ticketSupported     bool
 	secureRenegotiation bool
 	alpnProtocol        string
+
+	// TLS 1.3
+	supportedVersion        uint16
+	serverShare             keyShare
+	selectedIdentityPresent bool
+	selectedIdentity        uint16
+	cookie                  []byte
+
+	// HelloRetryRequest extensions
+	selectedGroup CurveID
 }
 
 func (m *serverHelloMsg) equal(i interface{}) bool {
@@ -492,7 +804,14 @@ func (m *serverHelloMsg) equal(i interface{}) bool {
 		m.ocspStapling == m1.ocspStapling &&
 		m.ticketSupported == m1.ticketSupported &&
 		m.secureRenegotiation == m1.secureRenegotiation &&
-		m.alpnProtocol == m1.alpnProtocol
+		m.alpnProtocol == m1.alpnProtocol &&
+		m.supportedVersion == m1.supportedVersion &&
+		m.serverShare.group == m1.serverShare.group &&
+		bytes.Equal(m.serverShare.data, m1.serverShare.data) &&
+		m.selectedIdentityPresent == m1.selectedIdentityPresent &&
+		m.selectedIdentity == m1.selectedIdentity &&
+		bytes.Equal(m.cookie, m1.cookie) &&
+		m.selectedGroup == m1.selectedGroup
 }
 
 func (m *serverHelloMsg) marshal() []byte {
@@ -530,6 +849,26 @@ func (m *serverHelloMsg) marshal() []byte {
 		extensionsLength += 2 + 1 + alpnLen
 		numExtensions++
 	}
+	if m.supportedVersion != 0 {
+		extensionsLength += 2
+		numExtensions++
+	}
+	if m.serverShare.group != 0 {
+		extensionsLength += 4 + len(m.serverShare.data)
+		numExtensions++
+	}
+	if m.selectedGroup != 0 {
+		extensionsLength += 2
+		numExtensions++
+	}
+	if m.selectedIdentityPresent {
+		extensionsLength += 2
+		numExtensions++
+	}
+	if len(m.cookie) > 0 {
+		extensionsLength += 2 + len(m.cookie)
+		numExtensions++
+	}
 
 	if numExtensions > 0 {
 		extensionsLength += 4 * numExtensions
@@ -605,6 +944,63 @@ func (m *serverHelloMsg) marshal() []byte {
 		copy(z[7:], []byte(m.alpnProtocol))
 		z = z[7+alpnLen:]
 	}
+	if m.supportedVersion != 0 {
+		// RFC 8446, section 4.2.1
+		z[0] = byte(extensionSupportedVersions >> 8)
+		z[1] = byte(extensionSupportedVersions)
+		z[2] = 0
+		z[3] = 2
+		z[4] = byte(m.supportedVersion >> 8)
+		z[5] = byte(m.supportedVersion)
+		z = z[6:]
+	}
+	if m.serverShare.group != 0 {
+		// RFC 8446, section 4.2.8
+		z[0] = byte(extensionKeyShare >> 8)
+		z[1] = byte(extensionKeyShare)
+		l := 4 + len(m.serverShare.data)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(m.serverShare.group >> 8)
+		z[5] = byte(m.serverShare.group)
+		z[6] = byte(len(m.serverShare.data) >> 8)
+		z[7] = byte(len(m.serverShare.data))
+		copy(z[8:], m.serverShare.data)
+		z = z[8+len(m.serverShare.data):]
+	}
+	if m.selectedGroup != 0 {
+		// In a HelloRetryRequest the key_share extension holds only the
+		// selected group. See RFC 8446, section 4.2.8.
+		z[0] = byte(extensionKeyShare >> 8)
+		z[1] = byte(extensionKeyShare)
+		z[2] = 0
+		z[3] = 2
+		z[4] = byte(m.selectedGroup >> 8)
+		z[5] = byte(m.selectedGroup)
+		z = z[6:]
+	}
+	if m.selectedIdentityPresent {
+		// RFC 8446, section 4.2.11
+		z[0] = byte(extensionPreSharedKey >> 8)
+		z[1] = byte(extensionPreSharedKey)
+		z[2] = 0
+		z[3] = 2
+		z[4] = byte(m.selectedIdentity >> 8)
+		z[5] = byte(m.selectedIdentity)
+		z = z[6:]
+	}
+	if len(m.cookie) > 0 {
+		// RFC 8446, section 4.2.2
+		z[0] = byte(extensionCookie >> 8)
+		z[1] = byte(extensionCookie)
+		l := 2 + len(m.cookie)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(len(m.cookie) >> 8)
+		z[5] = byte(len(m.cookie))
+		copy(z[6:], m.cookie)
+		z = z[6+len(m.cookie):]
+	}
 
 	m.raw = x
 
@@ -636,6 +1032,12 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 	m.ocspStapling = false
 	m.ticketSupported = false
 	m.alpnProtocol = ""
+	m.supportedVersion = 0
+	m.serverShare = keyShare{}
+	m.selectedIdentityPresent = false
+	m.selectedIdentity = 0
+	m.cookie = nil
+	m.selectedGroup = 0
 
 	if len(data) == 0 {
 		// ServerHello is optionally followed by extension data
@@ -706,6 +1108,41 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 			}
 			d = d[1:]
 			m.alpnProtocol = string(d)
+		case extensionSupportedVersions:
+			if length != 2 {
+				return false
+			}
+			m.supportedVersion = uint16(data[0])<<8 | uint16(data[1])
+		case extensionKeyShare:
+			if length == 2 {
+				// HelloRetryRequest
+				m.selectedGroup = CurveID(data[0])<<8 | CurveID(data[1])
+				break
+			}
+			if length < 4 {
+				return false
+			}
+			m.serverShare.group = CurveID(data[0])<<8 | CurveID(data[1])
+			l := int(data[2])<<8 | int(data[3])
+			if l == 0 || length != l+4 {
+				return false
+			}
+			m.serverShare.data = data[4:length]
+		case extensionPreSharedKey:
+			if length != 2 {
+				return false
+			}
+			m.selectedIdentityPresent = true
+			m.selectedIdentity = uint16(data[0])<<8 | uint16(data[1])
+		case extensionCookie:
+			if length < 2 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if l == 0 || length != l+2 {
+				return false
+			}
+			m.cookie = data[2:length]
 		}
 		data = data[length:]
 	}
@@ -1317,47 +1754,68 @@ func (m *certificateVerifyMsg) unmarshal(data []byte) bool {
 	return true
 }
 
-type newSessionTicketMsg struct {
-	raw    []byte
-	ticket []byte
+// encryptedExtensionsMsg is the TLS 1.3 EncryptedExtensions message. See
+// RFC 8446, section 4.3.1.
+type encryptedExtensionsMsg struct {
+	raw          []byte
+	alpnProtocol string
 }
 
-func (m *newSessionTicketMsg) equal(i interface{}) bool {
-	m1, ok := i.(*newSessionTicketMsg)
+func (m *encryptedExtensionsMsg) equal(i interface{}) bool {
+	m1, ok := i.(*encryptedExtensionsMsg)
 	if !ok {
 		return false
 	}
 
 	return bytes.Equal(m.raw, m1.raw) &&
-		bytes.Equal(m.ticket, m1.ticket)
+		m.alpnProtocol == m1.alpnProtocol
 }
 
-func (m *newSessionTicketMsg) marshal() (x []byte) {
+func (m *encryptedExtensionsMsg) marshal() (x []byte) {
 	if m.raw != nil {
 		return m.raw
 	}
 
-	// See http://tools.ietf.org/html/rfc5077#section-3.3
-	ticketLen := len(m.ticket)
-	length := 2 + 4 + ticketLen
+	extensionsLength := 0
+	alpnLen := len(m.alpnProtocol)
+	if alpnLen > 0 {
+		if alpnLen >= 256 {
+			panic("invalid ALPN protocol")
+		}
+		extensionsLength += 4 + 2 + 1 + alpnLen
+	}
+
+	length := 2 + extensionsLength
 	x = make([]byte, 4+length)
-	x[0] = typeNewSessionTicket
+	x[0] = typeEncryptedExtensions
 	x[1] = uint8(length >> 16)
 	x[2] = uint8(length >> 8)
 	x[3] = uint8(length)
-	x[8] = uint8(ticketLen >> 8)
-	x[9] = uint8(ticketLen)
-	copy(x[10:], m.ticket)
+	x[4] = uint8(extensionsLength >> 8)
+	x[5] = uint8(extensionsLength)
+	z := x[6:]
+	if alpnLen > 0 {
+		z[0] = byte(extensionALPN >> 8)
+		z[1] = byte(extensionALPN & 0xff)
+		l := 2 + 1 + alpnLen
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		l -= 2
+		z[4] = byte(l >> 8)
+		z[5] = byte(l)
+		z[6] = byte(alpnLen)
+		copy(z[7:], m.alpnProtocol)
+	}
 
 	m.raw = x
 
 	return
 }
 
-func (m *newSessionTicketMsg) unmarshal(data []byte) bool {
+func (m *encryptedExtensionsMsg) unmarshal(data []byte) bool {
 	m.raw = data
 
-	if len(data) < 10 {
+	if len(data) < 6 {
 		return false
 	}
 
@@ -1366,58 +1824,626 @@ func (m *newSessionTicketMsg) unmarshal(data []byte) bool {
 		return false
 	}
 
-	ticketLen := int(data[8])<<8 + int(data[9])
-	if len(data)-10 != ticketLen {
+	extensionsLength := int(data[4])<<8 | int(data[5])
+	data = data[6:]
+	if len(data) != extensionsLength {
 		return false
 	}
 
-	m.ticket = data[10:]
-
-	return true
-}
+	m.alpnProtocol = ""
 
-func eqUint16s(x, y []uint16) bool {
-	if len(x) != len(y) {
-		return false
-	}
-	for i, v := range x {
-		if y[i] != v {
+	for len(data) != 0 {
+		if len(data) < 4 {
 			return false
 		}
-	}
-	return true
-}
-
-func eqCurveIDs(x, y []CurveID) bool {
-	if len(x) != len(y) {
-		return false
-	}
-	for i, v := range x {
-		if y[i] != v {
+		extension := uint16(data[0])<<8 | uint16(data[1])
+		length := int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if len(data) < length {
 			return false
 		}
-	}
-	return true
-}
 
-func eqStrings(x, y []string) bool {
-	if len(x) != len(y) {
-		return false
-	}
-	for i, v := range x {
-		if y[i] != v {
-			return false
+		switch extension {
+		case extensionALPN:
+			d := data[:length]
+			if len(d) < 3 {
+				return false
+			}
+			l := int(d[0])<<8 | int(d[1])
+			if l != len(d)-2 {
+				return false
+			}
+			d = d[2:]
+			l = int(d[0])
+			if l == 0 || l != len(d)-1 {
+				return false
+			}
+			m.alpnProtocol = string(d[1:])
 		}
+		data = data[length:]
 	}
+
 	return true
 }
 
-func eqByteSlices(x, y [][]byte) bool {
-	if len(x) != len(y) {
+// certificateMsgTLS13 is the TLS 1.3 Certificate message, which carries
+// per-certificate extensions. See RFC 8446, section 4.4.2.
+type certificateMsgTLS13 struct {
+	raw          []byte
+	certificates [][]byte
+	ocspStapling bool
+	ocspStaple   []byte
+}
+
+func (m *certificateMsgTLS13) equal(i interface{}) bool {
+	m1, ok := i.(*certificateMsgTLS13)
+	if !ok {
 		return false
 	}
-	for i, v := range x {
-		if !bytes.Equal(v, y[i]) {
+
+	return bytes.Equal(m.raw, m1.raw) &&
+		eqByteSlices(m.certificates, m1.certificates) &&
+		m.ocspStapling == m1.ocspStapling &&
+		bytes.Equal(m.ocspStaple, m1.ocspStaple)
+}
+
+func (m *certificateMsgTLS13) marshal() (x []byte) {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	// The OCSP response, if any, is carried in the status_request
+	// extension of the leaf certificate entry.
+	ocspLength := 0
+	if m.ocspStapling {
+		ocspLength = 4 + 1 + 3 + len(m.ocspStaple)
+	}
+
+	certificatesLength := 0
+	for i, cert := range m.certificates {
+		certificatesLength += 3 + len(cert) + 2
+		if i == 0 {
+			certificatesLength += ocspLength
+		}
+	}
+	length := 1 + 3 + certificatesLength
+	x = make([]byte, 4+length)
+	x[0] = typeCertificate
+	x[1] = uint8(length >> 16)
+	x[2] = uint8(length >> 8)
+	x[3] = uint8(length)
+	x[4] = 0 // empty certificate_request_context
+	x[5] = uint8(certificatesLength >> 16)
+	x[6] = uint8(certificatesLength >> 8)
+	x[7] = uint8(certificatesLength)
+
+	z := x[8:]
+	for i, cert := range m.certificates {
+		z[0] = uint8(len(cert) >> 16)
+		z[1] = uint8(len(cert) >> 8)
+		z[2] = uint8(len(cert))
+		copy(z[3:], cert)
+		z = z[3+len(cert):]
+
+		extensionsLength := 0
+		if i == 0 {
+			extensionsLength = ocspLength
+		}
+		z[0] = uint8(extensionsLength >> 8)
+		z[1] = uint8(extensionsLength)
+		z = z[2:]
+		if extensionsLength > 0 {
+			z[0] = byte(extensionStatusRequest >> 8)
+			z[1] = byte(extensionStatusRequest)
+			l := 1 + 3 + len(m.ocspStaple)
+			z[2] = byte(l >> 8)
+			z[3] = byte(l)
+			z[4] = statusTypeOCSP
+			z[5] = byte(len(m.ocspStaple) >> 16)
+			z[6] = byte(len(m.ocspStaple) >> 8)
+			z[7] = byte(len(m.ocspStaple))
+			copy(z[8:], m.ocspStaple)
+			z = z[8+len(m.ocspStaple):]
+		}
+	}
+
+	m.raw = x
+
+	return
+}
+
+func (m *certificateMsgTLS13) unmarshal(data []byte) bool {
+	m.raw = data
+
+	if len(data) < 8 {
+		return false
+	}
+
+	length := uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	if uint32(len(data))-4 != length {
+		return false
+	}
+
+	// This package only ever sends an empty certificate_request_context,
+	// which is what the peer has to echo.
+	if data[4] != 0 {
+		return false
+	}
+
+	certificatesLength := int(data[5])<<16 | int(data[6])<<8 | int(data[7])
+	d := data[8:]
+	if len(d) != certificatesLength {
+		return false
+	}
+
+	m.certificates = nil
+	m.ocspStapling = false
+	m.ocspStaple = nil
+
+	for len(d) > 0 {
+		if len(d) < 3 {
+			return false
+		}
+		certLen := int(d[0])<<16 | int(d[1])<<8 | int(d[2])
+		d = d[3:]
+		if certLen == 0 || len(d) < certLen+2 {
+			return false
+		}
+		cert := d[:certLen]
+		d = d[certLen:]
+
+		extensionsLength := int(d[0])<<8 | int(d[1])
+		d = d[2:]
+		if len(d) < extensionsLength {
+			return false
+		}
+		extensions := d[:extensionsLength]
+		d = d[extensionsLength:]
+
+		for len(extensions) > 0 {
+			if len(extensions) < 4 {
+				return false
+			}
+			extension := uint16(extensions[0])<<8 | uint16(extensions[1])
+			l := int(extensions[2])<<8 | int(extensions[3])
+			extensions = extensions[4:]
+			if len(extensions) < l {
+				return false
+			}
+			if extension == extensionStatusRequest && len(m.certificates) == 0 {
+				if l < 4 || extensions[0] != statusTypeOCSP {
+					return false
+				}
+				responseLen := int(extensions[1])<<16 | int(extensions[2])<<8 | int(extensions[3])
+				if responseLen != l-4 {
+					return false
+				}
+				m.ocspStapling = true
+				m.ocspStaple = extensions[4:l]
+			}
+			extensions = extensions[l:]
+		}
+
+		m.certificates = append(m.certificates, cert)
+	}
+
+	return true
+}
+
+// certificateRequestMsgTLS13 is the TLS 1.3 CertificateRequest message. See
+// RFC 8446, section 4.3.2.
+type certificateRequestMsgTLS13 struct {
+	raw                    []byte
+	signatureAndHashes     []signatureAndHash
+	certificateAuthorities [][]byte
+}
+
+func (m *certificateRequestMsgTLS13) equal(i interface{}) bool {
+	m1, ok := i.(*certificateRequestMsgTLS13)
+	if !ok {
+		return false
+	}
+
+	return bytes.Equal(m.raw, m1.raw) &&
+		eqSignatureAndHashes(m.signatureAndHashes, m1.signatureAndHashes) &&
+		eqByteSlices(m.certificateAuthorities, m1.certificateAuthorities)
+}
+
+func (m *certificateRequestMsgTLS13) marshal() (x []byte) {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	extensionsLength := 0
+	if len(m.signatureAndHashes) > 0 {
+		extensionsLength += 4 + 2 + 2*len(m.signatureAndHashes)
+	}
+	casLength := 0
+	for _, ca := range m.certificateAuthorities {
+		casLength += 2 + len(ca)
+	}
+	if len(m.certificateAuthorities) > 0 {
+		extensionsLength += 4 + 2 + casLength
+	}
+
+	length := 1 + 2 + extensionsLength
+	x = make([]byte, 4+length)
+	x[0] = typeCertificateRequest
+	x[1] = uint8(length >> 16)
+	x[2] = uint8(length >> 8)
+	x[3] = uint8(length)
+	x[4] = 0 // empty certificate_request_context
+	x[5] = uint8(extensionsLength >> 8)
+	x[6] = uint8(extensionsLength)
+
+	z := x[7:]
+	if len(m.signatureAndHashes) > 0 {
+		z[0] = byte(extensionSignatureAlgorithms >> 8)
+		z[1] = byte(extensionSignatureAlgorithms)
+		l := 2 + 2*len(m.signatureAndHashes)
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		l -= 2
+		z[4] = byte(l >> 8)
+		z[5] = byte(l)
+		z = z[6:]
+		for _, sigAndHash := range m.signatureAndHashes {
+			z[0] = sigAndHash.hash
+			z[1] = sigAndHash.signature
+			z = z[2:]
+		}
+	}
+	if len(m.certificateAuthorities) > 0 {
+		z[0] = byte(extensionCertificateAuthorities >> 8)
+		z[1] = byte(extensionCertificateAuthorities)
+		l := 2 + casLength
+		z[2] = byte(l >> 8)
+		z[3] = byte(l)
+		z[4] = byte(casLength >> 8)
+		z[5] = byte(casLength)
+		z = z[6:]
+		for _, ca := range m.certificateAuthorities {
+			z[0] = byte(len(ca) >> 8)
+			z[1] = byte(len(ca))
+			copy(z[2:], ca)
+			z = z[2+len(ca):]
+		}
+	}
+
+	m.raw = x
+
+	return
+}
+
+func (m *certificateRequestMsgTLS13) unmarshal(data []byte) bool {
+	m.raw = data
+
+	if len(data) < 7 {
+		return false
+	}
+
+	length := uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	if uint32(len(data))-4 != length {
+		return false
+	}
+
+	// This package doesn't do post-handshake authentication, so the
+	// request context is always empty.
+	if data[4] != 0 {
+		return false
+	}
+
+	extensionsLength := int(data[5])<<8 | int(data[6])
+	data = data[7:]
+	if len(data) != extensionsLength {
+		return false
+	}
+
+	m.signatureAndHashes = nil
+	m.certificateAuthorities = nil
+
+	for len(data) != 0 {
+		if len(data) < 4 {
+			return false
+		}
+		extension := uint16(data[0])<<8 | uint16(data[1])
+		length := int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if len(data) < length {
+			return false
+		}
+
+		switch extension {
+		case extensionSignatureAlgorithms:
+			if length < 2 || length&1 != 0 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if l != length-2 {
+				return false
+			}
+			d := data[2:]
+			m.signatureAndHashes = make([]signatureAndHash, l/2)
+			for i := range m.signatureAndHashes {
+				m.signatureAndHashes[i].hash = d[0]
+				m.signatureAndHashes[i].signature = d[1]
+				d = d[2:]
+			}
+		case extensionCertificateAuthorities:
+			if length < 2 {
+				return false
+			}
+			l := int(data[0])<<8 | int(data[1])
+			if l != length-2 {
+				return false
+			}
+			d := data[2:length]
+			for len(d) > 0 {
+				if len(d) < 2 {
+					return false
+				}
+				caLen := int(d[0])<<8 | int(d[1])
+				d = d[2:]
+				if caLen == 0 || len(d) < caLen {
+					return false
+				}
+				m.certificateAuthorities = append(m.certificateAuthorities, d[:caLen])
+				d = d[caLen:]
+			}
+		}
+		data = data[length:]
+	}
+
+	return true
+}
+
+type newSessionTicketMsg struct {
+	raw    []byte
+	ticket []byte
+}
+
+func (m *newSessionTicketMsg) equal(i interface{}) bool {
+	m1, ok := i.(*newSessionTicketMsg)
+	if !ok {
+		return false
+	}
+
+	return bytes.Equal(m.raw, m1.raw) &&
+		bytes.Equal(m.ticket, m1.ticket)
+}
+
+func (m *newSessionTicketMsg) marshal() (x []byte) {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	// See http://tools.ietf.org/html/rfc5077#section-3.3
+	ticketLen := len(m.ticket)
+	length := 2 + 4 + ticketLen
+	x = make([]byte, 4+length)
+	x[0] = typeNewSessionTicket
+	x[1] = uint8(length >> 16)
+	x[2] = uint8(length >> 8)
+	x[3] = uint8(length)
+	x[8] = uint8(ticketLen >> 8)
+	x[9] = uint8(ticketLen)
+	copy(x[10:], m.ticket)
+
+	m.raw = x
+
+	return
+}
+
+func (m *newSessionTicketMsg) unmarshal(data []byte) bool {
+	m.raw = data
+
+	if len(data) < 10 {
+		return false
+	}
+
+	length := uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	if uint32(len(data))-4 != length {
+		return false
+	}
+
+	ticketLen := int(data[8])<<8 + int(data[9])
+	if len(data)-10 != ticketLen {
+		return false
+	}
+
+	m.ticket = data[10:]
+
+	return true
+}
+
+// newSessionTicketMsgTLS13 is the TLS 1.3 NewSessionTicket message, sent
+// after the handshake. See RFC 8446, section 4.6.1.
+type newSessionTicketMsgTLS13 struct {
+	raw      []byte
+	lifetime uint32
+	ageAdd   uint32
+	nonce    []byte
+	label    []byte
+}
+
+func (m *newSessionTicketMsgTLS13) equal(i interface{}) bool {
+	m1, ok := i.(*newSessionTicketMsgTLS13)
+	if !ok {
+		return false
+	}
+
+	return bytes.Equal(m.raw, m1.raw) &&
+		m.lifetime == m1.lifetime &&
+		m.ageAdd == m1.ageAdd &&
+		bytes.Equal(m.nonce, m1.nonce) &&
+		bytes.Equal(m.label, m1.label)
+}
+
+func (m *newSessionTicketMsgTLS13) marshal() (x []byte) {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	length := 4 + 4 + 1 + len(m.nonce) + 2 + len(m.label) + 2
+	x = make([]byte, 4+length)
+	x[0] = typeNewSessionTicket
+	x[1] = uint8(length >> 16)
+	x[2] = uint8(length >> 8)
+	x[3] = uint8(length)
+	x[4] = uint8(m.lifetime >> 24)
+	x[5] = uint8(m.lifetime >> 16)
+	x[6] = uint8(m.lifetime >> 8)
+	x[7] = uint8(m.lifetime)
+	x[8] = uint8(m.ageAdd >> 24)
+	x[9] = uint8(m.ageAdd >> 16)
+	x[10] = uint8(m.ageAdd >> 8)
+	x[11] = uint8(m.ageAdd)
+	x[12] = uint8(len(m.nonce))
+	copy(x[13:], m.nonce)
+	z := x[13+len(m.nonce):]
+	z[0] = uint8(len(m.label) >> 8)
+	z[1] = uint8(len(m.label))
+	copy(z[2:], m.label)
+	// The extensions, which this package doesn't send, follow as an empty
+	// vector.
+
+	m.raw = x
+
+	return
+}
+
+func (m *newSessionTicketMsgTLS13) unmarshal(data []byte) bool {
+	m.raw = data
+
+	if len(data) < 13 {
+		return false
+	}
+
+	length := uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	if uint32(len(data))-4 != length {
+		return false
+	}
+
+	m.lifetime = uint32(data[4])<<24 | uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])
+	m.ageAdd = uint32(data[8])<<24 | uint32(data[9])<<16 | uint32(data[10])<<8 | uint32(data[11])
+	nonceLen := int(data[12])
+	data = data[13:]
+	if len(data) < nonceLen+2 {
+		return false
+	}
+	m.nonce = data[:nonceLen]
+	data = data[nonceLen:]
+
+	labelLen := int(data[0])<<8 | int(data[1])
+	data = data[2:]
+	if labelLen == 0 || len(data) < labelLen+2 {
+		return false
+	}
+	m.label = data[:labelLen]
+	data = data[labelLen:]
+
+	// Extensions, such as early_data, are ignored.
+	extensionsLength := int(data[0])<<8 | int(data[1])
+	if len(data)-2 != extensionsLength {
+		return false
+	}
+
+	return true
+}
+
+// keyUpdateMsg is the TLS 1.3 KeyUpdate message. See RFC 8446, section
+// 4.6.3.
+type keyUpdateMsg struct {
+	raw             []byte
+	updateRequested bool
+}
+
+func (m *keyUpdateMsg) equal(i interface{}) bool {
+	m1, ok := i.(*keyUpdateMsg)
+	if !ok {
+		return false
+	}
+
+	return bytes.Equal(m.raw, m1.raw) &&
+		m.updateRequested == m1.updateRequested
+}
+
+func (m *keyUpdateMsg) marshal() (x []byte) {
+	if m.raw != nil {
+		return m.raw
+	}
+
+	x = []byte{typeKeyUpdate, 0, 0, 1, keyUpdateNotRequested}
+	if m.updateRequested {
+		x[4] = keyUpdateRequested
+	}
+
+	m.raw = x
+
+	return
+}
+
+func (m *keyUpdateMsg) unmarshal(data []byte) bool {
+	m.raw = data
+
+	if len(data) != 5 || data[1] != 0 || data[2] != 0 || data[3] != 1 {
+		return false
+	}
+
+	switch data[4] {
+	case keyUpdateNotRequested:
+		m.updateRequested = false
+	case keyUpdateRequested:
+		m.updateRequested = true
+	default:
+		return false
+	}
+
+	return true
+}
+
+func eqUint16s(x, y []uint16) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, v := range x {
+		if y[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func eqCurveIDs(x, y []CurveID) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, v := range x {
+		if y[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func eqStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, v := range x {
+		if y[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func eqByteSlices(x, y [][]byte) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, v := range x {
+		if !bytes.Equal(v, y[i]) {
 			return false
 		}
 	}
@@ -1436,3 +2462,27 @@ func eqSignatureAndHashes(x, y []signatureAndHash) bool {
 	}
 	return true
 }
+
+func eqKeyShares(x, y []keyShare) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].group != y[i].group || !bytes.Equal(x[i].data, y[i].data) {
+			return false
+		}
+	}
+	return true
+}
+
+func eqPSKIdentities(x, y []pskIdentity) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !bytes.Equal(x[i].label, y[i].label) || x[i].obfuscatedTicketAge != y[i].obfuscatedTicketAge {
+			return false
+		}
+	}
+	return true
+}
@@ -24,6 +24,12 @@ var tests = []interface{}{
 	&nextProtoMsg{},
 	&newSessionTicketMsg{},
 	&sessionState{},
+	&encryptedExtensionsMsg{},
+	&certificateMsgTLS13{},
+	&certificateRequestMsgTLS13{},
+	&newSessionTicketMsgTLS13{},
+	&keyUpdateMsg{},
+	&sessionStateTLS13{},
 }
 
 type testMessage interface {
@@ -142,6 +148,27 @@ func (*clientHelloMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 	for i := range m.alpnProtocols {
 		m.alpnProtocols[i] = randomString(rand.Intn(20)+1, rand)
 	}
+	if rand.Intn(10) > 5 {
+		m.supportedVersions = []uint16{VersionTLS13, VersionTLS12}
+		m.keyShares = make([]keyShare, rand.Intn(3)+1)
+		for i := range m.keyShares {
+			m.keyShares[i].group = CurveID(rand.Intn(30000))
+			m.keyShares[i].data = randomBytes(rand.Intn(100)+1, rand)
+		}
+		m.pskModes = []uint8{pskModeDHE}
+	}
+	if rand.Intn(10) > 5 {
+		m.cookie = randomBytes(rand.Intn(500)+1, rand)
+	}
+	if rand.Intn(10) > 5 {
+		m.pskIdentities = make([]pskIdentity, rand.Intn(3)+1)
+		m.pskBinders = make([][]byte, len(m.pskIdentities))
+		for i := range m.pskIdentities {
+			m.pskIdentities[i].label = randomBytes(rand.Intn(500)+1, rand)
+			m.pskIdentities[i].obfuscatedTicketAge = uint32(rand.Int31())
+			m.pskBinders[i] = randomBytes(rand.Intn(32)+32, rand)
+		}
+	}
 
 	return reflect.ValueOf(m)
 }
@@ -172,6 +199,23 @@ func (*serverHelloMsg) Generate(rand *rand.Rand, size int) reflect.Value {
 	}
 	m.alpnProtocol = randomString(rand.Intn(32)+1, rand)
 
+	if rand.Intn(10) > 5 {
+		m.supportedVersion = VersionTLS13
+		if rand.Intn(10) > 5 {
+			m.selectedGroup = CurveID(rand.Intn(30000) + 1)
+			if rand.Intn(10) > 5 {
+				m.cookie = randomBytes(rand.Intn(500)+1, rand)
+			}
+		} else {
+			m.serverShare.group = CurveID(rand.Intn(30000) + 1)
+			m.serverShare.data = randomBytes(rand.Intn(100)+1, rand)
+			if rand.Intn(10) > 5 {
+				m.selectedIdentityPresent = true
+				m.selectedIdentity = uint16(rand.Intn(0xffff))
+			}
+		}
+	}
+
 	return reflect.ValueOf(m)
 }
 
@@ -249,3 +293,66 @@ func (*sessionState) Generate(rand *rand.Rand, size int) reflect.Value {
 	}
 	return reflect.ValueOf(s)
 }
+
+func (*encryptedExtensionsMsg) Generate(rand *rand.Rand, size int) reflect.Value {
+	m := &encryptedExtensionsMsg{}
+	if rand.Intn(10) > 5 {
+		m.alpnProtocol = randomString(rand.Intn(32)+1, rand)
+	}
+	return reflect.ValueOf(m)
+}
+
+func (*certificateMsgTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
+	m := &certificateMsgTLS13{}
+	numCerts := rand.Intn(20)
+	m.certificates = make([][]byte, numCerts)
+	for i := 0; i < numCerts; i++ {
+		m.certificates[i] = randomBytes(rand.Intn(10)+1, rand)
+	}
+	if numCerts > 0 && rand.Intn(10) > 5 {
+		m.ocspStapling = true
+		m.ocspStaple = randomBytes(rand.Intn(100)+1, rand)
+	}
+	return reflect.ValueOf(m)
+}
+
+func (*certificateRequestMsgTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
+	m := &certificateRequestMsgTLS13{}
+	if rand.Intn(10) > 5 {
+		m.signatureAndHashes = supportedSignatureAlgorithmsTLS13
+	}
+	numCAs := rand.Intn(100)
+	m.certificateAuthorities = make([][]byte, numCAs)
+	for i := 0; i < numCAs; i++ {
+		m.certificateAuthorities[i] = randomBytes(rand.Intn(15)+1, rand)
+	}
+	return reflect.ValueOf(m)
+}
+
+func (*newSessionTicketMsgTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
+	m := &newSessionTicketMsgTLS13{}
+	m.lifetime = uint32(rand.Intn(500000))
+	m.ageAdd = uint32(rand.Intn(500000))
+	m.nonce = randomBytes(rand.Intn(100), rand)
+	m.label = randomBytes(rand.Intn(1000)+1, rand)
+	return reflect.ValueOf(m)
+}
+
+func (*keyUpdateMsg) Generate(rand *rand.Rand, size int) reflect.Value {
+	m := &keyUpdateMsg{}
+	m.updateRequested = rand.Intn(10) > 5
+	return reflect.ValueOf(m)
+}
+
+func (*sessionStateTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
+	s := &sessionStateTLS13{}
+	s.cipherSuite = uint16(rand.Intn(10000))
+	s.createdAt = uint64(rand.Int63())
+	s.psk = randomBytes(rand.Intn(100)+1, rand)
+	numCerts := rand.Intn(20)
+	s.certificates = make([][]byte, numCerts)
+	for i := 0; i < numCerts; i++ {
+		s.certificates[i] = randomBytes(rand.Intn(10)+1, rand)
+	}
+	return reflect.ValueOf(s)
+}
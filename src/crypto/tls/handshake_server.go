@@ -7,6 +7,7 @@ package tls
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/subtle"
 	"crypto/x509"
@@ -40,10 +41,24 @@ func (c *Conn) serverHandshake() error {
 	// encrypt the tickets with.
 	config.serverInitOnce.Do(config.serverInit)
 
+	clientHello, err := c.readClientHello()
+	if err != nil {
+		return err
+	}
+
+	if c.vers == VersionTLS13 {
+		hs := serverHandshakeStateTLS13{
+			c:           c,
+			clientHello: clientHello,
+		}
+		return hs.handshake()
+	}
+
 	hs := serverHandshakeState{
-		c: c,
+		c:           c,
+		clientHello: clientHello,
 	}
-	isResume, err := hs.readClientHello()
+	isResume, err := hs.processClientHello()
 	if err != nil {
 		return err
 	}
@@ -88,29 +103,49 @@ func (c *Conn) serverHandshake() error {
 	return nil
 }
 
-// readClientHello reads a ClientHello message from the client and decides
-// whether we will perform session resumption.
-func (hs *serverHandshakeState) readClientHello() (isResume bool, err error) {
-	config := hs.c.config
-	c := hs.c
-
+// readClientHello reads a ClientHello message from the client and
+// negotiates the protocol version.
+func (c *Conn) readClientHello() (*clientHelloMsg, error) {
 	msg, err := c.readHandshake()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	var ok bool
-	hs.clientHello, ok = msg.(*clientHelloMsg)
+	clientHello, ok := msg.(*clientHelloMsg)
 	if !ok {
 		c.sendAlert(alertUnexpectedMessage)
-		return false, unexpectedMessageError(hs.clientHello, msg)
+		return nil, unexpectedMessageError(clientHello, msg)
 	}
-	c.vers, ok = config.mutualVersion(hs.clientHello.vers)
-	if !ok {
-		c.sendAlert(alertProtocolVersion)
-		return false, fmt.Errorf("tls: client offered an unsupported, maximum protocol version of %x", hs.clientHello.vers)
+
+	if len(clientHello.supportedVersions) > 0 {
+		c.vers, ok = c.config.mutualVersionTLS13(clientHello.supportedVersions)
+		if !ok {
+			c.sendAlert(alertProtocolVersion)
+			return nil, fmt.Errorf("tls: client offered only unsupported versions: %x", clientHello.supportedVersions)
+		}
+	} else {
+		// TLS 1.3 can only be negotiated through the
+		// supported_versions extension.
+		vers := clientHello.vers
+		if vers > VersionTLS12 {
+			vers = VersionTLS12
+		}
+		c.vers, ok = c.config.mutualVersion(vers)
+		if !ok {
+			c.sendAlert(alertProtocolVersion)
+			return nil, fmt.Errorf("tls: client offered an unsupported, maximum protocol version of %x", clientHello.vers)
+		}
 	}
 	c.haveVers = true
 
+	return clientHello, nil
+}
+
+// processClientHello processes the ClientHello of a TLS 1.2 or earlier
+// handshake and decides whether we will perform session resumption.
+func (hs *serverHandshakeState) processClientHello() (isResume bool, err error) {
+	config := hs.c.config
+	c := hs.c
+
 	hs.finishedHash = newFinishedHash(c.vers)
 	hs.finishedHash.Write(hs.clientHello.marshal())
 
@@ -158,6 +193,16 @@ Curves:
 		c.sendAlert(alertInternalError)
 		return false, err
 	}
+	if config.maxVersion() >= VersionTLS13 {
+		// Signal to a TLS 1.3 capable client that the lower version
+		// was not the result of a downgrade attack. See RFC 8446,
+		// section 4.1.3.
+		if c.vers == VersionTLS12 {
+			copy(hs.hello.random[24:], downgradeCanaryTLS12)
+		} else {
+			copy(hs.hello.random[24:], downgradeCanaryTLS11)
+		}
+	}
 	hs.hello.secureRenegotiation = hs.clientHello.secureRenegotiation
 	hs.hello.compressionMethod = compressionNone
 	if len(hs.clientHello.serverName) > 0 {
@@ -247,8 +292,12 @@ func (hs *serverHandshakeState) checkForResumption() bool {
 		return false
 	}
 
-	var ok bool
-	if hs.sessionState, ok = c.decryptTicket(hs.clientHello.sessionTicket); !ok {
+	plaintext, ok := c.decryptTicket(hs.clientHello.sessionTicket)
+	if !ok {
+		return false
+	}
+	hs.sessionState = new(sessionState)
+	if !hs.sessionState.unmarshal(plaintext) {
 		return false
 	}
 
@@ -300,9 +349,10 @@ func (hs *serverHandshakeState) doResumeHandshake() error {
 	c.writeRecord(recordTypeHandshake, hs.hello.marshal())
 
 	if len(hs.sessionState.certificates) > 0 {
-		if _, err := hs.processCertsFromClient(hs.sessionState.certificates); err != nil {
+		if _, err := c.processCertsFromClient(hs.sessionState.certificates); err != nil {
 			return err
 		}
+		hs.certsFromClient = hs.sessionState.certificates
 	}
 
 	hs.masterSecret = hs.sessionState.masterSecret
@@ -401,10 +451,11 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 			}
 		}
 
-		pub, err = hs.processCertsFromClient(certMsg.certificates)
+		pub, err = c.processCertsFromClient(certMsg.certificates)
 		if err != nil {
 			return err
 		}
+		hs.certsFromClient = certMsg.certificates
 
 		msg, err = c.readHandshake()
 		if err != nil {
@@ -455,6 +506,8 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 		case *rsa.PublicKey:
 			digest, hashFunc, _ := hs.finishedHash.hashForClientCertificate(signatureRSA)
 			err = rsa.VerifyPKCS1v15(key, hashFunc, digest, certVerify.signature)
+		default:
+			err = fmt.Errorf("unsupported public key of type %T", pub)
 		}
 		if err != nil {
 			c.sendAlert(alertBadCertificate)
@@ -558,7 +611,7 @@ func (hs *serverHandshakeState) sendSessionTicket() error {
 		masterSecret: hs.masterSecret,
 		certificates: hs.certsFromClient,
 	}
-	m.ticket, err = c.encryptTicket(&state)
+	m.ticket, err = c.encryptTicket(state.marshal())
 	if err != nil {
 		return err
 	}
@@ -586,12 +639,9 @@ func (hs *serverHandshakeState) sendFinished(out []byte) error {
 }
 
 // processCertsFromClient takes a chain of client certificates either from a
-// Certificates message or from a sessionState and verifies them. It returns
-// the public key of the leaf certificate.
-func (hs *serverHandshakeState) processCertsFromClient(certificates [][]byte) (crypto.PublicKey, error) {
-	c := hs.c
-
-	hs.certsFromClient = certificates
+// Certificates message or from a session ticket and verifies them. It
+// returns the public key of the leaf certificate.
+func (c *Conn) processCertsFromClient(certificates [][]byte) (crypto.PublicKey, error) {
 	certs := make([]*x509.Certificate, len(certificates))
 	var err error
 	for i, asn1Data := range certificates {
@@ -637,7 +687,7 @@ func (hs *serverHandshakeState) processCertsFromClient(certificates [][]byte) (c
 	if len(certs) > 0 {
 		var pub crypto.PublicKey
 		switch key := certs[0].PublicKey.(type) {
-		case *ecdsa.PublicKey, *rsa.PublicKey:
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
 			pub = key
 		default:
 			c.sendAlert(alertUnsupportedCertificate)
@@ -5,8 +5,10 @@
 package tls
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/md5"
 	"crypto/rsa"
@@ -15,8 +17,9 @@ import (
 	"crypto/x509"
 	"encoding/asn1"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
-	"math/big"
 )
 
 var errClientKeyExchange = errors.New("tls: invalid ClientKeyExchange message")
@@ -50,7 +53,11 @@ func (ka rsaKeyAgreement) processClientKeyExchange(config *Config, cert *Certifi
 		ciphertext = ckx.ciphertext[2:]
 	}
 
-	err = rsa.DecryptPKCS1v15SessionKey(config.rand(), cert.PrivateKey.(*rsa.PrivateKey), ciphertext, preMasterSecret)
+	privKey, ok := cert.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("RSA key exchange requires a RSA server private key")
+	}
+	err = rsa.DecryptPKCS1v15SessionKey(config.rand(), privKey, ciphertext, preMasterSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -129,6 +136,15 @@ func hashForServerKeyExchange(sigType, hashFunc uint8, version uint16, slices ..
 			return sha256Hash(slices), crypto.SHA256, nil
 		case hashSHA1:
 			return sha1Hash(slices), crypto.SHA1, nil
+		case hashSHA384, hashSHA512:
+			// These are only offered by clients that also offer
+			// TLS 1.3.
+			h := hashForSignatureScheme(signatureAndHash{hashFunc, sigType})
+			digest := h.New()
+			for _, slice := range slices {
+				digest.Write(slice)
+			}
+			return digest.Sum(nil), h, nil
 		default:
 			return nil, crypto.Hash(0), errors.New("tls: unknown hash function used by peer")
 		}
@@ -163,6 +179,179 @@ func pickTLS12HashForSignature(sigType uint8, clientSignatureAndHashes []signatu
 	return 0, errors.New("tls: client doesn't support any common hash functions")
 }
 
+// isSupportedSignatureAndHash returns true if sigAndHash is in
+// sigAndHashes.
+func isSupportedSignatureAndHash(sigAndHash signatureAndHash, sigAndHashes []signatureAndHash) bool {
+	for _, s := range sigAndHashes {
+		if s == sigAndHash {
+			return true
+		}
+	}
+	return false
+}
+
+// Signature contexts for TLS 1.3 CertificateVerify messages. See RFC 8446,
+// section 4.4.3.
+const (
+	serverSignatureContext = "TLS 1.3, server CertificateVerify\x00"
+	clientSignatureContext = "TLS 1.3, client CertificateVerify\x00"
+)
+
+var signaturePadding = []byte{
+	0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+	0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+	0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+	0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20, 0x20,
+}
+
+// signedMessageTLS13 returns the digest that is signed in a TLS 1.3
+// CertificateVerify message. See RFC 8446, section 4.4.3. If hashFunc is
+// zero, as it is for Ed25519, the message itself is returned instead.
+func signedMessageTLS13(hashFunc crypto.Hash, context string, transcript hash.Hash) []byte {
+	if hashFunc == 0 {
+		var b bytes.Buffer
+		b.Write(signaturePadding)
+		b.WriteString(context)
+		b.Write(transcript.Sum(nil))
+		return b.Bytes()
+	}
+	h := hashFunc.New()
+	h.Write(signaturePadding)
+	h.Write([]byte(context))
+	h.Write(transcript.Sum(nil))
+	return h.Sum(nil)
+}
+
+// hashForSignatureScheme returns the hash function used by the given
+// signature and hash combination, or zero if it isn't supported or, as for
+// Ed25519, signs the message directly.
+func hashForSignatureScheme(sigAndHash signatureAndHash) crypto.Hash {
+	if sigAndHash.hash == hashIntrinsic {
+		switch sigAndHash.signature {
+		case signatureRSAPSSSHA256:
+			return crypto.SHA256
+		case signatureRSAPSSSHA384:
+			return crypto.SHA384
+		case signatureRSAPSSSHA512:
+			return crypto.SHA512
+		}
+		return 0
+	}
+	switch sigAndHash.hash {
+	case hashSHA1:
+		return crypto.SHA1
+	case hashSHA256:
+		return crypto.SHA256
+	case hashSHA384:
+		return crypto.SHA384
+	case hashSHA512:
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// signerOptsForSignatureScheme returns the crypto.SignerOpts to use when
+// signing with the given signature and hash combination.
+func signerOptsForSignatureScheme(sigAndHash signatureAndHash) crypto.SignerOpts {
+	hashFunc := hashForSignatureScheme(sigAndHash)
+	if sigAndHash.hash == hashIntrinsic && sigAndHash.signature != signatureEd25519 {
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hashFunc}
+	}
+	return hashFunc
+}
+
+// signatureSchemeForKey returns the TLS 1.3 signature scheme to use with
+// the given public key, picked from those that the peer supports.
+func signatureSchemeForKey(pub crypto.PublicKey, peerSigAndHashes []signatureAndHash) (signatureAndHash, error) {
+	var candidates []signatureAndHash
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		// In TLS 1.3 the ECDSA hash is tied to the curve.
+		switch pub.Curve {
+		case elliptic.P256():
+			candidates = []signatureAndHash{{hashSHA256, signatureECDSA}}
+		case elliptic.P384():
+			candidates = []signatureAndHash{{hashSHA384, signatureECDSA}}
+		case elliptic.P521():
+			candidates = []signatureAndHash{{hashSHA512, signatureECDSA}}
+		}
+	case *rsa.PublicKey:
+		candidates = []signatureAndHash{
+			{hashIntrinsic, signatureRSAPSSSHA256},
+			{hashIntrinsic, signatureRSAPSSSHA384},
+			{hashIntrinsic, signatureRSAPSSSHA512},
+		}
+	case ed25519.PublicKey:
+		candidates = []signatureAndHash{{hashIntrinsic, signatureEd25519}}
+	default:
+		return signatureAndHash{}, fmt.Errorf("tls: unsupported certificate key type %T", pub)
+	}
+
+	for _, candidate := range candidates {
+		for _, sigAndHash := range peerSigAndHashes {
+			if sigAndHash == candidate {
+				return candidate, nil
+			}
+		}
+	}
+	return signatureAndHash{}, errors.New("tls: peer doesn't support the certificate's signature algorithms")
+}
+
+// verifyHandshakeSignature checks that sig is a valid signature of digest
+// by pub, using the given signature and hash combination. For Ed25519,
+// digest is the signed message itself.
+func verifyHandshakeSignature(sigAndHash signatureAndHash, pub crypto.PublicKey, digest, sig []byte) error {
+	if sigAndHash == (signatureAndHash{hashIntrinsic, signatureEd25519}) {
+		pubKey, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("tls: Ed25519 signature with a non-Ed25519 public key")
+		}
+		if !ed25519.Verify(pubKey, digest, sig) {
+			return errors.New("tls: Ed25519 verification failure")
+		}
+		return nil
+	}
+
+	hashFunc := hashForSignatureScheme(sigAndHash)
+	if hashFunc == 0 {
+		return errors.New("tls: unsupported signature algorithm")
+	}
+
+	if sigAndHash.hash == hashIntrinsic {
+		pubKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("tls: RSA-PSS signature with a non-RSA public key")
+		}
+		return rsa.VerifyPSS(pubKey, hashFunc, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+
+	switch sigAndHash.signature {
+	case signatureECDSA:
+		pubKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("tls: ECDSA signature with a non-ECDSA public key")
+		}
+		ecdsaSig := new(ecdsaSignature)
+		if _, err := asn1.Unmarshal(sig, ecdsaSig); err != nil {
+			return err
+		}
+		if ecdsaSig.R.Sign() <= 0 || ecdsaSig.S.Sign() <= 0 {
+			return errors.New("ECDSA signature contained zero or negative values")
+		}
+		if !ecdsa.Verify(pubKey, digest, ecdsaSig.R, ecdsaSig.S) {
+			return errors.New("ECDSA verification failure")
+		}
+		return nil
+	case signatureRSA:
+		pubKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("tls: RSA signature with a non-RSA public key")
+		}
+		return rsa.VerifyPKCS1v15(pubKey, hashFunc, digest, sig)
+	}
+	return errors.New("tls: unsupported signature algorithm")
+}
+
 func curveForCurveID(id CurveID) (elliptic.Curve, bool) {
 	switch id {
 	case CurveP256:
@@ -182,11 +371,14 @@ func curveForCurveID(id CurveID) (elliptic.Curve, bool) {
 // pre-master secret is then calculated using ECDH. The signature may
 // either be ECDSA or RSA.
 type ecdheKeyAgreement struct {
-	version    uint16
-	sigType    uint8
-	privateKey []byte
-	curve      elliptic.Curve
-	x, y       *big.Int
+	version uint16
+	sigType uint8
+	params  ecdheParameters
+
+	// ckx and preMasterSecret are generated in processServerKeyExchange
+	// and returned in generateClientKeyExchange.
+	ckx             *clientKeyExchangeMsg
+	preMasterSecret []byte
 }
 
 func (ka *ecdheKeyAgreement) generateServerKeyExchange(config *Config, cert *Certificate, clientHello *clientHelloMsg, hello *serverHelloMsg) (*serverKeyExchangeMsg, error) {
@@ -207,18 +399,16 @@ NextCandidate:
 		return nil, errors.New("tls: no supported elliptic curves offered")
 	}
 
-	var ok bool
-	if ka.curve, ok = curveForCurveID(curveid); !ok {
+	if _, ok := curveForCurveID(curveid); curveid != X25519 && !ok {
 		return nil, errors.New("tls: preferredCurves includes unsupported curve")
 	}
 
-	var x, y *big.Int
-	var err error
-	ka.privateKey, x, y, err = elliptic.GenerateKey(ka.curve, config.rand())
+	params, err := generateECDHEParameters(config.rand(), curveid)
 	if err != nil {
 		return nil, err
 	}
-	ecdhePublic := elliptic.Marshal(ka.curve, x, y)
+	ka.params = params
+	ecdhePublic := params.PublicKey()
 
 	// http://tools.ietf.org/html/rfc4492#section-5.4
 	serverECDHParams := make([]byte, 1+2+1+len(ecdhePublic))
@@ -288,17 +478,10 @@ func (ka *ecdheKeyAgreement) processClientKeyExchange(config *Config, cert *Cert
 	if len(ckx.ciphertext) == 0 || int(ckx.ciphertext[0]) != len(ckx.ciphertext)-1 {
 		return nil, errClientKeyExchange
 	}
-	x, y := elliptic.Unmarshal(ka.curve, ckx.ciphertext[1:])
-	if x == nil {
+	preMasterSecret := ka.params.SharedKey(ckx.ciphertext[1:])
+	if preMasterSecret == nil {
 		return nil, errClientKeyExchange
 	}
-	if !ka.curve.IsOnCurve(x, y) {
-		return nil, errClientKeyExchange
-	}
-	x, _ = ka.curve.ScalarMult(x, y, ka.privateKey)
-	preMasterSecret := make([]byte, (ka.curve.Params().BitSize+7)>>3)
-	xBytes := x.Bytes()
-	copy(preMasterSecret[len(preMasterSecret)-len(xBytes):], xBytes)
 
 	return preMasterSecret, nil
 }
@@ -312,8 +495,7 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 	}
 	curveid := CurveID(skx.key[1])<<8 | CurveID(skx.key[2])
 
-	var ok bool
-	if ka.curve, ok = curveForCurveID(curveid); !ok {
+	if _, ok := curveForCurveID(curveid); curveid != X25519 && !ok {
 		return errors.New("tls: server selected unsupported curve")
 	}
 
@@ -321,14 +503,23 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 	if publicLen+4 > len(skx.key) {
 		return errServerKeyExchange
 	}
-	ka.x, ka.y = elliptic.Unmarshal(ka.curve, skx.key[4:4+publicLen])
-	if ka.x == nil {
-		return errServerKeyExchange
+	serverECDHParams := skx.key[:4+publicLen]
+	publicKey := serverECDHParams[4:]
+
+	params, err := generateECDHEParameters(config.rand(), curveid)
+	if err != nil {
+		return err
 	}
-	if !ka.curve.IsOnCurve(ka.x, ka.y) {
+	ka.preMasterSecret = params.SharedKey(publicKey)
+	if ka.preMasterSecret == nil {
 		return errServerKeyExchange
 	}
-	serverECDHParams := skx.key[:4+publicLen]
+
+	ourPublicKey := params.PublicKey()
+	ka.ckx = new(clientKeyExchangeMsg)
+	ka.ckx.ciphertext = make([]byte, 1+len(ourPublicKey))
+	ka.ckx.ciphertext[0] = byte(len(ourPublicKey))
+	copy(ka.ckx.ciphertext[1:], ourPublicKey)
 
 	sig := skx.key[4+publicLen:]
 	if len(sig) < 2 {
@@ -336,11 +527,19 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 	}
 
 	var tls12HashId uint8
+	var pss signatureAndHash
 	if ka.version >= VersionTLS12 {
 		// handle SignatureAndHashAlgorithm
 		var sigAndHash []uint8
 		sigAndHash, sig = sig[:2], sig[2:]
-		if sigAndHash[1] != ka.sigType {
+		if sigAndHash[0] == hashIntrinsic && ka.sigType == signatureRSA {
+			// A client that offers TLS 1.3 also offers RSASSA-PSS,
+			// which a TLS 1.2 server may then pick.
+			pss = signatureAndHash{sigAndHash[0], sigAndHash[1]}
+			if !isSupportedSignatureAndHash(pss, clientHello.signatureAndHashes) {
+				return errServerKeyExchange
+			}
+		} else if sigAndHash[1] != ka.sigType {
 			return errServerKeyExchange
 		}
 		tls12HashId = sigAndHash[0]
@@ -354,6 +553,14 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 	}
 	sig = sig[2:]
 
+	if pss.hash != 0 {
+		h := hashForSignatureScheme(pss).New()
+		h.Write(clientHello.random)
+		h.Write(serverHello.random)
+		h.Write(serverECDHParams)
+		return verifyHandshakeSignature(pss, cert.PublicKey, h.Sum(nil), sig)
+	}
+
 	digest, hashFunc, err := hashForServerKeyExchange(ka.sigType, tls12HashId, ka.version, clientHello.random, serverHello.random, serverECDHParams)
 	if err != nil {
 		return err
@@ -390,24 +597,9 @@ func (ka *ecdheKeyAgreement) processServerKeyExchange(config *Config, clientHell
 }
 
 func (ka *ecdheKeyAgreement) generateClientKeyExchange(config *Config, clientHello *clientHelloMsg, cert *x509.Certificate) ([]byte, *clientKeyExchangeMsg, error) {
-	if ka.curve == nil {
+	if ka.ckx == nil {
 		return nil, nil, errors.New("missing ServerKeyExchange message")
 	}
-	priv, mx, my, err := elliptic.GenerateKey(ka.curve, config.rand())
-	if err != nil {
-		return nil, nil, err
-	}
-	x, _ := ka.curve.ScalarMult(ka.x, ka.y, priv)
-	preMasterSecret := make([]byte, (ka.curve.Params().BitSize+7)>>3)
-	xBytes := x.Bytes()
-	copy(preMasterSecret[len(preMasterSecret)-len(xBytes):], xBytes)
 
-	serialized := elliptic.Marshal(ka.curve, mx, my)
-
-	ckx := new(clientKeyExchangeMsg)
-	ckx.ciphertext = make([]byte, 1+len(serialized))
-	ckx.ciphertext[0] = byte(len(serialized))
-	copy(ckx.ciphertext[1:], serialized)
-
-	return preMasterSecret, ckx, nil
+	return ka.preMasterSecret, ka.ckx, nil
 }
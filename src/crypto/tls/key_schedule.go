@@ -0,0 +1,218 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/curve25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// This file contains the functions necessary to compute the TLS 1.3 key
+// schedule. See RFC 8446, section 7.
+
+const (
+	resumptionBinderLabel         = "res binder"
+	clientHandshakeTrafficLabel   = "c hs traffic"
+	serverHandshakeTrafficLabel   = "s hs traffic"
+	clientApplicationTrafficLabel = "c ap traffic"
+	serverApplicationTrafficLabel = "s ap traffic"
+	resumptionLabel               = "res master"
+	trafficUpdateLabel            = "traffic upd"
+)
+
+// hkdfExtract implements HKDF-Extract from RFC 5869, section 2.2.
+func hkdfExtract(hash func() hash.Hash, secret, salt []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, hash().Size())
+	}
+	h := hmac.New(hash, salt)
+	h.Write(secret)
+	return h.Sum(nil)
+}
+
+// hkdfExpand implements HKDF-Expand from RFC 5869, section 2.3.
+func hkdfExpand(hash func() hash.Hash, prk, info []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	h := hmac.New(hash, prk)
+	var prev []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		h.Reset()
+		h.Write(prev)
+		h.Write(info)
+		h.Write([]byte{counter})
+		prev = h.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+// expandLabel implements HKDF-Expand-Label from RFC 8446, section 7.1.
+func (c *cipherSuiteTLS13) expandLabel(secret []byte, label string, context []byte, length int) []byte {
+	const labelPrefix = "tls13 "
+	hkdfLabel := make([]byte, 0, 2+1+len(labelPrefix)+len(label)+1+len(context))
+	hkdfLabel = append(hkdfLabel, byte(length>>8), byte(length))
+	hkdfLabel = append(hkdfLabel, byte(len(labelPrefix)+len(label)))
+	hkdfLabel = append(hkdfLabel, labelPrefix...)
+	hkdfLabel = append(hkdfLabel, label...)
+	hkdfLabel = append(hkdfLabel, byte(len(context)))
+	hkdfLabel = append(hkdfLabel, context...)
+	return hkdfExpand(c.hash.New, secret, hkdfLabel, length)
+}
+
+// deriveSecret implements Derive-Secret from RFC 8446, section 7.1. A nil
+// transcript stands for the hash of no messages.
+func (c *cipherSuiteTLS13) deriveSecret(secret []byte, label string, transcript hash.Hash) []byte {
+	if transcript == nil {
+		transcript = c.hash.New()
+	}
+	return c.expandLabel(secret, label, transcript.Sum(nil), c.hash.Size())
+}
+
+// extract implements HKDF-Extract with the cipher suite hash. A nil
+// newSecret stands for a string of zeros, as used when there is no PSK or
+// no further input to the key schedule.
+func (c *cipherSuiteTLS13) extract(newSecret, currentSecret []byte) []byte {
+	if newSecret == nil {
+		newSecret = make([]byte, c.hash.Size())
+	}
+	return hkdfExtract(c.hash.New, newSecret, currentSecret)
+}
+
+// nextSecret returns the secret that feeds the next stage of the key
+// schedule after secret, mixing in newSecret (the ECDHE shared key, or nil
+// when deriving the master secret).
+func (c *cipherSuiteTLS13) nextSecret(secret, newSecret []byte) []byte {
+	return c.extract(newSecret, c.deriveSecret(secret, "derived", nil))
+}
+
+// nextTrafficSecret generates the next traffic secret, given the current
+// one, according to RFC 8446, section 7.2.
+func (c *cipherSuiteTLS13) nextTrafficSecret(trafficSecret []byte) []byte {
+	return c.expandLabel(trafficSecret, trafficUpdateLabel, nil, c.hash.Size())
+}
+
+// trafficKey generates traffic keys according to RFC 8446, section 7.3.
+func (c *cipherSuiteTLS13) trafficKey(trafficSecret []byte) (key, iv []byte) {
+	key = c.expandLabel(trafficSecret, "key", nil, c.keyLen)
+	iv = c.expandLabel(trafficSecret, "iv", nil, 12)
+	return
+}
+
+// finishedHash generates the Finished verify_data or PskBinderEntry
+// according to RFC 8446, section 4.4.4. See sections 4.4 and 4.2.11.2 for
+// the baseKey selection.
+func (c *cipherSuiteTLS13) finishedHash(baseKey []byte, transcript hash.Hash) []byte {
+	finishedKey := c.expandLabel(baseKey, "finished", nil, c.hash.Size())
+	verifyData := hmac.New(c.hash.New, finishedKey)
+	verifyData.Write(transcript.Sum(nil))
+	return verifyData.Sum(nil)
+}
+
+// messageHashMsg returns the synthetic message_hash handshake message that
+// replaces the first ClientHello in the transcript after a
+// HelloRetryRequest. See RFC 8446, section 4.4.1.
+func messageHashMsg(clientHelloHash []byte) []byte {
+	return append([]byte{typeMessageHash, 0, 0, uint8(len(clientHelloHash))}, clientHelloHash...)
+}
+
+// ecdheParameters implements the ECDHE key exchange of the TLS 1.3
+// key_share extension and of the TLS 1.2 ECDHE cipher suites. See RFC 8446,
+// section 4.2.8, and RFC 4492.
+type ecdheParameters interface {
+	CurveID() CurveID
+	PublicKey() []byte
+	SharedKey(peerPublicKey []byte) []byte
+}
+
+func generateECDHEParameters(rand io.Reader, curveID CurveID) (ecdheParameters, error) {
+	if curveID == X25519 {
+		p := &x25519Parameters{}
+		if _, err := io.ReadFull(rand, p.privateKey[:]); err != nil {
+			return nil, err
+		}
+		curve25519.ScalarBaseMult(&p.publicKey, &p.privateKey)
+		return p, nil
+	}
+
+	curve, ok := curveForCurveID(curveID)
+	if !ok {
+		return nil, errors.New("tls: internal error: unsupported curve")
+	}
+
+	p := &nistParameters{curveID: curveID}
+	var err error
+	p.privateKey, p.x, p.y, err = elliptic.GenerateKey(curve, rand)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type nistParameters struct {
+	privateKey []byte
+	x, y       *big.Int // public key
+	curveID    CurveID
+}
+
+func (p *nistParameters) CurveID() CurveID {
+	return p.curveID
+}
+
+func (p *nistParameters) PublicKey() []byte {
+	curve, _ := curveForCurveID(p.curveID)
+	return elliptic.Marshal(curve, p.x, p.y)
+}
+
+func (p *nistParameters) SharedKey(peerPublicKey []byte) []byte {
+	curve, _ := curveForCurveID(p.curveID)
+	x, y := elliptic.Unmarshal(curve, peerPublicKey)
+	if x == nil || !curve.IsOnCurve(x, y) {
+		return nil
+	}
+
+	xShared, _ := curve.ScalarMult(x, y, p.privateKey)
+	sharedKey := make([]byte, (curve.Params().BitSize+7)>>3)
+	xBytes := xShared.Bytes()
+	copy(sharedKey[len(sharedKey)-len(xBytes):], xBytes)
+
+	return sharedKey
+}
+
+type x25519Parameters struct {
+	privateKey [32]byte
+	publicKey  [32]byte
+}
+
+func (p *x25519Parameters) CurveID() CurveID {
+	return X25519
+}
+
+func (p *x25519Parameters) PublicKey() []byte {
+	return p.publicKey[:]
+}
+
+func (p *x25519Parameters) SharedKey(peerPublicKey []byte) []byte {
+	if len(peerPublicKey) != 32 {
+		return nil
+	}
+	var theirPublicKey, sharedKey [32]byte
+	copy(theirPublicKey[:], peerPublicKey)
+	curve25519.ScalarMult(&sharedKey, &p.privateKey, &theirPublicKey)
+
+	// Reject low-order points, which would make the shared secret all
+	// zeros. See RFC 7748, section 6.1.
+	var zero [32]byte
+	if subtle.ConstantTimeCompare(sharedKey[:], zero[:]) == 1 {
+		return nil
+	}
+	return sharedKey[:]
+}
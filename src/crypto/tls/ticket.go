@@ -130,8 +130,105 @@ func (s *sessionState) unmarshal(data []byte) bool {
 	return true
 }
 
-func (c *Conn) encryptTicket(state *sessionState) ([]byte, error) {
-	serialized := state.marshal()
+// sessionStateTLS13 is the content of a TLS 1.3 session ticket. It starts
+// with the version, like sessionState, so that the two cannot be confused.
+type sessionStateTLS13 struct {
+	cipherSuite  uint16
+	createdAt    uint64 // seconds since the Unix epoch
+	psk          []byte // the PSK for the resumed session
+	certificates [][]byte
+}
+
+func (s *sessionStateTLS13) equal(i interface{}) bool {
+	s1, ok := i.(*sessionStateTLS13)
+	if !ok {
+		return false
+	}
+
+	return s.cipherSuite == s1.cipherSuite &&
+		s.createdAt == s1.createdAt &&
+		bytes.Equal(s.psk, s1.psk) &&
+		eqByteSlices(s.certificates, s1.certificates)
+}
+
+func (s *sessionStateTLS13) marshal() []byte {
+	length := 2 + 2 + 8 + 1 + len(s.psk) + 2
+	for _, cert := range s.certificates {
+		length += 4 + len(cert)
+	}
+
+	ret := make([]byte, length)
+	x := ret
+	x[0] = byte(VersionTLS13 >> 8)
+	x[1] = byte(VersionTLS13 & 0xff)
+	x[2] = byte(s.cipherSuite >> 8)
+	x[3] = byte(s.cipherSuite)
+	for i := 0; i < 8; i++ {
+		x[4+i] = byte(s.createdAt >> uint(56-8*i))
+	}
+	x[12] = byte(len(s.psk))
+	x = x[13:]
+	copy(x, s.psk)
+	x = x[len(s.psk):]
+
+	x[0] = byte(len(s.certificates) >> 8)
+	x[1] = byte(len(s.certificates))
+	x = x[2:]
+
+	for _, cert := range s.certificates {
+		x[0] = byte(len(cert) >> 24)
+		x[1] = byte(len(cert) >> 16)
+		x[2] = byte(len(cert) >> 8)
+		x[3] = byte(len(cert))
+		copy(x[4:], cert)
+		x = x[4+len(cert):]
+	}
+
+	return ret
+}
+
+func (s *sessionStateTLS13) unmarshal(data []byte) bool {
+	if len(data) < 13 {
+		return false
+	}
+
+	if uint16(data[0])<<8|uint16(data[1]) != VersionTLS13 {
+		return false
+	}
+	s.cipherSuite = uint16(data[2])<<8 | uint16(data[3])
+	s.createdAt = 0
+	for i := 0; i < 8; i++ {
+		s.createdAt = s.createdAt<<8 | uint64(data[4+i])
+	}
+	pskLen := int(data[12])
+	data = data[13:]
+	if pskLen == 0 || len(data) < pskLen+2 {
+		return false
+	}
+	s.psk = data[:pskLen]
+	data = data[pskLen:]
+
+	numCerts := int(data[0])<<8 | int(data[1])
+	data = data[2:]
+
+	s.certificates = make([][]byte, numCerts)
+	for i := range s.certificates {
+		if len(data) < 4 {
+			return false
+		}
+		certLen := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if certLen < 0 || len(data) < certLen {
+			return false
+		}
+		s.certificates[i] = data[:certLen]
+		data = data[certLen:]
+	}
+
+	return len(data) == 0
+}
+
+func (c *Conn) encryptTicket(serialized []byte) ([]byte, error) {
 	encrypted := make([]byte, aes.BlockSize+len(serialized)+sha256.Size)
 	iv := encrypted[:aes.BlockSize]
 	macBytes := encrypted[len(encrypted)-sha256.Size:]
@@ -152,7 +249,9 @@ func (c *Conn) encryptTicket(state *sessionState) ([]byte, error) {
 	return encrypted, nil
 }
 
-func (c *Conn) decryptTicket(encrypted []byte) (*sessionState, bool) {
+// decryptTicket authenticates and decrypts a ticket made by encryptTicket
+// and returns the serialized session state.
+func (c *Conn) decryptTicket(encrypted []byte) ([]byte, bool) {
 	if c.config.SessionTicketsDisabled ||
 		len(encrypted) < aes.BlockSize+sha256.Size {
 		return nil, false
@@ -174,10 +273,8 @@ func (c *Conn) decryptTicket(encrypted []byte) (*sessionState, bool) {
 		return nil, false
 	}
 	ciphertext := encrypted[aes.BlockSize : len(encrypted)-sha256.Size]
-	plaintext := ciphertext
+	plaintext := make([]byte, len(ciphertext))
 	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
 
-	state := new(sessionState)
-	ok := state.unmarshal(plaintext)
-	return state, ok
+	return plaintext, true
 }
@@ -280,3 +280,72 @@ func TestTLSUniqueMatches(t *testing.T) {
 		t.Error("client and server channel bindings differ when session resumption is used")
 	}
 }
+
+func TestKeyUpdate(t *testing.T) {
+	ln := newLocalListener(t)
+	defer ln.Close()
+
+	serverConfig := *testConfig
+	serverConfig.MaxVersion = VersionTLS13
+
+	errChan := make(chan error, 1)
+	go func() {
+		sconn, err := ln.Accept()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer sconn.Close()
+		srv := Server(sconn, &serverConfig)
+		if err := srv.Handshake(); err != nil {
+			errChan <- err
+			return
+		}
+		srv.out.Lock()
+		err = srv.sendKeyUpdateLocked(cipherSuiteTLS13ByID(srv.cipherSuite), true)
+		srv.out.Unlock()
+		if err != nil {
+			errChan <- err
+			return
+		}
+		if _, err := srv.Write([]byte("ping")); err != nil {
+			errChan <- err
+			return
+		}
+		// The client's reply is protected with its updated keys.
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(srv, buf); err != nil {
+			errChan <- err
+			return
+		}
+		if string(buf) != "pong" {
+			errChan <- fmt.Errorf("server read %q, expected %q", buf, "pong")
+			return
+		}
+		errChan <- nil
+	}()
+
+	clientConfig := *testConfig
+	clientConfig.MaxVersion = VersionTLS13
+	conn, err := Dial("tcp", ln.Addr().String(), &clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if v := conn.ConnectionState().Version; v != VersionTLS13 {
+		t.Fatalf("negotiated version %x, expected %x", v, VersionTLS13)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("client read %q, expected %q", buf, "ping")
+	}
+	if _, err := conn.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+}
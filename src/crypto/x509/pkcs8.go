@@ -5,6 +5,7 @@
 package x509
 
 import (
+	"crypto/ed25519"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
@@ -48,6 +49,21 @@ func ParsePKCS8PrivateKey(der []byte) (key interface{}, err error) {
 		}
 		return key, nil
 
+	case privKey.Algo.Algorithm.Equal(oidPublicKeyEd25519):
+		// RFC 8410, section 7: the parameters must be absent and the
+		// private key is the seed, wrapped in an OCTET STRING.
+		if len(privKey.Algo.Parameters.FullBytes) != 0 {
+			return nil, errors.New("x509: invalid Ed25519 private key parameters")
+		}
+		var seed []byte
+		if _, err := asn1.Unmarshal(privKey.PrivateKey, &seed); err != nil {
+			return nil, errors.New("x509: failed to parse Ed25519 private key embedded in PKCS#8: " + err.Error())
+		}
+		if l := len(seed); l != ed25519.SeedSize {
+			return nil, fmt.Errorf("x509: invalid Ed25519 private key length: %d", l)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+
 	default:
 		return nil, fmt.Errorf("x509: PKCS#8 wrapping contained private key with unknown algorithm: %v", privKey.Algo.Algorithm)
 	}
@@ -5,6 +5,8 @@
 package x509
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"encoding/hex"
 	"testing"
 )
@@ -15,6 +17,10 @@ var pkcs8RSAPrivateKeyHex = `30820278020100300d06092a864886f70d01010105000482026
 //   openssl ecparam -genkey -name secp521r1 | openssl pkcs8 -topk8 -nocrypt
 var pkcs8ECPrivateKeyHex = `3081ed020100301006072a8648ce3d020106052b810400230481d53081d20201010441850d81618c5da1aec74c2eed608ba816038506975e6427237c2def150c96a3b13efbfa1f89f1be15cdf4d0ac26422e680e65a0ddd4ad3541ad76165fbf54d6e34ba18189038186000400da97bcedba1eb6d30aeb93c9f9a1454598fa47278df27d6f60ea73eb672d8dc528a9b67885b5b5dcef93c9824f7449ab512ee6a27e76142f56b94b474cfd697e810046c8ca70419365245c1d7d44d0db82c334073835d002232714548abbae6e5700f5ef315ee08b929d8581383dcf2d1c98c2f8a9fccbf79c9579f7b2fd8a90115ac2`
 
+// Generated using:
+//   openssl genpkey -algorithm ed25519 -outform DER
+var pkcs8Ed25519PrivateKeyHex = `302e020100300506032b657004220420e60f93a36c68a1e25f401ce68e14b0980d0642a0057784ab70b880ba6cbc8119`
+
 func TestPKCS8(t *testing.T) {
 	derBytes, _ := hex.DecodeString(pkcs8RSAPrivateKeyHex)
 	if _, err := ParsePKCS8PrivateKey(derBytes); err != nil {
@@ -25,4 +31,14 @@ func TestPKCS8(t *testing.T) {
 	if _, err := ParsePKCS8PrivateKey(derBytes); err != nil {
 		t.Errorf("failed to decode PKCS8 with EC private key: %s", err)
 	}
+
+	derBytes, _ = hex.DecodeString(pkcs8Ed25519PrivateKeyHex)
+	key, err := ParsePKCS8PrivateKey(derBytes)
+	if err != nil {
+		t.Errorf("failed to decode PKCS8 with Ed25519 private key: %s", err)
+	} else if priv, ok := key.(ed25519.PrivateKey); !ok {
+		t.Errorf("PKCS8 with Ed25519 private key decoded to %T", key)
+	} else if seed, _ := hex.DecodeString("e60f93a36c68a1e25f401ce68e14b0980d0642a0057784ab70b880ba6cbc8119"); !bytes.Equal(priv.Seed(), seed) {
+		t.Errorf("Ed25519 private key has seed %x, want %x", priv.Seed(), seed)
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -303,6 +304,11 @@ func TestCreateSelfSignedCertificate(t *testing.T) {
 		t.Fatalf("Failed to generate ECDSA key: %s", err)
 	}
 
+	ed25519Pub, ed25519Priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %s", err)
+	}
+
 	tests := []struct {
 		name      string
 		pub, priv interface{}
@@ -313,6 +319,9 @@ func TestCreateSelfSignedCertificate(t *testing.T) {
 		{"RSA/ECDSA", &rsaPriv.PublicKey, ecdsaPriv, false, ECDSAWithSHA384},
 		{"ECDSA/RSA", &ecdsaPriv.PublicKey, rsaPriv, false, SHA256WithRSA},
 		{"ECDSA/ECDSA", &ecdsaPriv.PublicKey, ecdsaPriv, true, ECDSAWithSHA1},
+		{"RSA/Ed25519", &rsaPriv.PublicKey, ed25519Priv, false, PureEd25519},
+		{"Ed25519/ECDSA", ed25519Pub, ecdsaPriv, false, ECDSAWithSHA256},
+		{"Ed25519/Ed25519", ed25519Pub, ed25519Priv, true, PureEd25519},
 	}
 
 	testExtKeyUsage := []ExtKeyUsage{ExtKeyUsageClientAuth, ExtKeyUsageServerAuth}
@@ -556,6 +565,68 @@ func TestECDSA(t *testing.T) {
 	}
 }
 
+// Generated using:
+//   openssl genpkey -algorithm ed25519 -out key.pem
+//   openssl pkey -in key.pem -pubout
+//   openssl req -x509 -key key.pem -subj /CN=Ed25519 -days 36500 -set_serial 1
+var pemEd25519PublicKey = `-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VwAyEANNTnGl5l5Qc+sUBCBf33l3kyEFm/fE0d4Dh/4vZSAkc=
+-----END PUBLIC KEY-----
+`
+
+var pemEd25519Certificate = `-----BEGIN CERTIFICATE-----
+MIIBJzCB2qADAgECAgEBMAUGAytlcDASMRAwDgYDVQQDDAdFZDI1NTE5MCAXDTI2
+MTAxNzAxMjU1MloYDzIxMjYwOTIzMDEyNTUyWjASMRAwDgYDVQQDDAdFZDI1NTE5
+MCowBQYDK2VwAyEANNTnGl5l5Qc+sUBCBf33l3kyEFm/fE0d4Dh/4vZSAkejUzBR
+MB0GA1UdDgQWBBRhfmnTa3B5NyvtRlHQqIN+YuAMVjAfBgNVHSMEGDAWgBRhfmnT
+a3B5NyvtRlHQqIN+YuAMVjAPBgNVHRMBAf8EBTADAQH/MAUGAytlcANBAEch/84H
+YE71vNnlc57ijFO3r7NbFppLh9FSLw7vwpXd0Zo81HZBN5+UuNdkTDGFqfrV2Knr
+/5AGzRzHgTD4NAs=
+-----END CERTIFICATE-----
+`
+
+func TestEd25519(t *testing.T) {
+	block, _ := pem.Decode([]byte(pemEd25519PublicKey))
+	pub, err := ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse Ed25519 public key: %s", err)
+	}
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		t.Fatalf("Value returned from ParsePKIXPublicKey was not an Ed25519 public key")
+	}
+	pubBytes, err := MarshalPKIXPublicKey(edPub)
+	if err != nil {
+		t.Fatalf("Failed to marshal Ed25519 public key: %s", err)
+	}
+	if !bytes.Equal(pubBytes, block.Bytes) {
+		t.Errorf("Reserialization of public key didn't match. got %x, want %x", pubBytes, block.Bytes)
+	}
+
+	block, _ = pem.Decode([]byte(pemEd25519Certificate))
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %s", err)
+	}
+	if sa := cert.SignatureAlgorithm; sa != PureEd25519 {
+		t.Errorf("signature algorithm is %v, want PureEd25519", sa)
+	}
+	if pka := cert.PublicKeyAlgorithm; pka != Ed25519 {
+		t.Errorf("public key algorithm is %v, want Ed25519", pka)
+	}
+	if !bytes.Equal(cert.PublicKey.(ed25519.PublicKey), edPub) {
+		t.Errorf("certificate public key is %x, want %x", cert.PublicKey, edPub)
+	}
+	if err := cert.CheckSignatureFrom(cert); err != nil {
+		t.Errorf("certificate verification failed: %s", err)
+	}
+
+	cert.Signature[0] ^= 1
+	if err := cert.CheckSignatureFrom(cert); err == nil {
+		t.Errorf("certificate with corrupted signature verified")
+	}
+}
+
 // Self-signed certificate using DSA with SHA1
 var dsaCertPem = `-----BEGIN CERTIFICATE-----
 MIIEDTCCA82gAwIBAgIJALHPghaoxeDhMAkGByqGSM44BAMweTELMAkGA1UEBhMC
@@ -777,6 +848,11 @@ func TestCreateCertificateRequest(t *testing.T) {
 		t.Fatalf("Failed to generate ECDSA key: %s", err)
 	}
 
+	_, ed25519Priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %s", err)
+	}
+
 	tests := []struct {
 		name    string
 		priv    interface{}
@@ -786,6 +862,7 @@ func TestCreateCertificateRequest(t *testing.T) {
 		{"ECDSA-256", ecdsa256Priv, ECDSAWithSHA1},
 		{"ECDSA-384", ecdsa384Priv, ECDSAWithSHA1},
 		{"ECDSA-521", ecdsa521Priv, ECDSAWithSHA1},
+		{"Ed25519", ed25519Priv, PureEd25519},
 	}
 
 	for _, test := range tests {
@@ -17,11 +17,12 @@ import (
 var errNilPtr = errors.New("destination pointer is nil") // embedded in descriptive error
 
 // driverArgs converts arguments from callers of Stmt.Exec and
-// Stmt.Query into driver Values.
+// Stmt.Query into driver Values. A NamedArg argument becomes a
+// driver.NamedValue carrying its name; all others are positional.
 //
 // The statement ds may be nil, if no statement is available.
-func driverArgs(ds *driverStmt, args []interface{}) ([]driver.Value, error) {
-	dargs := make([]driver.Value, len(args))
+func driverArgs(ds *driverStmt, args []interface{}) ([]driver.NamedValue, error) {
+	nvargs := make([]driver.NamedValue, len(args))
 	var si driver.Stmt
 	if ds != nil {
 		si = ds.si
@@ -31,17 +32,39 @@ func driverArgs(ds *driverStmt, args []interface{}) ([]driver.Value, error) {
 	// Normal path, for a driver.Stmt that is not a ColumnConverter.
 	if !ok {
 		for n, arg := range args {
+			nv := &nvargs[n]
+			nv.Ordinal = n + 1
+			if np, ok := arg.(NamedArg); ok {
+				nv.Name = np.Name
+				arg = np.Value
+			}
 			var err error
-			dargs[n], err = driver.DefaultParameterConverter.ConvertValue(arg)
+			nv.Value, err = driver.DefaultParameterConverter.ConvertValue(arg)
 			if err != nil {
 				return nil, fmt.Errorf("sql: converting Exec argument #%d's type: %v", n, err)
 			}
 		}
-		return dargs, nil
+		return nvargs, nil
 	}
 
 	// Let the Stmt convert its own arguments.
 	for n, arg := range args {
+		nv := &nvargs[n]
+		nv.Ordinal = n + 1
+		if np, ok := arg.(NamedArg); ok && np.Name != "" {
+			// A named argument has no column position to
+			// ask the Stmt about.
+			nv.Name = np.Name
+			var err error
+			nv.Value, err = driver.DefaultParameterConverter.ConvertValue(np.Value)
+			if err != nil {
+				return nil, fmt.Errorf("sql: converting argument %q type: %v", np.Name, err)
+			}
+			continue
+		} else if ok {
+			arg = np.Value
+		}
+
 		// First, see if the value itself knows how to convert
 		// itself to a driver type.  For example, a NullString
 		// struct changing into a string or nil.
@@ -65,18 +88,18 @@ func driverArgs(ds *driverStmt, args []interface{}) ([]driver.Value, error) {
 		// same error.
 		var err error
 		ds.Lock()
-		dargs[n], err = cc.ColumnConverter(n).ConvertValue(arg)
+		nv.Value, err = cc.ColumnConverter(n).ConvertValue(arg)
 		ds.Unlock()
 		if err != nil {
 			return nil, fmt.Errorf("sql: converting argument #%d's type: %v", n, err)
 		}
-		if !driver.IsValue(dargs[n]) {
+		if !driver.IsValue(nv.Value) {
 			return nil, fmt.Errorf("sql: driver ColumnConverter error converted %T to unsupported type %T",
-				arg, dargs[n])
+				arg, nv.Value)
 		}
 	}
 
-	return dargs, nil
+	return nvargs, nil
 }
 
 // convertAssign copies to dest the value in src, converting it if possible.
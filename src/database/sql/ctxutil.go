@@ -0,0 +1,167 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// The ctxDriver functions call into the driver with ctx, using the
+// driver's context-aware interface when it has one. Otherwise they
+// fall back to the plain interface, refusing to start once ctx is
+// done and discarding any result that arrives after ctx is done.
+
+func ctxDriverPrepare(ctx context.Context, ci driver.Conn, query string) (driver.Stmt, error) {
+	if ciCtx, is := ci.(driver.ConnPrepareContext); is {
+		return ciCtx.PrepareContext(ctx, query)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	si, err := ci.Prepare(query)
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			si.Close()
+			return nil, cerr
+		}
+	}
+	return si, err
+}
+
+func ctxDriverExec(ctx context.Context, ci driver.Conn, query string, nvdargs []driver.NamedValue) (driver.Result, error) {
+	if execerCtx, is := ci.(driver.ExecerContext); is {
+		return execerCtx.ExecContext(ctx, query, nvdargs)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	resi, err := ci.(driver.Execer).Exec(query, dargs)
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+	}
+	return resi, err
+}
+
+func ctxDriverQuery(ctx context.Context, ci driver.Conn, query string, nvdargs []driver.NamedValue) (driver.Rows, error) {
+	if queryerCtx, is := ci.(driver.QueryerContext); is {
+		return queryerCtx.QueryContext(ctx, query, nvdargs)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	rowsi, err := ci.(driver.Queryer).Query(query, dargs)
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			rowsi.Close()
+			return nil, cerr
+		}
+	}
+	return rowsi, err
+}
+
+func ctxDriverStmtExec(ctx context.Context, si driver.Stmt, nvdargs []driver.NamedValue) (driver.Result, error) {
+	if siCtx, is := si.(driver.StmtExecContext); is {
+		return siCtx.ExecContext(ctx, nvdargs)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	resi, err := si.Exec(dargs)
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+	}
+	return resi, err
+}
+
+func ctxDriverStmtQuery(ctx context.Context, si driver.Stmt, nvdargs []driver.NamedValue) (driver.Rows, error) {
+	if siCtx, is := si.(driver.StmtQueryContext); is {
+		return siCtx.QueryContext(ctx, nvdargs)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dargs, err := namedValueToValue(nvdargs)
+	if err != nil {
+		return nil, err
+	}
+	rowsi, err := si.Query(dargs)
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			rowsi.Close()
+			return nil, cerr
+		}
+	}
+	return rowsi, err
+}
+
+func ctxDriverBegin(ctx context.Context, ci driver.Conn) (driver.Tx, error) {
+	if ciCtx, is := ci.(driver.ConnBeginContext); is {
+		return ciCtx.BeginContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	txi, err := ci.Begin()
+	if err == nil {
+		if cerr := ctx.Err(); cerr != nil {
+			txi.Rollback()
+			return nil, cerr
+		}
+	}
+	return txi, err
+}
+
+// isExecer reports whether ci implements driver.Execer or
+// driver.ExecerContext.
+func isExecer(ci driver.Conn) bool {
+	switch ci.(type) {
+	case driver.Execer, driver.ExecerContext:
+		return true
+	}
+	return false
+}
+
+// isQueryer reports whether ci implements driver.Queryer or
+// driver.QueryerContext.
+func isQueryer(ci driver.Conn) bool {
+	switch ci.(type) {
+	case driver.Queryer, driver.QueryerContext:
+		return true
+	}
+	return false
+}
+
+var errNamedNotSupported = errors.New("sql: driver does not support the use of Named Parameters")
+
+// namedValueToValue converts nvdargs for a driver that only accepts
+// positional arguments.
+func namedValueToValue(nvdargs []driver.NamedValue) ([]driver.Value, error) {
+	dargs := make([]driver.Value, len(nvdargs))
+	for n, param := range nvdargs {
+		if len(param.Name) > 0 {
+			return nil, errNamedNotSupported
+		}
+		dargs[n] = param.Value
+	}
+	return dargs, nil
+}
@@ -5,11 +5,14 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -33,6 +36,14 @@ var _ = log.Printf
 //   INSERT|<tablename>|col=val,col2=val2,col3=?
 //   SELECT|<tablename>|projectcol1,projectcol2|filtercol=?,filtercol2=?
 //
+// A placeholder may be named, as in col=?name, to bind a named
+// argument. Several SELECTs may be joined with ';' into one query
+// that returns a result set for each.
+//
+// Any statement may be prefixed with WAIT|<duration>| to make its
+// execution take that long, or until its context is done, as if the
+// server had canceled it.
+//
 // When opening a fakeDriver's database, it starts empty with no
 // tables.  All tables and data are stored in memory only.
 type fakeDriver struct {
@@ -110,15 +121,20 @@ type fakeStmt struct {
 	table string
 
 	closed bool
+	wait   time.Duration // prefix WAIT|<duration>|, see waitContext
 
 	colName      []string      // used by CREATE, INSERT, SELECT (selected columns)
 	colType      []string      // used by CREATE
 	colValue     []interface{} // used by INSERT (mix of strings and "?" for bound params)
 	placeholders int           // used by INSERT/SELECT: number of ? params
 
+	placeholderName []string // used by INSERT/SELECT: name of each ? param, or ""
+
 	whereCol []string // used by SELECT (all placeholders)
 
 	placeholderConverter []driver.ValueConverter // used by INSERT
+
+	next *fakeStmt // next statement of a multi-statement query
 }
 
 var fdriver driver.Driver = &fakeDriver{}
@@ -324,24 +340,33 @@ func checkSubsetTypes(args []driver.Value) error {
 	return nil
 }
 
-func (c *fakeConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+func checkNamedSubsetTypes(args []driver.NamedValue) error {
+	for _, arg := range args {
+		if err := checkSubsetTypes([]driver.Value{arg.Value}); err != nil {
+			return fmt.Errorf("fakedb_test: invalid argument #%d: %v, type %T", arg.Ordinal, arg.Value, arg.Value)
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	// This is an optional interface, but it's implemented here
 	// just to check that all the args are of the proper types.
 	// ErrSkip is returned so the caller acts as if we didn't
 	// implement this at all.
-	err := checkSubsetTypes(args)
+	err := checkNamedSubsetTypes(args)
 	if err != nil {
 		return nil, err
 	}
 	return nil, driver.ErrSkip
 }
 
-func (c *fakeConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	// This is an optional interface, but it's implemented here
 	// just to check that all the args are of the proper types.
 	// ErrSkip is returned so the caller acts as if we didn't
 	// implement this at all.
-	err := checkSubsetTypes(args)
+	err := checkNamedSubsetTypes(args)
 	if err != nil {
 		return nil, err
 	}
@@ -377,13 +402,14 @@ func (c *fakeConn) prepareSelect(stmt *fakeStmt, parts []string) (driver.Stmt, e
 			stmt.Close()
 			return nil, errf("SELECT on table %q references non-existent column %q", stmt.table, column)
 		}
-		if value != "?" {
+		if !strings.HasPrefix(value, "?") {
 			stmt.Close()
 			return nil, errf("SELECT on table %q has pre-bound value for where column %q; need a question mark",
 				stmt.table, column)
 		}
 		stmt.whereCol = append(stmt.whereCol, column)
 		stmt.placeholders++
+		stmt.placeholderName = append(stmt.placeholderName, value[1:])
 	}
 	return stmt, nil
 }
@@ -428,7 +454,7 @@ func (c *fakeConn) prepareInsert(stmt *fakeStmt, parts []string) (driver.Stmt, e
 		}
 		stmt.colName = append(stmt.colName, column)
 
-		if value != "?" {
+		if !strings.HasPrefix(value, "?") {
 			var subsetVal interface{}
 			// Convert to driver subset type
 			switch ctype {
@@ -450,6 +476,7 @@ func (c *fakeConn) prepareInsert(stmt *fakeStmt, parts []string) (driver.Stmt, e
 			stmt.colValue = append(stmt.colValue, subsetVal)
 		} else {
 			stmt.placeholders++
+			stmt.placeholderName = append(stmt.placeholderName, value[1:])
 			stmt.placeholderConverter = append(stmt.placeholderConverter, converterForType(ctype))
 			stmt.colValue = append(stmt.colValue, "?")
 		}
@@ -470,13 +497,42 @@ func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
 		return nil, driver.ErrBadConn
 	}
 
+	queries := strings.Split(query, ";")
+	si, err := c.prepareOne(queries[0])
+	if err != nil || len(queries) == 1 {
+		return si, err
+	}
+	first := si.(*fakeStmt)
+	for last, queries := first, queries[1:]; len(queries) > 0; queries = queries[1:] {
+		si, err := c.prepareOne(queries[0])
+		if err != nil {
+			first.Close()
+			return nil, err
+		}
+		last.next = si.(*fakeStmt)
+		last = last.next
+	}
+	return first, nil
+}
+
+// prepareOne prepares a single statement of a query.
+func (c *fakeConn) prepareOne(query string) (driver.Stmt, error) {
 	parts := strings.Split(query, "|")
 	if len(parts) < 1 {
 		return nil, errf("empty query")
 	}
+	var wait time.Duration
+	if parts[0] == "WAIT" && len(parts) > 2 {
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, errf("invalid WAIT duration %q: %v", parts[1], err)
+		}
+		wait = d
+		parts = parts[2:]
+	}
 	cmd := parts[0]
 	parts = parts[1:]
-	stmt := &fakeStmt{q: query, c: c, cmd: cmd}
+	stmt := &fakeStmt{q: query, c: c, cmd: cmd, wait: wait}
 	c.incrStat(&c.stmtsMade)
 	switch cmd {
 	case "WIPE":
@@ -499,7 +555,7 @@ func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
 }
 
 func (s *fakeStmt) ColumnConverter(idx int) driver.ValueConverter {
-	if len(s.placeholderConverter) == 0 {
+	if s.next != nil || len(s.placeholderConverter) == 0 {
 		return driver.DefaultParameterConverter
 	}
 	return s.placeholderConverter[idx]
@@ -516,6 +572,9 @@ func (s *fakeStmt) Close() error {
 		s.c.incrStat(&s.c.stmtsClosed)
 		s.closed = true
 	}
+	if s.next != nil {
+		return s.next.Close()
+	}
 	return nil
 }
 
@@ -533,6 +592,10 @@ func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
 		return nil, driver.ErrBadConn
 	}
 
+	if s.next != nil {
+		return nil, errf("multiple statements are only supported by Query")
+	}
+
 	err := checkSubsetTypes(args)
 	if err != nil {
 		return nil, err
@@ -622,11 +685,31 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 		return nil, err
 	}
 
-	db := s.c.db
-	if len(args) != s.placeholders {
+	if len(args) != s.NumInput() {
 		panic("error in pkg db; should only get here if size is correct")
 	}
 
+	// Each statement of a multi-statement query adds a result set,
+	// taking its arguments in turn.
+	var cursor *rowsCursor
+	for st := s; st != nil; st = st.next {
+		rc, err := st.queryRows(args[:st.placeholders])
+		if err != nil {
+			return nil, err
+		}
+		args = args[st.placeholders:]
+		if cursor == nil {
+			cursor = rc
+		} else {
+			cursor.rest = append(cursor.rest, rc)
+		}
+	}
+	return cursor, nil
+}
+
+// queryRows runs a single SELECT statement.
+func (s *fakeStmt) queryRows(args []driver.Value) (*rowsCursor, error) {
+	db := s.c.db
 	db.mu.Lock()
 	t, ok := db.table(s.table)
 	db.mu.Unlock()
@@ -646,12 +729,14 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 	defer t.mu.Unlock()
 
 	colIdx := make(map[string]int) // select column name -> column index in table
-	for _, name := range s.colName {
+	colType := make([]string, len(s.colName))
+	for i, name := range s.colName {
 		idx := t.columnIndex(name)
 		if idx == -1 {
 			return nil, fmt.Errorf("fakedb: unknown column name %q", name)
 		}
 		colIdx[name] = idx
+		colType[i] = t.coltype[idx]
 	}
 
 	mrows := []*row{}
@@ -682,16 +767,156 @@ rows:
 	}
 
 	cursor := &rowsCursor{
-		pos:    -1,
-		rows:   mrows,
-		cols:   s.colName,
-		errPos: -1,
+		pos:     -1,
+		rows:    mrows,
+		cols:    s.colName,
+		colType: colType,
+		errPos:  -1,
 	}
 	return cursor, nil
 }
 
+// waitContext blocks for the statement's WAIT duration. Like a driver
+// canceling the query on the server, it returns ctx's error as soon as
+// ctx is done.
+func (s *fakeStmt) waitContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(s.wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bindArgs orders args to match the statement's placeholders. Named
+// placeholders take the argument of the same name; the others take the
+// argument at their position.
+func (s *fakeStmt) bindArgs(args []driver.NamedValue) ([]driver.Value, error) {
+	dargs := make([]driver.Value, 0, len(args))
+	for st := s; st != nil; st = st.next {
+		for _, name := range st.placeholderName {
+			i := len(dargs)
+			if name == "" {
+				if i >= len(args) || args[i].Name != "" {
+					return nil, errf("no positional argument for placeholder %d", i+1)
+				}
+				dargs = append(dargs, args[i].Value)
+				continue
+			}
+			found := false
+			for _, arg := range args {
+				if arg.Name == name {
+					dargs = append(dargs, arg.Value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, errf("no argument named %q", name)
+			}
+		}
+	}
+	return dargs, nil
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.waitContext(ctx); err != nil {
+		return nil, err
+	}
+	dargs, err := s.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Exec(dargs)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.waitContext(ctx); err != nil {
+		return nil, err
+	}
+	dargs, err := s.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(dargs)
+}
+
 func (s *fakeStmt) NumInput() int {
-	return s.placeholders
+	n := 0
+	for st := s; st != nil; st = st.next {
+		n += st.placeholders
+	}
+	return n
+}
+
+// noCtxDriver wraps fakeDriver as a driver written before contexts:
+// its connections and statements implement none of the context-aware
+// interfaces, and a WAIT prefix makes a statement take its full time
+// however long its caller is willing to wait.
+type noCtxDriver struct{ d *fakeDriver }
+
+func (d noCtxDriver) Open(dsn string) (driver.Conn, error) {
+	ci, err := d.d.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return noCtxConn{ci.(*fakeConn)}, nil
+}
+
+// noCtxConn implements driver.Execer and driver.Queryer, but not
+// their context-aware versions.
+type noCtxConn struct{ c *fakeConn }
+
+func (c noCtxConn) Prepare(query string) (driver.Stmt, error) {
+	si, err := c.c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return noCtxStmt{si.(*fakeStmt)}, nil
+}
+
+func (c noCtxConn) Close() error              { return c.c.Close() }
+func (c noCtxConn) Begin() (driver.Tx, error) { return c.c.Begin() }
+
+func (c noCtxConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	si, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer si.Close()
+	return si.Exec(args)
+}
+
+func (c noCtxConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	si, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer si.Close()
+	return si.Query(args)
+}
+
+type noCtxStmt struct{ s *fakeStmt }
+
+func (s noCtxStmt) Close() error  { return s.s.Close() }
+func (s noCtxStmt) NumInput() int { return s.s.NumInput() }
+
+func (s noCtxStmt) Exec(args []driver.Value) (driver.Result, error) {
+	time.Sleep(s.s.wait)
+	return s.s.Exec(args)
+}
+
+func (s noCtxStmt) Query(args []driver.Value) (driver.Rows, error) {
+	time.Sleep(s.s.wait)
+	return s.s.Query(args)
 }
 
 func (tx *fakeTx) Commit() error {
@@ -705,15 +930,20 @@ func (tx *fakeTx) Rollback() error {
 }
 
 type rowsCursor struct {
-	cols   []string
-	pos    int
-	rows   []*row
-	closed bool
+	cols    []string
+	colType []string // fakedb column types, as in CREATE
+	pos     int
+	rows    []*row
+	closed  bool
 
 	// errPos and err are for making Next return early with error.
 	errPos int
 	err    error
 
+	// rest holds the result sets following this one, for
+	// multi-statement queries.
+	rest []*rowsCursor
+
 	// a clone of slices to give out to clients, indexed by the
 	// the original slice's first byte address.  we clone them
 	// just so we're able to corrupt them on close.
@@ -734,6 +964,44 @@ func (rc *rowsCursor) Columns() []string {
 	return rc.cols
 }
 
+func (rc *rowsCursor) ColumnTypeScanType(index int) reflect.Type {
+	return colTypeToReflectType(rc.colType[index])
+}
+
+func (rc *rowsCursor) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(rc.colType[index])
+}
+
+func (rc *rowsCursor) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return strings.HasPrefix(rc.colType[index], "null"), true
+}
+
+func (rc *rowsCursor) ColumnTypeLength(index int) (length int64, ok bool) {
+	switch rc.colType[index] {
+	case "string", "nullstring", "blob":
+		return math.MaxInt64, true
+	}
+	return 0, false
+}
+
+func (rc *rowsCursor) HasNextResultSet() bool {
+	return len(rc.rest) > 0
+}
+
+func (rc *rowsCursor) NextResultSet() error {
+	if rc.closed {
+		return errors.New("fakedb: cursor is closed")
+	}
+	if len(rc.rest) == 0 {
+		return io.EOF
+	}
+	next := rc.rest[0]
+	rc.cols, rc.colType, rc.rows = next.cols, next.colType, next.rows
+	rc.pos, rc.errPos = -1, -1
+	rc.rest = rc.rest[1:]
+	return nil
+}
+
 var rowsCursorNextHook func(dest []driver.Value) error
 
 func (rc *rowsCursor) Next(dest []driver.Value) error {
@@ -827,3 +1095,31 @@ func converterForType(typ string) driver.ValueConverter {
 	}
 	panic("invalid fakedb column type of " + typ)
 }
+
+func colTypeToReflectType(typ string) reflect.Type {
+	switch typ {
+	case "bool":
+		return reflect.TypeOf(false)
+	case "nullbool":
+		return reflect.TypeOf(NullBool{})
+	case "int32":
+		return reflect.TypeOf(int32(0))
+	case "string":
+		return reflect.TypeOf("")
+	case "nullstring":
+		return reflect.TypeOf(NullString{})
+	case "int64":
+		return reflect.TypeOf(int64(0))
+	case "nullint64":
+		return reflect.TypeOf(NullInt64{})
+	case "float64":
+		return reflect.TypeOf(float64(0))
+	case "nullfloat64":
+		return reflect.TypeOf(NullFloat64{})
+	case "datetime":
+		return reflect.TypeOf(time.Time{})
+	case "blob":
+		return reflect.TypeOf([]byte(nil))
+	}
+	panic("invalid fakedb column type of " + typ)
+}
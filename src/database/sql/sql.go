@@ -13,13 +13,17 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var drivers = make(map[string]driver.Driver)
@@ -52,6 +56,53 @@ func Drivers() []string {
 	return list
 }
 
+// A NamedArg is a named argument. NamedArg values may be used as
+// arguments to Query or Exec and bind to the corresponding named
+// parameter in the SQL statement.
+//
+// For a more concise way to create NamedArg values, see
+// the Named function.
+type NamedArg struct {
+	_Named_Fields_Required struct{}
+
+	// Name is the name of the parameter placeholder.
+	//
+	// If empty, the ordinal position in the argument list will be
+	// used.
+	//
+	// Name must omit any symbol prefix.
+	Name string
+
+	// Value is the value of the parameter.
+	// It may be assigned the same value types as the query
+	// arguments.
+	Value interface{}
+}
+
+// Named provides a more concise way to create NamedArg values.
+//
+// Example usage:
+//
+//     db.ExecContext(ctx, `
+//         delete from Invoice
+//         where
+//             TimeCreated < @end
+//             and TimeCreated >= @start;`,
+//         sql.Named("start", startTime),
+//         sql.Named("end", endTime),
+//     )
+//
+// Named arguments are only passed to drivers whose connections or
+// statements implement the context-aware driver interfaces; other
+// drivers fail the query.
+func Named(name string, value interface{}) NamedArg {
+	// This method exists because the go1compat promise
+	// doesn't guarantee that structs don't grow more fields,
+	// so unkeyed struct literals are a vet error. Thus, we don't
+	// want to allow sql.NamedArg{name, value}.
+	return NamedArg{Name: name, Value: value}
+}
+
 // RawBytes is a byte slice that holds a reference to memory owned by
 // the database itself. After a Scan into a RawBytes, the slice is only
 // valid until the next call to Next, Scan, or Close.
@@ -212,6 +263,10 @@ type DB struct {
 	driver driver.Driver
 	dsn    string
 
+	// Atomic access only. At top of struct to prevent mis-alignment
+	// on 32-bit platforms.
+	waitDuration int64 // Total time waited for new connections.
+
 	mu           sync.Mutex // protects following fields
 	freeConn     []*driverConn
 	connRequests []chan connRequest
@@ -223,11 +278,23 @@ type DB struct {
 	// It is closed during db.Close(). The close tells the connectionOpener
 	// goroutine to exit.
 	openerCh chan struct{}
-	closed   bool
-	dep      map[finalCloser]depSet
-	lastPut  map[*driverConn]string // stacktrace of last conn's put; debug only
-	maxIdle  int                    // zero means defaultMaxIdleConns; negative means 0
-	maxOpen  int                    // <= 0 means unlimited
+	// Used to wake the goroutine running connectionCleaner when the
+	// lifetime limits change. It is created when the cleaner starts
+	// and set to nil when it exits.
+	cleanerCh   chan struct{}
+	closed      bool
+	dep         map[finalCloser]depSet
+	lastPut     map[*driverConn]string // stacktrace of last conn's put; debug only
+	maxIdle     int                    // zero means defaultMaxIdleConns; negative means 0
+	maxOpen     int                    // <= 0 means unlimited
+	maxLifetime time.Duration          // maximum amount of time a connection may be reused
+	maxIdleTime time.Duration          // maximum amount of time a connection may be idle before being closed
+
+	// Counters reported by Stats.
+	waitCount         int64 // Total number of connections waited for.
+	maxIdleClosed     int64 // Total number of connections closed due to idle count.
+	maxIdleTimeClosed int64 // Total number of connections closed due to idle time.
+	maxLifetimeClosed int64 // Total number of connections closed due to max connection lifetime limit.
 }
 
 // driverConn wraps a driver.Conn with a mutex, to
@@ -235,7 +302,8 @@ type DB struct {
 // interfaces returned via that Conn, such as calls on Tx, Stmt,
 // Result, Rows)
 type driverConn struct {
-	db *DB
+	db        *DB
+	createdAt time.Time
 
 	sync.Mutex  // guards following
 	ci          driver.Conn
@@ -245,8 +313,21 @@ type driverConn struct {
 
 	// guarded by db.mu
 	inUse      bool
-	onPut      []func() // code (with db.mu held) run when conn is next returned
-	dbmuClosed bool     // same as closed, but guarded by db.mu, for connIfFree
+	returnedAt time.Time // Time the connection was created or returned.
+	onPut      []func()  // code (with db.mu held) run when conn is next returned
+	dbmuClosed bool      // same as closed, but guarded by db.mu, for connIfFree
+}
+
+// nowFunc returns the current time; it's overridden in tests.
+var nowFunc = time.Now
+
+// expired reports whether dc was created more than timeout ago.
+// A timeout <= 0 means connections never expire.
+func (dc *driverConn) expired(timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	return dc.createdAt.Add(timeout).Before(nowFunc())
 }
 
 func (dc *driverConn) releaseConn(err error) {
@@ -259,8 +340,8 @@ func (dc *driverConn) removeOpenStmt(si driver.Stmt) {
 	delete(dc.openStmt, si)
 }
 
-func (dc *driverConn) prepareLocked(query string) (driver.Stmt, error) {
-	si, err := dc.ci.Prepare(query)
+func (dc *driverConn) prepareLocked(ctx context.Context, query string) (driver.Stmt, error) {
+	si, err := ctxDriverPrepare(ctx, dc.ci, query)
 	if err == nil {
 		// Track each driverConn's open statements, so we can close them
 		// before closing the conn.
@@ -456,10 +537,24 @@ func Open(driverName, dataSourceName string) (*DB, error) {
 // Ping verifies a connection to the database is still alive,
 // establishing a connection if necessary.
 func (db *DB) Ping() error {
+	return db.PingContext(context.Background())
+}
+
+// PingContext verifies a connection to the database is still alive,
+// establishing a connection if necessary. It gives up waiting for a
+// connection once ctx is done.
+func (db *DB) PingContext(ctx context.Context) error {
 	// TODO(bradfitz): give drivers an optional hook to implement
 	// this in a more efficient or more reliable way, if they
 	// have one.
-	dc, err := db.conn()
+	var dc *driverConn
+	var err error
+	for i := 0; i < maxBadConnRetries; i++ {
+		dc, err = db.conn(ctx)
+		if err != driver.ErrBadConn {
+			break
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -478,6 +573,9 @@ func (db *DB) Close() error {
 		return nil
 	}
 	close(db.openerCh)
+	if db.cleanerCh != nil {
+		close(db.cleanerCh)
+	}
 	var err error
 	fns := make([]func() error, 0, len(db.freeConn))
 	for _, dc := range db.freeConn {
@@ -539,6 +637,7 @@ func (db *DB) SetMaxIdleConns(n int) {
 		closing = db.freeConn[maxIdle:]
 		db.freeConn = db.freeConn[:maxIdle]
 	}
+	db.maxIdleClosed += int64(len(closing))
 	db.mu.Unlock()
 	for _, c := range closing {
 		c.Close()
@@ -566,6 +665,187 @@ func (db *DB) SetMaxOpenConns(n int) {
 	}
 }
 
+// SetConnMaxLifetime sets the maximum amount of time a connection may
+// be reused.
+//
+// Expired connections may be closed lazily before reuse.
+//
+// If d <= 0, connections are not closed due to a connection's age.
+func (db *DB) SetConnMaxLifetime(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	db.mu.Lock()
+	// Wake cleaner up when lifetime is shortened.
+	if d > 0 && d < db.maxLifetime && db.cleanerCh != nil {
+		select {
+		case db.cleanerCh <- struct{}{}:
+		default:
+		}
+	}
+	db.maxLifetime = d
+	db.startCleanerLocked()
+	db.mu.Unlock()
+}
+
+// SetConnMaxIdleTime sets the maximum amount of time a connection may
+// be idle.
+//
+// Expired connections may be closed lazily before reuse.
+//
+// If d <= 0, connections are not closed due to a connection's idle time.
+func (db *DB) SetConnMaxIdleTime(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	db.mu.Lock()
+	// Wake cleaner up when idle time is shortened.
+	if d > 0 && d < db.maxIdleTime && db.cleanerCh != nil {
+		select {
+		case db.cleanerCh <- struct{}{}:
+		default:
+		}
+	}
+	db.maxIdleTime = d
+	db.startCleanerLocked()
+	db.mu.Unlock()
+}
+
+// shortestIdleTimeLocked returns the shorter of the two limits that
+// are set, or zero if neither is.
+func (db *DB) shortestIdleTimeLocked() time.Duration {
+	if db.maxIdleTime <= 0 {
+		return db.maxLifetime
+	}
+	if db.maxLifetime <= 0 {
+		return db.maxIdleTime
+	}
+	if db.maxIdleTime < db.maxLifetime {
+		return db.maxIdleTime
+	}
+	return db.maxLifetime
+}
+
+// startCleanerLocked starts connectionCleaner if needed.
+// Assumes db.mu is locked.
+func (db *DB) startCleanerLocked() {
+	if db.shortestIdleTimeLocked() > 0 && db.numOpen > 0 && db.cleanerCh == nil && !db.closed {
+		db.cleanerCh = make(chan struct{}, 1)
+		go db.connectionCleaner(db.shortestIdleTimeLocked())
+	}
+}
+
+// minCleanerInterval is the shortest interval between runs of
+// connectionCleaner.
+const minCleanerInterval = time.Second
+
+// Runs in a separate goroutine, closes idle connections that have
+// outlived the lifetime limits. It exits when the limits are cleared,
+// when no connections are open, or when the DB is closed.
+func (db *DB) connectionCleaner(d time.Duration) {
+	if d < minCleanerInterval {
+		d = minCleanerInterval
+	}
+	t := time.NewTimer(d)
+
+	for {
+		select {
+		case <-t.C:
+		case <-db.cleanerCh: // the limits were changed or db was closed.
+		}
+
+		db.mu.Lock()
+		d = db.shortestIdleTimeLocked()
+		if db.closed || db.numOpen == 0 || d <= 0 {
+			db.cleanerCh = nil
+			db.mu.Unlock()
+			t.Stop()
+			return
+		}
+		closing := db.connectionCleanerRunLocked()
+		db.mu.Unlock()
+		for _, c := range closing {
+			c.Close()
+		}
+
+		if d < minCleanerInterval {
+			d = minCleanerInterval
+		}
+		t.Reset(d)
+	}
+}
+
+// connectionCleanerRunLocked removes the expired connections from the
+// idle pool and returns them, to be closed by the caller.
+// Assumes db.mu is locked.
+func (db *DB) connectionCleanerRunLocked() (closing []*driverConn) {
+	var idleSince, createdBefore time.Time
+	now := nowFunc()
+	if db.maxIdleTime > 0 {
+		idleSince = now.Add(-db.maxIdleTime)
+	}
+	if db.maxLifetime > 0 {
+		createdBefore = now.Add(-db.maxLifetime)
+	}
+	kept := db.freeConn[:0]
+	for _, c := range db.freeConn {
+		switch {
+		case db.maxIdleTime > 0 && c.returnedAt.Before(idleSince):
+			db.maxIdleTimeClosed++
+			closing = append(closing, c)
+		case db.maxLifetime > 0 && c.createdAt.Before(createdBefore):
+			db.maxLifetimeClosed++
+			closing = append(closing, c)
+		default:
+			kept = append(kept, c)
+		}
+	}
+	for i := len(kept); i < len(db.freeConn); i++ {
+		db.freeConn[i] = nil
+	}
+	db.freeConn = kept
+	return closing
+}
+
+// DBStats contains database statistics.
+type DBStats struct {
+	MaxOpenConnections int // Maximum number of open connections to the database; 0 means unlimited.
+
+	// Pool Status
+	OpenConnections int // The number of established connections both in use and idle.
+	InUse           int // The number of connections currently in use.
+	Idle            int // The number of idle connections.
+
+	// Counters
+	WaitCount         int64         // The total number of connections waited for.
+	WaitDuration      time.Duration // The total time blocked waiting for a new connection.
+	MaxIdleClosed     int64         // The total number of connections closed due to SetMaxIdleConns.
+	MaxIdleTimeClosed int64         // The total number of connections closed due to SetConnMaxIdleTime.
+	MaxLifetimeClosed int64         // The total number of connections closed due to SetConnMaxLifetime.
+}
+
+// Stats returns database statistics.
+func (db *DB) Stats() DBStats {
+	wait := atomic.LoadInt64(&db.waitDuration)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return DBStats{
+		MaxOpenConnections: db.maxOpen,
+
+		Idle:            len(db.freeConn),
+		OpenConnections: db.numOpen,
+		InUse:           db.numOpen - len(db.freeConn),
+
+		WaitCount:         db.waitCount,
+		WaitDuration:      time.Duration(wait),
+		MaxIdleClosed:     db.maxIdleClosed,
+		MaxIdleTimeClosed: db.maxIdleTimeClosed,
+		MaxLifetimeClosed: db.maxLifetimeClosed,
+	}
+}
+
 // Assumes db.mu is locked.
 // If there are connRequests and the connection limit hasn't been reached,
 // then tell the connectionOpener to open new connections.
@@ -608,8 +888,10 @@ func (db *DB) openNewConnection() {
 		return
 	}
 	dc := &driverConn{
-		db: db,
-		ci: ci,
+		db:         db,
+		createdAt:  nowFunc(),
+		returnedAt: nowFunc(),
+		ci:         ci,
 	}
 	if db.putConnDBLocked(dc, err) {
 		db.addDepLocked(dc, dc)
@@ -629,13 +911,35 @@ type connRequest struct {
 
 var errDBClosed = errors.New("sql: database is closed")
 
-// conn returns a newly-opened or cached *driverConn
-func (db *DB) conn() (*driverConn, error) {
+// conn returns a newly-opened or cached *driverConn. It returns
+// ctx.Err() if ctx is done before a connection is available.
+func (db *DB) conn(ctx context.Context) (*driverConn, error) {
 	db.mu.Lock()
 	if db.closed {
 		db.mu.Unlock()
 		return nil, errDBClosed
 	}
+	if err := ctx.Err(); err != nil {
+		db.mu.Unlock()
+		return nil, err
+	}
+
+	// Close the idle connections that have outlived the lifetime limits
+	// before picking one. Handing each out as driver.ErrBadConn instead
+	// would fail callers once more than maxBadConnRetries had expired.
+	if db.shortestIdleTimeLocked() > 0 {
+		if closing := db.connectionCleanerRunLocked(); len(closing) > 0 {
+			db.mu.Unlock()
+			for _, c := range closing {
+				c.Close()
+			}
+			db.mu.Lock()
+			if db.closed {
+				db.mu.Unlock()
+				return nil, errDBClosed
+			}
+		}
+	}
 
 	// If db.maxOpen > 0 and the number of open connections is over the limit
 	// and there are no free connection, make a request and wait.
@@ -644,10 +948,35 @@ func (db *DB) conn() (*driverConn, error) {
 		// connectionOpener doesn't block while waiting for the req to be read.
 		req := make(chan connRequest, 1)
 		db.connRequests = append(db.connRequests, req)
+		db.waitCount++
 		db.maybeOpenNewConnections()
 		db.mu.Unlock()
-		ret := <-req
-		return ret.conn, ret.err
+		waitStart := time.Now()
+		select {
+		case ret := <-req:
+			atomic.AddInt64(&db.waitDuration, int64(time.Since(waitStart)))
+			return ret.conn, ret.err
+		case <-ctx.Done():
+			atomic.AddInt64(&db.waitDuration, int64(time.Since(waitStart)))
+			// Withdraw the request. If a connection was handed
+			// to it in the meantime, give it back.
+			db.mu.Lock()
+			for i, r := range db.connRequests {
+				if r == req {
+					db.connRequests = append(db.connRequests[:i], db.connRequests[i+1:]...)
+					break
+				}
+			}
+			db.mu.Unlock()
+			select {
+			case ret := <-req:
+				if ret.conn != nil {
+					db.putConn(ret.conn, ret.err)
+				}
+			default:
+			}
+			return nil, ctx.Err()
+		}
 	}
 
 	if c := len(db.freeConn); c > 0 {
@@ -670,8 +999,10 @@ func (db *DB) conn() (*driverConn, error) {
 	}
 	db.mu.Lock()
 	dc := &driverConn{
-		db: db,
-		ci: ci,
+		db:         db,
+		createdAt:  nowFunc(),
+		returnedAt: nowFunc(),
+		ci:         ci,
 	}
 	db.addDepLocked(dc, dc)
 	dc.inUse = true
@@ -760,12 +1091,17 @@ func (db *DB) putConn(dc *driverConn, err error) {
 		db.lastPut[dc] = stack()
 	}
 	dc.inUse = false
+	dc.returnedAt = nowFunc()
 
 	for _, fn := range dc.onPut {
 		fn()
 	}
 	dc.onPut = nil
 
+	if err != driver.ErrBadConn && dc.expired(db.maxLifetime) {
+		db.maxLifetimeClosed++
+		err = driver.ErrBadConn
+	}
 	if err == driver.ErrBadConn {
 		// Don't reuse bad connections.
 		// Since the conn is considered bad and is being discarded, treat it
@@ -812,9 +1148,13 @@ func (db *DB) putConnDBLocked(dc *driverConn, err error) bool {
 			err:  err,
 		}
 		return true
-	} else if err == nil && !db.closed && db.maxIdleConnsLocked() > len(db.freeConn) {
-		db.freeConn = append(db.freeConn, dc)
-		return true
+	} else if err == nil && !db.closed {
+		if db.maxIdleConnsLocked() > len(db.freeConn) {
+			db.freeConn = append(db.freeConn, dc)
+			db.startCleanerLocked()
+			return true
+		}
+		db.maxIdleClosed++
 	}
 	return false
 }
@@ -827,10 +1167,20 @@ const maxBadConnRetries = 10
 // Multiple queries or executions may be run concurrently from the
 // returned statement.
 func (db *DB) Prepare(query string) (*Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext creates a prepared statement for later queries or
+// executions. Multiple queries or executions may be run concurrently
+// from the returned statement.
+//
+// The provided context is used for the preparation of the statement,
+// not for the execution of the statement.
+func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	var stmt *Stmt
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		stmt, err = db.prepare(query)
+		stmt, err = db.prepare(ctx, query)
 		if err != driver.ErrBadConn {
 			break
 		}
@@ -838,19 +1188,19 @@ func (db *DB) Prepare(query string) (*Stmt, error) {
 	return stmt, err
 }
 
-func (db *DB) prepare(query string) (*Stmt, error) {
+func (db *DB) prepare(ctx context.Context, query string) (*Stmt, error) {
 	// TODO: check if db.driver supports an optional
 	// driver.Preparer interface and call that instead, if so,
 	// otherwise we make a prepared statement that's bound
 	// to a connection, and to execute this prepared statement
 	// we either need to use this connection (if it's free), else
 	// get a new connection + re-prepare + execute on that one.
-	dc, err := db.conn()
+	dc, err := db.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 	dc.Lock()
-	si, err := dc.prepareLocked(query)
+	si, err := dc.prepareLocked(ctx, query)
 	dc.Unlock()
 	if err != nil {
 		db.putConn(dc, err)
@@ -869,10 +1219,20 @@ func (db *DB) prepare(query string) (*Stmt, error) {
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Exec(query string, args ...interface{}) (Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query without returning any rows.
+// The args are for any placeholder parameters in the query.
+//
+// If ctx is done before the query completes, ExecContext returns
+// ctx.Err(), and drivers implementing the context-aware interfaces
+// of package driver cancel the query.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	var res Result
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		res, err = db.exec(query, args)
+		res, err = db.exec(ctx, query, args)
 		if err != driver.ErrBadConn {
 			break
 		}
@@ -880,8 +1240,8 @@ func (db *DB) Exec(query string, args ...interface{}) (Result, error) {
 	return res, err
 }
 
-func (db *DB) exec(query string, args []interface{}) (res Result, err error) {
-	dc, err := db.conn()
+func (db *DB) exec(ctx context.Context, query string, args []interface{}) (res Result, err error) {
+	dc, err := db.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -889,13 +1249,13 @@ func (db *DB) exec(query string, args []interface{}) (res Result, err error) {
 		db.putConn(dc, err)
 	}()
 
-	if execer, ok := dc.ci.(driver.Execer); ok {
+	if isExecer(dc.ci) {
 		dargs, err := driverArgs(nil, args)
 		if err != nil {
 			return nil, err
 		}
 		dc.Lock()
-		resi, err := execer.Exec(query, dargs)
+		resi, err := ctxDriverExec(ctx, dc.ci, query, dargs)
 		dc.Unlock()
 		if err != driver.ErrSkip {
 			if err != nil {
@@ -906,22 +1266,33 @@ func (db *DB) exec(query string, args []interface{}) (res Result, err error) {
 	}
 
 	dc.Lock()
-	si, err := dc.ci.Prepare(query)
+	si, err := ctxDriverPrepare(ctx, dc.ci, query)
 	dc.Unlock()
 	if err != nil {
 		return nil, err
 	}
 	defer withLock(dc, func() { si.Close() })
-	return resultFromStatement(driverStmt{dc, si}, args...)
+	return resultFromStatement(ctx, driverStmt{dc, si}, args...)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query that returns rows, typically a SELECT.
+// The args are for any placeholder parameters in the query.
+//
+// If ctx is done before the query completes, QueryContext returns
+// ctx.Err(), and drivers implementing the context-aware interfaces
+// of package driver cancel the query. The context is passed to the
+// driver, which may also honor it while the rows are read.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	var rows *Rows
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		rows, err = db.query(query, args)
+		rows, err = db.query(ctx, query, args)
 		if err != driver.ErrBadConn {
 			break
 		}
@@ -929,26 +1300,26 @@ func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
 	return rows, err
 }
 
-func (db *DB) query(query string, args []interface{}) (*Rows, error) {
-	ci, err := db.conn()
+func (db *DB) query(ctx context.Context, query string, args []interface{}) (*Rows, error) {
+	ci, err := db.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return db.queryConn(ci, ci.releaseConn, query, args)
+	return db.queryConn(ctx, ci, ci.releaseConn, query, args)
 }
 
 // queryConn executes a query on the given connection.
 // The connection gets released by the releaseConn function.
-func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, args []interface{}) (*Rows, error) {
-	if queryer, ok := dc.ci.(driver.Queryer); ok {
+func (db *DB) queryConn(ctx context.Context, dc *driverConn, releaseConn func(error), query string, args []interface{}) (*Rows, error) {
+	if isQueryer(dc.ci) {
 		dargs, err := driverArgs(nil, args)
 		if err != nil {
 			releaseConn(err)
 			return nil, err
 		}
 		dc.Lock()
-		rowsi, err := queryer.Query(query, dargs)
+		rowsi, err := ctxDriverQuery(ctx, dc.ci, query, dargs)
 		dc.Unlock()
 		if err != driver.ErrSkip {
 			if err != nil {
@@ -967,7 +1338,7 @@ func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, a
 	}
 
 	dc.Lock()
-	si, err := dc.ci.Prepare(query)
+	si, err := ctxDriverPrepare(ctx, dc.ci, query)
 	dc.Unlock()
 	if err != nil {
 		releaseConn(err)
@@ -975,7 +1346,7 @@ func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, a
 	}
 
 	ds := driverStmt{dc, si}
-	rowsi, err := rowsiFromStatement(ds, args...)
+	rowsi, err := rowsiFromStatement(ctx, ds, args...)
 	if err != nil {
 		dc.Lock()
 		si.Close()
@@ -999,17 +1370,34 @@ func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, a
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (db *DB) QueryRow(query string, args ...interface{}) *Row {
-	rows, err := db.Query(query, args...)
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most
+// one row. QueryRowContext always return a non-nil value. Errors are
+// deferred until Row's Scan method is called.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	rows, err := db.QueryContext(ctx, query, args...)
 	return &Row{rows: rows, err: err}
 }
 
 // Begin starts a transaction. The isolation level is dependent on
 // the driver.
 func (db *DB) Begin() (*Tx, error) {
+	return db.BeginContext(context.Background())
+}
+
+// BeginContext starts a transaction. The isolation level is dependent
+// on the driver.
+//
+// The provided context bounds the wait for a connection and the start
+// of the transaction. Statements run within the transaction take
+// their own context.
+func (db *DB) BeginContext(ctx context.Context) (*Tx, error) {
 	var tx *Tx
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		tx, err = db.begin()
+		tx, err = db.begin(ctx)
 		if err != driver.ErrBadConn {
 			break
 		}
@@ -1017,13 +1405,13 @@ func (db *DB) Begin() (*Tx, error) {
 	return tx, err
 }
 
-func (db *DB) begin() (tx *Tx, err error) {
-	dc, err := db.conn()
+func (db *DB) begin(ctx context.Context) (tx *Tx, err error) {
+	dc, err := db.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 	dc.Lock()
-	txi, err := dc.ci.Begin()
+	txi, err := ctxDriverBegin(ctx, dc.ci)
 	dc.Unlock()
 	if err != nil {
 		db.putConn(dc, err)
@@ -1133,6 +1521,19 @@ func (tx *Tx) Rollback() error {
 //
 // To use an existing prepared statement on this transaction, see Tx.Stmt.
 func (tx *Tx) Prepare(query string) (*Stmt, error) {
+	return tx.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext creates a prepared statement for use within a
+// transaction.
+//
+// The returned statement operates within the transaction and can no
+// longer be used once the transaction has been committed or rolled
+// back.
+//
+// The provided context is used for the preparation of the statement,
+// not for the execution of the statement.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	// TODO(bradfitz): We could be more efficient here and either
 	// provide a method to take an existing Stmt (created on
 	// perhaps a different Conn), and re-create it on this Conn if
@@ -1152,7 +1553,7 @@ func (tx *Tx) Prepare(query string) (*Stmt, error) {
 	}
 
 	dc.Lock()
-	si, err := dc.ci.Prepare(query)
+	si, err := ctxDriverPrepare(ctx, dc.ci, query)
 	dc.Unlock()
 	if err != nil {
 		return nil, err
@@ -1183,6 +1584,13 @@ func (tx *Tx) Prepare(query string) (*Stmt, error) {
 //  ...
 //  res, err := tx.Stmt(updateMoney).Exec(123.45, 98293203)
 func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
+	return tx.StmtContext(context.Background(), stmt)
+}
+
+// StmtContext returns a transaction-specific prepared statement from
+// an existing statement. The provided context is used for preparing
+// the statement on the transaction's connection.
+func (tx *Tx) StmtContext(ctx context.Context, stmt *Stmt) *Stmt {
 	// TODO(bradfitz): optimize this. Currently this re-prepares
 	// each time.  This is fine for now to illustrate the API but
 	// we should really cache already-prepared statements
@@ -1196,7 +1604,7 @@ func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
 		return &Stmt{stickyErr: err}
 	}
 	dc.Lock()
-	si, err := dc.ci.Prepare(stmt.query)
+	si, err := ctxDriverPrepare(ctx, dc.ci, stmt.query)
 	dc.Unlock()
 	txs := &Stmt{
 		db: tx.db,
@@ -1217,18 +1625,25 @@ func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
 // Exec executes a query that doesn't return rows.
 // For example: an INSERT and UPDATE.
 func (tx *Tx) Exec(query string, args ...interface{}) (Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query that doesn't return rows.
+// For example: an INSERT and UPDATE. If ctx is done before the
+// query completes, ExecContext returns ctx.Err().
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	dc, err := tx.grabConn()
 	if err != nil {
 		return nil, err
 	}
 
-	if execer, ok := dc.ci.(driver.Execer); ok {
+	if isExecer(dc.ci) {
 		dargs, err := driverArgs(nil, args)
 		if err != nil {
 			return nil, err
 		}
 		dc.Lock()
-		resi, err := execer.Exec(query, dargs)
+		resi, err := ctxDriverExec(ctx, dc.ci, query, dargs)
 		dc.Unlock()
 		if err == nil {
 			return driverResult{dc, resi}, nil
@@ -1239,31 +1654,45 @@ func (tx *Tx) Exec(query string, args ...interface{}) (Result, error) {
 	}
 
 	dc.Lock()
-	si, err := dc.ci.Prepare(query)
+	si, err := ctxDriverPrepare(ctx, dc.ci, query)
 	dc.Unlock()
 	if err != nil {
 		return nil, err
 	}
 	defer withLock(dc, func() { si.Close() })
 
-	return resultFromStatement(driverStmt{dc, si}, args...)
+	return resultFromStatement(ctx, driverStmt{dc, si}, args...)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 func (tx *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext executes a query that returns rows, typically a SELECT.
+// If ctx is done before the query completes, QueryContext returns
+// ctx.Err().
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	dc, err := tx.grabConn()
 	if err != nil {
 		return nil, err
 	}
 	releaseConn := func(error) {}
-	return tx.db.queryConn(dc, releaseConn, query, args)
+	return tx.db.queryConn(ctx, dc, releaseConn, query, args)
 }
 
 // QueryRow executes a query that is expected to return at most one row.
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (tx *Tx) QueryRow(query string, args ...interface{}) *Row {
-	rows, err := tx.Query(query, args...)
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most
+// one row. QueryRowContext always return a non-nil value. Errors are
+// deferred until Row's Scan method is called.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	return &Row{rows: rows, err: err}
 }
 
@@ -1299,12 +1728,20 @@ type Stmt struct {
 // Exec executes a prepared statement with the given arguments and
 // returns a Result summarizing the effect of the statement.
 func (s *Stmt) Exec(args ...interface{}) (Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// ExecContext executes a prepared statement with the given arguments
+// and returns a Result summarizing the effect of the statement. If ctx
+// is done before the statement completes, ExecContext returns
+// ctx.Err().
+func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (Result, error) {
 	s.closemu.RLock()
 	defer s.closemu.RUnlock()
 
 	var res Result
 	for i := 0; i < maxBadConnRetries; i++ {
-		dc, releaseConn, si, err := s.connStmt()
+		dc, releaseConn, si, err := s.connStmt(ctx)
 		if err != nil {
 			if err == driver.ErrBadConn {
 				continue
@@ -1312,7 +1749,7 @@ func (s *Stmt) Exec(args ...interface{}) (Result, error) {
 			return nil, err
 		}
 
-		res, err = resultFromStatement(driverStmt{dc, si}, args...)
+		res, err = resultFromStatement(ctx, driverStmt{dc, si}, args...)
 		releaseConn(err)
 		if err != driver.ErrBadConn {
 			return res, err
@@ -1321,7 +1758,7 @@ func (s *Stmt) Exec(args ...interface{}) (Result, error) {
 	return nil, driver.ErrBadConn
 }
 
-func resultFromStatement(ds driverStmt, args ...interface{}) (Result, error) {
+func resultFromStatement(ctx context.Context, ds driverStmt, args ...interface{}) (Result, error) {
 	ds.Lock()
 	want := ds.si.NumInput()
 	ds.Unlock()
@@ -1339,7 +1776,7 @@ func resultFromStatement(ds driverStmt, args ...interface{}) (Result, error) {
 	}
 
 	ds.Lock()
-	resi, err := ds.si.Exec(dargs)
+	resi, err := ctxDriverStmtExec(ctx, ds.si, dargs)
 	ds.Unlock()
 	if err != nil {
 		return nil, err
@@ -1350,7 +1787,7 @@ func resultFromStatement(ds driverStmt, args ...interface{}) (Result, error) {
 // connStmt returns a free driver connection on which to execute the
 // statement, a function to call to release the connection, and a
 // statement bound to that connection.
-func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.Stmt, err error) {
+func (s *Stmt) connStmt(ctx context.Context) (ci *driverConn, releaseConn func(error), si driver.Stmt, err error) {
 	if err = s.stickyErr; err != nil {
 		return
 	}
@@ -1395,7 +1832,7 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 	// new one.
 	//
 	// TODO(bradfitz): or always wait for one? make configurable later?
-	dc, err := s.db.conn()
+	dc, err := s.db.conn(ctx)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -1413,7 +1850,7 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 
 	// No luck; we need to prepare the statement on this connection
 	dc.Lock()
-	si, err = dc.prepareLocked(s.query)
+	si, err = dc.prepareLocked(ctx, s.query)
 	dc.Unlock()
 	if err != nil {
 		s.db.putConn(dc, err)
@@ -1430,12 +1867,19 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 // Query executes a prepared query statement with the given arguments
 // and returns the query results as a *Rows.
 func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryContext executes a prepared query statement with the given
+// arguments and returns the query results as a *Rows. If ctx is done
+// before the query completes, QueryContext returns ctx.Err().
+func (s *Stmt) QueryContext(ctx context.Context, args ...interface{}) (*Rows, error) {
 	s.closemu.RLock()
 	defer s.closemu.RUnlock()
 
 	var rowsi driver.Rows
 	for i := 0; i < maxBadConnRetries; i++ {
-		dc, releaseConn, si, err := s.connStmt()
+		dc, releaseConn, si, err := s.connStmt(ctx)
 		if err != nil {
 			if err == driver.ErrBadConn {
 				continue
@@ -1443,7 +1887,7 @@ func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
 			return nil, err
 		}
 
-		rowsi, err = rowsiFromStatement(driverStmt{dc, si}, args...)
+		rowsi, err = rowsiFromStatement(ctx, driverStmt{dc, si}, args...)
 		if err == nil {
 			// Note: ownership of ci passes to the *Rows, to be freed
 			// with releaseConn.
@@ -1468,7 +1912,7 @@ func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
 	return nil, driver.ErrBadConn
 }
 
-func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error) {
+func rowsiFromStatement(ctx context.Context, ds driverStmt, args ...interface{}) (driver.Rows, error) {
 	ds.Lock()
 	want := ds.si.NumInput()
 	ds.Unlock()
@@ -1486,7 +1930,7 @@ func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error)
 	}
 
 	ds.Lock()
-	rowsi, err := ds.si.Query(dargs)
+	rowsi, err := ctxDriverStmtQuery(ctx, ds.si, dargs)
 	ds.Unlock()
 	if err != nil {
 		return nil, err
@@ -1506,7 +1950,13 @@ func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error)
 //  var name string
 //  err := nameByUseridStmt.QueryRow(id).Scan(&name)
 func (s *Stmt) QueryRow(args ...interface{}) *Row {
-	rows, err := s.Query(args...)
+	return s.QueryRowContext(context.Background(), args...)
+}
+
+// QueryRowContext executes a prepared query statement with the given
+// arguments, like QueryRow. The context is used as in QueryContext.
+func (s *Stmt) QueryRowContext(ctx context.Context, args ...interface{}) *Row {
+	rows, err := s.QueryContext(ctx, args...)
 	if err != nil {
 		return &Row{err: err}
 	}
@@ -1572,7 +2022,7 @@ type Rows struct {
 
 	closed    bool
 	lastcols  []driver.Value
-	lasterr   error       // non-nil only if closed is true
+	lasterr   error       // non-nil only if closed is true, or io.EOF between result sets
 	closeStmt driver.Stmt // if non-nil, statement to Close on close
 }
 
@@ -1590,6 +2040,40 @@ func (rs *Rows) Next() bool {
 		rs.lastcols = make([]driver.Value, len(rs.rowsi.Columns()))
 	}
 	rs.lasterr = rs.rowsi.Next(rs.lastcols)
+	if rs.lasterr != nil {
+		// At the end of a result set, keep the Rows open if the
+		// driver has another result set for NextResultSet to
+		// advance to.
+		if rs.lasterr == io.EOF {
+			if nrs, ok := rs.rowsi.(driver.RowsNextResultSet); ok && nrs.HasNextResultSet() {
+				return false
+			}
+		}
+		rs.Close()
+		return false
+	}
+	return true
+}
+
+// NextResultSet prepares the next result set for reading. It reports
+// whether there is a further result set, or false if there is no
+// further result set or if there is an error advancing to it. The Err
+// method should be consulted to distinguish between the two cases.
+//
+// After calling NextResultSet, the Next method should always be called
+// before scanning. If there are further result sets they may not have
+// rows in the result set.
+func (rs *Rows) NextResultSet() bool {
+	if rs.closed {
+		return false
+	}
+	rs.lastcols = nil
+	nrs, ok := rs.rowsi.(driver.RowsNextResultSet)
+	if !ok {
+		rs.Close()
+		return false
+	}
+	rs.lasterr = nrs.NextResultSet()
 	if rs.lasterr != nil {
 		rs.Close()
 		return false
@@ -1619,6 +2103,110 @@ func (rs *Rows) Columns() ([]string, error) {
 	return rs.rowsi.Columns(), nil
 }
 
+// ColumnTypes returns column information such as column type, length,
+// and nullable. Some information may not be available from some drivers.
+// ColumnTypes returns an error if the rows are closed, or if the rows
+// are from QueryRow and there was a deferred error.
+func (rs *Rows) ColumnTypes() ([]*ColumnType, error) {
+	if rs.closed {
+		return nil, errors.New("sql: Rows are closed")
+	}
+	if rs.rowsi == nil {
+		return nil, errors.New("sql: no Rows available")
+	}
+	return rowsColumnInfoSetup(rs.rowsi), nil
+}
+
+// ColumnType contains the name and type of a column.
+type ColumnType struct {
+	name string
+
+	hasNullable       bool
+	hasLength         bool
+	hasPrecisionScale bool
+
+	nullable     bool
+	length       int64
+	databaseType string
+	precision    int64
+	scale        int64
+	scanType     reflect.Type
+}
+
+// Name returns the name or alias of the column.
+func (ci *ColumnType) Name() string {
+	return ci.name
+}
+
+// Length returns the column type length for variable length column
+// types such as text and binary field types. If the type length is
+// unbounded the value will be math.MaxInt64 (any database limits will
+// still apply). If the column type is not variable length, such as an
+// int, or if not supported by the driver ok is false.
+func (ci *ColumnType) Length() (length int64, ok bool) {
+	return ci.length, ci.hasLength
+}
+
+// DecimalSize returns the scale and precision of a decimal type.
+// If not applicable or if not supported ok is false.
+func (ci *ColumnType) DecimalSize() (precision, scale int64, ok bool) {
+	return ci.precision, ci.scale, ci.hasPrecisionScale
+}
+
+// ScanType returns a Go type suitable for scanning into using Rows.Scan.
+// If a driver does not support this property ScanType will return
+// the type of an empty interface.
+func (ci *ColumnType) ScanType() reflect.Type {
+	return ci.scanType
+}
+
+// Nullable reports whether the column may be null. If a driver does
+// not support this property ok will be false.
+func (ci *ColumnType) Nullable() (nullable, ok bool) {
+	return ci.nullable, ci.hasNullable
+}
+
+// DatabaseTypeName returns the database system name of the column
+// type. If an empty string is returned the driver type name is not
+// supported. Consult your driver documentation for a list of driver
+// data types. Length specifiers are not included.
+// Common type names include "VARCHAR", "TEXT", "NVARCHAR", "DECIMAL",
+// "BOOL", "INT", "BIGINT".
+func (ci *ColumnType) DatabaseTypeName() string {
+	return ci.databaseType
+}
+
+func rowsColumnInfoSetup(rowsi driver.Rows) []*ColumnType {
+	names := rowsi.Columns()
+
+	list := make([]*ColumnType, len(names))
+	for i := range list {
+		ci := &ColumnType{
+			name: names[i],
+		}
+		list[i] = ci
+
+		if prop, ok := rowsi.(driver.RowsColumnTypeScanType); ok {
+			ci.scanType = prop.ColumnTypeScanType(i)
+		} else {
+			ci.scanType = reflect.TypeOf(new(interface{})).Elem()
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeDatabaseTypeName); ok {
+			ci.databaseType = prop.ColumnTypeDatabaseTypeName(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeLength); ok {
+			ci.length, ci.hasLength = prop.ColumnTypeLength(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeNullable); ok {
+			ci.nullable, ci.hasNullable = prop.ColumnTypeNullable(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypePrecisionScale); ok {
+			ci.precision, ci.scale, ci.hasPrecisionScale = prop.ColumnTypePrecisionScale(i)
+		}
+	}
+	return list
+}
+
 // Scan copies the columns in the current row into the values pointed
 // at by dest.
 //
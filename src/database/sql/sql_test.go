@@ -5,9 +5,11 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"runtime"
@@ -206,6 +208,198 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+func TestQueryContext(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.QueryContext(ctx, "SELECT|people|age,name|"); err != context.Canceled {
+		t.Fatalf("QueryContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != context.Canceled {
+		t.Fatalf("ExecContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := db.QueryContext(ctx, "WAIT|10s|SELECT|people|age,name|")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("QueryContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("QueryContext took %v; want it canceled after 50ms", d)
+	}
+
+	// The connection goes back to the pool and remains usable.
+	if n := db.numFreeConns(); n != 1 {
+		t.Fatalf("free conns = %d; want 1", n)
+	}
+	var name string
+	err = db.QueryRowContext(context.Background(), "SELECT|people|name|age=?", 3).Scan(&name)
+	if err != nil || name != "Chris" {
+		t.Fatalf("QueryRowContext = %q, %v; want Chris", name, err)
+	}
+}
+
+// A driver without context support can't be interrupted, but a
+// result that arrives after the context is done must not be reported
+// as a success.
+func TestContextNoCtxDriver(t *testing.T) {
+	if !contains(Drivers(), "test-noctx") {
+		Register("test-noctx", noCtxDriver{fdriver.(*fakeDriver)})
+	}
+	db, err := Open("test-noctx", "noctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exec(t, db, "WIPE")
+	exec(t, db, "CREATE|t|name=string")
+
+	stmt, err := db.Prepare("WAIT|100ms|INSERT|t|name=?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"DB.ExecContext", func(ctx context.Context) error {
+			_, err := db.ExecContext(ctx, "WAIT|100ms|INSERT|t|name=Dave")
+			return err
+		}},
+		{"DB.QueryContext", func(ctx context.Context) error {
+			rows, err := db.QueryContext(ctx, "WAIT|100ms|SELECT|t|name|")
+			if err == nil {
+				rows.Close()
+			}
+			return err
+		}},
+		{"Stmt.ExecContext", func(ctx context.Context) error {
+			_, err := stmt.ExecContext(ctx, "Eve")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := tt.fn(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("%s finishing after the deadline = %v; want %v", tt.name, err, context.DeadlineExceeded)
+		}
+		if err := tt.fn(context.Background()); err != nil {
+			t.Errorf("%s without a deadline: %v", tt.name, err)
+		}
+	}
+}
+
+func TestNamedArgs(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	var name string
+	err := db.QueryRow("SELECT|people|name|age=?age,name=?", Named("age", 2), "Bob").Scan(&name)
+	if err != nil || name != "Bob" {
+		t.Fatalf("QueryRow with named arg = %q, %v; want Bob", name, err)
+	}
+
+	exec(t, db, "INSERT|people|name=?name,age=?age", Named("age", 4), Named("name", "Dave"))
+	err = db.QueryRow("SELECT|people|name|age=?", 4).Scan(&name)
+	if err != nil || name != "Dave" {
+		t.Fatalf("inserted row = %q, %v; want Dave", name, err)
+	}
+
+	_, err = db.Query("SELECT|people|name|age=?age", Named("years", 2))
+	if err == nil || err.Error() != `fakedb: no argument named "age"` {
+		t.Errorf("Query with misnamed arg = %v; want unknown name error", err)
+	}
+}
+
+// plainStmt hides all but the driver.Stmt methods of a statement.
+type plainStmt struct {
+	driver.Stmt
+}
+
+func TestNamedArgsNotSupported(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	dc, err := db.conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.releaseConn(nil)
+	dc.Lock()
+	si, err := dc.ci.Prepare("SELECT|people|name|age=?")
+	dc.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer si.Close()
+
+	args := []driver.NamedValue{{Name: "age", Ordinal: 1, Value: int64(2)}}
+	if _, err := ctxDriverStmtQuery(context.Background(), plainStmt{si}, args); err != errNamedNotSupported {
+		t.Errorf("query with named arg = %v; want %v", err, errNamedNotSupported)
+	}
+	args[0].Name = ""
+	rowsi, err := ctxDriverStmtQuery(context.Background(), plainStmt{si}, args)
+	if err != nil {
+		t.Fatalf("query with positional arg: %v", err)
+	}
+	rowsi.Close()
+}
+
+func TestMultiResultSet(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	rows, err := db.Query("SELECT|people|name|age=?;SELECT|people|age,name|", 1)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"Alice"}) {
+		t.Errorf("first result set = %q; want [Alice]", names)
+	}
+
+	if !rows.NextResultSet() {
+		t.Fatalf("NextResultSet = false; err = %v", rows.Err())
+	}
+	var ages []int
+	for rows.Next() {
+		var age int
+		var name string
+		if err := rows.Scan(&age, &name); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		ages = append(ages, age)
+	}
+	if !reflect.DeepEqual(ages, []int{1, 2, 3}) {
+		t.Errorf("second result set = %v; want [1 2 3]", ages)
+	}
+
+	if rows.NextResultSet() {
+		t.Fatal("NextResultSet = true after the last result set")
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if n := db.numFreeConns(); n != 1 {
+		t.Fatalf("free conns after last result set = %d; want 1", n)
+	}
+}
+
 func TestByteOwnership(t *testing.T) {
 	db := newTestDB(t, "people")
 	defer closeDB(t, db)
@@ -263,6 +457,105 @@ func TestRowsColumns(t *testing.T) {
 	}
 }
 
+func TestRowsColumnTypes(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	rows, err := db.Query("SELECT|people|age,name,photo|")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	tt, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes: %v", err)
+	}
+
+	want := []struct {
+		name     string
+		dbType   string
+		scanType reflect.Type
+		length   int64
+		hasLen   bool
+	}{
+		{"age", "INT32", reflect.TypeOf(int32(0)), 0, false},
+		{"name", "STRING", reflect.TypeOf(""), math.MaxInt64, true},
+		{"photo", "BLOB", reflect.TypeOf([]byte(nil)), math.MaxInt64, true},
+	}
+	if len(tt) != len(want) {
+		t.Fatalf("got %d column types; want %d", len(tt), len(want))
+	}
+	for i, ct := range tt {
+		w := want[i]
+		if ct.Name() != w.name {
+			t.Errorf("column %d: Name = %q; want %q", i, ct.Name(), w.name)
+		}
+		if ct.DatabaseTypeName() != w.dbType {
+			t.Errorf("column %d: DatabaseTypeName = %q; want %q", i, ct.DatabaseTypeName(), w.dbType)
+		}
+		if ct.ScanType() != w.scanType {
+			t.Errorf("column %d: ScanType = %v; want %v", i, ct.ScanType(), w.scanType)
+		}
+		if length, ok := ct.Length(); length != w.length || ok != w.hasLen {
+			t.Errorf("column %d: Length = %d, %v; want %d, %v", i, length, ok, w.length, w.hasLen)
+		}
+		if nullable, ok := ct.Nullable(); nullable || !ok {
+			t.Errorf("column %d: Nullable = %v, %v; want false, true", i, nullable, ok)
+		}
+		if _, _, ok := ct.DecimalSize(); ok {
+			t.Errorf("column %d: DecimalSize supported by fakedb", i)
+		}
+	}
+
+	types := make([]reflect.Type, len(tt))
+	for i, ct := range tt {
+		types[i] = ct.ScanType()
+	}
+	values := make([]interface{}, len(tt))
+	for i := range values {
+		values[i] = reflect.New(types[i]).Interface()
+	}
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			t.Fatalf("failed to scan values in %v", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d rows; want 3", n)
+	}
+	if _, err := rows.ColumnTypes(); err == nil {
+		t.Error("ColumnTypes succeeded on closed Rows")
+	}
+}
+
+func TestRowsColumnTypesNullable(t *testing.T) {
+	db := newTestDB(t, "")
+	defer closeDB(t, db)
+	exec(t, db, "CREATE|t|id=int64,nick=nullstring,score=nullfloat64")
+
+	rows, err := db.Query("SELECT|t|id,nick,score|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	tt, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, true, true} {
+		if nullable, ok := tt[i].Nullable(); nullable != want || !ok {
+			t.Errorf("column %q: Nullable = %v, %v; want %v, true", tt[i].Name(), nullable, ok, want)
+		}
+	}
+	if got, want := tt[1].ScanType(), reflect.TypeOf(NullString{}); got != want {
+		t.Errorf("ScanType of nullstring column = %v; want %v", got, want)
+	}
+}
+
 func TestQueryRow(t *testing.T) {
 	db := newTestDB(t, "people")
 	defer closeDB(t, db)
@@ -532,6 +825,46 @@ func TestTxQuery(t *testing.T) {
 	}
 }
 
+func TestTxStmtContext(t *testing.T) {
+	db := newTestDB(t, "")
+	defer closeDB(t, db)
+	exec(t, db, "CREATE|t1|name=string,age=int32,dead=bool")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.BeginContext(ctx); err != context.Canceled {
+		t.Fatalf("BeginContext = %v; want %v", err, context.Canceled)
+	}
+	if _, err := db.PrepareContext(ctx, "INSERT|t1|name=?,age=?"); err != context.Canceled {
+		t.Fatalf("PrepareContext = %v; want %v", err, context.Canceled)
+	}
+
+	stmt, err := db.Prepare("WAIT|10s|INSERT|t1|name=?,age=?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	txs := tx.StmtContext(context.Background(), stmt)
+	defer txs.Close()
+	tctx, tcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer tcancel()
+	if _, err := txs.ExecContext(tctx, "Bobby", 7); err != context.DeadlineExceeded {
+		t.Fatalf("Stmt.ExecContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := tx.QueryContext(ctx, "SELECT|t1|name|"); err != context.Canceled {
+		t.Fatalf("Tx.QueryContext = %v; want %v", err, context.Canceled)
+	}
+	if _, err := tx.ExecContext(context.Background(), "INSERT|t1|name=?,age=?", "Bobby", 7); err != nil {
+		t.Fatalf("Tx.ExecContext: %v", err)
+	}
+}
+
 func TestTxQueryInvalid(t *testing.T) {
 	db := newTestDB(t, "")
 	defer closeDB(t, db)
@@ -935,6 +1268,223 @@ func TestMaxIdleConns(t *testing.T) {
 	}
 }
 
+func TestMaxIdleClosedStats(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	if st := db.Stats(); st.OpenConnections != 1 || st.Idle != 1 || st.InUse != 0 {
+		t.Fatalf("Stats = %+v; want 1 open, 1 idle", st)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := db.Stats(); st.OpenConnections != 1 || st.Idle != 0 || st.InUse != 1 {
+		t.Fatalf("Stats with open Tx = %+v; want 1 open, 1 in use", st)
+	}
+	tx.Commit()
+
+	db.SetMaxIdleConns(0)
+	if st := db.Stats(); st.MaxIdleClosed != 1 || st.Idle != 0 {
+		t.Errorf("Stats after SetMaxIdleConns(0) = %+v; want 1 closed, 0 idle", st)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if st := db.Stats(); st.MaxIdleClosed != 2 {
+		t.Errorf("MaxIdleClosed = %d; want 2", st.MaxIdleClosed)
+	}
+}
+
+func TestWaitStats(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := db.Exec("INSERT|people|name=Dave,age=?", 4)
+		done <- err
+	}()
+
+	// Wait for the Exec to queue up for the connection.
+	for i := 0; ; i++ {
+		db.mu.Lock()
+		n := len(db.connRequests)
+		db.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if i == 1000 {
+			t.Fatal("Exec never waited for a connection")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	tx.Commit()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	st := db.Stats()
+	if st.MaxOpenConnections != 1 || st.WaitCount != 1 {
+		t.Errorf("Stats = %+v; want MaxOpenConnections 1 and WaitCount 1", st)
+	}
+	if st.WaitDuration < 10*time.Millisecond {
+		t.Errorf("WaitDuration = %v; want at least 10ms", st.WaitDuration)
+	}
+}
+
+// setTestNow makes nowFunc return the real time plus an offset that
+// the returned function advances. The caller must call the restore
+// function once the test is done.
+func setTestNow() (advance func(time.Duration), restore func()) {
+	var mu sync.Mutex
+	var offset time.Duration
+	nowFunc = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Now().Add(offset)
+	}
+	advance = func(d time.Duration) {
+		mu.Lock()
+		offset += d
+		mu.Unlock()
+	}
+	return advance, func() { nowFunc = time.Now }
+}
+
+func TestConnMaxLifetime(t *testing.T) {
+	advance, restore := setTestNow()
+	defer restore()
+
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	driver := db.driver.(*fakeDriver)
+	driver.mu.Lock()
+	opens0 := driver.openCount
+	closes0 := driver.closeCount
+	driver.mu.Unlock()
+
+	db.SetConnMaxLifetime(time.Minute)
+	exec(t, db, "INSERT|people|name=Dave,age=?", 4)
+
+	// The pooled connection outlives its lifetime and is replaced
+	// on its next use.
+	advance(2 * time.Minute)
+	exec(t, db, "INSERT|people|name=Eve,age=?", 5)
+
+	driver.mu.Lock()
+	opens := driver.openCount - opens0
+	closes := driver.closeCount - closes0
+	driver.mu.Unlock()
+	if opens != 1 || closes != 1 {
+		t.Errorf("opens = %d, closes = %d; want 1 and 1", opens, closes)
+	}
+	if st := db.Stats(); st.MaxLifetimeClosed != 1 || st.OpenConnections != 1 {
+		t.Errorf("Stats = %+v; want 1 closed by lifetime, 1 open", st)
+	}
+
+	// Connections returned after expiring are not pooled.
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	advance(2 * time.Minute)
+	tx.Commit()
+	if st := db.Stats(); st.MaxLifetimeClosed != 2 || st.Idle != 0 {
+		t.Errorf("Stats = %+v; want 2 closed by lifetime, 0 idle", st)
+	}
+}
+
+// TestConnMaxLifetimeManyIdle checks that a query gets a live connection
+// even when more idle connections than maxBadConnRetries have expired.
+func TestConnMaxLifetimeManyIdle(t *testing.T) {
+	advance, restore := setTestNow()
+	defer restore()
+
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	const idle = maxBadConnRetries + 5
+	db.SetMaxIdleConns(idle + 5)
+	var txs []*Tx
+	for i := 0; i < idle; i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	for _, tx := range txs {
+		tx.Commit()
+	}
+	if st := db.Stats(); st.Idle != idle {
+		t.Fatalf("Stats = %+v; want %d idle", st, idle)
+	}
+
+	db.SetConnMaxLifetime(time.Minute)
+	advance(2 * time.Minute)
+	if _, err := db.Exec("INSERT|people|name=Dave,age=?", 4); err != nil {
+		t.Fatalf("Exec with %d expired idle connections: %v", idle, err)
+	}
+	if st := db.Stats(); st.MaxLifetimeClosed != idle || st.OpenConnections != 1 {
+		t.Errorf("Stats = %+v; want %d closed by lifetime, 1 open", st, idle)
+	}
+}
+
+func TestConnMaxIdleTime(t *testing.T) {
+	advance, restore := setTestNow()
+	defer restore()
+
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxIdleTime(time.Minute)
+	tx1, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx1.Commit()
+	advance(2 * time.Minute)
+	tx2.Commit()
+
+	// Only the connection idle for longer than a minute is closed.
+	db.mu.Lock()
+	closing := db.connectionCleanerRunLocked()
+	db.mu.Unlock()
+	for _, c := range closing {
+		c.Close()
+	}
+	if len(closing) != 1 {
+		t.Fatalf("cleaner closed %d connections; want 1", len(closing))
+	}
+	if st := db.Stats(); st.MaxIdleTimeClosed != 1 || st.Idle != 1 || st.OpenConnections != 1 {
+		t.Errorf("Stats = %+v; want 1 closed by idle time, 1 idle", st)
+	}
+
+	db.mu.Lock()
+	running := db.cleanerCh != nil
+	db.mu.Unlock()
+	if !running {
+		t.Errorf("connection cleaner not running")
+	}
+	db.SetConnMaxIdleTime(0)
+}
+
 func TestMaxOpenConns(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping in short mode")
@@ -1070,6 +1620,39 @@ func TestMaxOpenConns(t *testing.T) {
 	}
 }
 
+func TestConnPoolWaitContext(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext waiting for a conn = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if err := db.PingContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("PingContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	db.mu.Lock()
+	n := len(db.connRequests)
+	db.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("%d conn requests pending after context expired; want 0", n)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(context.Background(), "INSERT|people|name=Dave,age=?", 4); err != nil {
+		t.Fatalf("Exec after conn was released: %v", err)
+	}
+}
+
 func TestSingleOpenConn(t *testing.T) {
 	db := newTestDB(t, "people")
 	defer closeDB(t, db)
@@ -167,6 +167,30 @@ func hasSubdir(root, dir string) (rel string, ok bool) {
 	return filepath.ToSlash(dir[len(root):]), true
 }
 
+// hasPathElem reports whether the slash-separated path
+// has an element equal to elem.
+func hasPathElem(path, elem string) bool {
+	for _, e := range strings.Split(path, "/") {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGoFiles reports whether dir contains any files with names ending in .go.
+// A vendor directory satisfies an import only if it has Go files,
+// so that vendoring just a/b/c does not hide the non-vendored a/b.
+func hasGoFiles(ctxt *Context, dir string) bool {
+	ents, _ := ctxt.readDir(dir)
+	for _, ent := range ents {
+		if !ent.IsDir() && strings.HasSuffix(ent.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
 // readDir calls ctxt.ReadDir (if not nil) or else ioutil.ReadDir.
 func (ctxt *Context) readDir(path string) ([]os.FileInfo, error) {
 	if f := ctxt.ReadDir; f != nil {
@@ -342,6 +366,19 @@ const (
 	// or finds conflicting comments in multiple source files.
 	// See golang.org/s/go14customimport for more information.
 	ImportComment
+
+	// If AllowVendor is set, Import searches the vendor directories
+	// that apply to srcDir before searching GOROOT and GOPATH.
+	// The vendor directories that apply are those named vendor in
+	// srcDir and in each of its parents, up to the src directory of
+	// the GOROOT or GOPATH tree containing srcDir; the innermost
+	// vendor directory providing the package wins.
+	// A package found in a vendor directory has an ImportPath that
+	// includes the path leading to the vendor directory: if
+	// Import("y", "$GOPATH/src/x/z", AllowVendor) finds
+	// $GOPATH/src/x/vendor/y, the returned package's ImportPath
+	// is "x/vendor/y", not plain "y".
+	AllowVendor
 )
 
 // A Package describes the Go package found in a directory.
@@ -464,20 +501,23 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 
 	var pkga string
 	var pkgerr error
-	switch ctxt.Compiler {
-	case "gccgo":
-		dir, elem := pathpkg.Split(p.ImportPath)
-		pkga = "pkg/gccgo_" + ctxt.GOOS + "_" + ctxt.GOARCH + "/" + dir + "lib" + elem + ".a"
-	case "gc":
-		suffix := ""
-		if ctxt.InstallSuffix != "" {
-			suffix = "_" + ctxt.InstallSuffix
+	setPkga := func() {
+		switch ctxt.Compiler {
+		case "gccgo":
+			dir, elem := pathpkg.Split(p.ImportPath)
+			pkga = "pkg/gccgo_" + ctxt.GOOS + "_" + ctxt.GOARCH + "/" + dir + "lib" + elem + ".a"
+		case "gc":
+			suffix := ""
+			if ctxt.InstallSuffix != "" {
+				suffix = "_" + ctxt.InstallSuffix
+			}
+			pkga = "pkg/" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix + "/" + p.ImportPath + ".a"
+		default:
+			// Save error for end of function.
+			pkgerr = fmt.Errorf("import %q: unknown compiler %q", path, ctxt.Compiler)
 		}
-		pkga = "pkg/" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix + "/" + p.ImportPath + ".a"
-	default:
-		// Save error for end of function.
-		pkgerr = fmt.Errorf("import %q: unknown compiler %q", path, ctxt.Compiler)
 	}
+	setPkga()
 
 	binaryOnly := false
 	if IsLocalImport(path) {
@@ -534,10 +574,52 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 
 		// tried records the location of unsuccessful package lookups
 		var tried struct {
+			vendor []string
 			goroot string
 			gopath []string
 		}
 
+		// Vendor directories get first chance to satisfy import.
+		if mode&AllowVendor != 0 && srcDir != "" {
+			searchVendor := func(root string, isGoroot bool) bool {
+				sub, ok := ctxt.hasSubdir(ctxt.joinPath(root, "src"), srcDir)
+				if !ok || hasPathElem(sub, "testdata") {
+					return false
+				}
+				for {
+					vendor := ctxt.joinPath(root, "src", sub, "vendor")
+					if ctxt.isDir(vendor) {
+						dir := ctxt.joinPath(vendor, path)
+						if ctxt.isDir(dir) && hasGoFiles(ctxt, dir) {
+							p.Dir = dir
+							p.ImportPath = pathpkg.Join(sub, "vendor", path)
+							p.Goroot = isGoroot
+							p.Root = root
+							setPkga() // p.ImportPath changed
+							return true
+						}
+						tried.vendor = append(tried.vendor, dir)
+					}
+					if sub == "" {
+						return false
+					}
+					i := strings.LastIndex(sub, "/")
+					if i < 0 {
+						i = 0
+					}
+					sub = sub[:i]
+				}
+			}
+			if ctxt.GOROOT != "" && searchVendor(ctxt.GOROOT, true) {
+				goto Found
+			}
+			for _, root := range ctxt.gopath() {
+				if searchVendor(root, false) {
+					goto Found
+				}
+			}
+		}
+
 		// Determine directory from import path.
 		if ctxt.GOROOT != "" {
 			dir := ctxt.joinPath(ctxt.GOROOT, "src", path)
@@ -565,13 +647,18 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 
 		// package was not found
 		var paths []string
+		format := "\t%s (vendor tree)"
+		for _, dir := range tried.vendor {
+			paths = append(paths, fmt.Sprintf(format, dir))
+			format = "\t%s"
+		}
 		if tried.goroot != "" {
 			paths = append(paths, fmt.Sprintf("\t%s (from $GOROOT)", tried.goroot))
 		} else {
 			paths = append(paths, "\t($GOROOT not set)")
 		}
 		var i int
-		var format = "\t%s (from $GOPATH)"
+		format = "\t%s (from $GOPATH)"
 		for ; i < len(tried.gopath); i++ {
 			if i > 0 {
 				format = "\t%s"
@@ -222,3 +222,47 @@ func TestImportCmd(t *testing.T) {
 		t.Fatalf("Import cmd/internal/objfile returned Dir=%q, want %q", filepath.ToSlash(p.Dir), ".../src/cmd/internal/objfile")
 	}
 }
+
+func TestImportVendor(t *testing.T) {
+	gopath, err := filepath.Abs("testdata/withvendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt := Default
+	ctxt.GOPATH = gopath
+	srcDir := filepath.Join(ctxt.GOPATH, "src", "a", "b")
+
+	tests := []struct {
+		path       string
+		importPath string
+	}{
+		// The innermost vendor directory wins.
+		{"c/d", "a/b/vendor/c/d"},
+		// a/b/vendor/e has no Go files, so it does not hide a/vendor/e.
+		{"e", "a/vendor/e"},
+		{"e/f", "a/b/vendor/e/f"},
+	}
+	for _, tt := range tests {
+		p, err := ctxt.Import(tt.path, srcDir, AllowVendor)
+		if err != nil {
+			t.Errorf("Import(%q, AllowVendor): %v", tt.path, err)
+			continue
+		}
+		if p.ImportPath != tt.importPath {
+			t.Errorf("Import(%q, AllowVendor).ImportPath = %q, want %q", tt.path, p.ImportPath, tt.importPath)
+		}
+		if want := filepath.Join(ctxt.GOPATH, "src", filepath.FromSlash(tt.importPath)); p.Dir != want {
+			t.Errorf("Import(%q, AllowVendor).Dir = %q, want %q", tt.path, p.Dir, want)
+		}
+	}
+
+	// Without AllowVendor, vendor directories are not searched.
+	if _, err := ctxt.Import("c/d", srcDir, 0); err == nil {
+		t.Errorf(`Import("c/d", 0) succeeded, want error`)
+	}
+
+	// Vendor directories apply only below their parent.
+	if _, err := ctxt.Import("c/d", filepath.Join(gopath, "src"), AllowVendor); err == nil {
+		t.Errorf(`Import("c/d") from outside a succeeded, want error`)
+	}
+}
@@ -121,13 +121,14 @@ var pkgDeps = map[string][]string{
 	// End of linear dependency definitions.
 
 	// Operating system access.
-	"syscall":       {"L0", "unicode/utf16"},
-	"time":          {"L0", "syscall"},
-	"os":            {"L1", "os", "syscall", "time"},
-	"path/filepath": {"L2", "os", "syscall"},
-	"io/ioutil":     {"L2", "os", "path/filepath", "time"},
-	"os/exec":       {"L2", "os", "path/filepath", "syscall"},
-	"os/signal":     {"L2", "os", "syscall"},
+	"syscall":          {"L0", "unicode/utf16"},
+	"time":             {"L0", "syscall"},
+	"internal/testlog": {"L0"},
+	"os":               {"L1", "os", "syscall", "time", "internal/testlog"},
+	"path/filepath":    {"L2", "os", "syscall"},
+	"io/ioutil":        {"L2", "os", "path/filepath", "time"},
+	"os/exec":          {"L2", "os", "path/filepath", "syscall"},
+	"os/signal":        {"L2", "os", "syscall"},
 
 	// OS enables basic operating system functionality,
 	// but not direct use of package syscall, nor os/signal.
@@ -147,12 +148,16 @@ var pkgDeps = map[string][]string{
 	"regexp":         {"L2", "regexp/syntax"},
 	"regexp/syntax":  {"L2"},
 	"runtime/debug":  {"L2", "fmt", "io/ioutil", "os", "time"},
-	"runtime/pprof":  {"L2", "fmt", "text/tabwriter"},
+	"runtime/pprof":  {"L2", "compress/gzip", "context", "fmt", "io/ioutil", "text/tabwriter", "time"},
 	"text/tabwriter": {"L2"},
 
-	"testing":        {"L2", "flag", "fmt", "os", "runtime/pprof", "time"},
-	"testing/iotest": {"L2", "log"},
-	"testing/quick":  {"L2", "flag", "fmt", "reflect"},
+	"testing":                   {"L2", "flag", "fmt", "internal/testlog", "io/ioutil", "os", "reflect", "time"},
+	"testing/iotest":            {"L2", "log"},
+	"testing/quick":             {"L2", "flag", "fmt", "reflect", "time"},
+	"testing/internal/testdeps": {"L2", "regexp", "runtime/pprof"},
+
+	// Cancelation and deadlines, used across API boundaries.
+	"context": {"L2", "fmt", "time"},
 
 	// L4 is defined as L3+fmt+log+time, because in general once
 	// you're using L3 packages, use of fmt, log, or time is not a big deal.
@@ -188,8 +193,8 @@ var pkgDeps = map[string][]string{
 	"compress/gzip":       {"L4", "compress/flate"},
 	"compress/lzw":        {"L4"},
 	"compress/zlib":       {"L4", "compress/flate"},
-	"database/sql":        {"L4", "container/list", "database/sql/driver"},
-	"database/sql/driver": {"L4", "time"},
+	"database/sql":        {"L4", "container/list", "context", "database/sql/driver"},
+	"database/sql/driver": {"L4", "context", "time"},
 	"debug/dwarf":         {"L4"},
 	"debug/elf":           {"L4", "OS", "debug/dwarf"},
 	"debug/gosym":         {"L4"},
@@ -212,12 +217,16 @@ var pkgDeps = map[string][]string{
 	"image/jpeg":          {"L4"},
 	"image/png":           {"L4", "compress/zlib"},
 	"index/suffixarray":   {"L4", "regexp"},
+	"internal/trace":      {"L4", "OS"},
 	"math/big":            {"L4"},
 	"mime":                {"L4", "OS", "syscall"},
 	"net/url":             {"L4"},
 	"text/scanner":        {"L4", "OS"},
 	"text/template/parse": {"L4"},
 
+	// The profile representation used by cmd/pprof.
+	"internal/pprof/profile": {"L4", "OS", "compress/gzip", "regexp"},
+
 	"html/template": {
 		"L4", "OS", "encoding/json", "html", "text/template",
 		"text/template/parse",
@@ -256,17 +265,21 @@ var pkgDeps = map[string][]string{
 	"net/textproto": {"L4", "OS", "net"},
 
 	// Core crypto.
-	"crypto/aes":    {"L3"},
-	"crypto/des":    {"L3"},
-	"crypto/hmac":   {"L3"},
-	"crypto/md5":    {"L3"},
-	"crypto/rc4":    {"L3"},
-	"crypto/sha1":   {"L3"},
-	"crypto/sha256": {"L3"},
-	"crypto/sha512": {"L3"},
+	"crypto/aes":              {"L3"},
+	"crypto/chacha20poly1305": {"L3"},
+	"crypto/curve25519":       {"L3"},
+	"crypto/des":              {"L3"},
+	"crypto/hmac":             {"L3"},
+	"crypto/md5":              {"L3"},
+	"crypto/rc4":              {"L3"},
+	"crypto/sha1":             {"L3"},
+	"crypto/sha256":           {"L3"},
+	"crypto/sha512":           {"L3"},
 
 	"CRYPTO": {
 		"crypto/aes",
+		"crypto/chacha20poly1305",
+		"crypto/curve25519",
 		"crypto/des",
 		"crypto/hmac",
 		"crypto/md5",
@@ -285,13 +298,17 @@ var pkgDeps = map[string][]string{
 	// We could avoid some of the fmt, but math/big imports fmt anyway.
 	"crypto/dsa":      {"L4", "CRYPTO", "math/big"},
 	"crypto/ecdsa":    {"L4", "CRYPTO", "crypto/elliptic", "math/big", "encoding/asn1"},
+	"crypto/ed25519":  {"L3", "CRYPTO", "crypto/rand", "crypto/ed25519/internal/edwards25519"},
 	"crypto/elliptic": {"L4", "CRYPTO", "math/big"},
 	"crypto/rsa":      {"L4", "CRYPTO", "crypto/rand", "math/big"},
 
+	"crypto/ed25519/internal/edwards25519": {},
+
 	"CRYPTO-MATH": {
 		"CRYPTO",
 		"crypto/dsa",
 		"crypto/ecdsa",
+		"crypto/ed25519",
 		"crypto/elliptic",
 		"crypto/rand",
 		"crypto/rsa",
@@ -315,10 +332,11 @@ var pkgDeps = map[string][]string{
 	"net/smtp":       {"L4", "CRYPTO", "NET", "crypto/tls"},
 
 	// HTTP, kingpin of dependencies.
+	"net/http/internal/hpack": {"L4"},
 	"net/http": {
 		"L4", "NET", "OS",
 		"compress/gzip", "crypto/tls", "mime/multipart", "runtime/debug",
-		"net/http/internal",
+		"net/http/internal", "net/http/internal/hpack",
 	},
 
 	// HTTP-using packages.
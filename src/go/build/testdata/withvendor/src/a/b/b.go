@@ -0,0 +1,8 @@
+package b
+
+import (
+	"c/d"
+	"e"
+)
+
+var _ = d.D + e.E
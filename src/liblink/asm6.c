@@ -1917,6 +1917,17 @@ prefixof(Link *ctxt, Addr *a)
 			return 0x65; // GS
 		}
 	}
+	if(a->index == D_TLS && ctxt->flag_shared) {
+		// In a shared object, off(reg)(TLS*1) indexes from
+		// the TLS base register using the offset that
+		// MOVQ TLS, reg loaded from the GOT.
+		switch(ctxt->headtype) {
+		default:
+			sysfatal("unknown TLS base register for %s -shared", headstr(ctxt->headtype));
+		case Hlinux:
+			return 0x64; // FS
+		}
+	}
 	switch(a->index) {
 	case D_CS:
 		return 0x2e;
@@ -2102,6 +2113,7 @@ oclass(Link *ctxt, Addr *a)
 
 	case D_EXTERN:
 	case D_STATIC:
+	case D_GOTREF:
 	case D_AUTO:
 	case D_PARAM:
 		return Ym;
@@ -2313,6 +2325,20 @@ vaddr(Link *ctxt, Addr *a, Reloc *r)
 			r->xsym = s;
 		}
 		break;
+
+	case D_GOTREF:
+		s = a->sym;
+		if(r == nil) {
+			ctxt->diag("need reloc for %D", a);
+			sysfatal("reloc");
+		}
+		r->siz = 4;
+		r->type = R_GOTPCREL;
+		r->off = -1;	// caller must fill in
+		r->sym = s;
+		r->add = v;
+		v = 0;
+		break;
 	
 	case D_INDIR+D_TLS:
 		if(r == nil) {
@@ -2396,6 +2422,7 @@ asmandsz(Link *ctxt, Addr *a, int r, int rex, int m64)
 			goto bad;
 		case D_STATIC:
 		case D_EXTERN:
+		case D_GOTREF:
 			t = D_NONE;
 			v = vaddr(ctxt, a, &rel);
 			break;
@@ -2412,7 +2439,7 @@ asmandsz(Link *ctxt, Addr *a, int r, int rex, int m64)
 
 	ctxt->rexflag |= (regrex[t] & Rxb) | rex;
 	if(t == D_NONE || (D_CS <= t && t <= D_GS) || t == D_TLS) {
-		if((a->sym == nil || !isextern(a->sym)) && t == D_NONE && (a->type == D_STATIC || a->type == D_EXTERN) || ctxt->asmode != 64) {
+		if((a->sym == nil || !isextern(a->sym)) && t == D_NONE && (a->type == D_STATIC || a->type == D_EXTERN || a->type == D_GOTREF) || ctxt->asmode != 64) {
 			*ctxt->andptr++ = (0 << 6) | (5 << 0) | (r << 3);
 			goto putrelv;
 		}
@@ -2438,7 +2465,7 @@ asmandsz(Link *ctxt, Addr *a, int r, int rex, int m64)
 		goto putrelv;
 	}
 	if(t >= D_AX && t <= D_R15) {
-		if(a->index == D_TLS) {
+		if(a->index == D_TLS && !ctxt->flag_shared) {
 			memset(&rel, 0, sizeof rel);
 			rel.type = R_TLS_IE;
 			rel.siz = 4;
@@ -3414,6 +3441,26 @@ mfound:
 			*ctxt->andptr++ = 0x8B;
 			asmand(ctxt, &pp.from, &p->to);
 			break;
+
+		case Hlinux:
+			// Only shared objects get here; see canuselocaltls in obj6.c.
+			// The instruction loads the offset of runtime.tlsg from the
+			// TLS base out of the GOT:
+			//	MOVQ runtime.tlsg@GOTTPOFF(RIP), reg
+			// The use of reg that follows, off(reg)(TLS*1), adds the
+			// FS prefix; see prefixof.
+			if(!ctxt->flag_shared)
+				sysfatal("unknown TLS base location for linux without -shared");
+			ctxt->rexflag |= Pw | (regrex[p->to.type] & Rxr);
+			*ctxt->andptr++ = 0x8B;
+			*ctxt->andptr++ = (0 << 6) | (5 << 0) | (reg[p->to.type] << 3);
+			r = addrel(ctxt->cursym);
+			r->off = p->pc + ctxt->andptr - ctxt->and;
+			r->type = R_TLS_IE;
+			r->siz = 4;
+			r->add = -4;
+			put4(ctxt, 0);
+			break;
 		}
 		break;
 	}
@@ -3591,7 +3638,7 @@ asmins(Link *ctxt, Prog *p)
 			break;
 		if(ctxt->rexflag)
 			r->off++;
-		if(r->type == R_PCREL || r->type == R_CALL)
+		if(r->type == R_PCREL || r->type == R_CALL || r->type == R_GOTPCREL)
 			r->add -= p->pc + n - (r->off + r->siz);
 	}
 
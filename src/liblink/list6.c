@@ -212,6 +212,10 @@ Dconv(Fmt *fp)
 		sprint(str, "%s<>+%lld(SB)", a->sym->name, a->offset);
 		break;
 
+	case D_GOTREF:
+		sprint(str, "%s@GOT(SB)", a->sym->name);
+		break;
+
 	case D_AUTO:
 		if(a->sym)
 			sprint(str, "%s+%lld(SP)", a->sym->name, a->offset);
@@ -115,6 +115,34 @@ haspathprefix(char *s, char *t)
 	return s[i] == '\0' || s[i] == '/' || s[i] == '\\';
 }
 
+// trimprefix returns the length of the element of ctxt->trimpath,
+// a list of path prefixes separated by semicolons, that is a prefix
+// of s, or -1 if there is none.
+static int
+trimprefix(Link *ctxt, char *s)
+{
+	char prefix[1024], *p, *q;
+	int n;
+
+	if(ctxt->trimpath == nil)
+		return -1;
+	for(p = ctxt->trimpath; *p; p = q) {
+		q = strchr(p, ';');
+		if(q == nil)
+			q = p + strlen(p);
+		n = q - p;
+		if(*q == ';')
+			q++;
+		if(n == 0 || n >= sizeof prefix)
+			continue;
+		memmove(prefix, p, n);
+		prefix[n] = '\0';
+		if(haspathprefix(s, prefix))
+			return n;
+	}
+	return -1;
+}
+
 // This is a simplified copy of linklinefmt above.
 // It doesn't allow printing the full stack, and it returns the file name and line number separately.
 // TODO: Unify with linklinefmt somehow.
@@ -129,7 +157,7 @@ linkgetline(Link *ctxt, int32 line, LSym **f, int32 *l)
 		int32	ldel;	/* delta line number to apply to #line */
 	} a[HISTSZ];
 	int32 lno, d, dlno;
-	int n;
+	int n, t;
 	Hist *h;
 	char buf[1024], buf1[1024], *file;
 
@@ -188,12 +216,13 @@ linkgetline(Link *ctxt, int32 line, LSym **f, int32 *l)
 	else
 		snprint(buf, sizeof buf, "%s/%s", ctxt->pathname, file);
 
-	// Remove leading ctxt->trimpath, or else rewrite $GOROOT to $GOROOT_FINAL.
-	if(haspathprefix(buf, ctxt->trimpath)) {
-		if(strlen(buf) == strlen(ctxt->trimpath))
+	// Remove a leading element of ctxt->trimpath, or else rewrite $GOROOT to $GOROOT_FINAL.
+	t = trimprefix(ctxt, buf);
+	if(t >= 0) {
+		if(strlen(buf) == t)
 			strcpy(buf, "??");
 		else {
-			snprint(buf1, sizeof buf1, "%s", buf+strlen(ctxt->trimpath)+1);
+			snprint(buf1, sizeof buf1, "%s", buf+t+1);
 			if(buf1[0] == '\0')
 				strcpy(buf1, "??");
 			strcpy(buf, buf1);
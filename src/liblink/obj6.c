@@ -98,10 +98,15 @@ settextflag(Prog *p, int f)
 }
 
 static void nacladdr(Link*, Prog*, Addr*);
+static void rewritetogot(Link*, Prog*);
 
 static int
 canuselocaltls(Link *ctxt)
 {
+	// Code in a shared object cannot know the offset of its
+	// thread-local storage; it must be loaded from the GOT.
+	if(ctxt->flag_shared)
+		return 0;
 	switch(ctxt->headtype) {
 	case Hplan9:
 	case Hwindows:
@@ -307,6 +312,131 @@ progedit(Link *ctxt, Prog *p)
 		}
 		break;
 	}
+
+	if(ctxt->flag_dynlink)
+		rewritetogot(ctxt, p);
+}
+
+static int
+isextern(Addr *a)
+{
+	return a->type == D_EXTERN && a->sym != nil;
+}
+
+static int
+isexternaddr(Addr *a)
+{
+	return a->type == D_ADDR && a->index == D_EXTERN && a->sym != nil;
+}
+
+static int
+usesr15(Addr *a)
+{
+	return a->type == D_R15 || a->type == D_INDIR+D_R15 || a->index == D_R15;
+}
+
+// In code compiled with -dynlink, a global symbol may be defined
+// in a Go shared library, so every reference to one must go through
+// the symbol's GOT entry, which is loaded into R15. The instruction
+//	MOVQ $sym+off(SB), CX
+// becomes
+//	MOVQ sym@GOT(SB), CX
+//	LEAQ off(CX), CX
+// and any other use of $sym+off(SB) or sym+off(SB) becomes
+//	MOVQ sym@GOT(SB), R15
+//	... $off(R15) or off(R15) ...
+// (LEAQ rather than ADDQ, so that flags are preserved). The linker
+// turns the GOT load back into a LEAQ when sym is defined in the
+// module being linked.
+static void
+rewritetogot(Link *ctxt, Prog *p)
+{
+	Prog *q, *link;
+	Addr *a;
+	vlong off;
+
+	switch(p->as) {
+	case ATEXT:
+	case ADATA:
+	case AGLOBL:
+	case ANAME:
+	case ATYPE:
+	case AUSEFIELD:
+	case AFUNCDATA:
+	case APCDATA:
+	case ANOP:
+	case ACALL:
+	case AJMP:
+	case ARET:
+		return;
+	case ADUFFZERO:
+	case ADUFFCOPY:
+		ctxt->diag("%P: cannot use DUFFZERO or DUFFCOPY with -dynlink", p);
+		return;
+	}
+
+	if(isextern(&p->from) || isexternaddr(&p->from))
+		a = &p->from;
+	else if(isextern(&p->to) || isexternaddr(&p->to))
+		a = &p->to;
+	else
+		return;
+	if((a == &p->from && (isextern(&p->to) || isexternaddr(&p->to))) ||
+	   usesr15(&p->from) || usesr15(&p->to)) {
+		ctxt->diag("%P: cannot rewrite to use the GOT with -dynlink", p);
+		return;
+	}
+
+	if(isexternaddr(a) && a == &p->from && p->as == AMOVQ && D_AX <= p->to.type && p->to.type <= D_R15) {
+		off = p->from.offset;
+		p->from.type = D_GOTREF;
+		p->from.index = D_NONE;
+		p->from.offset = 0;
+		if(off != 0) {
+			q = appendp(ctxt, p);
+			q->as = ALEAQ;
+			q->from.type = D_INDIR + p->to.type;
+			q->from.offset = off;
+			q->to = p->to;
+		}
+		return;
+	}
+
+	// Move the instruction to a new Prog after p and
+	// turn p itself into the GOT load, so that jumps
+	// to p still execute the load.
+	q = appendp(ctxt, p);
+	link = q->link;
+	*q = *p;
+	q->link = link;
+	a = (a == &p->from) ? &q->from : &q->to;
+
+	*p = zprg;
+	p->link = q;
+	p->lineno = q->lineno;
+	p->mode = q->mode;
+	p->pc = q->pc;
+	p->as = AMOVQ;
+	p->from.type = D_GOTREF;
+	p->from.sym = a->sym;
+	p->to.type = D_R15;
+
+	off = a->offset;
+	a->sym = nil;
+	if(a->type == D_ADDR) {
+		if(off != 0) {
+			p = appendp(ctxt, p);
+			p->as = ALEAQ;
+			p->from.type = D_INDIR+D_R15;
+			p->from.offset = off;
+			p->to.type = D_R15;
+		}
+		a->type = D_R15;
+		a->index = D_NONE;
+		a->offset = 0;
+	} else {
+		a->type = D_INDIR+D_R15;
+	}
 }
 
 static void
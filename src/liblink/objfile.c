@@ -795,12 +795,14 @@ rdsym(Link *ctxt, Biobuf *f, char *pkg)
 			int32 i32;
 			i32 = strtoul(s->name+5, nil, 16);
 			s->type = SRODATA;
+			s->local = 1;
 			adduint32(ctxt, s, i32);
 			s->reachable = 0;
 		} else if(strncmp(s->name, "$f64.", 5) == 0 || strncmp(s->name, "$i64.", 5) == 0) {
 			int64 i64;
 			i64 = strtoull(s->name+5, nil, 16);
 			s->type = SRODATA;
+			s->local = 1;
 			adduint64(ctxt, s, i64);
 			s->reachable = 0;
 		}
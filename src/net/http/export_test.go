@@ -106,3 +106,11 @@ func SetPendingDialHooks(before, after func()) {
 var ExportServerNewConn = (*Server).newConn
 
 var ExportCloseWriteAndWait = (*conn).closeWriteAndWait
+
+// SetNewConnGracePeriod sets how long Server.Shutdown leaves
+// new connections open and returns a function restoring it.
+func SetNewConnGracePeriod(d time.Duration) (restore func()) {
+	old := newConnGracePeriod
+	newConnGracePeriod = d
+	return func() { newConnGracePeriod = old }
+}
@@ -0,0 +1,729 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 framing and flow control. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// http2ClientPreface is the string that must be sent by new
+// connections from clients.
+const http2ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	// http2NextProtoTLS is the NPN/ALPN protocol negotiated during
+	// HTTP/2's TLS setup.
+	http2NextProtoTLS = "h2"
+
+	http2FrameHeaderLen = 9
+
+	// SETTINGS_INITIAL_WINDOW_SIZE default, section 6.9.2.
+	http2InitialWindowSize = 65535
+
+	// SETTINGS_MAX_FRAME_SIZE default and bounds, section 6.5.2.
+	http2DefaultMaxFrameSize = 16384
+	http2MaxFrameSizeLimit   = 1<<24 - 1
+
+	// http2MaxWindow is the largest legal flow-control window.
+	http2MaxWindow = 1<<31 - 1
+)
+
+// An http2FrameType is a registered frame type as defined in section
+// 11.2.
+type http2FrameType uint8
+
+const (
+	http2FrameData         http2FrameType = 0x0
+	http2FrameHeaders      http2FrameType = 0x1
+	http2FramePriority     http2FrameType = 0x2
+	http2FrameRSTStream    http2FrameType = 0x3
+	http2FrameSettings     http2FrameType = 0x4
+	http2FramePushPromise  http2FrameType = 0x5
+	http2FramePing         http2FrameType = 0x6
+	http2FrameGoAway       http2FrameType = 0x7
+	http2FrameWindowUpdate http2FrameType = 0x8
+	http2FrameContinuation http2FrameType = 0x9
+)
+
+var http2frameName = map[http2FrameType]string{
+	http2FrameData:         "DATA",
+	http2FrameHeaders:      "HEADERS",
+	http2FramePriority:     "PRIORITY",
+	http2FrameRSTStream:    "RST_STREAM",
+	http2FrameSettings:     "SETTINGS",
+	http2FramePushPromise:  "PUSH_PROMISE",
+	http2FramePing:         "PING",
+	http2FrameGoAway:       "GOAWAY",
+	http2FrameWindowUpdate: "WINDOW_UPDATE",
+	http2FrameContinuation: "CONTINUATION",
+}
+
+func (t http2FrameType) String() string {
+	if s, ok := http2frameName[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN_FRAME_TYPE_%d", uint8(t))
+}
+
+// Frame flags. Not all flags apply to all frame types.
+const (
+	http2FlagEndStream  = 0x1 // DATA, HEADERS
+	http2FlagAck        = 0x1 // SETTINGS, PING
+	http2FlagEndHeaders = 0x4 // HEADERS, CONTINUATION
+	http2FlagPadded     = 0x8 // DATA, HEADERS
+	http2FlagPriority   = 0x20
+)
+
+// An http2ErrCode is an unsigned 32-bit error code as defined in
+// section 7.
+type http2ErrCode uint32
+
+const (
+	http2ErrCodeNo                 http2ErrCode = 0x0
+	http2ErrCodeProtocol           http2ErrCode = 0x1
+	http2ErrCodeInternal           http2ErrCode = 0x2
+	http2ErrCodeFlowControl        http2ErrCode = 0x3
+	http2ErrCodeSettingsTimeout    http2ErrCode = 0x4
+	http2ErrCodeStreamClosed       http2ErrCode = 0x5
+	http2ErrCodeFrameSize          http2ErrCode = 0x6
+	http2ErrCodeRefusedStream      http2ErrCode = 0x7
+	http2ErrCodeCancel             http2ErrCode = 0x8
+	http2ErrCodeCompression        http2ErrCode = 0x9
+	http2ErrCodeConnect            http2ErrCode = 0xa
+	http2ErrCodeEnhanceYourCalm    http2ErrCode = 0xb
+	http2ErrCodeInadequateSecurity http2ErrCode = 0xc
+	http2ErrCodeHTTP11Required     http2ErrCode = 0xd
+)
+
+var http2errCodeName = map[http2ErrCode]string{
+	http2ErrCodeNo:                 "NO_ERROR",
+	http2ErrCodeProtocol:           "PROTOCOL_ERROR",
+	http2ErrCodeInternal:           "INTERNAL_ERROR",
+	http2ErrCodeFlowControl:        "FLOW_CONTROL_ERROR",
+	http2ErrCodeSettingsTimeout:    "SETTINGS_TIMEOUT",
+	http2ErrCodeStreamClosed:       "STREAM_CLOSED",
+	http2ErrCodeFrameSize:          "FRAME_SIZE_ERROR",
+	http2ErrCodeRefusedStream:      "REFUSED_STREAM",
+	http2ErrCodeCancel:             "CANCEL",
+	http2ErrCodeCompression:        "COMPRESSION_ERROR",
+	http2ErrCodeConnect:            "CONNECT_ERROR",
+	http2ErrCodeEnhanceYourCalm:    "ENHANCE_YOUR_CALM",
+	http2ErrCodeInadequateSecurity: "INADEQUATE_SECURITY",
+	http2ErrCodeHTTP11Required:     "HTTP_1_1_REQUIRED",
+}
+
+func (e http2ErrCode) String() string {
+	if s, ok := http2errCodeName[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown error code 0x%x", uint32(e))
+}
+
+// http2ConnectionError is an error that results in the termination
+// of the entire connection.
+type http2ConnectionError http2ErrCode
+
+func (e http2ConnectionError) Error() string {
+	return fmt.Sprintf("http2: connection error: %v", http2ErrCode(e))
+}
+
+// http2StreamError is an error that only affects one stream within
+// an HTTP/2 connection.
+type http2StreamError struct {
+	StreamID uint32
+	Code     http2ErrCode
+}
+
+func (e http2StreamError) Error() string {
+	return fmt.Sprintf("http2: stream error: stream ID %d; %v", e.StreamID, e.Code)
+}
+
+// An http2SettingID is an HTTP/2 setting as defined in section 6.5.2.
+type http2SettingID uint16
+
+const (
+	http2SettingHeaderTableSize      http2SettingID = 0x1
+	http2SettingEnablePush           http2SettingID = 0x2
+	http2SettingMaxConcurrentStreams http2SettingID = 0x3
+	http2SettingInitialWindowSize    http2SettingID = 0x4
+	http2SettingMaxFrameSize         http2SettingID = 0x5
+	http2SettingMaxHeaderListSize    http2SettingID = 0x6
+)
+
+// An http2Setting is a setting parameter: which setting it is, and
+// its value.
+type http2Setting struct {
+	ID  http2SettingID
+	Val uint32
+}
+
+// valid reports whether the setting value is in range, and the
+// connection error to report if not.
+func (s http2Setting) valid() error {
+	switch s.ID {
+	case http2SettingEnablePush:
+		if s.Val != 0 && s.Val != 1 {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+	case http2SettingInitialWindowSize:
+		if s.Val > http2MaxWindow {
+			return http2ConnectionError(http2ErrCodeFlowControl)
+		}
+	case http2SettingMaxFrameSize:
+		if s.Val < http2DefaultMaxFrameSize || s.Val > http2MaxFrameSizeLimit {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+	}
+	return nil
+}
+
+// An http2FrameHeader is the 9 byte header of all HTTP/2 frames.
+type http2FrameHeader struct {
+	Type     http2FrameType
+	Flags    uint8
+	Length   uint32 // payload length, not including the header
+	StreamID uint32
+}
+
+func (h http2FrameHeader) has(f uint8) bool { return h.Flags&f == f }
+
+func (h http2FrameHeader) String() string {
+	return fmt.Sprintf("[FrameHeader %v flags=0x%x stream=%d len=%d]", h.Type, h.Flags, h.StreamID, h.Length)
+}
+
+// An http2Frame is a frame read by an http2Framer. Its payload
+// slices are only valid until the next call to ReadFrame.
+type http2Frame struct {
+	http2FrameHeader
+
+	// data holds the DATA payload without padding, or the
+	// complete header block of HEADERS or PUSH_PROMISE (merged
+	// with any CONTINUATION frames).
+	data []byte
+
+	settings   []http2Setting // SETTINGS
+	ping       [8]byte        // PING
+	code       http2ErrCode   // RST_STREAM, GOAWAY
+	lastID     uint32         // GOAWAY
+	incr       uint32         // WINDOW_UPDATE
+	promisedID uint32         // PUSH_PROMISE
+}
+
+// endStream reports whether f is a DATA or HEADERS frame ending its
+// stream.
+func (f *http2Frame) endStream() bool {
+	return (f.Type == http2FrameData || f.Type == http2FrameHeaders) && f.has(http2FlagEndStream)
+}
+
+// An http2Framer reads and writes HTTP/2 frames.
+type http2Framer struct {
+	r         io.Reader
+	hdr       [http2FrameHeaderLen]byte
+	readBuf   []byte
+	headerBuf []byte
+	frame     http2Frame
+
+	// maxReadSize is the largest frame payload we accept, as
+	// advertised in our SETTINGS_MAX_FRAME_SIZE.
+	maxReadSize uint32
+
+	// maxHeaderBlockSize bounds the total size of a header
+	// block accumulated across CONTINUATION frames.
+	maxHeaderBlockSize uint32
+
+	w    io.Writer
+	wbuf []byte
+}
+
+// newHTTP2Framer returns a Framer that writes frames to w and reads
+// them from r.
+func newHTTP2Framer(w io.Writer, r io.Reader) *http2Framer {
+	return &http2Framer{
+		r:                  r,
+		w:                  w,
+		maxReadSize:        http2DefaultMaxFrameSize,
+		maxHeaderBlockSize: 1 << 20,
+	}
+}
+
+func (fr *http2Framer) readFrameHeader() (http2FrameHeader, error) {
+	if _, err := io.ReadFull(fr.r, fr.hdr[:]); err != nil {
+		return http2FrameHeader{}, err
+	}
+	b := fr.hdr[:]
+	return http2FrameHeader{
+		Length:   uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+		Type:     http2FrameType(b[3]),
+		Flags:    b[4],
+		StreamID: binary.BigEndian.Uint32(b[5:]) & (1<<31 - 1),
+	}, nil
+}
+
+func (fr *http2Framer) readPayload(fh http2FrameHeader) ([]byte, error) {
+	if fh.Length > fr.maxReadSize {
+		return nil, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	if uint32(cap(fr.readBuf)) < fh.Length {
+		fr.readBuf = make([]byte, fh.Length)
+	}
+	payload := fr.readBuf[:fh.Length]
+	if _, err := io.ReadFull(fr.r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// ReadFrame reads a single frame. HEADERS frames are returned with
+// their complete header block, reassembled from any CONTINUATION
+// frames which must immediately follow them.
+//
+// Errors of type http2ConnectionError and http2StreamError are
+// protocol violations by the peer; other errors are I/O errors.
+func (fr *http2Framer) ReadFrame() (*http2Frame, error) {
+	fh, err := fr.readFrameHeader()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := fr.readPayload(fh)
+	if err != nil {
+		return nil, err
+	}
+	f := &fr.frame
+	*f = http2Frame{http2FrameHeader: fh}
+	switch fh.Type {
+	case http2FrameData:
+		if fh.StreamID == 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if f.data, err = http2stripPadding(fh, payload); err != nil {
+			return nil, err
+		}
+	case http2FrameHeaders:
+		if fh.StreamID == 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		block, err := http2stripPadding(fh, payload)
+		if err != nil {
+			return nil, err
+		}
+		if fh.has(http2FlagPriority) {
+			if len(block) < 5 {
+				return nil, http2ConnectionError(http2ErrCodeFrameSize)
+			}
+			if binary.BigEndian.Uint32(block)&(1<<31-1) == fh.StreamID {
+				return nil, http2StreamError{fh.StreamID, http2ErrCodeProtocol}
+			}
+			block = block[5:]
+		}
+		if f.data, err = fr.readContinuations(fh, block); err != nil {
+			return nil, err
+		}
+		f.Flags |= http2FlagEndHeaders
+	case http2FramePushPromise:
+		if fh.StreamID == 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		block, err := http2stripPadding(fh, payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(block) < 4 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		f.promisedID = binary.BigEndian.Uint32(block) & (1<<31 - 1)
+		if f.data, err = fr.readContinuations(fh, block[4:]); err != nil {
+			return nil, err
+		}
+		f.Flags |= http2FlagEndHeaders
+	case http2FramePriority:
+		if fh.StreamID == 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if len(payload) != 5 {
+			return nil, http2StreamError{fh.StreamID, http2ErrCodeFrameSize}
+		}
+	case http2FrameRSTStream:
+		if len(payload) != 4 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		if fh.StreamID == 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		f.code = http2ErrCode(binary.BigEndian.Uint32(payload))
+	case http2FrameSettings:
+		if fh.StreamID != 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if fh.has(http2FlagAck) && len(payload) > 0 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		if len(payload)%6 != 0 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		for p := payload; len(p) > 0; p = p[6:] {
+			s := http2Setting{
+				ID:  http2SettingID(binary.BigEndian.Uint16(p)),
+				Val: binary.BigEndian.Uint32(p[2:]),
+			}
+			if err := s.valid(); err != nil {
+				return nil, err
+			}
+			f.settings = append(f.settings, s)
+		}
+	case http2FramePing:
+		if len(payload) != 8 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		if fh.StreamID != 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		copy(f.ping[:], payload)
+	case http2FrameGoAway:
+		if fh.StreamID != 0 {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if len(payload) < 8 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		f.lastID = binary.BigEndian.Uint32(payload) & (1<<31 - 1)
+		f.code = http2ErrCode(binary.BigEndian.Uint32(payload[4:]))
+		f.data = payload[8:]
+	case http2FrameWindowUpdate:
+		if len(payload) != 4 {
+			return nil, http2ConnectionError(http2ErrCodeFrameSize)
+		}
+		f.incr = binary.BigEndian.Uint32(payload) & (1<<31 - 1)
+		if f.incr == 0 {
+			if fh.StreamID == 0 {
+				return nil, http2ConnectionError(http2ErrCodeProtocol)
+			}
+			return nil, http2StreamError{fh.StreamID, http2ErrCodeProtocol}
+		}
+	case http2FrameContinuation:
+		// Only valid immediately after HEADERS or
+		// PUSH_PROMISE, where readContinuations consumes it.
+		return nil, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return f, nil
+}
+
+// readContinuations returns the complete header block starting with
+// first, reading CONTINUATION frames for the stream of fh until one
+// has END_HEADERS set.
+func (fr *http2Framer) readContinuations(fh http2FrameHeader, first []byte) ([]byte, error) {
+	fr.headerBuf = append(fr.headerBuf[:0], first...)
+	for !fh.has(http2FlagEndHeaders) {
+		streamID := fh.StreamID
+		var err error
+		if fh, err = fr.readFrameHeader(); err != nil {
+			return nil, err
+		}
+		if fh.Type != http2FrameContinuation || fh.StreamID != streamID {
+			return nil, http2ConnectionError(http2ErrCodeProtocol)
+		}
+		p, err := fr.readPayload(fh)
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(fr.headerBuf)+len(p)) > fr.maxHeaderBlockSize {
+			return nil, http2ConnectionError(http2ErrCodeEnhanceYourCalm)
+		}
+		fr.headerBuf = append(fr.headerBuf, p...)
+	}
+	return fr.headerBuf, nil
+}
+
+func http2stripPadding(fh http2FrameHeader, payload []byte) ([]byte, error) {
+	if !fh.has(http2FlagPadded) {
+		return payload, nil
+	}
+	if len(payload) < 1 {
+		return nil, http2ConnectionError(http2ErrCodeFrameSize)
+	}
+	padLen := int(payload[0])
+	payload = payload[1:]
+	if padLen > len(payload) {
+		// Padding is as long as or longer than the payload.
+		return nil, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return payload[:len(payload)-padLen], nil
+}
+
+func (fr *http2Framer) startWrite(t http2FrameType, flags uint8, streamID uint32) {
+	fr.wbuf = append(fr.wbuf[:0],
+		0, 0, 0, // length, filled in by endWrite
+		byte(t),
+		flags,
+		byte(streamID>>24),
+		byte(streamID>>16),
+		byte(streamID>>8),
+		byte(streamID))
+}
+
+func (fr *http2Framer) endWrite() error {
+	length := len(fr.wbuf) - http2FrameHeaderLen
+	if length >= 1<<24 {
+		return errors.New("http2: frame too large")
+	}
+	fr.wbuf[0] = byte(length >> 16)
+	fr.wbuf[1] = byte(length >> 8)
+	fr.wbuf[2] = byte(length)
+	_, err := fr.w.Write(fr.wbuf)
+	return err
+}
+
+func (fr *http2Framer) writeUint32(v uint32) {
+	fr.wbuf = append(fr.wbuf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// WriteData writes a DATA frame. The caller is responsible for flow
+// control and for not exceeding the peer's maximum frame size.
+func (fr *http2Framer) WriteData(streamID uint32, endStream bool, data []byte) error {
+	var flags uint8
+	if endStream {
+		flags |= http2FlagEndStream
+	}
+	fr.startWrite(http2FrameData, flags, streamID)
+	fr.wbuf = append(fr.wbuf, data...)
+	return fr.endWrite()
+}
+
+// WriteHeaders writes a header block as a HEADERS frame followed by
+// as many CONTINUATION frames as needed to keep each frame within
+// maxFrameSize.
+func (fr *http2Framer) WriteHeaders(streamID uint32, endStream bool, block []byte, maxFrameSize uint32) error {
+	var flags uint8
+	if endStream {
+		flags |= http2FlagEndStream
+	}
+	return fr.writeHeaderBlock(http2FrameHeaders, flags, streamID, nil, block, maxFrameSize)
+}
+
+// WritePushPromise writes a PUSH_PROMISE frame on streamID reserving
+// promisedID for the request described by block, followed by as many
+// CONTINUATION frames as needed to keep each frame within
+// maxFrameSize.
+func (fr *http2Framer) WritePushPromise(streamID, promisedID uint32, block []byte, maxFrameSize uint32) error {
+	prefix := []byte{byte(promisedID >> 24), byte(promisedID >> 16), byte(promisedID >> 8), byte(promisedID)}
+	return fr.writeHeaderBlock(http2FramePushPromise, 0, streamID, prefix, block, maxFrameSize)
+}
+
+// writeHeaderBlock writes a frame of type t whose payload is prefix
+// and the start of block, followed by CONTINUATION frames holding
+// the rest of block.
+func (fr *http2Framer) writeHeaderBlock(t http2FrameType, flags uint8, streamID uint32, prefix, block []byte, maxFrameSize uint32) error {
+	for first := true; first || len(block) > 0; first = false {
+		frag := block
+		if max := maxFrameSize - uint32(len(prefix)); uint32(len(frag)) > max {
+			frag = frag[:max]
+		}
+		block = block[len(frag):]
+		if len(block) == 0 {
+			flags |= http2FlagEndHeaders
+		}
+		fr.startWrite(t, flags, streamID)
+		fr.wbuf = append(fr.wbuf, prefix...)
+		fr.wbuf = append(fr.wbuf, frag...)
+		if err := fr.endWrite(); err != nil {
+			return err
+		}
+		t, flags, prefix = http2FrameContinuation, 0, nil
+	}
+	return nil
+}
+
+// WriteSettings writes a SETTINGS frame with zero or more settings.
+func (fr *http2Framer) WriteSettings(settings ...http2Setting) error {
+	fr.startWrite(http2FrameSettings, 0, 0)
+	for _, s := range settings {
+		fr.wbuf = append(fr.wbuf, byte(s.ID>>8), byte(s.ID))
+		fr.writeUint32(s.Val)
+	}
+	return fr.endWrite()
+}
+
+// WriteSettingsAck writes an empty SETTINGS frame with the ACK bit set.
+func (fr *http2Framer) WriteSettingsAck() error {
+	fr.startWrite(http2FrameSettings, http2FlagAck, 0)
+	return fr.endWrite()
+}
+
+// WritePing writes a PING frame.
+func (fr *http2Framer) WritePing(ack bool, data [8]byte) error {
+	var flags uint8
+	if ack {
+		flags = http2FlagAck
+	}
+	fr.startWrite(http2FramePing, flags, 0)
+	fr.wbuf = append(fr.wbuf, data[:]...)
+	return fr.endWrite()
+}
+
+// WriteGoAway writes a GOAWAY frame.
+func (fr *http2Framer) WriteGoAway(maxStreamID uint32, code http2ErrCode, debugData []byte) error {
+	fr.startWrite(http2FrameGoAway, 0, 0)
+	fr.writeUint32(maxStreamID & (1<<31 - 1))
+	fr.writeUint32(uint32(code))
+	fr.wbuf = append(fr.wbuf, debugData...)
+	return fr.endWrite()
+}
+
+// WriteWindowUpdate writes a WINDOW_UPDATE frame. The increment
+// must be between 1 and 2,147,483,647, inclusive.
+func (fr *http2Framer) WriteWindowUpdate(streamID, incr uint32) error {
+	if incr < 1 || incr > http2MaxWindow {
+		return errors.New("http2: illegal window increment value")
+	}
+	fr.startWrite(http2FrameWindowUpdate, 0, streamID)
+	fr.writeUint32(incr)
+	return fr.endWrite()
+}
+
+// WriteRSTStream writes a RST_STREAM frame.
+func (fr *http2Framer) WriteRSTStream(streamID uint32, code http2ErrCode) error {
+	fr.startWrite(http2FrameRSTStream, 0, streamID)
+	fr.writeUint32(uint32(code))
+	return fr.endWrite()
+}
+
+// An http2flow is the flow-control window for sending on a stream
+// or connection. A stream's flow is bounded by its connection's.
+type http2flow struct {
+	n    int32      // available window
+	conn *http2flow // connection-level flow, nil for the connection itself
+}
+
+func (f *http2flow) available() int32 {
+	n := f.n
+	if f.conn != nil && f.conn.n < n {
+		n = f.conn.n
+	}
+	return n
+}
+
+func (f *http2flow) take(n int32) {
+	f.n -= n
+	if f.conn != nil {
+		f.conn.n -= n
+	}
+}
+
+// add adds n bytes (positive or negative) to the flow control
+// window. It reports false if the sum would overflow.
+func (f *http2flow) add(n int32) bool {
+	sum := int64(f.n) + int64(n)
+	if sum > http2MaxWindow {
+		return false
+	}
+	f.n = int32(sum)
+	return true
+}
+
+// An http2writeReq is a frame write queued for a connection's writer
+// goroutine.
+type http2writeReq struct {
+	write func(fr *http2Framer) error
+	done  chan error // optional; receives the result of write
+}
+
+// An http2writeQueue serializes all frame writes on a connection
+// through one goroutine, so that readers never block on writes and
+// HPACK state is updated in the same order frames go on the wire.
+type http2writeQueue struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	q      []http2writeReq
+	err    error // sticky; set once a write fails or the queue is closed
+	closed bool
+}
+
+var http2errWriteQueueClosed = errors.New("http2: connection closed")
+
+func (wq *http2writeQueue) init() {
+	wq.cond.L = &wq.mu
+}
+
+// push queues wr. If the queue has already failed, wr.done (if any)
+// receives the error immediately.
+func (wq *http2writeQueue) push(wr http2writeReq) {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+	if wq.err != nil {
+		if wr.done != nil {
+			wr.done <- wq.err
+		}
+		return
+	}
+	wq.q = append(wq.q, wr)
+	wq.cond.Signal()
+}
+
+// writeAndWait queues write and waits for it to complete.
+func (wq *http2writeQueue) writeAndWait(write func(fr *http2Framer) error) error {
+	done := make(chan error, 1)
+	wq.push(http2writeReq{write: write, done: done})
+	return <-done
+}
+
+// close fails any queued and future writes and stops loop.
+func (wq *http2writeQueue) close() {
+	wq.fail(http2errWriteQueueClosed)
+}
+
+func (wq *http2writeQueue) fail(err error) {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+	if wq.err == nil {
+		wq.err = err
+	}
+	for _, wr := range wq.q {
+		if wr.done != nil {
+			wr.done <- wq.err
+		}
+	}
+	wq.q = nil
+	wq.closed = true
+	wq.cond.Broadcast()
+}
+
+// loop runs the queued writes against fr, flushing bw whenever the
+// queue drains. It returns once the queue is closed or a write fails.
+func (wq *http2writeQueue) loop(fr *http2Framer, bw *bufio.Writer) {
+	for {
+		wq.mu.Lock()
+		for len(wq.q) == 0 && !wq.closed {
+			wq.cond.Wait()
+		}
+		if wq.closed {
+			wq.mu.Unlock()
+			return
+		}
+		wr := wq.q[0]
+		copy(wq.q, wq.q[1:])
+		wq.q[len(wq.q)-1] = http2writeReq{}
+		wq.q = wq.q[:len(wq.q)-1]
+		more := len(wq.q) > 0
+		wq.mu.Unlock()
+
+		err := wr.write(fr)
+		if err == nil && !more {
+			err = bw.Flush()
+		}
+		if wr.done != nil {
+			wr.done <- err
+		}
+		if err != nil {
+			wq.fail(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestHTTP2FramerRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	fr := newHTTP2Framer(&buf, &buf)
+	block := bytes.Repeat([]byte("x"), 40)
+
+	writes := []func() error{
+		func() error { return fr.WriteSettings(http2Setting{http2SettingEnablePush, 0}) },
+		func() error { return fr.WriteSettingsAck() },
+		func() error { return fr.WriteData(1, true, []byte("hello")) },
+		// Split into HEADERS plus two CONTINUATION frames.
+		func() error { return fr.WriteHeaders(3, false, block, 16) },
+		func() error { return fr.WritePushPromise(3, 2, block, 16) },
+		func() error { return fr.WritePing(true, [8]byte{1, 2, 3}) },
+		func() error { return fr.WriteWindowUpdate(5, 1000) },
+		func() error { return fr.WriteRSTStream(7, http2ErrCodeCancel) },
+		func() error { return fr.WriteGoAway(9, http2ErrCodeProtocol, nil) },
+	}
+	for i, w := range writes {
+		if err := w(); err != nil {
+			t.Fatalf("write #%d: %v", i, err)
+		}
+	}
+
+	check := func(what string, got, want interface{}) {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v; want %v", what, got, want)
+		}
+	}
+	read := func(typ http2FrameType, streamID uint32) *http2Frame {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("reading %v: %v", typ, err)
+		}
+		if f.Type != typ || f.StreamID != streamID {
+			t.Fatalf("read %v; want %v on stream %d", f.http2FrameHeader, typ, streamID)
+		}
+		return f
+	}
+
+	f := read(http2FrameSettings, 0)
+	check("settings", f.settings, []http2Setting{{http2SettingEnablePush, 0}})
+	f = read(http2FrameSettings, 0)
+	check("settings ack", f.has(http2FlagAck), true)
+	f = read(http2FrameData, 1)
+	check("data", string(f.data), "hello")
+	check("data endStream", f.endStream(), true)
+	f = read(http2FrameHeaders, 3)
+	check("header block", f.data, block)
+	check("headers endStream", f.endStream(), false)
+	f = read(http2FramePushPromise, 3)
+	check("promised stream", f.promisedID, uint32(2))
+	check("promised header block", f.data, block)
+	f = read(http2FramePing, 0)
+	check("ping", f.ping, [8]byte{1, 2, 3})
+	f = read(http2FrameWindowUpdate, 5)
+	check("increment", f.incr, uint32(1000))
+	f = read(http2FrameRSTStream, 7)
+	check("rst code", f.code, http2ErrCodeCancel)
+	f = read(http2FrameGoAway, 0)
+	check("goaway last stream", f.lastID, uint32(9))
+	check("goaway code", f.code, http2ErrCodeProtocol)
+}
+
+func TestHTTP2FramerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		wire []byte
+	}{
+		{"DATA on stream 0", []byte{0, 0, 1, byte(http2FrameData), 0, 0, 0, 0, 0, 'x'}},
+		{"SETTINGS ack with payload", []byte{0, 0, 6, byte(http2FrameSettings), http2FlagAck, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{"PING wrong length", []byte{0, 0, 1, byte(http2FramePing), 0, 0, 0, 0, 0, 0}},
+		{"WINDOW_UPDATE zero increment", []byte{0, 0, 4, byte(http2FrameWindowUpdate), 0, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{"PUSH_PROMISE without promised stream", []byte{0, 0, 2, byte(http2FramePushPromise), http2FlagEndHeaders, 0, 0, 0, 1, 0, 0}},
+		{"oversized frame", []byte{0, 0x40, 1, byte(http2FrameData), 0, 0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		fr := newHTTP2Framer(nil, bytes.NewReader(tt.wire))
+		if _, err := fr.ReadFrame(); err == nil {
+			t.Errorf("%s: ReadFrame succeeded; want error", tt.name)
+		}
+	}
+}
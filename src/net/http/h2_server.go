@@ -0,0 +1,1133 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 server. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/internal/hpack"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// http2MaxConcurrentStreams is the SETTINGS_MAX_CONCURRENT_STREAMS
+	// advertised by the server.
+	http2MaxConcurrentStreams = 250
+
+	// http2MaxHeaderTableSize is the SETTINGS_HEADER_TABLE_SIZE
+	// advertised for decoding.
+	http2MaxHeaderTableSize = 4096
+
+	// http2closeFlushTimeout bounds how long a closing connection
+	// waits for queued frames to be written.
+	http2closeFlushTimeout = 1 * time.Second
+)
+
+var (
+	http2errStreamClosed  = errors.New("http2: stream closed")
+	http2errClientGone    = errors.New("http2: client disconnected")
+	http2errRecursivePush = errors.New("http2: recursive push not allowed")
+	http2errPushLimit     = errors.New("http2: push would exceed the client's SETTINGS_MAX_CONCURRENT_STREAMS")
+)
+
+// http2serverConn is the server side of an HTTP/2 connection. Frames
+// are read by serve's goroutine, each stream's handler runs in its
+// own goroutine, and all writes are serialized through wq.
+type http2serverConn struct {
+	srv        *Server
+	c          *conn // underlying HTTP/1 conn, for state tracking
+	nc         net.Conn
+	remoteAddr string
+	tlsState   *tls.ConnectionState
+	br         io.Reader
+	bw         *bufio.Writer
+	fr         *http2Framer
+	wq         http2writeQueue
+	hdec       *hpack.Decoder // owned by the serve goroutine
+	henc       *hpack.Encoder // owned by the wq.loop goroutine
+	hbuf       bytes.Buffer   // henc's output
+	loopDone   chan struct{}  // closed when wq.loop returns
+
+	mu                sync.Mutex
+	cond              sync.Cond // broadcast when windows grow or streams close
+	streams           map[uint32]*http2stream
+	maxStreamID       uint32 // highest stream ID opened by the client
+	maxPushID         uint32 // highest stream ID opened by a push
+	pushStreams       int    // pushed streams not yet closed
+	outflow           http2flow
+	inflow            int32 // connection receive window
+	peerMaxFrameSize  uint32
+	peerInitialWindow int32
+	peerMaxStreams    uint32 // the client's SETTINGS_MAX_CONCURRENT_STREAMS
+	peerNoPush        bool   // the client set SETTINGS_ENABLE_PUSH to 0
+	sawSettings       bool
+	sentGoAway        bool
+	closed            bool
+}
+
+// http2stream is one server-side stream and its request.
+type http2stream struct {
+	sc      *http2serverConn
+	id      uint32
+	pushed  bool       // opened by the server with PUSH_PROMISE
+	body    *http2pipe // request body; nil if the request had none
+	inflow  int32      // stream receive window
+	outflow http2flow
+
+	// Guarded by sc.mu:
+	gotEndStream  bool  // the client half-closed the stream
+	sentEndStream bool  // the response is complete
+	closed        bool  // reset or removed from sc.streams
+	declBodyBytes int64 // Content-Length of the request, or -1
+	bodyBytes     int64 // request body bytes received
+
+	cn chan bool // CloseNotify channel; receives once if the client goes away
+}
+
+// serveHTTP2 takes over c, which has negotiated HTTP/2 either
+// through TLS or with prior knowledge on a cleartext connection, and
+// serves it until the client goes away.
+func (c *conn) serveHTTP2() {
+	// HTTP/2 connections are long-lived and multiplexed; the
+	// per-request timeouts used by HTTP/1 don't apply.
+	c.rwc.SetReadDeadline(time.Time{})
+	c.rwc.SetWriteDeadline(time.Time{})
+	c.lr.N = noLimit
+
+	sc := &http2serverConn{
+		srv:               c.server,
+		c:                 c,
+		nc:                c.rwc,
+		remoteAddr:        c.remoteAddr,
+		tlsState:          c.tlsState,
+		br:                c.buf.Reader,
+		bw:                bufio.NewWriterSize(c.rwc, 4<<10),
+		loopDone:          make(chan struct{}),
+		streams:           make(map[uint32]*http2stream),
+		inflow:            http2InitialWindowSize,
+		peerMaxFrameSize:  http2DefaultMaxFrameSize,
+		peerInitialWindow: http2InitialWindowSize,
+		peerMaxStreams:    ^uint32(0),
+	}
+	sc.outflow.n = http2InitialWindowSize
+	sc.cond.L = &sc.mu
+	sc.wq.init()
+	sc.fr = newHTTP2Framer(sc.bw, sc.br)
+	sc.fr.maxHeaderBlockSize = uint32(sc.srv.maxHeaderBytes())
+	sc.hdec = hpack.NewDecoder(http2MaxHeaderTableSize)
+	sc.hdec.SetMaxStringLength(sc.srv.maxHeaderBytes())
+	sc.henc = hpack.NewEncoder(&sc.hbuf)
+	sc.serve()
+}
+
+func (sc *http2serverConn) logf(format string, args ...interface{}) {
+	sc.srv.logf(format, args...)
+}
+
+func (sc *http2serverConn) serve() {
+	defer sc.close()
+	go func() {
+		sc.wq.loop(sc.fr, sc.bw)
+		close(sc.loopDone)
+	}()
+
+	sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		return fr.WriteSettings(
+			http2Setting{http2SettingMaxConcurrentStreams, http2MaxConcurrentStreams},
+			http2Setting{http2SettingMaxHeaderListSize, uint32(sc.srv.maxHeaderBytes())},
+		)
+	}})
+
+	preface := make([]byte, len(http2ClientPreface))
+	if _, err := io.ReadFull(sc.br, preface); err != nil {
+		return
+	}
+	if string(preface) != http2ClientPreface {
+		sc.logf("http2: server: client %s sent bogus preface %q", sc.remoteAddr, preface)
+		return
+	}
+
+	for {
+		f, err := sc.fr.ReadFrame()
+		if err == nil {
+			err = sc.processFrame(f)
+		}
+		switch ev := err.(type) {
+		case nil:
+		case http2StreamError:
+			sc.resetStream(ev)
+		case http2ConnectionError:
+			sc.goAway(http2ErrCode(ev))
+			return
+		default:
+			// I/O error; the client is gone.
+			return
+		}
+	}
+}
+
+// close tears down the connection, failing every open stream.
+func (sc *http2serverConn) close() {
+	sc.mu.Lock()
+	sc.closed = true
+	for _, st := range sc.streams {
+		st.closeLocked(http2errClientGone)
+	}
+	sc.cond.Broadcast()
+	sc.mu.Unlock()
+
+	// Give any queued frames (such as GOAWAY) a chance to go
+	// out before closing the connection.
+	done := make(chan error, 1)
+	sc.wq.push(http2writeReq{write: func(*http2Framer) error { return nil }, done: done})
+	select {
+	case <-done:
+	case <-time.After(http2closeFlushTimeout):
+	}
+	sc.wq.close()
+	sc.nc.Close()
+	<-sc.loopDone
+}
+
+// goAway tells the client the connection is going away because of
+// code.
+func (sc *http2serverConn) goAway(code http2ErrCode) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.goAwayLocked(code)
+}
+
+func (sc *http2serverConn) goAwayLocked(code http2ErrCode) {
+	if sc.sentGoAway {
+		return
+	}
+	sc.sentGoAway = true
+	maxID := sc.maxStreamID
+	sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		return fr.WriteGoAway(maxID, code, nil)
+	}})
+}
+
+// resetStream sends a RST_STREAM for se and forgets the stream.
+func (sc *http2serverConn) resetStream(se http2StreamError) {
+	sc.mu.Lock()
+	if st, ok := sc.streams[se.StreamID]; ok {
+		st.closeLocked(se)
+	}
+	sc.mu.Unlock()
+	sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		return fr.WriteRSTStream(se.StreamID, se.Code)
+	}})
+}
+
+func (sc *http2serverConn) processFrame(f *http2Frame) error {
+	if !sc.sawSettings {
+		if f.Type != http2FrameSettings || f.has(http2FlagAck) {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		sc.sawSettings = true
+	}
+	switch f.Type {
+	case http2FrameSettings:
+		return sc.processSettings(f)
+	case http2FrameHeaders:
+		return sc.processHeaders(f)
+	case http2FrameData:
+		return sc.processData(f)
+	case http2FrameWindowUpdate:
+		return sc.processWindowUpdate(f)
+	case http2FramePing:
+		if !f.has(http2FlagAck) {
+			data := f.ping
+			sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+				return fr.WritePing(true, data)
+			}})
+		}
+	case http2FrameRSTStream:
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		if f.StreamID%2 == 1 && f.StreamID > sc.maxStreamID || f.StreamID%2 == 0 && f.StreamID > sc.maxPushID {
+			// The stream is idle.
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		if st, ok := sc.streams[f.StreamID]; ok {
+			st.closeLocked(http2StreamError{f.StreamID, f.code})
+		}
+	case http2FramePushPromise:
+		// Clients can't push.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	case http2FrameGoAway:
+		// The client won't open new streams; existing ones run
+		// to completion and then the client closes the
+		// connection.
+	default:
+		// PRIORITY is advisory and unknown frame types must be
+		// ignored.
+	}
+	return nil
+}
+
+func (sc *http2serverConn) processSettings(f *http2Frame) error {
+	if f.has(http2FlagAck) {
+		return nil
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var tableSize uint32
+	var setTableSize bool
+	for _, s := range f.settings {
+		switch s.ID {
+		case http2SettingHeaderTableSize:
+			tableSize, setTableSize = s.Val, true
+		case http2SettingEnablePush:
+			sc.peerNoPush = s.Val == 0
+		case http2SettingMaxConcurrentStreams:
+			sc.peerMaxStreams = s.Val
+		case http2SettingInitialWindowSize:
+			// Adjust the window of every open stream by the
+			// difference (section 6.9.2).
+			delta := int32(s.Val) - sc.peerInitialWindow
+			for _, st := range sc.streams {
+				if !st.outflow.add(delta) {
+					return http2ConnectionError(http2ErrCodeFlowControl)
+				}
+			}
+			sc.peerInitialWindow = int32(s.Val)
+			sc.cond.Broadcast()
+		case http2SettingMaxFrameSize:
+			sc.peerMaxFrameSize = s.Val
+		}
+	}
+	sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		if setTableSize {
+			sc.henc.SetMaxDynamicTableSizeLimit(tableSize)
+		}
+		return fr.WriteSettingsAck()
+	}})
+	return nil
+}
+
+func (sc *http2serverConn) processWindowUpdate(f *http2Frame) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if f.StreamID == 0 {
+		if !sc.outflow.add(int32(f.incr)) {
+			return http2ConnectionError(http2ErrCodeFlowControl)
+		}
+	} else if st, ok := sc.streams[f.StreamID]; ok {
+		if !st.outflow.add(int32(f.incr)) {
+			return http2StreamError{f.StreamID, http2ErrCodeFlowControl}
+		}
+	}
+	sc.cond.Broadcast()
+	return nil
+}
+
+func (sc *http2serverConn) processData(f *http2Frame) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	n := int32(f.Length)
+	if n > sc.inflow {
+		return http2ConnectionError(http2ErrCodeFlowControl)
+	}
+	sc.inflow -= n
+
+	st, ok := sc.streams[f.StreamID]
+	if !ok || st.gotEndStream || st.body == nil {
+		// Data for a stream we're not reading; return its
+		// connection-level flow control right away.
+		sc.sendWindowUpdateLocked(nil, n)
+		if f.StreamID > sc.maxStreamID {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		return http2StreamError{f.StreamID, http2ErrCodeStreamClosed}
+	}
+	if n > st.inflow {
+		sc.sendWindowUpdateLocked(nil, n)
+		return http2StreamError{f.StreamID, http2ErrCodeFlowControl}
+	}
+	st.inflow -= n
+
+	data := f.data
+	st.bodyBytes += int64(len(data))
+	if st.declBodyBytes != -1 && st.bodyBytes > st.declBodyBytes {
+		sc.sendWindowUpdateLocked(nil, n)
+		return http2StreamError{f.StreamID, http2ErrCodeProtocol}
+	}
+	// Padding is never seen by the handler; credit it back now,
+	// along with anything the handler has stopped reading.
+	refund := n - int32(len(data))
+	if len(data) > 0 {
+		if _, err := st.body.Write(data); err != nil {
+			refund += int32(len(data))
+		}
+	}
+	if refund > 0 {
+		sc.sendWindowUpdateLocked(st, refund)
+	}
+	if f.endStream() {
+		st.gotEndStream = true
+		if st.declBodyBytes != -1 && st.declBodyBytes != st.bodyBytes {
+			st.body.CloseWithError(fmt.Errorf("request declared a Content-Length of %d but only wrote %d bytes",
+				st.declBodyBytes, st.bodyBytes))
+		} else {
+			st.body.CloseWithError(io.EOF)
+		}
+		sc.maybeForgetStreamLocked(st)
+	}
+	return nil
+}
+
+// sendWindowUpdateLocked returns n bytes of receive window to the
+// client, for the connection and, if st is non-nil and still open,
+// for st.
+func (sc *http2serverConn) sendWindowUpdateLocked(st *http2stream, n int32) {
+	if n <= 0 {
+		return
+	}
+	sc.inflow += n
+	var streamID uint32
+	if st != nil && !st.gotEndStream && !st.closed {
+		st.inflow += n
+		streamID = st.id
+	}
+	sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		if streamID != 0 {
+			if err := fr.WriteWindowUpdate(streamID, uint32(n)); err != nil {
+				return err
+			}
+		}
+		return fr.WriteWindowUpdate(0, uint32(n))
+	}})
+}
+
+func (sc *http2serverConn) processHeaders(f *http2Frame) error {
+	id := f.StreamID
+	// Decode even if the stream is doomed, to keep the HPACK
+	// state in sync with the client.
+	hfs, err := sc.hdec.Decode(f.data)
+	if err != nil {
+		return http2ConnectionError(http2ErrCodeCompression)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if st, ok := sc.streams[id]; ok {
+		// Trailers. They must end the stream.
+		if st.gotEndStream {
+			return http2StreamError{id, http2ErrCodeStreamClosed}
+		}
+		if !f.endStream() {
+			return http2StreamError{id, http2ErrCodeProtocol}
+		}
+		st.gotEndStream = true
+		if st.body != nil {
+			st.body.CloseWithError(io.EOF)
+		}
+		sc.maybeForgetStreamLocked(st)
+		return nil
+	}
+	if id%2 != 1 {
+		// Clients may only open odd-numbered streams.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	if id <= sc.maxStreamID {
+		// Streams are opened in increasing order, so this
+		// one has already been closed.
+		return http2StreamError{id, http2ErrCodeStreamClosed}
+	}
+	sc.maxStreamID = id
+	if sc.sentGoAway {
+		return nil
+	}
+	if sc.srv.shuttingDown() {
+		sc.goAwayLocked(http2ErrCodeNo)
+		return http2StreamError{id, http2ErrCodeRefusedStream}
+	}
+	if len(sc.streams) >= http2MaxConcurrentStreams {
+		return http2StreamError{id, http2ErrCodeRefusedStream}
+	}
+
+	st := &http2stream{
+		sc:            sc,
+		id:            id,
+		inflow:        http2InitialWindowSize,
+		gotEndStream:  f.endStream(),
+		declBodyBytes: -1,
+		cn:            make(chan bool, 1),
+	}
+	st.outflow.n = sc.peerInitialWindow
+	st.outflow.conn = &sc.outflow
+
+	req, err := sc.newRequest(st, hfs)
+	if err != nil {
+		return http2StreamError{id, http2ErrCodeProtocol}
+	}
+	if len(sc.streams) == 0 {
+		sc.c.setState(sc.nc, StateActive)
+	}
+	sc.streams[id] = st
+	go sc.runHandler(st, req)
+	return nil
+}
+
+// newRequest builds the *Request for a new stream from its decoded
+// header fields, per section 8.1.2.
+func (sc *http2serverConn) newRequest(st *http2stream, hfs []hpack.HeaderField) (*Request, error) {
+	var method, scheme, authority, path string
+	header := make(Header)
+	var cookies []string
+	sawRegular := false
+	for _, hf := range hfs {
+		if strings.HasPrefix(hf.Name, ":") {
+			if sawRegular {
+				return nil, errors.New("pseudo-header after regular header")
+			}
+			var p *string
+			switch hf.Name {
+			case ":method":
+				p = &method
+			case ":scheme":
+				p = &scheme
+			case ":authority":
+				p = &authority
+			case ":path":
+				p = &path
+			default:
+				return nil, fmt.Errorf("invalid pseudo-header %q", hf.Name)
+			}
+			if *p != "" {
+				return nil, fmt.Errorf("duplicate pseudo-header %q", hf.Name)
+			}
+			*p = hf.Value
+			continue
+		}
+		sawRegular = true
+		if hf.Name != strings.ToLower(hf.Name) || http2connectionHeaders[hf.Name] {
+			return nil, fmt.Errorf("invalid header field %q", hf.Name)
+		}
+		if hf.Name == "te" && hf.Value != "trailers" {
+			return nil, errors.New(`invalid "te" header`)
+		}
+		if hf.Name == "cookie" {
+			// Section 8.1.2.5: cookie fields may be split
+			// and must be concatenated for HTTP/1 handlers.
+			cookies = append(cookies, hf.Value)
+			continue
+		}
+		header.Add(CanonicalHeaderKey(hf.Name), hf.Value)
+	}
+	if len(cookies) > 0 {
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	var (
+		u          *url.URL
+		requestURI string
+		err        error
+	)
+	if method == "CONNECT" {
+		if scheme != "" || path != "" || authority == "" {
+			return nil, errors.New("invalid CONNECT request")
+		}
+		u = &url.URL{Host: authority}
+		requestURI = authority
+	} else {
+		if method == "" || scheme == "" || path == "" {
+			return nil, errors.New("missing pseudo-header")
+		}
+		if u, err = url.ParseRequestURI(path); err != nil {
+			return nil, err
+		}
+		requestURI = path
+	}
+	if authority == "" {
+		authority = header.get("Host")
+	}
+	header.Del("Host")
+
+	req := &Request{
+		Method:     method,
+		URL:        u,
+		RequestURI: requestURI,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		ProtoMinor: 0,
+		Header:     header,
+		Host:       authority,
+		RemoteAddr: sc.remoteAddr,
+		TLS:        sc.tlsState,
+	}
+	if st.gotEndStream {
+		req.Body = eofReader
+		return req, nil
+	}
+	req.ContentLength = -1
+	if cl := header.get("Content-Length"); cl != "" {
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid Content-Length")
+		}
+		req.ContentLength = n
+		st.declBodyBytes = n
+	}
+	st.body = newHTTP2Pipe()
+	req.Body = &http2requestBody{st: st}
+	return req, nil
+}
+
+// http2connectionHeaders are the HTTP/1 connection-specific headers
+// which must not appear in HTTP/2 (section 8.1.2.2).
+var http2connectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+func (sc *http2serverConn) runHandler(st *http2stream, req *Request) {
+	rws := &http2responseWriter{
+		st:            st,
+		req:           req,
+		handlerHeader: make(Header),
+		contentLength: -1,
+	}
+	rws.bw = bufio.NewWriterSize(http2chunkWriter{rws}, bufferBeforeChunkingSize)
+	didPanic := true
+	defer func() {
+		if didPanic {
+			err := recover()
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			sc.logf("http: panic serving %v: %v\n%s", sc.remoteAddr, err, buf)
+			sc.resetStream(http2StreamError{st.id, http2ErrCodeInternal})
+			return
+		}
+		rws.finishRequest()
+	}()
+	serverHandler{sc.srv}.ServeHTTP(rws, req)
+	didPanic = false
+}
+
+// maybeForgetStreamLocked removes st once both sides are done with
+// it. sc.mu must be held.
+func (sc *http2serverConn) maybeForgetStreamLocked(st *http2stream) {
+	if st.gotEndStream && st.sentEndStream {
+		st.closeLocked(nil)
+	}
+}
+
+// closeLocked marks st as closed with err, which is reported to the
+// handler via its request body and response writes, and forgets it.
+// sc.mu must be held.
+func (st *http2stream) closeLocked(err error) {
+	if st.closed {
+		return
+	}
+	st.closed = true
+	sc := st.sc
+	if st.body != nil {
+		if err == nil {
+			err = http2errStreamClosed
+		}
+		// Buffered body data will never be read; return it
+		// to the connection's window.
+		if n := st.body.breakWithError(err); n > 0 && !sc.closed {
+			sc.sendWindowUpdateLocked(nil, int32(n))
+		}
+	}
+	if err != nil {
+		select {
+		case st.cn <- true:
+		default:
+		}
+	}
+	delete(sc.streams, st.id)
+	if st.pushed {
+		sc.pushStreams--
+	}
+	if len(sc.streams) == 0 && !sc.closed {
+		sc.c.setState(sc.nc, StateIdle)
+	}
+	sc.cond.Broadcast()
+}
+
+// writeHeaders sends the response header block for st.
+func (sc *http2serverConn) writeHeaders(st *http2stream, status int, h Header, endStream bool) error {
+	sc.mu.Lock()
+	if st.closed {
+		sc.mu.Unlock()
+		return http2errStreamClosed
+	}
+	if endStream {
+		st.sentEndStream = true
+	}
+	maxFrameSize := sc.peerMaxFrameSize
+	sc.mu.Unlock()
+
+	err := sc.wq.writeAndWait(func(fr *http2Framer) error {
+		sc.hbuf.Reset()
+		sc.henc.WriteField(hpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
+		http2encodeHeaders(sc.henc, h)
+		return fr.WriteHeaders(st.id, endStream, sc.hbuf.Bytes(), maxFrameSize)
+	})
+	if endStream {
+		sc.mu.Lock()
+		sc.maybeForgetStreamLocked(st)
+		sc.mu.Unlock()
+	}
+	return err
+}
+
+// http2encodeHeaders writes the fields of h to enc, lowercasing
+// names and dropping connection-specific headers.
+func http2encodeHeaders(enc *hpack.Encoder, h Header) {
+	for k, vv := range h {
+		k = strings.ToLower(k)
+		if http2connectionHeaders[k] {
+			continue
+		}
+		for _, v := range vv {
+			enc.WriteField(hpack.HeaderField{Name: k, Value: v})
+		}
+	}
+}
+
+// writeData sends p on st as one or more DATA frames, blocking for
+// flow control as needed.
+func (sc *http2serverConn) writeData(st *http2stream, p []byte, endStream bool) error {
+	for first := true; first || len(p) > 0; first = false {
+		sc.mu.Lock()
+		for len(p) > 0 && st.outflow.available() <= 0 && !st.closed {
+			sc.cond.Wait()
+		}
+		if st.closed {
+			sc.mu.Unlock()
+			return http2errStreamClosed
+		}
+		n := len(p)
+		if avail := int(st.outflow.available()); n > avail {
+			n = avail
+		}
+		if max := int(sc.peerMaxFrameSize); n > max {
+			n = max
+		}
+		st.outflow.take(int32(n))
+		chunk := p[:n]
+		p = p[n:]
+		end := endStream && len(p) == 0
+		if end {
+			st.sentEndStream = true
+		}
+		sc.mu.Unlock()
+
+		err := sc.wq.writeAndWait(func(fr *http2Framer) error {
+			return fr.WriteData(st.id, end, chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if end {
+			sc.mu.Lock()
+			sc.maybeForgetStreamLocked(st)
+			sc.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// noteBodyRead is called after the handler reads n bytes of st's
+// request body, to return that much window to the client.
+func (sc *http2serverConn) noteBodyRead(st *http2stream, n int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !sc.closed {
+		sc.sendWindowUpdateLocked(st, int32(n))
+	}
+}
+
+// http2requestBody is the Request.Body of an HTTP/2 request.
+type http2requestBody struct {
+	st     *http2stream
+	closed bool
+}
+
+func (b *http2requestBody) Read(p []byte) (n int, err error) {
+	if b.closed {
+		return 0, errors.New("http: invalid Read on closed Body")
+	}
+	n, err = b.st.body.Read(p)
+	if n > 0 {
+		b.st.sc.noteBodyRead(b.st, n)
+	}
+	return
+}
+
+func (b *http2requestBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		sc := b.st.sc
+		sc.mu.Lock()
+		if n := b.st.body.breakWithError(errors.New("http: invalid Read on closed Body")); n > 0 && !sc.closed {
+			sc.sendWindowUpdateLocked(nil, int32(n))
+		}
+		sc.mu.Unlock()
+	}
+	return nil
+}
+
+// http2responseWriter is the ResponseWriter for HTTP/2 requests.
+type http2responseWriter struct {
+	st            *http2stream
+	req           *Request
+	bw            *bufio.Writer // buffers writes to http2chunkWriter
+	handlerHeader Header
+	snapHeader    Header // handlerHeader at WriteHeader time
+	status        int
+	wroteHeader   bool  // WriteHeader called
+	sentHeader    bool  // HEADERS frame sent
+	handlerDone   bool  // the handler has returned
+	contentLength int64 // declared Content-Length, or -1
+	written       int64 // body bytes written by the handler
+}
+
+var (
+	_ CloseNotifier = (*http2responseWriter)(nil)
+	_ Flusher       = (*http2responseWriter)(nil)
+	_ Pusher        = (*http2responseWriter)(nil)
+)
+
+func (w *http2responseWriter) Header() Header {
+	return w.handlerHeader
+}
+
+func (w *http2responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		w.st.sc.logf("http: multiple response.WriteHeader calls")
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	w.snapHeader = w.handlerHeader.clone()
+	if cl := w.snapHeader.get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err == nil && v >= 0 {
+			w.contentLength = v
+		} else {
+			w.st.sc.logf("http: invalid Content-Length of %q", cl)
+			w.snapHeader.Del("Content-Length")
+		}
+	}
+}
+
+func (w *http2responseWriter) Write(p []byte) (n int, err error) {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	w.written += int64(len(p))
+	if w.contentLength != -1 && w.written > w.contentLength {
+		return 0, ErrContentLength
+	}
+	return w.bw.Write(p)
+}
+
+func (w *http2responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	w.bw.Flush()
+	if !w.sentHeader {
+		w.writeChunk(nil)
+	}
+}
+
+func (w *http2responseWriter) CloseNotify() <-chan bool {
+	return w.st.cn
+}
+
+// http2noPushHeaders are the request headers, other than the
+// connection-specific ones, that a promised request may not carry:
+// a promised request has no body and its host is its :authority.
+var http2noPushHeaders = map[string]bool{
+	"content-encoding": true,
+	"content-length":   true,
+	"content-type":     true,
+	"expect":           true,
+	"host":             true,
+	"te":               true,
+	"trailer":          true,
+}
+
+// Push implements Pusher. It sends a PUSH_PROMISE on w's stream for
+// a new server-initiated stream and runs the handler for the promised
+// request on that stream.
+func (w *http2responseWriter) Push(target string, opts *PushOptions) error {
+	st := w.st
+	sc := st.sc
+	if st.pushed {
+		return http2errRecursivePush
+	}
+	if opts == nil {
+		opts = new(PushOptions)
+	}
+	method := opts.Method
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" && method != "HEAD" {
+		return fmt.Errorf("http2: method %q cannot be pushed", method)
+	}
+	scheme := "http"
+	if w.req.TLS != nil {
+		scheme = "https"
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" {
+		if !strings.HasPrefix(target, "/") {
+			return fmt.Errorf("http2: push target %q is not an absolute path or URL", target)
+		}
+		u.Scheme, u.Host = scheme, w.req.Host
+	}
+	if u.Scheme != scheme || u.Host != w.req.Host {
+		return fmt.Errorf("http2: push target %q is not on %s://%s", target, scheme, w.req.Host)
+	}
+	for k := range opts.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(k, ":") || http2connectionHeaders[lk] || http2noPushHeaders[lk] {
+			return fmt.Errorf("http2: promised request headers cannot include %q", k)
+		}
+	}
+	path := u.RequestURI()
+	ru, err := url.ParseRequestURI(path)
+	if err != nil {
+		return err
+	}
+	req := &Request{
+		Method:     method,
+		URL:        ru,
+		RequestURI: path,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		ProtoMinor: 0,
+		Header:     opts.Header.clone(),
+		Host:       u.Host,
+		RemoteAddr: sc.remoteAddr,
+		TLS:        sc.tlsState,
+		Body:       eofReader,
+	}
+
+	sc.mu.Lock()
+	if !sc.srv.EnableH2Push || sc.peerNoPush {
+		sc.mu.Unlock()
+		return ErrNotSupported
+	}
+	if sc.closed || sc.sentGoAway || sc.srv.shuttingDown() || st.closed || st.sentEndStream {
+		sc.mu.Unlock()
+		return http2errStreamClosed
+	}
+	if uint32(sc.pushStreams) >= sc.peerMaxStreams || sc.maxPushID >= 1<<31-2 {
+		sc.mu.Unlock()
+		return http2errPushLimit
+	}
+	sc.maxPushID += 2
+	pst := &http2stream{
+		sc:     sc,
+		id:     sc.maxPushID,
+		pushed: true,
+		inflow: http2InitialWindowSize,
+		// The client sends nothing on a pushed stream.
+		gotEndStream:  true,
+		declBodyBytes: -1,
+		cn:            make(chan bool, 1),
+	}
+	pst.outflow.n = sc.peerInitialWindow
+	pst.outflow.conn = &sc.outflow
+	sc.streams[pst.id] = pst
+	sc.pushStreams++
+	maxFrameSize := sc.peerMaxFrameSize
+	sc.mu.Unlock()
+
+	err = sc.wq.writeAndWait(func(fr *http2Framer) error {
+		sc.hbuf.Reset()
+		sc.henc.WriteField(hpack.HeaderField{Name: ":method", Value: method})
+		sc.henc.WriteField(hpack.HeaderField{Name: ":scheme", Value: scheme})
+		sc.henc.WriteField(hpack.HeaderField{Name: ":authority", Value: u.Host})
+		sc.henc.WriteField(hpack.HeaderField{Name: ":path", Value: path})
+		http2encodeHeaders(sc.henc, req.Header)
+		return fr.WritePushPromise(st.id, pst.id, sc.hbuf.Bytes(), maxFrameSize)
+	})
+	if err != nil {
+		sc.mu.Lock()
+		pst.closeLocked(err)
+		sc.mu.Unlock()
+		return err
+	}
+	go sc.runHandler(pst, req)
+	return nil
+}
+
+func (w *http2responseWriter) finishRequest() {
+	if !w.wroteHeader {
+		w.WriteHeader(StatusOK)
+	}
+	w.handlerDone = true
+	w.bw.Flush()
+	sc := w.st.sc
+	sc.mu.Lock()
+	done := w.st.sentEndStream || w.st.closed
+	sc.mu.Unlock()
+	if !done {
+		w.writeChunk(nil)
+	}
+	if w.req.MultipartForm != nil {
+		w.req.MultipartForm.RemoveAll()
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !w.st.closed && !w.st.gotEndStream {
+		// The response is complete but the client is still
+		// sending a body we no longer need (section 8.1).
+		w.st.closeLocked(nil)
+		id := w.st.id
+		sc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+			return fr.WriteRSTStream(id, http2ErrCodeNo)
+		}})
+	}
+}
+
+// writeChunk writes the response headers if they haven't been sent,
+// followed by p as DATA. Once the handler is done, the stream is
+// ended with the last chunk.
+func (w *http2responseWriter) writeChunk(p []byte) (n int, err error) {
+	sc := w.st.sc
+	isHEAD := w.req.Method == "HEAD"
+	if !w.sentHeader {
+		w.sentHeader = true
+		h := w.snapHeader
+		if bodyAllowedForStatus(w.status) && h.get("Content-Type") == "" && len(p) > 0 {
+			h.Set("Content-Type", DetectContentType(p))
+		}
+		if w.handlerDone && bodyAllowedForStatus(w.status) && h.get("Content-Length") == "" && (!isHEAD || len(p) > 0) {
+			h.Set("Content-Length", strconv.Itoa(len(p)))
+		}
+		if h.get("Date") == "" {
+			h.Set("Date", time.Now().UTC().Format(TimeFormat))
+		}
+		endStream := w.handlerDone && (len(p) == 0 || isHEAD)
+		if err := sc.writeHeaders(w.st, w.status, h, endStream); err != nil {
+			return 0, err
+		}
+		if endStream {
+			return len(p), nil
+		}
+	}
+	if isHEAD {
+		p = nil
+		if !w.handlerDone {
+			return len(p), nil
+		}
+	}
+	if err := sc.writeData(w.st, p, w.handlerDone); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// http2chunkWriter is the destination of an http2responseWriter's
+// buffered writes.
+type http2chunkWriter struct{ w *http2responseWriter }
+
+func (cw http2chunkWriter) Write(p []byte) (n int, err error) {
+	return cw.w.writeChunk(p)
+}
+
+// An http2pipe is a buffered, goroutine-safe reader of data written
+// by a connection's read loop.
+type http2pipe struct {
+	mu  sync.Mutex
+	c   sync.Cond
+	b   bytes.Buffer
+	err error // returned by Read once b is drained
+}
+
+func newHTTP2Pipe() *http2pipe {
+	p := new(http2pipe)
+	p.c.L = &p.mu
+	return p
+}
+
+func (p *http2pipe) Read(d []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.b.Len() == 0 && p.err == nil {
+		p.c.Wait()
+	}
+	if p.b.Len() > 0 {
+		return p.b.Read(d)
+	}
+	return 0, p.err
+}
+
+var http2errPipeClosed = errors.New("http2: write to closed pipe")
+
+// Write buffers d for reading. It fails if the pipe has been closed.
+func (p *http2pipe) Write(d []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return 0, http2errPipeClosed
+	}
+	p.c.Signal()
+	return p.b.Write(d)
+}
+
+// CloseWithError causes Reads to return err once the buffered data
+// has been read.
+func (p *http2pipe) CloseWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+		p.c.Broadcast()
+	}
+}
+
+// breakWithError discards any buffered data, causes Reads to return
+// err immediately, and returns the number of bytes discarded.
+func (p *http2pipe) breakWithError(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.b.Len()
+	p.b.Reset()
+	if p.err == nil || p.err == io.EOF {
+		p.err = err
+	}
+	p.c.Broadcast()
+	return n
+}
@@ -0,0 +1,134 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http/internal/hpack"
+	"testing"
+	"time"
+)
+
+// TestHTTP2ServerPush talks to the server with a bare framer, since
+// the Transport never accepts pushed streams.
+func TestHTTP2ServerPush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	pushed := make(chan *Request, 1)
+	srv := &Server{
+		EnableH2C:    true,
+		EnableH2Push: true,
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			if r.URL.Path == "/style.css" {
+				pushed <- r
+				io.WriteString(w, "body{}")
+				return
+			}
+			err := w.(Pusher).Push("/style.css", &PushOptions{Header: Header{"Accept": {"text/css"}}})
+			if err != nil {
+				t.Errorf("Push = %v", err)
+			}
+			if err := w.(Pusher).Push("/other", &PushOptions{Method: "POST"}); err == nil {
+				t.Error("Push with method POST succeeded; want error")
+			}
+			if err := w.(Pusher).Push("http://elsewhere.example/x", nil); err == nil {
+				t.Error("Push to another host succeeded; want error")
+			}
+			io.WriteString(w, "<html>")
+		}),
+	}
+	go srv.Serve(ln)
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	fr := newHTTP2Framer(c, bufio.NewReader(c))
+	if _, err := io.WriteString(c, http2ClientPreface); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for _, hf := range []hpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/"},
+	} {
+		enc.WriteField(hf)
+	}
+	if err := fr.WriteHeaders(1, true, block.Bytes(), http2DefaultMaxFrameSize); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := hpack.NewDecoder(http2MaxHeaderTableSize)
+	var promise []hpack.HeaderField
+	bodies := map[uint32]string{}
+	ended := map[uint32]bool{}
+	for !ended[1] || !ended[2] {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch f.Type {
+		case http2FramePushPromise:
+			if f.StreamID != 1 || f.promisedID != 2 {
+				t.Fatalf("PUSH_PROMISE on stream %d for stream %d; want stream 2 promised on stream 1", f.StreamID, f.promisedID)
+			}
+			if bodies[1] != "" {
+				t.Error("PUSH_PROMISE sent after the response body that refers to it")
+			}
+			if promise, err = dec.Decode(f.data); err != nil {
+				t.Fatal(err)
+			}
+		case http2FrameHeaders:
+			if _, err := dec.Decode(f.data); err != nil {
+				t.Fatal(err)
+			}
+		case http2FrameData:
+			bodies[f.StreamID] += string(f.data)
+		case http2FrameRSTStream, http2FrameGoAway:
+			t.Fatalf("unexpected %v", f.http2FrameHeader)
+		}
+		if f.endStream() {
+			ended[f.StreamID] = true
+		}
+	}
+
+	want := []hpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/style.css"},
+		{Name: "accept", Value: "text/css"},
+	}
+	if len(promise) != len(want) {
+		t.Fatalf("promised request = %v; want %v", promise, want)
+	}
+	for i := range want {
+		if promise[i].Name != want[i].Name || promise[i].Value != want[i].Value {
+			t.Errorf("promised request = %v; want %v", promise, want)
+			break
+		}
+	}
+	if bodies[1] != "<html>" || bodies[2] != "body{}" {
+		t.Errorf("bodies = %q; want <html> on stream 1 and body{} on stream 2", bodies)
+	}
+	r := <-pushed
+	if r.Method != "GET" || r.Host != "example.com" || r.Header.Get("Accept") != "text/css" {
+		t.Errorf("pushed request = %s %s, Host %q, Accept %q", r.Method, r.URL, r.Host, r.Header.Get("Accept"))
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// End-to-end HTTP/2 tests, run over cleartext connections with
+// prior knowledge (Server.EnableH2C and Transport.EnableH2C).
+
+package http_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newH2CServer returns a started cleartext HTTP/2 test server and a
+// Transport that talks to it.
+func newH2CServer(h Handler) (*httptest.Server, *Transport) {
+	ts := httptest.NewUnstartedServer(h)
+	ts.Config.EnableH2C = true
+	ts.Start()
+	return ts, &Transport{EnableH2C: true}
+}
+
+func TestH2Get(t *testing.T) {
+	defer afterTest(t)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("request proto = %q; want HTTP/2.0", r.Proto)
+		}
+		w.Header().Set("X-Foo", "bar")
+		fmt.Fprintf(w, "path=%s host=%s", r.URL.Path, r.Host)
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	for i := 0; i < 3; i++ {
+		res, err := c.Get(ts.URL + "/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != 200 || res.Status != "200 OK" || res.ProtoMajor != 2 {
+			t.Errorf("got %q %q; want 200 OK HTTP/2.0", res.Status, res.Proto)
+		}
+		if g := res.Header.Get("X-Foo"); g != "bar" {
+			t.Errorf("X-Foo = %q; want bar", g)
+		}
+		want := "path=/foo host=" + strings.TrimPrefix(ts.URL, "http://")
+		if string(body) != want {
+			t.Errorf("body = %q; want %q", body, want)
+		}
+		if res.ContentLength != int64(len(want)) {
+			t.Errorf("ContentLength = %d; want %d", res.ContentLength, len(want))
+		}
+	}
+}
+
+func TestH2PostEcho(t *testing.T) {
+	defer afterTest(t)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	// Larger than the initial flow control windows, so both
+	// directions have to wait for WINDOW_UPDATEs.
+	want := bytes.Repeat([]byte("abcdefghij"), 100<<10)
+	res, err := c.Post(ts.URL, "application/octet-stream", bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	got, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("echoed %d bytes; want %d", len(got), len(want))
+	}
+}
+
+func TestH2ConcurrentRequests(t *testing.T) {
+	defer afterTest(t)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.URL.Query().Get("n"))
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := c.Get(fmt.Sprintf("%s/?n=%d", ts.URL, i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want := fmt.Sprint(i); string(body) != want {
+				t.Errorf("body = %q; want %q", body, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestH2HeadAndTrailers(t *testing.T) {
+	defer afterTest(t)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Length", "5")
+		io.WriteString(w, "hello")
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	res, err := c.Head(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if len(body) != 0 {
+		t.Errorf("HEAD body = %q; want empty", body)
+	}
+	if res.ContentLength != 5 {
+		t.Errorf("HEAD ContentLength = %d; want 5", res.ContentLength)
+	}
+}
+
+func TestH2CancelResponseBody(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan bool)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "first")
+		w.(Flusher).Flush()
+		<-unblock
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	defer close(unblock)
+	c := &Client{Transport: tr}
+
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(res.Body, buf); err != nil || string(buf) != "first" {
+		t.Fatalf("read %q, %v; want first", buf, err)
+	}
+	// Closing the body early resets the stream; the connection
+	// stays usable.
+	res.Body.Close()
+	unblock <- true
+
+	res, err = c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestH2ServerShutdown(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	release := make(chan bool)
+	ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			inHandler <- true
+			<-release
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	resc := make(chan string, 1)
+	go func() {
+		res, err := c.Get(ts.URL + "/slow")
+		if err != nil {
+			resc <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		resc <- string(body)
+	}()
+	<-inHandler
+
+	shutdownc := make(chan error, 1)
+	go func() { shutdownc <- ts.Config.Shutdown(time.Time{}) }()
+	select {
+	case err := <-shutdownc:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if got := <-resc; got != "ok" {
+		t.Errorf("in-flight request got %q; want ok", got)
+	}
+	if err := <-shutdownc; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+func TestH2PushNotSupported(t *testing.T) {
+	defer afterTest(t)
+	for _, enable := range []bool{false, true} {
+		ts, tr := newH2CServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+			p, ok := w.(Pusher)
+			if !ok {
+				t.Errorf("HTTP/2 ResponseWriter %T is not a Pusher", w)
+				return
+			}
+			// Push is off in the server unless enabled, and
+			// the Transport always disables it.
+			if err := p.Push("/style.css", nil); err != ErrNotSupported {
+				t.Errorf("EnableH2Push=%v: Push = %v; want ErrNotSupported", enable, err)
+			}
+		}))
+		ts.Config.EnableH2Push = enable
+		res, err := (&Client{Transport: tr}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		tr.CloseIdleConnections()
+		ts.Close()
+	}
+}
@@ -0,0 +1,797 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 client connections for Transport. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http/internal/hpack"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// http2errClientConnUnusable is returned by roundTrip when the
+	// connection can't take new streams; the request is safe to
+	// retry on another connection.
+	http2errClientConnUnusable = errors.New("http2: client conn not usable")
+
+	http2errClientConnLost  = errors.New("http2: client connection lost")
+	http2errServerGoAway    = errors.New("http2: server sent GOAWAY and closed the connection")
+	http2errRequestCanceled = errors.New("net/http: request canceled")
+)
+
+// http2clientConn is the client side of an HTTP/2 connection, shared
+// by all requests to the same connectMethodKey. Frames are read by
+// readLoop and all writes are serialized through wq.
+type http2clientConn struct {
+	t          *Transport
+	key        connectMethodKey
+	nc         net.Conn
+	tlsState   *tls.ConnectionState
+	bw         *bufio.Writer
+	fr         *http2Framer
+	wq         http2writeQueue
+	henc       *hpack.Encoder // owned by the wq.loop goroutine
+	hbuf       bytes.Buffer   // henc's output
+	hdec       *hpack.Decoder // owned by readLoop
+	readerDone chan struct{}  // closed when readLoop returns
+
+	mu                   sync.Mutex
+	cond                 sync.Cond // broadcast when windows grow or streams close
+	streams              map[uint32]*http2clientStream
+	nextStreamID         uint32
+	numStreams           int // streams open or about to be
+	maxConcurrentStreams uint32
+	peerMaxFrameSize     uint32
+	peerInitialWindow    int32
+	outflow              http2flow
+	inflow               int32
+	sawSettings          bool
+	goAway               bool // no new streams; close once the last finishes
+	singleUse            bool // not pooled; used only by the request that dialed it
+	used                 bool // a stream has been started
+	closed               bool
+}
+
+// http2clientStream is one request/response exchange on an
+// http2clientConn.
+type http2clientStream struct {
+	cc            *http2clientConn
+	req           *Request
+	id            uint32
+	resc          chan responseAndError // receives the response headers or an error
+	body          *http2pipe            // response body
+	inflow        int32                 // stream receive window
+	outflow       http2flow
+	requestedGzip bool
+
+	// Guarded by cc.mu:
+	resp          *Response // non-nil once response headers arrive
+	sentEndStream bool
+	gotEndStream  bool
+	closed        bool
+}
+
+// newHTTP2ClientConn starts an HTTP/2 connection over nc, which has
+// either negotiated "h2" over TLS or is a cleartext connection to a
+// server known to support HTTP/2.
+func (t *Transport) newHTTP2ClientConn(key connectMethodKey, nc net.Conn, tlsState *tls.ConnectionState) *http2clientConn {
+	cc := &http2clientConn{
+		t:                    t,
+		key:                  key,
+		nc:                   nc,
+		tlsState:             tlsState,
+		bw:                   bufio.NewWriter(nc),
+		readerDone:           make(chan struct{}),
+		streams:              make(map[uint32]*http2clientStream),
+		nextStreamID:         1,
+		maxConcurrentStreams: 1000, // effectively unlimited until the server's SETTINGS arrive
+		peerMaxFrameSize:     http2DefaultMaxFrameSize,
+		peerInitialWindow:    http2InitialWindowSize,
+		inflow:               http2InitialWindowSize,
+	}
+	cc.outflow.n = http2InitialWindowSize
+	cc.cond.L = &cc.mu
+	cc.wq.init()
+	cc.fr = newHTTP2Framer(cc.bw, bufio.NewReader(nc))
+	cc.henc = hpack.NewEncoder(&cc.hbuf)
+	cc.hdec = hpack.NewDecoder(http2MaxHeaderTableSize)
+
+	cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		if _, err := cc.bw.WriteString(http2ClientPreface); err != nil {
+			return err
+		}
+		// We never want server push.
+		return fr.WriteSettings(http2Setting{http2SettingEnablePush, 0})
+	}})
+	go cc.wq.loop(cc.fr, cc.bw)
+	go cc.readLoop()
+	return cc
+}
+
+// getHTTP2Conn returns the cached HTTP/2 connection for key, if any.
+func (t *Transport) getHTTP2Conn(key connectMethodKey) *http2clientConn {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	return t.h2conns[key]
+}
+
+// addHTTP2Conn caches cc for reuse. If another connection to the
+// same destination is already cached, cc is only used for the
+// request that dialed it.
+func (t *Transport) addHTTP2Conn(cc *http2clientConn) {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	if _, ok := t.h2conns[cc.key]; ok || t.DisableKeepAlives {
+		cc.mu.Lock()
+		cc.singleUse = true
+		cc.mu.Unlock()
+		return
+	}
+	if t.h2conns == nil {
+		t.h2conns = make(map[connectMethodKey]*http2clientConn)
+	}
+	t.h2conns[cc.key] = cc
+}
+
+func (t *Transport) removeHTTP2Conn(cc *http2clientConn) {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	if t.h2conns[cc.key] == cc {
+		delete(t.h2conns, cc.key)
+	}
+}
+
+// closeIdleHTTP2Conns closes the cached HTTP/2 connections with no
+// streams in flight.
+func (t *Transport) closeIdleHTTP2Conns() {
+	t.h2mu.Lock()
+	var idle []*http2clientConn
+	for key, cc := range t.h2conns {
+		cc.mu.Lock()
+		if cc.numStreams == 0 {
+			idle = append(idle, cc)
+			delete(t.h2conns, key)
+		}
+		cc.mu.Unlock()
+	}
+	t.h2mu.Unlock()
+	for _, cc := range idle {
+		cc.closeWithError(nil)
+	}
+}
+
+func (cc *http2clientConn) roundTrip(treq *transportRequest) (*Response, error) {
+	req := treq.Request
+	cc.mu.Lock()
+	for !cc.closed && !cc.goAway && uint32(cc.numStreams) >= cc.maxConcurrentStreams {
+		cc.cond.Wait()
+	}
+	if cc.closed || cc.goAway || cc.singleUse && cc.used {
+		cc.mu.Unlock()
+		return nil, http2errClientConnUnusable
+	}
+	cc.used = true
+	cc.numStreams++
+	cc.mu.Unlock()
+
+	hasBody := req.Body != nil
+	cs := &http2clientStream{
+		cc:     cc,
+		req:    req,
+		resc:   make(chan responseAndError, 1),
+		body:   newHTTP2Pipe(),
+		inflow: http2InitialWindowSize,
+	}
+	// Ask for gzip under the same conditions as HTTP/1; see
+	// persistConn.roundTrip.
+	if !cc.t.DisableCompression &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		req.Method != "HEAD" {
+		cs.requestedGzip = true
+	}
+
+	err := cc.wq.writeAndWait(func(fr *http2Framer) error {
+		// Stream IDs must be used in increasing order on the
+		// wire, so they're assigned here in the writer.
+		cc.mu.Lock()
+		if cc.closed {
+			cc.mu.Unlock()
+			return http2errClientConnLost
+		}
+		cs.id = cc.nextStreamID
+		cc.nextStreamID += 2
+		cs.outflow.n = cc.peerInitialWindow
+		cs.outflow.conn = &cc.outflow
+		cs.sentEndStream = !hasBody
+		cc.streams[cs.id] = cs
+		maxFrameSize := cc.peerMaxFrameSize
+		cc.mu.Unlock()
+
+		cc.hbuf.Reset()
+		cc.encodeHeaders(treq, cs.requestedGzip)
+		return fr.WriteHeaders(cs.id, !hasBody, cc.hbuf.Bytes(), maxFrameSize)
+	})
+	if err != nil {
+		cc.mu.Lock()
+		if cs.id == 0 {
+			cc.numStreams--
+			cc.cond.Broadcast()
+		} else {
+			cs.abortLocked(err)
+		}
+		cc.mu.Unlock()
+		req.closeBody()
+		return nil, err
+	}
+
+	cc.t.setReqCanceler(req, func() { cs.cancel(http2errRequestCanceled) })
+	if hasBody {
+		go cs.writeBody()
+	}
+	re := <-cs.resc
+	if re.err != nil {
+		cc.t.setReqCanceler(req, nil)
+	}
+	return re.res, re.err
+}
+
+// encodeHeaders writes the header block for treq to cc.henc.
+func (cc *http2clientConn) encodeHeaders(treq *transportRequest, addGzip bool) {
+	req := treq.Request
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	enc := cc.henc
+	enc.WriteField(hpack.HeaderField{Name: ":authority", Value: host})
+	enc.WriteField(hpack.HeaderField{Name: ":method", Value: method})
+	if method != "CONNECT" {
+		enc.WriteField(hpack.HeaderField{Name: ":path", Value: req.URL.RequestURI()})
+		enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: req.URL.Scheme})
+	}
+
+	h := make(Header, len(req.Header)+4)
+	for k, vv := range req.Header {
+		h[k] = vv
+	}
+	for k, vv := range treq.extra {
+		h[k] = vv
+	}
+	h.Del("Host")
+	h.Del("Content-Length")
+	if _, ok := h["User-Agent"]; !ok {
+		h.Set("User-Agent", defaultUserAgent)
+	} else if h.get("User-Agent") == "" {
+		h.Del("User-Agent")
+	}
+	if req.ContentLength > 0 {
+		h.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	}
+	if addGzip {
+		h.Set("Accept-Encoding", "gzip")
+	}
+	http2encodeHeaders(enc, h)
+}
+
+// writeBody sends the request body and ends the stream.
+func (cs *http2clientStream) writeBody() {
+	cc := cs.cc
+	body := cs.req.Body
+	buf := make([]byte, http2DefaultMaxFrameSize)
+	var err error
+	for err == nil {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			err = cc.writeData(cs, buf[:n], false)
+		}
+		if err != nil {
+			break
+		}
+		if rerr == io.EOF {
+			err = cc.writeData(cs, nil, true)
+			break
+		}
+		err = rerr
+	}
+	body.Close()
+	if err != nil && err != http2errStreamClosed {
+		cs.cancel(err)
+	}
+}
+
+// writeData sends p on cs as DATA frames, blocking for flow control
+// as needed.
+func (cc *http2clientConn) writeData(cs *http2clientStream, p []byte, endStream bool) error {
+	for first := true; first || len(p) > 0; first = false {
+		cc.mu.Lock()
+		for len(p) > 0 && cs.outflow.available() <= 0 && !cs.closed {
+			cc.cond.Wait()
+		}
+		if cs.closed {
+			cc.mu.Unlock()
+			return http2errStreamClosed
+		}
+		n := len(p)
+		if avail := int(cs.outflow.available()); n > avail {
+			n = avail
+		}
+		if max := int(cc.peerMaxFrameSize); n > max {
+			n = max
+		}
+		cs.outflow.take(int32(n))
+		chunk := p[:n]
+		p = p[n:]
+		end := endStream && len(p) == 0
+		if end {
+			cs.sentEndStream = true
+		}
+		cc.mu.Unlock()
+
+		err := cc.wq.writeAndWait(func(fr *http2Framer) error {
+			return fr.WriteData(cs.id, end, chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if end {
+			cc.mu.Lock()
+			cs.maybeForgetLocked()
+			cc.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// cancel aborts cs with err, telling the server to stop sending.
+func (cs *http2clientStream) cancel(err error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cs.closed {
+		return
+	}
+	cs.abortLocked(err)
+	id := cs.id
+	cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		return fr.WriteRSTStream(id, http2ErrCodeCancel)
+	}})
+}
+
+// abortLocked fails cs with err: a pending RoundTrip returns err
+// and reads of the response body return it. cc.mu must be held.
+func (cs *http2clientStream) abortLocked(err error) {
+	if cs.closed {
+		return
+	}
+	if cs.resp == nil {
+		cs.resc <- responseAndError{nil, err}
+	}
+	if n := cs.body.breakWithError(err); n > 0 {
+		cs.cc.sendWindowUpdateLocked(nil, int32(n))
+	}
+	cs.forgetLocked()
+}
+
+// maybeForgetLocked forgets cs once both sides have ended it. Any
+// buffered response body remains readable.
+func (cs *http2clientStream) maybeForgetLocked() {
+	if cs.sentEndStream && cs.gotEndStream {
+		cs.forgetLocked()
+	}
+}
+
+func (cs *http2clientStream) forgetLocked() {
+	if cs.closed {
+		return
+	}
+	cs.closed = true
+	cc := cs.cc
+	delete(cc.streams, cs.id)
+	cc.numStreams--
+	cc.cond.Broadcast()
+	if (cc.goAway || cc.singleUse) && cc.numStreams == 0 && !cc.closed {
+		go cc.closeWithError(nil)
+	}
+}
+
+// closeWithError closes the connection, failing any streams in
+// flight with err.
+func (cc *http2clientConn) closeWithError(err error) {
+	if err == nil {
+		err = http2errClientConnLost
+	}
+	cc.mu.Lock()
+	if cc.closed {
+		cc.mu.Unlock()
+		return
+	}
+	cc.closed = true
+	for _, cs := range cc.streams {
+		cs.abortLocked(err)
+	}
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	cc.t.removeHTTP2Conn(cc)
+	cc.wq.close()
+	cc.nc.Close()
+}
+
+func (cc *http2clientConn) readLoop() {
+	defer close(cc.readerDone)
+	for {
+		f, err := cc.fr.ReadFrame()
+		if err == nil {
+			err = cc.processFrame(f)
+		}
+		switch ev := err.(type) {
+		case nil:
+			continue
+		case http2StreamError:
+			cc.resetStream(ev)
+			continue
+		case http2ConnectionError:
+			cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+				return fr.WriteGoAway(0, http2ErrCode(ev), nil)
+			}})
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		cc.closeWithError(err)
+		return
+	}
+}
+
+// resetStream sends a RST_STREAM for se and fails the stream.
+func (cc *http2clientConn) resetStream(se http2StreamError) {
+	cc.mu.Lock()
+	if cs, ok := cc.streams[se.StreamID]; ok {
+		cs.abortLocked(se)
+	}
+	cc.mu.Unlock()
+	cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		return fr.WriteRSTStream(se.StreamID, se.Code)
+	}})
+}
+
+func (cc *http2clientConn) processFrame(f *http2Frame) error {
+	if !cc.sawSettings {
+		// The server's preface is a SETTINGS frame.
+		if f.Type != http2FrameSettings || f.has(http2FlagAck) {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
+		cc.sawSettings = true
+	}
+	switch f.Type {
+	case http2FrameSettings:
+		return cc.processSettings(f)
+	case http2FrameHeaders:
+		return cc.processHeaders(f)
+	case http2FrameData:
+		return cc.processData(f)
+	case http2FrameWindowUpdate:
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		if f.StreamID == 0 {
+			if !cc.outflow.add(int32(f.incr)) {
+				return http2ConnectionError(http2ErrCodeFlowControl)
+			}
+		} else if cs, ok := cc.streams[f.StreamID]; ok {
+			if !cs.outflow.add(int32(f.incr)) {
+				return http2StreamError{f.StreamID, http2ErrCodeFlowControl}
+			}
+		}
+		cc.cond.Broadcast()
+	case http2FrameRSTStream:
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		if cs, ok := cc.streams[f.StreamID]; ok {
+			cs.abortLocked(http2StreamError{f.StreamID, f.code})
+		}
+	case http2FramePing:
+		if !f.has(http2FlagAck) {
+			data := f.ping
+			cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+				return fr.WritePing(true, data)
+			}})
+		}
+	case http2FrameGoAway:
+		cc.t.removeHTTP2Conn(cc)
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		cc.goAway = true
+		for id, cs := range cc.streams {
+			if id > f.lastID {
+				cs.abortLocked(http2errServerGoAway)
+			}
+		}
+		if cc.numStreams == 0 {
+			go cc.closeWithError(nil)
+		}
+		cc.cond.Broadcast()
+	case http2FramePushPromise:
+		// We disabled push in our SETTINGS.
+		return http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return nil
+}
+
+func (cc *http2clientConn) processSettings(f *http2Frame) error {
+	if f.has(http2FlagAck) {
+		return nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	var tableSize uint32
+	var setTableSize bool
+	for _, s := range f.settings {
+		switch s.ID {
+		case http2SettingHeaderTableSize:
+			tableSize, setTableSize = s.Val, true
+		case http2SettingMaxConcurrentStreams:
+			cc.maxConcurrentStreams = s.Val
+		case http2SettingInitialWindowSize:
+			delta := int32(s.Val) - cc.peerInitialWindow
+			for _, cs := range cc.streams {
+				if !cs.outflow.add(delta) {
+					return http2ConnectionError(http2ErrCodeFlowControl)
+				}
+			}
+			cc.peerInitialWindow = int32(s.Val)
+		case http2SettingMaxFrameSize:
+			cc.peerMaxFrameSize = s.Val
+		}
+	}
+	cc.cond.Broadcast()
+	cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		if setTableSize {
+			cc.henc.SetMaxDynamicTableSizeLimit(tableSize)
+		}
+		return fr.WriteSettingsAck()
+	}})
+	return nil
+}
+
+// streamForFrameLocked returns the open stream f belongs to, or nil
+// if the stream is already closed. It returns an error if f refers
+// to a stream we never opened.
+func (cc *http2clientConn) streamForFrameLocked(f *http2Frame) (*http2clientStream, error) {
+	if cs, ok := cc.streams[f.StreamID]; ok {
+		return cs, nil
+	}
+	if f.StreamID%2 == 0 || f.StreamID >= cc.nextStreamID {
+		return nil, http2ConnectionError(http2ErrCodeProtocol)
+	}
+	return nil, nil
+}
+
+func (cc *http2clientConn) processHeaders(f *http2Frame) error {
+	hfs, err := cc.hdec.Decode(f.data)
+	if err != nil {
+		return http2ConnectionError(http2ErrCodeCompression)
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cs, err := cc.streamForFrameLocked(f)
+	if cs == nil {
+		return err
+	}
+	if cs.gotEndStream {
+		return http2StreamError{cs.id, http2ErrCodeStreamClosed}
+	}
+
+	if cs.resp != nil {
+		// Trailers. They must end the stream.
+		if !f.endStream() {
+			return http2StreamError{cs.id, http2ErrCodeProtocol}
+		}
+		trailer := make(Header)
+		for _, hf := range hfs {
+			if !strings.HasPrefix(hf.Name, ":") {
+				trailer.Add(CanonicalHeaderKey(hf.Name), hf.Value)
+			}
+		}
+		cs.resp.Trailer = trailer
+		cs.endStreamLocked()
+		return nil
+	}
+
+	res, err := cs.newResponse(hfs)
+	if err != nil {
+		return http2StreamError{cs.id, http2ErrCodeProtocol}
+	}
+	if res.StatusCode >= 100 && res.StatusCode <= 199 {
+		// Informational; the real response follows.
+		if f.endStream() {
+			return http2StreamError{cs.id, http2ErrCodeProtocol}
+		}
+		return nil
+	}
+	cs.resp = res
+	if f.endStream() || cs.req.Method == "HEAD" {
+		if f.endStream() && res.ContentLength == -1 {
+			res.ContentLength = 0
+		}
+		res.Body = eofReader
+	} else {
+		res.Body = &http2transportResponseBody{cs: cs}
+		if cs.requestedGzip && res.Header.get("Content-Encoding") == "gzip" {
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			res.Body = &gzipReader{body: res.Body}
+		}
+	}
+	cs.resc <- responseAndError{res: res}
+	if f.endStream() {
+		cs.endStreamLocked()
+	}
+	return nil
+}
+
+// newResponse builds the *Response from a response header block.
+func (cs *http2clientStream) newResponse(hfs []hpack.HeaderField) (*Response, error) {
+	var status string
+	header := make(Header)
+	for _, hf := range hfs {
+		switch {
+		case hf.Name == ":status":
+			status = hf.Value
+		case strings.HasPrefix(hf.Name, ":"):
+			return nil, errors.New("invalid response pseudo-header")
+		default:
+			header.Add(CanonicalHeaderKey(hf.Name), hf.Value)
+		}
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil || len(status) != 3 {
+		return nil, errors.New("malformed response status")
+	}
+	res := &Response{
+		Status:        status + " " + StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		ContentLength: -1,
+		Request:       cs.req,
+		TLS:           cs.cc.tlsState,
+	}
+	if cl := header.get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			res.ContentLength = n
+		}
+	}
+	return res, nil
+}
+
+// endStreamLocked notes that the server ended cs.
+func (cs *http2clientStream) endStreamLocked() {
+	cs.gotEndStream = true
+	cs.body.CloseWithError(io.EOF)
+	cs.cc.t.setReqCanceler(cs.req, nil)
+	cs.maybeForgetLocked()
+}
+
+func (cc *http2clientConn) processData(f *http2Frame) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	n := int32(f.Length)
+	if n > cc.inflow {
+		return http2ConnectionError(http2ErrCodeFlowControl)
+	}
+	cc.inflow -= n
+	cs, err := cc.streamForFrameLocked(f)
+	if cs == nil {
+		// A canceled stream; discard, but keep the
+		// connection's window open.
+		cc.sendWindowUpdateLocked(nil, n)
+		return err
+	}
+	if cs.resp == nil || cs.gotEndStream {
+		cc.sendWindowUpdateLocked(nil, n)
+		return http2StreamError{cs.id, http2ErrCodeProtocol}
+	}
+	if n > cs.inflow {
+		cc.sendWindowUpdateLocked(nil, n)
+		return http2StreamError{cs.id, http2ErrCodeFlowControl}
+	}
+	cs.inflow -= n
+	refund := n - int32(len(f.data))
+	if len(f.data) > 0 {
+		if _, err := cs.body.Write(f.data); err != nil {
+			refund += int32(len(f.data))
+		}
+	}
+	if refund > 0 {
+		cc.sendWindowUpdateLocked(cs, refund)
+	}
+	if f.endStream() {
+		cs.endStreamLocked()
+	}
+	return nil
+}
+
+// sendWindowUpdateLocked returns n bytes of receive window to the
+// server, for the connection and, if cs is non-nil and still
+// receiving, for cs.
+func (cc *http2clientConn) sendWindowUpdateLocked(cs *http2clientStream, n int32) {
+	if n <= 0 || cc.closed {
+		return
+	}
+	cc.inflow += n
+	var streamID uint32
+	if cs != nil && !cs.gotEndStream && !cs.closed {
+		cs.inflow += n
+		streamID = cs.id
+	}
+	cc.wq.push(http2writeReq{write: func(fr *http2Framer) error {
+		if streamID != 0 {
+			if err := fr.WriteWindowUpdate(streamID, uint32(n)); err != nil {
+				return err
+			}
+		}
+		return fr.WriteWindowUpdate(0, uint32(n))
+	}})
+}
+
+// http2transportResponseBody is the Response.Body of an HTTP/2
+// response.
+type http2transportResponseBody struct {
+	cs     *http2clientStream
+	closed bool
+}
+
+func (b *http2transportResponseBody) Read(p []byte) (n int, err error) {
+	if b.closed {
+		return 0, errors.New("http: read on closed response body")
+	}
+	cs := b.cs
+	n, err = cs.body.Read(p)
+	if n > 0 {
+		cs.cc.mu.Lock()
+		cs.cc.sendWindowUpdateLocked(cs, int32(n))
+		cs.cc.mu.Unlock()
+	}
+	if err == io.EOF {
+		return n, err
+	}
+	if err != nil && err != http2errRequestCanceled {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func (b *http2transportResponseBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	cs := b.cs
+	cc := cs.cc
+	cc.mu.Lock()
+	done := cs.gotEndStream || cs.closed
+	cc.mu.Unlock()
+	if !done {
+		// Tell the server to stop sending what we won't read.
+		cs.cancel(http2errRequestCanceled)
+	}
+	cc.t.setReqCanceler(cs.req, nil)
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpack
+
+import (
+	"io"
+)
+
+const (
+	// initialTableSize is the default dynamic table size from
+	// RFC 7540, section 6.5.2.
+	initialTableSize = 4096
+)
+
+// An Encoder encodes header fields into header blocks written to w.
+type Encoder struct {
+	dyn dynamicTable
+	w   io.Writer
+	buf []byte
+
+	// minSize is the smallest table size set since the last
+	// header block; both it and the final size must be signaled
+	// to the decoder (RFC 7541, section 4.2).
+	minSize         uint32
+	tableSizeUpdate bool
+
+	// maxSizeLimit is the largest table size the peer allows.
+	maxSizeLimit uint32
+}
+
+// NewEncoder returns a new Encoder which performs HPACK encoding. An
+// encoded data is written to w.
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{w: w, maxSizeLimit: initialTableSize}
+	e.dyn.maxSize = initialTableSize
+	return e
+}
+
+// WriteField encodes f into a single Write to e's underlying Writer.
+// This function may also produce bytes for a "Dynamic Table Size
+// Update" if necessary. If produced, it is done before encoding f.
+func (e *Encoder) WriteField(f HeaderField) error {
+	e.buf = e.buf[:0]
+
+	if e.tableSizeUpdate {
+		e.tableSizeUpdate = false
+		if e.minSize < e.dyn.maxSize {
+			e.buf = appendVarInt(e.buf, 5, uint64(e.minSize))
+			e.buf[0] |= 0x20
+		}
+		start := len(e.buf)
+		e.buf = appendVarInt(e.buf, 5, uint64(e.dyn.maxSize))
+		e.buf[start] |= 0x20
+	}
+
+	idx, nameValueMatch := e.search(f)
+	switch {
+	case nameValueMatch:
+		start := len(e.buf)
+		e.buf = appendVarInt(e.buf, 7, idx)
+		e.buf[start] |= 0x80
+	case f.Sensitive:
+		e.buf = e.appendLiteral(e.buf, f, idx, 4, 0x10)
+	case f.size() > e.dyn.maxSize:
+		// Indexing would just flush the table.
+		e.buf = e.appendLiteral(e.buf, f, idx, 4, 0)
+	default:
+		e.buf = e.appendLiteral(e.buf, f, idx, 6, 0x40)
+		e.dyn.add(f)
+	}
+
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// search returns the index of the entry best matching f. If
+// nameValueMatch is true, the entry matches both name and value;
+// otherwise, a non-zero index matches only the name.
+func (e *Encoder) search(f HeaderField) (idx uint64, nameValueMatch bool) {
+	for i, hf := range staticTable {
+		if hf.Name != f.Name {
+			continue
+		}
+		if idx == 0 {
+			idx = uint64(i + 1)
+		}
+		if !f.Sensitive && hf.Value == f.Value {
+			return uint64(i + 1), true
+		}
+	}
+	for i := len(e.dyn.ents) - 1; i >= 0; i-- {
+		hf := e.dyn.ents[i]
+		if hf.Name != f.Name {
+			continue
+		}
+		j := uint64(len(staticTable) + len(e.dyn.ents) - i)
+		if idx == 0 {
+			idx = j
+		}
+		if !f.Sensitive && hf.Value == f.Value {
+			return j, true
+		}
+	}
+	return idx, false
+}
+
+// appendLiteral appends a literal representation of f with the given
+// prefix width and pattern bits, referencing the name by idx if
+// non-zero.
+func (e *Encoder) appendLiteral(dst []byte, f HeaderField, idx uint64, n byte, pattern byte) []byte {
+	start := len(dst)
+	dst = appendVarInt(dst, n, idx)
+	dst[start] |= pattern
+	if idx == 0 {
+		dst = appendString(dst, f.Name)
+	}
+	return appendString(dst, f.Value)
+}
+
+// SetMaxDynamicTableSize changes the dynamic header table size to v.
+// The actual size is bounded by the value passed to
+// SetMaxDynamicTableSizeLimit.
+func (e *Encoder) SetMaxDynamicTableSize(v uint32) {
+	if v > e.maxSizeLimit {
+		v = e.maxSizeLimit
+	}
+	if v < e.minSize || !e.tableSizeUpdate {
+		e.minSize = v
+	}
+	e.tableSizeUpdate = true
+	e.dyn.setMaxSize(v)
+}
+
+// SetMaxDynamicTableSizeLimit changes the maximum value that can be
+// specified in SetMaxDynamicTableSize, normally the peer's
+// SETTINGS_HEADER_TABLE_SIZE. If the current table size exceeds v,
+// the table is shrunk to v.
+func (e *Encoder) SetMaxDynamicTableSizeLimit(v uint32) {
+	e.maxSizeLimit = v
+	if e.dyn.maxSize > v {
+		e.SetMaxDynamicTableSize(v)
+	}
+}
+
+// appendString appends s as a string literal, Huffman-encoded when
+// that is shorter.
+func appendString(dst []byte, s string) []byte {
+	huffmanLength := HuffmanEncodeLength(s)
+	if huffmanLength < uint64(len(s)) {
+		start := len(dst)
+		dst = appendVarInt(dst, 7, huffmanLength)
+		dst[start] |= 0x80
+		return AppendHuffmanString(dst, s)
+	}
+	dst = appendVarInt(dst, 7, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// appendVarInt appends i, as encoded in variable integer form using n
+// bit prefix, to dst and returns the extended buffer. The prefix
+// bits of the first byte are left zero for the caller to fill in.
+func appendVarInt(dst []byte, n byte, i uint64) []byte {
+	k := uint64((1 << n) - 1)
+	if i < k {
+		return append(dst, byte(i))
+	}
+	dst = append(dst, byte(k))
+	i -= k
+	for ; i >= 128; i >>= 7 {
+		dst = append(dst, byte(0x80|(i&0x7f)))
+	}
+	return append(dst, byte(i))
+}
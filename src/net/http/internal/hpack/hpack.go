@@ -0,0 +1,307 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpack implements HPACK, the header compression format used
+// by HTTP/2, as defined in RFC 7541.
+package hpack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A HeaderField is a name-value pair. Both the name and value are
+// treated as opaque sequences of octets.
+type HeaderField struct {
+	Name, Value string
+
+	// Sensitive means that this header field should never be
+	// indexed.
+	Sensitive bool
+}
+
+func (hf HeaderField) String() string {
+	var suffix string
+	if hf.Sensitive {
+		suffix = " (sensitive)"
+	}
+	return fmt.Sprintf("header field %q = %q%s", hf.Name, hf.Value, suffix)
+}
+
+// size returns the size of an entry per RFC 7541 section 4.1.
+func (hf HeaderField) size() uint32 {
+	return uint32(len(hf.Name) + len(hf.Value) + 32)
+}
+
+// A DecodingError is something the spec defines as a decoding error.
+type DecodingError struct {
+	Err error
+}
+
+func (de DecodingError) Error() string {
+	return fmt.Sprintf("decoding error: %v", de.Err)
+}
+
+// An InvalidIndexError is returned when an encoder references a table
+// entry before the static table or after the end of the dynamic table.
+type InvalidIndexError int
+
+func (e InvalidIndexError) Error() string {
+	return fmt.Sprintf("invalid indexed representation index %d", int(e))
+}
+
+var (
+	errNeedMore          = errors.New("need more data")
+	errVarintOverflow    = DecodingError{errors.New("varint integer overflow")}
+	errStringTooLong     = DecodingError{errors.New("string length exceeds limit")}
+	errLateSizeUpdate    = DecodingError{errors.New("dynamic table size update after header field")}
+	errSizeUpdateTooHigh = DecodingError{errors.New("dynamic table size update above maximum")}
+)
+
+// A dynamicTable is the FIFO table of header fields shared by an
+// encoder and decoder pair. Entries are appended at the end and
+// evicted from the front.
+type dynamicTable struct {
+	ents    []HeaderField
+	size    uint32
+	maxSize uint32
+}
+
+func (dt *dynamicTable) setMaxSize(v uint32) {
+	dt.maxSize = v
+	dt.evict()
+}
+
+func (dt *dynamicTable) add(f HeaderField) {
+	dt.ents = append(dt.ents, f)
+	dt.size += f.size()
+	dt.evict()
+}
+
+// evict removes the oldest entries until the table fits maxSize.
+func (dt *dynamicTable) evict() {
+	n := 0
+	for dt.size > dt.maxSize && n < len(dt.ents) {
+		dt.size -= dt.ents[n].size()
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	copy(dt.ents, dt.ents[n:])
+	for k := len(dt.ents) - n; k < len(dt.ents); k++ {
+		dt.ents[k] = HeaderField{} // so strings can be garbage collected
+	}
+	dt.ents = dt.ents[:len(dt.ents)-n]
+}
+
+// at returns the entry with the given 1-based HPACK index, which
+// addresses the static table followed by the dynamic table, newest
+// entry first.
+func (dt *dynamicTable) at(i uint64) (hf HeaderField, ok bool) {
+	if i < 1 {
+		return
+	}
+	if i <= uint64(len(staticTable)) {
+		return staticTable[i-1], true
+	}
+	i -= uint64(len(staticTable))
+	if i > uint64(len(dt.ents)) {
+		return
+	}
+	return dt.ents[len(dt.ents)-int(i)], true
+}
+
+// A Decoder decodes header blocks. Its dynamic table persists across
+// calls to Decode, so one Decoder must be used for all header blocks
+// received on a connection, in order.
+type Decoder struct {
+	dyn dynamicTable
+
+	// maxTableSize is the limit we advertised to the peer; the
+	// encoder may shrink the table below it, but never grow it
+	// beyond.
+	maxTableSize uint32
+
+	// maxStrLen, if non-zero, limits the length of any single
+	// decoded string.
+	maxStrLen int
+}
+
+// NewDecoder returns a new decoder with the provided maximum dynamic
+// table size, as advertised in SETTINGS_HEADER_TABLE_SIZE.
+func NewDecoder(maxDynamicTableSize uint32) *Decoder {
+	d := &Decoder{maxTableSize: maxDynamicTableSize}
+	d.dyn.maxSize = maxDynamicTableSize
+	return d
+}
+
+// SetMaxStringLength sets the maximum length of any decoded name or
+// value. A value of 0 means unlimited.
+func (d *Decoder) SetMaxStringLength(n int) {
+	d.maxStrLen = n
+}
+
+// SetAllowedMaxDynamicTableSize changes the upper bound that the
+// encoder may set the dynamic table size to.
+func (d *Decoder) SetAllowedMaxDynamicTableSize(v uint32) {
+	d.maxTableSize = v
+}
+
+// Decode decodes a complete header block and returns its fields in
+// order.
+func (d *Decoder) Decode(p []byte) ([]HeaderField, error) {
+	var hfs []HeaderField
+	for len(p) > 0 {
+		b := p[0]
+		var (
+			hf   HeaderField
+			emit = true
+			err  error
+		)
+		switch {
+		case b&128 != 0:
+			// Indexed header field, section 6.1.
+			var idx uint64
+			idx, p, err = readVarInt(7, p)
+			if err != nil {
+				break
+			}
+			var ok bool
+			if hf, ok = d.dyn.at(idx); !ok {
+				err = DecodingError{InvalidIndexError(idx)}
+			}
+		case b&192 == 64:
+			// Literal with incremental indexing, section 6.2.1.
+			hf, p, err = d.readLiteral(6, p)
+			if err == nil {
+				d.dyn.add(hf)
+			}
+		case b&240 == 0:
+			// Literal without indexing, section 6.2.2.
+			hf, p, err = d.readLiteral(4, p)
+		case b&240 == 16:
+			// Literal never indexed, section 6.2.3.
+			hf, p, err = d.readLiteral(4, p)
+			hf.Sensitive = true
+		case b&224 == 32:
+			// Dynamic table size update, section 6.3. It
+			// must occur at the beginning of a block.
+			emit = false
+			if len(hfs) > 0 {
+				err = errLateSizeUpdate
+				break
+			}
+			var size uint64
+			size, p, err = readVarInt(5, p)
+			if err != nil {
+				break
+			}
+			if size > uint64(d.maxTableSize) {
+				err = errSizeUpdateTooHigh
+				break
+			}
+			d.dyn.setMaxSize(uint32(size))
+		default:
+			err = DecodingError{errors.New("invalid encoding")}
+		}
+		if err == errNeedMore {
+			err = DecodingError{errors.New("truncated header block")}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if emit {
+			hfs = append(hfs, hf)
+		}
+	}
+	return hfs, nil
+}
+
+// readLiteral reads a literal header field representation whose
+// index uses an n-bit prefix.
+func (d *Decoder) readLiteral(n byte, p []byte) (hf HeaderField, rest []byte, err error) {
+	idx, p, err := readVarInt(n, p)
+	if err != nil {
+		return
+	}
+	if idx > 0 {
+		ihf, ok := d.dyn.at(idx)
+		if !ok {
+			return hf, p, DecodingError{InvalidIndexError(idx)}
+		}
+		hf.Name = ihf.Name
+	} else {
+		hf.Name, p, err = d.readString(p)
+		if err != nil {
+			return
+		}
+	}
+	hf.Value, p, err = d.readString(p)
+	return hf, p, err
+}
+
+// readString reads a string literal, section 5.2.
+func (d *Decoder) readString(p []byte) (s string, rest []byte, err error) {
+	if len(p) == 0 {
+		return "", p, errNeedMore
+	}
+	isHuff := p[0]&128 != 0
+	n, p, err := readVarInt(7, p)
+	if err != nil {
+		return "", p, err
+	}
+	if d.maxStrLen != 0 && n > uint64(d.maxStrLen) {
+		return "", p, errStringTooLong
+	}
+	if uint64(len(p)) < n {
+		return "", p, errNeedMore
+	}
+	if !isHuff {
+		return string(p[:n]), p[n:], nil
+	}
+	buf, err := HuffmanDecode(nil, p[:n])
+	if err != nil {
+		return "", p, DecodingError{err}
+	}
+	if d.maxStrLen != 0 && len(buf) > d.maxStrLen {
+		return "", p, errStringTooLong
+	}
+	return string(buf), p[n:], nil
+}
+
+// readVarInt reads an unsigned variable length integer off the
+// beginning of p, using an n-bit prefix (section 5.1).
+func readVarInt(n byte, p []byte) (i uint64, rest []byte, err error) {
+	if n < 1 || n > 8 {
+		panic("bad n")
+	}
+	if len(p) == 0 {
+		return 0, p, errNeedMore
+	}
+	i = uint64(p[0])
+	if n < 8 {
+		i &= (1 << uint64(n)) - 1
+	}
+	if i < (1<<uint64(n))-1 {
+		return i, p[1:], nil
+	}
+
+	origP := p
+	p = p[1:]
+	var m uint64
+	for len(p) > 0 {
+		b := p[0]
+		p = p[1:]
+		i += uint64(b&127) << m
+		if b&128 == 0 {
+			return i, p, nil
+		}
+		m += 7
+		if m >= 63 {
+			return 0, origP, errVarintOverflow
+		}
+	}
+	return 0, origP, errNeedMore
+}
@@ -0,0 +1,215 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+type blockTest struct {
+	wire string
+	want []HeaderField
+}
+
+// Examples from RFC 7541, Appendix C.3 (plain) and C.4 (Huffman).
+var requestTests = map[string][]blockTest{
+	"C.3": {
+		{"8286 8441 0f77 7777 2e65 7861 6d70 6c65 2e63 6f6d", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "http"},
+			{Name: ":path", Value: "/"},
+			{Name: ":authority", Value: "www.example.com"},
+		}},
+		{"8286 84be 5808 6e6f 2d63 6163 6865", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "http"},
+			{Name: ":path", Value: "/"},
+			{Name: ":authority", Value: "www.example.com"},
+			{Name: "cache-control", Value: "no-cache"},
+		}},
+		{"8287 85bf 400a 6375 7374 6f6d 2d6b 6579 0c63 7573 746f 6d2d 7661 6c75 65", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "https"},
+			{Name: ":path", Value: "/index.html"},
+			{Name: ":authority", Value: "www.example.com"},
+			{Name: "custom-key", Value: "custom-value"},
+		}},
+	},
+	"C.4": {
+		{"8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "http"},
+			{Name: ":path", Value: "/"},
+			{Name: ":authority", Value: "www.example.com"},
+		}},
+		{"8286 84be 5886 a8eb 1064 9cbf", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "http"},
+			{Name: ":path", Value: "/"},
+			{Name: ":authority", Value: "www.example.com"},
+			{Name: "cache-control", Value: "no-cache"},
+		}},
+		{"8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf", []HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":scheme", Value: "https"},
+			{Name: ":path", Value: "/index.html"},
+			{Name: ":authority", Value: "www.example.com"},
+			{Name: "custom-key", Value: "custom-value"},
+		}},
+	},
+}
+
+func TestDecodeExamples(t *testing.T) {
+	for name, blocks := range requestTests {
+		d := NewDecoder(initialTableSize)
+		for i, bt := range blocks {
+			got, err := d.Decode(mustHex(t, bt.wire))
+			if err != nil {
+				t.Errorf("%s #%d: %v", name, i, err)
+				break
+			}
+			if !reflect.DeepEqual(got, bt.want) {
+				t.Errorf("%s #%d: got %v; want %v", name, i, got, bt.want)
+			}
+		}
+	}
+}
+
+func TestEncodeExamples(t *testing.T) {
+	// The encoder always prefers Huffman coding when shorter, so
+	// its output matches the C.4 examples byte for byte.
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for i, bt := range requestTests["C.4"] {
+		buf.Reset()
+		for _, hf := range bt.want {
+			if err := e.WriteField(hf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := mustHex(t, bt.wire); !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("#%d: encoded %x; want %x", i, buf.Bytes(), want)
+		}
+	}
+}
+
+func TestEncodeDecodeTableSizeUpdate(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	d := NewDecoder(initialTableSize)
+	fields := []HeaderField{
+		{Name: "x-foo", Value: "bar"},
+		{Name: "x-secret", Value: "hunter2", Sensitive: true},
+		{Name: "x-foo", Value: "bar"},
+	}
+	for _, size := range []uint32{initialTableSize, 0, 64, 8192} {
+		e.SetMaxDynamicTableSize(size)
+		buf.Reset()
+		for _, hf := range fields {
+			e.WriteField(hf)
+		}
+		got, err := d.Decode(buf.Bytes())
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !reflect.DeepEqual(got, fields) {
+			t.Errorf("size %d: got %v; want %v", size, got, fields)
+		}
+		if d.dyn.maxSize != e.dyn.maxSize {
+			t.Errorf("size %d: decoder table size %d; encoder %d", size, d.dyn.maxSize, e.dyn.maxSize)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []string{
+		"be",        // dynamic index beyond the table
+		"80",        // index 0
+		"41",        // truncated literal
+		"418c f1e3", // truncated Huffman string
+		"3fe1 ff03", // table size update above the maximum
+		"82 20",     // table size update after a field
+		// varint overflow
+		"ff80 8080 8080 8080 8080 01",
+	}
+	for _, tt := range tests {
+		if _, err := NewDecoder(initialTableSize).Decode(mustHex(t, tt)); err == nil {
+			t.Errorf("Decode(%s) succeeded; want error", tt)
+		}
+	}
+}
+
+func TestHuffmanRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"www.example.com",
+		"no-cache",
+		"Mon, 21 Oct 2013 20:13:21 GMT",
+		"\x00\x01\xfe\xff",
+		strings.Repeat("a", 1000),
+	}
+	for _, s := range tests {
+		enc := AppendHuffmanString(nil, s)
+		if uint64(len(enc)) != HuffmanEncodeLength(s) {
+			t.Errorf("%q: encoded to %d bytes; HuffmanEncodeLength = %d", s, len(enc), HuffmanEncodeLength(s))
+		}
+		dec, err := HuffmanDecode(nil, enc)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if string(dec) != s {
+			t.Errorf("round trip of %q = %q", s, dec)
+		}
+	}
+}
+
+func TestHuffmanDecodeInvalid(t *testing.T) {
+	tests := []string{
+		"ff",           // padding longer than 7 bits
+		"f1e3 c2e5 00", // padding of zeros
+		"ffff fffc",    // EOS
+	}
+	for _, tt := range tests {
+		if _, err := HuffmanDecode(nil, mustHex(t, tt)); err == nil {
+			t.Errorf("HuffmanDecode(%s) succeeded; want error", tt)
+		}
+	}
+}
+
+func TestVarInt(t *testing.T) {
+	tests := []struct {
+		n    byte
+		i    uint64
+		want []byte
+	}{
+		{5, 10, []byte{10}},
+		{5, 1337, []byte{31, 154, 10}},
+		{8, 42, []byte{42}},
+		{7, 127, []byte{127, 0}},
+	}
+	for _, tt := range tests {
+		got := appendVarInt(nil, tt.n, tt.i)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("appendVarInt(%d, %d) = %v; want %v", tt.n, tt.i, got, tt.want)
+		}
+		i, rest, err := readVarInt(tt.n, got)
+		if err != nil || i != tt.i || len(rest) != 0 {
+			t.Errorf("readVarInt(%d, %v) = %d, %v, %v; want %d", tt.n, got, i, rest, err, tt.i)
+		}
+	}
+}
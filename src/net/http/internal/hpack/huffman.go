@@ -0,0 +1,109 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpack
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInvalidHuffman is returned for Huffman-encoded strings with
+// invalid padding or an encoded EOS symbol.
+var ErrInvalidHuffman = errors.New("hpack: invalid Huffman-encoded data")
+
+// A huffmanNode is a node of the binary decoding tree. Interior
+// nodes have sym == -1 and children indexes into huffmanTree.
+type huffmanNode struct {
+	children [2]uint16
+	sym      int16
+}
+
+var (
+	huffmanTreeOnce sync.Once
+	huffmanTree     []huffmanNode // huffmanTree[0] is the root
+)
+
+func buildHuffmanTree() {
+	huffmanTree = []huffmanNode{{sym: -1}}
+	for sym, code := range huffmanCodes {
+		n := 0
+		for i := int(huffmanCodeLens[sym]) - 1; i >= 0; i-- {
+			bit := (code >> uint(i)) & 1
+			next := huffmanTree[n].children[bit]
+			if next == 0 {
+				next = uint16(len(huffmanTree))
+				huffmanTree[n].children[bit] = next
+				huffmanTree = append(huffmanTree, huffmanNode{sym: -1})
+			}
+			n = int(next)
+		}
+		huffmanTree[n].sym = int16(sym)
+	}
+}
+
+// HuffmanDecode appends the decoding of the Huffman-encoded v to dst
+// and returns the extended buffer.
+func HuffmanDecode(dst, v []byte) ([]byte, error) {
+	huffmanTreeOnce.Do(buildHuffmanTree)
+	n := 0     // current node
+	depth := 0 // bits consumed since the last emitted symbol
+	allOnes := true
+	for _, b := range v {
+		for i := 7; i >= 0; i-- {
+			bit := (b >> uint(i)) & 1
+			if bit == 0 {
+				allOnes = false
+			}
+			n = int(huffmanTree[n].children[bit])
+			depth++
+			if n == 0 {
+				// Only the 30-bit EOS code is missing from
+				// the tree; reaching it is an error.
+				return dst, ErrInvalidHuffman
+			}
+			if sym := huffmanTree[n].sym; sym >= 0 {
+				dst = append(dst, byte(sym))
+				n, depth, allOnes = 0, 0, true
+			}
+		}
+	}
+	// Any remaining bits must be a prefix of EOS (all ones)
+	// shorter than a byte.
+	if depth > 7 || !allOnes {
+		return dst, ErrInvalidHuffman
+	}
+	return dst, nil
+}
+
+// AppendHuffmanString appends the Huffman encoding of s to dst and
+// returns the extended buffer.
+func AppendHuffmanString(dst []byte, s string) []byte {
+	var acc uint64 // pending bits, right-aligned
+	var nbits uint // number of pending bits in acc
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		acc = acc<<huffmanCodeLens[c] | uint64(huffmanCodes[c])
+		nbits += uint(huffmanCodeLens[c])
+		for nbits >= 8 {
+			nbits -= 8
+			dst = append(dst, byte(acc>>nbits))
+		}
+	}
+	if nbits > 0 {
+		// Pad with the most significant bits of EOS.
+		dst = append(dst, byte(acc<<(8-nbits)|0xff>>nbits))
+	}
+	return dst
+}
+
+// HuffmanEncodeLength returns the number of bytes required to
+// Huffman-encode s.
+func HuffmanEncodeLength(s string) uint64 {
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		n += uint64(huffmanCodeLens[s[i]])
+	}
+	return (n + 7) / 8
+}
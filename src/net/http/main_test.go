@@ -35,6 +35,7 @@ func interestingGoroutines() (gs []string) {
 		if stack == "" ||
 			strings.Contains(stack, "created by net.startServer") ||
 			strings.Contains(stack, "created by testing.RunTests") ||
+			strings.Contains(stack, "created by testing.(*T).Run") ||
 			strings.Contains(stack, "closeWriteAndWait") ||
 			strings.Contains(stack, "testing.Main(") ||
 			// These only show up with GOTRACEBACK=2; Issue 5005 (comment 28)
@@ -2582,6 +2582,206 @@ func TestServerKeepAlivesEnabled(t *testing.T) {
 	}
 }
 
+// shutdownTestServer starts srv on a local listener and returns its
+// URL along with a channel that receives the result of Serve.
+func shutdownTestServer(t *testing.T, srv *Server) (url string, serveErr <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	return "http://" + ln.Addr().String(), errc
+}
+
+func TestServerShutdown(t *testing.T) {
+	defer afterTest(t)
+	gotReq := make(chan bool)
+	release := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		gotReq <- true
+		<-release
+		io.WriteString(w, "done")
+	})}
+	url, serveErr := shutdownTestServer(t, srv)
+
+	type result struct {
+		body string
+		err  error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		res, err := Get(url)
+		if err != nil {
+			resc <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		slurp, err := ioutil.ReadAll(res.Body)
+		resc <- result{string(slurp), err}
+	}()
+	<-gotReq
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(time.Time{}) }()
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve = %v; want ErrServerClosed", err)
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	if r := <-resc; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want \"done\", nil", r.body, r.err)
+	}
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for Shutdown")
+	}
+	if _, err := Get(url); err == nil {
+		t.Error("Get after Shutdown succeeded; want error")
+	}
+	if err := srv.ListenAndServe(); err != ErrServerClosed {
+		t.Errorf("ListenAndServe after Shutdown = %v; want ErrServerClosed", err)
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	defer afterTest(t)
+	gotReq := make(chan bool)
+	release := make(chan bool)
+	handlerDone := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		defer close(handlerDone)
+		gotReq <- true
+		<-release
+	})}
+	url, _ := shutdownTestServer(t, srv)
+	errc := make(chan error, 1)
+	go func() {
+		res, err := Get(url)
+		if err == nil {
+			res.Body.Close()
+		}
+		errc <- err
+	}()
+	<-gotReq
+	if err := srv.Shutdown(time.Now().Add(50 * time.Millisecond)); err != ErrShutdownTimeout {
+		t.Errorf("Shutdown = %v; want ErrShutdownTimeout", err)
+	}
+	if err := srv.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if err := <-errc; err == nil {
+		t.Error("request survived Close; want error")
+	}
+	// Close does not wait for handlers; let this one finish
+	// before the test returns.
+	close(release)
+	<-handlerDone
+}
+
+// Shutdown leaves a connection that has not sent a request open for
+// a grace period, so that a request already on its way is served,
+// and then closes it like an idle one.
+func TestServerShutdownStateNew(t *testing.T) {
+	defer afterTest(t)
+	defer SetNewConnGracePeriod(300 * time.Millisecond)()
+	newc := make(chan bool, 2)
+	srv := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			io.WriteString(w, "done")
+		}),
+		ConnState: func(c net.Conn, st ConnState) {
+			if st == StateNew {
+				newc <- true
+			}
+		},
+	}
+	url, _ := shutdownTestServer(t, srv)
+	addr := strings.TrimPrefix(url, "http://")
+	var conns [2]net.Conn
+	for i := range conns {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		conns[i] = c
+		<-newc
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(time.Time{}) }()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := io.WriteString(conns[0], "GET / HTTP/1.1\r\nHost: foo\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ReadResponse(bufio.NewReader(conns[0]), nil)
+	if err != nil {
+		t.Fatalf("request sent just after Shutdown: %v", err)
+	}
+	slurp, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || string(slurp) != "done" {
+		t.Errorf("request sent just after Shutdown = %q, %v; want \"done\", nil", slurp, err)
+	}
+
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for Shutdown to close the silent connection")
+	}
+	conns[1].SetReadDeadline(time.Now().Add(5 * time.Second))
+	if n, err := conns[1].Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read from silent connection after Shutdown = %d, %v; want io.EOF", n, err)
+	}
+}
+
+func TestServerCloseIdle(t *testing.T) {
+	defer afterTest(t)
+	var numClosed int32
+	srv := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {}),
+		ConnState: func(c net.Conn, state ConnState) {
+			if state == StateClosed {
+				atomic.AddInt32(&numClosed, 1)
+			}
+		},
+	}
+	url, serveErr := shutdownTestServer(t, srv)
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	res, err := (&Client{Transport: tr}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve = %v; want ErrServerClosed", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&numClosed) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection was not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // golang.org/issue/7856
 func TestServerEmptyBodyRace(t *testing.T) {
 	defer afterTest(t)
@@ -109,8 +109,43 @@ type CloseNotifier interface {
 	CloseNotify() <-chan bool
 }
 
+// The Pusher interface is implemented by the ResponseWriters of
+// HTTP/2 requests, which can push responses to the client before
+// it asks for them (HTTP/2 server push).
+type Pusher interface {
+	// Push promises the client a response to a request for
+	// target, and runs the server's handler for that request as
+	// if the client had sent it. The target is either an absolute
+	// path, such as "/static/app.js", or an absolute URL with the
+	// same scheme and host as the request being served.
+	//
+	// Push should be called before the response refers to the
+	// pushed resource, so that the client does not request it
+	// itself. It returns ErrNotSupported if the server's
+	// EnableH2Push is false or the client has disabled push.
+	Push(target string, opts *PushOptions) error
+}
+
+// PushOptions describes the request for a pushed response.
+type PushOptions struct {
+	// Method is the request method, "GET" or "HEAD".
+	// If empty, "GET" is used.
+	Method string
+
+	// Header holds additional request header fields. It may not
+	// contain Host, Content-Length or any header that concerns
+	// the connection or a request body.
+	Header Header
+}
+
 // A conn represents the server side of an HTTP connection.
 type conn struct {
+	// curState packs the ConnState of the connection into its low
+	// 8 bits and the Unix time in milliseconds when it entered that
+	// state into the rest. It is accessed atomically, and is first
+	// in the struct to be 64-bit aligned.
+	curState uint64
+
 	remoteAddr string               // network address of remote side
 	server     *Server              // the Server on which the connection arrived
 	rwc        net.Conn             // i/o connection
@@ -1115,11 +1150,31 @@ func validNPN(proto string) bool {
 }
 
 func (c *conn) setState(nc net.Conn, state ConnState) {
+	srv := c.server
+	switch state {
+	case StateNew:
+		srv.trackConn(c, nc, true)
+	case StateHijacked, StateClosed:
+		srv.trackConn(c, nc, false)
+	}
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	atomic.StoreUint64(&c.curState, uint64(ms)<<8|uint64(state))
 	if hook := c.server.ConnState; hook != nil {
 		hook(nc, state)
 	}
 }
 
+// getState returns the ConnState of the connection and when it
+// entered that state. The time is zero if the state was never set.
+func (c *conn) getState() (state ConnState, since time.Time) {
+	packed := atomic.LoadUint64(&c.curState)
+	state = ConnState(packed & 0xff)
+	if ms := int64(packed >> 8); ms != 0 {
+		since = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return state, since
+}
+
 // Serve a new connection.
 func (c *conn) serve() {
 	origConn := c.rwc // copy it before it's set nil on Close or Hijack
@@ -1149,6 +1204,10 @@ func (c *conn) serve() {
 		}
 		c.tlsState = new(tls.ConnectionState)
 		*c.tlsState = tlsConn.ConnectionState()
+		if c.tlsState.NegotiatedProtocol == http2NextProtoTLS && c.server.TLSNextProto == nil {
+			c.serveHTTP2()
+			return
+		}
 		if proto := c.tlsState.NegotiatedProtocol; validNPN(proto) {
 			if fn := c.server.TLSNextProto[proto]; fn != nil {
 				h := initNPNRequest{tlsConn, serverHandler{c.server}}
@@ -1158,6 +1217,11 @@ func (c *conn) serve() {
 		}
 	}
 
+	if c.tlsState == nil && c.server.EnableH2C && c.sawHTTP2Preface() {
+		c.serveHTTP2()
+		return
+	}
+
 	for {
 		w, err := c.readRequest()
 		if c.lr.N != c.server.initialLimitedReaderSize() {
@@ -1216,6 +1280,23 @@ func (c *conn) serve() {
 	}
 }
 
+// sawHTTP2Preface reports whether the client has begun the
+// connection with the HTTP/2 client preface, indicating it has prior
+// knowledge of our HTTP/2 support.
+func (c *conn) sawHTTP2Preface() bool {
+	if d := c.server.ReadTimeout; d != 0 {
+		c.rwc.SetReadDeadline(time.Now().Add(d))
+	}
+	// Every HTTP/1 request line is at least as long as "PRI", so
+	// peeking that far never blocks a valid HTTP/1 client.
+	br := c.buf.Reader
+	if p, err := br.Peek(3); err != nil || string(p) != http2ClientPreface[:3] {
+		return false
+	}
+	p, err := br.Peek(len(http2ClientPreface))
+	return err == nil && string(p) == http2ClientPreface
+}
+
 func (w *response) sendExpectationFailed() {
 	// TODO(bradfitz): let ServeHTTP handlers handle
 	// requests with non-standard expectation[s]? Seems
@@ -1623,8 +1704,21 @@ type Server struct {
 	// handle HTTP requests and will initialize the Request's TLS
 	// and RemoteAddr if not already set.  The connection is
 	// automatically closed when the function returns.
+	// If TLSNextProto is nil, HTTP/2 support is enabled
+	// automatically for connections negotiating the "h2" protocol.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
+	// EnableH2C, if true, lets clients with prior knowledge of
+	// the server's HTTP/2 support speak HTTP/2 directly over
+	// unencrypted connections ("h2c"), by starting with the
+	// HTTP/2 connection preface. It is intended mainly for tests.
+	EnableH2C bool
+
+	// EnableH2Push, if true, lets handlers push responses to HTTP/2
+	// clients that accept them, through the Pusher interface.
+	// Server push is off by default.
+	EnableH2Push bool
+
 	// ConnState specifies an optional callback function that is
 	// called when a client connection changes state. See the
 	// ConnState type and associated constants for details.
@@ -1637,6 +1731,11 @@ type Server struct {
 	ErrorLog *log.Logger
 
 	disableKeepAlives int32 // accessed atomically.
+	inShutdown        int32 // accessed atomically; non-zero after Shutdown or Close
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]bool
+	activeConn map[*conn]net.Conn
 }
 
 // A ConnState represents the state of a client connection to a server.
@@ -1707,6 +1806,9 @@ func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
 // calls Serve to handle requests on incoming connections.  If
 // srv.Addr is blank, ":http" is used.
 func (srv *Server) ListenAndServe() error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 	addr := srv.Addr
 	if addr == "" {
 		addr = ":http"
@@ -1721,12 +1823,22 @@ func (srv *Server) ListenAndServe() error {
 // Serve accepts incoming connections on the Listener l, creating a
 // new service goroutine for each.  The service goroutines read requests and
 // then call srv.Handler to reply to them.
+//
+// Serve always returns a non-nil error. After Shutdown or Close, the
+// returned error is ErrServerClosed.
 func (srv *Server) Serve(l net.Listener) error {
 	defer l.Close()
+	if !srv.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(l, false)
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
 		rw, e := l.Accept()
 		if e != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
 			if ne, ok := e.(net.Error); ok && ne.Temporary() {
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
@@ -1752,8 +1864,164 @@ func (srv *Server) Serve(l net.Listener) error {
 	}
 }
 
+// ErrServerClosed is returned by the Server's Serve and ListenAndServe
+// methods after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("http: Server closed")
+
+// ErrShutdownTimeout is returned by Shutdown when its deadline passes
+// before all connections have been closed.
+var ErrShutdownTimeout = errors.New("http: Server shutdown timed out")
+
+// shutdownPollInterval is how often Shutdown polls for idle
+// connections while waiting for active ones to finish.
+const shutdownPollInterval = 500 * time.Millisecond
+
+// newConnGracePeriod is how long Shutdown treats a connection that
+// has not yet sent a request (StateNew) as active, since its first
+// request may be on its way. After that, it is closed like an idle one.
+var newConnGracePeriod = 5 * time.Second
+
+// Close immediately closes all listeners being served and all
+// connections in state StateNew, StateActive or StateIdle, without
+// waiting for in-flight requests to complete. For a graceful
+// shutdown, use Shutdown.
+//
+// Close does not attempt to close (and does not even know about)
+// any hijacked connections, such as WebSockets.
+//
+// Close returns any error returned from closing the Server's
+// underlying Listener(s).
+func (srv *Server) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	err := srv.closeListenersLocked()
+	for c, nc := range srv.activeConn {
+		nc.Close()
+		delete(srv.activeConn, c)
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the server without interrupting any
+// active connections. Shutdown works by first closing all listeners
+// being served, then closing all idle connections, and then waiting
+// indefinitely for connections to return to idle and then shut down.
+// A connection that has not yet sent a request counts as active for
+// a few seconds after it was accepted, so that a request already on
+// its way is served, and is then closed like an idle one.
+// Keep-alives are disabled for the remainder of the shutdown so that
+// connections finishing a request are closed rather than reused.
+//
+// If deadline is non-zero and passes before every connection has
+// been closed, Shutdown returns ErrShutdownTimeout and leaves the
+// remaining connections open; a call to Close can then be used to
+// force them shut. Otherwise, Shutdown returns any error returned
+// from closing the Server's underlying Listener(s).
+//
+// Once Shutdown has been called, Serve and ListenAndServe
+// immediately return ErrServerClosed. Shutdown does not attempt to
+// close nor wait for hijacked connections such as WebSockets; the
+// caller of Hijack is responsible for those.
+func (srv *Server) Shutdown(deadline time.Time) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	lnerr := srv.closeListenersLocked()
+	srv.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(deadline.Sub(time.Now()))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.closeIdleConns() {
+			return lnerr
+		}
+		select {
+		case <-timeout:
+			return ErrShutdownTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdleConns closes all idle connections and reports whether the
+// server is quiescent. Connections that have not yet read any bytes
+// of a request (StateNew) count as active until newConnGracePeriod
+// has passed since they were accepted.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quiescent := true
+	for c, nc := range s.activeConn {
+		st, since := c.getState()
+		if st == StateNew && !since.IsZero() && time.Since(since) >= newConnGracePeriod {
+			st = StateIdle
+		}
+		if st != StateIdle || since.IsZero() {
+			quiescent = false
+			continue
+		}
+		nc.Close()
+		delete(s.activeConn, c)
+	}
+	return quiescent
+}
+
+func (s *Server) closeListenersLocked() error {
+	var err error
+	for ln := range s.listeners {
+		if cerr := ln.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.listeners, ln)
+	}
+	return err
+}
+
+// trackListener adds or removes ln from the set of tracked
+// listeners. It reports false if ln is being added after the
+// server has begun shutting down.
+func (s *Server) trackListener(ln net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]bool)
+		}
+		s.listeners[ln] = true
+	} else {
+		delete(s.listeners, ln)
+	}
+	return true
+}
+
+func (s *Server) trackConn(c *conn, nc net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.activeConn == nil {
+			s.activeConn = make(map[*conn]net.Conn)
+		}
+		s.activeConn[c] = nc
+	} else {
+		delete(s.activeConn, c)
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&s.inShutdown) != 0
+}
+
 func (s *Server) doKeepAlives() bool {
-	return atomic.LoadInt32(&s.disableKeepAlives) == 0
+	return atomic.LoadInt32(&s.disableKeepAlives) == 0 && !s.shuttingDown()
 }
 
 // SetKeepAlivesEnabled controls whether HTTP keep-alives are enabled.
@@ -1849,8 +2117,14 @@ func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Han
 // certificate authority, the certFile should be the concatenation
 // of the server's certificate followed by the CA's certificate.
 //
+// Unless srv.TLSConfig.NextProtos or srv.TLSNextProto is set, the
+// server offers HTTP/2 ("h2") in addition to HTTP/1.1.
+//
 // If srv.Addr is blank, ":https" is used.
 func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 	addr := srv.Addr
 	if addr == "" {
 		addr = ":https"
@@ -1861,6 +2135,9 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	}
 	if config.NextProtos == nil {
 		config.NextProtos = []string{"http/1.1"}
+		if srv.TLSNextProto == nil {
+			config.NextProtos = []string{http2NextProtoTLS, "http/1.1"}
+		}
 	}
 
 	var err error
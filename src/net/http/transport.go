@@ -58,6 +58,9 @@ type Transport struct {
 	altMu    sync.RWMutex
 	altProto map[string]RoundTripper // nil or map of URI scheme => RoundTripper
 
+	h2mu    sync.Mutex
+	h2conns map[connectMethodKey]*http2clientConn
+
 	// Proxy specifies a function to return a proxy for a given
 	// Request. If the function returns a non-nil error, the
 	// request is aborted with the provided error.
@@ -113,6 +116,15 @@ type Transport struct {
 	// time does not include the time to read the response body.
 	ResponseHeaderTimeout time.Duration
 
+	// EnableH2C, if true, makes the Transport speak HTTP/2 on
+	// cleartext ("http") connections that don't go through a
+	// proxy, assuming prior knowledge that the server supports it.
+	// It is mainly useful for tests; see Server.EnableH2C.
+	//
+	// HTTP/2 is always used for "https" requests if the server
+	// selects it during the TLS handshake.
+	EnableH2C bool
+
 	// TODO: tunable on global max cached connections
 	// TODO: tunable on timeout on cached connections
 }
@@ -221,6 +233,15 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 		return nil, err
 	}
 
+	// An HTTP/2 connection to the host, if we have one, can carry
+	// any number of requests at once.
+	if cc := t.getHTTP2Conn(cm.key()); cc != nil {
+		resp, err := cc.roundTrip(treq)
+		if err != http2errClientConnUnusable {
+			return resp, err
+		}
+	}
+
 	// Get the cached or newly-created connection to either the
 	// host (for http or https), the http proxy, or the http proxy
 	// pre-CONNECTed to https server.  In any case, we'll be ready
@@ -232,6 +253,9 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 		return nil, err
 	}
 
+	if pconn.alt != nil {
+		return pconn.alt.roundTrip(treq)
+	}
 	return pconn.roundTrip(treq)
 }
 
@@ -272,6 +296,7 @@ func (t *Transport) CloseIdleConnections() {
 			pconn.close()
 		}
 	}
+	t.closeIdleHTTP2Conns()
 }
 
 // CancelRequest cancels an in-flight request by closing its
@@ -358,6 +383,14 @@ func (cm *connectMethod) proxyAuth() string {
 // If pconn is no longer needed or not in a good state, putIdleConn
 // returns false.
 func (t *Transport) putIdleConn(pconn *persistConn) bool {
+	if pconn.alt != nil {
+		// HTTP/2 connections are pooled by addHTTP2Conn. One it
+		// declined to pool has no other user.
+		if t.getHTTP2Conn(pconn.cacheKey) != pconn.alt {
+			pconn.alt.closeWithError(nil)
+		}
+		return false
+	}
 	if t.DisableKeepAlives || t.MaxIdleConnsPerHost < 0 {
 		pconn.close()
 		return false
@@ -624,6 +657,12 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 				cfg = &clone
 			}
 		}
+		if cfg.NextProtos == nil {
+			// Offer HTTP/2 via ALPN.
+			clone := *cfg
+			clone.NextProtos = []string{http2NextProtoTLS, "http/1.1"}
+			cfg = &clone
+		}
 		plainConn := pconn.conn
 		tlsConn := tls.Client(plainConn, cfg)
 		errc := make(chan error, 2)
@@ -655,6 +694,13 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 		pconn.conn = tlsConn
 	}
 
+	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol == http2NextProtoTLS ||
+		s == nil && t.EnableH2C && cm.targetScheme == "http" && cm.proxyURL == nil {
+		cc := t.newHTTP2ClientConn(pconn.cacheKey, pconn.conn, pconn.tlsState)
+		t.addHTTP2Conn(cc)
+		return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: cc}, nil
+	}
+
 	pconn.br = bufio.NewReader(noteEOFReader{pconn.conn, &pconn.sawEOF})
 	pconn.bw = bufio.NewWriter(pconn.conn)
 	go pconn.readLoop()
@@ -795,6 +841,7 @@ type persistConn struct {
 	writech  chan writeRequest   // written by roundTrip; read by writeLoop
 	closech  chan struct{}       // closed when conn closed
 	isProxy  bool
+	alt      *http2clientConn // if non-nil, an HTTP/2 connection used instead
 	// writeErrCh passes the request write error (usually nil)
 	// from the writeLoop goroutine to the readLoop which passes
 	// it off to the res.Body reader, which then uses it to decide
@@ -37,6 +37,7 @@
 package os
 
 import (
+	"internal/testlog"
 	"io"
 	"syscall"
 )
@@ -212,6 +213,7 @@ func Mkdir(name string, perm FileMode) error {
 // Chdir changes the current working directory to the named directory.
 // If there is an error, it will be of type *PathError.
 func Chdir(dir string) error {
+	testlog.Chdir(dir)
 	if e := syscall.Chdir(dir); e != nil {
 		return &PathError{"chdir", dir, e}
 	}
@@ -236,6 +238,7 @@ func (f *File) Chdir() error {
 // descriptor has mode O_RDONLY.
 // If there is an error, it will be of type *PathError.
 func Open(name string) (file *File, err error) {
+	testlog.Open(name)
 	return OpenFile(name, O_RDONLY, 0)
 }
 
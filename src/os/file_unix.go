@@ -7,6 +7,7 @@
 package os
 
 import (
+	"internal/testlog"
 	"runtime"
 	"sync/atomic"
 	"syscall"
@@ -130,6 +131,7 @@ func (f *File) Stat() (fi FileInfo, err error) {
 // Stat returns a FileInfo describing the named file.
 // If there is an error, it will be of type *PathError.
 func Stat(name string) (fi FileInfo, err error) {
+	testlog.Stat(name)
 	var stat syscall.Stat_t
 	err = syscall.Stat(name, &stat)
 	if err != nil {
@@ -143,6 +145,7 @@ func Stat(name string) (fi FileInfo, err error) {
 // describes the symbolic link.  Lstat makes no attempt to follow the link.
 // If there is an error, it will be of type *PathError.
 func Lstat(name string) (fi FileInfo, err error) {
+	testlog.Stat(name)
 	var stat syscall.Stat_t
 	err = syscall.Lstat(name, &stat)
 	if err != nil {
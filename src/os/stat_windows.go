@@ -5,6 +5,7 @@
 package os
 
 import (
+	"internal/testlog"
 	"syscall"
 	"unsafe"
 )
@@ -49,6 +50,7 @@ func (file *File) Stat() (fi FileInfo, err error) {
 // Stat returns a FileInfo structure describing the named file.
 // If there is an error, it will be of type *PathError.
 func Stat(name string) (fi FileInfo, err error) {
+	testlog.Stat(name)
 	for {
 		fi, err = Lstat(name)
 		if err != nil {
@@ -70,6 +72,7 @@ func Stat(name string) (fi FileInfo, err error) {
 // describes the symbolic link.  Lstat makes no attempt to follow the link.
 // If there is an error, it will be of type *PathError.
 func Lstat(name string) (fi FileInfo, err error) {
+	testlog.Stat(name)
 	if len(name) == 0 {
 		return nil, &PathError{"Lstat", name, syscall.Errno(syscall.ERROR_PATH_NOT_FOUND)}
 	}
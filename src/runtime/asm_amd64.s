@@ -2248,3 +2248,13 @@ TEXT runtime·prefetchnta(SB),NOSPLIT,$0-8
 	MOVQ	addr+0(FP), AX
 	PREFETCHNTA	(AX)
 	RET
+
+// addmoduledata adds the moduledata in DI to the module list.
+// It is called from the .init_array of a program linked with
+// -linkshared, before the runtime starts, so it must obey the
+// gcc calling convention.
+TEXT runtime·addmoduledata(SB),NOSPLIT,$0-0
+	MOVQ	runtime·lastmoduledatap(SB), AX
+	MOVQ	DI, moduledata_next(AX)
+	MOVQ	DI, runtime·lastmoduledatap(SB)
+	RET
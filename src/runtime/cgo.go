@@ -14,10 +14,12 @@ import "unsafe"
 //go:linkname _cgo_malloc _cgo_malloc
 //go:linkname _cgo_free _cgo_free
 //go:linkname _cgo_thread_start _cgo_thread_start
+//go:linkname _cgo_sys_thread_create _cgo_sys_thread_create
 
 var (
-	_cgo_init         unsafe.Pointer
-	_cgo_malloc       unsafe.Pointer
-	_cgo_free         unsafe.Pointer
-	_cgo_thread_start unsafe.Pointer
+	_cgo_init              unsafe.Pointer
+	_cgo_malloc            unsafe.Pointer
+	_cgo_free              unsafe.Pointer
+	_cgo_thread_start      unsafe.Pointer
+	_cgo_sys_thread_create unsafe.Pointer
 )
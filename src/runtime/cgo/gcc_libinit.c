@@ -0,0 +1,27 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+#include <pthread.h>
+#include <string.h> // strerror
+#include "libcgo.h"
+
+/*
+ * Start a thread running fn(arg) without involving the Go scheduler.
+ * A C archive or shared library initializes the Go runtime on such a
+ * thread, so that loading it does not wait for the package initializers.
+ */
+void
+x_cgo_sys_thread_create(void* (*fn)(void*), void* arg)
+{
+	pthread_t p;
+	int err;
+
+	err = pthread_create(&p, NULL, fn, arg);
+	if (err != 0) {
+		fatalf("pthread_create failed: %s", strerror(err));
+	}
+	pthread_detach(p);
+}
@@ -47,6 +47,14 @@
 // handoff using atomic operations.  The operations are needed, however,
 // in order to let the log closer set the high bit to indicate "EOF" safely
 // in the situation when normally the goroutine "owns" handoff.
+//
+// Each stack trace is recorded together with the profiler labels of the
+// goroutine that was running, as set by runtime/pprof.  Traces with the same
+// stack but different labels are kept in separate entries.  The labels are
+// heap objects, and the cpuProfile is not, so the label pointers live in a
+// parallel cpuProfileTags structure in the heap where the garbage collector
+// can find them.  The tag of an entry or log record is stored at the same
+// index as the entry or at the same offset as the record's first word.
 
 package runtime
 
@@ -94,9 +102,19 @@ type cpuProfile struct {
 	eodSent  bool // special end-of-data record sent; => flushing
 }
 
+// cpuProfileTags holds the profiler labels of the entries in the
+// cpuProfile's hash table and of the records in its log.
+// The arrays are allocated separately: a single object holding
+// them all would be too large for the collector's type masks.
+type cpuProfileTags struct {
+	hash [][assoc]unsafe.Pointer // [numBuckets]
+	log  [2][]unsafe.Pointer     // [2][logSize / 2]
+}
+
 var (
 	cpuprofLock mutex
 	cpuprof     *cpuProfile
+	cpuproftags *cpuProfileTags
 
 	eod = [3]uintptr{0, 1, 0}
 )
@@ -137,6 +155,13 @@ func SetCPUProfileRate(hz int) {
 				unlock(&cpuprofLock)
 				return
 			}
+			cpuproftags = &cpuProfileTags{
+				hash: make([][assoc]unsafe.Pointer, numBuckets),
+				log: [2][]unsafe.Pointer{
+					make([]unsafe.Pointer, logSize/2),
+					make([]unsafe.Pointer, logSize/2),
+				},
+			}
 		}
 		if cpuprof.on || cpuprof.handoff != 0 {
 			print("runtime: cannot set cpu profile rate until previous profile has finished.\n")
@@ -153,6 +178,7 @@ func SetCPUProfileRate(hz int) {
 		p[2] = 0                 // version number
 		p[3] = uintptr(1e6 / hz) // period (microseconds)
 		p[4] = 0
+		cpuproftags.log[0][0] = nil
 		cpuprof.nlog = 5
 		cpuprof.toggle = 0
 		cpuprof.wholding = false
@@ -185,12 +211,25 @@ func SetCPUProfileRate(hz int) {
 	unlock(&cpuprofLock)
 }
 
-func cpuproftick(pc *uintptr, n int32) {
+func cpuproftick(pc *uintptr, n int32, gp *g) {
 	if n > maxCPUProfStack {
 		n = maxCPUProfStack
 	}
 	s := (*[maxCPUProfStack]uintptr)(unsafe.Pointer(pc))[:n]
-	cpuprof.add(s)
+	var tag unsafe.Pointer
+	if gp != nil {
+		tag = gp.labels
+	}
+	cpuprof.add(s, tag)
+}
+
+// settag stores tag in *dst without a write barrier, which the
+// signal handler cannot execute.  The labels remain reachable from
+// the goroutine that set them at least until the next collection,
+// by which time the collector finds them through cpuproftags.
+//go:nosplit
+func settag(dst *unsafe.Pointer, tag unsafe.Pointer) {
+	*(*uintptr)(unsafe.Pointer(dst)) = uintptr(tag)
 }
 
 // add adds the stack trace to the profile.
@@ -198,21 +237,23 @@ func cpuproftick(pc *uintptr, n int32) {
 // and cannot allocate memory or acquire locks that might be
 // held at the time of the signal, nor can it use substantial amounts
 // of stack.  It is allowed to call evict.
-func (p *cpuProfile) add(pc []uintptr) {
+func (p *cpuProfile) add(pc []uintptr, tag unsafe.Pointer) {
 	// Compute hash.
 	h := uintptr(0)
 	for _, x := range pc {
 		h = h<<8 | (h >> (8 * (unsafe.Sizeof(h) - 1)))
 		h += x*31 + x*7 + x*3
 	}
+	h += uintptr(tag) * 41
 	p.count++
 
 	// Add to entry count if already present in table.
 	b := &p.hash[h%numBuckets]
+	tags := &cpuproftags.hash[h%numBuckets]
 Assoc:
 	for i := range b.entry {
 		e := &b.entry[i]
-		if e.depth != uintptr(len(pc)) {
+		if e.depth != uintptr(len(pc)) || tags[i] != tag {
 			continue
 		}
 		for j := range pc {
@@ -226,13 +267,15 @@ Assoc:
 
 	// Evict entry with smallest count.
 	var e *cpuprofEntry
+	var etag *unsafe.Pointer
 	for i := range b.entry {
 		if e == nil || b.entry[i].count < e.count {
 			e = &b.entry[i]
+			etag = &tags[i]
 		}
 	}
 	if e.count > 0 {
-		if !p.evict(e) {
+		if !p.evict(e, *etag) {
 			// Could not evict entry.  Record lost stack.
 			p.lost++
 			return
@@ -244,15 +287,16 @@ Assoc:
 	e.depth = uintptr(len(pc))
 	e.count = 1
 	copy(e.stack[:], pc)
+	settag(etag, tag)
 }
 
-// evict copies the given entry's data into the log, so that
+// evict copies the given entry's data and tag into the log, so that
 // the entry can be reused.  evict is called from add, which
 // is called from the profiling signal handler, so it must not
 // allocate memory or block.  It is safe to call flushlog.
 // evict returns true if the entry was copied to the log,
 // false if there was no room available.
-func (p *cpuProfile) evict(e *cpuprofEntry) bool {
+func (p *cpuProfile) evict(e *cpuprofEntry, tag unsafe.Pointer) bool {
 	d := e.depth
 	nslot := d + 2
 	log := &p.log[p.toggle]
@@ -264,6 +308,7 @@ func (p *cpuProfile) evict(e *cpuprofEntry) bool {
 	}
 
 	q := p.nlog
+	settag(&cpuproftags.log[p.toggle][q], tag)
 	log[q] = e.count
 	q++
 	log[q] = d
@@ -290,6 +335,7 @@ func (p *cpuProfile) flushlog() bool {
 	q := uintptr(0)
 	if p.lost > 0 {
 		lostPC := funcPC(lostProfileData)
+		settag(&cpuproftags.log[p.toggle][0], nil)
 		log[0] = p.lost
 		log[1] = 1
 		log[2] = lostPC
@@ -301,10 +347,11 @@ func (p *cpuProfile) flushlog() bool {
 }
 
 // getprofile blocks until the next block of profiling data is available
-// and returns it as a []byte.  It is called from the writing goroutine.
-func (p *cpuProfile) getprofile() []byte {
+// and returns it as a []byte, along with the tags of the records in it,
+// indexed by word offset.  It is called from the writing goroutine.
+func (p *cpuProfile) getprofile() ([]byte, []unsafe.Pointer) {
 	if p == nil {
-		return nil
+		return nil, nil
 	}
 
 	if p.wholding {
@@ -314,7 +361,7 @@ func (p *cpuProfile) getprofile() []byte {
 			n := p.handoff
 			if n == 0 {
 				print("runtime: phase error during cpu profile handoff\n")
-				return nil
+				return nil, nil
 			}
 			if n&0x80000000 != 0 {
 				p.wtoggle = 1 - p.wtoggle
@@ -335,7 +382,7 @@ func (p *cpuProfile) getprofile() []byte {
 	}
 
 	if !p.on && p.handoff == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// Wait for new log.
@@ -345,7 +392,7 @@ func (p *cpuProfile) getprofile() []byte {
 	switch n := p.handoff; {
 	case n == 0:
 		print("runtime: phase error during cpu profile wait\n")
-		return nil
+		return nil, nil
 	case n == 0x80000000:
 		p.flushing = true
 		goto Flush
@@ -355,7 +402,7 @@ func (p *cpuProfile) getprofile() []byte {
 		// Return new log to caller.
 		p.wholding = true
 
-		return uintptrBytes(p.log[p.wtoggle][:n])
+		return uintptrBytes(p.log[p.wtoggle][:n]), cpuproftags.log[p.wtoggle][:n]
 	}
 
 	// In flush mode.
@@ -367,7 +414,7 @@ Flush:
 		b := &p.hash[i]
 		for j := range b.entry {
 			e := &b.entry[j]
-			if e.count > 0 && !p.evict(e) {
+			if e.count > 0 && !p.evict(e, cpuproftags.hash[i][j]) {
 				// Filled the log.  Stop the loop and return what we've got.
 				break Flush
 			}
@@ -380,7 +427,7 @@ Flush:
 		// because we're working on the log directly.
 		n := p.nlog
 		p.nlog = 0
-		return uintptrBytes(p.log[p.toggle][:n])
+		return uintptrBytes(p.log[p.toggle][:n]), cpuproftags.log[p.toggle][:n]
 	}
 
 	// Made it through the table without finding anything to log.
@@ -388,7 +435,7 @@ Flush:
 		// We may not have space to append this to the partial log buf,
 		// so we always return a new slice for the end-of-data marker.
 		p.eodSent = true
-		return uintptrBytes(eod[:])
+		return uintptrBytes(eod[:]), nil
 	}
 
 	// Finally done.  Clean up and return nil.
@@ -396,7 +443,7 @@ Flush:
 	if !cas(&p.handoff, p.handoff, 0) {
 		print("runtime: profile flush racing with something\n")
 	}
-	return nil
+	return nil, nil
 }
 
 func uintptrBytes(p []uintptr) (ret []byte) {
@@ -419,5 +466,19 @@ func uintptrBytes(p []uintptr) (ret []byte) {
 // the testing package's -test.cpuprofile flag instead of calling
 // CPUProfile directly.
 func CPUProfile() []byte {
+	data, _ := cpuprof.getprofile()
+	return data
+}
+
+// readProfile is like CPUProfile but also returns the profiler labels
+// of the records in the data: tags[i] holds the labels of the record
+// that begins at word i.  It is called by runtime/pprof.
+func readProfile() (data []byte, tags []unsafe.Pointer) {
 	return cpuprof.getprofile()
 }
+
+// setProfLabel sets the profiler labels of the current goroutine.
+// It is called by runtime/pprof, which owns their representation.
+func setProfLabel(labels unsafe.Pointer) {
+	getg().labels = labels
+}
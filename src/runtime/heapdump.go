@@ -414,18 +414,22 @@ func finq_callback(fn *funcval, obj unsafe.Pointer, nret uintptr, fint *_type, o
 
 func dumproots() {
 	// data segment
-	dumpbvtypes(&gcdatamask, unsafe.Pointer(&data))
-	dumpint(tagData)
-	dumpint(uint64(uintptr(unsafe.Pointer(&data))))
-	dumpmemrange(unsafe.Pointer(&data), uintptr(unsafe.Pointer(&edata))-uintptr(unsafe.Pointer(&data)))
-	dumpfields(gcdatamask)
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		dumpbvtypes(&datap.gcdatamask, unsafe.Pointer(datap.data))
+		dumpint(tagData)
+		dumpint(uint64(datap.data))
+		dumpmemrange(unsafe.Pointer(datap.data), datap.edata-datap.data)
+		dumpfields(datap.gcdatamask)
+	}
 
 	// bss segment
-	dumpbvtypes(&gcbssmask, unsafe.Pointer(&bss))
-	dumpint(tagBSS)
-	dumpint(uint64(uintptr(unsafe.Pointer(&bss))))
-	dumpmemrange(unsafe.Pointer(&bss), uintptr(unsafe.Pointer(&ebss))-uintptr(unsafe.Pointer(&bss)))
-	dumpfields(gcbssmask)
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		dumpbvtypes(&datap.gcbssmask, unsafe.Pointer(datap.bss))
+		dumpint(tagBSS)
+		dumpint(uint64(datap.bss))
+		dumpmemrange(unsafe.Pointer(datap.bss), datap.ebss-datap.bss)
+		dumpfields(datap.gcbssmask)
+	}
 
 	// MSpan.types
 	allspans := h_allspans
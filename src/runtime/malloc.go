@@ -458,7 +458,7 @@ func gogc(force int32) {
 	releasem(mp)
 	mp = nil
 
-	semacquire(&worldsema, false)
+	semacquire(&worldsema, 0)
 
 	if force == 0 && memstats.heap_alloc < memstats.next_gc {
 		// typically threads which lost the race to grab
@@ -467,6 +467,10 @@ func gogc(force int32) {
 		return
 	}
 
+	if trace.enabled {
+		traceGCStart()
+	}
+
 	// Ok, we're doing it!  Stop everybody else
 	startTime := nanotime()
 	mp = acquirem()
@@ -518,6 +522,11 @@ func gogc(force int32) {
 	})
 
 	// all done
+	if trace.enabled {
+		// Emit the event while holding worldsema,
+		// so that it precedes the next GCStart.
+		traceGCDone()
+	}
 	mp.gcing = 0
 	semrelease(&worldsema)
 	systemstack(starttheworld)
@@ -627,11 +636,13 @@ func SetFinalizer(obj interface{}, finalizer interface{}) {
 		// The relevant segments are: noptrdata, data, bss, noptrbss.
 		// We cannot assume they are in any order or even contiguous,
 		// due to external linking.
-		if uintptr(unsafe.Pointer(&noptrdata)) <= uintptr(e.data) && uintptr(e.data) < uintptr(unsafe.Pointer(&enoptrdata)) ||
-			uintptr(unsafe.Pointer(&data)) <= uintptr(e.data) && uintptr(e.data) < uintptr(unsafe.Pointer(&edata)) ||
-			uintptr(unsafe.Pointer(&bss)) <= uintptr(e.data) && uintptr(e.data) < uintptr(unsafe.Pointer(&ebss)) ||
-			uintptr(unsafe.Pointer(&noptrbss)) <= uintptr(e.data) && uintptr(e.data) < uintptr(unsafe.Pointer(&enoptrbss)) {
-			return
+		for datap := &firstmoduledata; datap != nil; datap = datap.next {
+			if datap.noptrdata <= uintptr(e.data) && uintptr(e.data) < datap.enoptrdata ||
+				datap.data <= uintptr(e.data) && uintptr(e.data) < datap.edata ||
+				datap.bss <= uintptr(e.data) && uintptr(e.data) < datap.ebss ||
+				datap.noptrbss <= uintptr(e.data) && uintptr(e.data) < datap.enoptrbss {
+				return
+			}
 		}
 		gothrow("runtime.SetFinalizer: pointer not in allocated block")
 	}
@@ -772,7 +783,7 @@ func runfinq() {
 			fing = gp
 			fingwait = true
 			gp.issystem = true
-			goparkunlock(&finlock, "finalizer wait")
+			goparkunlock(&finlock, "finalizer wait", traceEvGoBlock, 1)
 			gp.issystem = false
 			continue
 		}
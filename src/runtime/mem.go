@@ -79,7 +79,7 @@ func ReadMemStats(m *MemStats) {
 	// because stoptheworld can only be used by
 	// one goroutine at a time, and there might be
 	// a pending garbage collection already calling it.
-	semacquire(&worldsema, false)
+	semacquire(&worldsema, 0)
 	gp := getg()
 	gp.m.gcing = 1
 	systemstack(stoptheworld)
@@ -97,7 +97,7 @@ func ReadMemStats(m *MemStats) {
 
 // Implementation of runtime/debug.WriteHeapDump
 func writeHeapDump(fd uintptr) {
-	semacquire(&worldsema, false)
+	semacquire(&worldsema, 0)
 	gp := getg()
 	gp.m.gcing = 1
 	systemstack(stoptheworld)
@@ -168,7 +168,7 @@ type workbuf struct {
 	obj  [(_WorkbufSize - unsafe.Sizeof(lfnode{}) - ptrSize) / ptrSize]uintptr
 }
 
-var data, edata, bss, ebss, gcdata, gcbss struct{}
+var data, edata, bss, ebss struct{}
 
 var finlock mutex  // protects the following variables
 var fing *g        // goroutine that runs finalizers
@@ -179,9 +179,6 @@ var fingwait bool
 var fingwake bool
 var allfin *finblock // list of all blocks
 
-var gcdatamask bitvector
-var gcbssmask bitvector
-
 var gclock mutex
 
 var badblock [1024]uintptr
@@ -619,10 +616,14 @@ func markroot(desc *parfor, i uint32) {
 	// Note: if you add a case here, please also update heapdump.c:dumproots.
 	switch i {
 	case _RootData:
-		scanblock(uintptr(unsafe.Pointer(&data)), uintptr(unsafe.Pointer(&edata))-uintptr(unsafe.Pointer(&data)), gcdatamask.bytedata)
+		for datap := &firstmoduledata; datap != nil; datap = datap.next {
+			scanblock(datap.data, datap.edata-datap.data, datap.gcdatamask.bytedata)
+		}
 
 	case _RootBss:
-		scanblock(uintptr(unsafe.Pointer(&bss)), uintptr(unsafe.Pointer(&ebss))-uintptr(unsafe.Pointer(&bss)), gcbssmask.bytedata)
+		for datap := &firstmoduledata; datap != nil; datap = datap.next {
+			scanblock(datap.bss, datap.ebss-datap.bss, datap.gcbssmask.bytedata)
+		}
 
 	case _RootFinalizers:
 		for fb := allfin; fb != nil; fb = fb.alllink {
@@ -1553,8 +1554,10 @@ func gcinit() {
 
 	work.markfor = parforalloc(_MaxGcproc)
 	gcpercent = readgogc()
-	gcdatamask = unrollglobgcprog((*byte)(unsafe.Pointer(&gcdata)), uintptr(unsafe.Pointer(&edata))-uintptr(unsafe.Pointer(&data)))
-	gcbssmask = unrollglobgcprog((*byte)(unsafe.Pointer(&gcbss)), uintptr(unsafe.Pointer(&ebss))-uintptr(unsafe.Pointer(&bss)))
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		datap.gcdatamask = unrollglobgcprog((*byte)(unsafe.Pointer(datap.gcdata)), datap.edata-datap.data)
+		datap.gcbssmask = unrollglobgcprog((*byte)(unsafe.Pointer(datap.gcbss)), datap.ebss-datap.bss)
+	}
 }
 
 // Called from malloc.go using onM, stopping and starting the world handled in caller.
@@ -1921,6 +1924,9 @@ func gc(start_time int64, eagersweep bool) {
 	// conservatively set next_gc to high value assuming that everything is live
 	// concurrent/lazy sweep will reduce this number while discovering new garbage
 	memstats.next_gc = memstats.heap_alloc + memstats.heap_alloc*uint64(gcpercent)/100
+	if trace.enabled {
+		traceNextGC()
+	}
 
 	t4 := nanotime()
 	atomicstore64(&memstats.last_gc, uint64(unixnanotime())) // must be Unix time to make sense to user
@@ -2341,30 +2347,33 @@ func getgcmask(p unsafe.Pointer, t *_type, mask **byte, len *uintptr) {
 	*mask = nil
 	*len = 0
 
-	// data
-	if uintptr(unsafe.Pointer(&data)) <= uintptr(p) && uintptr(p) < uintptr(unsafe.Pointer(&edata)) {
-		n := (*ptrtype)(unsafe.Pointer(t)).elem.size
-		*len = n / ptrSize
-		*mask = &make([]byte, *len)[0]
-		for i := uintptr(0); i < n; i += ptrSize {
-			off := (uintptr(p) + i - uintptr(unsafe.Pointer(&data))) / ptrSize
-			bits := (*(*byte)(add(unsafe.Pointer(gcdatamask.bytedata), off/pointersPerByte)) >> ((off % pointersPerByte) * bitsPerPointer)) & bitsMask
-			*(*byte)(add(unsafe.Pointer(*mask), i/ptrSize)) = bits
+	// data or bss
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		// data
+		if datap.data <= uintptr(p) && uintptr(p) < datap.edata {
+			n := (*ptrtype)(unsafe.Pointer(t)).elem.size
+			*len = n / ptrSize
+			*mask = &make([]byte, *len)[0]
+			for i := uintptr(0); i < n; i += ptrSize {
+				off := (uintptr(p) + i - datap.data) / ptrSize
+				bits := (*(*byte)(add(unsafe.Pointer(datap.gcdatamask.bytedata), off/pointersPerByte)) >> ((off % pointersPerByte) * bitsPerPointer)) & bitsMask
+				*(*byte)(add(unsafe.Pointer(*mask), i/ptrSize)) = bits
+			}
+			return
 		}
-		return
-	}
 
-	// bss
-	if uintptr(unsafe.Pointer(&bss)) <= uintptr(p) && uintptr(p) < uintptr(unsafe.Pointer(&ebss)) {
-		n := (*ptrtype)(unsafe.Pointer(t)).elem.size
-		*len = n / ptrSize
-		*mask = &make([]byte, *len)[0]
-		for i := uintptr(0); i < n; i += ptrSize {
-			off := (uintptr(p) + i - uintptr(unsafe.Pointer(&bss))) / ptrSize
-			bits := (*(*byte)(add(unsafe.Pointer(gcbssmask.bytedata), off/pointersPerByte)) >> ((off % pointersPerByte) * bitsPerPointer)) & bitsMask
-			*(*byte)(add(unsafe.Pointer(*mask), i/ptrSize)) = bits
+		// bss
+		if datap.bss <= uintptr(p) && uintptr(p) < datap.ebss {
+			n := (*ptrtype)(unsafe.Pointer(t)).elem.size
+			*len = n / ptrSize
+			*mask = &make([]byte, *len)[0]
+			for i := uintptr(0); i < n; i += ptrSize {
+				off := (uintptr(p) + i - datap.bss) / ptrSize
+				bits := (*(*byte)(add(unsafe.Pointer(datap.gcbssmask.bytedata), off/pointersPerByte)) >> ((off % pointersPerByte) * bitsPerPointer)) & bitsMask
+				*(*byte)(add(unsafe.Pointer(*mask), i/ptrSize)) = bits
+			}
+			return
 		}
-		return
 	}
 
 	// heap
@@ -217,6 +217,9 @@ func mHeap_Alloc_m(h *mheap, npage uintptr, sizeclass int32, large bool) *mspan
 			}
 		}
 	}
+	if trace.enabled {
+		traceHeapAlloc()
+	}
 	unlock(&h.lock)
 	return s
 }
@@ -439,6 +442,9 @@ func mHeap_Free(h *mheap, s *mspan, acct int32) {
 			memstats.heap_alloc -= uint64(s.npages << _PageShift)
 			memstats.heap_objects--
 		}
+		if trace.enabled {
+			traceHeapAlloc()
+		}
 		mHeap_FreeSpanLocked(h, s, true, true)
 		unlock(&h.lock)
 	})
@@ -0,0 +1,93 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"context"
+	"sort"
+)
+
+type label struct {
+	key   string
+	value string
+}
+
+// A LabelSet is a set of profiler labels.
+type LabelSet struct {
+	list []label
+}
+
+// labelContextKey is the type of the context key for profiler labels.
+type labelContextKey struct{}
+
+// labelMap is the representation of the label set held in the context
+// and attached to goroutines.
+type labelMap map[string]string
+
+func labelValue(ctx context.Context) labelMap {
+	labels, _ := ctx.Value(labelContextKey{}).(*labelMap)
+	if labels == nil {
+		return nil
+	}
+	return *labels
+}
+
+// WithLabels returns a new context.Context with the given labels added.
+// A label overwrites a prior label with the same key.
+func WithLabels(ctx context.Context, labels LabelSet) context.Context {
+	parent := labelValue(ctx)
+	m := make(labelMap, len(parent)+len(labels.list))
+	for k, v := range parent {
+		m[k] = v
+	}
+	for _, l := range labels.list {
+		m[l.key] = l.value
+	}
+	return context.WithValue(ctx, labelContextKey{}, &m)
+}
+
+// Labels takes an even number of strings representing key-value pairs
+// and makes a LabelSet containing them.
+// A label overwrites a prior label with the same key.
+// Labels panics if given an odd number of strings.
+func Labels(args ...string) LabelSet {
+	if len(args)%2 != 0 {
+		panic("uneven number of arguments to pprof.Labels")
+	}
+	labels := LabelSet{}
+	for i := 0; i+1 < len(args); i += 2 {
+		labels.list = append(labels.list, label{key: args[i], value: args[i+1]})
+	}
+	return labels
+}
+
+// Label returns the value of the label with the given key on ctx,
+// and a boolean indicating whether that label exists.
+func Label(ctx context.Context, key string) (string, bool) {
+	v, ok := labelValue(ctx)[key]
+	return v, ok
+}
+
+// ForLabels invokes f with each label set on the context, in key order.
+// The function f should return true to continue iteration or false
+// to stop iteration early.
+func ForLabels(ctx context.Context, f func(key, value string) bool) {
+	labels := labelValue(ctx)
+	for _, k := range labels.keys() {
+		if !f(k, labels[k]) {
+			break
+		}
+	}
+}
+
+// keys returns the keys of m in sorted order.
+func (m labelMap) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
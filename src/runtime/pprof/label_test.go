@@ -0,0 +1,71 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof_test
+
+import (
+	"context"
+	"reflect"
+	. "runtime/pprof"
+	"testing"
+)
+
+func labelsOf(ctx context.Context) []string {
+	var kv []string
+	ForLabels(ctx, func(key, value string) bool {
+		kv = append(kv, key, value)
+		return true
+	})
+	return kv
+}
+
+func TestContextLabels(t *testing.T) {
+	ctx := context.Background()
+	if v, ok := Label(ctx, "key"); ok {
+		t.Errorf(`Label(ctx, "key") = %q, true on an empty context`, v)
+	}
+
+	ctx = WithLabels(ctx, Labels("key", "value", "other", "x"))
+	if v, ok := Label(ctx, "key"); !ok || v != "value" {
+		t.Errorf(`Label(ctx, "key") = %q, %v, want "value", true`, v, ok)
+	}
+
+	// Labels are listed in key order, and a new label
+	// replaces an old one with the same key.
+	inner := WithLabels(ctx, Labels("key", "new", "a", "b"))
+	if got, want := labelsOf(inner), []string{"a", "b", "key", "new", "other", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %q, want %q", got, want)
+	}
+	if got, want := labelsOf(ctx), []string{"key", "value", "other", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outer labels changed to %q, want %q", got, want)
+	}
+
+	// Returning false stops the iteration.
+	n := 0
+	ForLabels(inner, func(key, value string) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("ForLabels called f %d times after it returned false, want 1", n)
+	}
+}
+
+func TestDo(t *testing.T) {
+	ctx := WithLabels(context.Background(), Labels("outer", "1"))
+	Do(ctx, Labels("inner", "2"), func(ctx context.Context) {
+		if got, want := labelsOf(ctx), []string{"inner", "2", "outer", "1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("labels in Do = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestLabelsOddArgs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Labels with an odd number of arguments did not panic")
+		}
+	}()
+	Labels("key")
+}
@@ -13,11 +13,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
+	"unsafe"
 )
 
 // BUG(rsc): Profiles are incomplete and inaccurate on NetBSD and OS X.
@@ -37,6 +40,7 @@ import (
 //	heap         - a sampling of all heap allocations
 //	threadcreate - stack traces that led to the creation of new OS threads
 //	block        - stack traces that led to blocking on synchronization primitives
+//	mutex        - stack traces of holders of contended mutexes
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -83,6 +87,12 @@ var blockProfile = &Profile{
 	write: writeBlock,
 }
 
+var mutexProfile = &Profile{
+	name:  "mutex",
+	count: countMutex,
+	write: writeMutex,
+}
+
 func lockProfiles() {
 	profiles.mu.Lock()
 	if profiles.m == nil {
@@ -92,6 +102,7 @@ func lockProfiles() {
 			"threadcreate": threadcreateProfile,
 			"heap":         heapProfile,
 			"block":        blockProfile,
+			"mutex":        mutexProfile,
 		}
 	}
 }
@@ -213,9 +224,12 @@ func (p *Profile) Remove(value interface{}) {
 // Otherwise, WriteTo returns nil.
 //
 // The debug parameter enables additional output.
-// Passing debug=0 prints only the hexadecimal addresses that pprof needs.
-// Passing debug=1 adds comments translating addresses to function names
-// and line numbers, so that a programmer can read the profile without tools.
+// Passing debug=0 writes the gzip-compressed protocol buffer described
+// in profile.proto in the pprof sources, with the function names and
+// line numbers needed to read the profile without the program's binary.
+// Passing debug=1 writes the legacy text format with comments translating
+// addresses to function names and line numbers, so that a programmer
+// can read the profile without tools.
 //
 // The predefined profiles may assign meaning to other debug values;
 // for example, when printing the "goroutine" profile, debug=2 means to
@@ -268,7 +282,12 @@ type countProfile interface {
 }
 
 // printCountProfile prints a countProfile at the specified debug level.
+// The profile will be in compressed proto format unless debug is nonzero.
 func printCountProfile(w io.Writer, debug int, name string, p countProfile) error {
+	if debug == 0 {
+		return writeCountProfileProto(w, name, p)
+	}
+
 	b := bufio.NewWriter(w)
 	var tw *tabwriter.Writer
 	w = b
@@ -314,6 +333,47 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 	return b.Flush()
 }
 
+// writeCountProfileProto writes p in compressed proto format,
+// with one sample for each unique stack holding the number of
+// times the stack appears in p.
+func writeCountProfileProto(w io.Writer, name string, p countProfile) error {
+	b := newProfileBuilder(w)
+	b.pbValueType(tagProfile_PeriodType, name, "count")
+	b.pb.int64Opt(tagProfile_Period, 1)
+	b.pbValueType(tagProfile_SampleType, name, "count")
+
+	// Count each unique stack, in order of first occurrence.
+	var buf bytes.Buffer
+	index := map[string]int{}
+	var stks [][]uintptr
+	var counts []int64
+	n := p.Len()
+	for i := 0; i < n; i++ {
+		stk := p.Stack(i)
+		buf.Reset()
+		for _, pc := range stk {
+			fmt.Fprintf(&buf, " %#x", pc)
+		}
+		j, ok := index[buf.String()]
+		if !ok {
+			j = len(stks)
+			index[buf.String()] = j
+			stks = append(stks, stk)
+			counts = append(counts, 0)
+		}
+		counts[j]++
+	}
+
+	values := []int64{0}
+	var locs []uint64
+	for i, stk := range stks {
+		values[0] = counts[i]
+		locs = b.appendLocsForStack(locs[:0], stk)
+		b.pbSample(values, locs, nil)
+	}
+	return b.build()
+}
+
 // printStackRecord prints the function + source line information
 // for a single stack trace.
 func printStackRecord(w io.Writer, stk []uintptr, allFrames bool) {
@@ -366,6 +426,7 @@ func (x byInUseBytes) Less(i, j int) bool { return x[i].InUseBytes() > x[j].InUs
 
 // WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0).
 // It is preserved for backwards compatibility.
+// The profile is written in compressed proto format.
 func WriteHeapProfile(w io.Writer) error {
 	return writeHeap(w, 0)
 }
@@ -401,6 +462,10 @@ func writeHeap(w io.Writer, debug int) error {
 
 	sort.Sort(byInUseBytes(p))
 
+	if debug == 0 {
+		return writeHeapProto(w, p, int64(runtime.MemProfileRate))
+	}
+
 	b := bufio.NewWriter(w)
 	var tw *tabwriter.Writer
 	w = b
@@ -478,6 +543,72 @@ func writeHeap(w io.Writer, debug int) error {
 	return b.Flush()
 }
 
+// writeHeapProto writes the heap profile records p in compressed
+// proto format. Each record carries the allocated and in-use object
+// counts and sizes, scaled to estimate the true totals from the
+// samples taken at the given rate.
+func writeHeapProto(w io.Writer, p []runtime.MemProfileRecord, rate int64) error {
+	b := newProfileBuilder(w)
+	b.pbValueType(tagProfile_PeriodType, "space", "bytes")
+	b.pb.int64Opt(tagProfile_Period, rate)
+	b.pbValueType(tagProfile_SampleType, "alloc_objects", "count")
+	b.pbValueType(tagProfile_SampleType, "alloc_space", "bytes")
+	b.pbValueType(tagProfile_SampleType, "inuse_objects", "count")
+	b.pbValueType(tagProfile_SampleType, "inuse_space", "bytes")
+
+	values := []int64{0, 0, 0, 0}
+	var locs []uint64
+	for i := range p {
+		r := &p[i]
+		stk := r.Stack()
+
+		// Hide the runtime functions at the top of the stack,
+		// which are the allocator's own, unless there is nothing else.
+		for j, pc := range stk {
+			if f := runtime.FuncForPC(pc - 1); f == nil || !strings.HasPrefix(f.Name(), "runtime.") {
+				stk = stk[j:]
+				break
+			}
+		}
+		locs = b.appendLocsForStack(locs[:0], stk)
+
+		values[0], values[1] = scaleHeapSample(r.AllocObjects, r.AllocBytes, rate)
+		values[2], values[3] = scaleHeapSample(r.InUseObjects(), r.InUseBytes(), rate)
+		var blockSize int64
+		if r.AllocObjects > 0 {
+			blockSize = r.AllocBytes / r.AllocObjects
+		}
+		b.pbSample(values, locs, func() {
+			if blockSize != 0 {
+				b.pbLabel(tagSample_Label, "bytes", "", blockSize)
+			}
+		})
+	}
+	return b.build()
+}
+
+// scaleHeapSample adjusts the data from a heap sample to account for
+// its probability of appearing in the collected data. Heap profiles are
+// a sampling of the memory allocations: an allocation of s bytes is
+// sampled with probability 1-exp(-s/rate), so each sample stands for
+// 1/(1-exp(-s/rate)) allocations of its size.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+
+	if rate <= 1 {
+		// if rate==1 all samples were collected so no adjustment is needed.
+		// if rate<1 treat as unknown and skip scaling.
+		return count, size
+	}
+
+	avgSize := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avgSize/float64(rate)))
+
+	return int64(float64(count) * scale), int64(float64(size) * scale)
+}
+
 // countThreadCreate returns the size of the current ThreadCreateProfile.
 func countThreadCreate() int {
 	n, _ := runtime.ThreadCreateProfile(nil)
@@ -560,7 +691,8 @@ var cpu struct {
 }
 
 // StartCPUProfile enables CPU profiling for the current process.
-// While profiling, the profile will be buffered and written to w.
+// While profiling, the profile will be buffered and written to w
+// in compressed proto format when StopCPUProfile is called.
 // StartCPUProfile returns an error if profiling is already enabled.
 func StartCPUProfile(w io.Writer) error {
 	// The runtime routines allow a variable profiling rate,
@@ -589,17 +721,106 @@ func StartCPUProfile(w io.Writer) error {
 	return nil
 }
 
+// profileWriter collects the profile data from the runtime until
+// profiling stops and then writes it to w.
 func profileWriter(w io.Writer) {
+	start := time.Now()
+	var data []uintptr
+	var tags []*labelMap
 	for {
-		data := runtime.CPUProfile()
-		if data == nil {
+		chunk, chunkTags := runtime_readProfile()
+		if chunk == nil {
 			break
 		}
-		w.Write(data)
+		// The runtime hands out the profile as a sequence
+		// of machine words, reusing its buffers; copy them.
+		// Holding on to the tags keeps their labels alive.
+		n := len(chunk) / int(unsafe.Sizeof(uintptr(0)))
+		if n > 0 {
+			data = append(data, (*[1 << 26]uintptr)(unsafe.Pointer(&chunk[0]))[:n:n]...)
+		}
+		for i := 0; i < n; i++ {
+			var tag *labelMap
+			if i < len(chunkTags) {
+				tag = (*labelMap)(chunkTags[i])
+			}
+			tags = append(tags, tag)
+		}
 	}
+	writeCPUProfile(w, start, data, tags)
 	cpu.done <- true
 }
 
+// writeCPUProfile writes the raw profile data collected by the
+// runtime between start and now to w in compressed proto format.
+//
+// The data begins with the header words 0, 3, 0, period, 0,
+// where period is the sampling period in microseconds.
+// Each sample that follows is a count n, a stack depth d
+// and d program counters; the leaf's is the interrupted PC
+// and the others are return PCs. The data ends with 0, 1, 0.
+//
+// If tags is not nil, tags[i] holds the labels of the goroutines
+// sampled in the record that begins at data[i].
+func writeCPUProfile(w io.Writer, start time.Time, data []uintptr, tags []*labelMap) error {
+	b := newProfileBuilder(w)
+	b.start = start
+
+	var period int64
+	if len(data) >= 5 && data[0] == 0 && data[1] == 3 {
+		period = int64(data[3]) * 1000
+		data = data[5:]
+		if tags != nil {
+			tags = tags[5:]
+		}
+	}
+	b.pbValueType(tagProfile_PeriodType, "cpu", "nanoseconds")
+	b.pb.int64Opt(tagProfile_Period, period)
+	b.pbValueType(tagProfile_SampleType, "samples", "count")
+	b.pbValueType(tagProfile_SampleType, "cpu", "nanoseconds")
+
+	values := []int64{0, 0}
+	var locs []uint64
+	for len(data) >= 2 {
+		count, d := data[0], data[1]
+		if uintptr(len(data)) < 2+d || count == 0 {
+			// End-of-data marker or truncated record.
+			break
+		}
+		stk := data[2 : 2+d]
+		data = data[2+d:]
+		var labels labelMap
+		if tags != nil {
+			if tags[0] != nil {
+				labels = *tags[0]
+			}
+			tags = tags[2+d:]
+		}
+
+		values[0] = int64(count)
+		values[1] = int64(count) * period
+		locs = locs[:0]
+		for i, addr := range stk {
+			// Every PC but the leaf's is a return PC,
+			// which is what locForPC expects.
+			if i == 0 {
+				addr++
+			}
+			if l := b.locForPC(addr); l != 0 {
+				locs = append(locs, l)
+			}
+		}
+		b.pbSample(values, locs, func() {
+			for _, k := range labels.keys() {
+				b.pbLabel(tagSample_Label, k, labels[k], 0)
+			}
+		})
+	}
+
+	b.pb.int64Opt(tagProfile_DurationNanos, time.Since(start).Nanoseconds())
+	return b.build()
+}
+
 // StopCPUProfile stops the current CPU profile, if any.
 // StopCPUProfile only returns after all the writes for the
 // profile have completed.
@@ -627,13 +848,33 @@ func countBlock() int {
 	return n
 }
 
+// countMutex returns the number of records in the mutex profile.
+func countMutex() int {
+	n, _ := runtime.MutexProfile(nil)
+	return n
+}
+
 // writeBlock writes the current blocking profile to w.
 func writeBlock(w io.Writer, debug int) error {
+	return writeContention(w, debug, "contention", runtime.BlockProfile, 1)
+}
+
+// writeMutex writes the current mutex profile to w.
+func writeMutex(w io.Writer, debug int) error {
+	// The runtime records on average one contention event in
+	// every period; scale the records up by that factor.
+	period := int64(runtime.SetMutexProfileFraction(-1))
+	return writeContention(w, debug, "mutex", runtime.MutexProfile, period)
+}
+
+// writeContention writes the contention profile returned by fetch to w.
+// The profile holds one sample of every period events.
+func writeContention(w io.Writer, debug int, name string, fetch func([]runtime.BlockProfileRecord) (int, bool), period int64) error {
 	var p []runtime.BlockProfileRecord
-	n, ok := runtime.BlockProfile(nil)
+	n, ok := fetch(nil)
 	for {
 		p = make([]runtime.BlockProfileRecord, n+50)
-		n, ok = runtime.BlockProfile(p)
+		n, ok = fetch(p)
 		if ok {
 			p = p[:n]
 			break
@@ -642,6 +883,10 @@ func writeBlock(w io.Writer, debug int) error {
 
 	sort.Sort(byCycles(p))
 
+	if debug == 0 {
+		return writeContentionProto(w, p, period)
+	}
+
 	b := bufio.NewWriter(w)
 	var tw *tabwriter.Writer
 	w = b
@@ -650,8 +895,11 @@ func writeBlock(w io.Writer, debug int) error {
 		w = tw
 	}
 
-	fmt.Fprintf(w, "--- contention:\n")
+	fmt.Fprintf(w, "--- %v:\n", name)
 	fmt.Fprintf(w, "cycles/second=%v\n", runtime_cyclesPerSecond())
+	if period > 1 {
+		fmt.Fprintf(w, "sampling period=%d\n", period)
+	}
 	for i := range p {
 		r := &p[i]
 		fmt.Fprintf(w, "%v %v @", r.Cycles, r.Count)
@@ -670,4 +918,30 @@ func writeBlock(w io.Writer, debug int) error {
 	return b.Flush()
 }
 
+// writeContentionProto writes the contention profile records p in
+// compressed proto format, with the number of contentions and the
+// total delay in nanoseconds for each stack, each scaled by period.
+func writeContentionProto(w io.Writer, p []runtime.BlockProfileRecord, period int64) error {
+	if period < 1 {
+		period = 1
+	}
+	b := newProfileBuilder(w)
+	b.pbValueType(tagProfile_PeriodType, "contentions", "count")
+	b.pb.int64Opt(tagProfile_Period, period)
+	b.pbValueType(tagProfile_SampleType, "contentions", "count")
+	b.pbValueType(tagProfile_SampleType, "delay", "nanoseconds")
+
+	cpuGHz := float64(runtime_cyclesPerSecond()) / 1e9
+	values := []int64{0, 0}
+	var locs []uint64
+	for i := range p {
+		r := &p[i]
+		values[0] = r.Count * period
+		values[1] = int64(float64(r.Cycles)/cpuGHz) * period
+		locs = b.appendLocsForStack(locs[:0], r.Stack())
+		b.pbSample(values, locs, nil)
+	}
+	return b.build()
+}
+
 func runtime_cyclesPerSecond() int64
@@ -8,7 +8,9 @@ package pprof_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"internal/pprof/profile"
 	"math/big"
 	"os/exec"
 	"regexp"
@@ -18,7 +20,6 @@ import (
 	"sync"
 	"testing"
 	"time"
-	"unsafe"
 )
 
 func cpuHogger(f func()) {
@@ -84,40 +85,91 @@ func TestCPUProfileMultithreaded(t *testing.T) {
 	})
 }
 
-func parseProfile(t *testing.T, bytes []byte, f func(uintptr, []uintptr)) {
-	// Convert []byte to []uintptr.
-	l := len(bytes) / int(unsafe.Sizeof(uintptr(0)))
-	val := *(*[]uintptr)(unsafe.Pointer(&bytes))
-	val = val[:l]
+func TestCPUProfileLabel(t *testing.T) {
+	if runtime.GOOS == "plan9" {
+		t.Skip("skipping on plan9")
+	}
+	var prof bytes.Buffer
+	if err := StartCPUProfile(&prof); err != nil {
+		t.Fatal(err)
+	}
+	Do(context.Background(), Labels("key", "value"), func(context.Context) {
+		// The hog running in the new goroutine checks
+		// that labels are inherited by child goroutines.
+		c := make(chan int)
+		go func() {
+			cpuHogger(cpuHog1)
+			c <- 1
+		}()
+		<-c
+	})
+	cpuHogger(cpuHog2)
+	StopCPUProfile()
 
-	// 5 for the header, 2 for the per-sample header on at least one sample, 3 for the trailer.
-	if l < 5+2+3 {
-		t.Logf("profile too short: %#x", val)
+	labeled := map[string]uintptr{}
+	var total uintptr
+	p, err := profile.Parse(&prof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range p.Sample {
+		for _, loc := range s.Location {
+			fn := funcName(loc)
+			if fn != "runtime/pprof_test.cpuHog1" && fn != "runtime/pprof_test.cpuHog2" {
+				continue
+			}
+			total++
+			if got := s.Label["key"]; len(got) == 1 && got[0] == "value" {
+				labeled[fn]++
+			} else if len(got) != 0 {
+				t.Errorf("%s: labels = %v, want key:value or none", fn, s.Label)
+			}
+		}
+	}
+	if total == 0 {
 		if badOS[runtime.GOOS] {
 			t.Skipf("ignoring failure on %s; see golang.org/issue/6047", runtime.GOOS)
-			return
 		}
-		t.FailNow()
+		t.Fatal("no CPU profile samples collected")
 	}
-
-	hd, val, tl := val[:5], val[5:l-3], val[l-3:]
-	if hd[0] != 0 || hd[1] != 3 || hd[2] != 0 || hd[3] != 1e6/100 || hd[4] != 0 {
-		t.Fatalf("unexpected header %#x", hd)
+	if labeled["runtime/pprof_test.cpuHog1"] == 0 {
+		t.Errorf("no labeled samples in cpuHog1; labeled = %v", labeled)
 	}
-
-	if tl[0] != 0 || tl[1] != 1 || tl[2] != 0 {
-		t.Fatalf("malformed end-of-data marker %#x", tl)
+	if labeled["runtime/pprof_test.cpuHog2"] != 0 {
+		t.Errorf("cpuHog2 ran outside Do but has labeled samples; labeled = %v", labeled)
 	}
+}
 
-	for len(val) > 0 {
-		if len(val) < 2 || val[0] < 1 || val[1] < 1 || uintptr(len(val)) < 2+val[1] {
-			t.Fatalf("malformed profile.  leftover: %#x", val)
+func parseProfile(t *testing.T, valBytes []byte, f func(uintptr, []*profile.Location)) {
+	p, err := profile.Parse(bytes.NewReader(valBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PeriodType == nil || p.PeriodType.Type != "cpu" || p.Period != 1e9/100 {
+		t.Fatalf("unexpected period %v %v", p.PeriodType, p.Period)
+	}
+	if len(p.Sample) == 0 {
+		t.Logf("profile has no samples")
+		if badOS[runtime.GOOS] {
+			t.Skipf("ignoring failure on %s; see golang.org/issue/6047", runtime.GOOS)
+			return
 		}
-		f(val[0], val[2:2+val[1]])
-		val = val[2+val[1]:]
+		t.FailNow()
+	}
+	for _, sample := range p.Sample {
+		count := uintptr(sample.Value[0])
+		f(count, sample.Location)
 	}
 }
 
+// funcName returns the name of the function at loc, or "" if unknown.
+func funcName(loc *profile.Location) string {
+	if len(loc.Line) == 0 || loc.Line[0].Function == nil {
+		return ""
+	}
+	return loc.Line[0].Function.Name
+}
+
 func testCPUProfile(t *testing.T, need []string, f func()) {
 	switch runtime.GOOS {
 	case "darwin":
@@ -141,14 +193,14 @@ func testCPUProfile(t *testing.T, need []string, f func()) {
 
 	// Check that profile is well formed and contains need.
 	have := make([]uintptr, len(need))
-	parseProfile(t, prof.Bytes(), func(count uintptr, stk []uintptr) {
-		for _, pc := range stk {
-			f := runtime.FuncForPC(pc)
-			if f == nil {
+	parseProfile(t, prof.Bytes(), func(count uintptr, stk []*profile.Location) {
+		for _, loc := range stk {
+			fn := funcName(loc)
+			if fn == "" {
 				continue
 			}
 			for i, name := range need {
-				if strings.Contains(f.Name(), name) {
+				if strings.Contains(fn, name) {
 					have[i] += count
 				}
 			}
@@ -244,28 +296,26 @@ func TestGoroutineSwitch(t *testing.T) {
 
 		// Read profile to look for entries for runtime.gogo with an attempt at a traceback.
 		// The special entry
-		parseProfile(t, prof.Bytes(), func(count uintptr, stk []uintptr) {
+		parseProfile(t, prof.Bytes(), func(count uintptr, stk []*profile.Location) {
 			// An entry with two frames with 'System' in its top frame
 			// exists to record a PC without a traceback. Those are okay.
 			if len(stk) == 2 {
-				f := runtime.FuncForPC(stk[1])
-				if f != nil && (f.Name() == "runtime._System" || f.Name() == "runtime._ExternalCode" || f.Name() == "runtime._GC") {
+				name := funcName(stk[1])
+				if name == "runtime._System" || name == "runtime._ExternalCode" || name == "runtime._GC" {
 					return
 				}
 			}
 
 			// Otherwise, should not see runtime.gogo.
 			// The place we'd see it would be the inner most frame.
-			f := runtime.FuncForPC(stk[0])
-			if f != nil && f.Name() == "runtime.gogo" {
+			if funcName(stk[0]) == "runtime.gogo" {
 				var buf bytes.Buffer
-				for _, pc := range stk {
-					f := runtime.FuncForPC(pc)
-					if f == nil {
-						fmt.Fprintf(&buf, "%#x ?:0\n", pc)
+				for _, loc := range stk {
+					if len(loc.Line) == 0 || loc.Line[0].Function == nil {
+						fmt.Fprintf(&buf, "%#x ?:0\n", loc.Address)
 					} else {
-						file, line := f.FileLine(pc)
-						fmt.Fprintf(&buf, "%#x %s:%d\n", pc, file, line)
+						ln := loc.Line[0]
+						fmt.Fprintf(&buf, "%#x %s:%d\n", loc.Address, ln.Function.Filename, ln.Line)
 					}
 				}
 				t.Fatalf("found profile entry for runtime.gogo:\n%s", buf.String())
@@ -437,6 +487,70 @@ func blockMutex() {
 	mu.Lock()
 }
 
+func TestMutexProfile(t *testing.T) {
+	old := runtime.SetMutexProfileFraction(1)
+	defer runtime.SetMutexProfileFraction(old)
+	if old != 0 {
+		t.Fatalf("need MutexProfileRate 0, got %d", old)
+	}
+
+	blockMutex()
+	blockRWMutex()
+
+	var w bytes.Buffer
+	Lookup("mutex").WriteTo(&w, 1)
+	prof := w.String()
+
+	if !strings.HasPrefix(prof, "--- mutex:\ncycles/second=") {
+		t.Fatalf("Bad profile header:\n%v", prof)
+	}
+
+	// The delays are charged to the goroutines that held the locks.
+	tests := []struct {
+		name string
+		re   string
+	}{
+		{"mutex", `
+[0-9]+ 1 @( 0x[0-9,a-f]+)+
+#	0x[0-9,a-f]+	sync\.\(\*Mutex\)\.Unlock\+0x[0-9,a-f]+	+.*/src/sync/mutex\.go:[0-9]+
+#	0x[0-9,a-f]+	runtime/pprof_test\.func·[0-9]+\+0x[0-9,a-f]+	.*/src/runtime/pprof/pprof_test.go:[0-9]+
+`},
+		{"rwmutex", `
+[0-9]+ 1 @( 0x[0-9,a-f]+)+
+#	0x[0-9,a-f]+	sync\.\(\*RWMutex\)\.Unlock\+0x[0-9,a-f]+	+.*/src/sync/rwmutex\.go:[0-9]+
+#	0x[0-9,a-f]+	runtime/pprof_test\.func·[0-9]+\+0x[0-9,a-f]+	.*/src/runtime/pprof/pprof_test.go:[0-9]+
+`},
+	}
+	for _, test := range tests {
+		if !regexp.MustCompile(test.re).MatchString(prof) {
+			t.Errorf("Bad %v entry, expect:\n%v\ngot:\n%v", test.name, test.re, prof)
+		}
+	}
+
+	// The delay is at least the time the holder kept the lock.
+	var p []runtime.BlockProfileRecord
+	n, _ := runtime.MutexProfile(nil)
+	p = make([]runtime.BlockProfileRecord, n+10)
+	n, _ = runtime.MutexProfile(p)
+	var cycles int64
+	for _, r := range p[:n] {
+		cycles += r.Cycles
+	}
+	if cycles == 0 {
+		t.Errorf("mutex profile records no delay")
+	}
+}
+
+func blockRWMutex() {
+	var mu sync.RWMutex
+	mu.Lock()
+	go func() {
+		time.Sleep(blockDelay)
+		mu.Unlock()
+	}()
+	mu.RLock()
+}
+
 func blockCond() {
 	var mu sync.Mutex
 	c := sync.NewCond(&mu)
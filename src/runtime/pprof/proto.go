@@ -0,0 +1,347 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// A profileBuilder writes a profile in the gzipped protocol buffer
+// format described by profile.proto in the pprof sources.
+//
+// The profile is encoded as it is built: locations and functions
+// are written the first time a sample refers to them, and the
+// mappings and string table are written at the end by build.
+// Every location is symbolized using the running binary's own
+// tables, so the profile can be read without the binary.
+type profileBuilder struct {
+	start time.Time
+	w     io.Writer
+	pb    protobuf
+
+	strings   []string
+	stringMap map[string]int
+	locs      map[uintptr]int // location ID by address
+	funcs     map[string]int  // function ID by name
+	mem       []memMap
+	fake      int // index of the mapping for addresses outside mem, or -1
+}
+
+// A memMap is an executable region of the process's address space.
+type memMap struct {
+	start, end uintptr
+	offset     uint64
+	file       string
+
+	used  bool // some location lies in the mapping
+	funcs bool // every location in the mapping has function information
+}
+
+const (
+	// message Profile
+	tagProfile_SampleType    = 1  // repeated ValueType
+	tagProfile_Sample        = 2  // repeated Sample
+	tagProfile_Mapping       = 3  // repeated Mapping
+	tagProfile_Location      = 4  // repeated Location
+	tagProfile_Function      = 5  // repeated Function
+	tagProfile_StringTable   = 6  // repeated string
+	tagProfile_DropFrames    = 7  // int64 (string table index)
+	tagProfile_KeepFrames    = 8  // int64 (string table index)
+	tagProfile_TimeNanos     = 9  // int64
+	tagProfile_DurationNanos = 10 // int64
+	tagProfile_PeriodType    = 11 // ValueType
+	tagProfile_Period        = 12 // int64
+
+	// message ValueType
+	tagValueType_Type = 1 // int64 (string table index)
+	tagValueType_Unit = 2 // int64 (string table index)
+
+	// message Sample
+	tagSample_Location = 1 // repeated uint64
+	tagSample_Value    = 2 // repeated int64
+	tagSample_Label    = 3 // repeated Label
+
+	// message Label
+	tagLabel_Key = 1 // int64 (string table index)
+	tagLabel_Str = 2 // int64 (string table index)
+	tagLabel_Num = 3 // int64
+
+	// message Mapping
+	tagMapping_ID              = 1  // uint64
+	tagMapping_Start           = 2  // uint64
+	tagMapping_Limit           = 3  // uint64
+	tagMapping_Offset          = 4  // uint64
+	tagMapping_Filename        = 5  // int64 (string table index)
+	tagMapping_BuildID         = 6  // int64 (string table index)
+	tagMapping_HasFunctions    = 7  // bool
+	tagMapping_HasFilenames    = 8  // bool
+	tagMapping_HasLineNumbers  = 9  // bool
+	tagMapping_HasInlineFrames = 10 // bool
+
+	// message Location
+	tagLocation_ID        = 1 // uint64
+	tagLocation_MappingID = 2 // uint64
+	tagLocation_Address   = 3 // uint64
+	tagLocation_Line      = 4 // repeated Line
+
+	// message Line
+	tagLine_FunctionID = 1 // uint64
+	tagLine_Line       = 2 // int64
+
+	// message Function
+	tagFunction_ID         = 1 // uint64
+	tagFunction_Name       = 2 // int64 (string table index)
+	tagFunction_SystemName = 3 // int64 (string table index)
+	tagFunction_Filename   = 4 // int64 (string table index)
+	tagFunction_StartLine  = 5 // int64
+)
+
+// newProfileBuilder returns a new profileBuilder.
+// The profile is written to w when build is called.
+func newProfileBuilder(w io.Writer) *profileBuilder {
+	b := &profileBuilder{
+		start:     time.Now(),
+		w:         w,
+		strings:   []string{""},
+		stringMap: map[string]int{"": 0},
+		locs:      map[uintptr]int{},
+		funcs:     map[string]int{},
+		fake:      -1,
+	}
+	b.readMapping()
+	return b
+}
+
+// stringIndex adds s to the string table if not already present
+// and returns the index of s in the string table.
+func (b *profileBuilder) stringIndex(s string) int64 {
+	id, ok := b.stringMap[s]
+	if !ok {
+		id = len(b.strings)
+		b.strings = append(b.strings, s)
+		b.stringMap[s] = id
+	}
+	return int64(id)
+}
+
+// pbValueType encodes a ValueType message to b.pb.
+func (b *profileBuilder) pbValueType(tag int, typ, unit string) {
+	start := b.pb.startMessage()
+	b.pb.int64(tagValueType_Type, b.stringIndex(typ))
+	b.pb.int64(tagValueType_Unit, b.stringIndex(unit))
+	b.pb.endMessage(tag, start)
+}
+
+// pbSample encodes a Sample message to b.pb.
+// The locations must already have been added with locForPC.
+// If labels is not nil, it is called to encode the sample's labels.
+func (b *profileBuilder) pbSample(values []int64, locs []uint64, labels func()) {
+	start := b.pb.startMessage()
+	b.pb.int64s(tagSample_Value, values)
+	b.pb.uint64s(tagSample_Location, locs)
+	if labels != nil {
+		labels()
+	}
+	b.pb.endMessage(tagProfile_Sample, start)
+}
+
+// pbLabel encodes a Label message to b.pb.
+func (b *profileBuilder) pbLabel(tag int, key, str string, num int64) {
+	start := b.pb.startMessage()
+	b.pb.int64Opt(tagLabel_Key, b.stringIndex(key))
+	b.pb.int64Opt(tagLabel_Str, b.stringIndex(str))
+	b.pb.int64Opt(tagLabel_Num, num)
+	b.pb.endMessage(tag, start)
+}
+
+// pbLine encodes a Line message to b.pb.
+func (b *profileBuilder) pbLine(tag int, funcID uint64, line int64) {
+	start := b.pb.startMessage()
+	b.pb.uint64Opt(tagLine_FunctionID, funcID)
+	b.pb.int64Opt(tagLine_Line, line)
+	b.pb.endMessage(tag, start)
+}
+
+// pbMapping encodes a Mapping message to b.pb.
+func (b *profileBuilder) pbMapping(tag int, id uint64, m *memMap) {
+	start := b.pb.startMessage()
+	b.pb.uint64Opt(tagMapping_ID, id)
+	b.pb.uint64Opt(tagMapping_Start, uint64(m.start))
+	b.pb.uint64Opt(tagMapping_Limit, uint64(m.end))
+	b.pb.uint64Opt(tagMapping_Offset, m.offset)
+	b.pb.int64Opt(tagMapping_Filename, b.stringIndex(m.file))
+	b.pb.boolOpt(tagMapping_HasFunctions, m.funcs)
+	b.pb.boolOpt(tagMapping_HasFilenames, m.funcs)
+	b.pb.boolOpt(tagMapping_HasLineNumbers, m.funcs)
+	b.pb.endMessage(tag, start)
+}
+
+// appendLocsForStack appends the location IDs for the return PCs
+// in stk to locs and returns the result.
+func (b *profileBuilder) appendLocsForStack(locs []uint64, stk []uintptr) []uint64 {
+	for _, addr := range stk {
+		if l := b.locForPC(addr); l != 0 {
+			locs = append(locs, l)
+		}
+	}
+	return locs
+}
+
+// locForPC returns the location ID for addr.
+// addr must be a return PC; the location describes the call before it.
+// locForPC returns 0 for runtime.goexit, which is not shown in profiles.
+func (b *profileBuilder) locForPC(addr uintptr) uint64 {
+	if id := b.locs[addr]; id != 0 {
+		return uint64(id)
+	}
+
+	f := runtime.FuncForPC(addr - 1)
+	if f != nil && f.Name() == "runtime.goexit" {
+		return 0
+	}
+
+	// Write the function before the location refers to it:
+	// messages cannot be nested inside one another's encoding.
+	var funcID uint64
+	var line int
+	if f != nil {
+		var file string
+		file, line = f.FileLine(addr - 1)
+		name := f.Name()
+		funcID = uint64(b.funcs[name])
+		if funcID == 0 {
+			funcID = uint64(len(b.funcs)) + 1
+			b.funcs[name] = int(funcID)
+			start := b.pb.startMessage()
+			b.pb.uint64Opt(tagFunction_ID, funcID)
+			b.pb.int64Opt(tagFunction_Name, b.stringIndex(name))
+			b.pb.int64Opt(tagFunction_SystemName, b.stringIndex(name))
+			b.pb.int64Opt(tagFunction_Filename, b.stringIndex(file))
+			b.pb.endMessage(tagProfile_Function, start)
+		}
+	}
+
+	mi := b.mappingFor(addr)
+	m := &b.mem[mi]
+	if !m.used {
+		m.used = true
+		m.funcs = true
+	}
+	if f == nil {
+		m.funcs = false
+	}
+
+	id := len(b.locs) + 1
+	b.locs[addr] = id
+	start := b.pb.startMessage()
+	b.pb.uint64Opt(tagLocation_ID, uint64(id))
+	b.pb.uint64Opt(tagLocation_MappingID, uint64(mi+1))
+	b.pb.uint64Opt(tagLocation_Address, uint64(addr))
+	if f != nil {
+		b.pbLine(tagLocation_Line, funcID, int64(line))
+	}
+	b.pb.endMessage(tagProfile_Location, start)
+	return uint64(id)
+}
+
+// mappingFor returns the index in b.mem of the mapping containing addr.
+// Addresses in no known mapping are assigned to a single catch-all mapping.
+func (b *profileBuilder) mappingFor(addr uintptr) int {
+	for i := range b.mem {
+		m := &b.mem[i]
+		if m.start <= addr && addr < m.end {
+			return i
+		}
+	}
+	if b.fake < 0 {
+		b.fake = len(b.mem)
+		b.mem = append(b.mem, memMap{})
+	}
+	return b.fake
+}
+
+// build completes the profile and writes it to the underlying writer.
+func (b *profileBuilder) build() error {
+	b.pb.int64Opt(tagProfile_TimeNanos, b.start.UnixNano())
+
+	for i := range b.mem {
+		if b.mem[i].used {
+			b.pbMapping(tagProfile_Mapping, uint64(i+1), &b.mem[i])
+		}
+	}
+
+	// The string table must come last:
+	// it gains entries while the rest of the profile is encoded.
+	for _, s := range b.strings {
+		b.pb.string(tagProfile_StringTable, s)
+	}
+
+	zw := gzip.NewWriter(b.w)
+	if _, err := zw.Write(b.pb.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readMapping records the executable mappings of the process,
+// as listed in /proc/self/maps. On systems without /proc, all
+// addresses fall in the catch-all mapping.
+func (b *profileBuilder) readMapping() {
+	data, _ := ioutil.ReadFile("/proc/self/maps")
+	b.mem = parseProcSelfMaps(data)
+}
+
+// parseProcSelfMaps parses the contents of /proc/self/maps and
+// returns the executable mappings backed by files.
+//
+// Each line has the form
+//
+//	start-end perms offset dev inode path
+//
+// for example
+//
+//	00400000-0040b000 r-xp 00000000 fd:01 41108 /bin/cat
+func parseProcSelfMaps(data []byte) []memMap {
+	var mem []memMap
+	for len(data) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+		f := bytes.Fields(line)
+		if len(f) < 6 {
+			continue
+		}
+		perm, file := f[1], string(bytes.Join(f[5:], []byte(" ")))
+		if len(perm) < 3 || perm[2] != 'x' || file == "" || file[0] == '[' {
+			continue
+		}
+		i := bytes.IndexByte(f[0], '-')
+		if i < 0 {
+			continue
+		}
+		start, err1 := strconv.ParseUint(string(f[0][:i]), 16, 64)
+		end, err2 := strconv.ParseUint(string(f[0][i+1:]), 16, 64)
+		offset, err3 := strconv.ParseUint(string(f[2]), 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		mem = append(mem, memMap{
+			start:  uintptr(start),
+			end:    uintptr(end),
+			offset: offset,
+			file:   file,
+		})
+	}
+	return mem
+}
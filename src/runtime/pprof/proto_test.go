@@ -0,0 +1,88 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof_test
+
+import (
+	"bytes"
+	"internal/pprof/profile"
+	"reflect"
+	"runtime"
+	. "runtime/pprof"
+	"testing"
+)
+
+func TestGoroutineProfileProto(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("profile.Parse: %v", err)
+	}
+	if len(p.SampleType) != 1 || p.SampleType[0].Type != "goroutine" || p.SampleType[0].Unit != "count" {
+		t.Fatalf("sample types = %v, want goroutine/count", p.SampleType)
+	}
+	found := false
+	for _, s := range p.Sample {
+		for _, l := range s.Location {
+			for _, ln := range l.Line {
+				if ln.Function.Name == "runtime/pprof_test.TestGoroutineProfileProto" {
+					found = true
+				}
+			}
+		}
+	}
+	if !found {
+		t.Errorf("profile does not contain the current goroutine:\n%v", p)
+	}
+}
+
+var protoMemSink []byte
+
+func TestHeapProfileProto(t *testing.T) {
+	oldRate := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() {
+		runtime.MemProfileRate = oldRate
+	}()
+	for i := 0; i < 100; i++ {
+		protoMemSink = make([]byte, 1024)
+	}
+	runtime.GC() // materialize stats
+
+	var buf bytes.Buffer
+	if err := WriteHeapProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("profile.Parse: %v", err)
+	}
+	var types []string
+	for _, st := range p.SampleType {
+		types = append(types, st.Type+"/"+st.Unit)
+	}
+	want := []string{"alloc_objects/count", "alloc_space/bytes", "inuse_objects/count", "inuse_space/bytes"}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("sample types = %v, want %v", types, want)
+	}
+	for _, s := range p.Sample {
+		if len(s.Location) == 0 || len(s.Location[0].Line) == 0 {
+			continue
+		}
+		if s.Location[0].Line[0].Function.Name != "runtime/pprof_test.TestHeapProfileProto" {
+			continue
+		}
+		if s.Value[0] < 100 || s.Value[1] < 100*1024 {
+			t.Errorf("allocations = %d objects, %d bytes; want at least 100, %d", s.Value[0], s.Value[1], 100*1024)
+		}
+		if got := s.NumLabel["bytes"]; len(got) != 1 || got[0] != 1024 {
+			t.Errorf("bytes label = %v, want [1024]", got)
+		}
+		return
+	}
+	t.Errorf("no sample for the allocations in TestHeapProfileProto:\n%v", p)
+}
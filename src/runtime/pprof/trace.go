@@ -0,0 +1,64 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+var tracing struct {
+	sync.Mutex
+	tracing bool
+	done    chan bool
+}
+
+// StartTrace enables tracing for the current process.
+// While tracing, the data will be buffered and written to w.
+// StartTrace returns an error if tracing is already enabled.
+// The trace can be analyzed with "go tool trace".
+func StartTrace(w io.Writer) error {
+	tracing.Lock()
+	defer tracing.Unlock()
+	if tracing.done == nil {
+		tracing.done = make(chan bool)
+	}
+	if tracing.tracing {
+		return fmt.Errorf("tracing is already enabled")
+	}
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	tracing.tracing = true
+	go traceWriter(w)
+	return nil
+}
+
+func traceWriter(w io.Writer) {
+	for {
+		data := runtime.ReadTrace()
+		if data == nil {
+			break
+		}
+		w.Write(data)
+	}
+	tracing.done <- true
+}
+
+// StopTrace stops the current tracing, if any.
+// StopTrace only returns after all the writes for the trace have completed.
+func StopTrace() {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	if !tracing.tracing {
+		return
+	}
+	tracing.tracing = false
+	runtime.StopTrace()
+	<-tracing.done
+}
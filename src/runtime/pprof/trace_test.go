@@ -0,0 +1,308 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !nacl
+
+package pprof_test
+
+import (
+	"bytes"
+	"internal/trace"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	. "runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func skipTraceTestsIfNeeded(t *testing.T) {
+	switch runtime.GOOS {
+	case "solaris":
+		t.Skip("skipping: solaris timer can go backwards")
+	}
+}
+
+func TestTraceStartStop(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+	buf := new(bytes.Buffer)
+	if err := StartTrace(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	StopTrace()
+	size := buf.Len()
+	if size == 0 {
+		t.Fatalf("trace is empty")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if size != buf.Len() {
+		t.Fatalf("trace writes after stop: %v -> %v", size, buf.Len())
+	}
+}
+
+func TestTraceDoubleStart(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+	StopTrace()
+	buf := new(bytes.Buffer)
+	if err := StartTrace(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	if err := StartTrace(buf); err == nil {
+		t.Fatalf("succeed to start tracing second time")
+	}
+	StopTrace()
+	StopTrace()
+}
+
+func TestTrace(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+	buf := new(bytes.Buffer)
+	if err := StartTrace(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	StopTrace()
+	_, err := trace.Parse(buf)
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+}
+
+func parseTrace(t *testing.T, r io.Reader) []*trace.Event {
+	events, err := trace.Parse(r)
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	gs := trace.GoroutineStats(events)
+	for goid := range gs {
+		// We don't do any particular checks on the result at the moment.
+		// But still check that RelatedGoroutines does not crash, hang, etc.
+		_ = trace.RelatedGoroutines(events, goid)
+	}
+	return events
+}
+
+// traceLoad runs goroutines that exercise the scheduler, channels,
+// synchronization, timers, the network poller and system calls.
+// It returns a function that waits for them to finish.
+func traceLoad(t *testing.T) func() {
+	var wg sync.WaitGroup
+	done := make(chan bool)
+
+	// Create a goroutine blocked before tracing.
+	wg.Add(1)
+	go func() {
+		<-done
+		wg.Done()
+	}()
+
+	// Create a goroutine blocked in syscall before tracing.
+	rp, wp, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		var tmp [1]byte
+		rp.Read(tmp[:])
+		<-done
+		wg.Done()
+	}()
+	time.Sleep(time.Millisecond) // give the goroutine above time to block
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	return func() {
+		procs := runtime.GOMAXPROCS(10)
+
+		go func() {
+			runtime.LockOSThread()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					runtime.Gosched()
+				}
+			}
+		}()
+
+		runtime.GC()
+		// Trigger GC from malloc.
+		for i := 0; i < 1e3; i++ {
+			_ = make([]byte, 1<<20)
+		}
+
+		// Create a bunch of busy goroutines to load all Ps.
+		for p := 0; p < 10; p++ {
+			wg.Add(1)
+			go func() {
+				// Do something useful.
+				tmp := make([]byte, 1<<16)
+				for i := range tmp {
+					tmp[i]++
+				}
+				_ = tmp
+				<-done
+				wg.Done()
+			}()
+		}
+
+		// Block in syscall.
+		wg.Add(1)
+		go func() {
+			var tmp [1]byte
+			rp.Read(tmp[:])
+			<-done
+			wg.Done()
+		}()
+
+		// Test timers.
+		timerDone := make(chan bool)
+		go func() {
+			time.Sleep(time.Millisecond)
+			timerDone <- true
+		}()
+		<-timerDone
+
+		// A bit of network.
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				t.Errorf("accept failed: %v", err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			var buf [1]byte
+			c.Write(buf[:])
+			c.Close()
+		}()
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		var tmp [1]byte
+		c.Read(tmp[:])
+		c.Close()
+
+		go func() {
+			runtime.Gosched()
+			select {}
+		}()
+
+		// Unblock helper goroutines and wait them to finish.
+		wp.Write(tmp[:])
+		wp.Write(tmp[:])
+		close(done)
+		wg.Wait()
+
+		runtime.GOMAXPROCS(procs)
+		ln.Close()
+		rp.Close()
+		wp.Close()
+	}
+}
+
+// Do a bunch of various stuff (timers, GC, network, etc) while tracing.
+func TestTraceStress(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+
+	run := traceLoad(t)
+	buf := new(bytes.Buffer)
+	if err := StartTrace(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	run()
+	StopTrace()
+	parseTrace(t, buf)
+}
+
+// Do a bunch of various stuff (timers, GC, network, etc) in a separate goroutine.
+// And concurrently with all that start/stop trace 3 times.
+func TestTraceStressStartStop(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	outerDone := make(chan bool)
+	go func() {
+		defer func() {
+			outerDone <- true
+		}()
+		run := traceLoad(t)
+		run()
+	}()
+	for i := 0; i < 3; i++ {
+		buf := new(bytes.Buffer)
+		if err := StartTrace(buf); err != nil {
+			t.Fatalf("failed to start tracing: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		StopTrace()
+		parseTrace(t, buf)
+	}
+	<-outerDone
+}
+
+func TestTraceSymbolize(t *testing.T) {
+	skipTraceTestsIfNeeded(t)
+	if runtime.GOOS == "nacl" || testing.Short() {
+		t.Skip("skipping: needs go tool addr2line")
+	}
+	buf := new(bytes.Buffer)
+	if err := StartTrace(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	runtime.GC()
+	done := make(chan bool)
+	go traceSymbolizeBlocked(done)
+	time.Sleep(10 * time.Millisecond)
+	done <- true
+	<-done
+	StopTrace()
+	events := parseTrace(t, buf)
+	if err := trace.Symbolize(events, os.Args[0]); err != nil {
+		t.Fatalf("failed to symbolize trace: %v", err)
+	}
+	want := []struct {
+		typ byte
+		fn  string
+	}{
+		{trace.EvGoCreate, "runtime/pprof_test.TestTraceSymbolize"},
+		{trace.EvGoStart, "runtime/pprof_test.traceSymbolizeBlocked"},
+		{trace.EvGoBlockRecv, "runtime/pprof_test.traceSymbolizeBlocked"},
+		{trace.EvGoUnblock, "runtime/pprof_test.TestTraceSymbolize"},
+		{trace.EvGCStart, "runtime.GC"},
+	}
+	for _, w := range want {
+		found := false
+	search:
+		for _, ev := range events {
+			if ev.Type != w.typ || len(ev.Stk) == 0 {
+				continue
+			}
+			for _, f := range ev.Stk {
+				if strings.HasSuffix(f.Fn, w.fn) {
+					found = true
+					break search
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no %v event with %v on the stack", trace.EventDescriptions[w.typ].Name, w.fn)
+		}
+	}
+	if t.Failed() {
+		trace.Print(events)
+	}
+}
+
+func traceSymbolizeBlocked(done chan bool) {
+	<-done
+	done <- true
+}
@@ -205,6 +205,9 @@ func ready(gp *g) {
 
 	// status is Gwaiting or Gscanwaiting, make Grunnable and put on runq
 	casgstatus(gp, _Gwaiting, _Grunnable)
+	if trace.enabled {
+		traceGoUnpark(gp, 3)
+	}
 	runqput(_g_.m.p, gp)
 	if atomicload(&sched.npidle) != 0 && atomicload(&sched.nmspinning) == 0 { // TODO: fast atomic
 		wakep()
@@ -601,6 +604,11 @@ func stoptheworld() {
 		p := allp[i]
 		s := p.status
 		if s == _Psyscall && cas(&p.status, s, _Pgcstop) {
+			if trace.enabled {
+				traceGoSysBlock(p)
+				traceProcStop(p)
+			}
+			p.syscalltick++
 			sched.stopwait--
 		}
 	}
@@ -858,6 +866,14 @@ func allocg() *g {
 // put the m back on the list.
 //go:nosplit
 func needm(x byte) {
+	if isarchive || islibrary {
+		// The runtime of a C archive or shared library starts on
+		// a thread of its own while the C program keeps running.
+		// Wait for it to finish initializing the packages.
+		for atomicload(&libinitdone) == 0 {
+			usleep(1000)
+		}
+	}
 	if needextram != 0 {
 		// Can happen if C/C++ code calls Go from a global ctor.
 		// Can not throw, because scheduler is not initialized yet.
@@ -1232,6 +1248,10 @@ func execute(gp *g) {
 	_g_.m.curg = gp
 	gp.m = _g_.m
 
+	if trace.enabled {
+		traceGoStart()
+	}
+
 	// Check whether the profiler needs to be turned on or off.
 	hz := sched.profilehz
 	if _g_.m.profilehz != hz {
@@ -1276,6 +1296,9 @@ top:
 	if gp := netpoll(false); gp != nil { // non-blocking
 		injectglist(gp.schedlink)
 		casgstatus(gp, _Gwaiting, _Grunnable)
+		if trace.enabled {
+			traceGoUnpark(gp, 0)
+		}
 		return gp
 	}
 
@@ -1359,6 +1382,9 @@ stop:
 				acquirep(_p_)
 				injectglist(gp.schedlink)
 				casgstatus(gp, _Gwaiting, _Grunnable)
+				if trace.enabled {
+					traceGoUnpark(gp, 0)
+				}
 				return gp
 			}
 			injectglist(gp)
@@ -1395,6 +1421,11 @@ func injectglist(glist *g) {
 	if glist == nil {
 		return
 	}
+	if trace.enabled {
+		for gp := glist; gp != nil; gp = gp.schedlink {
+			traceGoUnpark(gp, 0)
+		}
+	}
 	lock(&sched.lock)
 	var n int
 	for n = 0; glist != nil; n++ {
@@ -1430,13 +1461,21 @@ top:
 	}
 
 	var gp *g
+	if trace.enabled || trace.shutdown {
+		gp = traceReader()
+		if gp != nil {
+			casgstatus(gp, _Gwaiting, _Grunnable)
+			traceGoUnpark(gp, 0)
+			resetspinning()
+		}
+	}
 	// Check the global runnable queue once in a while to ensure fairness.
 	// Otherwise two goroutines can completely occupy the local runqueue
 	// by constantly respawning each other.
 	tick := _g_.m.p.schedtick
 	// This is a fancy way to say tick%61==0,
 	// it uses 2 MUL instructions instead of a single DIV and so is faster on modern processors.
-	if uint64(tick)-((uint64(tick)*0x4325c53f)>>36)*61 == 0 && sched.runqsize > 0 {
+	if gp == nil && uint64(tick)-((uint64(tick)*0x4325c53f)>>36)*61 == 0 && sched.runqsize > 0 {
 		lock(&sched.lock)
 		gp = globrunqget(_g_.m.p, 1)
 		unlock(&sched.lock)
@@ -1489,6 +1528,8 @@ func park(unlockf func(*g, unsafe.Pointer) bool, lock unsafe.Pointer, reason str
 	_g_.m.waitlock = lock
 	_g_.m.waitunlockf = *(*unsafe.Pointer)(unsafe.Pointer(&unlockf))
 	_g_.waitreason = reason
+	_g_.m.waittraceev = traceEvGoBlock
+	_g_.m.waittraceskip = 2
 	mcall(park_m)
 }
 
@@ -1507,6 +1548,12 @@ func parkunlock(lock *mutex, reason string) {
 func park_m(gp *g) {
 	_g_ := getg()
 
+	// N.B. trace.lockOwner in ReadTrace relies on the event
+	// being emitted while gp is still the current goroutine.
+	if trace.enabled {
+		traceGoPark(_g_.m.waittraceev, _g_.m.waittraceskip)
+	}
+
 	casgstatus(gp, _Grunning, _Gwaiting)
 	dropg()
 
@@ -1516,6 +1563,9 @@ func park_m(gp *g) {
 		_g_.m.waitunlockf = nil
 		_g_.m.waitlock = nil
 		if !ok {
+			if trace.enabled {
+				traceGoUnpark(gp, 2)
+			}
 			casgstatus(gp, _Gwaiting, _Grunnable)
 			execute(gp) // Schedule it back, never returns.
 		}
@@ -1523,8 +1573,7 @@ func park_m(gp *g) {
 	schedule()
 }
 
-// Gosched continuation on g0.
-func gosched_m(gp *g) {
+func goschedImpl(gp *g) {
 	status := readgstatus(gp)
 	if status&^_Gscan != _Grunning {
 		dumpgstatus(gp)
@@ -1539,6 +1588,23 @@ func gosched_m(gp *g) {
 	schedule()
 }
 
+// Gosched continuation on g0.
+func gosched_m(gp *g) {
+	if trace.enabled {
+		traceGoSched()
+	}
+	goschedImpl(gp)
+}
+
+// gopreempt_m is like gosched_m, but records a preemption
+// rather than a voluntary yield in the trace.
+func gopreempt_m(gp *g) {
+	if trace.enabled {
+		traceGoPreempt()
+	}
+	goschedImpl(gp)
+}
+
 // Finishes execution of the current goroutine.
 // Must be NOSPLIT because it is called from Go. (TODO - probably not anymore)
 //go:nosplit
@@ -1553,6 +1619,10 @@ func goexit1() {
 func goexit0(gp *g) {
 	_g_ := getg()
 
+	if trace.enabled {
+		traceGoEnd()
+	}
+
 	casgstatus(gp, _Grunning, _Gdead)
 	gp.m = nil
 	gp.lockedm = nil
@@ -1563,6 +1633,7 @@ func goexit0(gp *g) {
 	gp.writebuf = nil
 	gp.waitreason = ""
 	gp.param = nil
+	gp.labels = nil
 
 	dropg()
 
@@ -1642,6 +1713,14 @@ func reentersyscall(pc, sp uintptr) {
 		save(pc, sp)
 	}
 
+	if trace.enabled {
+		systemstack(traceGoSysCall)
+		// systemstack clobbers g.sched, which is needed
+		// while the goroutine is blocked in the syscall.
+		save(pc, sp)
+	}
+
+	_g_.m.syscalltick = _g_.m.p.syscalltick
 	_g_.m.mcache = nil
 	_g_.m.p.m = nil
 	atomicstore(&_g_.m.p.status, _Psyscall)
@@ -1677,6 +1756,11 @@ func entersyscall_gcwait() {
 
 	lock(&sched.lock)
 	if sched.stopwait > 0 && cas(&_g_.m.p.status, _Psyscall, _Pgcstop) {
+		if trace.enabled {
+			traceGoSysBlock(_g_.m.p)
+			traceProcStop(_g_.m.p)
+		}
+		_g_.m.p.syscalltick++
 		if sched.stopwait--; sched.stopwait == 0 {
 			notewakeup(&sched.stopnote)
 		}
@@ -1725,6 +1809,10 @@ func entersyscallblock(dummy int32) {
 }
 
 func entersyscallblock_handoff() {
+	if trace.enabled {
+		traceGoSysCall()
+		traceGoSysBlock(getg().m.p)
+	}
 	handoffp(releasep())
 }
 
@@ -1742,10 +1830,27 @@ func exitsyscall(dummy int32) {
 	}
 
 	_g_.waitsince = 0
+	oldp := _g_.m.p
 	if exitsyscallfast() {
 		if _g_.m.mcache == nil {
 			gothrow("lost mcache")
 		}
+		if trace.enabled {
+			if oldp == _g_.m.p && _g_.m.syscalltick != oldp.syscalltick {
+				// The P was retaken during the syscall and another
+				// goroutine entered a syscall on it, which we have
+				// just taken the P away from.
+				systemstack(func() {
+					traceGoSysBlock(oldp)
+				})
+			}
+			systemstack(traceGoSysExit)
+			if oldp != _g_.m.p || _g_.m.syscalltick != oldp.syscalltick {
+				// The P was taken away during the syscall,
+				// so the goroutine starts over on this one.
+				systemstack(traceGoStart)
+			}
+		}
 		// There's a cpu for us, so we can run.
 		_g_.m.p.syscalltick++
 		// We need to cas the status and scan before resuming...
@@ -1840,6 +1945,10 @@ func exitsyscallfast_pidle() bool {
 func exitsyscall0(gp *g) {
 	_g_ := getg()
 
+	if trace.enabled {
+		traceGoSysExit()
+	}
+
 	casgstatus(gp, _Gsyscall, _Grunnable)
 	dropg()
 	lock(&sched.lock)
@@ -1993,6 +2102,11 @@ func newproc1(fn *funcval, argp *uint8, narg int32, nret int32, callerpc uintptr
 	newg.sched.g = newg
 	gostartcallfn(&newg.sched, fn)
 	newg.gopc = callerpc
+	newg.startpc = fn.fn
+	if _g_.m.curg != nil {
+		// A goroutine inherits the profiler labels of its creator.
+		newg.labels = _g_.m.curg.labels
+	}
 	casgstatus(newg, _Gdead, _Grunnable)
 
 	if _p_.goidcache == _p_.goidcacheend {
@@ -2008,6 +2122,9 @@ func newproc1(fn *funcval, argp *uint8, narg int32, nret int32, callerpc uintptr
 	if raceenabled {
 		newg.racectx = racegostart(callerpc)
 	}
+	if trace.enabled {
+		traceGoCreate(newg, newg.startpc)
+	}
 	runqput(_p_, newg)
 
 	if atomicload(&sched.npidle) != 0 && atomicload(&sched.nmspinning) == 0 && unsafe.Pointer(fn.fn) != unsafe.Pointer(funcPC(main)) { // TODO: fast atomic
@@ -2202,8 +2319,6 @@ func _System()       { _System() }
 func _ExternalCode() { _ExternalCode() }
 func _GC()           { _GC() }
 
-var etext struct{}
-
 // Called if we receive a SIGPROF signal.
 func sigprof(pc *uint8, sp *uint8, lr *uint8, gp *g, mp *m) {
 	var n int32
@@ -2321,7 +2436,7 @@ func sigprof(pc *uint8, sp *uint8, lr *uint8, gp *g, mp *m) {
 			// If all of the above has failed, account it against abstract "System" or "GC".
 			n = 2
 			// "ExternalCode" is better than "etext".
-			if uintptr(unsafe.Pointer(pc)) > uintptr(unsafe.Pointer(&etext)) {
+			if findmoduledatap(uintptr(unsafe.Pointer(pc))) == nil {
 				pc = (*uint8)(unsafe.Pointer(uintptr(funcPC(_ExternalCode) + _PCQuantum)))
 			}
 			stk[0] = uintptr(unsafe.Pointer(pc))
@@ -2339,7 +2454,7 @@ func sigprof(pc *uint8, sp *uint8, lr *uint8, gp *g, mp *m) {
 			osyield()
 		}
 		if prof.hz != 0 {
-			cpuproftick(&stk[0], n)
+			cpuproftick(&stk[0], n, mp.curg)
 		}
 		atomicstore(&prof.lock, 0)
 	}
@@ -2388,6 +2503,9 @@ func procresize(new int32) {
 	if old < 0 || old > _MaxGomaxprocs || new <= 0 || new > _MaxGomaxprocs {
 		gothrow("procresize: invalid arg")
 	}
+	if trace.enabled {
+		traceGomaxprocs(new)
+	}
 
 	// initialize new P's
 	for i := int32(0); i < new; i++ {
@@ -2462,6 +2580,13 @@ func procresize(new int32) {
 
 	_g_ := getg()
 	if _g_.m.p != nil {
+		if trace.enabled {
+			// The current goroutine moves to allp[0] below.
+			// Pretend that it was rescheduled there,
+			// so that the trace stays consistent.
+			traceGoSched()
+			traceProcStop(_g_.m.p)
+		}
 		_g_.m.p.m = nil
 	}
 	_g_.m.p = nil
@@ -2470,6 +2595,9 @@ func procresize(new int32) {
 	p.m = nil
 	p.status = _Pidle
 	acquirep(p)
+	if trace.enabled {
+		traceGoStart()
+	}
 	for i := new - 1; i > 0; i-- {
 		p := allp[i]
 		p.status = _Pidle
@@ -2498,6 +2626,10 @@ func acquirep(_p_ *p) {
 	_g_.m.p = _p_
 	_p_.m = _g_.m
 	_p_.status = _Prunning
+
+	if trace.enabled {
+		traceProcStart()
+	}
 }
 
 // Disassociate p and the current m.
@@ -2512,6 +2644,9 @@ func releasep() *p {
 		print("releasep: m=", _g_.m, " m->p=", _g_.m.p, " p->m=", _p_.m, " m->mcache=", _g_.m.mcache, " p->mcache=", _p_.mcache, " p->status=", _p_.status, "\n")
 		gothrow("releasep: invalid p state")
 	}
+	if trace.enabled {
+		traceProcStop(_g_.m.p)
+	}
 	_g_.m.p = nil
 	_g_.m.mcache = nil
 	_p_.m = nil
@@ -2731,7 +2866,12 @@ func retake(now int64) uint32 {
 			// increment nmidle and report deadlock.
 			incidlelocked(-1)
 			if cas(&_p_.status, s, _Pidle) {
+				if trace.enabled {
+					traceGoSysBlock(_p_)
+					traceProcStop(_p_)
+				}
 				n++
+				_p_.syscalltick++
 				handoffp(_p_)
 			}
 			incidlelocked(1)
@@ -395,15 +395,13 @@ func gomcache() *mcache {
 	return getg().m.mcache
 }
 
-var typelink, etypelink [0]byte
-
-//go:nosplit
-func typelinks() []*_type {
-	var ret []*_type
-	sp := (*slice)(unsafe.Pointer(&ret))
-	sp.array = (*byte)(unsafe.Pointer(&typelink))
-	sp.len = uint((uintptr(unsafe.Pointer(&etypelink)) - uintptr(unsafe.Pointer(&typelink))) / unsafe.Sizeof(ret[0]))
-	sp.cap = sp.len
+// typelinks returns the typelinks of each module of the program,
+// see moduledata.typelinks.
+func typelinks() [][]*_type {
+	var ret [][]*_type
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		ret = append(ret, datap.typelinks)
+	}
 	return ret
 }
 
@@ -112,6 +112,7 @@ type sudog struct {
 	prev        *sudog
 	elem        unsafe.Pointer // data element
 	releasetime int64
+	acquiretime int64  // when the wait began, for the mutex profile
 	nrelease    int32  // -1 for acquire
 	waitlink    *sudog // g.waiting list
 }
@@ -189,8 +190,10 @@ type g struct {
 	sigcode1     uintptr
 	sigpc        uintptr
 	gopc         uintptr // pc of go statement that created this goroutine
+	startpc      uintptr // pc of goroutine function
 	racectx      uintptr
-	waiting      *sudog // sudog structures this g is waiting on (that have a valid elem ptr)
+	waiting      *sudog         // sudog structures this g is waiting on (that have a valid elem ptr)
+	labels       unsafe.Pointer // profiler labels, set by runtime/pprof
 	end          [0]byte
 }
 
@@ -253,6 +256,10 @@ type m struct {
 	traceback     uint8
 	waitunlockf   unsafe.Pointer // todo go func(*g, unsafe.pointer) bool
 	waitlock      unsafe.Pointer
+	waittraceev   byte
+	waittraceskip int
+	startingtrace bool
+	syscalltick   uint32 // p.syscalltick when the current syscall was entered
 	//#ifdef GOOS_windows
 	thread uintptr // thread handle
 	// these are here because they are too large to be on the stack
@@ -302,6 +309,8 @@ type p struct {
 	gfree    *g
 	gfreecnt int32
 
+	tracebuf *traceBuf
+
 	pad [64]byte
 }
 
@@ -549,6 +558,9 @@ var (
 	goos        *int8
 	ncpu        int32
 	iscgo       bool
+	islibrary   bool   // -buildmode=c-shared, set by the linker
+	isarchive   bool   // -buildmode=c-archive, set by the linker
+	libinitdone uint32 // library: the package initializers have run
 	cpuid_ecx   uint32
 	cpuid_edx   uint32
 	debug       debugvars
@@ -37,6 +37,12 @@ func initsig() {
 			}
 		}
 
+		// A C archive or shared library leaves alone the signals
+		// that the C program handles or ignores.
+		if (isarchive || islibrary) && getsig(i) != _SIG_DFL {
+			continue
+		}
+
 		t.flags |= _SigHandling
 		setsig(i, funcPC(sighandler), true)
 	}
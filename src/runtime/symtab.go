@@ -29,13 +29,39 @@ const (
 	_ArgsSizeUnknown            = -0x80000000
 )
 
-var (
+// A moduledata describes one module of the running program: the
+// executable, or a Go shared library it was linked against. The
+// linker writes the fields from pclntab to etypelink, see
+// cmd/ld/symtab.c:/^moduledata; moduledatainit computes the rest.
+// The pointers in a moduledata all point outside the heap, so the
+// garbage collector does not need to see them.
+type moduledata struct {
+	pclntab, epclntab     uintptr
+	text, etext           uintptr
+	noptrdata, enoptrdata uintptr
+	data, edata           uintptr
+	bss, ebss             uintptr
+	noptrbss, enoptrbss   uintptr
+	end                   uintptr
+	gcdata, gcbss         uintptr
+	typelink, etypelink   uintptr
+
 	pclntable []byte
 	ftab      []functab
 	filetab   []uint32
+	typelinks []*_type
 
-	pclntab, epclntab struct{} // linker symbols
-)
+	gcdatamask, gcbssmask bitvector
+
+	next *moduledata
+}
+
+// firstmoduledata describes the module holding the runtime.
+// A program linked with -linkshared adds its own module to the
+// list from its .init_array, before the runtime starts, by calling
+// addmoduledata.
+var firstmoduledata moduledata // linker symbol
+var lastmoduledatap = &firstmoduledata
 
 type functab struct {
 	entry   uintptr
@@ -43,29 +69,39 @@ type functab struct {
 }
 
 func symtabinit() {
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		moduledatainit(datap)
+	}
+}
+
+// moduledatainit sets up the function, file and type tables of
+// the module described by datap.
+func moduledatainit(datap *moduledata) {
 	// See golang.org/s/go12symtab for header: 0xfffffffb,
 	// two zero bytes, a byte giving the PC quantum,
 	// and a byte giving the pointer width in bytes.
-	pcln := (*[8]byte)(unsafe.Pointer(&pclntab))
-	pcln32 := (*[2]uint32)(unsafe.Pointer(&pclntab))
+	pcln := (*[8]byte)(unsafe.Pointer(datap.pclntab))
+	pcln32 := (*[2]uint32)(unsafe.Pointer(datap.pclntab))
 	if pcln32[0] != 0xfffffffb || pcln[4] != 0 || pcln[5] != 0 || pcln[6] != _PCQuantum || pcln[7] != ptrSize {
 		println("runtime: function symbol table header:", hex(pcln32[0]), hex(pcln[4]), hex(pcln[5]), hex(pcln[6]), hex(pcln[7]))
 		gothrow("invalid function symbol table\n")
 	}
 
 	// pclntable is all bytes of pclntab symbol.
-	sp := (*sliceStruct)(unsafe.Pointer(&pclntable))
-	sp.array = unsafe.Pointer(&pclntab)
-	sp.len = int(uintptr(unsafe.Pointer(&epclntab)) - uintptr(unsafe.Pointer(&pclntab)))
+	sp := (*sliceStruct)(unsafe.Pointer(&datap.pclntable))
+	sp.array = unsafe.Pointer(datap.pclntab)
+	sp.len = int(datap.epclntab - datap.pclntab)
 	sp.cap = sp.len
 
 	// ftab is lookup table for function by program counter.
 	nftab := int(*(*uintptr)(add(unsafe.Pointer(pcln), 8)))
 	p := add(unsafe.Pointer(pcln), 8+ptrSize)
-	sp = (*sliceStruct)(unsafe.Pointer(&ftab))
+	sp = (*sliceStruct)(unsafe.Pointer(&datap.ftab))
 	sp.array = p
 	sp.len = nftab + 1
 	sp.cap = sp.len
+	ftab := datap.ftab
+	pclntable := datap.pclntable
 	for i := 0; i < nftab; i++ {
 		// NOTE: ftab[nftab].entry is legal; it is the address beyond the final function.
 		if ftab[i].entry > ftab[i+1].entry {
@@ -86,7 +122,7 @@ func symtabinit() {
 	// The ftab ends with a half functab consisting only of
 	// 'entry', followed by a uint32 giving the pcln-relative
 	// offset of the file table.
-	sp = (*sliceStruct)(unsafe.Pointer(&filetab))
+	sp = (*sliceStruct)(unsafe.Pointer(&datap.filetab))
 	end := unsafe.Pointer(&ftab[nftab].funcoff) // just beyond ftab
 	fileoffset := *(*uint32)(end)
 	sp.array = unsafe.Pointer(&pclntable[fileoffset])
@@ -94,10 +130,27 @@ func symtabinit() {
 	// set len to 1 so we can get first element.
 	sp.len = 1
 	sp.cap = 1
-	sp.len = int(filetab[0])
+	sp.len = int(datap.filetab[0])
+	sp.cap = sp.len
+
+	// typelinks is the module's types, sorted by string.
+	sp = (*sliceStruct)(unsafe.Pointer(&datap.typelinks))
+	sp.array = unsafe.Pointer(datap.typelink)
+	sp.len = int((datap.etypelink - datap.typelink) / ptrSize)
 	sp.cap = sp.len
 }
 
+// findmoduledatap returns the module whose code contains pc,
+// or nil if no module does.
+func findmoduledatap(pc uintptr) *moduledata {
+	for datap := &firstmoduledata; datap != nil; datap = datap.next {
+		if datap.text <= pc && pc < datap.etext {
+			return datap
+		}
+	}
+	return nil
+}
+
 // FuncForPC returns a *Func describing the function that contains the
 // given program counter address, or else nil.
 func FuncForPC(pc uintptr) *Func {
@@ -126,6 +179,11 @@ func (f *Func) FileLine(pc uintptr) (file string, line int) {
 }
 
 func findfunc(pc uintptr) *_func {
+	datap := findmoduledatap(pc)
+	if datap == nil {
+		return nil
+	}
+	ftab := datap.ftab
 	if len(ftab) == 0 {
 		return nil
 	}
@@ -141,7 +199,7 @@ func findfunc(pc uintptr) *_func {
 		n := nf / 2
 		f := &ftab[lo+n]
 		if f.entry <= pc && pc < ftab[lo+n+1].entry {
-			return (*_func)(unsafe.Pointer(&pclntable[f.funcoff]))
+			return (*_func)(unsafe.Pointer(&datap.pclntable[f.funcoff]))
 		} else if pc < f.entry {
 			nf = n
 		} else {
@@ -158,7 +216,11 @@ func pcvalue(f *_func, off int32, targetpc uintptr, strict bool) int32 {
 	if off == 0 {
 		return -1
 	}
-	p := pclntable[off:]
+	datap := findmoduledatap(f.entry) // inefficient
+	if datap == nil {
+		return -1
+	}
+	p := datap.pclntable[off:]
 	pc := f.entry
 	val := int32(-1)
 	for {
@@ -180,7 +242,7 @@ func pcvalue(f *_func, off int32, targetpc uintptr, strict bool) int32 {
 
 	print("runtime: invalid pc-encoded table f=", gofuncname(f), " pc=", hex(pc), " targetpc=", hex(targetpc), " tab=", p, "\n")
 
-	p = pclntable[off:]
+	p = datap.pclntable[off:]
 	pc = f.entry
 	val = -1
 	for {
@@ -200,7 +262,11 @@ func funcname(f *_func) *byte {
 	if f == nil || f.nameoff == 0 {
 		return nil
 	}
-	return (*byte)(unsafe.Pointer(&pclntable[f.nameoff]))
+	datap := findmoduledatap(f.entry) // inefficient
+	if datap == nil {
+		return nil
+	}
+	return (*byte)(unsafe.Pointer(&datap.pclntable[f.nameoff]))
 }
 
 func gofuncname(f *_func) string {
@@ -208,13 +274,17 @@ func gofuncname(f *_func) string {
 }
 
 func funcline1(f *_func, targetpc uintptr, strict bool) (file string, line int32) {
+	datap := findmoduledatap(f.entry) // inefficient
+	if datap == nil {
+		return "?", 0
+	}
 	fileno := int(pcvalue(f, f.pcfile, targetpc, strict))
 	line = pcvalue(f, f.pcln, targetpc, strict)
-	if fileno == -1 || line == -1 || fileno >= len(filetab) {
+	if fileno == -1 || line == -1 || fileno >= len(datap.filetab) {
 		// print("looking for ", hex(targetpc), " in ", gofuncname(f), " got file=", fileno, " line=", lineno, "\n")
 		return "?", 0
 	}
-	file = gostringnocopy(&pclntable[filetab[fileno]])
+	file = gostringnocopy(&datap.pclntable[datap.filetab[fileno]])
 	return
 }
 
@@ -43,6 +43,9 @@ TEXT sync·runtime_Syncsemcheck(SB),NOSPLIT,$0-0
 TEXT sync·runtime_Semacquire(SB),NOSPLIT,$0-0
 	JMP	runtime·asyncsemacquire(SB)
 
+TEXT sync·runtime_SemacquireMutex(SB),NOSPLIT,$0-0
+	JMP	runtime·asyncsemacquiremutex(SB)
+
 TEXT sync·runtime_Semrelease(SB),NOSPLIT,$0-0
 	JMP	runtime·asyncsemrelease(SB)
 
@@ -58,6 +61,12 @@ TEXT net·runtime_Semrelease(SB),NOSPLIT,$0-0
 TEXT runtime∕pprof·runtime_cyclesPerSecond(SB),NOSPLIT,$0-0
 	JMP	runtime·tickspersecond(SB)
 
+TEXT runtime∕pprof·runtime_setProfLabel(SB),NOSPLIT,$0-0
+	JMP	runtime·setProfLabel(SB)
+
+TEXT runtime∕pprof·runtime_readProfile(SB),NOSPLIT,$0-0
+	JMP	runtime·readProfile(SB)
+
 TEXT bytes·Compare(SB),NOSPLIT,$0-0
 	JMP	runtime·cmpbytes(SB)
 
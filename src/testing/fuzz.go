@@ -0,0 +1,458 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+var (
+	matchFuzz        = flag.String("test.fuzz", "", "run the fuzz target matching the regular expression")
+	fuzzDuration     = flag.Duration("test.fuzztime", 0, "time to spend fuzzing; by default fuzzing runs until it finds a failure")
+	minimizeDuration = flag.Duration("test.fuzzminimizetime", 60*time.Second, "time to spend minimizing a failing input")
+
+	haveFuzzTargets bool // are there fuzz targets?
+)
+
+// corpusDir is the directory, relative to the package directory, that holds
+// the stored corpus of each fuzz target in a subdirectory named after it.
+var corpusDir = "testdata/fuzz"
+
+// An internal type but exported because it is cross-package; part of the implementation
+// of the "go test" command.
+type InternalFuzzTarget struct {
+	Name string
+	Fn   func(f *F)
+}
+
+// F is a type passed to fuzz targets.
+//
+// A fuzz target adds seed inputs with Add and then calls Fuzz with
+// the fuzz function, which is run on each input. The reporting methods
+// of F, such as Fatal and Skip, may be used by the fuzz target but not by
+// the fuzz function, which reports through its *T instead.
+type F struct {
+	common
+	context    *testContext  // For running the corpus entries as subtests.
+	fuzzing    bool          // Generate new inputs rather than just run the corpus.
+	corpus     []corpusEntry // Seed inputs added with Add.
+	fuzzCalled bool
+}
+
+var _ TB = (*F)(nil)
+
+// corpusEntry is an input to a fuzz function.
+type corpusEntry struct {
+	name   string // seed#N for a seed input, or the name of the file holding it
+	values []interface{}
+}
+
+// supportedTypes are the types of the fuzzed arguments of a fuzz function.
+var supportedTypes = map[reflect.Type]bool{
+	reflect.TypeOf([]byte(nil)): true,
+	reflect.TypeOf(""):          true,
+	reflect.TypeOf(false):       true,
+	reflect.TypeOf(int(0)):      true,
+	reflect.TypeOf(int8(0)):     true,
+	reflect.TypeOf(int16(0)):    true,
+	reflect.TypeOf(int32(0)):    true,
+	reflect.TypeOf(int64(0)):    true,
+	reflect.TypeOf(uint(0)):     true,
+	reflect.TypeOf(uint8(0)):    true,
+	reflect.TypeOf(uint16(0)):   true,
+	reflect.TypeOf(uint32(0)):   true,
+	reflect.TypeOf(uint64(0)):   true,
+	reflect.TypeOf(float32(0)):  true,
+	reflect.TypeOf(float64(0)):  true,
+}
+
+// Add adds the arguments to the seed corpus of the fuzz target.
+// The arguments must match, in number and type, the parameters that
+// follow the *T of the fuzz function.
+func (f *F) Add(args ...interface{}) {
+	if f.fuzzCalled {
+		panic("testing: F.Add called after F.Fuzz")
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if !supportedTypes[reflect.TypeOf(arg)] {
+			panic(fmt.Sprintf("testing: unsupported type for F.Add: %T", arg))
+		}
+		values[i] = arg
+	}
+	f.corpus = append(f.corpus, corpusEntry{name: fmt.Sprintf("seed#%d", len(f.corpus)), values: values})
+}
+
+// Fuzz runs the fuzz function ff, which must have the form
+//     func(t *testing.T, a A, b B, ...)
+// with at least one argument after t. The fuzzed arguments may be of
+// type []byte, string, bool, float32, float64 or any integer type.
+//
+// Without the -fuzz flag, Fuzz runs ff once for each seed input added
+// with Add and each input stored in testdata/fuzz/FuzzXxx, as a subtest
+// named after the input.
+//
+// With the -fuzz flag, Fuzz then generates new inputs by mutating the
+// corpus, keeping those that reach code no earlier input reached, until
+// the -fuzztime deadline passes or ff fails. The coverage counters come
+// from building the package under test with coverage analysis, which
+// 'go test -fuzz' always does; a test binary built without it fails
+// rather than fuzz without guidance. The failing
+// input is minimized and written to testdata/fuzz/FuzzXxx, so that
+// every later 'go test' runs it as part of the corpus.
+//
+// ff must not call t.Parallel. Fuzz may be called only once, from the
+// goroutine running the fuzz target.
+func (f *F) Fuzz(ff interface{}) {
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+
+	fn := reflect.ValueOf(ff)
+	if fn.Kind() != reflect.Func {
+		panic("testing: F.Fuzz must receive a function")
+	}
+	fnType := fn.Type()
+	if fnType.NumIn() < 2 || fnType.In(0) != reflect.TypeOf((*T)(nil)) {
+		panic("testing: fuzz function must have the form func(*testing.T, ...) with at least one fuzzed argument")
+	}
+	if fnType.NumOut() != 0 {
+		panic("testing: fuzz function must not return a value")
+	}
+	var types []reflect.Type
+	for i := 1; i < fnType.NumIn(); i++ {
+		t := fnType.In(i)
+		if !supportedTypes[t] {
+			panic(fmt.Sprintf("testing: unsupported type for fuzzing: %v", t))
+		}
+		types = append(types, t)
+	}
+
+	for _, e := range f.corpus {
+		if err := checkCorpusTypes(e.values, types); err != nil {
+			f.Fatalf("%s: %v", e.name, err)
+		}
+	}
+	stored, err := readCorpus(corpusDir+"/"+f.name, types)
+	if err != nil {
+		f.Fatal(err)
+	}
+	corpus := append(f.corpus, stored...)
+
+	if !f.fuzzing {
+		for _, e := range corpus {
+			values := e.values
+			f.runSeed(e.name, func(t *T) { callFuzzFn(fn, t, values) })
+		}
+		return
+	}
+	f.fuzz(fn, types, corpus)
+}
+
+// runSeed runs fn as a subtest of f called name.
+func (f *F) runSeed(name string, fn func(t *T)) bool {
+	testName, ok := f.context.match.fullName(&f.common, name)
+	if !ok {
+		return true
+	}
+	t := &T{
+		common: common{
+			barrier: make(chan bool),
+			signal:  make(chan bool),
+			name:    testName,
+			parent:  &f.common,
+			level:   f.level + 1,
+			chatty:  f.chatty,
+		},
+		context:  f.context,
+		inFuzzFn: true,
+	}
+	t.w = indenter{&t.common}
+
+	if t.chatty {
+		root := t.parent
+		for ; root.parent != nil; root = root.parent {
+		}
+		root.mu.Lock()
+		fmt.Fprintf(root.w, "=== RUN %s\n", t.name)
+		root.mu.Unlock()
+	}
+	go tRunner(t, fn)
+	<-t.signal
+	return !t.Failed()
+}
+
+// callFuzzFn calls the fuzz function fn with t and a copy of values.
+func callFuzzFn(fn reflect.Value, t *T, values []interface{}) {
+	args := make([]reflect.Value, len(values)+1)
+	args[0] = reflect.ValueOf(t)
+	for i, v := range copyValues(values) {
+		args[i+1] = reflect.ValueOf(v)
+	}
+	fn.Call(args)
+}
+
+// runInput runs the fuzz function fn on values, in a T of its own that
+// reports to no parent. It reports whether fn failed or panicked, and
+// returns the output fn logged.
+func (f *F) runInput(fn reflect.Value, values []interface{}) (failed bool, output []byte) {
+	t := &T{
+		common: common{
+			barrier: make(chan bool),
+			signal:  make(chan bool),
+			name:    f.name,
+			level:   f.level + 1,
+		},
+		context:  f.context,
+		inFuzzFn: true,
+	}
+	t.w = indenter{&t.common}
+	go func() {
+		defer func() {
+			err := recover()
+			if !t.finished && err == nil {
+				err = fmt.Errorf("fuzz function executed panic(nil) or runtime.Goexit")
+			}
+			if err != nil {
+				buf := make([]byte, 16<<10)
+				buf = buf[:runtime.Stack(buf, false)]
+				t.mu.Lock()
+				t.output = append(t.output, fmt.Sprintf("\tpanic: %v\n%s\n", err, buf)...)
+				t.mu.Unlock()
+				t.Fail()
+			}
+			t.runCleanup()
+			t.signal <- true
+		}()
+		callFuzzFn(fn, t, values)
+		t.finished = true
+	}()
+	<-t.signal
+	return t.Failed(), t.output
+}
+
+// fuzz generates inputs for fn from the corpus until the deadline passes
+// or an input fails.
+func (f *F) fuzz(fn reflect.Value, types []reflect.Type, corpus []corpusEntry) {
+	if cover.Mode == "" {
+		f.Fatal("fuzz: the test binary was not built with coverage analysis, which guides fuzzing; build it with 'go test -fuzz'")
+	}
+	cov := newCoverTracker(cover.Counters)
+	defer cov.restore()
+	run := func(values []interface{}) (failed bool, output []byte, newCoverage bool) {
+		cov.reset()
+		failed, output = f.runInput(fn, values)
+		return failed, output, cov.update()
+	}
+
+	// The corpus gives the baseline coverage. Its inputs are already
+	// recorded, so a failing one is reported as is.
+	for _, e := range corpus {
+		if failed, output, _ := run(e.values); failed {
+			f.reportFailure(output, fmt.Sprintf("failure while testing corpus entry: %s/%s\n", f.name, e.name))
+			return
+		}
+	}
+	if len(corpus) == 0 {
+		corpus = append(corpus, corpusEntry{name: "zero", values: zeroValues(types)})
+	}
+
+	m := newMutator()
+	start := time.Now()
+	var deadline time.Time
+	if *fuzzDuration > 0 {
+		deadline = start.Add(*fuzzDuration)
+	}
+	execs, interesting := 0, 0
+	status := func() {
+		elapsed := time.Since(start)
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(execs) / elapsed.Seconds()
+		}
+		f.printf("fuzz: elapsed: %ds, execs: %d (%.0f/sec), new interesting: %d (total: %d)\n",
+			int(elapsed.Seconds()), execs, rate, interesting, len(corpus))
+	}
+	nextStatus := start
+	for {
+		now := time.Now()
+		if !deadline.IsZero() && now.After(deadline) {
+			break
+		}
+		if !now.Before(nextStatus) {
+			status()
+			nextStatus = now.Add(3 * time.Second)
+		}
+
+		values := m.mutate(corpus[m.intn(len(corpus))].values)
+		failed, _, newCoverage := run(values)
+		execs++
+		if failed {
+			status()
+			f.recordFailure(fn, values)
+			return
+		}
+		if newCoverage {
+			interesting++
+			corpus = append(corpus, corpusEntry{name: fmt.Sprintf("gen#%d", interesting), values: values})
+		}
+	}
+	status()
+}
+
+// printf prints a progress message directly to the output of the root
+// test, so that it shows while fuzzing goes on.
+func (f *F) printf(format string, args ...interface{}) {
+	root := f.parent
+	for ; root.parent != nil; root = root.parent {
+	}
+	root.mu.Lock()
+	fmt.Fprintf(root.w, format, args...)
+	root.mu.Unlock()
+}
+
+// recordFailure minimizes the failing input values, writes it to the
+// corpus directory, and reports the failure.
+func (f *F) recordFailure(fn reflect.Value, values []interface{}) {
+	var deadline time.Time
+	if *minimizeDuration > 0 {
+		deadline = time.Now().Add(*minimizeDuration)
+	}
+	values = minimizeInput(values, func(v []interface{}) bool {
+		failed, _ := f.runInput(fn, v)
+		return failed
+	}, deadline)
+	_, output := f.runInput(fn, values)
+
+	dir := corpusDir + "/" + f.name
+	name, err := writeCorpusFile(dir, values)
+	if err != nil {
+		f.reportFailure(output, fmt.Sprintf("failed to record failing input: %v\n", err))
+		return
+	}
+	f.reportFailure(output, fmt.Sprintf("Failing input written to %s/%s\nTo re-run:\ngo test -run=%s/%s\n", dir, name, f.name, name))
+}
+
+// reportFailure marks f as failed and adds to its output the output of
+// the failing fuzz function followed by msg.
+func (f *F) reportFailure(output []byte, msg string) {
+	f.Fail()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintf(f.w, "--- FAIL: %s\n", f.name)
+	f.w.Write(output)
+	fmt.Fprintf(f.w, "\n%s", msg)
+}
+
+// fRunner runs the fuzz target fn on f, much as tRunner runs a test.
+func fRunner(f *F, fn func(f *F)) {
+	defer func() {
+		f.duration += time.Now().Sub(f.start)
+		err := recover()
+		if !f.finished && err == nil {
+			err = fmt.Errorf("fuzz target executed panic(nil) or runtime.Goexit")
+		}
+		if err != nil {
+			f.Fail()
+			f.runCleanup()
+			f.report()
+			panic(err)
+		}
+		f.runCleanup()
+		f.report()
+		f.signal <- true
+	}()
+
+	f.runner = callerName(0)
+	f.start = time.Now()
+	fn(f)
+	if f.fuzzing && !f.fuzzCalled && !f.Failed() && !f.Skipped() {
+		f.Error("testing: fuzz target did not call F.Fuzz")
+	}
+	f.finished = true
+}
+
+// newFuzzTarget returns an F for running the top-level fuzz target called
+// name under root.
+func newFuzzTarget(root *common, ctx *testContext, name string, fuzzing bool) *F {
+	f := &F{
+		common: common{
+			signal: make(chan bool),
+			name:   name,
+			parent: root,
+			level:  root.level + 1,
+			chatty: root.chatty,
+		},
+		context: ctx,
+		fuzzing: fuzzing,
+	}
+	f.w = indenter{&f.common}
+	if f.chatty {
+		root.mu.Lock()
+		fmt.Fprintf(root.w, "=== RUN %s\n", f.name)
+		root.mu.Unlock()
+	}
+	return f
+}
+
+// runFuzzTests runs the fuzz targets selected by -test.run on their
+// corpus, without generating new inputs.
+func runFuzzTests(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ok bool) {
+	ok = true
+	if len(fuzzTargets) == 0 {
+		return
+	}
+	ctx := newTestContext(1, newMatcher(matchString, *match, "-test.run"))
+	root := common{w: os.Stdout, chatty: *chatty}
+	for _, ft := range fuzzTargets {
+		name, matched := ctx.match.fullName(&root, ft.Name)
+		if !matched {
+			continue
+		}
+		f := newFuzzTarget(&root, ctx, name, false)
+		go fRunner(f, ft.Fn)
+		<-f.signal
+		ok = ok && !f.Failed()
+	}
+	return
+}
+
+// runFuzzing runs the fuzz target selected by -test.fuzz, if any,
+// generating new inputs. It reports whether no failure was found.
+func runFuzzing(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ok bool) {
+	if *matchFuzz == "" {
+		return true
+	}
+	m := newMatcher(matchString, *matchFuzz, "-test.fuzz")
+	var target *InternalFuzzTarget
+	var names []string
+	for i := range fuzzTargets {
+		if _, matched := m.fullName(nil, fuzzTargets[i].Name); matched {
+			target = &fuzzTargets[i]
+			names = append(names, target.Name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		fmt.Fprintln(os.Stderr, "testing: warning: no fuzz targets to fuzz")
+		return true
+	case len(names) > 1:
+		fmt.Fprintf(os.Stderr, "testing: will not fuzz, -test.fuzz matches more than one fuzz target: %v\n", names)
+		return false
+	}
+
+	ctx := newTestContext(1, newMatcher(matchString, "", "-test.run"))
+	root := common{w: os.Stdout, chatty: *chatty}
+	f := newFuzzTarget(&root, ctx, target.Name, true)
+	go fRunner(f, target.Fn)
+	<-f.signal
+	return !f.Failed()
+}
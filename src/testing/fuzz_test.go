@@ -0,0 +1,244 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func TestCorpusFileRoundTrip(t *T) {
+	values := []interface{}{
+		[]byte("GIF89a\x00\xff\n"),
+		"hello, 世界\t",
+		true,
+		int(-7),
+		int8(-128),
+		int16(300),
+		int32(-1),  // Not a valid rune.
+		int32('☺'), // A rune.
+		int64(math.MinInt64),
+		uint(7),
+		uint8(0xff), // A byte.
+		uint16(65535),
+		uint32(1 << 31),
+		uint64(math.MaxUint64),
+		float32(1.5),
+		float64(-0.25),
+		math.Inf(-1),
+		float32(math.Inf(1)),
+	}
+	data := marshalCorpusFile(values)
+	got, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatalf("unmarshal:\n%s\nfailed: %v", data, err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("round trip of\n%s\ngave %#v, want %#v", data, got, values)
+	}
+
+	nan, err := unmarshalCorpusFile(marshalCorpusFile([]interface{}{math.NaN()}))
+	if err != nil || len(nan) != 1 || !math.IsNaN(nan[0].(float64)) {
+		t.Errorf("round trip of NaN = %v, %v", nan, err)
+	}
+}
+
+func TestCorpusFileErrors(t *T) {
+	for _, data := range []string{
+		"",
+		"int(1)\n",
+		corpusHeader + "\n",
+		corpusHeader + "\nint(x)\n",
+		corpusHeader + "\nint8(300)\n",
+		corpusHeader + "\nbyte('☺')\n",
+		corpusHeader + "\ncomplex128(1)\n",
+		corpusHeader + "\nstring(\"unterminated)\n",
+		corpusHeader + "\nbool true\n",
+	} {
+		if v, err := unmarshalCorpusFile([]byte(data)); err == nil {
+			t.Errorf("unmarshalCorpusFile(%q) = %v, want error", data, v)
+		}
+	}
+}
+
+func TestMutatorKeepsTypes(t *T) {
+	m := newMutator()
+	values := []interface{}{[]byte{}, "", false, int8(0), uint16(0), int(0), float32(0), 0.0}
+	for i := 0; i < 1000; i++ {
+		next := m.mutate(values)
+		for j := range next {
+			if reflect.TypeOf(next[j]) != reflect.TypeOf(values[j]) {
+				t.Fatalf("mutate changed %T to %T", values[j], next[j])
+			}
+		}
+		values = next
+	}
+}
+
+func TestMinimizeInput(t *T) {
+	fails := func(v []interface{}) bool {
+		return bytes.Contains(v[0].([]byte), []byte("bug")) && v[1].(int) >= 10 && strings.HasPrefix(v[2].(string), "x")
+	}
+	in := []interface{}{[]byte("lots of text around a bug in here"), 1000, "xyzzy"}
+	got := minimizeInput(in, fails, time.Time{})
+	want := []interface{}{[]byte("bug"), 15, "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("minimizeInput = %#v, want %#v", got, want)
+	}
+	if string(in[0].([]byte)) != "lots of text around a bug in here" {
+		t.Errorf("minimizeInput modified its input")
+	}
+}
+
+func TestCoverTracker(t *T) {
+	counters := make([]uint32, 2)
+	c := newCoverTracker(map[string][]uint32{"x.go": counters})
+	for i, tc := range []struct {
+		hits [2]uint32
+		want bool
+	}{
+		{[2]uint32{1, 0}, true},
+		{[2]uint32{1, 0}, false},
+		{[2]uint32{0, 1}, true},
+		{[2]uint32{5, 0}, true},
+		{[2]uint32{6, 1}, false}, // Same bucket as 5.
+		{[2]uint32{200, 0}, true},
+	} {
+		c.reset()
+		counters[0], counters[1] = tc.hits[0], tc.hits[1]
+		if got := c.update(); got != tc.want {
+			t.Errorf("%d: update = %v, want %v", i, got, tc.want)
+		}
+	}
+	c.reset()
+	c.restore()
+	if counters[0] != 1+1+5+6+200 || counters[1] != 2 {
+		t.Errorf("restored counters = %v, want [213 2]", counters)
+	}
+}
+
+// runFuzzTarget runs fn as the fuzz target name, with its corpus in dir,
+// and returns it and its output.
+func runFuzzTarget(name, dir string, fuzzing bool, fn func(f *F)) (*F, string) {
+	defer func(old string) { corpusDir = old }(corpusDir)
+	corpusDir = dir
+	var buf bytes.Buffer
+	root := common{w: &buf, chatty: true}
+	ctx := newTestContext(1, newMatcher(regexp.MatchString, "", "-test.run"))
+	f := newFuzzTarget(&root, ctx, name, fuzzing)
+	go fRunner(f, fn)
+	<-f.signal
+	return f, buf.String()
+}
+
+func TestFuzzRunsCorpus(t *T) {
+	dir, err := ioutil.TempDir("", "fuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name, err := writeCorpusFile(filepath.Join(dir, "FuzzCorpus"), []interface{}{"stored", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []string
+	f, out := runFuzzTarget("FuzzCorpus", dir, false, func(f *F) {
+		f.Add("a", 0)
+		f.Add("b", 1)
+		f.Fuzz(func(t *T, s string, n int) {
+			ran = append(ran, s)
+			if n == 2 {
+				t.Error("stored input fails")
+			}
+		})
+	})
+	if !reflect.DeepEqual(ran, []string{"a", "b", "stored"}) {
+		t.Errorf("ran %q, want [a b stored]", ran)
+	}
+	if !f.Failed() {
+		t.Errorf("fuzz target passed, want failure from stored input")
+	}
+	for _, want := range []string{
+		"=== RUN FuzzCorpus/seed#0\n",
+		"=== RUN FuzzCorpus/seed#1\n",
+		"--- FAIL: FuzzCorpus/" + name,
+		"stored input fails",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFuzzFindsFailure(t *T) {
+	if Short() {
+		t.Skip("skipping fuzzing in short mode")
+	}
+	dir, err := ioutil.TempDir("", "fuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(c Cover, d time.Duration) { cover, *fuzzDuration = c, d }(cover, *fuzzDuration)
+	*fuzzDuration = 30 * time.Second
+
+	// Stand in for the coverage counters of an instrumented parser that
+	// fails on inputs starting with "FUZ". Finding that input without
+	// coverage guidance takes far longer than the deadline.
+	counters := make([]uint32, 3)
+	cover = Cover{Mode: "count", Counters: map[string][]uint32{"parse.go": counters}}
+	f, out := runFuzzTarget("FuzzFind", dir, true, func(f *F) {
+		f.Add([]byte("seed"))
+		f.Fuzz(func(t *T, b []byte) {
+			if len(b) > 0 && b[0] == 'F' {
+				counters[0]++
+				if len(b) > 1 && b[1] == 'U' {
+					counters[1]++
+					if len(b) > 2 && b[2] == 'Z' {
+						counters[2]++
+						t.Fatalf("found %q", b)
+					}
+				}
+			}
+		})
+	})
+	if !f.Failed() {
+		t.Fatalf("fuzzing found no failure:\n%s", out)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "FuzzFind", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("corpus files = %v, %v, want one", files, err)
+	}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{[]byte("FUZ")}; !reflect.DeepEqual(values, want) {
+		t.Errorf("recorded input %#v, want minimized %#v", values, want)
+	}
+	for _, want := range []string{
+		`found "FUZ"`,
+		"Failing input written to " + corpusDir,
+		"go test -run=FuzzFind/" + filepath.Base(files[0]),
+	} {
+		if !strings.Contains(out, want) && !strings.Contains(strings.Replace(out, dir, corpusDir, -1), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
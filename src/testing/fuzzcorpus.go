@@ -0,0 +1,319 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reading and writing the stored corpus of fuzz targets.
+//
+// Each input is stored in a file of its own, which starts with a header
+// line and then holds one argument per line, written as a Go conversion:
+//
+//	go test fuzz v1
+//	[]byte("GIF89a\x00")
+//	int(-3)
+//	rune('☺')
+
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const corpusHeader = "go test fuzz v1"
+
+// marshalCorpusFile encodes values in the corpus file format.
+func marshalCorpusFile(values []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(corpusHeader + "\n")
+	for _, v := range values {
+		switch v := v.(type) {
+		case []byte:
+			fmt.Fprintf(&b, "[]byte(%s)\n", strconv.Quote(string(v)))
+		case string:
+			fmt.Fprintf(&b, "string(%s)\n", strconv.Quote(v))
+		case uint8:
+			fmt.Fprintf(&b, "byte(%s)\n", strconv.QuoteRune(rune(v)))
+		case int32:
+			if utf8.ValidRune(v) {
+				fmt.Fprintf(&b, "rune(%s)\n", strconv.QuoteRune(v))
+			} else {
+				fmt.Fprintf(&b, "int32(%d)\n", v)
+			}
+		case float32:
+			if f := float64(v); math.IsInf(f, 0) || math.IsNaN(f) {
+				fmt.Fprintf(&b, "math.Float32frombits(%#x)\n", math.Float32bits(v))
+			} else {
+				fmt.Fprintf(&b, "float32(%s)\n", strconv.FormatFloat(f, 'g', -1, 32))
+			}
+		case float64:
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				fmt.Fprintf(&b, "math.Float64frombits(%#x)\n", math.Float64bits(v))
+			} else {
+				fmt.Fprintf(&b, "float64(%s)\n", strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		default:
+			// bool and the other integer types.
+			fmt.Fprintf(&b, "%T(%v)\n", v, v)
+		}
+	}
+	return b.Bytes()
+}
+
+// unmarshalCorpusFile decodes the values in a corpus file.
+func unmarshalCorpusFile(data []byte) ([]interface{}, error) {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) == 0 || lines[0] != corpusHeader {
+		return nil, errors.New("missing header line " + strconv.Quote(corpusHeader))
+	}
+	var values []interface{}
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		v, err := parseCorpusValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no values")
+	}
+	return values, nil
+}
+
+// parseCorpusValue parses a line of the form typ(literal).
+func parseCorpusValue(line string) (interface{}, error) {
+	open := strings.Index(line, "(")
+	if open < 0 || !strings.HasSuffix(line, ")") {
+		return nil, fmt.Errorf("malformed value %q", line)
+	}
+	typ, lit := line[:open], line[open+1:len(line)-1]
+	switch typ {
+	case "[]byte", "string":
+		s, err := strconv.Unquote(lit)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s literal %s", typ, lit)
+		}
+		if typ == "string" {
+			return s, nil
+		}
+		return []byte(s), nil
+	case "byte", "rune":
+		r, err := parseRune(lit)
+		if err != nil || typ == "byte" && r > math.MaxUint8 {
+			return nil, fmt.Errorf("malformed %s literal %s", typ, lit)
+		}
+		if typ == "byte" {
+			return byte(r), nil
+		}
+		return r, nil
+	case "bool":
+		b, err := strconv.ParseBool(lit)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bool literal %s", lit)
+		}
+		return b, nil
+	case "math.Float32frombits":
+		u, err := strconv.ParseUint(lit, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed float32 bits %s", lit)
+		}
+		return math.Float32frombits(uint32(u)), nil
+	case "math.Float64frombits":
+		u, err := strconv.ParseUint(lit, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed float64 bits %s", lit)
+		}
+		return math.Float64frombits(u), nil
+	case "float32":
+		f, err := strconv.ParseFloat(lit, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed float32 literal %s", lit)
+		}
+		return float32(f), nil
+	case "float64":
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed float64 literal %s", lit)
+		}
+		return f, nil
+	}
+
+	// An integer type.
+	var bits int
+	var signed bool
+	switch typ {
+	case "int", "int64":
+		bits, signed = 64, true
+	case "int8":
+		bits, signed = 8, true
+	case "int16":
+		bits, signed = 16, true
+	case "int32":
+		bits, signed = 32, true
+	case "uint", "uint64":
+		bits = 64
+	case "uint8":
+		bits = 8
+	case "uint16":
+		bits = 16
+	case "uint32":
+		bits = 32
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	if signed {
+		n, err := strconv.ParseInt(lit, 0, bits)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s literal %s", typ, lit)
+		}
+		switch typ {
+		case "int":
+			return int(n), nil
+		case "int8":
+			return int8(n), nil
+		case "int16":
+			return int16(n), nil
+		case "int32":
+			return int32(n), nil
+		}
+		return n, nil
+	}
+	n, err := strconv.ParseUint(lit, 0, bits)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s literal %s", typ, lit)
+	}
+	switch typ {
+	case "uint":
+		return uint(n), nil
+	case "uint8":
+		return uint8(n), nil
+	case "uint16":
+		return uint16(n), nil
+	case "uint32":
+		return uint32(n), nil
+	}
+	return n, nil
+}
+
+// parseRune parses a quoted character or an integer.
+func parseRune(lit string) (rune, error) {
+	if strings.HasPrefix(lit, "'") {
+		s, err := strconv.Unquote(lit)
+		if err != nil {
+			return 0, err
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		if size != len(s) {
+			return 0, errors.New("not a single character")
+		}
+		return r, nil
+	}
+	n, err := strconv.ParseInt(lit, 0, 32)
+	return rune(n), err
+}
+
+// checkCorpusTypes reports whether values match the fuzzed argument types.
+func checkCorpusTypes(values []interface{}, types []reflect.Type) error {
+	if len(values) != len(types) {
+		return fmt.Errorf("wrong number of values in corpus entry: %d, want %d", len(values), len(types))
+	}
+	for i, v := range values {
+		if t := reflect.TypeOf(v); t != types[i] {
+			return fmt.Errorf("mismatched types in corpus entry: %v, want %v", t, types[i])
+		}
+	}
+	return nil
+}
+
+// readCorpus reads the inputs stored in dir, which need not exist.
+func readCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	d, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var corpus []corpusEntry
+	for _, name := range names {
+		file := dir + "/" + name
+		data, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		values, err := unmarshalCorpusFile(data)
+		if err == nil {
+			err = checkCorpusTypes(values, types)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		corpus = append(corpus, corpusEntry{name: name, values: values})
+	}
+	return corpus, nil
+}
+
+// writeCorpusFile stores values in dir, creating it if needed, under a
+// name derived from their encoding, which it returns.
+func writeCorpusFile(dir string, values []interface{}) (name string, err error) {
+	data := marshalCorpusFile(values)
+	name = fmt.Sprintf("%016x", fnv64a(data))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	f, err := os.Create(dir + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	return name, f.Close()
+}
+
+func readFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	_, err = b.ReadFrom(f)
+	return b.Bytes(), err
+}
+
+// fnv64a returns the 64-bit FNV-1a hash of data. It is written out here
+// because the tests of hash/fnv import package testing.
+func fnv64a(data []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range data {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,369 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Input generation, minimization and coverage tracking for fuzzing.
+
+package testing
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// maxInputLen is the length beyond which the mutator stops growing
+// []byte and string inputs.
+const maxInputLen = 1 << 20
+
+// A mutator derives new fuzz inputs from old ones.
+//
+// It has its own xorshift generator rather than a math/rand source,
+// since the tests of math/rand import package testing.
+type mutator struct {
+	x uint64
+}
+
+func newMutator() *mutator {
+	return &mutator{x: uint64(time.Now().UnixNano()) | 1}
+}
+
+// uint64 returns the next number of the xorshift64* sequence.
+func (m *mutator) uint64() uint64 {
+	m.x ^= m.x >> 12
+	m.x ^= m.x << 25
+	m.x ^= m.x >> 27
+	return m.x * 2685821657736338717
+}
+
+// intn returns a number in [0, n).
+func (m *mutator) intn(n int) int {
+	return int(m.uint64() % uint64(n))
+}
+
+// mutate returns a copy of values with one of them changed.
+func (m *mutator) mutate(values []interface{}) []interface{} {
+	values = copyValues(values)
+	i := m.intn(len(values))
+	switch v := values[i].(type) {
+	case []byte:
+		values[i] = m.mutateBytes(v)
+	case string:
+		values[i] = string(m.mutateBytes([]byte(v)))
+	case bool:
+		values[i] = !v
+	case int:
+		values[i] = int(m.mutateInt(int64(v), 64))
+	case int8:
+		values[i] = int8(m.mutateInt(int64(v), 8))
+	case int16:
+		values[i] = int16(m.mutateInt(int64(v), 16))
+	case int32:
+		values[i] = int32(m.mutateInt(int64(v), 32))
+	case int64:
+		values[i] = m.mutateInt(v, 64)
+	case uint:
+		values[i] = uint(m.mutateInt(int64(v), 64))
+	case uint8:
+		values[i] = uint8(m.mutateInt(int64(v), 8))
+	case uint16:
+		values[i] = uint16(m.mutateInt(int64(v), 16))
+	case uint32:
+		values[i] = uint32(m.mutateInt(int64(v), 32))
+	case uint64:
+		values[i] = uint64(m.mutateInt(int64(v), 64))
+	case float32:
+		values[i] = float32(m.mutateFloat(float64(v)))
+	case float64:
+		values[i] = m.mutateFloat(v)
+	}
+	return values
+}
+
+// mutateInt returns v changed in one of its low bits bits. The caller
+// truncates the result to the width of its type.
+func (m *mutator) mutateInt(v int64, bits uint) int64 {
+	switch m.intn(4) {
+	case 0:
+		return v + int64(m.intn(16)) + 1
+	case 1:
+		return v - int64(m.intn(16)) - 1
+	case 2:
+		return v ^ 1<<uint(m.intn(int(bits)))
+	default:
+		return int64(m.uint64())
+	}
+}
+
+// interestingFloats are boundary values worth trying as float inputs.
+var interestingFloats = []float64{0, -1, 1, math.Inf(1), math.Inf(-1), math.NaN(), math.MaxFloat64, math.SmallestNonzeroFloat64}
+
+func (m *mutator) mutateFloat(v float64) float64 {
+	switch m.intn(5) {
+	case 0:
+		return v + float64(m.intn(16)+1)
+	case 1:
+		return v - float64(m.intn(16)+1)
+	case 2:
+		return -v
+	case 3:
+		// Scale by a factor in [-2, 2).
+		return v * (float64(m.uint64()>>11)/(1<<53)*4 - 2)
+	default:
+		return interestingFloats[m.intn(len(interestingFloats))]
+	}
+}
+
+// interestingBytes are byte values that often sit on boundaries in parsers.
+var interestingBytes = []byte{0, 1, 0x7f, 0x80, 0xff, ' ', '0', '\n'}
+
+// mutateBytes returns b, which it may modify in place, with between
+// one and four random edits applied.
+func (m *mutator) mutateBytes(b []byte) []byte {
+	for n := m.intn(4) + 1; n > 0; n-- {
+		if len(b) == 0 {
+			b = m.insert(b)
+			continue
+		}
+		switch m.intn(8) {
+		case 0:
+			b = m.insert(b)
+		case 1:
+			// Remove a range.
+			i := m.intn(len(b))
+			j := i + m.intn(len(b)-i) + 1
+			b = append(b[:i], b[j:]...)
+		case 2:
+			// Duplicate a range at a random position.
+			if len(b) >= maxInputLen {
+				break
+			}
+			i := m.intn(len(b))
+			j := i + m.intn(len(b)-i) + 1
+			chunk := append([]byte(nil), b[i:j]...)
+			k := m.intn(len(b) + 1)
+			b = append(b[:k], append(chunk, b[k:]...)...)
+		case 3:
+			b[m.intn(len(b))] ^= 1 << uint(m.intn(8))
+		case 4:
+			b[m.intn(len(b))] = byte(m.intn(256))
+		case 5:
+			b[m.intn(len(b))] = interestingBytes[m.intn(len(interestingBytes))]
+		case 6:
+			i, j := m.intn(len(b)), m.intn(len(b))
+			b[i], b[j] = b[j], b[i]
+		case 7:
+			b[m.intn(len(b))] += byte(m.intn(35) - 17)
+		}
+	}
+	return b
+}
+
+// insert inserts up to eight random bytes at a random position in b.
+func (m *mutator) insert(b []byte) []byte {
+	if len(b) >= maxInputLen {
+		return b
+	}
+	chunk := make([]byte, m.intn(8)+1)
+	for i := range chunk {
+		chunk[i] = byte(m.intn(256))
+	}
+	k := m.intn(len(b) + 1)
+	return append(b[:k], append(chunk, b[k:]...)...)
+}
+
+// minimizeInput returns the smallest variant of values it finds for which
+// fails still reports true. It shortens []byte and string values and moves
+// numbers towards zero, and gives up at deadline, if that is not zero.
+func minimizeInput(values []interface{}, fails func([]interface{}) bool, deadline time.Time) []interface{} {
+	values = copyValues(values)
+	expired := func() bool {
+		return !deadline.IsZero() && time.Now().After(deadline)
+	}
+	// try replaces values[i] by v if the input still fails with it.
+	try := func(i int, v interface{}) bool {
+		if expired() {
+			return false
+		}
+		cand := copyValues(values)
+		cand[i] = v
+		if !fails(cand) {
+			return false
+		}
+		values = cand
+		return true
+	}
+	for i := range values {
+		switch v := values[i].(type) {
+		case []byte:
+			minimizeBytes(v, func(b []byte) bool { return try(i, b) }, expired)
+		case string:
+			minimizeBytes([]byte(v), func(b []byte) bool { return try(i, string(b)) }, expired)
+		case bool:
+			try(i, false)
+		case float32:
+			if !try(i, float32(0)) {
+				try(i, float32(math.Trunc(float64(v))))
+			}
+		case float64:
+			if !try(i, float64(0)) {
+				try(i, math.Trunc(v))
+			}
+		default:
+			// An integer. Halve it until the input no longer fails.
+			rv := reflect.ValueOf(v)
+			for !expired() {
+				var next reflect.Value
+				switch rv.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+					if rv.Int() == 0 {
+						break
+					}
+					next = reflect.New(rv.Type()).Elem()
+					next.SetInt(rv.Int() / 2)
+				default:
+					if rv.Uint() == 0 {
+						break
+					}
+					next = reflect.New(rv.Type()).Elem()
+					next.SetUint(rv.Uint() / 2)
+				}
+				if !next.IsValid() || !try(i, next.Interface()) {
+					break
+				}
+				rv = next
+			}
+		}
+	}
+	return values
+}
+
+// minimizeBytes removes ever smaller chunks of b, keeping each removal
+// that try accepts.
+func minimizeBytes(b []byte, try func([]byte) bool, expired func() bool) {
+	if len(b) == 0 || try([]byte{}) {
+		return
+	}
+	for chunk := len(b) / 2; chunk >= 1; chunk /= 2 {
+		for off := 0; off+chunk <= len(b); {
+			if expired() {
+				return
+			}
+			cand := append(append([]byte{}, b[:off]...), b[off+chunk:]...)
+			if try(cand) {
+				b = cand
+			} else {
+				off += chunk
+			}
+		}
+	}
+}
+
+// copyValues returns a copy of values that shares no []byte with it.
+func copyValues(values []interface{}) []interface{} {
+	c := make([]interface{}, len(values))
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = append([]byte{}, b...)
+		}
+		c[i] = v
+	}
+	return c
+}
+
+// zeroValues returns the zero value of each of types.
+func zeroValues(types []reflect.Type) []interface{} {
+	values := make([]interface{}, len(types))
+	for i, t := range types {
+		values[i] = reflect.Zero(t).Interface()
+	}
+	return values
+}
+
+// A coverTracker watches the coverage counters to tell which fuzz inputs
+// reach new code. Like AFL, it considers a counter's hit count only up
+// to the power of two bucket it falls in, so that an input that runs a
+// loop a few more times than before does not count as new.
+type coverTracker struct {
+	counters [][]uint32 // the coverage counters, by file name
+	seen     [][]uint8  // for each counter, the buckets reached so far
+	totals   [][]uint32 // the counts of the inputs run so far
+}
+
+func newCoverTracker(counters map[string][]uint32) *coverTracker {
+	var names []string
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c := new(coverTracker)
+	for _, name := range names {
+		n := len(counters[name])
+		c.counters = append(c.counters, counters[name])
+		c.seen = append(c.seen, make([]uint8, n))
+		c.totals = append(c.totals, make([]uint32, n))
+	}
+	return c
+}
+
+// reset zeroes the counters before an input runs.
+func (c *coverTracker) reset() {
+	for i, counters := range c.counters {
+		for j := range counters {
+			c.totals[i][j] += atomic.LoadUint32(&counters[j])
+			atomic.StoreUint32(&counters[j], 0)
+		}
+	}
+}
+
+// update reports whether the input run since the last reset reached
+// a counter bucket that no earlier input reached.
+func (c *coverTracker) update() bool {
+	found := false
+	for i, counters := range c.counters {
+		for j := range counters {
+			b := bucket(atomic.LoadUint32(&counters[j]))
+			if b&^c.seen[i][j] != 0 {
+				c.seen[i][j] |= b
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// restore puts the counts of all inputs back in the counters, for the
+// coverage report.
+func (c *coverTracker) restore() {
+	for i, counters := range c.counters {
+		for j := range counters {
+			atomic.AddUint32(&counters[j], c.totals[i][j])
+			c.totals[i][j] = 0
+		}
+	}
+}
+
+// bucket returns a bit identifying the range of n.
+func bucket(n uint32) uint8 {
+	switch {
+	case n == 0:
+		return 0
+	case n == 1:
+		return 1 << 0
+	case n == 2:
+		return 1 << 1
+	case n == 3:
+		return 1 << 2
+	case n <= 7:
+		return 1 << 3
+	case n <= 15:
+		return 1 << 4
+	case n <= 31:
+		return 1 << 5
+	case n <= 127:
+		return 1 << 6
+	}
+	return 1 << 7
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// runRoot runs f as a subtest of a fresh root test and returns its output.
+func runRoot(name string, f func(t *T)) string {
+	ctx := newTestContext(1, newMatcher(regexp.MatchString, "", ""))
+	buf := &bytes.Buffer{}
+	root := &T{
+		common: common{
+			signal: make(chan bool),
+			name:   "Test",
+			w:      buf,
+		},
+		context: ctx,
+	}
+	root.Run(name, f)
+	ctx.release()
+	return buf.String()
+}
+
+// callerLine returns the line number of its caller.
+func callerLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+// notHelper returns the line of its call to Error.
+func notHelper(t *T, msg string) int {
+	t.Error(msg)
+	return callerLine() - 1
+}
+
+func helper(t *T, msg string) {
+	t.Helper()
+	t.Error(msg)
+}
+
+func helperCallingHelper(t *T, msg string) {
+	t.Helper()
+	helper(t, msg)
+}
+
+func TestHelper(t *T) {
+	var lines [4]int
+	out := runRoot("helper", func(t *T) {
+		lines[0] = notHelper(t, "0")
+		helper(t, "1")
+		lines[1] = callerLine() - 1
+		helperCallingHelper(t, "2")
+		lines[2] = callerLine() - 1
+		t.Run("sub", func(t *T) {
+			helper(t, "3")
+			lines[3] = callerLine() - 1
+		})
+	})
+	want := []string{
+		fmt.Sprintf("helper_test.go:%d: 0", lines[0]),
+		fmt.Sprintf("helper_test.go:%d: 1", lines[1]),
+		fmt.Sprintf("helper_test.go:%d: 2", lines[2]),
+		fmt.Sprintf("helper_test.go:%d: 3", lines[3]),
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %q:\n%s", w, out)
+		}
+	}
+}
+
+func TestHelperOnly(t *T) {
+	// When every function up to the test runner is a helper, the call
+	// in the outermost one is reported.
+	var line int
+	out := runRoot("helper only", func(t *T) {
+		t.Helper()
+		t.Error("x")
+		line = callerLine() - 1
+	})
+	if want := fmt.Sprintf("helper_test.go:%d: x", line); !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+}
+
+func TestCleanup(t *T) {
+	var order []string
+	runRoot("cleanup", func(t *T) {
+		t.Cleanup(func() { order = append(order, "first") })
+		t.Cleanup(func() { order = append(order, "second") })
+		t.Run("sub", func(t *T) {
+			t.Parallel()
+			t.Cleanup(func() { order = append(order, "sub") })
+		})
+		order = append(order, "test")
+	})
+	want := "test sub second first"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("cleanup order = %q, want %q", got, want)
+	}
+}
+
+func TestCleanupFailNow(t *T) {
+	ran := false
+	out := runRoot("cleanup", func(t *T) {
+		t.Cleanup(func() { ran = true })
+		t.Cleanup(func() { t.Fatal("cleanup failed") })
+	})
+	if !ran {
+		t.Errorf("FailNow in a cleanup function stopped the other cleanup functions")
+	}
+	if !strings.Contains(out, "--- FAIL: cleanup") || !strings.Contains(out, "cleanup failed") {
+		t.Errorf("cleanup failure not reported:\n%s", out)
+	}
+}
+
+func TestTempDir(t *T) {
+	var dirs []string
+	out := runRoot("temp/dir", func(t *T) {
+		for i := 0; i < 2; i++ {
+			dir := t.TempDir()
+			fi, err := os.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.IsDir() {
+				t.Fatalf("%s is not a directory", dir)
+			}
+			dirs = append(dirs, dir)
+		}
+	})
+	if strings.Contains(out, "FAIL") {
+		t.Fatalf("TempDir failed:\n%s", out)
+	}
+	if dirs[0] == dirs[1] {
+		t.Errorf("TempDir returned %s twice", dirs[0])
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s not removed after the test: %v", dir, err)
+		}
+	}
+}
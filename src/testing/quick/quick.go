@@ -12,9 +12,11 @@ import (
 	"math/rand"
 	"reflect"
 	"strings"
+	"time"
 )
 
 var defaultMaxCount *int = flag.Int("quickchecks", 100, "The default number of iterations for each check")
+var defaultSeed *int64 = flag.Int64("quickseed", 0, "The seed of the default source of random values, if nonzero")
 
 // A Generator can generate random values of its own type.
 type Generator interface {
@@ -156,16 +158,49 @@ type Config struct {
 	// being tested. Otherwise, the top-level Values function is used
 	// to generate them.
 	Values func([]reflect.Value, *rand.Rand)
+	// If Rand is nil, Seed seeds the default source of random values.
+	// If Seed is zero too, the -quickseed flag is used, and if that is
+	// zero, the source is seeded with 0, so that every run generates the
+	// same values. A nonzero seed is reported in a *CheckError, so that
+	// setting Seed to it replays the failing check.
+	Seed int64
+	// If RandomSeed is set, a seed chosen from the time is used in place
+	// of the fixed seed 0, so that every run generates different values.
+	RandomSeed bool
+	// MaxShrinks sets the maximum number of steps taken to shrink a
+	// failing input. If zero, the default is 1000. If negative, or if
+	// Values is non-nil, failing inputs are reported as generated.
+	MaxShrinks int
 }
 
 var defaultConfig Config
 
-// getRand returns the *rand.Rand to use for a given Config.
-func (c *Config) getRand() *rand.Rand {
-	if c.Rand == nil {
-		return rand.New(rand.NewSource(0))
+// getRand returns the *rand.Rand to use for a given Config and the seed
+// it was created with, which is zero if the Config supplies its own.
+func (c *Config) getRand() (r *rand.Rand, seed int64) {
+	if c.Rand != nil {
+		return c.Rand, 0
 	}
-	return c.Rand
+	seed = c.Seed
+	if seed == 0 {
+		seed = *defaultSeed
+	}
+	if seed == 0 && c.RandomSeed {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed)), seed
+}
+
+// getMaxShrinks returns the maximum number of shrinking steps for a given
+// Config.
+func (c *Config) getMaxShrinks() int {
+	if c.Values != nil || c.MaxShrinks < 0 {
+		return 0
+	}
+	if c.MaxShrinks == 0 {
+		return defaultMaxShrinks
+	}
+	return c.MaxShrinks
 }
 
 // getMaxCount returns the maximum number of iterations to run for a given
@@ -191,12 +226,29 @@ func (s SetupError) Error() string { return string(s) }
 
 // A CheckError is the result of Check finding an error.
 type CheckError struct {
-	Count int
-	In    []interface{}
+	Count   int
+	In      []interface{}
+	Seed    int64 // Seed of the default source of random values, or zero.
+	Shrinks int   // Number of steps taken to shrink In.
 }
 
 func (s *CheckError) Error() string {
-	return fmt.Sprintf("#%d: failed on input %s", s.Count, toString(s.In))
+	return fmt.Sprintf("#%d: failed on input %s%s", s.Count, toString(s.In), s.details())
+}
+
+// details describes the shrinking and seed of the failing input.
+func (s *CheckError) details() string {
+	var d []string
+	if s.Shrinks > 0 {
+		d = append(d, fmt.Sprintf("shrunk in %d steps", s.Shrinks))
+	}
+	if s.Seed != 0 {
+		d = append(d, fmt.Sprintf("seed %d", s.Seed))
+	}
+	if len(d) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(d, ", ") + ")"
 }
 
 // A CheckEqualError is the result CheckEqual finding an error.
@@ -207,13 +259,14 @@ type CheckEqualError struct {
 }
 
 func (s *CheckEqualError) Error() string {
-	return fmt.Sprintf("#%d: failed on input %s. Output 1: %s. Output 2: %s", s.Count, toString(s.In), toString(s.Out1), toString(s.Out2))
+	return fmt.Sprintf("#%d: failed on input %s%s. Output 1: %s. Output 2: %s", s.Count, toString(s.In), s.details(), toString(s.Out1), toString(s.Out2))
 }
 
 // Check looks for an input to f, any function that returns bool,
 // such that f returns false.  It calls f repeatedly, with arbitrary
 // values for each argument.  If f returns false on a given input,
-// Check returns that input as a *CheckError.
+// Check returns that input as a *CheckError, after shrinking it to the
+// simplest input it can find on which f still returns false.
 // For example:
 //
 // 	func TestOddMultipleOfThree(t *testing.T) {
@@ -246,8 +299,11 @@ func Check(f interface{}, config *Config) (err error) {
 	}
 
 	arguments := make([]reflect.Value, fType.NumIn())
-	rand := config.getRand()
+	rand, seed := config.getRand()
 	maxCount := config.getMaxCount()
+	fails := func(args []reflect.Value) bool {
+		return !fVal.Call(args)[0].Bool()
+	}
 
 	for i := 0; i < maxCount; i++ {
 		err = arbitraryValues(arguments, fType, config, rand)
@@ -255,8 +311,9 @@ func Check(f interface{}, config *Config) (err error) {
 			return
 		}
 
-		if !fVal.Call(arguments)[0].Bool() {
-			err = &CheckError{i + 1, toInterfaces(arguments)}
+		if fails(arguments) {
+			shrinks := shrinkArgs(arguments, fails, config.getMaxShrinks())
+			err = &CheckError{i + 1, toInterfaces(arguments), seed, shrinks}
 			return
 		}
 	}
@@ -267,7 +324,7 @@ func Check(f interface{}, config *Config) (err error) {
 // CheckEqual looks for an input on which f and g return different results.
 // It calls f and g repeatedly with arbitrary values for each argument.
 // If f and g return different answers, CheckEqual returns a *CheckEqualError
-// describing the input and the outputs.
+// describing the input, shrunk as by Check, and the outputs.
 func CheckEqual(f, g interface{}, config *Config) (err error) {
 	if config == nil {
 		config = &defaultConfig
@@ -290,8 +347,11 @@ func CheckEqual(f, g interface{}, config *Config) (err error) {
 	}
 
 	arguments := make([]reflect.Value, xType.NumIn())
-	rand := config.getRand()
+	rand, seed := config.getRand()
 	maxCount := config.getMaxCount()
+	fails := func(args []reflect.Value) bool {
+		return !reflect.DeepEqual(toInterfaces(x.Call(args)), toInterfaces(y.Call(args)))
+	}
 
 	for i := 0; i < maxCount; i++ {
 		err = arbitraryValues(arguments, xType, config, rand)
@@ -299,11 +359,11 @@ func CheckEqual(f, g interface{}, config *Config) (err error) {
 			return
 		}
 
-		xOut := toInterfaces(x.Call(arguments))
-		yOut := toInterfaces(y.Call(arguments))
-
-		if !reflect.DeepEqual(xOut, yOut) {
-			err = &CheckEqualError{CheckError{i + 1, toInterfaces(arguments)}, xOut, yOut}
+		if fails(arguments) {
+			shrinks := shrinkArgs(arguments, fails, config.getMaxShrinks())
+			xOut := toInterfaces(x.Call(arguments))
+			yOut := toInterfaces(y.Call(arguments))
+			err = &CheckEqualError{CheckError{i + 1, toInterfaces(arguments), seed, shrinks}, xOut, yOut}
 			return
 		}
 	}
@@ -247,3 +247,179 @@ func TestFailure(t *testing.T) {
 		t.Errorf("#3 Error was not a SetupError: %s", err)
 	}
 }
+
+func TestShrink(t *testing.T) {
+	type pair struct {
+		A int
+		B string
+	}
+	tests := []struct {
+		in   interface{}
+		want interface{} // the simplest shrink of in
+	}{
+		{true, false},
+		{int8(-5), int8(0)},
+		{uint(7), uint(0)},
+		{2.5, 0.0},
+		{"hello", ""},
+		{[]int{1, 2, 3}, []int{}},
+		{map[string]int{"a": 1}, map[string]int{}},
+		{pair{3, ""}, pair{0, ""}},
+		{[2]int{0, 4}, [2]int{0, 0}},
+	}
+	for _, tt := range tests {
+		s := Shrink(reflect.ValueOf(tt.in))
+		if len(s) == 0 {
+			t.Errorf("Shrink(%#v) = none, want %#v first", tt.in, tt.want)
+			continue
+		}
+		if got := s[0].Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Shrink(%#v)[0] = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []interface{}{false, 0, uint8(0), 0.0, "", []int{}, map[int]int{}, pair{}} {
+		if s := Shrink(reflect.ValueOf(in)); len(s) != 0 {
+			t.Errorf("Shrink(%#v) = %d values, want none", in, len(s))
+		}
+	}
+}
+
+func TestShrinkRunes(t *testing.T) {
+	s := Shrink(reflect.ValueOf("xa"))
+	var got []string
+	for _, v := range s {
+		got = append(got, v.String())
+	}
+	// The shorter strings first, then the runes simplified.
+	want := []string{"", "x", "a", "a", "x", "aa", "la", "wa"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Shrink(%q) = %q, want %q", "xa", got, want)
+	}
+
+	err := Check(func(s string) bool { return len([]rune(s)) < 3 }, nil)
+	cerr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("Check = %v, want *CheckError", err)
+	}
+	if got := cerr.In[0].(string); got != "aaa" {
+		t.Errorf("shrunk input = %q, want \"aaa\"", got)
+	}
+}
+
+func TestShrinkMapKeys(t *testing.T) {
+	s := Shrink(reflect.ValueOf(map[int]bool{0: true, 6: true}))
+	found := false
+	for _, v := range s {
+		m := v.Interface().(map[int]bool)
+		if len(m) == 2 && m[0] && m[3] {
+			found = true
+		}
+		if len(m) == 2 && !m[0] && !m[6] {
+			t.Errorf("Shrink moved an entry onto an existing key: %v", m)
+		}
+	}
+	if !found {
+		t.Errorf("Shrink(map[0:true 6:true]) does not shrink key 6 to 3")
+	}
+
+	err := Check(func(m map[int]bool) bool {
+		for k := range m {
+			if k >= 10 {
+				return false
+			}
+		}
+		return true
+	}, nil)
+	cerr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("Check = %v, want *CheckError", err)
+	}
+	want := map[int]bool{10: false}
+	if got := cerr.In[0].(map[int]bool); !reflect.DeepEqual(got, want) {
+		t.Errorf("shrunk input = %v, want %v", got, want)
+	}
+}
+
+// evenInt shrinks only to even numbers.
+type evenInt int
+
+func (e evenInt) Shrink() []reflect.Value {
+	if e <= 0 {
+		return nil
+	}
+	return []reflect.Value{reflect.ValueOf(e / 2 &^ 1)}
+}
+
+func TestShrinker(t *testing.T) {
+	err := Check(func(e evenInt) bool { return e < 10 }, &Config{Rand: rand.New(rand.NewSource(1))})
+	cerr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("Check = %v, want *CheckError", err)
+	}
+	if got := cerr.In[0].(evenInt); got < 10 || got%2 != 0 || cerr.Shrinks == 0 {
+		t.Errorf("shrunk input = %d in %d steps, want even number of at least 10", got, cerr.Shrinks)
+	}
+}
+
+func TestCheckShrinks(t *testing.T) {
+	err := Check(func(x int, s []int) bool { return x < 100 || len(s) < 3 }, nil)
+	cerr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("Check = %v, want *CheckError", err)
+	}
+	want := []interface{}{100, []int{0, 0, 0}}
+	if !reflect.DeepEqual(cerr.In, want) {
+		t.Errorf("shrunk input = %#v, want %#v", cerr.In, want)
+	}
+	if cerr.Shrinks == 0 {
+		t.Errorf("Shrinks = 0, want shrinking steps")
+	}
+
+	err = CheckEqual(func(x uint16) bool { return x < 1000 }, func(x uint16) bool { return true }, nil)
+	eerr, ok := err.(*CheckEqualError)
+	if !ok {
+		t.Fatalf("CheckEqual = %v, want *CheckEqualError", err)
+	}
+	if x := eerr.In[0].(uint16); x != 1000 {
+		t.Errorf("shrunk input = %d, want 1000", x)
+	}
+
+	err = Check(func(x int) bool { return x < 100 }, &Config{MaxShrinks: -1})
+	if cerr, ok := err.(*CheckError); !ok || cerr.Shrinks != 0 {
+		t.Errorf("Check with MaxShrinks -1 = %v, want unshrunk *CheckError", err)
+	}
+}
+
+func TestDefaultSeed(t *testing.T) {
+	f := func(x int) bool { return x%7 != 0 }
+	err1 := Check(f, &Config{MaxShrinks: -1})
+	err2 := Check(f, &Config{MaxShrinks: -1})
+	cerr1, ok1 := err1.(*CheckError)
+	cerr2, ok2 := err2.(*CheckError)
+	if !ok1 || !ok2 {
+		t.Fatalf("Check = %v, %v, want *CheckError", err1, err2)
+	}
+	if cerr1.Seed != 0 {
+		t.Errorf("CheckError.Seed = %d, want 0 for the fixed default seed", cerr1.Seed)
+	}
+	if cerr1.Count != cerr2.Count || !reflect.DeepEqual(cerr1.In, cerr2.In) {
+		t.Errorf("checks with the default seed differ: %v and %v", cerr1, cerr2)
+	}
+}
+
+func TestSeedReplay(t *testing.T) {
+	f := func(x int) bool { return x%7 != 0 }
+	err := Check(f, &Config{MaxShrinks: -1, RandomSeed: true})
+	cerr, ok := err.(*CheckError)
+	if !ok {
+		t.Fatalf("Check = %v, want *CheckError", err)
+	}
+	if cerr.Seed == 0 {
+		t.Fatalf("CheckError.Seed = 0, want the seed used")
+	}
+	err = Check(f, &Config{MaxShrinks: -1, Seed: cerr.Seed})
+	replay, ok := err.(*CheckError)
+	if !ok || replay.Count != cerr.Count || !reflect.DeepEqual(replay.In, cerr.In) {
+		t.Errorf("replay with seed %d = %v, want %v", cerr.Seed, err, cerr)
+	}
+}
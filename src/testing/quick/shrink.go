@@ -0,0 +1,295 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quick
+
+import (
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+// A Shrinker can produce simpler values of its own type, for Check and
+// CheckEqual to try in place of a failing input.
+type Shrinker interface {
+	// Shrink returns values of the type on which it is a method that are
+	// simpler than the receiver, simplest first. It returns nil if there
+	// is no simpler value.
+	Shrink() []reflect.Value
+}
+
+// defaultMaxShrinks is the number of shrinking steps taken when
+// Config.MaxShrinks is zero.
+const defaultMaxShrinks = 1000
+
+// Shrink returns values of v's type that are simpler than v, simplest
+// first. If the type implements the Shrinker interface, that will be used.
+// Otherwise numbers shrink towards zero, strings, slices and maps shrink
+// by dropping elements and then by shrinking the elements that remain,
+// and arrays, structs and pointers shrink by shrinking what they hold.
+// The runes of a string shrink towards 'a', and the keys of a map shrink
+// like its values, to keys not already in the map.
+// Shrink returns nil for other types and for values with nothing simpler.
+func Shrink(v reflect.Value) []reflect.Value {
+	if v.CanInterface() {
+		if s, ok := v.Interface().(Shrinker); ok {
+			return s.Shrink()
+		}
+	}
+
+	t := v.Type()
+	var out []reflect.Value
+	add := func(x reflect.Value) {
+		out = append(out, x)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			add(reflect.Zero(t))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		for _, x := range shrinkInt(v.Int()) {
+			n := reflect.New(t).Elem()
+			n.SetInt(x)
+			add(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		for _, x := range shrinkUint(v.Uint()) {
+			n := reflect.New(t).Elem()
+			n.SetUint(x)
+			add(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		for _, x := range shrinkFloat(v.Float()) {
+			n := reflect.New(t).Elem()
+			n.SetFloat(x)
+			add(n)
+		}
+	case reflect.String:
+		runes := []rune(v.String())
+		for _, r := range shrinkRunes(runes) {
+			n := reflect.New(t).Elem()
+			n.SetString(string(r))
+			add(n)
+		}
+		for i, r := range runes {
+			for _, x := range shrinkRune(r) {
+				c := append([]rune{}, runes...)
+				c[i] = x
+				n := reflect.New(t).Elem()
+				n.SetString(string(c))
+				add(n)
+			}
+		}
+	case reflect.Slice:
+		l := v.Len()
+		if l == 0 {
+			break
+		}
+		add(reflect.MakeSlice(t, 0, 0))
+		if l > 1 {
+			add(appendSlice(t, v.Slice(0, l/2)))
+			add(appendSlice(t, v.Slice(l/2, l)))
+		}
+		for i := 0; i < l; i++ {
+			add(appendSlice(t, v.Slice(0, i), v.Slice(i+1, l)))
+		}
+		for i := 0; i < l; i++ {
+			for _, e := range Shrink(v.Index(i)) {
+				n := appendSlice(t, v)
+				n.Index(i).Set(e)
+				add(n)
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			for _, e := range Shrink(v.Index(i)) {
+				n := reflect.New(t).Elem()
+				n.Set(v)
+				n.Index(i).Set(e)
+				add(n)
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		if len(keys) == 0 {
+			break
+		}
+		add(reflect.MakeMap(t))
+		for _, k := range keys {
+			n := copyMap(v)
+			n.SetMapIndex(k, reflect.Value{})
+			add(n)
+		}
+		for _, k := range keys {
+			for _, e := range Shrink(v.MapIndex(k)) {
+				n := copyMap(v)
+				n.SetMapIndex(k, e)
+				add(n)
+			}
+		}
+		for _, k := range keys {
+			for _, e := range Shrink(k) {
+				if v.MapIndex(e).IsValid() {
+					// Moving the value there would drop an entry,
+					// which is the job of the shrinks above.
+					continue
+				}
+				n := copyMap(v)
+				n.SetMapIndex(k, reflect.Value{})
+				n.SetMapIndex(e, v.MapIndex(k))
+				add(n)
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				// Unexported fields cannot be set.
+				continue
+			}
+			for _, e := range Shrink(v.Field(i)) {
+				n := reflect.New(t).Elem()
+				n.Set(v)
+				n.Field(i).Set(e)
+				add(n)
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			break
+		}
+		for _, e := range Shrink(v.Elem()) {
+			n := reflect.New(t.Elem())
+			n.Elem().Set(e)
+			add(n)
+		}
+	}
+	return out
+}
+
+// shrinkInt returns the integers to try in place of x: zero, half of x,
+// and x moved one step towards zero.
+func shrinkInt(x int64) []int64 {
+	if x == 0 {
+		return nil
+	}
+	out := []int64{0}
+	if h := x / 2; h != 0 {
+		out = append(out, h)
+	}
+	step := x - 1
+	if x < 0 {
+		step = x + 1
+	}
+	if step != 0 && step != x/2 {
+		out = append(out, step)
+	}
+	return out
+}
+
+// shrinkUint is like shrinkInt for unsigned integers.
+func shrinkUint(x uint64) []uint64 {
+	if x == 0 {
+		return nil
+	}
+	out := []uint64{0}
+	if h := x / 2; h != 0 {
+		out = append(out, h)
+	}
+	if x-1 != 0 && x-1 != x/2 {
+		out = append(out, x-1)
+	}
+	return out
+}
+
+// shrinkFloat returns the floats to try in place of x: zero, x without
+// its fraction, and half of x.
+func shrinkFloat(x float64) []float64 {
+	if x == 0 {
+		return nil
+	}
+	out := []float64{0}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return out
+	}
+	if t := math.Trunc(x); t != 0 && t != x {
+		out = append(out, t)
+	}
+	if h := x / 2; h != 0 && h != x {
+		out = append(out, h)
+	}
+	return out
+}
+
+// shrinkRunes returns the shorter rune sequences to try in place of r:
+// the empty one, each half, and r without each of its runes.
+func shrinkRunes(r []rune) [][]rune {
+	if len(r) == 0 {
+		return nil
+	}
+	out := [][]rune{{}}
+	if len(r) > 1 {
+		out = append(out, r[:len(r)/2], r[len(r)/2:])
+	}
+	for i := range r {
+		out = append(out, append(append([]rune{}, r[:i]...), r[i+1:]...))
+	}
+	return out
+}
+
+// shrinkRune returns the runes to try in place of r: 'a', the rune
+// halfway between r and 'a', and r moved one step towards 'a'.
+func shrinkRune(r rune) []rune {
+	var out []rune
+	for _, d := range shrinkInt(int64(r - 'a')) {
+		if x := 'a' + rune(d); utf8.ValidRune(x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// appendSlice returns a new slice of type t holding the elements of parts.
+func appendSlice(t reflect.Type, parts ...reflect.Value) reflect.Value {
+	n := reflect.MakeSlice(t, 0, 0)
+	for _, p := range parts {
+		n = reflect.AppendSlice(n, p)
+	}
+	return n
+}
+
+// copyMap returns a new map holding the entries of m.
+func copyMap(m reflect.Value) reflect.Value {
+	n := reflect.MakeMap(m.Type())
+	for _, k := range m.MapKeys() {
+		n.SetMapIndex(k, m.MapIndex(k))
+	}
+	return n
+}
+
+// shrinkArgs repeatedly replaces one of args with a simpler value on
+// which fails still reports true, until no simpler value of any argument
+// fails or it has taken max steps. It returns the number of steps taken.
+func shrinkArgs(args []reflect.Value, fails func([]reflect.Value) bool, max int) (steps int) {
+	cand := make([]reflect.Value, len(args))
+	for steps < max {
+		shrunk := false
+		for i := 0; i < len(args) && steps < max; i++ {
+			for _, s := range Shrink(args[i]) {
+				copy(cand, args)
+				cand[i] = s
+				if fails(cand) {
+					args[i] = s
+					steps++
+					shrunk = true
+					break
+				}
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return
+}
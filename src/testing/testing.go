@@ -75,6 +75,86 @@
 //         })
 //     }
 //
+// Subtests and Sub-benchmarks
+//
+// The Run methods of T and B allow defining subtests and sub-benchmarks,
+// without having to define separate functions for each. This enables uses
+// like table-driven benchmarks and creating hierarchical tests.
+// It also provides a way to share common setup and tear-down code:
+//
+//     func TestFoo(t *testing.T) {
+//         // <setup code>
+//         t.Run("A=1", func(t *testing.T) { ... })
+//         t.Run("A=2", func(t *testing.T) { ... })
+//         t.Run("B=1", func(t *testing.T) { ... })
+//         // <tear-down code>
+//     }
+//
+// Each subtest and sub-benchmark has a unique name: the combination of the name
+// of the top-level test and the sequence of names passed to Run, separated by
+// slashes, with an optional trailing sequence number for disambiguation.
+//
+// The argument to the -run and -bench command-line flags is a slash-separated
+// list of regular expressions that match each name element in turn.
+// For example:
+//
+//     go test -run Foo     # Run top-level tests matching "Foo".
+//     go test -run Foo/A=  # Run subtests of Foo matching "A=".
+//     go test -run /A=1    # Run all subtests of a top-level test matching "A=1".
+//
+// Subtests can also be used to control parallelism. A parent test will only
+// complete once all of its subtests complete. In this example, all tests are
+// run in parallel with each other, and only with each other, regardless of
+// other top-level tests that may be defined:
+//
+//     func TestGroupedParallel(t *testing.T) {
+//         for _, tc := range testCases {
+//             tc := tc // capture range variable
+//             t.Run(tc.Name, func(t *testing.T) {
+//                 t.Parallel()
+//                 ...
+//             })
+//         }
+//     }
+//
+// Run does not return until parallel subtests have completed, providing a way
+// to clean up after a group of parallel tests:
+//
+//     func TestTeardownParallel(t *testing.T) {
+//         // This Run will not return until the parallel tests finish.
+//         t.Run("group", func(t *testing.T) {
+//             t.Run("Test1", parallelTest1)
+//             t.Run("Test2", parallelTest2)
+//             t.Run("Test3", parallelTest3)
+//         })
+//         // <tear-down code>
+//     }
+//
+// Fuzzing
+//
+// Functions of the form
+//     func FuzzXxx(*testing.F)
+// are considered fuzz targets. A fuzz target adds seed inputs with F.Add
+// and then passes a fuzz function to F.Fuzz:
+//
+//     func FuzzDecode(f *testing.F) {
+//         f.Add([]byte("GIF89a"))
+//         f.Fuzz(func(t *testing.T, data []byte) {
+//             img, err := Decode(bytes.NewReader(data))
+//             if err != nil {
+//                 return
+//             }
+//             ...
+//         })
+//     }
+//
+// By default, "go test" runs the fuzz function as a subtest on each seed
+// input and on each input stored in testdata/fuzz/FuzzXxx. With the -fuzz
+// flag, it also generates new inputs, guided by coverage analysis of the
+// package under test, until the fuzz function fails. The failing input is
+// minimized and stored in testdata/fuzz/FuzzXxx, so that it is run by
+// every later "go test".
+//
 // Examples
 //
 // The package also runs and verifies example code. Example functions may
@@ -141,14 +221,18 @@ package testing
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"internal/testlog"
+	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
-	"runtime/pprof"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -169,12 +253,14 @@ var (
 	// Report as tests are run; default is silent for success.
 	chatty           = flag.Bool("test.v", false, "verbose: print additional output")
 	coverProfile     = flag.String("test.coverprofile", "", "write a coverage profile to the named file after execution")
-	match            = flag.String("test.run", "", "regular expression to select tests and examples to run")
+	match            = flag.String("test.run", "", "regular expression to select tests and examples to run; '/' separates subtest names")
 	memProfile       = flag.String("test.memprofile", "", "write a memory profile to the named file after execution")
 	memProfileRate   = flag.Int("test.memprofilerate", 0, "if >=0, sets runtime.MemProfileRate")
 	cpuProfile       = flag.String("test.cpuprofile", "", "write a cpu profile to the named file during execution")
 	blockProfile     = flag.String("test.blockprofile", "", "write a goroutine blocking profile to the named file after execution")
 	blockProfileRate = flag.Int("test.blockprofilerate", 1, "if >= 0, calls runtime.SetBlockProfileRate()")
+	traceFile        = flag.String("test.trace", "", "write an execution trace to the named file after execution")
+	testlogFile      = flag.String("test.testlogfile", "", "write the environment variables and files the tests consult to the named file (for use by the go command)")
 	timeout          = flag.Duration("test.timeout", 0, "if positive, sets an aggregate time limit for all tests")
 	cpuListStr       = flag.String("test.cpu", "", "comma-separated list of number of CPUs to use for each test")
 	parallel         = flag.Int("test.parallel", runtime.GOMAXPROCS(0), "maximum test parallelism")
@@ -187,16 +273,31 @@ var (
 // common holds the elements common between T and B and
 // captures common methods such as Errorf.
 type common struct {
-	mu       sync.RWMutex // guards output and failed
+	mu       sync.RWMutex // guards output, failed, helpers and cleanups
 	output   []byte       // Output generated by test or benchmark.
+	w        io.Writer    // For flushToParent.
+	chatty   bool         // A copy of the chatty flag.
 	failed   bool         // Test or benchmark has failed.
 	skipped  bool         // Test of benchmark has been skipped.
 	finished bool
+	hasSub   bool                // Test or benchmark has called Run.
+	helpers  map[string]struct{} // Functions to be skipped when writing file/line info.
+	cleanups []func()            // Functions to call when the test finishes.
+	runner   string              // Function that calls the test function.
+
+	tempDirOnce sync.Once
+	tempDir     string // Directory holding the directories made by TempDir.
+	tempDirErr  error
+	tempDirSeq  int32
 
+	parent   *common
+	level    int       // Nesting depth of test or benchmark.
+	name     string    // Name of test or benchmark.
 	start    time.Time // Time test or benchmark started
 	duration time.Duration
-	self     interface{}      // To be sent on signal channel when done.
-	signal   chan interface{} // Output for serial tests.
+	barrier  chan bool // To signal parallel subtests they may start.
+	signal   chan bool // To signal a test is done.
+	sub      []*T      // Queue of subtests to be run in parallel.
 }
 
 // Short reports whether the -test.short flag is set.
@@ -209,10 +310,49 @@ func Verbose() bool {
 	return *chatty
 }
 
+// callerName returns the name of the function skip frames above the
+// caller of callerName, or the empty string if there is none.
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return ""
+	}
+	return f.Name()
+}
+
+// frameSkip returns the number of frames to skip, counted from the
+// caller of frameSkip, to pass over the skip frames and then the calls
+// made in functions marked by Helper. If every function up to the test
+// runner is a helper, it stops at the outermost helper call.
+// c.mu must be held.
+func (c *common) frameSkip(skip int) int {
+	if len(c.helpers) == 0 {
+		return skip
+	}
+	for i := skip; ; i++ {
+		name := callerName(i + 1)
+		switch {
+		case name == "":
+			return skip
+		case name == c.runner:
+			return i - 1
+		}
+		if _, ok := c.helpers[name]; !ok {
+			return i
+		}
+	}
+}
+
 // decorate prefixes the string with the file and line of the call site
 // and inserts the final newline if needed and indentation tabs for formatting.
-func decorate(s string) string {
-	_, file, line, ok := runtime.Caller(3) // decorate + log + public function.
+// c.mu must be held.
+func (c *common) decorate(s string) string {
+	skip := c.frameSkip(3) // decorate + log + public function.
+	_, file, line, ok := runtime.Caller(skip)
 	if ok {
 		// Truncate file name at last file name separator.
 		if index := strings.LastIndex(file, "/"); index >= 0 {
@@ -243,6 +383,46 @@ func decorate(s string) string {
 	return buf.String()
 }
 
+// flushToParent writes c.output to the parent after first writing the header
+// with the given format and arguments.
+func (c *common) flushToParent(format string, args ...interface{}) {
+	p := c.parent
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.w, format, args...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.Copy(p.w, bytes.NewReader(c.output))
+	c.output = c.output[:0]
+}
+
+// An indenter appends to the output of a test, indenting each line so
+// that the output of subtests nests under their parent.
+type indenter struct {
+	c *common
+}
+
+func (w indenter) Write(b []byte) (n int, err error) {
+	n = len(b)
+	for len(b) > 0 {
+		end := bytes.IndexByte(b, '\n')
+		if end == -1 {
+			end = len(b)
+		} else {
+			end++
+		}
+		// An indent of 4 spaces will neatly align the dashes with the status
+		// indicator of the parent.
+		const indent = "    "
+		w.c.output = append(w.c.output, indent...)
+		w.c.output = append(w.c.output, b[:end]...)
+		b = b[end:]
+	}
+	return
+}
+
 // fmtDuration returns a string representing d in the form "87.00s".
 func fmtDuration(d time.Duration) string {
 	return fmt.Sprintf("%.2fs", d.Seconds())
@@ -263,6 +443,9 @@ type TB interface {
 	SkipNow()
 	Skipf(format string, args ...interface{})
 	Skipped() bool
+	Helper()
+	Cleanup(func())
+	TempDir() string
 
 	// A private method to prevent users implementing the
 	// interface and so future additions to it will not
@@ -275,16 +458,29 @@ var _ TB = (*B)(nil)
 
 // T is a type passed to Test functions to manage test state and support formatted test logs.
 // Logs are accumulated during execution and dumped to standard error when done.
+//
+// A test ends when its Test function returns or calls any of the methods
+// FailNow, Fatal, Fatalf, SkipNow, Skip, or Skipf. Those methods, as well as
+// the Parallel method, must be called only from the goroutine running the
+// Test function.
+//
+// The other reporting methods, such as the variations of Log and Error,
+// may be called simultaneously from multiple goroutines.
 type T struct {
 	common
-	name          string    // Name of test.
-	startParallel chan bool // Parallel tests will wait on this.
+	isParallel bool
+	inFuzzFn   bool         // Running a fuzz function, which may not call Parallel.
+	context    *testContext // For running tests and subtests.
 }
 
 func (c *common) private() {}
 
 // Fail marks the function as having failed but continues execution.
+// A subtest that fails also marks its parent as having failed.
 func (c *common) Fail() {
+	if c.parent != nil {
+		c.parent.Fail()
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.failed = true
@@ -333,7 +529,7 @@ func (c *common) FailNow() {
 func (c *common) log(s string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.output = append(c.output, decorate(s)...)
+	c.output = append(c.output, c.decorate(s)...)
 }
 
 // Log formats its arguments using default formatting, analogous to Println,
@@ -406,13 +602,105 @@ func (c *common) Skipped() bool {
 	return c.skipped
 }
 
+// Helper marks the calling function as a test helper function.
+// When printing file and line information, that function will be skipped.
+// Helper may be called simultaneously from multiple goroutines.
+func (c *common) Helper() {
+	name := callerName(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.helpers == nil {
+		c.helpers = make(map[string]struct{})
+	}
+	c.helpers[name] = struct{}{}
+}
+
+// Cleanup registers a function to be called when the test and all its
+// subtests complete. Cleanup functions are called in last added,
+// first called order. For a benchmark, they are called each time the
+// benchmark function returns.
+func (c *common) Cleanup(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanups = append(c.cleanups, f)
+}
+
+// runCleanup calls the cleanup functions registered with Cleanup. Each
+// runs on a goroutine of its own, so that one that calls FailNow or
+// SkipNow does not stop the others.
+func (c *common) runCleanup() {
+	for {
+		var f func()
+		c.mu.Lock()
+		if n := len(c.cleanups); n > 0 {
+			f = c.cleanups[n-1]
+			c.cleanups = c.cleanups[:n-1]
+		}
+		c.mu.Unlock()
+		if f == nil {
+			return
+		}
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			f()
+		}()
+		<-done
+	}
+}
+
+// TempDir returns a temporary directory for the test to use.
+// The directory is automatically removed by Cleanup when the test and
+// all its subtests complete. Each call to TempDir returns a new
+// directory; if the directory cannot be created, TempDir calls Fatal.
+func (c *common) TempDir() string {
+	c.tempDirOnce.Do(func() {
+		// ioutil.TempDir does not like path separators in its pattern,
+		// so flatten the names of subtests.
+		pattern := strings.Replace(c.name, "/", "_", -1)
+		c.tempDir, c.tempDirErr = ioutil.TempDir("", pattern)
+		if c.tempDirErr == nil {
+			c.Cleanup(func() {
+				if err := os.RemoveAll(c.tempDir); err != nil {
+					c.Errorf("TempDir: removing %s: %v", c.tempDir, err)
+				}
+			})
+		}
+	})
+	if c.tempDirErr != nil {
+		c.Fatalf("TempDir: %v", c.tempDirErr)
+	}
+	seq := atomic.AddInt32(&c.tempDirSeq, 1)
+	dir := fmt.Sprintf("%s%c%03d", c.tempDir, os.PathSeparator, seq)
+	if err := os.Mkdir(dir, 0777); err != nil {
+		c.Fatalf("TempDir: %v", err)
+	}
+	return dir
+}
+
 // Parallel signals that this test is to be run in parallel with (and only with)
-// other parallel tests.
+// other parallel tests. A parallel subtest does not start until its parent
+// test function has returned, and then runs alongside its parallel siblings.
 func (t *T) Parallel() {
-	t.signal <- (*T)(nil) // Release main testing loop
-	<-t.startParallel     // Wait for serial tests to finish
-	// Assuming Parallel is the first thing a test does, which is reasonable,
-	// reinitialize the test's start time because it's actually starting now.
+	if t.isParallel {
+		panic("testing: t.Parallel called multiple times")
+	}
+	if t.inFuzzFn {
+		panic("testing: t.Parallel called inside a fuzz function")
+	}
+	t.isParallel = true
+
+	// We don't want to include the time we spend waiting for serial tests
+	// in the test duration. Record the elapsed time thus far and reset the
+	// timer afterwards.
+	t.duration += time.Now().Sub(t.start)
+
+	// Add to the list of tests to be released by the parent.
+	t.parent.sub = append(t.parent.sub, t)
+
+	t.signal <- true   // Release calling test.
+	<-t.parent.barrier // Wait for the parent test to complete.
+	t.context.waitParallel()
 	t.start = time.Now()
 }
 
@@ -423,13 +711,13 @@ type InternalTest struct {
 	F    func(*T)
 }
 
-func tRunner(t *T, test *InternalTest) {
-	// When this goroutine is done, either because test.F(t)
+func tRunner(t *T, fn func(t *T)) {
+	// When this goroutine is done, either because fn(t)
 	// returned normally or because a test failure triggered
 	// a call to runtime.Goexit, record the duration and send
 	// a signal saying that the test is done.
 	defer func() {
-		t.duration = time.Now().Sub(t.start)
+		t.duration += time.Now().Sub(t.start)
 		// If the test panicked, print any test output before dying.
 		err := recover()
 		if !t.finished && err == nil {
@@ -437,39 +725,206 @@ func tRunner(t *T, test *InternalTest) {
 		}
 		if err != nil {
 			t.Fail()
+			t.runCleanup()
 			t.report()
+			// Flush the output of the parents too so that it is not
+			// lost when the process dies.
+			for root := t.parent; root != nil && root.parent != nil; root = root.parent {
+				root.duration += time.Now().Sub(root.start)
+				root.flushToParent("--- FAIL: %s (%s)\n", root.name, fmtDuration(root.duration))
+			}
 			panic(err)
 		}
-		t.signal <- t
+
+		if len(t.sub) > 0 {
+			// Run parallel subtests.
+			// Decrease the running count for this test.
+			t.context.release()
+			// Release the parallel subtests.
+			close(t.barrier)
+			// Wait for subtests to complete.
+			for _, sub := range t.sub {
+				<-sub.signal
+			}
+			if !t.isParallel {
+				// Reacquire the count for sequential tests. See comment in Run.
+				t.context.waitParallel()
+			}
+		} else if t.isParallel {
+			// Only release the count for this test if it was run as a parallel
+			// test. See comment in Run method.
+			t.context.release()
+		}
+		t.runCleanup() // Clean up after all subtests have finished.
+		t.report()     // Report after all subtests have finished.
+		t.signal <- true
 	}()
 
+	t.runner = callerName(0)
 	t.start = time.Now()
-	test.F(t)
+	fn(t)
 	t.finished = true
 }
 
-// An internal function but exported because it is cross-package; part of the implementation
-// of the "go test" command.
+// Run runs f as a subtest of t called name. It reports whether f succeeded.
+// Run will block until all its parallel subtests have completed.
+//
+// The name of the subtest is the name of t followed by a slash and
+// name, with spaces replaced by underscores and a sequence number
+// appended if the name is not unique among its siblings. Run does
+// nothing and reports success if the -run flag does not select the
+// subtest.
+func (t *T) Run(name string, f func(t *T)) bool {
+	t.hasSub = true
+	testName, ok := t.context.match.fullName(&t.common, name)
+	if !ok {
+		return true
+	}
+	t = &T{
+		common: common{
+			barrier: make(chan bool),
+			signal:  make(chan bool),
+			name:    testName,
+			parent:  &t.common,
+			level:   t.level + 1,
+			chatty:  t.chatty,
+		},
+		context: t.context,
+	}
+	t.w = indenter{&t.common}
+
+	if t.chatty {
+		// Print directly to root's io.Writer so there is no delay.
+		root := t.parent
+		for ; root.parent != nil; root = root.parent {
+		}
+		root.mu.Lock()
+		fmt.Fprintf(root.w, "=== RUN %s\n", t.name)
+		root.mu.Unlock()
+	}
+	// Instead of reducing the running count of this test before calling the
+	// tRunner and increasing it afterwards, we rely on tRunner keeping the
+	// count correct. This ensures that a sequence of sequential tests runs
+	// without being preempted, even when their parent is a parallel test. This
+	// may especially reduce surprises if *parallel == 1.
+	go tRunner(t, f)
+	<-t.signal
+	return !t.Failed()
+}
+
+// testContext holds all fields that are common to all tests. This includes
+// synchronization primitives to run at most *parallel tests.
+type testContext struct {
+	match *matcher
+
+	mu sync.Mutex
+
+	// Channel used to signal tests that are ready to be run in parallel.
+	startParallel chan bool
+
+	// running is the number of tests currently running in parallel.
+	// This does not include tests that are waiting for subtests to complete.
+	running int
+
+	// numWaiting is the number tests waiting to be run in parallel.
+	numWaiting int
+
+	// maxParallel is a copy of the parallel flag.
+	maxParallel int
+}
+
+func newTestContext(maxParallel int, m *matcher) *testContext {
+	return &testContext{
+		match:         m,
+		startParallel: make(chan bool),
+		maxParallel:   maxParallel,
+		running:       1, // Set the count to 1 for the main (sequential) test.
+	}
+}
+
+// waitParallel blocks until the test may run without exceeding
+// maxParallel running tests.
+func (c *testContext) waitParallel() {
+	c.mu.Lock()
+	if c.running < c.maxParallel {
+		c.running++
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting++
+	c.mu.Unlock()
+	<-c.startParallel
+}
+
+// release gives the running slot of a finished test to a waiting
+// test, if any.
+func (c *testContext) release() {
+	c.mu.Lock()
+	if c.numWaiting == 0 {
+		c.running--
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting--
+	c.mu.Unlock()
+	c.startParallel <- true // Pick a waiting test to be run.
+}
+
+// matchStringOnly is a testDeps that can only match names, for Main.
+type matchStringOnly func(pat, str string) (bool, error)
+
+var errMain = errors.New("testing: unexpected use of func Main")
+
+func (f matchStringOnly) MatchString(pat, str string) (bool, error)   { return f(pat, str) }
+func (f matchStringOnly) StartCPUProfile(w io.Writer) error           { return errMain }
+func (f matchStringOnly) StopCPUProfile()                             {}
+func (f matchStringOnly) StartTrace(w io.Writer) error                { return errMain }
+func (f matchStringOnly) StopTrace()                                  {}
+func (f matchStringOnly) WriteHeapProfile(w io.Writer) error          { return errMain }
+func (f matchStringOnly) WriteProfileTo(string, io.Writer, int) error { return errMain }
+
+// Main is an internal function, part of the implementation of the "go test" command.
+// It was exported because it is cross-package and predates "internal" packages.
+// It is no longer used by "go test" but preserved, as much as possible, for other
+// systems that simulate "go test" using Main, but Main sometimes cannot be updated as
+// new functionality is added to the testing package.
+// Systems simulating "go test" should be updated to use MainStart.
 func Main(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) {
-	os.Exit(MainStart(matchString, tests, benchmarks, examples).Run())
+	os.Exit(MainStart(matchStringOnly(matchString), tests, benchmarks, nil, examples).Run())
 }
 
 // M is a type passed to a TestMain function to run the actual tests.
 type M struct {
-	matchString func(pat, str string) (bool, error)
+	deps        testDeps
 	tests       []InternalTest
 	benchmarks  []InternalBenchmark
+	fuzzTargets []InternalFuzzTarget
 	examples    []InternalExample
 }
 
+// testDeps is an internal interface of functionality that is
+// passed into this package by a test's generated main package.
+// The canonical implementation of this interface is
+// testing/internal/testdeps's TestDeps.
+type testDeps interface {
+	MatchString(pat, str string) (bool, error)
+	StartCPUProfile(io.Writer) error
+	StopCPUProfile()
+	StartTrace(io.Writer) error
+	StopTrace()
+	WriteHeapProfile(io.Writer) error
+	WriteProfileTo(string, io.Writer, int) error
+}
+
 // MainStart is meant for use by tests generated by 'go test'.
 // It is not meant to be called directly and is not subject to the Go 1 compatibility document.
 // It may change signature from release to release.
-func MainStart(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) *M {
+func MainStart(deps testDeps, tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget, examples []InternalExample) *M {
 	return &M{
-		matchString: matchString,
+		deps:        deps,
 		tests:       tests,
 		benchmarks:  benchmarks,
+		fuzzTargets: fuzzTargets,
 		examples:    examples,
 	}
 }
@@ -479,111 +934,80 @@ func (m *M) Run() int {
 	flag.Parse()
 	parseCpuList()
 
-	before()
+	m.before()
 	startAlarm()
 	haveExamples = len(m.examples) > 0
-	testOk := RunTests(m.matchString, m.tests)
-	exampleOk := RunExamples(m.matchString, m.examples)
+	haveFuzzTargets = len(m.fuzzTargets) > 0
+	testOk := RunTests(m.deps.MatchString, m.tests)
+	fuzzTestOk := runFuzzTests(m.deps.MatchString, m.fuzzTargets)
+	exampleOk := RunExamples(m.deps.MatchString, m.examples)
 	stopAlarm()
-	if !testOk || !exampleOk {
+	if !testOk || !fuzzTestOk || !exampleOk || !runFuzzing(m.deps.MatchString, m.fuzzTargets) {
 		fmt.Println("FAIL")
-		after()
+		m.after()
 		return 1
 	}
 	fmt.Println("PASS")
-	RunBenchmarks(m.matchString, m.benchmarks)
-	after()
+	RunBenchmarks(m.deps.MatchString, m.benchmarks)
+	m.after()
 	return 0
 }
 
-func (t *T) report() {
-	dstr := fmtDuration(t.duration)
-	format := "--- %s: %s (%s)\n%s"
-	if t.Failed() {
-		fmt.Printf(format, "FAIL", t.name, dstr, t.output)
-	} else if *chatty {
-		if t.Skipped() {
-			fmt.Printf(format, "SKIP", t.name, dstr, t.output)
+// report flushes the output of a test or fuzz target to its parent,
+// headed by its result.
+func (c *common) report() {
+	if c.parent == nil {
+		return
+	}
+	dstr := fmtDuration(c.duration)
+	format := "--- %s: %s (%s)\n"
+	if c.Failed() {
+		c.flushToParent(format, "FAIL", c.name, dstr)
+	} else if c.chatty {
+		if c.Skipped() {
+			c.flushToParent(format, "SKIP", c.name, dstr)
 		} else {
-			fmt.Printf(format, "PASS", t.name, dstr, t.output)
+			c.flushToParent(format, "PASS", c.name, dstr)
 		}
 	}
 }
 
 func RunTests(matchString func(pat, str string) (bool, error), tests []InternalTest) (ok bool) {
 	ok = true
-	if len(tests) == 0 && !haveExamples {
+	if len(tests) == 0 && !haveExamples && !haveFuzzTargets {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 		return
 	}
 	for _, procs := range cpuList {
 		runtime.GOMAXPROCS(procs)
-		// We build a new channel tree for each run of the loop.
-		// collector merges in one channel all the upstream signals from parallel tests.
-		// If all tests pump to the same channel, a bug can occur where a test
-		// kicks off a goroutine that Fails, yet the test still delivers a completion signal,
-		// which skews the counting.
-		var collector = make(chan interface{})
-
-		numParallel := 0
-		startParallel := make(chan bool)
-
-		for i := 0; i < len(tests); i++ {
-			matched, err := matchString(*match, tests[i].Name)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "testing: invalid regexp for -test.run: %s\n", err)
-				os.Exit(1)
-			}
-			if !matched {
-				continue
-			}
-			testName := tests[i].Name
-			if procs != 1 {
-				testName = fmt.Sprintf("%s-%d", tests[i].Name, procs)
-			}
-			t := &T{
-				common: common{
-					signal: make(chan interface{}),
-				},
-				name:          testName,
-				startParallel: startParallel,
-			}
-			t.self = t
-			if *chatty {
-				fmt.Printf("=== RUN %s\n", t.name)
-			}
-			go tRunner(t, &tests[i])
-			out := (<-t.signal).(*T)
-			if out == nil { // Parallel run.
-				go func() {
-					collector <- <-t.signal
-				}()
-				numParallel++
-				continue
-			}
-			t.report()
-			ok = ok && !out.Failed()
+		ctx := newTestContext(*parallel, newMatcher(matchString, *match, "-test.run"))
+		// The top-level tests run as subtests of a root T, which
+		// waits for any parallel ones before it returns.
+		t := &T{
+			common: common{
+				signal:  make(chan bool),
+				barrier: make(chan bool),
+				w:       os.Stdout,
+				chatty:  *chatty,
+			},
+			context: ctx,
 		}
-
-		running := 0
-		for numParallel+running > 0 {
-			if running < *parallel && numParallel > 0 {
-				startParallel <- true
-				running++
-				numParallel--
-				continue
+		tRunner(t, func(t *T) {
+			for _, test := range tests {
+				t.Run(test.Name, test.F)
 			}
-			t := (<-collector).(*T)
-			t.report()
-			ok = ok && !t.Failed()
-			running--
-		}
+			// Run catching the signal rather than the tRunner as a separate
+			// goroutine to avoid adding a goroutine during the sequential
+			// phase as this pollutes the stacktrace output when aborting.
+			go func() { <-t.signal }()
+		})
+		ok = ok && !t.Failed()
 	}
 	return
 }
 
 // before runs before all testing.
-func before() {
+func (m *M) before() {
 	if *memProfileRate > 0 {
 		runtime.MemProfileRate = *memProfileRate
 	}
@@ -593,13 +1017,26 @@ func before() {
 			fmt.Fprintf(os.Stderr, "testing: %s", err)
 			return
 		}
-		if err := pprof.StartCPUProfile(f); err != nil {
+		if err := m.deps.StartCPUProfile(f); err != nil {
 			fmt.Fprintf(os.Stderr, "testing: can't start cpu profile: %s", err)
 			f.Close()
 			return
 		}
 		// Could save f so after can call f.Close; not worth the effort.
 	}
+	if *traceFile != "" {
+		f, err := os.Create(toOutputDir(*traceFile))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testing: %s", err)
+			return
+		}
+		if err := m.deps.StartTrace(f); err != nil {
+			fmt.Fprintf(os.Stderr, "testing: can't start tracing: %s", err)
+			f.Close()
+			return
+		}
+		// Could save f so after can call f.Close; not worth the effort.
+	}
 	if *blockProfile != "" && *blockProfileRate >= 0 {
 		runtime.SetBlockProfileRate(*blockProfileRate)
 	}
@@ -607,12 +1044,51 @@ func before() {
 		fmt.Fprintf(os.Stderr, "testing: cannot use -test.coverprofile because test binary was not built with coverage enabled\n")
 		os.Exit(2)
 	}
+	if *testlogFile != "" {
+		f, err := os.Create(*testlogFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testing: %s\n", err)
+			os.Exit(2)
+		}
+		f.WriteString("# test log\n")
+		testlog.SetLogger(&testLog{w: f, seen: make(map[string]bool)})
+	}
+}
+
+// testLog records the environment variables and files consulted
+// through package os, one per line, for the go command to check
+// before it reuses the cached result of a test run. Each line is
+// written at once, so that the log is complete even if a test
+// exits the process.
+type testLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seen map[string]bool
 }
 
+func (l *testLog) add(op, name string) {
+	line := op + " " + name + "\n"
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[line] {
+		return
+	}
+	l.seen[line] = true
+	io.WriteString(l.w, line)
+}
+
+func (l *testLog) Getenv(key string) { l.add("getenv", key) }
+func (l *testLog) Stat(file string)  { l.add("stat", file) }
+func (l *testLog) Open(file string)  { l.add("open", file) }
+func (l *testLog) Chdir(dir string)  { l.add("chdir", dir) }
+
 // after runs after all testing.
-func after() {
+func (m *M) after() {
 	if *cpuProfile != "" {
-		pprof.StopCPUProfile() // flushes profile to disk
+		m.deps.StopCPUProfile() // flushes profile to disk
+	}
+	if *traceFile != "" {
+		m.deps.StopTrace() // flushes trace to disk
 	}
 	if *memProfile != "" {
 		f, err := os.Create(toOutputDir(*memProfile))
@@ -621,7 +1097,7 @@ func after() {
 			os.Exit(2)
 		}
 		runtime.GC() // materialize all statistics
-		if err = pprof.WriteHeapProfile(f); err != nil {
+		if err = m.deps.WriteHeapProfile(f); err != nil {
 			fmt.Fprintf(os.Stderr, "testing: can't write %s: %s\n", *memProfile, err)
 			os.Exit(2)
 		}
@@ -633,7 +1109,7 @@ func after() {
 			fmt.Fprintf(os.Stderr, "testing: %s\n", err)
 			os.Exit(2)
 		}
-		if err = pprof.Lookup("block").WriteTo(f, 0); err != nil {
+		if err = m.deps.WriteProfileTo("block", f, 0); err != nil {
 			fmt.Fprintf(os.Stderr, "testing: can't write %s: %s\n", *blockProfile, err)
 			os.Exit(2)
 		}